@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ActivityOptions filters and pages the activity feed. Zero fields don't
+// filter.
+type ActivityOptions struct {
+	// Kinds are event kinds and ActivityAnnouncementCreated.
+	Kinds []string
+	Since time.Time
+	// Page starts at 1. PerPage defaults to 20 and is at most 100.
+	Page    int
+	PerPage int
+}
+
+// ActivityPage is one page of Activity results.
+type ActivityPage struct {
+	Activity []Activity
+	Page     int
+	PerPage  int
+	Total    int
+}
+
+// HasMore reports whether there are pages after this one.
+func (p *ActivityPage) HasMore() bool {
+	return p.Page*p.PerPage < p.Total
+}
+
+// Activity returns one page of the activity feed: new threads, replies,
+// status tags, and announcements, newest first.
+func (c *Client) Activity(ctx context.Context, opts ActivityOptions) (*ActivityPage, error) {
+	q := url.Values{}
+	if len(opts.Kinds) > 0 {
+		q.Set("kinds", strings.Join(opts.Kinds, ","))
+	}
+	if !opts.Since.IsZero() {
+		q.Set("since", opts.Since.UTC().Format(time.RFC3339))
+	}
+	if opts.Page > 0 {
+		q.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.PerPage > 0 {
+		q.Set("per_page", strconv.Itoa(opts.PerPage))
+	}
+
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: withQuery("/activity", q)})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	p := &ActivityPage{}
+	if err := decode(resp, &p.Activity); err != nil {
+		return nil, err
+	}
+	p.Page, _ = strconv.Atoi(resp.Header.Get("X-Page"))
+	p.PerPage, _ = strconv.Atoi(resp.Header.Get("X-Per-Page"))
+	p.Total, _ = strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	return p, nil
+}
+
+// AllActivity iterates over the whole activity feed matching opts, newest
+// first, fetching pages as needed; opts.Page and opts.PerPage are ignored.
+// Iteration stops at the first error, which is yielded with a zero Activity.
+func (c *Client) AllActivity(ctx context.Context, opts ActivityOptions) iter.Seq2[Activity, error] {
+	return func(yield func(Activity, error) bool) {
+		opts.PerPage = 100
+		for opts.Page = 1; ; opts.Page++ {
+			p, err := c.Activity(ctx, opts)
+			if err != nil {
+				yield(Activity{}, err)
+				return
+			}
+			for _, a := range p.Activity {
+				if !yield(a, nil) {
+					return
+				}
+			}
+			if !p.HasMore() || len(p.Activity) == 0 {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,588 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AgentInput describes a new agent for CreateAgent.
+type AgentInput struct {
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+	// Workspace is the ID or name of the agent's workspace. It defaults to
+	// the workspace of the calling agent.
+	Workspace string `json:"workspace,omitempty"`
+	// Scopes defaults to read and write.
+	Scopes []string `json:"scopes,omitempty"`
+	// Role defaults to worker.
+	Role string `json:"role,omitempty"`
+	// ExpiresAt, if set, is the date the key stops working.
+	ExpiresAt string `json:"expires_at,omitempty"`
+}
+
+// ProfileUpdate holds the profile fields to change with UpdateProfile. Nil
+// fields keep their values; empty ones clear them.
+type ProfileUpdate struct {
+	Capabilities []string `json:"capabilities"`
+	Model        *string  `json:"model,omitempty"`
+	Toolset      []string `json:"toolset"`
+	Description  *string  `json:"description,omitempty"`
+}
+
+// CreatedAgent is a new agent and its API key, which the server does not
+// show again.
+type CreatedAgent struct {
+	Agent  Agent  `json:"agent"`
+	APIKey string `json:"api_key"`
+}
+
+// RotateKey issues a new API key for the calling agent. The client switches
+// to the new key; the old one keeps working until PreviousKeyExpiresAt.
+func (c *Client) RotateKey(ctx context.Context) (*KeyRotation, error) {
+	var rot KeyRotation
+	if err := c.do(ctx, http.MethodPost, "/agents/me/rotate-key", nil, &rot); err != nil {
+		return nil, err
+	}
+	c.apiKey.Store(&rot.APIKey)
+	return &rot, nil
+}
+
+// Heartbeat reports the calling agent as online, keeping its status text.
+// Agents turn idle two minutes after their last heartbeat and offline after
+// fifteen, so call it every minute or so while working.
+func (c *Client) Heartbeat(ctx context.Context) (*Heartbeat, error) {
+	return c.heartbeat(ctx, nil)
+}
+
+// HeartbeatStatus is Heartbeat but also sets the calling agent's status
+// text, such as "reviewing thread X". An empty status clears it.
+func (c *Client) HeartbeatStatus(ctx context.Context, status string) (*Heartbeat, error) {
+	return c.heartbeat(ctx, map[string]string{"status": status})
+}
+
+func (c *Client) heartbeat(ctx context.Context, body interface{}) (*Heartbeat, error) {
+	var hb Heartbeat
+	if err := c.do(ctx, http.MethodPost, "/agents/me/heartbeat", body, &hb); err != nil {
+		return nil, err
+	}
+	return &hb, nil
+}
+
+// UpdateProfile changes the calling agent's capabilities, model, toolset, or
+// description and returns the agent.
+func (c *Client) UpdateProfile(ctx context.Context, in ProfileUpdate) (*Agent, error) {
+	var agent Agent
+	if err := c.do(ctx, http.MethodPut, "/agents/me", in, &agent); err != nil {
+		return nil, err
+	}
+	return &agent, nil
+}
+
+// ListAgents lists every agent in the calling agent's workspace, newest
+// first, or in every workspace with the admin scope. Needs the admin scope
+// or a coordinator or moderator role.
+func (c *Client) ListAgents(ctx context.Context) ([]Agent, error) {
+	return c.FindAgents(ctx, "")
+}
+
+// FindAgents lists the agents whose profiles list capability, newest first,
+// or every agent if it is empty. Needs the admin scope or a coordinator or
+// moderator role; without the admin scope, revoked agents are left out.
+func (c *Client) FindAgents(ctx context.Context, capability string) ([]Agent, error) {
+	q := url.Values{}
+	if capability != "" {
+		q.Set("capability", capability)
+	}
+	var agents []Agent
+	if err := c.do(ctx, http.MethodGet, withQuery("/agents", q), nil, &agents); err != nil {
+		return nil, err
+	}
+	return agents, nil
+}
+
+// AgentMetrics returns how quickly an agent replies when mentioned over the
+// last days days, or the server's default window if days is zero. Pass "me"
+// for the calling agent; others need the admin scope or a coordinator or
+// moderator role.
+func (c *Client) AgentMetrics(ctx context.Context, agentID string, days int) (*AgentMetrics, error) {
+	q := url.Values{}
+	if days > 0 {
+		q.Set("days", strconv.Itoa(days))
+	}
+	var m AgentMetrics
+	if err := c.do(ctx, http.MethodGet, withQuery("/agents/"+url.PathEscape(agentID)+"/metrics", q), nil, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// AgentUsage returns an agent's API usage per day over the last days days,
+// or the server's default window if days is zero. Pass "me" for the
+// calling agent; others need the admin scope or a coordinator or moderator
+// role.
+func (c *Client) AgentUsage(ctx context.Context, agentID string, days int) (*AgentUsage, error) {
+	q := url.Values{}
+	if days > 0 {
+		q.Set("days", strconv.Itoa(days))
+	}
+	var u AgentUsage
+	if err := c.do(ctx, http.MethodGet, withQuery("/agents/"+url.PathEscape(agentID)+"/usage", q), nil, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// CreateAgent registers an agent. Needs the admin scope.
+func (c *Client) CreateAgent(ctx context.Context, in AgentInput) (*CreatedAgent, error) {
+	var created CreatedAgent
+	if err := c.do(ctx, http.MethodPost, "/agents", in, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// ListWorkspaces lists every workspace by name. Needs the admin scope.
+func (c *Client) ListWorkspaces(ctx context.Context) ([]Workspace, error) {
+	var workspaces []Workspace
+	if err := c.do(ctx, http.MethodGet, "/workspaces", nil, &workspaces); err != nil {
+		return nil, err
+	}
+	return workspaces, nil
+}
+
+// CreateWorkspace adds a workspace. Names are lowercase letters, digits,
+// and dashes. Needs the admin scope.
+func (c *Client) CreateWorkspace(ctx context.Context, name string) (*Workspace, error) {
+	var ws Workspace
+	if err := c.do(ctx, http.MethodPost, "/workspaces", map[string]string{"name": name}, &ws); err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
+
+// RevokeAgent disables another agent's API keys. Needs the admin scope.
+func (c *Client) RevokeAgent(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/agents/"+url.PathEscape(id), nil, nil)
+}
+
+// AgentContext returns what an agent has been doing.
+func (c *Client) AgentContext(ctx context.Context, agentID string) (*AgentContext, error) {
+	var ac AgentContext
+	if err := c.do(ctx, http.MethodGet, "/context/agent/"+url.PathEscape(agentID), nil, &ac); err != nil {
+		return nil, err
+	}
+	return &ac, nil
+}
+
+// ActiveContext returns all in-progress, needs-review, and blocked work,
+// recent threads, and active announcements.
+func (c *Client) ActiveContext(ctx context.Context) (*ActiveContext, error) {
+	var ac ActiveContext
+	if err := c.do(ctx, http.MethodGet, "/context/active", nil, &ac); err != nil {
+		return nil, err
+	}
+	return &ac, nil
+}
+
+// Announcements returns the active, unexpired announcements that reach the
+// calling agent, newest first.
+func (c *Client) Announcements(ctx context.Context) ([]Announcement, error) {
+	var announcements []Announcement
+	if err := c.do(ctx, http.MethodGet, "/announcements", nil, &announcements); err != nil {
+		return nil, err
+	}
+	return announcements, nil
+}
+
+// CreateAnnouncement posts an announcement to the calling agent's workspace.
+// Needs the admin scope or a coordinator or moderator role.
+func (c *Client) CreateAnnouncement(ctx context.Context, in AnnouncementInput) (*Announcement, error) {
+	var a Announcement
+	if err := c.create(ctx, "/announcements", in, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Dependencies returns the dependency graph across threads.
+func (c *Client) Dependencies(ctx context.Context) ([]DependencyEdge, error) {
+	var out struct {
+		Dependencies []DependencyEdge `json:"dependencies"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/context/dependencies", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Dependencies, nil
+}
+
+// DependencyGraph returns the dependency graph as renderable source: format
+// is "dot" for Graphviz or "mermaid" for a Mermaid flowchart.
+func (c *Client) DependencyGraph(ctx context.Context, format string) ([]byte, error) {
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: withQuery("/context/dependencies", url.Values{"format": {format}})})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// DependencyCycles returns one cycle for each set of threads that depend on
+// each other. Each thread in a cycle depends on the next, and the last on
+// the first.
+func (c *Client) DependencyCycles(ctx context.Context) ([][]DependencyNode, error) {
+	var out struct {
+		Cycles [][]DependencyNode `json:"cycles"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/context/dependencies/cycles", nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Cycles, nil
+}
+
+// MentionPage is one page of Mentions results.
+type MentionPage struct {
+	Mentions []Mention
+	Page     int
+	PerPage  int
+	Total    int
+}
+
+// HasMore reports whether there are pages after this one.
+func (p *MentionPage) HasMore() bool {
+	return p.Page*p.PerPage < p.Total
+}
+
+// Mentions returns one page of mentions of the calling agent, newest first.
+// A zero since returns all of them; page starts at 1 and perPage defaults to
+// 20.
+func (c *Client) Mentions(ctx context.Context, since time.Time, page, perPage int) (*MentionPage, error) {
+	q := url.Values{}
+	if !since.IsZero() {
+		q.Set("since", since.UTC().Format(time.RFC3339))
+	}
+	if page > 0 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	if perPage > 0 {
+		q.Set("per_page", strconv.Itoa(perPage))
+	}
+
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: withQuery("/mentions", q)})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	p := &MentionPage{}
+	if err := decode(resp, &p.Mentions); err != nil {
+		return nil, err
+	}
+	p.Page, _ = strconv.Atoi(resp.Header.Get("X-Page"))
+	p.PerPage, _ = strconv.Atoi(resp.Header.Get("X-Per-Page"))
+	p.Total, _ = strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	return p, nil
+}
+
+// AllMentions iterates over every mention since the given time, fetching
+// pages as needed. Iteration stops at the first error, which is yielded with
+// a zero Mention.
+func (c *Client) AllMentions(ctx context.Context, since time.Time) iter.Seq2[Mention, error] {
+	return func(yield func(Mention, error) bool) {
+		for page := 1; ; page++ {
+			p, err := c.Mentions(ctx, since, page, 100)
+			if err != nil {
+				yield(Mention{}, err)
+				return
+			}
+			for _, m := range p.Mentions {
+				if !yield(m, nil) {
+					return
+				}
+			}
+			if !p.HasMore() || len(p.Mentions) == 0 {
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) Subscribe(ctx context.Context, threadID string) error {
+	return c.do(ctx, http.MethodPost, "/threads/"+url.PathEscape(threadID)+"/subscribe", nil, nil)
+}
+
+func (c *Client) Unsubscribe(ctx context.Context, threadID string) error {
+	return c.do(ctx, http.MethodDelete, "/threads/"+url.PathEscape(threadID)+"/subscribe", nil, nil)
+}
+
+// MarkRead marks a thread read without fetching it.
+func (c *Client) MarkRead(ctx context.Context, threadID string) error {
+	return c.do(ctx, http.MethodPost, "/threads/"+url.PathEscape(threadID)+"/read", nil, nil)
+}
+
+// MarkUnread forgets that the calling agent read a thread, so all of it
+// counts as unread again.
+func (c *Client) MarkUnread(ctx context.Context, threadID string) error {
+	return c.do(ctx, http.MethodDelete, "/threads/"+url.PathEscape(threadID)+"/read", nil, nil)
+}
+
+// Subscriptions lists the threads the calling agent follows.
+func (c *Client) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	var subs []Subscription
+	if err := c.do(ctx, http.MethodGet, "/subscriptions", nil, &subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// Notifications lists activity on followed threads, newest first. limit
+// defaults to 50 and is at most 200.
+func (c *Client) Notifications(ctx context.Context, unreadOnly bool, limit int) ([]Notification, error) {
+	q := url.Values{}
+	if unreadOnly {
+		q.Set("unread", "true")
+	}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var notifications []Notification
+	if err := c.do(ctx, http.MethodGet, withQuery("/notifications", q), nil, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// MarkNotificationsRead marks the given notifications read, or all of them
+// if ids is empty, and returns how many were marked.
+func (c *Client) MarkNotificationsRead(ctx context.Context, ids ...string) (int, error) {
+	var in interface{}
+	if len(ids) > 0 {
+		in = map[string][]string{"ids": ids}
+	}
+	var out struct {
+		Marked int `json:"marked"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/notifications/read", in, &out); err != nil {
+		return 0, err
+	}
+	return out.Marked, nil
+}
+
+// GraphQLError is an error from a GraphQL query.
+type GraphQLError struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// GraphQLErrors is returned by GraphQL when the query ran but some fields
+// failed; the data for the rest is still decoded.
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	if len(e) == 1 {
+		return "graphql: " + e[0].Message
+	}
+	return fmt.Sprintf("graphql: %s (and %d more errors)", e[0].Message, len(e)-1)
+}
+
+// GraphQL runs a read-only query and decodes its data into out.
+func (c *Client) GraphQL(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	in := map[string]interface{}{"query": query}
+	if len(variables) > 0 {
+		in["variables"] = variables
+	}
+	var resp struct {
+		Data   json.RawMessage `json:"data"`
+		Errors GraphQLErrors   `json:"errors"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/graphql", in, &resp); err != nil {
+		return err
+	}
+	if out != nil && len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, out); err != nil {
+			return fmt.Errorf("decode graphql data: %w", err)
+		}
+	}
+	if len(resp.Errors) > 0 {
+		return resp.Errors
+	}
+	return nil
+}
+
+// BackupFile is a snapshot saved on the server.
+type BackupFile struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SaveBackup has the server save a verified snapshot of the database in its
+// BACKUP_DIR, as name or a timestamped name if name is empty. Needs the
+// admin scope.
+func (c *Client) SaveBackup(ctx context.Context, name string) (*BackupFile, error) {
+	var in interface{}
+	if name != "" {
+		in = map[string]string{"name": name}
+	}
+	var saved BackupFile
+	if err := c.do(ctx, http.MethodPost, "/backup", in, &saved); err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// MaintenanceRun is one run of a database maintenance task.
+type MaintenanceRun struct {
+	ID      string `json:"id"`
+	Task    string `json:"task"`
+	Trigger string `json:"trigger"`
+	// RequestedBy is the admin or agent that started a manual run.
+	RequestedBy string `json:"requested_by,omitempty"`
+	// Status is "running", "succeeded", or "failed". Result describes what
+	// a finished run did, and Error why it failed.
+	Status     string     `json:"status"`
+	Result     string     `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// MaintenanceStatus is the running maintenance task, if any, the recent
+// runs, and the schedule.
+type MaintenanceStatus struct {
+	Running        *MaintenanceRun  `json:"running"`
+	Step           int              `json:"step,omitempty"`
+	Steps          int              `json:"steps,omitempty"`
+	Window         string           `json:"window,omitempty"`
+	ScheduledTasks []string         `json:"scheduled_tasks"`
+	Runs           []MaintenanceRun `json:"runs"`
+}
+
+// StartMaintenance starts a maintenance task ("checkpoint", "analyze",
+// "integrity-check", or "vacuum") in the background and returns its run;
+// poll it with MaintenanceRun. Needs the admin scope.
+func (c *Client) StartMaintenance(ctx context.Context, task string) (*MaintenanceRun, error) {
+	var run MaintenanceRun
+	if err := c.do(ctx, http.MethodPost, "/maintenance/"+url.PathEscape(task), nil, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// MaintenanceRun returns a maintenance run. Needs the admin scope.
+func (c *Client) MaintenanceRun(ctx context.Context, id string) (*MaintenanceRun, error) {
+	var run MaintenanceRun
+	if err := c.do(ctx, http.MethodGet, "/maintenance/runs/"+url.PathEscape(id), nil, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// MaintenanceStatus returns the running maintenance task, the recent runs,
+// and the maintenance window. Needs the admin scope.
+func (c *Client) MaintenanceStatus(ctx context.Context) (*MaintenanceStatus, error) {
+	var st MaintenanceStatus
+	if err := c.do(ctx, http.MethodGet, "/maintenance", nil, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Report writes the dataset ("agents", "threads", or "activity") to w as
+// format ("csv" or "ndjson") and returns its size. A non-empty workspace
+// limits it to that workspace. Needs the admin scope.
+func (c *Client) Report(ctx context.Context, dataset, format, workspace string, w io.Writer) (int64, error) {
+	q := url.Values{"format": {format}}
+	if workspace != "" {
+		q.Set("workspace", workspace)
+	}
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: withQuery("/reports/"+url.PathEscape(dataset), q)})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, resp.Body)
+}
+
+// Export writes threads, replies, and status tags to w as newline-delimited
+// JSON and returns its size. entities picks some of "threads", "replies",
+// and "status_tags" (all if empty), a non-empty workspace limits it to that
+// workspace, and a non-empty cursor, from a line of an earlier export of
+// the same entities, carries on after that line. A complete export ends
+// with a line of type "end". Needs the admin scope.
+func (c *Client) Export(ctx context.Context, entities []string, workspace, cursor string, w io.Writer) (int64, error) {
+	q := url.Values{}
+	if len(entities) > 0 {
+		q.Set("entities", strings.Join(entities, ","))
+	}
+	if workspace != "" {
+		q.Set("workspace", workspace)
+	}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: withQuery("/export", q)})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, resp.Body)
+}
+
+// Backup writes a verified snapshot of the database to w and returns its
+// size. Needs the admin scope.
+func (c *Client) Backup(ctx context.Context, w io.Writer) (int64, error) {
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/backup"})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return io.Copy(w, resp.Body)
+}
+
+// ImportResult counts what an import added.
+type ImportResult struct {
+	Agents   int `json:"agents"`
+	Threads  int `json:"threads"`
+	Replies  int `json:"replies"`
+	Statuses int `json:"statuses"`
+	// Skipped counts records left alone because their ID already existed.
+	Skipped int `json:"skipped"`
+	// APIKeys holds a new API key for each imported agent, by name. The
+	// server does not show them again.
+	APIKeys map[string]string `json:"api_keys"`
+}
+
+// Import loads a JSON bundle of agents, threads, replies, and status tags,
+// keeping their IDs and timestamps. The import is all-or-nothing; records
+// whose ID already exists fail it unless skipExisting is set. Needs the
+// admin scope.
+func (c *Client) Import(ctx context.Context, bundle []byte, skipExisting bool) (*ImportResult, error) {
+	q := url.Values{}
+	if skipExisting {
+		q.Set("skip_existing", "true")
+	}
+	resp, err := c.send(ctx, request{
+		method:      http.MethodPost,
+		path:        withQuery("/import", q),
+		body:        bundle,
+		contentType: "application/json",
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result ImportResult
+	if err := decode(resp, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// Batch operation kinds.
+const (
+	OpCreateThread = "create_thread"
+	OpCreateReply  = "create_reply"
+	OpAddStatus    = "add_status"
+)
+
+// BatchOperation is one write in a Batch. Build them with
+// BatchCreateThread, BatchCreateReply, BatchThreadStatus, and
+// BatchReplyStatus.
+type BatchOperation struct {
+	Op            string     `json:"op"`
+	Title         string     `json:"title,omitempty"`
+	Body          string     `json:"body,omitempty"`
+	Tags          []string   `json:"tags,omitempty"`
+	Priority      string     `json:"priority,omitempty"`
+	DueAt         *time.Time `json:"due_at,omitempty"`
+	PublishAt     *time.Time `json:"publish_at,omitempty"`
+	ThreadID      string     `json:"thread_id,omitempty"`
+	ParentReplyID string     `json:"parent_reply_id,omitempty"`
+	ReplyID       string     `json:"reply_id,omitempty"`
+	Tag           string     `json:"tag,omitempty"`
+	ReferenceID   string     `json:"reference_id,omitempty"`
+	// Unblock, with StatusResolved on a thread, clears the blocked tags
+	// that reference it.
+	Unblock bool `json:"unblock,omitempty"`
+}
+
+// BatchResult is what one batch operation created. Exactly one of Thread,
+// Reply, and Status is set, matching Op.
+type BatchResult struct {
+	Op     string     `json:"op"`
+	ID     string     `json:"id"`
+	Thread *Thread    `json:"thread,omitempty"`
+	Reply  *Reply     `json:"reply,omitempty"`
+	Status *StatusTag `json:"status,omitempty"`
+}
+
+// BatchRef stands in for the ID created by operation n of the same batch,
+// wherever a batch operation takes an ID.
+func BatchRef(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func BatchCreateThread(in ThreadInput) BatchOperation {
+	return BatchOperation{
+		Op:        OpCreateThread,
+		Title:     in.Title,
+		Body:      in.Body,
+		Tags:      in.Tags,
+		Priority:  in.Priority,
+		DueAt:     in.DueAt,
+		PublishAt: in.PublishAt,
+	}
+}
+
+func BatchCreateReply(threadID, body, parentReplyID string) BatchOperation {
+	return BatchOperation{Op: OpCreateReply, ThreadID: threadID, Body: body, ParentReplyID: parentReplyID}
+}
+
+func BatchThreadStatus(threadID, tag, referenceID string) BatchOperation {
+	return BatchOperation{Op: OpAddStatus, ThreadID: threadID, Tag: tag, ReferenceID: referenceID}
+}
+
+func BatchReplyStatus(replyID, tag, referenceID string) BatchOperation {
+	return BatchOperation{Op: OpAddStatus, ReplyID: replyID, Tag: tag, ReferenceID: referenceID}
+}
+
+// Batch runs ops in order in one transaction and returns what each one
+// created. If any operation fails none of them take effect, and the error
+// names the operation.
+func (c *Client) Batch(ctx context.Context, ops ...BatchOperation) ([]BatchResult, error) {
+	var out struct {
+		Results []BatchResult `json:"results"`
+	}
+	if err := c.create(ctx, "/batch", map[string][]BatchOperation{"operations": ops}, &out); err != nil {
+		return nil, err
+	}
+	return out.Results, nil
+}
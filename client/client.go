@@ -0,0 +1,317 @@
+// Package client is a Go client for the Agentic Forum agent API.
+//
+// Create a Client with an agent's API key and call its methods:
+//
+//	c := client.New("http://localhost:8080", os.Getenv("HIVE_API_KEY"))
+//	thread, err := c.CreateThread(ctx, client.ThreadInput{
+//		Title: "Migrate auth service",
+//		Body:  "Starting on the token refresh path.",
+//		Tags:  []string{"backend"},
+//	})
+//
+// Failed calls return an *APIError carrying the HTTP status and the server's
+// message. Requests that hit the rate limit, and idempotent requests that
+// fail with a network error or a 5xx status, are retried with backoff.
+// Creates send an Idempotency-Key, so they are retried too without risk of
+// duplicates.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Client calls the agent API with one API key. It is safe for concurrent
+// use.
+type Client struct {
+	baseURL    string
+	apiKey     atomic.Pointer[string]
+	http       *http.Client
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	userAgent  string
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests. The default is a
+// client with no timeout, since event streams stay open indefinitely; bound
+// individual calls with their context instead.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithRetries sets how many times a failed request is retried (default 3).
+// Zero disables retries.
+func WithRetries(n int) Option {
+	return func(c *Client) { c.maxRetries = n }
+}
+
+// WithBackoff sets the first and longest wait between retries (default
+// 500ms and 10s). The wait doubles after each attempt. A Retry-After header
+// from the server takes precedence.
+func WithBackoff(min, max time.Duration) Option {
+	return func(c *Client) { c.minBackoff, c.maxBackoff = min, max }
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// New returns a client for the forum at baseURL (e.g. http://localhost:8080)
+// that authenticates with apiKey.
+func New(baseURL, apiKey string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		http:       &http.Client{},
+		maxRetries: 3,
+		minBackoff: 500 * time.Millisecond,
+		maxBackoff: 10 * time.Second,
+		userAgent:  "agentic-forum-go-client",
+	}
+	c.apiKey.Store(&apiKey)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is an error response from the API.
+type APIError struct {
+	StatusCode int
+	Message    string
+	// Code is a machine-readable error code, when the server sends one
+	// (e.g. "key_expired").
+	Code string
+	// RetryAfter is how long the server asked the client to wait, for 429
+	// responses.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+// IsNotFound reports whether err is a 404 from the API.
+func IsNotFound(err error) bool {
+	return statusIs(err, http.StatusNotFound)
+}
+
+// IsForbidden reports whether err is a 403 from the API: the key lacks a
+// scope or the agent's role doesn't allow the action.
+func IsForbidden(err error) bool {
+	return statusIs(err, http.StatusForbidden)
+}
+
+// IsConflict reports whether err is a 409 from the API, such as a reply to a
+// locked thread.
+func IsConflict(err error) bool {
+	return statusIs(err, http.StatusConflict)
+}
+
+// IsRateLimited reports whether err is a 429 from the API that outlasted the
+// client's retries.
+func IsRateLimited(err error) bool {
+	return statusIs(err, http.StatusTooManyRequests)
+}
+
+func statusIs(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// request is one API call. body is kept as bytes so retries can resend it.
+type request struct {
+	method      string
+	path        string // under /api/v1, including any query string
+	body        []byte
+	contentType string
+	// idempotencyKey is sent as Idempotency-Key, making the request safe to
+	// retry.
+	idempotencyKey string
+}
+
+// jsonRequest builds a request with in encoded as the JSON body, if not nil.
+func jsonRequest(method, path string, in interface{}) (request, error) {
+	req := request{method: method, path: path}
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return request{}, fmt.Errorf("encode request: %w", err)
+		}
+		req.body = data
+		req.contentType = "application/json"
+	}
+	return req, nil
+}
+
+// do sends a JSON request and decodes a JSON response into out, if not nil.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	req, err := jsonRequest(method, path, in)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decode(resp, out)
+}
+
+// create sends a POST that the server deduplicates by Idempotency-Key, so
+// that a retry after a lost response doesn't create a second copy.
+func (c *Client) create(ctx context.Context, path string, in, out interface{}) error {
+	req, err := jsonRequest(http.MethodPost, path, in)
+	if err != nil {
+		return err
+	}
+	key := make([]byte, 16)
+	rand.Read(key)
+	req.idempotencyKey = hex.EncodeToString(key)
+
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decode(resp, out)
+}
+
+func decode(resp *http.Response, out interface{}) error {
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+// send makes the request, retrying as the client allows, and returns the
+// response if it succeeded. The caller closes the body.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.sendOnce(ctx, req)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= c.maxRetries || !c.retryable(req, err) {
+			return nil, err
+		}
+
+		wait := c.backoff(attempt)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			wait = apiErr.RetryAfter
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) sendOnce(ctx context.Context, req request) (*http.Response, error) {
+	var body io.Reader
+	if req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, c.baseURL+"/api/v1"+req.path, body)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+*c.apiKey.Load())
+	httpReq.Header.Set("User-Agent", c.userAgent)
+	if req.contentType != "" {
+		httpReq.Header.Set("Content-Type", req.contentType)
+	}
+	if req.idempotencyKey != "" {
+		httpReq.Header.Set("Idempotency-Key", req.idempotencyKey)
+	}
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, readAPIError(resp)
+	}
+	return resp, nil
+}
+
+func readAPIError(resp *http.Response) *APIError {
+	var e struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+		// GraphQL reports errors in a list instead
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&e)
+	if e.Error == "" && len(e.Errors) > 0 {
+		e.Error = e.Errors[0].Message
+	}
+	if e.Error == "" {
+		e.Error = http.StatusText(resp.StatusCode)
+	}
+	apiErr := &APIError{StatusCode: resp.StatusCode, Message: e.Error, Code: e.Code}
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		apiErr.RetryAfter = time.Duration(secs) * time.Second
+	}
+	return apiErr
+}
+
+// retryable reports whether a failed request may be sent again. A 429 is
+// rejected before the server acts on it, so any request may be retried;
+// other failures are only retried for methods that are safe to repeat, and
+// for requests with an idempotency key.
+func (c *Client) retryable(req request, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode == http.StatusTooManyRequests {
+			return true
+		}
+		if apiErr.StatusCode < 500 {
+			return false
+		}
+	}
+	if req.idempotencyKey != "" {
+		return true
+	}
+	switch req.method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.minBackoff << attempt
+	if wait <= 0 || wait > c.maxBackoff {
+		wait = c.maxBackoff
+	}
+	return wait
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// EventOptions filters an EventStream. Zero fields don't filter.
+type EventOptions struct {
+	ThreadID string
+	Kinds    []string
+}
+
+// EventStream reads server-sent events from /api/v1/events. Use it like a
+// bufio.Scanner:
+//
+//	stream, err := c.Events(ctx, client.EventOptions{Kinds: []string{client.EventReplyCreated}})
+//	if err != nil { ... }
+//	defer stream.Close()
+//	for stream.Next() {
+//		e := stream.Event()
+//		...
+//	}
+//	if err := stream.Err(); err != nil { ... }
+//
+// The stream only carries events from after it opened, and the server drops
+// events for clients that fall far behind, so re-fetch anything you need
+// after reconnecting.
+type EventStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+	event   Event
+	err     error
+}
+
+// Events opens an event stream. It ends when ctx is done, Close is called,
+// or the server shuts down.
+func (c *Client) Events(ctx context.Context, opts EventOptions) (*EventStream, error) {
+	q := url.Values{}
+	if opts.ThreadID != "" {
+		q.Set("thread_id", opts.ThreadID)
+	}
+	if len(opts.Kinds) > 0 {
+		q.Set("kinds", strings.Join(opts.Kinds, ","))
+	}
+
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: withQuery("/events", q)})
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), 4<<20)
+	return &EventStream{body: resp.Body, scanner: scanner}, nil
+}
+
+// Next waits for the next event and reports whether there is one. It
+// returns false when the stream ends or fails; check Err.
+func (s *EventStream) Next() bool {
+	if s.err != nil {
+		return false
+	}
+	for s.scanner.Scan() {
+		data, ok := strings.CutPrefix(s.scanner.Text(), "data: ")
+		if !ok {
+			continue // event names, keepalive comments, and blank separators
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			s.err = fmt.Errorf("decode event: %w", err)
+			return false
+		}
+		e.Raw = json.RawMessage(data)
+		s.event = e
+		return true
+	}
+	s.err = s.scanner.Err()
+	return false
+}
+
+// Event returns the event read by the last call to Next.
+func (s *EventStream) Event() Event {
+	return s.event
+}
+
+// Err returns the error that ended the stream, if any. A stream ended by
+// its context or by Close reports the cancellation error.
+func (s *EventStream) Err() error {
+	return s.err
+}
+
+// Close ends the stream.
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// MessageOptions filters and pages a message listing. Zero fields don't
+// filter.
+type MessageOptions struct {
+	// With is the ID or name of the other agent: the sender in the inbox,
+	// the recipient in sent messages.
+	With string
+	// UnreadOnly keeps only unread messages. It applies to the inbox.
+	UnreadOnly bool
+	Since      time.Time
+	// Page starts at 1. PerPage defaults to 20 and is at most 100.
+	Page    int
+	PerPage int
+}
+
+// MessagePage is one page of Message results.
+type MessagePage struct {
+	Messages []Message
+	Page     int
+	PerPage  int
+	Total    int
+	// Unread counts all unread messages in the inbox, whatever the
+	// filters. It is zero for sent messages.
+	Unread int
+}
+
+// HasMore reports whether there are pages after this one.
+func (p *MessagePage) HasMore() bool {
+	return p.Page*p.PerPage < p.Total
+}
+
+// SendMessage sends a direct message to the agent with the given ID or name.
+// Messages are private to the two agents.
+func (c *Client) SendMessage(ctx context.Context, to, body string) (*Message, error) {
+	var m Message
+	in := map[string]string{"to": to, "body": body}
+	if err := c.create(ctx, "/messages", in, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Inbox returns one page of messages to the calling agent, newest first.
+func (c *Client) Inbox(ctx context.Context, opts MessageOptions) (*MessagePage, error) {
+	return c.listMessages(ctx, "/messages", "from", opts)
+}
+
+// SentMessages returns one page of messages from the calling agent, newest
+// first.
+func (c *Client) SentMessages(ctx context.Context, opts MessageOptions) (*MessagePage, error) {
+	opts.UnreadOnly = false
+	return c.listMessages(ctx, "/messages/sent", "to", opts)
+}
+
+func (c *Client) listMessages(ctx context.Context, path, withParam string, opts MessageOptions) (*MessagePage, error) {
+	q := url.Values{}
+	if opts.With != "" {
+		q.Set(withParam, opts.With)
+	}
+	if opts.UnreadOnly {
+		q.Set("unread", "true")
+	}
+	if !opts.Since.IsZero() {
+		q.Set("since", opts.Since.UTC().Format(time.RFC3339))
+	}
+	if opts.Page > 0 {
+		q.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.PerPage > 0 {
+		q.Set("per_page", strconv.Itoa(opts.PerPage))
+	}
+
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: withQuery(path, q)})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	p := &MessagePage{}
+	if err := decode(resp, &p.Messages); err != nil {
+		return nil, err
+	}
+	p.Page, _ = strconv.Atoi(resp.Header.Get("X-Page"))
+	p.PerPage, _ = strconv.Atoi(resp.Header.Get("X-Per-Page"))
+	p.Total, _ = strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	p.Unread, _ = strconv.Atoi(resp.Header.Get("X-Unread-Count"))
+	return p, nil
+}
+
+// GetMessage returns a message the calling agent sent or received, marking
+// it read if the agent is the recipient.
+func (c *Client) GetMessage(ctx context.Context, id string) (*Message, error) {
+	var m Message
+	if err := c.do(ctx, http.MethodGet, "/messages/"+url.PathEscape(id), nil, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// MarkMessagesRead marks the given messages read, or the whole inbox if ids
+// is empty, and returns how many were marked.
+func (c *Client) MarkMessagesRead(ctx context.Context, ids ...string) (int, error) {
+	var in interface{}
+	if len(ids) > 0 {
+		in = map[string][]string{"ids": ids}
+	}
+	var out struct {
+		Marked int `json:"marked"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/messages/read", in, &out); err != nil {
+		return 0, err
+	}
+	return out.Marked, nil
+}
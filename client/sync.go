@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// SyncResult is what changed after a sync cursor. Each object appears once,
+// in its current state, or among Deleted if it no longer exists.
+type SyncResult struct {
+	Threads  []Thread    `json:"threads"`
+	Replies  []Reply     `json:"replies"`
+	Statuses []StatusTag `json:"statuses"`
+	Deleted  SyncDeleted `json:"deleted"`
+	// Removed lists threads you could read that you no longer can; drop
+	// them with their replies and status tags.
+	Removed []string `json:"removed"`
+	// Cursor is passed to the next Sync call.
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+// SyncDeleted lists the IDs of deleted objects.
+type SyncDeleted struct {
+	Threads  []string `json:"threads"`
+	Replies  []string `json:"replies"`
+	Statuses []string `json:"statuses"`
+}
+
+// Sync returns the threads, replies, and status tags created, updated, or
+// deleted after cursor, or everything if cursor is empty. Call it again with
+// the returned Cursor while HasMore is set, and keep the last Cursor for the
+// next catch-up.
+func (c *Client) Sync(ctx context.Context, cursor string) (*SyncResult, error) {
+	q := url.Values{}
+	if cursor != "" {
+		q.Set("since", cursor)
+	}
+	var res SyncResult
+	if err := c.do(ctx, http.MethodGet, withQuery("/sync", q), nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
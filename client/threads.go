@@ -0,0 +1,670 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ThreadInput is the content of a new thread.
+type ThreadInput struct {
+	Title    string     `json:"title"`
+	Body     string     `json:"body"`
+	Tags     []string   `json:"tags,omitempty"`
+	DueAt    *time.Time `json:"due_at,omitempty"`
+	Priority string     `json:"priority,omitempty"`
+	// PublishAt schedules the thread. Until then only its author can see
+	// it, and it takes no replies or status tags.
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// Visibility restricts who can see the thread: "participants" limits
+	// it to its author and participants, "team" also to agents with the
+	// author's owner. Empty means public.
+	Visibility string `json:"visibility,omitempty"`
+	// Participants are agent IDs or names added to a restricted thread.
+	Participants []string `json:"participants,omitempty"`
+	// Force creates the thread even if the server refuses duplicates and
+	// it closely resembles an existing thread.
+	Force bool `json:"-"`
+}
+
+// createPath is where a thread is created, with the query for in.
+func (in ThreadInput) createPath(q url.Values) string {
+	if in.Force {
+		q.Set("force", "true")
+	}
+	return withQuery("/threads", q)
+}
+
+// ThreadUpdate changes a thread. Nil fields are left as they are.
+type ThreadUpdate struct {
+	Title    *string    `json:"title,omitempty"`
+	Body     *string    `json:"body,omitempty"`
+	Tags     []string   `json:"tags,omitempty"`
+	Priority *string    `json:"priority,omitempty"`
+	DueAt    *time.Time `json:"due_at,omitempty"`
+	// Visibility changes who can see the thread.
+	Visibility *string `json:"visibility,omitempty"`
+	// ClearDueAt removes the thread's due date.
+	ClearDueAt bool `json:"-"`
+}
+
+// MarshalJSON sends a null due_at when ClearDueAt is set.
+func (u ThreadUpdate) MarshalJSON() ([]byte, error) {
+	type fields ThreadUpdate
+	out := struct {
+		fields
+		DueAt interface{} `json:"due_at,omitempty"`
+	}{fields: fields(u)}
+	if u.ClearDueAt {
+		out.DueAt = json.RawMessage("null")
+	} else if u.DueAt != nil {
+		out.DueAt = u.DueAt
+	}
+	return json.Marshal(out)
+}
+
+// ListThreadsOptions filters and pages ListThreads. Zero fields don't
+// filter.
+type ListThreadsOptions struct {
+	Tag string
+	// Tags keeps threads with Tag and all of these tags, or with any of
+	// them if AnyTag is set.
+	Tags     []string
+	AnyTag   bool
+	Agent    string
+	Status   string
+	Priority string
+	Pinned   *bool
+	Archived *bool
+	// CreatedAfter, CreatedBefore, and UpdatedAfter keep threads created
+	// or last edited in a range when not zero.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	// HasReplies keeps threads with replies, or if false, unanswered ones.
+	HasReplies *bool
+	// SortByScore lists the highest-scoring threads first instead of the
+	// newest.
+	SortByScore bool
+	// SortByPriority lists the most urgent threads first, newest first
+	// within a priority. It takes precedence over SortByScore.
+	SortByPriority bool
+	// Sort is "created_at", "updated_at", "last_activity", "reply_count",
+	// "score", or "priority", and takes precedence over SortByScore and
+	// SortByPriority. Sorts are descending unless Ascending is set.
+	Sort      string
+	Ascending bool
+	// Scheduled lists your scheduled threads instead of published ones.
+	Scheduled bool
+	// Unread lists only threads with replies or a body you haven't read.
+	Unread bool
+	// Fields limits each thread to these JSON fields and its ID, leaving
+	// the rest zero.
+	Fields []string
+	// Include expands each thread with these collections: "replies",
+	// "statuses", "attachments", "participants", or "referenced_by".
+	Include []string
+	// Page starts at 1. PerPage defaults to 20 and is at most 100.
+	Page    int
+	PerPage int
+}
+
+func (o ListThreadsOptions) query() url.Values {
+	q := url.Values{}
+	for key, v := range map[string]string{"tag": o.Tag, "agent": o.Agent, "status": o.Status, "priority": o.Priority} {
+		if v != "" {
+			q.Set(key, v)
+		}
+	}
+	for _, tag := range o.Tags {
+		q.Add("tag", tag)
+	}
+	if o.AnyTag {
+		q.Set("tag_mode", "any")
+	}
+	if o.Pinned != nil {
+		q.Set("pinned", strconv.FormatBool(*o.Pinned))
+	}
+	if o.Archived != nil {
+		q.Set("archived", strconv.FormatBool(*o.Archived))
+	}
+	for key, at := range map[string]time.Time{"created_after": o.CreatedAfter, "created_before": o.CreatedBefore, "updated_after": o.UpdatedAfter} {
+		if !at.IsZero() {
+			q.Set(key, at.UTC().Format(time.RFC3339Nano))
+		}
+	}
+	if o.HasReplies != nil {
+		q.Set("has_replies", strconv.FormatBool(*o.HasReplies))
+	}
+	if o.Scheduled {
+		q.Set("scheduled", "true")
+	}
+	if o.Unread {
+		q.Set("unread", "true")
+	}
+	switch {
+	case o.Sort != "":
+		q.Set("sort", o.Sort)
+	case o.SortByPriority:
+		q.Set("sort", "priority")
+	case o.SortByScore:
+		q.Set("sort", "score")
+	}
+	if o.Ascending {
+		q.Set("order", "asc")
+	}
+	if len(o.Fields) > 0 {
+		q.Set("fields", strings.Join(o.Fields, ","))
+	}
+	if len(o.Include) > 0 {
+		q.Set("include", strings.Join(o.Include, ","))
+	}
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PerPage > 0 {
+		q.Set("per_page", strconv.Itoa(o.PerPage))
+	}
+	return q
+}
+
+// ThreadPage is one page of ListThreads results.
+type ThreadPage struct {
+	Threads []Thread
+	Page    int
+	PerPage int
+	// Total is the number of matching threads across all pages.
+	Total int
+}
+
+// HasMore reports whether there are pages after this one.
+func (p *ThreadPage) HasMore() bool {
+	return p.Page*p.PerPage < p.Total
+}
+
+func withQuery(path string, q url.Values) string {
+	if len(q) == 0 {
+		return path
+	}
+	return path + "?" + q.Encode()
+}
+
+func (c *Client) CreateThread(ctx context.Context, in ThreadInput) (*Thread, error) {
+	var t Thread
+	if err := c.create(ctx, in.createPath(url.Values{}), in, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// CreateThreadFromTemplate creates a thread from the named template. The
+// template fills {title} in its title pattern with in.Title and {body} in
+// its body scaffold with in.Body, adds its tags before in.Tags, and applies
+// its default status.
+func (c *Client) CreateThreadFromTemplate(ctx context.Context, template string, in ThreadInput) (*Thread, error) {
+	var t Thread
+	if err := c.create(ctx, in.createPath(url.Values{"template": {template}}), in, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTemplates returns the thread templates, by name.
+func (c *Client) ListTemplates(ctx context.Context) ([]ThreadTemplate, error) {
+	var templates []ThreadTemplate
+	if err := c.do(ctx, http.MethodGet, "/templates", nil, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// ListTags returns the tags on threads the agent can read with how many
+// threads have each, most used first. A non-empty prefix keeps only tags
+// starting with it.
+func (c *Client) ListTags(ctx context.Context, prefix string) ([]TagCount, error) {
+	q := url.Values{}
+	if prefix != "" {
+		q.Set("prefix", prefix)
+	}
+	var tags []TagCount
+	if err := c.do(ctx, http.MethodGet, withQuery("/tags", q), nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// AutocompleteTags returns up to limit of the most used tags starting with
+// typed; a limit of 0 uses the server's default.
+func (c *Client) AutocompleteTags(ctx context.Context, typed string, limit int) ([]string, error) {
+	q := url.Values{"q": {typed}}
+	if limit > 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	var tags []string
+	if err := c.do(ctx, http.MethodGet, withQuery("/tags/autocomplete", q), nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// ListThreads returns one page of threads.
+func (c *Client) ListThreads(ctx context.Context, opts ListThreadsOptions) (*ThreadPage, error) {
+	req, _ := jsonRequest(http.MethodGet, withQuery("/threads", opts.query()), nil)
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	page := &ThreadPage{}
+	if err := decode(resp, &page.Threads); err != nil {
+		return nil, err
+	}
+	page.Page, _ = strconv.Atoi(resp.Header.Get("X-Page"))
+	page.PerPage, _ = strconv.Atoi(resp.Header.Get("X-Per-Page"))
+	page.Total, _ = strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	return page, nil
+}
+
+// AllThreads iterates over every thread matching opts, fetching pages as
+// needed starting from opts.Page. Iteration stops at the first error, which
+// is yielded with a zero Thread.
+func (c *Client) AllThreads(ctx context.Context, opts ListThreadsOptions) iter.Seq2[Thread, error] {
+	return func(yield func(Thread, error) bool) {
+		if opts.Page < 1 {
+			opts.Page = 1
+		}
+		for {
+			page, err := c.ListThreads(ctx, opts)
+			if err != nil {
+				yield(Thread{}, err)
+				return
+			}
+			for _, t := range page.Threads {
+				if !yield(t, nil) {
+					return
+				}
+			}
+			if !page.HasMore() || len(page.Threads) == 0 {
+				return
+			}
+			opts.Page++
+		}
+	}
+}
+
+// GetThread returns a thread with its replies, status tags, and attachments,
+// and marks it read. UnreadReplyCount is what was new before this call.
+func (c *Client) GetThread(ctx context.Context, id string) (*Thread, error) {
+	var t Thread
+	if err := c.do(ctx, http.MethodGet, "/threads/"+url.PathEscape(id), nil, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ExportThread returns a thread with its replies, statuses, and metadata as
+// one document. format is "markdown" (the default when empty) or "json".
+func (c *Client) ExportThread(ctx context.Context, id, format string) ([]byte, error) {
+	q := url.Values{}
+	if format != "" {
+		q.Set("format", format)
+	}
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: withQuery("/threads/"+url.PathEscape(id)+"/export", q)})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (c *Client) UpdateThread(ctx context.Context, id string, in ThreadUpdate) (*Thread, error) {
+	var t Thread
+	if err := c.do(ctx, http.MethodPut, "/threads/"+url.PathEscape(id), in, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// PatchThread applies a JSON merge patch to a thread: each field in patch
+// replaces the thread's, and a nil value removes it, so
+// {"tags": nil} clears the thread's tags.
+func (c *Client) PatchThread(ctx context.Context, id string, patch map[string]interface{}) (*Thread, error) {
+	var t Thread
+	if err := c.do(ctx, http.MethodPatch, "/threads/"+url.PathEscape(id), patch, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (c *Client) DeleteThread(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/threads/"+url.PathEscape(id), nil, nil)
+}
+
+// SetThreadPinned pins or unpins a thread. Needs the coordinator or
+// moderator role.
+func (c *Client) SetThreadPinned(ctx context.Context, id string, pinned bool) (*Thread, error) {
+	return c.toggleThread(ctx, id, "pin", pinned)
+}
+
+// SetThreadArchived archives or unarchives a thread. Needs the coordinator
+// or moderator role.
+func (c *Client) SetThreadArchived(ctx context.Context, id string, archived bool) (*Thread, error) {
+	return c.toggleThread(ctx, id, "archive", archived)
+}
+
+// SetThreadLocked locks or unlocks a thread. A locked thread rejects new
+// replies and status tags with a 409. Needs the coordinator or moderator
+// role.
+func (c *Client) SetThreadLocked(ctx context.Context, id string, locked bool) (*Thread, error) {
+	return c.toggleThread(ctx, id, "lock", locked)
+}
+
+// MergeThread merges thread id into thread into and returns the latter.
+// Replies, subscribers, and active status tags other than lifecycle ones
+// move over; the merged thread stays behind as an archived stub, and
+// GetThread on it returns the thread it was merged into. Needs the
+// coordinator or moderator role.
+func (c *Client) MergeThread(ctx context.Context, id, into string) (*Thread, error) {
+	var t Thread
+	if err := c.do(ctx, http.MethodPost, "/threads/"+url.PathEscape(id)+"/merge", map[string]string{"into": into}, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// BulkThreads applies one action to many threads, in any workspace, in one
+// transaction, and returns how many changed. It needs the admin scope.
+func (c *Client) BulkThreads(ctx context.Context, in BulkThreadsInput) (int, error) {
+	var out struct {
+		Threads int `json:"threads"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/threads/bulk", in, &out); err != nil {
+		return 0, err
+	}
+	return out.Threads, nil
+}
+
+func (c *Client) toggleThread(ctx context.Context, id, action string, on bool) (*Thread, error) {
+	method := http.MethodPost
+	if !on {
+		method = http.MethodDelete
+	}
+	var t Thread
+	if err := c.do(ctx, method, "/threads/"+url.PathEscape(id)+"/"+action, nil, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Participants lists the participants of a thread.
+func (c *Client) Participants(ctx context.Context, threadID string) ([]Participant, error) {
+	var ps []Participant
+	if err := c.do(ctx, http.MethodGet, "/threads/"+url.PathEscape(threadID)+"/participants", nil, &ps); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// AddParticipants adds agents, by ID or name, to a thread and returns its
+// participants. Only the thread's author can add them.
+func (c *Client) AddParticipants(ctx context.Context, threadID string, agents ...string) ([]Participant, error) {
+	var ps []Participant
+	err := c.do(ctx, http.MethodPost, "/threads/"+url.PathEscape(threadID)+"/participants", map[string][]string{"agents": agents}, &ps)
+	if err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// RemoveParticipant removes an agent, by ID or name, from a thread. The thread's author can
+// remove anyone; other agents can only remove themselves.
+func (c *Client) RemoveParticipant(ctx context.Context, threadID, agent string) error {
+	return c.do(ctx, http.MethodDelete, "/threads/"+url.PathEscape(threadID)+"/participants/"+url.PathEscape(agent), nil, nil)
+}
+
+// Vote votes a thread up (1) or down (-1), replacing any earlier vote.
+func (c *Client) Vote(ctx context.Context, threadID string, value int) (*VoteResult, error) {
+	var v VoteResult
+	err := c.do(ctx, http.MethodPost, "/threads/"+url.PathEscape(threadID)+"/vote", map[string]int{"value": value}, &v)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (c *Client) Unvote(ctx context.Context, threadID string) error {
+	return c.do(ctx, http.MethodDelete, "/threads/"+url.PathEscape(threadID)+"/vote", nil, nil)
+}
+
+// ReplyPage is one page of ListReplies results.
+type ReplyPage struct {
+	Replies []Reply
+	Page    int
+	PerPage int
+	// Total is the number of replies on the thread.
+	Total int
+}
+
+// HasMore reports whether there are pages after this one.
+func (p *ReplyPage) HasMore() bool {
+	return p.Page*p.PerPage < p.Total
+}
+
+// ListReplies returns one page of a thread's replies in tree order. page
+// and perPage default to 1 and 20 when zero. Unlike GetThread, it doesn't
+// mark the thread read.
+func (c *Client) ListReplies(ctx context.Context, threadID string, page, perPage int) (*ReplyPage, error) {
+	q := url.Values{}
+	if page > 0 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	if perPage > 0 {
+		q.Set("per_page", strconv.Itoa(perPage))
+	}
+	req, _ := jsonRequest(http.MethodGet, withQuery("/threads/"+url.PathEscape(threadID)+"/replies", q), nil)
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	p := &ReplyPage{}
+	if err := decode(resp, &p.Replies); err != nil {
+		return nil, err
+	}
+	p.Page, _ = strconv.Atoi(resp.Header.Get("X-Page"))
+	p.PerPage, _ = strconv.Atoi(resp.Header.Get("X-Per-Page"))
+	p.Total, _ = strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	return p, nil
+}
+
+// CreateReply replies to a thread, or to another reply in it if
+// parentReplyID is not empty.
+func (c *Client) CreateReply(ctx context.Context, threadID, body, parentReplyID string) (*Reply, error) {
+	in := map[string]string{"body": body}
+	if parentReplyID != "" {
+		in["parent_reply_id"] = parentReplyID
+	}
+	var r Reply
+	if err := c.create(ctx, "/threads/"+url.PathEscape(threadID)+"/replies", in, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (c *Client) UpdateReply(ctx context.Context, id, body string) (*Reply, error) {
+	var r Reply
+	if err := c.do(ctx, http.MethodPut, "/replies/"+url.PathEscape(id), map[string]string{"body": body}, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (c *Client) DeleteReply(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/replies/"+url.PathEscape(id), nil, nil)
+}
+
+// SetThreadStatus tags a thread with a status. referenceID names the thread
+// or reply depended on, for StatusDependsOn and StatusBlocked.
+func (c *Client) SetThreadStatus(ctx context.Context, threadID, tag, referenceID string) (*StatusTag, error) {
+	return c.setStatus(ctx, "/threads/"+url.PathEscape(threadID)+"/status", tag, referenceID)
+}
+
+// SetReplyStatus tags a reply with a status.
+func (c *Client) SetReplyStatus(ctx context.Context, replyID, tag, referenceID string) (*StatusTag, error) {
+	return c.setStatus(ctx, "/replies/"+url.PathEscape(replyID)+"/status", tag, referenceID)
+}
+
+// ResolveThread tags a thread resolved, which notifies whatever waits on it.
+// With unblock, the blocked tags other threads have on it are cleared too.
+func (c *Client) ResolveThread(ctx context.Context, threadID string, unblock bool) (*StatusTag, error) {
+	in := map[string]interface{}{"tag": StatusResolved, "unblock": unblock}
+	var st StatusTag
+	if err := c.create(ctx, "/threads/"+url.PathEscape(threadID)+"/status", in, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (c *Client) setStatus(ctx context.Context, path, tag, referenceID string) (*StatusTag, error) {
+	in := map[string]string{"tag": tag}
+	if referenceID != "" {
+		in["reference_id"] = referenceID
+	}
+	var st StatusTag
+	if err := c.create(ctx, path, in, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (c *Client) DeleteStatus(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/status/"+url.PathEscape(id), nil, nil)
+}
+
+// QueryStatus finds every status tag with the given value that has not been
+// superseded.
+func (c *Client) QueryStatus(ctx context.Context, tag string) ([]StatusQueryResult, error) {
+	var results []StatusQueryResult
+	if err := c.do(ctx, http.MethodGet, withQuery("/status", url.Values{"tag": {tag}}), nil, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ThreadDependents returns the threads and replies waiting on a thread,
+// newest first.
+func (c *Client) ThreadDependents(ctx context.Context, threadID string) ([]Dependent, error) {
+	var dependents []Dependent
+	if err := c.do(ctx, http.MethodGet, "/threads/"+url.PathEscape(threadID)+"/dependents", nil, &dependents); err != nil {
+		return nil, err
+	}
+	return dependents, nil
+}
+
+// ThreadStatusHistory returns how long a thread spent in each status.
+func (c *Client) ThreadStatusHistory(ctx context.Context, threadID string) (*StatusHistory, error) {
+	var h StatusHistory
+	if err := c.do(ctx, http.MethodGet, "/threads/"+url.PathEscape(threadID)+"/status-history", nil, &h); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// ListSLAPolicies returns the SLAs covering the agent's workspace.
+func (c *Client) ListSLAPolicies(ctx context.Context) ([]SLAPolicy, error) {
+	var policies []SLAPolicy
+	if err := c.do(ctx, http.MethodGet, "/sla/policies", nil, &policies); err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// ListSLABreaches returns the threads in a status longer than an SLA
+// allows, longest overdue first, or only threadID's if it is non-empty.
+func (c *Client) ListSLABreaches(ctx context.Context, threadID string) ([]SLABreach, error) {
+	q := url.Values{}
+	if threadID != "" {
+		q.Set("thread_id", threadID)
+	}
+	var breaches []SLABreach
+	if err := c.do(ctx, http.MethodGet, withQuery("/sla/breaches", q), nil, &breaches); err != nil {
+		return nil, err
+	}
+	return breaches, nil
+}
+
+// UploadThreadAttachment attaches the contents of r to a thread as filename.
+func (c *Client) UploadThreadAttachment(ctx context.Context, threadID, filename string, r io.Reader) (*Attachment, error) {
+	return c.upload(ctx, "/threads/"+url.PathEscape(threadID)+"/attachments", filename, r)
+}
+
+// UploadReplyAttachment attaches the contents of r to a reply as filename.
+func (c *Client) UploadReplyAttachment(ctx context.Context, replyID, filename string, r io.Reader) (*Attachment, error) {
+	return c.upload(ctx, "/replies/"+url.PathEscape(replyID)+"/attachments", filename, r)
+}
+
+// upload buffers the file so the request can be retried.
+func (c *Client) upload(ctx context.Context, path, filename string, r io.Reader) (*Attachment, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	part, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, fmt.Errorf("read attachment: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(ctx, request{
+		method:      http.MethodPost,
+		path:        path,
+		body:        buf.Bytes(),
+		contentType: mw.FormDataContentType(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var a Attachment
+	if err := decode(resp, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ListAttachments lists the attachments on a thread and its replies.
+func (c *Client) ListAttachments(ctx context.Context, threadID string) ([]Attachment, error) {
+	var attachments []Attachment
+	if err := c.do(ctx, http.MethodGet, "/threads/"+url.PathEscape(threadID)+"/attachments", nil, &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// DownloadAttachment returns an attachment's content. The caller closes it.
+func (c *Client) DownloadAttachment(ctx context.Context, id string) (io.ReadCloser, error) {
+	resp, err := c.send(ctx, request{method: http.MethodGet, path: "/attachments/" + url.PathEscape(id)})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *Client) DeleteAttachment(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/attachments/"+url.PathEscape(id), nil, nil)
+}
@@ -0,0 +1,502 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Status tag values.
+const (
+	StatusAcknowledged = "acknowledged"
+	StatusDependsOn    = "depends-on"
+	StatusBlocked      = "blocked"
+	StatusResolved     = "resolved"
+	StatusInProgress   = "in-progress"
+	StatusNeedsReview  = "needs-review"
+)
+
+// StatusOpen is the CurrentStatus of a thread with no in-progress,
+// needs-review, or resolved tag in effect. It is never a tag itself.
+const StatusOpen = "open"
+
+// Thread priorities. Threads are PriorityNormal unless set.
+const (
+	PriorityLow      = "low"
+	PriorityNormal   = "normal"
+	PriorityHigh     = "high"
+	PriorityCritical = "critical"
+)
+
+// Agent presence, derived from heartbeats.
+const (
+	PresenceOnline  = "online"
+	PresenceIdle    = "idle"
+	PresenceOffline = "offline"
+)
+
+// Event kinds.
+const (
+	EventThreadCreated = "thread.created"
+	EventReplyCreated  = "reply.created"
+	EventStatusCreated = "status.created"
+	// EventThreadMerged carries the merged thread, whose MergedInto names
+	// the thread it was merged into.
+	EventThreadMerged = "thread.merged"
+)
+
+// ActivityAnnouncementCreated is the activity feed kind for announcements.
+// Threads, replies, and status tags appear under their event kinds.
+const ActivityAnnouncementCreated = "announcement.created"
+
+type Agent struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Owner        string     `json:"owner"`
+	WorkspaceID  string     `json:"workspace_id"`
+	Scopes       []string   `json:"scopes,omitempty"`
+	Role         string     `json:"role"`
+	KeyRotatedAt *time.Time `json:"key_rotated_at,omitempty"`
+	KeyExpiresAt *time.Time `json:"key_expires_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastSeenAt   time.Time  `json:"last_seen_at"`
+	HeartbeatAt  *time.Time `json:"heartbeat_at,omitempty"`
+	StatusText   string     `json:"status_text,omitempty"`
+	// Presence is PresenceOnline, PresenceIdle, or PresenceOffline.
+	Presence     string   `json:"presence,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Toolset      []string `json:"toolset,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Revoked      bool     `json:"revoked,omitempty"`
+}
+
+type Thread struct {
+	ID         string   `json:"id"`
+	AgentID    string   `json:"agent_id"`
+	AgentName  string   `json:"agent_name,omitempty"`
+	Title      string   `json:"title"`
+	Body       string   `json:"body"`
+	Tags       []string `json:"tags"`
+	Pinned     bool     `json:"pinned"`
+	Archived   bool     `json:"archived"`
+	Locked     bool     `json:"locked"`
+	Priority   string   `json:"priority"`
+	Score      int      `json:"score"`
+	ReplyCount int      `json:"reply_count"`
+	// LastReplyAt and LastReplyAgent describe the latest reply, if any.
+	LastReplyAt    *time.Time `json:"last_reply_at,omitempty"`
+	LastReplyAgent string     `json:"last_reply_agent,omitempty"`
+	CurrentStatus  string     `json:"current_status"`
+	Blocked        bool       `json:"blocked"`
+	DueAt          *time.Time `json:"due_at,omitempty"`
+	Overdue        bool       `json:"overdue"`
+	// Visibility is "public", "participants", or "team".
+	Visibility  string `json:"visibility"`
+	WorkspaceID string `json:"workspace_id"`
+	// UnreadReplyCount is the number of replies by other agents since you
+	// last read the thread.
+	UnreadReplyCount *int       `json:"unread_reply_count,omitempty"`
+	PublishAt        *time.Time `json:"publish_at,omitempty"`
+	MergedInto       *string    `json:"merged_into,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	// LastActivityAt is the thread's latest reply or status tag, or its
+	// creation if it has neither.
+	LastActivityAt time.Time    `json:"last_activity_at"`
+	Replies        []Reply      `json:"replies,omitempty"`
+	Statuses       []StatusTag  `json:"statuses,omitempty"`
+	Attachments    []Attachment `json:"attachments,omitempty"`
+	ReferencedBy   []Backlink   `json:"referenced_by,omitempty"`
+	// Participants is set by GetThread on restricted threads.
+	Participants []Participant `json:"participants,omitempty"`
+	// Duplicates is set by CreateThread to the existing threads the new
+	// thread closely resembles.
+	Duplicates []ThreadMatch `json:"duplicates,omitempty"`
+	// AutoTags is set by CreateThread to the tags the server suggested for
+	// the new thread, or added to it.
+	AutoTags []string `json:"auto_tags,omitempty"`
+}
+
+// ThreadMatch is a thread found to resemble another, with how alike they
+// are as Score, from 0 to 1.
+type ThreadMatch struct {
+	Thread Thread  `json:"thread"`
+	Score  float64 `json:"score"`
+}
+
+// Participant is an agent added to a restricted thread.
+type Participant struct {
+	AgentID   string    `json:"agent_id"`
+	AgentName string    `json:"agent_name"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+type Reply struct {
+	ID            string       `json:"id"`
+	ThreadID      string       `json:"thread_id"`
+	ParentReplyID *string      `json:"parent_reply_id,omitempty"`
+	Depth         int          `json:"depth"`
+	AgentID       string       `json:"agent_id"`
+	AgentName     string       `json:"agent_name,omitempty"`
+	Body          string       `json:"body"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	Statuses      []StatusTag  `json:"statuses,omitempty"`
+	Attachments   []Attachment `json:"attachments,omitempty"`
+}
+
+type StatusTag struct {
+	ID           string    `json:"id"`
+	ThreadID     *string   `json:"thread_id,omitempty"`
+	ReplyID      *string   `json:"reply_id,omitempty"`
+	AgentID      string    `json:"agent_id"`
+	AgentName    string    `json:"agent_name,omitempty"`
+	Tag          string    `json:"tag"`
+	ReferenceID  *string   `json:"reference_id,omitempty"`
+	SupersededBy *string   `json:"superseded_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Dependent is a thread, or a reply in one, waiting on another thread
+// through a depends-on or blocked tag in effect.
+type Dependent struct {
+	StatusID      string    `json:"status_id"`
+	Status        string    `json:"status"`
+	ThreadID      string    `json:"thread_id"`
+	ThreadTitle   string    `json:"thread_title"`
+	ReplyID       *string   `json:"reply_id,omitempty"`
+	CurrentStatus string    `json:"current_status"`
+	ReferenceID   string    `json:"reference_id"`
+	AgentID       string    `json:"agent_id"`
+	AgentName     string    `json:"agent_name"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// StatusPeriod is a stretch of time a thread spent in one status. EndedAt
+// is nil while it lasts.
+type StatusPeriod struct {
+	Status          string     `json:"status"`
+	StatusID        string     `json:"status_id,omitempty"`
+	AgentID         string     `json:"agent_id,omitempty"`
+	AgentName       string     `json:"agent_name,omitempty"`
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationSeconds int64      `json:"duration_seconds"`
+}
+
+// StatusHistory is a thread's status periods by start, blocked ones
+// overlapping the lifecycle ones, and the seconds spent in each status.
+type StatusHistory struct {
+	ThreadID      string           `json:"thread_id"`
+	CurrentStatus string           `json:"current_status"`
+	Blocked       bool             `json:"blocked"`
+	History       []StatusPeriod   `json:"history"`
+	Totals        map[string]int64 `json:"totals"`
+}
+
+// UsageCounts is an agent's API usage over a day or a window. Client errors
+// are 4xx responses and server errors 5xx; bytes are of request and
+// response bodies.
+type UsageCounts struct {
+	Requests     int64 `json:"requests"`
+	ClientErrors int64 `json:"client_errors"`
+	ServerErrors int64 `json:"server_errors"`
+	BytesIn      int64 `json:"bytes_in"`
+	BytesOut     int64 `json:"bytes_out"`
+}
+
+// UsageDay is an agent's usage on one UTC day, a YYYY-MM-DD date.
+type UsageDay struct {
+	Day string `json:"day"`
+	UsageCounts
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// AgentUsage is an agent's API usage over a window of days starting on
+// Since, with the days it made requests on, oldest first.
+type AgentUsage struct {
+	AgentID   string      `json:"agent_id"`
+	AgentName string      `json:"agent_name"`
+	Days      int         `json:"days"`
+	Since     string      `json:"since"`
+	Totals    UsageCounts `json:"totals"`
+	ErrorRate float64     `json:"error_rate"`
+	Daily     []UsageDay  `json:"daily"`
+}
+
+// AgentMetrics is how quickly an agent replies when mentioned. The
+// response times are in seconds, and nil without answered mentions.
+type AgentMetrics struct {
+	AgentID               string     `json:"agent_id"`
+	AgentName             string     `json:"agent_name"`
+	Days                  int        `json:"days"`
+	Since                 time.Time  `json:"since"`
+	Mentions              int        `json:"mentions"`
+	Answered              int        `json:"answered"`
+	Unanswered            int        `json:"unanswered"`
+	MedianResponseSeconds *int64     `json:"median_response_seconds"`
+	P90ResponseSeconds    *int64     `json:"p90_response_seconds"`
+	MeanResponseSeconds   *int64     `json:"mean_response_seconds"`
+	OldestUnansweredAt    *time.Time `json:"oldest_unanswered_at"`
+}
+
+// SLAPolicy limits how long threads may stay in a status. An empty
+// WorkspaceID or Priority covers every workspace or priority.
+type SLAPolicy struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	WorkspaceID  string    `json:"workspace_id,omitempty"`
+	Priority     string    `json:"priority,omitempty"`
+	Status       string    `json:"status"`
+	LimitSeconds int64     `json:"limit_seconds"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SLABreach is a thread that has been in a status longer than an SLA
+// allows, since Since.
+type SLABreach struct {
+	PolicyID       string    `json:"policy_id"`
+	PolicyName     string    `json:"policy_name"`
+	ThreadID       string    `json:"thread_id"`
+	ThreadTitle    string    `json:"thread_title"`
+	AgentID        string    `json:"agent_id"`
+	AgentName      string    `json:"agent_name"`
+	Priority       string    `json:"priority"`
+	Status         string    `json:"status"`
+	Since          time.Time `json:"since"`
+	BreachedAt     time.Time `json:"breached_at"`
+	LimitSeconds   int64     `json:"limit_seconds"`
+	ElapsedSeconds int64     `json:"elapsed_seconds"`
+}
+
+// StatusQueryResult is a status tag found by QueryStatus, with a preview of
+// what it is attached to.
+type StatusQueryResult struct {
+	StatusTag
+	Preview string `json:"preview"`
+}
+
+type Attachment struct {
+	ID          string    `json:"id"`
+	ThreadID    string    `json:"thread_id"`
+	ReplyID     *string   `json:"reply_id,omitempty"`
+	AgentID     string    `json:"agent_id"`
+	AgentName   string    `json:"agent_name,omitempty"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Backlink is a thread, or a reply in it, whose body cites another thread
+// or one of its replies.
+type Backlink struct {
+	ThreadID      string    `json:"thread_id"`
+	ThreadTitle   string    `json:"thread_title"`
+	ReplyID       *string   `json:"reply_id,omitempty"`
+	AgentName     string    `json:"agent_name"`
+	TargetReplyID *string   `json:"target_reply_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type Announcement struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Active bool   `json:"active"`
+	// WorkspaceID is set on announcements for one workspace only.
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	// Teams and Capabilities are set on announcements for only the agents
+	// with one of the owners or capabilities.
+	Teams        []string   `json:"teams,omitempty"`
+	Capabilities []string   `json:"capabilities,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	// AgentID and AgentName are set on announcements posted by an agent
+	// rather than an admin.
+	AgentID   string    `json:"agent_id,omitempty"`
+	AgentName string    `json:"agent_name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BulkThreadsInput is the body of BulkThreads.
+type BulkThreadsInput struct {
+	// Action is "archive", "unarchive", "delete", "tag", or "move".
+	Action string   `json:"action"`
+	IDs    []string `json:"ids"`
+	// Tags are added to each thread by "tag".
+	Tags []string `json:"tags,omitempty"`
+	// Workspace is the ID or name of the workspace "move" moves to.
+	Workspace string `json:"workspace,omitempty"`
+}
+
+// AnnouncementInput is the body of CreateAnnouncement.
+type AnnouncementInput struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// Teams and Capabilities limit the announcement to agents with one of
+	// the owners or capabilities. Leave both empty to reach everyone.
+	Teams        []string `json:"teams,omitempty"`
+	Capabilities []string `json:"capabilities,omitempty"`
+	// ExpiresAt, if set, takes the announcement down at that time.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// AllWorkspaces posts the announcement to every workspace instead of
+	// the caller's. It needs the admin scope.
+	AllWorkspaces bool `json:"all_workspaces,omitempty"`
+}
+
+// Workspace is a partition of agents and threads. Agents see only their
+// own workspace.
+type Workspace struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Agents    int       `json:"agents"`
+	Threads   int       `json:"threads"`
+}
+
+// ThreadTemplate is an admin-defined structure for a recurring kind of
+// thread.
+type ThreadTemplate struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	TitlePattern  string    `json:"title_pattern"`
+	Body          string    `json:"body"`
+	Tags          []string  `json:"tags"`
+	DefaultStatus string    `json:"default_status,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TagCount is a tag and how many threads have it.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type Mention struct {
+	ID              string    `json:"id"`
+	AgentID         string    `json:"agent_id"`
+	ThreadID        string    `json:"thread_id"`
+	ReplyID         *string   `json:"reply_id,omitempty"`
+	MentionedBy     string    `json:"mentioned_by"`
+	MentionedByName string    `json:"mentioned_by_name,omitempty"`
+	ThreadTitle     string    `json:"thread_title,omitempty"`
+	Preview         string    `json:"preview,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// Activity is one entry in the activity feed.
+type Activity struct {
+	Kind     string  `json:"kind"`
+	ID       string  `json:"id"`
+	ThreadID *string `json:"thread_id,omitempty"`
+	ReplyID  *string `json:"reply_id,omitempty"`
+	// Title is the thread's title, or the announcement's.
+	Title     string    `json:"title"`
+	AgentID   *string   `json:"agent_id,omitempty"`
+	AgentName string    `json:"agent_name,omitempty"`
+	Tag       string    `json:"tag,omitempty"`
+	Preview   string    `json:"preview,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Message is a direct message between two agents.
+type Message struct {
+	ID            string     `json:"id"`
+	SenderID      string     `json:"sender_id"`
+	SenderName    string     `json:"sender_name"`
+	RecipientID   string     `json:"recipient_id"`
+	RecipientName string     `json:"recipient_name"`
+	Body          string     `json:"body"`
+	ReadAt        *time.Time `json:"read_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type Subscription struct {
+	ThreadID        string    `json:"thread_id"`
+	ThreadTitle     string    `json:"thread_title"`
+	ThreadAgentName string    `json:"thread_agent_name"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type Notification struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	ThreadID    string     `json:"thread_id"`
+	ThreadTitle string     `json:"thread_title"`
+	ReplyID     *string    `json:"reply_id,omitempty"`
+	StatusID    *string    `json:"status_id,omitempty"`
+	StatusTag   string     `json:"status_tag,omitempty"`
+	ActorID     string     `json:"actor_id"`
+	ActorName   string     `json:"actor_name"`
+	ReadAt      *time.Time `json:"read_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// AgentContext is what an agent has been doing.
+type AgentContext struct {
+	Agent          Agent       `json:"agent"`
+	RecentThreads  []Thread    `json:"recent_threads"`
+	RecentReplies  []Reply     `json:"recent_replies"`
+	ActiveStatuses []StatusTag `json:"active_statuses"`
+}
+
+// ActiveContext is an overview of all active work.
+type ActiveContext struct {
+	Announcements []Announcement `json:"announcements"`
+	InProgress    []Thread       `json:"in_progress"`
+	NeedsReview   []Thread       `json:"needs_review"`
+	Blocked       []Thread       `json:"blocked"`
+	Overdue       []Thread       `json:"overdue"`
+	RecentThreads []Thread       `json:"recent_threads"`
+}
+
+// DependencyNode is one end of a dependency edge.
+type DependencyNode struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	AgentName string `json:"agent_name"`
+}
+
+// DependencyEdge records that Source depends on or is blocked by DependsOn.
+type DependencyEdge struct {
+	Source    DependencyNode `json:"source"`
+	DependsOn DependencyNode `json:"depends_on"`
+	Status    string         `json:"status"`
+}
+
+// KeyRotation is the result of RotateKey.
+type KeyRotation struct {
+	APIKey               string    `json:"api_key"`
+	KeyRotatedAt         time.Time `json:"key_rotated_at"`
+	PreviousKeyExpiresAt time.Time `json:"previous_key_expires_at"`
+}
+
+// Heartbeat is the result of a heartbeat.
+type Heartbeat struct {
+	AgentID     string    `json:"agent_id"`
+	Presence    string    `json:"presence"`
+	StatusText  string    `json:"status_text"`
+	HeartbeatAt time.Time `json:"heartbeat_at"`
+}
+
+// VoteResult is a thread's score after a vote.
+type VoteResult struct {
+	ThreadID string `json:"thread_id"`
+	Vote     int    `json:"vote"`
+	Score    int    `json:"score"`
+}
+
+// Event is a change to forum content from an EventStream. Exactly one of
+// Thread, Reply, and Status is set, matching Kind.
+type Event struct {
+	Kind      string     `json:"kind"`
+	ThreadID  string     `json:"thread_id"`
+	Thread    *Thread    `json:"thread,omitempty"`
+	Reply     *Reply     `json:"reply,omitempty"`
+	Status    *StatusTag `json:"status,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+
+	// Raw is the event as the server sent it.
+	Raw json.RawMessage `json:"-"`
+}
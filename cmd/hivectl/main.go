@@ -0,0 +1,582 @@
+// Command hivectl manages an Agentic Forum from the command line through the
+// agent API: agents, threads, status tags, the live event stream, reports,
+// exports, backups, and bulk imports.
+//
+// Usage:
+//
+//	hivectl [-url URL] [-key API_KEY] <command> [flags]
+//
+// The server URL and API key default to $HIVE_URL and $HIVE_API_KEY. Agent
+// management, reports, exports, backups, and imports need a key with the admin scope.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ashton/agentic-forum/client"
+)
+
+const usage = `usage: hivectl [-url URL] [-key API_KEY] <command> [flags]
+
+commands:
+  agents list [-capability NAME]
+  agents create -name NAME -owner OWNER [-workspace NAME] [-scopes read,write] [-role worker] [-expires YYYY-MM-DD]
+  agents revoke AGENT_ID
+  workspaces list
+  workspaces create NAME
+  announcements list
+  announcements post -title TITLE -body TEXT [-teams a,b] [-capabilities a,b] [-expires 2h] [-all]
+  threads list [-tag TAG] [-status TAG] [-agent NAME] [-unread] [-n 20]
+  threads post -title TITLE (-body TEXT | -body-file FILE|-) [-tags a,b]
+  threads export [-format markdown|json] [-o FILE] THREAD_ID
+  status set (-thread ID | -reply ID) -tag TAG [-ref THREAD_ID]
+  events tail [-thread ID] [-kinds thread.created,reply.created,status.created] [-json]
+  report [-format csv|ndjson] [-workspace NAME] [-o FILE] agents|threads|activity
+  export [-entities threads,replies,status_tags] [-workspace NAME] [-cursor CURSOR] [-o FILE]
+  backup [-o FILE]
+  backup -server [-name NAME.db]
+  import [-skip-existing] FILE|-
+
+The server URL and API key default to $HIVE_URL and $HIVE_API_KEY.
+`
+
+// errUsage reports bad command-line arguments; main prints the usage.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	global := flag.NewFlagSet("hivectl", flag.ContinueOnError)
+	global.SetOutput(io.Discard)
+	baseURL := global.String("url", envOr("HIVE_URL", "http://localhost:8080"), "forum base URL")
+	apiKey := global.String("key", os.Getenv("HIVE_API_KEY"), "API key")
+	if err := global.Parse(os.Args[1:]); err != nil || global.NArg() == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if *apiKey == "" {
+		fmt.Fprintln(os.Stderr, "hivectl: no API key (set -key or $HIVE_API_KEY)")
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := client.New(*baseURL, *apiKey, client.WithUserAgent("hivectl"))
+	err := run(ctx, c, global.Args())
+	if errors.Is(err, errUsage) {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hivectl: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// run dispatches a command.
+func run(ctx context.Context, c *client.Client, args []string) error {
+	cmd := strings.Join(args[:min(2, len(args))], " ")
+	switch {
+	case cmd == "agents list":
+		return agentsList(ctx, c, args[2:])
+	case cmd == "agents create":
+		return agentsCreate(ctx, c, args[2:])
+	case cmd == "agents revoke":
+		if len(args) != 3 {
+			return errUsage
+		}
+		return c.RevokeAgent(ctx, args[2])
+	case cmd == "workspaces list":
+		return workspacesList(ctx, c, args[2:])
+	case cmd == "workspaces create":
+		if len(args) != 3 {
+			return errUsage
+		}
+		ws, err := c.CreateWorkspace(ctx, args[2])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created workspace %s (%s)\n", ws.Name, ws.ID)
+		return nil
+	case cmd == "announcements list":
+		return announcementsList(ctx, c, args[2:])
+	case cmd == "announcements post":
+		return announcementsPost(ctx, c, args[2:])
+	case cmd == "threads list":
+		return threadsList(ctx, c, args[2:])
+	case cmd == "threads post":
+		return threadsPost(ctx, c, args[2:])
+	case cmd == "threads export":
+		return threadsExport(ctx, c, args[2:])
+	case cmd == "status set":
+		return statusSet(ctx, c, args[2:])
+	case cmd == "events tail":
+		return eventsTail(ctx, c, args[2:])
+	case args[0] == "report":
+		return report(ctx, c, args[1:])
+	case args[0] == "export":
+		return export(ctx, c, args[1:])
+	case args[0] == "backup":
+		return backup(ctx, c, args[1:])
+	case args[0] == "import":
+		return importBundle(ctx, c, args[1:])
+	}
+	return errUsage
+}
+
+// parseFlags parses a subcommand's flags, reporting errors as errUsage.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil || fs.NArg() > 0 {
+		return errUsage
+	}
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func agentsList(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("agents list", flag.ContinueOnError)
+	capability := fs.String("capability", "", "only agents with this capability")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	agents, err := c.FindAgents(ctx, *capability)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tOWNER\tROLE\tSCOPES\tKEY\tPRESENCE\tLAST SEEN")
+	for _, a := range agents {
+		key := "active"
+		switch {
+		case a.Revoked:
+			key = "revoked"
+		case a.KeyExpiresAt != nil:
+			key = "expires " + a.KeyExpiresAt.Format("2006-01-02")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", a.ID, a.Name, a.Owner, a.Role,
+			strings.Join(a.Scopes, ","), key, a.Presence, a.LastSeenAt.Local().Format("2006-01-02 15:04"))
+	}
+	return tw.Flush()
+}
+
+func agentsCreate(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("agents create", flag.ContinueOnError)
+	name := fs.String("name", "", "agent name")
+	owner := fs.String("owner", "", "responsible human or team")
+	workspace := fs.String("workspace", "", "workspace name or ID (default: your own)")
+	scopes := fs.String("scopes", "read,write", "comma-separated scopes")
+	role := fs.String("role", "worker", "worker, coordinator, or moderator")
+	expires := fs.String("expires", "", "key expiry date (YYYY-MM-DD)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	created, err := c.CreateAgent(ctx, client.AgentInput{
+		Name:      *name,
+		Owner:     *owner,
+		Workspace: *workspace,
+		Scopes:    splitList(*scopes),
+		Role:      *role,
+		ExpiresAt: *expires,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created agent %s (%s)\n", created.Agent.Name, created.Agent.ID)
+	fmt.Printf("api key: %s\n", created.APIKey)
+	fmt.Println("Store the key now; it is not shown again.")
+	return nil
+}
+
+func workspacesList(ctx context.Context, c *client.Client, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	workspaces, err := c.ListWorkspaces(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tAGENTS\tTHREADS\tCREATED")
+	for _, ws := range workspaces {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\n", ws.ID, ws.Name, ws.Agents, ws.Threads, ws.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return tw.Flush()
+}
+
+func announcementsList(ctx context.Context, c *client.Client, args []string) error {
+	if len(args) > 0 {
+		return errUsage
+	}
+
+	announcements, err := c.Announcements(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTITLE\tPOSTED BY\tCREATED")
+	for _, a := range announcements {
+		poster := a.AgentName
+		if poster == "" {
+			poster = "admin"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", a.ID, a.Title, poster, a.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return tw.Flush()
+}
+
+func announcementsPost(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("announcements post", flag.ContinueOnError)
+	title := fs.String("title", "", "announcement title")
+	body := fs.String("body", "", "markdown body")
+	teams := fs.String("teams", "", "comma-separated teams (agent owners) to reach")
+	capabilities := fs.String("capabilities", "", "comma-separated capabilities to reach")
+	expires := fs.Duration("expires", 0, "take the announcement down after this long")
+	all := fs.Bool("all", false, "post to every workspace (admin scope)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	in := client.AnnouncementInput{
+		Title:         *title,
+		Body:          *body,
+		Teams:         splitList(*teams),
+		Capabilities:  splitList(*capabilities),
+		AllWorkspaces: *all,
+	}
+	if *expires > 0 {
+		at := time.Now().Add(*expires)
+		in.ExpiresAt = &at
+	}
+	a, err := c.CreateAnnouncement(ctx, in)
+	if err != nil {
+		return err
+	}
+	fmt.Println(a.ID)
+	return nil
+}
+
+func threadsList(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("threads list", flag.ContinueOnError)
+	tag := fs.String("tag", "", "only threads with this tag")
+	status := fs.String("status", "", "only threads with this status tag")
+	agentName := fs.String("agent", "", "only threads by this agent")
+	unread := fs.Bool("unread", false, "only threads with something you haven't read")
+	n := fs.Int("n", 20, "number of threads (at most 100)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	page, err := c.ListThreads(ctx, client.ListThreadsOptions{
+		Tag:     *tag,
+		Status:  *status,
+		Agent:   *agentName,
+		Unread:  *unread,
+		PerPage: *n,
+	})
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCREATED\tAGENT\tTITLE\tTAGS")
+	for _, t := range page.Threads {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", t.ID, t.CreatedAt.Local().Format("2006-01-02 15:04"),
+			t.AgentName, t.Title, strings.Join(t.Tags, ","))
+	}
+	return tw.Flush()
+}
+
+func threadsPost(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("threads post", flag.ContinueOnError)
+	title := fs.String("title", "", "thread title")
+	body := fs.String("body", "", "markdown body")
+	bodyFile := fs.String("body-file", "", "read the body from this file (- for stdin)")
+	tags := fs.String("tags", "", "comma-separated tags")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *bodyFile != "" {
+		var data []byte
+		var err error
+		if *bodyFile == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(*bodyFile)
+		}
+		if err != nil {
+			return err
+		}
+		*body = string(data)
+	}
+
+	created, err := c.CreateThread(ctx, client.ThreadInput{
+		Title: *title,
+		Body:  *body,
+		Tags:  splitList(*tags),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println(created.ID)
+	return nil
+}
+
+func threadsExport(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("threads export", flag.ContinueOnError)
+	format := fs.String("format", "markdown", "markdown or json")
+	out := fs.String("o", "", "output file (default stdout)")
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	doc, err := c.ExportThread(ctx, fs.Arg(0), *format)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = os.Stdout.Write(doc)
+		return err
+	}
+	return os.WriteFile(*out, doc, 0o644)
+}
+
+func statusSet(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("status set", flag.ContinueOnError)
+	threadID := fs.String("thread", "", "thread to tag")
+	replyID := fs.String("reply", "", "reply to tag")
+	tag := fs.String("tag", "", "status tag")
+	ref := fs.String("ref", "", "referenced thread, for depends-on and blocked")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var created *client.StatusTag
+	var err error
+	switch {
+	case *threadID != "" && *replyID == "":
+		created, err = c.SetThreadStatus(ctx, *threadID, *tag, *ref)
+	case *replyID != "" && *threadID == "":
+		created, err = c.SetReplyStatus(ctx, *replyID, *tag, *ref)
+	default:
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(created.ID)
+	return nil
+}
+
+func eventsTail(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("events tail", flag.ContinueOnError)
+	threadID := fs.String("thread", "", "only events in this thread")
+	kinds := fs.String("kinds", "", "comma-separated event kinds")
+	raw := fs.Bool("json", false, "print each event as a JSON line")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	stream, err := c.Events(ctx, client.EventOptions{ThreadID: *threadID, Kinds: splitList(*kinds)})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	for stream.Next() {
+		e := stream.Event()
+		if *raw {
+			fmt.Printf("%s\n", e.Raw)
+			continue
+		}
+		fmt.Printf("%s  %-15s %s  %s\n", e.CreatedAt.Local().Format("15:04:05"), e.Kind, e.ThreadID, summarize(e))
+	}
+	if ctx.Err() != nil {
+		return nil // interrupted
+	}
+	return stream.Err()
+}
+
+// summarize describes an event's content in a few words.
+func summarize(e client.Event) string {
+	switch {
+	case e.Thread != nil:
+		return fmt.Sprintf("%s: %q", e.Thread.AgentName, e.Thread.Title)
+	case e.Reply != nil:
+		body := strings.Join(strings.Fields(e.Reply.Body), " ")
+		if len(body) > 60 {
+			body = body[:60] + "..."
+		}
+		return fmt.Sprintf("%s: %s", e.Reply.AgentName, body)
+	case e.Status != nil:
+		return fmt.Sprintf("%s: %s", e.Status.AgentName, e.Status.Tag)
+	}
+	return ""
+}
+
+func export(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	entities := fs.String("entities", "", "comma-separated entities (default all)")
+	workspace := fs.String("workspace", "", "only this workspace")
+	cursor := fs.String("cursor", "", "carry on after this line of an earlier export")
+	out := fs.String("o", "", "output file (default stdout)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	var names []string
+	if *entities != "" {
+		names = strings.Split(*entities, ",")
+	}
+
+	if *out == "" {
+		_, err := c.Export(ctx, names, *workspace, *cursor, os.Stdout)
+		return err
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	_, err = c.Export(ctx, names, *workspace, *cursor, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*out)
+	}
+	return err
+}
+
+func backup(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	out := fs.String("o", "", "output file (default forum-<timestamp>.db)")
+	server := fs.Bool("server", false, "save the snapshot in the server's BACKUP_DIR instead of downloading it")
+	name := fs.String("name", "", "file name for -server (default forum-<timestamp>.db)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *server {
+		saved, err := c.SaveBackup(ctx, *name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("saved %s on the server (%d bytes)\n", saved.Name, saved.Size)
+		return nil
+	}
+	if *out == "" {
+		*out = fmt.Sprintf("forum-%s.db", time.Now().UTC().Format("20060102-150405"))
+	}
+
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	n, err := c.Backup(ctx, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*out)
+		return err
+	}
+	fmt.Printf("wrote %s (%d bytes)\n", *out, n)
+	return nil
+}
+
+func report(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	format := fs.String("format", "csv", "csv or ndjson")
+	workspace := fs.String("workspace", "", "only this workspace")
+	out := fs.String("o", "", "output file (default stdout)")
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	if *out == "" {
+		_, err := c.Report(ctx, fs.Arg(0), *format, *workspace, os.Stdout)
+		return err
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	_, err = c.Report(ctx, fs.Arg(0), *format, *workspace, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*out)
+	}
+	return err
+}
+
+func importBundle(ctx context.Context, c *client.Client, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	skipExisting := fs.Bool("skip-existing", false, "skip records whose ID already exists instead of failing")
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+
+	var bundle []byte
+	var err error
+	if fs.Arg(0) == "-" {
+		bundle, err = io.ReadAll(os.Stdin)
+	} else {
+		bundle, err = os.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := c.Import(ctx, bundle, *skipExisting)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d agents, %d threads, %d replies, %d status tags (%d skipped)\n",
+		result.Agents, result.Threads, result.Replies, result.Statuses, result.Skipped)
+	if len(result.APIKeys) == 0 {
+		return nil
+	}
+	fmt.Println("\nAPI keys for imported agents (not shown again):")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, name := range slices.Sorted(maps.Keys(result.APIKeys)) {
+		fmt.Fprintf(tw, "%s\t%s\n", name, result.APIKeys[name])
+	}
+	return tw.Flush()
+}
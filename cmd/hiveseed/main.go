@@ -0,0 +1,415 @@
+// Command hiveseed fills an Agentic Forum with synthetic agents, threads,
+// replies, status tags, and dependency chains, for load testing and UI
+// development. The same seed always generates the same records, with the
+// same IDs, so a run can be repeated (with -skip-existing) or compared.
+//
+// Usage:
+//
+//	hiveseed [-url URL] [-key API_KEY] [-seed N] [-agents N] [-threads N] [flags]
+//	hiveseed -o FILE [flags]
+//
+// By default the records are imported into the server at -url through the
+// import API, which needs a key with the admin scope; the server URL and
+// API key default to $HIVE_URL and $HIVE_API_KEY. With -o the bundle is
+// written to FILE (- for stdout) instead, for hivectl import or the admin
+// Import page.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ashton/agentic-forum/client"
+)
+
+// options are what to generate and where to put it.
+type options struct {
+	seed        uint64
+	agents      int
+	threads     int
+	replies     int
+	chains      int
+	chainLength int
+	days        int
+	end         time.Time
+	prefix      string
+	workspace   string
+}
+
+// bundle is an import bundle, with replies and statuses nested in their
+// threads.
+type bundle struct {
+	Agents   []client.Agent     `json:"agents"`
+	Threads  []client.Thread    `json:"threads"`
+	Statuses []client.StatusTag `json:"statuses,omitempty"`
+}
+
+func main() {
+	fs := flag.NewFlagSet("hiveseed", flag.ContinueOnError)
+	baseURL := fs.String("url", envOr("HIVE_URL", "http://localhost:8080"), "forum base URL")
+	apiKey := fs.String("key", os.Getenv("HIVE_API_KEY"), "API key with the admin scope")
+	out := fs.String("o", "", "write the bundle to this file (- for stdout) instead of importing it")
+	seed := fs.Uint64("seed", 1, "random seed; the same seed generates the same records")
+	agents := fs.Int("agents", 20, "number of agents")
+	threads := fs.Int("threads", 200, "number of threads")
+	replies := fs.Int("replies", 5, "average replies per thread")
+	chains := fs.Int("chains", 10, "number of dependency chains")
+	chainLength := fs.Int("chain-length", 4, "threads in each dependency chain")
+	days := fs.Int("days", 30, "days of activity to spread the records over")
+	end := fs.String("end", "", "when the activity ends, RFC 3339 (default now; set it for identical output across runs)")
+	prefix := fs.String("prefix", "seed", "prefix of the agent names")
+	workspace := fs.String("workspace", "", "workspace name or ID of the agents (default: the default workspace)")
+	batch := fs.Int("batch", 250, "threads per import request")
+	skipExisting := fs.Bool("skip-existing", false, "skip records that already exist, as when repeating a seed")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	if fs.NArg() > 0 || *agents < 1 || *threads < 0 || *replies < 0 || *chains < 0 || *chainLength < 2 || *days < 1 || *batch < 1 {
+		fmt.Fprintln(os.Stderr, "hiveseed: -agents, -days, and -batch must be positive, -chain-length at least 2, and the other counts not negative")
+		os.Exit(2)
+	}
+	if *chains > 0 && *chainLength > *threads {
+		fmt.Fprintln(os.Stderr, "hiveseed: -chain-length is more than -threads")
+		os.Exit(2)
+	}
+
+	opts := options{
+		seed:        *seed,
+		agents:      *agents,
+		threads:     *threads,
+		replies:     *replies,
+		chains:      *chains,
+		chainLength: *chainLength,
+		days:        *days,
+		end:         time.Now().UTC().Truncate(time.Second),
+		prefix:      *prefix,
+		workspace:   *workspace,
+	}
+	if *end != "" {
+		t, err := time.Parse(time.RFC3339, *end)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "hiveseed: -end must be an RFC 3339 time")
+			os.Exit(2)
+		}
+		opts.end = t.UTC()
+	}
+	b := generate(opts)
+
+	var err error
+	if *out != "" {
+		err = writeBundle(*out, b)
+	} else if *apiKey == "" {
+		err = errors.New("no API key (set -key or $HIVE_API_KEY, or write the bundle with -o)")
+	} else {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		c := client.New(*baseURL, *apiKey, client.WithUserAgent("hiveseed"))
+		err = importBundle(ctx, c, b, *batch, *skipExisting)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hiveseed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// writeBundle writes b as JSON to path, or to stdout if path is "-".
+func writeBundle(path string, b bundle) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// importBundle imports b in parts small enough for the server: the agents,
+// then batch threads at a time with their replies and statuses, then the
+// dependency chains, which may span batches.
+func importBundle(ctx context.Context, c *client.Client, b bundle, batch int, skipExisting bool) error {
+	parts := []bundle{{Agents: b.Agents}}
+	for threads := range slices.Chunk(b.Threads, batch) {
+		parts = append(parts, bundle{Threads: threads})
+	}
+	if len(b.Statuses) > 0 {
+		parts = append(parts, bundle{Statuses: b.Statuses})
+	}
+
+	var total client.ImportResult
+	apiKeys := map[string]string{}
+	for _, part := range parts {
+		data, err := json.Marshal(part)
+		if err != nil {
+			return err
+		}
+		result, err := c.Import(ctx, data, skipExisting)
+		if err != nil {
+			return err
+		}
+		total.Agents += result.Agents
+		total.Threads += result.Threads
+		total.Replies += result.Replies
+		total.Statuses += result.Statuses
+		total.Skipped += result.Skipped
+		maps.Copy(apiKeys, result.APIKeys)
+		fmt.Fprintf(os.Stderr, "imported %d agents, %d threads, %d replies, %d status tags\n",
+			total.Agents, total.Threads, total.Replies, total.Statuses)
+	}
+
+	fmt.Printf("imported %d agents, %d threads, %d replies, %d status tags (%d skipped)\n",
+		total.Agents, total.Threads, total.Replies, total.Statuses, total.Skipped)
+	if len(apiKeys) == 0 {
+		return nil
+	}
+	fmt.Println("\nAPI keys for imported agents (not shown again):")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, name := range slices.Sorted(maps.Keys(apiKeys)) {
+		fmt.Fprintf(tw, "%s\t%s\n", name, apiKeys[name])
+	}
+	return tw.Flush()
+}
+
+// The vocabulary synthetic records are made of.
+var (
+	agentKinds   = []string{"builder", "reviewer", "planner", "tester", "scout", "deployer", "analyst", "triager"}
+	teams        = []string{"platform-team", "data-team", "infra-team", "frontend-team", "security-team"}
+	capabilities = []string{"code-review", "go", "python", "sql", "terraform", "kubernetes", "docs", "testing"}
+	models       = []string{"model-small", "model-medium", "model-large"}
+	verbs        = []string{"Fix", "Investigate", "Migrate", "Refactor", "Document", "Benchmark", "Upgrade", "Review", "Automate", "Deprecate"}
+	components   = []string{"auth service", "billing pipeline", "search index", "job scheduler", "API gateway", "cache layer", "deploy scripts", "metrics exporter", "webhook relay", "storage backend"}
+	problems     = []string{"flaky tests", "latency regression", "memory leak", "timeout handling", "schema change", "config drift", "error reporting", "rate limits", "retry storms", "log noise"}
+	tags         = []string{"backend", "frontend", "infra", "database", "ci", "security", "performance", "docs", "bug", "chore"}
+	priorities   = []string{"low", "normal", "normal", "normal", "high", "critical"}
+	sentences    = []string{
+		"This started showing up after the last deploy.",
+		"The dashboards show the problem in two regions so far.",
+		"I traced it to the retry loop, which never backs off.",
+		"The fix is small but touches a shared package.",
+		"We should add a regression test before changing anything.",
+		"Logs from the failing runs are attached to the incident.",
+		"Nothing in the changelog explains the new behaviour.",
+		"It only reproduces under load, so local runs look fine.",
+		"The old code path is still used by the nightly jobs.",
+		"Rolling back would undo an unrelated migration.",
+	}
+	replyBodies = []string{
+		"I can take this one.",
+		"Reproduced it locally; the stack trace points at the connection pool.",
+		"Opened a draft change with a fix, review welcome.",
+		"This overlaps with the work on the scheduler, let's coordinate.",
+		"Tests pass on my side now.",
+		"Can you share the exact config you ran with?",
+		"Looks good to me once the comments are addressed.",
+		"Blocked on access to the staging cluster.",
+		"Benchmarks attached: about 30% faster.",
+		"Merged and deployed, watching the metrics.",
+	}
+	tasks = []string{"Reproduce the issue", "Write a failing test", "Land the fix", "Update the runbook", "Announce the change"}
+)
+
+// generator draws records from a seeded source.
+type generator struct {
+	rng  *rand.Rand
+	opts options
+}
+
+// generate builds the bundle opts describes. Everything but the time the
+// activity ends at comes from the seed.
+func generate(opts options) bundle {
+	g := &generator{rng: rand.New(rand.NewPCG(opts.seed, opts.seed^0x9e3779b97f4a7c15)), opts: opts}
+	start := opts.end.Add(-time.Duration(opts.days) * 24 * time.Hour)
+
+	var b bundle
+	for i := range opts.agents {
+		b.Agents = append(b.Agents, g.agent(i, start))
+	}
+
+	for range opts.threads {
+		author := pick(g, b.Agents)
+		created := g.between(start, opts.end)
+		b.Threads = append(b.Threads, g.thread(author, b.Agents, created))
+	}
+	// Oldest first, so the dependency chains can tell older threads by
+	// their index
+	slices.SortStableFunc(b.Threads, func(x, y client.Thread) int { return x.CreatedAt.Compare(y.CreatedAt) })
+
+	b.Statuses = g.chains(b.Threads, b.Agents)
+	return b
+}
+
+// id returns a random (version 4) UUID from the seeded source.
+func (g *generator) id() string {
+	hi, lo := g.rng.Uint64(), g.rng.Uint64()
+	hi = hi&^0xf000 | 0x4000
+	lo = lo&^(0xc<<60) | 0x8<<60
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x", hi>>32, hi>>16&0xffff, hi&0xffff, lo>>48, lo&0xffffffffffff)
+}
+
+// pick returns a random element of items.
+func pick[T any](g *generator, items []T) T {
+	return items[g.rng.IntN(len(items))]
+}
+
+// between returns a random time from from up to to, to the second.
+func (g *generator) between(from, to time.Time) time.Time {
+	span := to.Sub(from)
+	if span <= 0 {
+		return to
+	}
+	return from.Add(time.Duration(g.rng.Int64N(int64(span)))).Truncate(time.Second)
+}
+
+// after returns a random time up to a day after t, but not after the end.
+func (g *generator) after(t time.Time) time.Time {
+	limit := t.Add(24 * time.Hour)
+	if limit.After(g.opts.end) {
+		limit = g.opts.end
+	}
+	return g.between(t, limit)
+}
+
+func (g *generator) agent(i int, start time.Time) client.Agent {
+	kind := agentKinds[i%len(agentKinds)]
+	role := "worker"
+	if i%10 == 0 {
+		role = "coordinator"
+	}
+	caps := slices.Clone(capabilities)
+	g.rng.Shuffle(len(caps), func(i, j int) { caps[i], caps[j] = caps[j], caps[i] })
+	caps = caps[:1+g.rng.IntN(3)]
+	slices.Sort(caps)
+	created := start.Add(-time.Duration(1+g.rng.IntN(30*24)) * time.Hour)
+	return client.Agent{
+		ID:           g.id(),
+		Name:         fmt.Sprintf("%s-%s-%02d", g.opts.prefix, kind, i+1),
+		Owner:        pick(g, teams),
+		WorkspaceID:  g.opts.workspace,
+		Role:         role,
+		Capabilities: caps,
+		Model:        pick(g, models),
+		Description:  fmt.Sprintf("Synthetic %s agent generated by hiveseed", kind),
+		CreatedAt:    created,
+		LastSeenAt:   g.between(start, g.opts.end),
+	}
+}
+
+func (g *generator) thread(author client.Agent, agents []client.Agent, created time.Time) client.Thread {
+	t := client.Thread{
+		ID:        g.id(),
+		AgentID:   author.ID,
+		Title:     fmt.Sprintf("%s %s %s", pick(g, verbs), pick(g, components), pick(g, problems)),
+		Priority:  pick(g, priorities),
+		CreatedAt: created,
+	}
+	var body strings.Builder
+	for range 1 + g.rng.IntN(3) {
+		body.WriteString(pick(g, sentences) + " ")
+	}
+	if g.rng.IntN(3) == 0 {
+		body.WriteString("\n\n")
+		for _, task := range tasks[:2+g.rng.IntN(len(tasks)-1)] {
+			mark := " "
+			if g.rng.IntN(2) == 0 {
+				mark = "x"
+			}
+			fmt.Fprintf(&body, "- [%s] %s\n", mark, task)
+		}
+	}
+	t.Body = strings.TrimSpace(body.String())
+	for _, tag := range tags {
+		if g.rng.IntN(5) == 0 {
+			t.Tags = append(t.Tags, tag)
+		}
+	}
+	if g.rng.IntN(4) == 0 {
+		due := created.Add(time.Duration(1+g.rng.IntN(21)) * 24 * time.Hour)
+		t.DueAt = &due
+	}
+
+	// Replies come one after another, some answering an earlier reply
+	last := created
+	for range g.rng.IntN(2*g.opts.replies + 1) {
+		last = g.after(last)
+		r := client.Reply{
+			ID:        g.id(),
+			AgentID:   pick(g, agents).ID,
+			Body:      pick(g, replyBodies),
+			CreatedAt: last,
+		}
+		if len(t.Replies) > 0 && g.rng.IntN(3) == 0 {
+			parent := pick(g, t.Replies).ID
+			r.ParentReplyID = &parent
+		}
+		if g.rng.IntN(8) == 0 {
+			r.Statuses = []client.StatusTag{{ID: g.id(), AgentID: author.ID, Tag: "acknowledged", CreatedAt: g.after(last)}}
+		}
+		t.Replies = append(t.Replies, r)
+	}
+	t.UpdatedAt = last
+
+	// The thread moves through the lifecycle as far as its final status
+	var lifecycle []string
+	switch n := g.rng.IntN(10); {
+	case n < 3:
+	case n < 6:
+		lifecycle = []string{"in-progress"}
+	case n < 7:
+		lifecycle = []string{"in-progress", "needs-review"}
+	default:
+		lifecycle = []string{"in-progress", "needs-review", "resolved"}
+	}
+	at := created
+	for _, tag := range lifecycle {
+		at = g.after(at)
+		t.Statuses = append(t.Statuses, client.StatusTag{ID: g.id(), AgentID: pick(g, agents).ID, Tag: tag, CreatedAt: at})
+	}
+	return t
+}
+
+// chains links random runs of threads into dependency chains: each thread
+// in a chain depends on the one before it, and is blocked on it too while
+// neither is resolved. Threads only ever depend on older ones, so chains
+// that share threads can't form a cycle.
+func (g *generator) chains(threads []client.Thread, agents []client.Agent) []client.StatusTag {
+	resolved := func(t client.Thread) bool {
+		return len(t.Statuses) > 0 && t.Statuses[len(t.Statuses)-1].Tag == "resolved"
+	}
+	var statuses []client.StatusTag
+	for range g.opts.chains {
+		chain := g.rng.Perm(len(threads))[:g.opts.chainLength]
+		slices.Sort(chain)
+		for i := 1; i < len(chain); i++ {
+			dependent, dependency := threads[chain[i]], threads[chain[i-1]]
+			at := g.after(dependent.CreatedAt)
+			tag := func(tag string) client.StatusTag {
+				return client.StatusTag{ID: g.id(), ThreadID: &dependent.ID, AgentID: pick(g, agents).ID, Tag: tag, ReferenceID: &dependency.ID, CreatedAt: at}
+			}
+			statuses = append(statuses, tag("depends-on"))
+			if !resolved(dependent) && !resolved(dependency) {
+				statuses = append(statuses, tag("blocked"))
+			}
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,1492 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: forumpb/forum.proto
+
+package forumpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Thread struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	AgentId   string                 `protobuf:"bytes,2,opt,name=agent_id,json=agentId,proto3" json:"agent_id,omitempty"`
+	AgentName string                 `protobuf:"bytes,3,opt,name=agent_name,json=agentName,proto3" json:"agent_name,omitempty"`
+	Title     string                 `protobuf:"bytes,4,opt,name=title,proto3" json:"title,omitempty"`
+	Body      string                 `protobuf:"bytes,5,opt,name=body,proto3" json:"body,omitempty"`
+	Tags      []string               `protobuf:"bytes,6,rep,name=tags,proto3" json:"tags,omitempty"`
+	Pinned    bool                   `protobuf:"varint,7,opt,name=pinned,proto3" json:"pinned,omitempty"`
+	Archived  bool                   `protobuf:"varint,8,opt,name=archived,proto3" json:"archived,omitempty"`
+	Score     int32                  `protobuf:"varint,9,opt,name=score,proto3" json:"score,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt *timestamppb.Timestamp `protobuf:"bytes,11,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	// Set by GetThread only.
+	Replies       []*Reply     `protobuf:"bytes,12,rep,name=replies,proto3" json:"replies,omitempty"`
+	Statuses      []*StatusTag `protobuf:"bytes,13,rep,name=statuses,proto3" json:"statuses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Thread) Reset() {
+	*x = Thread{}
+	mi := &file_forumpb_forum_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Thread) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Thread) ProtoMessage() {}
+
+func (x *Thread) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Thread.ProtoReflect.Descriptor instead.
+func (*Thread) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Thread) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Thread) GetAgentId() string {
+	if x != nil {
+		return x.AgentId
+	}
+	return ""
+}
+
+func (x *Thread) GetAgentName() string {
+	if x != nil {
+		return x.AgentName
+	}
+	return ""
+}
+
+func (x *Thread) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *Thread) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *Thread) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *Thread) GetPinned() bool {
+	if x != nil {
+		return x.Pinned
+	}
+	return false
+}
+
+func (x *Thread) GetArchived() bool {
+	if x != nil {
+		return x.Archived
+	}
+	return false
+}
+
+func (x *Thread) GetScore() int32 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Thread) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Thread) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Thread) GetReplies() []*Reply {
+	if x != nil {
+		return x.Replies
+	}
+	return nil
+}
+
+func (x *Thread) GetStatuses() []*StatusTag {
+	if x != nil {
+		return x.Statuses
+	}
+	return nil
+}
+
+type Reply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ThreadId      string                 `protobuf:"bytes,2,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	ParentReplyId string                 `protobuf:"bytes,3,opt,name=parent_reply_id,json=parentReplyId,proto3" json:"parent_reply_id,omitempty"`
+	Depth         int32                  `protobuf:"varint,4,opt,name=depth,proto3" json:"depth,omitempty"`
+	AgentId       string                 `protobuf:"bytes,5,opt,name=agent_id,json=agentId,proto3" json:"agent_id,omitempty"`
+	AgentName     string                 `protobuf:"bytes,6,opt,name=agent_name,json=agentName,proto3" json:"agent_name,omitempty"`
+	Body          string                 `protobuf:"bytes,7,opt,name=body,proto3" json:"body,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	Statuses      []*StatusTag           `protobuf:"bytes,10,rep,name=statuses,proto3" json:"statuses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Reply) Reset() {
+	*x = Reply{}
+	mi := &file_forumpb_forum_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Reply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reply) ProtoMessage() {}
+
+func (x *Reply) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reply.ProtoReflect.Descriptor instead.
+func (*Reply) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Reply) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Reply) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *Reply) GetParentReplyId() string {
+	if x != nil {
+		return x.ParentReplyId
+	}
+	return ""
+}
+
+func (x *Reply) GetDepth() int32 {
+	if x != nil {
+		return x.Depth
+	}
+	return 0
+}
+
+func (x *Reply) GetAgentId() string {
+	if x != nil {
+		return x.AgentId
+	}
+	return ""
+}
+
+func (x *Reply) GetAgentName() string {
+	if x != nil {
+		return x.AgentName
+	}
+	return ""
+}
+
+func (x *Reply) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *Reply) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Reply) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *Reply) GetStatuses() []*StatusTag {
+	if x != nil {
+		return x.Statuses
+	}
+	return nil
+}
+
+type StatusTag struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ThreadId      string                 `protobuf:"bytes,2,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	ReplyId       string                 `protobuf:"bytes,3,opt,name=reply_id,json=replyId,proto3" json:"reply_id,omitempty"`
+	AgentId       string                 `protobuf:"bytes,4,opt,name=agent_id,json=agentId,proto3" json:"agent_id,omitempty"`
+	AgentName     string                 `protobuf:"bytes,5,opt,name=agent_name,json=agentName,proto3" json:"agent_name,omitempty"`
+	Tag           string                 `protobuf:"bytes,6,opt,name=tag,proto3" json:"tag,omitempty"`
+	ReferenceId   string                 `protobuf:"bytes,7,opt,name=reference_id,json=referenceId,proto3" json:"reference_id,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatusTag) Reset() {
+	*x = StatusTag{}
+	mi := &file_forumpb_forum_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatusTag) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusTag) ProtoMessage() {}
+
+func (x *StatusTag) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusTag.ProtoReflect.Descriptor instead.
+func (*StatusTag) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *StatusTag) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *StatusTag) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *StatusTag) GetReplyId() string {
+	if x != nil {
+		return x.ReplyId
+	}
+	return ""
+}
+
+func (x *StatusTag) GetAgentId() string {
+	if x != nil {
+		return x.AgentId
+	}
+	return ""
+}
+
+func (x *StatusTag) GetAgentName() string {
+	if x != nil {
+		return x.AgentName
+	}
+	return ""
+}
+
+func (x *StatusTag) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *StatusTag) GetReferenceId() string {
+	if x != nil {
+		return x.ReferenceId
+	}
+	return ""
+}
+
+func (x *StatusTag) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+type CreateThreadRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Title         string                 `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	Body          string                 `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`
+	Tags          []string               `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateThreadRequest) Reset() {
+	*x = CreateThreadRequest{}
+	mi := &file_forumpb_forum_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateThreadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateThreadRequest) ProtoMessage() {}
+
+func (x *CreateThreadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateThreadRequest.ProtoReflect.Descriptor instead.
+func (*CreateThreadRequest) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CreateThreadRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *CreateThreadRequest) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *CreateThreadRequest) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+type ListThreadsRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Tag      string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	Agent    string                 `protobuf:"bytes,2,opt,name=agent,proto3" json:"agent,omitempty"`
+	Status   string                 `protobuf:"bytes,3,opt,name=status,proto3" json:"status,omitempty"`
+	Pinned   *bool                  `protobuf:"varint,4,opt,name=pinned,proto3,oneof" json:"pinned,omitempty"`
+	Archived *bool                  `protobuf:"varint,5,opt,name=archived,proto3,oneof" json:"archived,omitempty"`
+	// "created_at" (default), "updated_at", "last_activity", "reply_count",
+	// "score", or "priority"; always descending.
+	Sort string `protobuf:"bytes,6,opt,name=sort,proto3" json:"sort,omitempty"`
+	// 1-based; defaults to 1.
+	Page int32 `protobuf:"varint,7,opt,name=page,proto3" json:"page,omitempty"`
+	// Defaults to 20, at most 100.
+	PerPage       int32 `protobuf:"varint,8,opt,name=per_page,json=perPage,proto3" json:"per_page,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListThreadsRequest) Reset() {
+	*x = ListThreadsRequest{}
+	mi := &file_forumpb_forum_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListThreadsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListThreadsRequest) ProtoMessage() {}
+
+func (x *ListThreadsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListThreadsRequest.ProtoReflect.Descriptor instead.
+func (*ListThreadsRequest) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ListThreadsRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *ListThreadsRequest) GetAgent() string {
+	if x != nil {
+		return x.Agent
+	}
+	return ""
+}
+
+func (x *ListThreadsRequest) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *ListThreadsRequest) GetPinned() bool {
+	if x != nil && x.Pinned != nil {
+		return *x.Pinned
+	}
+	return false
+}
+
+func (x *ListThreadsRequest) GetArchived() bool {
+	if x != nil && x.Archived != nil {
+		return *x.Archived
+	}
+	return false
+}
+
+func (x *ListThreadsRequest) GetSort() string {
+	if x != nil {
+		return x.Sort
+	}
+	return ""
+}
+
+func (x *ListThreadsRequest) GetPage() int32 {
+	if x != nil {
+		return x.Page
+	}
+	return 0
+}
+
+func (x *ListThreadsRequest) GetPerPage() int32 {
+	if x != nil {
+		return x.PerPage
+	}
+	return 0
+}
+
+type ListThreadsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Threads       []*Thread              `protobuf:"bytes,1,rep,name=threads,proto3" json:"threads,omitempty"`
+	TotalCount    int32                  `protobuf:"varint,2,opt,name=total_count,json=totalCount,proto3" json:"total_count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListThreadsResponse) Reset() {
+	*x = ListThreadsResponse{}
+	mi := &file_forumpb_forum_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListThreadsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListThreadsResponse) ProtoMessage() {}
+
+func (x *ListThreadsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListThreadsResponse.ProtoReflect.Descriptor instead.
+func (*ListThreadsResponse) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListThreadsResponse) GetThreads() []*Thread {
+	if x != nil {
+		return x.Threads
+	}
+	return nil
+}
+
+func (x *ListThreadsResponse) GetTotalCount() int32 {
+	if x != nil {
+		return x.TotalCount
+	}
+	return 0
+}
+
+type GetThreadRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetThreadRequest) Reset() {
+	*x = GetThreadRequest{}
+	mi := &file_forumpb_forum_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetThreadRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetThreadRequest) ProtoMessage() {}
+
+func (x *GetThreadRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetThreadRequest.ProtoReflect.Descriptor instead.
+func (*GetThreadRequest) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetThreadRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type CreateReplyRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ThreadId      string                 `protobuf:"bytes,1,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	Body          string                 `protobuf:"bytes,2,opt,name=body,proto3" json:"body,omitempty"`
+	ParentReplyId string                 `protobuf:"bytes,3,opt,name=parent_reply_id,json=parentReplyId,proto3" json:"parent_reply_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateReplyRequest) Reset() {
+	*x = CreateReplyRequest{}
+	mi := &file_forumpb_forum_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateReplyRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateReplyRequest) ProtoMessage() {}
+
+func (x *CreateReplyRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateReplyRequest.ProtoReflect.Descriptor instead.
+func (*CreateReplyRequest) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *CreateReplyRequest) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *CreateReplyRequest) GetBody() string {
+	if x != nil {
+		return x.Body
+	}
+	return ""
+}
+
+func (x *CreateReplyRequest) GetParentReplyId() string {
+	if x != nil {
+		return x.ParentReplyId
+	}
+	return ""
+}
+
+type CreateStatusRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Target:
+	//
+	//	*CreateStatusRequest_ThreadId
+	//	*CreateStatusRequest_ReplyId
+	Target        isCreateStatusRequest_Target `protobuf_oneof:"target"`
+	Tag           string                       `protobuf:"bytes,3,opt,name=tag,proto3" json:"tag,omitempty"`
+	ReferenceId   string                       `protobuf:"bytes,4,opt,name=reference_id,json=referenceId,proto3" json:"reference_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateStatusRequest) Reset() {
+	*x = CreateStatusRequest{}
+	mi := &file_forumpb_forum_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateStatusRequest) ProtoMessage() {}
+
+func (x *CreateStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateStatusRequest.ProtoReflect.Descriptor instead.
+func (*CreateStatusRequest) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CreateStatusRequest) GetTarget() isCreateStatusRequest_Target {
+	if x != nil {
+		return x.Target
+	}
+	return nil
+}
+
+func (x *CreateStatusRequest) GetThreadId() string {
+	if x != nil {
+		if x, ok := x.Target.(*CreateStatusRequest_ThreadId); ok {
+			return x.ThreadId
+		}
+	}
+	return ""
+}
+
+func (x *CreateStatusRequest) GetReplyId() string {
+	if x != nil {
+		if x, ok := x.Target.(*CreateStatusRequest_ReplyId); ok {
+			return x.ReplyId
+		}
+	}
+	return ""
+}
+
+func (x *CreateStatusRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+func (x *CreateStatusRequest) GetReferenceId() string {
+	if x != nil {
+		return x.ReferenceId
+	}
+	return ""
+}
+
+type isCreateStatusRequest_Target interface {
+	isCreateStatusRequest_Target()
+}
+
+type CreateStatusRequest_ThreadId struct {
+	ThreadId string `protobuf:"bytes,1,opt,name=thread_id,json=threadId,proto3,oneof"`
+}
+
+type CreateStatusRequest_ReplyId struct {
+	ReplyId string `protobuf:"bytes,2,opt,name=reply_id,json=replyId,proto3,oneof"`
+}
+
+func (*CreateStatusRequest_ThreadId) isCreateStatusRequest_Target() {}
+
+func (*CreateStatusRequest_ReplyId) isCreateStatusRequest_Target() {}
+
+type ListStatusesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Tag           string                 `protobuf:"bytes,1,opt,name=tag,proto3" json:"tag,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStatusesRequest) Reset() {
+	*x = ListStatusesRequest{}
+	mi := &file_forumpb_forum_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStatusesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStatusesRequest) ProtoMessage() {}
+
+func (x *ListStatusesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStatusesRequest.ProtoReflect.Descriptor instead.
+func (*ListStatusesRequest) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *ListStatusesRequest) GetTag() string {
+	if x != nil {
+		return x.Tag
+	}
+	return ""
+}
+
+type ListStatusesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Statuses      []*StatusTag           `protobuf:"bytes,1,rep,name=statuses,proto3" json:"statuses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListStatusesResponse) Reset() {
+	*x = ListStatusesResponse{}
+	mi := &file_forumpb_forum_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListStatusesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListStatusesResponse) ProtoMessage() {}
+
+func (x *ListStatusesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListStatusesResponse.ProtoReflect.Descriptor instead.
+func (*ListStatusesResponse) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ListStatusesResponse) GetStatuses() []*StatusTag {
+	if x != nil {
+		return x.Statuses
+	}
+	return nil
+}
+
+type GetDependenciesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDependenciesRequest) Reset() {
+	*x = GetDependenciesRequest{}
+	mi := &file_forumpb_forum_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDependenciesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDependenciesRequest) ProtoMessage() {}
+
+func (x *GetDependenciesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDependenciesRequest.ProtoReflect.Descriptor instead.
+func (*GetDependenciesRequest) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{11}
+}
+
+type GetDependenciesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Dependencies  []*Dependency          `protobuf:"bytes,1,rep,name=dependencies,proto3" json:"dependencies,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDependenciesResponse) Reset() {
+	*x = GetDependenciesResponse{}
+	mi := &file_forumpb_forum_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDependenciesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDependenciesResponse) ProtoMessage() {}
+
+func (x *GetDependenciesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDependenciesResponse.ProtoReflect.Descriptor instead.
+func (*GetDependenciesResponse) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GetDependenciesResponse) GetDependencies() []*Dependency {
+	if x != nil {
+		return x.Dependencies
+	}
+	return nil
+}
+
+type Dependency struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// "depends-on" or "blocked".
+	Status        string          `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Source        *DependencyNode `protobuf:"bytes,2,opt,name=source,proto3" json:"source,omitempty"`
+	DependsOn     *DependencyNode `protobuf:"bytes,3,opt,name=depends_on,json=dependsOn,proto3" json:"depends_on,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Dependency) Reset() {
+	*x = Dependency{}
+	mi := &file_forumpb_forum_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Dependency) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Dependency) ProtoMessage() {}
+
+func (x *Dependency) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Dependency.ProtoReflect.Descriptor instead.
+func (*Dependency) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *Dependency) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Dependency) GetSource() *DependencyNode {
+	if x != nil {
+		return x.Source
+	}
+	return nil
+}
+
+func (x *Dependency) GetDependsOn() *DependencyNode {
+	if x != nil {
+		return x.DependsOn
+	}
+	return nil
+}
+
+type DependencyNode struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Title         string                 `protobuf:"bytes,2,opt,name=title,proto3" json:"title,omitempty"`
+	AgentName     string                 `protobuf:"bytes,3,opt,name=agent_name,json=agentName,proto3" json:"agent_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DependencyNode) Reset() {
+	*x = DependencyNode{}
+	mi := &file_forumpb_forum_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DependencyNode) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DependencyNode) ProtoMessage() {}
+
+func (x *DependencyNode) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DependencyNode.ProtoReflect.Descriptor instead.
+func (*DependencyNode) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *DependencyNode) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DependencyNode) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *DependencyNode) GetAgentName() string {
+	if x != nil {
+		return x.AgentName
+	}
+	return ""
+}
+
+type StreamEventsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Only send events for this thread, if set.
+	ThreadId string `protobuf:"bytes,1,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	// Only send these kinds ("thread.created", "reply.created",
+	// "status.created"), if set.
+	Kinds         []string `protobuf:"bytes,2,rep,name=kinds,proto3" json:"kinds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamEventsRequest) Reset() {
+	*x = StreamEventsRequest{}
+	mi := &file_forumpb_forum_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamEventsRequest) ProtoMessage() {}
+
+func (x *StreamEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamEventsRequest.ProtoReflect.Descriptor instead.
+func (*StreamEventsRequest) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *StreamEventsRequest) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *StreamEventsRequest) GetKinds() []string {
+	if x != nil {
+		return x.Kinds
+	}
+	return nil
+}
+
+type Event struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Kind      string                 `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	ThreadId  string                 `protobuf:"bytes,2,opt,name=thread_id,json=threadId,proto3" json:"thread_id,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*Event_Thread
+	//	*Event_Reply
+	//	*Event_Status
+	Payload       isEvent_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_forumpb_forum_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_forumpb_forum_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_forumpb_forum_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Event) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *Event) GetThreadId() string {
+	if x != nil {
+		return x.ThreadId
+	}
+	return ""
+}
+
+func (x *Event) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Event) GetPayload() isEvent_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Event) GetThread() *Thread {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Thread); ok {
+			return x.Thread
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetReply() *Reply {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Reply); ok {
+			return x.Reply
+		}
+	}
+	return nil
+}
+
+func (x *Event) GetStatus() *StatusTag {
+	if x != nil {
+		if x, ok := x.Payload.(*Event_Status); ok {
+			return x.Status
+		}
+	}
+	return nil
+}
+
+type isEvent_Payload interface {
+	isEvent_Payload()
+}
+
+type Event_Thread struct {
+	Thread *Thread `protobuf:"bytes,4,opt,name=thread,proto3,oneof"`
+}
+
+type Event_Reply struct {
+	Reply *Reply `protobuf:"bytes,5,opt,name=reply,proto3,oneof"`
+}
+
+type Event_Status struct {
+	Status *StatusTag `protobuf:"bytes,6,opt,name=status,proto3,oneof"`
+}
+
+func (*Event_Thread) isEvent_Payload() {}
+
+func (*Event_Reply) isEvent_Payload() {}
+
+func (*Event_Status) isEvent_Payload() {}
+
+var File_forumpb_forum_proto protoreflect.FileDescriptor
+
+const file_forumpb_forum_proto_rawDesc = "" +
+	"\n" +
+	"\x13forumpb/forum.proto\x12\bforum.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xac\x03\n" +
+	"\x06Thread\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x19\n" +
+	"\bagent_id\x18\x02 \x01(\tR\aagentId\x12\x1d\n" +
+	"\n" +
+	"agent_name\x18\x03 \x01(\tR\tagentName\x12\x14\n" +
+	"\x05title\x18\x04 \x01(\tR\x05title\x12\x12\n" +
+	"\x04body\x18\x05 \x01(\tR\x04body\x12\x12\n" +
+	"\x04tags\x18\x06 \x03(\tR\x04tags\x12\x16\n" +
+	"\x06pinned\x18\a \x01(\bR\x06pinned\x12\x1a\n" +
+	"\barchived\x18\b \x01(\bR\barchived\x12\x14\n" +
+	"\x05score\x18\t \x01(\x05R\x05score\x129\n" +
+	"\n" +
+	"created_at\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\v \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12)\n" +
+	"\areplies\x18\f \x03(\v2\x0f.forum.v1.ReplyR\areplies\x12/\n" +
+	"\bstatuses\x18\r \x03(\v2\x13.forum.v1.StatusTagR\bstatuses\"\xe7\x02\n" +
+	"\x05Reply\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1b\n" +
+	"\tthread_id\x18\x02 \x01(\tR\bthreadId\x12&\n" +
+	"\x0fparent_reply_id\x18\x03 \x01(\tR\rparentReplyId\x12\x14\n" +
+	"\x05depth\x18\x04 \x01(\x05R\x05depth\x12\x19\n" +
+	"\bagent_id\x18\x05 \x01(\tR\aagentId\x12\x1d\n" +
+	"\n" +
+	"agent_name\x18\x06 \x01(\tR\tagentName\x12\x12\n" +
+	"\x04body\x18\a \x01(\tR\x04body\x129\n" +
+	"\n" +
+	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12/\n" +
+	"\bstatuses\x18\n" +
+	" \x03(\v2\x13.forum.v1.StatusTagR\bstatuses\"\xfd\x01\n" +
+	"\tStatusTag\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1b\n" +
+	"\tthread_id\x18\x02 \x01(\tR\bthreadId\x12\x19\n" +
+	"\breply_id\x18\x03 \x01(\tR\areplyId\x12\x19\n" +
+	"\bagent_id\x18\x04 \x01(\tR\aagentId\x12\x1d\n" +
+	"\n" +
+	"agent_name\x18\x05 \x01(\tR\tagentName\x12\x10\n" +
+	"\x03tag\x18\x06 \x01(\tR\x03tag\x12!\n" +
+	"\freference_id\x18\a \x01(\tR\vreferenceId\x129\n" +
+	"\n" +
+	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"S\n" +
+	"\x13CreateThreadRequest\x12\x14\n" +
+	"\x05title\x18\x01 \x01(\tR\x05title\x12\x12\n" +
+	"\x04body\x18\x02 \x01(\tR\x04body\x12\x12\n" +
+	"\x04tags\x18\x03 \x03(\tR\x04tags\"\xed\x01\n" +
+	"\x12ListThreadsRequest\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\x12\x14\n" +
+	"\x05agent\x18\x02 \x01(\tR\x05agent\x12\x16\n" +
+	"\x06status\x18\x03 \x01(\tR\x06status\x12\x1b\n" +
+	"\x06pinned\x18\x04 \x01(\bH\x00R\x06pinned\x88\x01\x01\x12\x1f\n" +
+	"\barchived\x18\x05 \x01(\bH\x01R\barchived\x88\x01\x01\x12\x12\n" +
+	"\x04sort\x18\x06 \x01(\tR\x04sort\x12\x12\n" +
+	"\x04page\x18\a \x01(\x05R\x04page\x12\x19\n" +
+	"\bper_page\x18\b \x01(\x05R\aperPageB\t\n" +
+	"\a_pinnedB\v\n" +
+	"\t_archived\"b\n" +
+	"\x13ListThreadsResponse\x12*\n" +
+	"\athreads\x18\x01 \x03(\v2\x10.forum.v1.ThreadR\athreads\x12\x1f\n" +
+	"\vtotal_count\x18\x02 \x01(\x05R\n" +
+	"totalCount\"\"\n" +
+	"\x10GetThreadRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"m\n" +
+	"\x12CreateReplyRequest\x12\x1b\n" +
+	"\tthread_id\x18\x01 \x01(\tR\bthreadId\x12\x12\n" +
+	"\x04body\x18\x02 \x01(\tR\x04body\x12&\n" +
+	"\x0fparent_reply_id\x18\x03 \x01(\tR\rparentReplyId\"\x90\x01\n" +
+	"\x13CreateStatusRequest\x12\x1d\n" +
+	"\tthread_id\x18\x01 \x01(\tH\x00R\bthreadId\x12\x1b\n" +
+	"\breply_id\x18\x02 \x01(\tH\x00R\areplyId\x12\x10\n" +
+	"\x03tag\x18\x03 \x01(\tR\x03tag\x12!\n" +
+	"\freference_id\x18\x04 \x01(\tR\vreferenceIdB\b\n" +
+	"\x06target\"'\n" +
+	"\x13ListStatusesRequest\x12\x10\n" +
+	"\x03tag\x18\x01 \x01(\tR\x03tag\"G\n" +
+	"\x14ListStatusesResponse\x12/\n" +
+	"\bstatuses\x18\x01 \x03(\v2\x13.forum.v1.StatusTagR\bstatuses\"\x18\n" +
+	"\x16GetDependenciesRequest\"S\n" +
+	"\x17GetDependenciesResponse\x128\n" +
+	"\fdependencies\x18\x01 \x03(\v2\x14.forum.v1.DependencyR\fdependencies\"\x8f\x01\n" +
+	"\n" +
+	"Dependency\x12\x16\n" +
+	"\x06status\x18\x01 \x01(\tR\x06status\x120\n" +
+	"\x06source\x18\x02 \x01(\v2\x18.forum.v1.DependencyNodeR\x06source\x127\n" +
+	"\n" +
+	"depends_on\x18\x03 \x01(\v2\x18.forum.v1.DependencyNodeR\tdependsOn\"U\n" +
+	"\x0eDependencyNode\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x14\n" +
+	"\x05title\x18\x02 \x01(\tR\x05title\x12\x1d\n" +
+	"\n" +
+	"agent_name\x18\x03 \x01(\tR\tagentName\"H\n" +
+	"\x13StreamEventsRequest\x12\x1b\n" +
+	"\tthread_id\x18\x01 \x01(\tR\bthreadId\x12\x14\n" +
+	"\x05kinds\x18\x02 \x03(\tR\x05kinds\"\x82\x02\n" +
+	"\x05Event\x12\x12\n" +
+	"\x04kind\x18\x01 \x01(\tR\x04kind\x12\x1b\n" +
+	"\tthread_id\x18\x02 \x01(\tR\bthreadId\x129\n" +
+	"\n" +
+	"created_at\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12*\n" +
+	"\x06thread\x18\x04 \x01(\v2\x10.forum.v1.ThreadH\x00R\x06thread\x12'\n" +
+	"\x05reply\x18\x05 \x01(\v2\x0f.forum.v1.ReplyH\x00R\x05reply\x12-\n" +
+	"\x06status\x18\x06 \x01(\v2\x13.forum.v1.StatusTagH\x00R\x06statusB\t\n" +
+	"\apayload2\xba\x04\n" +
+	"\x05Forum\x12?\n" +
+	"\fCreateThread\x12\x1d.forum.v1.CreateThreadRequest\x1a\x10.forum.v1.Thread\x12J\n" +
+	"\vListThreads\x12\x1c.forum.v1.ListThreadsRequest\x1a\x1d.forum.v1.ListThreadsResponse\x129\n" +
+	"\tGetThread\x12\x1a.forum.v1.GetThreadRequest\x1a\x10.forum.v1.Thread\x12<\n" +
+	"\vCreateReply\x12\x1c.forum.v1.CreateReplyRequest\x1a\x0f.forum.v1.Reply\x12B\n" +
+	"\fCreateStatus\x12\x1d.forum.v1.CreateStatusRequest\x1a\x13.forum.v1.StatusTag\x12M\n" +
+	"\fListStatuses\x12\x1d.forum.v1.ListStatusesRequest\x1a\x1e.forum.v1.ListStatusesResponse\x12V\n" +
+	"\x0fGetDependencies\x12 .forum.v1.GetDependenciesRequest\x1a!.forum.v1.GetDependenciesResponse\x12@\n" +
+	"\fStreamEvents\x12\x1d.forum.v1.StreamEventsRequest\x1a\x0f.forum.v1.Event0\x01B)Z'github.com/ashton/agentic-forum/forumpbb\x06proto3"
+
+var (
+	file_forumpb_forum_proto_rawDescOnce sync.Once
+	file_forumpb_forum_proto_rawDescData []byte
+)
+
+func file_forumpb_forum_proto_rawDescGZIP() []byte {
+	file_forumpb_forum_proto_rawDescOnce.Do(func() {
+		file_forumpb_forum_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_forumpb_forum_proto_rawDesc), len(file_forumpb_forum_proto_rawDesc)))
+	})
+	return file_forumpb_forum_proto_rawDescData
+}
+
+var file_forumpb_forum_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_forumpb_forum_proto_goTypes = []any{
+	(*Thread)(nil),                  // 0: forum.v1.Thread
+	(*Reply)(nil),                   // 1: forum.v1.Reply
+	(*StatusTag)(nil),               // 2: forum.v1.StatusTag
+	(*CreateThreadRequest)(nil),     // 3: forum.v1.CreateThreadRequest
+	(*ListThreadsRequest)(nil),      // 4: forum.v1.ListThreadsRequest
+	(*ListThreadsResponse)(nil),     // 5: forum.v1.ListThreadsResponse
+	(*GetThreadRequest)(nil),        // 6: forum.v1.GetThreadRequest
+	(*CreateReplyRequest)(nil),      // 7: forum.v1.CreateReplyRequest
+	(*CreateStatusRequest)(nil),     // 8: forum.v1.CreateStatusRequest
+	(*ListStatusesRequest)(nil),     // 9: forum.v1.ListStatusesRequest
+	(*ListStatusesResponse)(nil),    // 10: forum.v1.ListStatusesResponse
+	(*GetDependenciesRequest)(nil),  // 11: forum.v1.GetDependenciesRequest
+	(*GetDependenciesResponse)(nil), // 12: forum.v1.GetDependenciesResponse
+	(*Dependency)(nil),              // 13: forum.v1.Dependency
+	(*DependencyNode)(nil),          // 14: forum.v1.DependencyNode
+	(*StreamEventsRequest)(nil),     // 15: forum.v1.StreamEventsRequest
+	(*Event)(nil),                   // 16: forum.v1.Event
+	(*timestamppb.Timestamp)(nil),   // 17: google.protobuf.Timestamp
+}
+var file_forumpb_forum_proto_depIdxs = []int32{
+	17, // 0: forum.v1.Thread.created_at:type_name -> google.protobuf.Timestamp
+	17, // 1: forum.v1.Thread.updated_at:type_name -> google.protobuf.Timestamp
+	1,  // 2: forum.v1.Thread.replies:type_name -> forum.v1.Reply
+	2,  // 3: forum.v1.Thread.statuses:type_name -> forum.v1.StatusTag
+	17, // 4: forum.v1.Reply.created_at:type_name -> google.protobuf.Timestamp
+	17, // 5: forum.v1.Reply.updated_at:type_name -> google.protobuf.Timestamp
+	2,  // 6: forum.v1.Reply.statuses:type_name -> forum.v1.StatusTag
+	17, // 7: forum.v1.StatusTag.created_at:type_name -> google.protobuf.Timestamp
+	0,  // 8: forum.v1.ListThreadsResponse.threads:type_name -> forum.v1.Thread
+	2,  // 9: forum.v1.ListStatusesResponse.statuses:type_name -> forum.v1.StatusTag
+	13, // 10: forum.v1.GetDependenciesResponse.dependencies:type_name -> forum.v1.Dependency
+	14, // 11: forum.v1.Dependency.source:type_name -> forum.v1.DependencyNode
+	14, // 12: forum.v1.Dependency.depends_on:type_name -> forum.v1.DependencyNode
+	17, // 13: forum.v1.Event.created_at:type_name -> google.protobuf.Timestamp
+	0,  // 14: forum.v1.Event.thread:type_name -> forum.v1.Thread
+	1,  // 15: forum.v1.Event.reply:type_name -> forum.v1.Reply
+	2,  // 16: forum.v1.Event.status:type_name -> forum.v1.StatusTag
+	3,  // 17: forum.v1.Forum.CreateThread:input_type -> forum.v1.CreateThreadRequest
+	4,  // 18: forum.v1.Forum.ListThreads:input_type -> forum.v1.ListThreadsRequest
+	6,  // 19: forum.v1.Forum.GetThread:input_type -> forum.v1.GetThreadRequest
+	7,  // 20: forum.v1.Forum.CreateReply:input_type -> forum.v1.CreateReplyRequest
+	8,  // 21: forum.v1.Forum.CreateStatus:input_type -> forum.v1.CreateStatusRequest
+	9,  // 22: forum.v1.Forum.ListStatuses:input_type -> forum.v1.ListStatusesRequest
+	11, // 23: forum.v1.Forum.GetDependencies:input_type -> forum.v1.GetDependenciesRequest
+	15, // 24: forum.v1.Forum.StreamEvents:input_type -> forum.v1.StreamEventsRequest
+	0,  // 25: forum.v1.Forum.CreateThread:output_type -> forum.v1.Thread
+	5,  // 26: forum.v1.Forum.ListThreads:output_type -> forum.v1.ListThreadsResponse
+	0,  // 27: forum.v1.Forum.GetThread:output_type -> forum.v1.Thread
+	1,  // 28: forum.v1.Forum.CreateReply:output_type -> forum.v1.Reply
+	2,  // 29: forum.v1.Forum.CreateStatus:output_type -> forum.v1.StatusTag
+	10, // 30: forum.v1.Forum.ListStatuses:output_type -> forum.v1.ListStatusesResponse
+	12, // 31: forum.v1.Forum.GetDependencies:output_type -> forum.v1.GetDependenciesResponse
+	16, // 32: forum.v1.Forum.StreamEvents:output_type -> forum.v1.Event
+	25, // [25:33] is the sub-list for method output_type
+	17, // [17:25] is the sub-list for method input_type
+	17, // [17:17] is the sub-list for extension type_name
+	17, // [17:17] is the sub-list for extension extendee
+	0,  // [0:17] is the sub-list for field type_name
+}
+
+func init() { file_forumpb_forum_proto_init() }
+func file_forumpb_forum_proto_init() {
+	if File_forumpb_forum_proto != nil {
+		return
+	}
+	file_forumpb_forum_proto_msgTypes[4].OneofWrappers = []any{}
+	file_forumpb_forum_proto_msgTypes[8].OneofWrappers = []any{
+		(*CreateStatusRequest_ThreadId)(nil),
+		(*CreateStatusRequest_ReplyId)(nil),
+	}
+	file_forumpb_forum_proto_msgTypes[16].OneofWrappers = []any{
+		(*Event_Thread)(nil),
+		(*Event_Reply)(nil),
+		(*Event_Status)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_forumpb_forum_proto_rawDesc), len(file_forumpb_forum_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_forumpb_forum_proto_goTypes,
+		DependencyIndexes: file_forumpb_forum_proto_depIdxs,
+		MessageInfos:      file_forumpb_forum_proto_msgTypes,
+	}.Build()
+	File_forumpb_forum_proto = out.File
+	file_forumpb_forum_proto_goTypes = nil
+	file_forumpb_forum_proto_depIdxs = nil
+}
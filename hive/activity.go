@@ -0,0 +1,147 @@
+package hive
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// eventAnnouncementCreated is the activity kind for announcements, which
+// aren't on the event stream.
+const eventAnnouncementCreated = "announcement.created"
+
+// activitySources select each kind of activity with the columns scanned by
+// handleListActivity. Each names its columns, since any may come first in
+// the union.
+var activitySources = []struct {
+	kind  string
+	query string
+}{
+	{eventThreadCreated, `SELECT '` + eventThreadCreated + `' AS kind, t.id AS id, t.id AS thread_id, NULL AS reply_id, t.title AS title,
+		t.agent_id AS agent_id, a.name AS agent_name, NULL AS tag, t.body AS body, t.created_at AS created_at, t.workspace_id AS workspace_id
+		FROM threads t JOIN agents a ON t.agent_id = a.id
+		WHERE ` + publishedCondition},
+	{eventReplyCreated, `SELECT '` + eventReplyCreated + `' AS kind, r.id AS id, r.thread_id AS thread_id, r.id AS reply_id, t.title AS title,
+		r.agent_id AS agent_id, a.name AS agent_name, NULL AS tag, r.body AS body, r.created_at AS created_at, t.workspace_id AS workspace_id
+		FROM replies r JOIN threads t ON r.thread_id = t.id JOIN agents a ON r.agent_id = a.id`},
+	{eventStatusCreated, `SELECT '` + eventStatusCreated + `' AS kind, s.id AS id, t.id AS thread_id, s.reply_id AS reply_id, t.title AS title,
+		s.agent_id AS agent_id, a.name AS agent_name, s.tag AS tag, '' AS body, s.created_at AS created_at, t.workspace_id AS workspace_id
+		FROM status_tags s
+		LEFT JOIN replies r ON s.reply_id = r.id
+		JOIN threads t ON t.id = COALESCE(s.thread_id, r.thread_id)
+		JOIN agents a ON s.agent_id = a.id`},
+	{eventAnnouncementCreated, `SELECT '` + eventAnnouncementCreated + `' AS kind, an.id AS id, NULL AS thread_id, NULL AS reply_id, an.title AS title,
+		an.agent_id AS agent_id, ag.name AS agent_name, NULL AS tag, an.body AS body, an.created_at AS created_at, an.workspace_id AS workspace_id
+		FROM announcements an LEFT JOIN agents ag ON an.agent_id = ag.id
+		WHERE an.active = 1`},
+}
+
+// handleListActivity lists recent threads, replies, status tags, and
+// announcements as one feed, newest first. ?kinds= (comma-separated) limits
+// it to some kinds and ?since= to activity after a time.
+func handleListActivity(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	// Parse pagination
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 {
+		perPage = 20
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	offset := (page - 1) * perPage
+
+	kinds := map[string]bool{}
+	if v := r.URL.Query().Get("kinds"); v != "" {
+		for _, kind := range strings.Split(v, ",") {
+			kinds[strings.TrimSpace(kind)] = true
+		}
+	}
+	var sources []string
+	for _, s := range activitySources {
+		if len(kinds) == 0 || kinds[s.kind] {
+			sources = append(sources, s.query)
+			delete(kinds, s.kind)
+		}
+	}
+	for kind := range kinds {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown kind %q (use thread.created, reply.created, status.created, or announcement.created)", kind)})
+		return
+	}
+
+	// Announcements have no thread and must be for the agent's workspace;
+	// everything else must be in a thread the agent can read
+	visible, visibleArgs := visibleThreadCondition(agent, "activity.thread_id")
+	conditions := []string{"((activity.thread_id IS NULL AND activity.workspace_id IN ('', ?)) OR " + visible + ")"}
+	args := append([]interface{}{agent.WorkspaceID}, visibleArgs...)
+	if since := r.URL.Query().Get("since"); since != "" {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be an RFC 3339 timestamp"})
+			return
+		}
+		conditions = append(conditions, "created_at > ?")
+		args = append(args, sinceTime)
+	}
+	whereClause := "WHERE " + strings.Join(conditions, " AND ")
+	from := "(" + strings.Join(sources, "\n\t\tUNION ALL\n\t\t") + ") activity"
+
+	var totalCount int
+	if err := db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM "+from+" "+whereClause, args...).Scan(&totalCount); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to count activity"})
+		return
+	}
+
+	args = append(args, perPage, offset)
+	rows, err := db.QueryContext(r.Context(),
+		fmt.Sprintf(
+			`SELECT kind, id, thread_id, reply_id, title, agent_id, agent_name, tag, body, created_at
+			FROM %s
+			%s
+			ORDER BY created_at DESC, id
+			LIMIT ? OFFSET ?`, from, whereClause,
+		), args...,
+	)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query activity"})
+		return
+	}
+	defer rows.Close()
+
+	items := []Activity{}
+	for rows.Next() {
+		var a Activity
+		var agentName, tag sql.NullString
+		var body string
+		if err := rows.Scan(&a.Kind, &a.ID, &a.ThreadID, &a.ReplyID, &a.Title, &a.AgentID, &agentName, &tag, &body, &a.CreatedAt); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to scan activity"})
+			return
+		}
+		a.AgentName = agentName.String
+		a.Tag = tag.String
+		a.Preview = truncate(body, 100)
+		items = append(items, a)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to iterate activity"})
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(totalCount))
+	w.Header().Set("X-Page", strconv.Itoa(page))
+	w.Header().Set("X-Per-Page", strconv.Itoa(perPage))
+
+	writeJSON(w, http.StatusOK, items)
+}
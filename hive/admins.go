@@ -0,0 +1,63 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// createAdmin inserts an admin account with a bcrypt-hashed password.
+func createAdmin(ctx context.Context, db *sql.DB, username, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO admins (id, username, password_hash, created_at) VALUES (?, ?, ?, ?)`,
+		uuid.New().String(), username, string(hash), time.Now(),
+	)
+	if err != nil {
+		return fmt.Errorf("insert admin: %w", err)
+	}
+	return nil
+}
+
+// bootstrapAdmin creates the first admin account from ADMIN_USER and
+// ADMIN_PASS when the admins table is empty. Once any admin exists the
+// environment credentials are ignored; manage accounts from the admin panel.
+func bootstrapAdmin(db *sql.DB, cfg Config) error {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM admins").Scan(&count); err != nil {
+		return fmt.Errorf("count admins: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	if err := createAdmin(context.Background(), db, cfg.AdminUser, cfg.AdminPass); err != nil {
+		return err
+	}
+	log.Printf("created initial admin account %q from ADMIN_USER/ADMIN_PASS", cfg.AdminUser)
+	if cfg.AdminPass == "changeme" {
+		log.Printf("WARNING: the initial admin password is the default; change it in the admin panel")
+	}
+	return nil
+}
+
+// resetAdminTOTP removes an admin's TOTP secret and recovery codes.
+func resetAdminTOTP(ctx context.Context, db *sql.DB, adminID string) error {
+	if _, err := db.ExecContext(ctx,
+		"UPDATE admins SET totp_secret = '', totp_enabled = 0, totp_last_counter = 0 WHERE id = ?", adminID,
+	); err != nil {
+		return fmt.Errorf("clear totp: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, "DELETE FROM admin_recovery_codes WHERE admin_id = ?", adminID); err != nil {
+		return fmt.Errorf("delete recovery codes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// createAgent registers an agent and returns it with its raw API key, which
+// is shown to the caller once and never stored. An empty role means worker,
+// and an empty workspace, given by ID or name, the default workspace.
+func createAgent(ctx context.Context, db *sql.DB, name, owner, workspace string, scopes []string, role string, expiresAt *time.Time) (Agent, string, error) {
+	if name == "" || owner == "" {
+		return Agent{}, "", inputError("name and owner are required")
+	}
+	if len(scopes) == 0 {
+		return Agent{}, "", inputError("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !validScopes[scope] {
+			return Agent{}, "", inputError(fmt.Sprintf("invalid scope %q", scope))
+		}
+	}
+	if role == "" {
+		role = roleWorker
+	}
+	if !validRoles[role] {
+		return Agent{}, "", inputError("invalid role")
+	}
+	workspaceID := defaultWorkspaceID
+	if workspace != "" {
+		id, err := resolveWorkspace(context.Background(), db, workspace)
+		if _, ok := err.(notFoundError); ok {
+			return Agent{}, "", inputError(err.Error())
+		}
+		if err != nil {
+			return Agent{}, "", err
+		}
+		workspaceID = id
+	}
+
+	var taken bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM agents WHERE name = ?)", name).Scan(&taken); err != nil {
+		return Agent{}, "", fmt.Errorf("check agent name: %w", err)
+	}
+	if taken {
+		return Agent{}, "", inputError("an agent with that name already exists")
+	}
+
+	scopesJSON, err := json.Marshal(scopes)
+	if err != nil {
+		return Agent{}, "", fmt.Errorf("marshal scopes: %w", err)
+	}
+	keyID, rawAPIKey, hash, err := generateAPIKey()
+	if err != nil {
+		return Agent{}, "", err
+	}
+
+	now := time.Now()
+	agent := Agent{
+		ID:           uuid.New().String(),
+		Name:         name,
+		Owner:        owner,
+		WorkspaceID:  workspaceID,
+		Scopes:       scopes,
+		Role:         role,
+		KeyExpiresAt: expiresAt,
+		CreatedAt:    now,
+		LastSeenAt:   now,
+		Kind:         agentKindAgent,
+	}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO agents (id, name, owner, workspace_id, key_id, api_key_hash, scopes, role, key_expires_at, created_at, last_seen_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		agent.ID, name, owner, workspaceID, keyID, hash, string(scopesJSON), role, expiresAt, now, now,
+	)
+	if err != nil {
+		return Agent{}, "", fmt.Errorf("insert agent: %w", err)
+	}
+	return agent, rawAPIKey, nil
+}
+
+// revokeAgent disables an agent's current and previous API keys. The agent
+// record is kept for thread history. Human agents have no keys to revoke.
+func revokeAgent(ctx context.Context, db *sql.DB, agentID string) error {
+	res, err := db.ExecContext(ctx, "UPDATE agents SET api_key_hash = '', previous_key_hash = '' WHERE id = ? AND kind = ?", agentID, agentKindAgent)
+	if err != nil {
+		return fmt.Errorf("revoke agent: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return notFoundError("agent not found")
+	}
+	return nil
+}
+
+// agentColumns is the select list scanned by scanAgent.
+const agentColumns = `id, name, owner, workspace_id, scopes, role, key_rotated_at, key_expires_at, created_at, last_seen_at, heartbeat_at, status_text,
+		capabilities, model, toolset, description, api_key_hash = '', kind`
+
+// scanAgent scans a row selected with agentColumns.
+func scanAgent(row rowScanner) (Agent, error) {
+	var a Agent
+	var scopesStr, capabilitiesStr, toolsetStr string
+	if err := row.Scan(&a.ID, &a.Name, &a.Owner, &a.WorkspaceID, &scopesStr, &a.Role, &a.KeyRotatedAt, &a.KeyExpiresAt, &a.CreatedAt, &a.LastSeenAt, &a.HeartbeatAt, &a.StatusText,
+		&capabilitiesStr, &a.Model, &toolsetStr, &a.Description, &a.Revoked, &a.Kind); err != nil {
+		return Agent{}, err
+	}
+	// Humans have no API key to revoke
+	a.Revoked = a.Revoked && a.Kind != agentKindHuman
+	a.Presence = a.PresenceAt(time.Now())
+	if err := json.Unmarshal([]byte(scopesStr), &a.Scopes); err != nil {
+		a.Scopes = []string{}
+	}
+	if err := json.Unmarshal([]byte(capabilitiesStr), &a.Capabilities); err != nil || a.Capabilities == nil {
+		a.Capabilities = []string{}
+	}
+	if err := json.Unmarshal([]byte(toolsetStr), &a.Toolset); err != nil || a.Toolset == nil {
+		a.Toolset = []string{}
+	}
+	return a, nil
+}
+
+// listAgents returns every agent, newest first, or only those listing
+// capability if it isn't empty. A non-empty workspaceID keeps only the
+// agents of that workspace.
+func listAgents(ctx context.Context, db *sql.DB, capability, workspaceID string) ([]Agent, error) {
+	var conditions []string
+	var args []interface{}
+	if capability != "" {
+		conditions = append(conditions, capabilityCondition)
+		args = append(args, capability)
+	}
+	if workspaceID != "" {
+		conditions = append(conditions, "workspace_id = ?")
+		args = append(args, workspaceID)
+	}
+	query := "SELECT " + agentColumns + " FROM agents"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	rows, err := db.QueryContext(ctx, query+" ORDER BY created_at DESC", args...)
+	if err != nil {
+		return nil, fmt.Errorf("query agents: %w", err)
+	}
+	defer rows.Close()
+
+	agents := []Agent{}
+	for rows.Next() {
+		a, err := scanAgent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan agent: %w", err)
+		}
+		agents = append(agents, a)
+	}
+	return agents, rows.Err()
+}
+
+// handleListAgents lists the agents of the requesting agent's workspace, or
+// those with the capability in ?capability=. Requires the admin scope or a
+// coordinator or moderator role; without the admin scope, revoked agents and
+// key details are left out. With the admin scope it lists every workspace,
+// or the one in ?workspace=.
+func handleListAgents(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	admin := agent.HasScope(scopeAdmin)
+	if !admin && !requirePermission(w, agent, permListAgents) {
+		return
+	}
+
+	workspaceID := agent.WorkspaceID
+	if admin {
+		workspaceID = ""
+		if ref := r.URL.Query().Get("workspace"); ref != "" {
+			id, err := resolveWorkspace(r.Context(), db, ref)
+			if err != nil {
+				writeStoreError(w, err, "failed to query workspace")
+				return
+			}
+			workspaceID = id
+		}
+	}
+
+	agents, err := listAgents(r.Context(), db, r.URL.Query().Get("capability"), workspaceID)
+	if err != nil {
+		writeStoreError(w, err, "failed to query agents")
+		return
+	}
+	if !admin {
+		active := []Agent{}
+		for _, a := range agents {
+			if !a.Revoked {
+				a.hideKeyDetails()
+				active = append(active, a)
+			}
+		}
+		agents = active
+	}
+	writeJSON(w, http.StatusOK, agents)
+}
+
+// handleCreateAgent registers an agent and returns its API key. The agent
+// joins the workspace in "workspace", or the requesting agent's. Requires the
+// admin scope.
+func handleCreateAgent(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requireScope(w, agent, scopeAdmin) {
+		return
+	}
+
+	var input struct {
+		Name      string   `json:"name"`
+		Owner     string   `json:"owner"`
+		Workspace string   `json:"workspace"`
+		Scopes    []string `json:"scopes"`
+		Role      string   `json:"role"`
+		ExpiresAt string   `json:"expires_at"`
+	}
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+	if input.Scopes == nil {
+		input.Scopes = []string{scopeRead, scopeWrite}
+	}
+	var expiresAt *time.Time
+	if input.ExpiresAt != "" {
+		t, err := time.Parse("2006-01-02", input.ExpiresAt)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expires_at must be a date (YYYY-MM-DD)"})
+			return
+		}
+		expiresAt = &t
+	}
+
+	if input.Workspace == "" {
+		input.Workspace = agent.WorkspaceID
+	}
+
+	created, apiKey, err := createAgent(r.Context(), db, input.Name, input.Owner, input.Workspace, input.Scopes, input.Role, expiresAt)
+	if err != nil {
+		writeStoreError(w, err, "failed to create agent")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"agent":   created,
+		"api_key": apiKey,
+	})
+}
+
+// handleRevokeAgent revokes another agent's API keys. Requires the admin
+// scope.
+func handleRevokeAgent(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requireScope(w, agent, scopeAdmin) {
+		return
+	}
+
+	agentID := r.PathValue("id")
+	if agentID == agent.ID {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "you can't revoke your own key"})
+		return
+	}
+
+	if err := revokeAgent(r.Context(), db, agentID); err != nil {
+		writeStoreError(w, err, "failed to revoke agent")
+		return
+	}
+	log.Printf("agent %s revoked by %s", agentID, agent.Name)
+
+	w.WriteHeader(http.StatusNoContent)
+}
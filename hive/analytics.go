@@ -0,0 +1,263 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The admin analytics page charts forum activity over a window of recent
+// days: threads and replies per day, the most active agents and tags, where
+// the window's threads stand, and how long they took to resolve. It is
+// computed with aggregate queries on each load.
+
+// analyticsWindows are the windows, in days, the analytics page offers.
+var analyticsWindows = []int{7, 30, 90}
+
+// defaultAnalyticsWindow is the window shown unless ?days= picks another.
+const defaultAnalyticsWindow = 30
+
+// analyticsTopN caps the agents and tags charted.
+const analyticsTopN = 15
+
+// analyticsDay is one day of the activity chart. The percentages scale the
+// bars to the busiest day.
+type analyticsDay struct {
+	Day        string
+	Threads    int
+	Replies    int
+	ThreadsPct int
+	RepliesPct int
+}
+
+// analyticsCount is one bar of a ranked chart, with its length as a
+// percentage of the longest.
+type analyticsCount struct {
+	Name  string
+	Count int
+	Pct   int
+}
+
+// analyticsReport is everything the analytics page charts.
+type analyticsReport struct {
+	Days     []analyticsDay
+	Agents   []analyticsCount
+	Tags     []analyticsCount
+	Statuses []analyticsCount
+	// Resolved counts the window's threads that have been resolved, and
+	// AvgToResolved is how long after creation they were first resolved, on
+	// average.
+	Resolved      int
+	AvgToResolved time.Duration
+}
+
+// buildAnalytics computes the report for the days up to and including
+// today, in the workspace with ID workspaceID or in all of them if it's
+// empty.
+func buildAnalytics(ctx context.Context, db *sql.DB, days int, workspaceID string) (analyticsReport, error) {
+	var report analyticsReport
+	// Days are in server time, the zone timestamps are written in
+	y, m, d := time.Now().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	since := today.AddDate(0, 0, 1-days)
+
+	// Threads and replies per day, keyed by the date their timestamps start
+	// with
+	perDay := make(map[string]*analyticsDay, days)
+	for d := since; !d.After(today); d = d.AddDate(0, 0, 1) {
+		report.Days = append(report.Days, analyticsDay{Day: d.Format("2006-01-02")})
+	}
+	for i := range report.Days {
+		perDay[report.Days[i].Day] = &report.Days[i]
+	}
+	for _, q := range []struct {
+		query string
+		count func(*analyticsDay) *int
+	}{
+		{`SELECT substr(t.created_at, 1, 10), COUNT(*) FROM threads t
+			WHERE t.created_at >= ? AND (? = '' OR t.workspace_id = ?)
+			GROUP BY 1`, func(d *analyticsDay) *int { return &d.Threads }},
+		{`SELECT substr(r.created_at, 1, 10), COUNT(*) FROM replies r JOIN threads t ON r.thread_id = t.id
+			WHERE r.created_at >= ? AND (? = '' OR t.workspace_id = ?)
+			GROUP BY 1`, func(d *analyticsDay) *int { return &d.Replies }},
+	} {
+		counts, err := queryAnalyticsCounts(ctx, db, q.query, since, workspaceID, workspaceID)
+		if err != nil {
+			return report, err
+		}
+		for _, c := range counts {
+			if d, ok := perDay[c.Name]; ok {
+				*q.count(d) = c.Count
+			}
+		}
+	}
+	busiest := 0
+	for _, d := range report.Days {
+		busiest = max(busiest, d.Threads, d.Replies)
+	}
+	for i := range report.Days {
+		report.Days[i].ThreadsPct = percentOf(report.Days[i].Threads, busiest)
+		report.Days[i].RepliesPct = percentOf(report.Days[i].Replies, busiest)
+	}
+
+	var err error
+	report.Agents, err = queryAnalyticsCounts(ctx, db,
+		`SELECT a.name, (SELECT COUNT(*) FROM threads t WHERE t.agent_id = a.id AND t.created_at >= ?)
+				+ (SELECT COUNT(*) FROM replies r WHERE r.agent_id = a.id AND r.created_at >= ?) AS n
+		FROM agents a
+		WHERE ? = '' OR a.workspace_id = ?
+		ORDER BY n DESC, a.name
+		LIMIT ?`, since, since, workspaceID, workspaceID, analyticsTopN)
+	if err != nil {
+		return report, err
+	}
+
+	report.Tags, err = queryAnalyticsCounts(ctx, db,
+		`SELECT tt.tag, COUNT(*) AS n FROM thread_tags tt JOIN threads t ON t.id = tt.thread_id
+		WHERE t.created_at >= ? AND (? = '' OR t.workspace_id = ?)
+		GROUP BY tt.tag
+		ORDER BY n DESC, tt.tag
+		LIMIT ?`, since, workspaceID, workspaceID, analyticsTopN)
+	if err != nil {
+		return report, err
+	}
+
+	report.Statuses, err = queryAnalyticsCounts(ctx, db,
+		`SELECT current_status, COUNT(*) AS n FROM (
+			SELECT `+currentStatusColumn+` FROM threads t
+			WHERE t.created_at >= ? AND (? = '' OR t.workspace_id = ?) AND `+unmergedCondition+`
+		)
+		GROUP BY current_status
+		ORDER BY n DESC, current_status`, since, workspaceID, workspaceID)
+	if err != nil {
+		return report, err
+	}
+
+	// Time to resolved, from each thread's first resolved tag
+	rows, err := db.QueryContext(ctx,
+		`SELECT t.id, t.created_at, s.created_at FROM status_tags s JOIN threads t ON s.thread_id = t.id
+		WHERE s.tag = 'resolved' AND t.created_at >= ? AND (? = '' OR t.workspace_id = ?)
+		ORDER BY s.created_at`, since, workspaceID, workspaceID)
+	if err != nil {
+		return report, fmt.Errorf("query resolved threads: %w", err)
+	}
+	defer rows.Close()
+	seen := make(map[string]bool)
+	var total time.Duration
+	for rows.Next() {
+		var id string
+		var created, resolved time.Time
+		if err := rows.Scan(&id, &created, &resolved); err != nil {
+			return report, fmt.Errorf("scan resolved thread: %w", err)
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		total += resolved.Sub(created)
+	}
+	if err := rows.Err(); err != nil {
+		return report, fmt.Errorf("iterate resolved threads: %w", err)
+	}
+	if report.Resolved = len(seen); report.Resolved > 0 {
+		report.AvgToResolved = total / time.Duration(report.Resolved)
+	}
+	return report, nil
+}
+
+// queryAnalyticsCounts runs a query selecting names and counts, largest
+// first, and scales them into bars. Names with a count of zero are left out.
+func queryAnalyticsCounts(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]analyticsCount, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query analytics: %w", err)
+	}
+	defer rows.Close()
+
+	var counts []analyticsCount
+	for rows.Next() {
+		var c analyticsCount
+		if err := rows.Scan(&c.Name, &c.Count); err != nil {
+			return nil, fmt.Errorf("scan analytics: %w", err)
+		}
+		if c.Count > 0 {
+			counts = append(counts, c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate analytics: %w", err)
+	}
+	longest := 0
+	for _, c := range counts {
+		longest = max(longest, c.Count)
+	}
+	for i := range counts {
+		counts[i].Pct = percentOf(counts[i].Count, longest)
+	}
+	return counts, nil
+}
+
+// percentOf returns n as a whole percentage of total, or 0 if total is 0.
+func percentOf(n, total int) int {
+	if total == 0 {
+		return 0
+	}
+	return n * 100 / total
+}
+
+// formatDuration renders a duration to the nearest minute, largest units
+// first, as in "2d 3h 15m".
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	days, hours, minutes := int(d.Hours())/24, int(d.Hours())%24, int(d.Minutes())%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh %dm", days, hours, minutes)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
+
+// handleAdminAnalytics renders activity charts for the last ?days= days
+// (7, 30, or 90), in all workspaces or the one in ?workspace=.
+func handleAdminAnalytics(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	days := defaultAnalyticsWindow
+	if n, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil {
+		for _, window := range analyticsWindows {
+			if n == window {
+				days = n
+			}
+		}
+	}
+
+	workspaceID, workspaces, err := adminWorkspaceFilter(db, r)
+	if err != nil {
+		log.Printf("admin analytics workspaces query error: %v", err)
+		http.Error(w, "failed to load analytics", http.StatusInternalServerError)
+		return
+	}
+
+	report, err := buildAnalytics(r.Context(), db, days, workspaceID)
+	if err != nil {
+		log.Printf("admin analytics error: %v", err)
+		http.Error(w, "failed to load analytics", http.StatusInternalServerError)
+		return
+	}
+
+	renderAdminTemplate(w, r, "analytics.html", map[string]interface{}{
+		"Report":     report,
+		"AvgResolve": formatDuration(report.AvgToResolved),
+		"From":       report.Days[0].Day,
+		"To":         report.Days[len(report.Days)-1].Day,
+		"Days":       days,
+		"Windows":    analyticsWindows,
+		"Workspace":  workspaceID,
+		"Workspaces": workspaces,
+	})
+}
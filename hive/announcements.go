@@ -0,0 +1,316 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Announcements are standing guidance for every agent in a workspace, or in
+// all of them. Admins post them from the admin panel; agents with the admin
+// scope or a coordinator or moderator role post them through the API, so
+// orchestrators can broadcast without a human in the loop.
+//
+// An announcement can target teams (agent owners) and capabilities, reaching
+// only agents in one of the teams or with one of the capabilities, and can
+// expire. Expired announcements stop showing at once and are deactivated by
+// a background sweep.
+
+// announcementExpiryInterval is how often expired announcements are
+// deactivated.
+const announcementExpiryInterval = time.Minute
+
+// announcementColumns is the select list scanned by scanAnnouncement. Queries
+// using it must select from announcementJoins.
+const announcementColumns = `an.id, an.title, an.body, an.active, an.workspace_id, an.target_teams, an.target_capabilities,
+	an.expires_at, COALESCE(an.agent_id, ''), COALESCE(ag.name, ''), an.created_at`
+
+const announcementJoins = `FROM announcements an LEFT JOIN agents ag ON an.agent_id = ag.id`
+
+// announcementTargetCondition matches announcements, aliased an, that are
+// live and reach the agent whose ID is bound to it twice, after the current
+// time.
+const announcementTargetCondition = `an.active = 1 AND (an.expires_at IS NULL OR an.expires_at > ?)
+	AND ((an.target_teams = '[]' AND an.target_capabilities = '[]')
+		OR EXISTS (SELECT 1 FROM json_each(an.target_teams) tt JOIN agents me ON me.owner = tt.value WHERE me.id = ?)
+		OR EXISTS (SELECT 1 FROM json_each(an.target_capabilities) tc, agents me, json_each(me.capabilities) mc
+			WHERE me.id = ? AND mc.value = tc.value))`
+
+// scanAnnouncement scans a row selected with announcementColumns.
+func scanAnnouncement(row rowScanner) (Announcement, error) {
+	var a Announcement
+	var active int
+	var teamsJSON, capabilitiesJSON string
+	err := row.Scan(&a.ID, &a.Title, &a.Body, &active, &a.WorkspaceID, &teamsJSON, &capabilitiesJSON, &a.ExpiresAt, &a.AgentID, &a.AgentName, &a.CreatedAt)
+	if err != nil {
+		return a, err
+	}
+	a.Active = active != 0
+	if err := json.Unmarshal([]byte(teamsJSON), &a.Teams); err != nil {
+		return a, fmt.Errorf("decode target teams: %w", err)
+	}
+	if err := json.Unmarshal([]byte(capabilitiesJSON), &a.Capabilities); err != nil {
+		return a, fmt.Errorf("decode target capabilities: %w", err)
+	}
+	return a, nil
+}
+
+// createAnnouncement posts an active announcement to the workspace, or to
+// all workspaces if workspaceID is empty. Empty teams and capabilities reach
+// every agent there; a nil expiresAt never expires. poster is the posting
+// agent, or nil for an admin.
+func createAnnouncement(ctx context.Context, db dbtx, title, body, workspaceID string, teams, capabilities []string, expiresAt *time.Time, poster *Agent) (Announcement, error) {
+	if strings.TrimSpace(title) == "" || strings.TrimSpace(body) == "" {
+		return Announcement{}, inputError("title and body are required")
+	}
+	now := time.Now()
+	if expiresAt != nil && !expiresAt.After(now) {
+		return Announcement{}, inputError("expires_at must be in the future")
+	}
+	teams, err := normalizeProfileList("teams", teams)
+	if err != nil {
+		return Announcement{}, err
+	}
+	capabilities, err = normalizeProfileList("capabilities", capabilities)
+	if err != nil {
+		return Announcement{}, err
+	}
+	teamsJSON, err := json.Marshal(teams)
+	if err != nil {
+		return Announcement{}, fmt.Errorf("marshal teams: %w", err)
+	}
+	capabilitiesJSON, err := json.Marshal(capabilities)
+	if err != nil {
+		return Announcement{}, fmt.Errorf("marshal capabilities: %w", err)
+	}
+
+	a := Announcement{
+		ID:           uuid.New().String(),
+		Title:        title,
+		Body:         body,
+		Active:       true,
+		WorkspaceID:  workspaceID,
+		Teams:        teams,
+		Capabilities: capabilities,
+		ExpiresAt:    utcTime(expiresAt),
+		CreatedAt:    now,
+	}
+	var agentID interface{}
+	if poster != nil {
+		a.AgentID, a.AgentName = poster.ID, poster.Name
+		agentID = poster.ID
+	}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO announcements (id, title, body, active, workspace_id, target_teams, target_capabilities, expires_at, agent_id, created_at)
+		VALUES (?, ?, ?, 1, ?, ?, ?, ?, ?, ?)`,
+		a.ID, a.Title, a.Body, a.WorkspaceID, string(teamsJSON), string(capabilitiesJSON), a.ExpiresAt, agentID, a.CreatedAt,
+	)
+	if err != nil {
+		return Announcement{}, fmt.Errorf("insert announcement: %w", err)
+	}
+	return a, nil
+}
+
+// listActiveAnnouncements returns the unexpired active announcements that
+// reach the agent, newest first.
+func listActiveAnnouncements(ctx context.Context, db dbtx, agent *Agent) ([]Announcement, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+announcementColumns+` `+announcementJoins+`
+		WHERE an.workspace_id IN ('', ?) AND `+announcementTargetCondition+`
+		ORDER BY an.created_at DESC`,
+		agent.WorkspaceID, time.Now().UTC(), agent.ID, agent.ID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query announcements: %w", err)
+	}
+	defer rows.Close()
+
+	announcements := []Announcement{}
+	for rows.Next() {
+		a, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan announcement: %w", err)
+		}
+		announcements = append(announcements, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate announcements: %w", err)
+	}
+	return announcements, nil
+}
+
+// listAnnouncements returns every announcement, active or not, newest
+// first.
+func listAnnouncements(ctx context.Context, db dbtx) ([]Announcement, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+announcementColumns+` `+announcementJoins+` ORDER BY an.created_at DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query announcements: %w", err)
+	}
+	defer rows.Close()
+
+	var announcements []Announcement
+	for rows.Next() {
+		a, err := scanAnnouncement(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan announcement: %w", err)
+		}
+		announcements = append(announcements, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate announcements: %w", err)
+	}
+	return announcements, nil
+}
+
+// createAnnouncementFromForm creates an announcement from the admin panel's
+// or dashboard's form: for the workspace in the form, or for all
+// workspaces, targeted at the comma-separated teams and capabilities and
+// expiring at expires_at (UTC). Mistakes in the form are inputErrors.
+func createAnnouncementFromForm(ctx context.Context, db *sql.DB, r *http.Request, poster *Agent) error {
+	if err := r.ParseForm(); err != nil {
+		return inputError("invalid form data")
+	}
+
+	var workspaceID string
+	if ref := r.FormValue("workspace"); ref != "" {
+		id, err := resolveWorkspace(ctx, db, ref)
+		if _, ok := err.(notFoundError); ok {
+			return inputError(err.Error())
+		}
+		if err != nil {
+			return fmt.Errorf("resolve workspace: %w", err)
+		}
+		workspaceID = id
+	}
+
+	var expiresAt *time.Time
+	if v := r.FormValue("expires_at"); v != "" {
+		t, err := time.Parse("2006-01-02T15:04", v)
+		if err != nil {
+			return inputError("expires_at must be a date and time")
+		}
+		expiresAt = &t
+	}
+
+	teams := strings.Split(r.FormValue("teams"), ",")
+	capabilities := strings.Split(r.FormValue("capabilities"), ",")
+	_, err := createAnnouncement(ctx, db, r.FormValue("title"), r.FormValue("body"), workspaceID, teams, capabilities, expiresAt, poster)
+	return err
+}
+
+// toggleAnnouncement activates or deactivates an announcement. Reactivating
+// an expired announcement clears its expiry.
+func toggleAnnouncement(ctx context.Context, db *sql.DB, id string) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE announcements SET active = NOT active, expires_at = CASE WHEN active = 0 AND expires_at <= ? THEN NULL ELSE expires_at END WHERE id = ?",
+		time.Now().UTC(), id,
+	)
+	return err
+}
+
+// expireAnnouncements deactivates the active announcements that expired by
+// now and returns how many it deactivated.
+func expireAnnouncements(ctx context.Context, db *sql.DB, now time.Time) (int64, error) {
+	res, err := db.ExecContext(ctx, "UPDATE announcements SET active = 0 WHERE active = 1 AND expires_at IS NOT NULL AND expires_at <= ?", now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("expire announcements: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// StartAnnouncementExpiry deactivates expired announcements now and then
+// every interval until ctx is done.
+func StartAnnouncementExpiry(ctx context.Context, db *sql.DB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			n, err := expireAnnouncements(ctx, db, time.Now())
+			if err != nil {
+				log.Printf("announcement expiry: %v", err)
+			}
+			if n > 0 {
+				log.Printf("announcement expiry: deactivated %d announcements", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// handleListAnnouncements lists the active announcements that reach the
+// requesting agent, newest first.
+func handleListAnnouncements(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	announcements, err := announcementsCache.get(db, cfg.CacheTTL, agent.ID, func() (interface{}, error) {
+		return listActiveAnnouncements(r.Context(), db, agent)
+	})
+	if err != nil {
+		writeStoreError(w, err, "failed to query announcements")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, announcements)
+}
+
+// handleCreateAnnouncement posts an announcement to the requesting agent's
+// workspace, optionally targeted at teams and capabilities and expiring at
+// expires_at. Requires the admin scope or a coordinator or moderator role;
+// with the admin scope, all_workspaces posts it to every workspace.
+func handleCreateAnnouncement(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	admin := agent.HasScope(scopeAdmin)
+	if !admin && !requirePermission(w, agent, permAnnounce) {
+		return
+	}
+
+	var input struct {
+		Title         string     `json:"title"`
+		Body          string     `json:"body"`
+		Teams         []string   `json:"teams"`
+		Capabilities  []string   `json:"capabilities"`
+		ExpiresAt     *time.Time `json:"expires_at"`
+		AllWorkspaces bool       `json:"all_workspaces"`
+	}
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	workspaceID := agent.WorkspaceID
+	if input.AllWorkspaces {
+		if !requireScope(w, agent, scopeAdmin) {
+			return
+		}
+		workspaceID = ""
+	}
+
+	a, err := createAnnouncement(r.Context(), db, input.Title, input.Body, workspaceID, input.Teams, input.Capabilities, input.ExpiresAt, agent)
+	if err != nil {
+		writeStoreError(w, err, "failed to create announcement")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, a)
+}
@@ -0,0 +1,267 @@
+package hive
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// apiKeyPrefix starts every API key. Keys have the form
+// "ahv_<key id>_<secret>"; the key id is stored in the clear so
+// authentication can find the agent with one indexed lookup.
+const apiKeyPrefix = "ahv_"
+
+// generateAPIKey returns a new API key, its key id, and the bcrypt hash of
+// its secret.
+func generateAPIKey() (keyID, rawKey, hash string, err error) {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", "", fmt.Errorf("generate key id: %w", err)
+	}
+	keyID = hex.EncodeToString(idBytes)
+
+	// 32 bytes of crypto/rand, hex encoded (64 char string)
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", "", fmt.Errorf("generate api key: %w", err)
+	}
+	secret := hex.EncodeToString(secretBytes)
+	rawKey = apiKeyPrefix + keyID + "_" + secret
+
+	hashBytes, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", "", "", fmt.Errorf("hash api key: %w", err)
+	}
+	return keyID, rawKey, string(hashBytes), nil
+}
+
+// splitAPIKey splits an API key into its key id and secret. ok is false for
+// malformed keys, and for keys issued before key ids existed, which have no
+// prefix: finding their agent would take a bcrypt compare against every
+// legacy key, so they are refused until an admin re-issues them (see
+// reissueLegacyKeys).
+func splitAPIKey(rawKey string) (keyID, secret string, ok bool) {
+	rest, found := strings.CutPrefix(rawKey, apiKeyPrefix)
+	if !found {
+		return "", "", false
+	}
+	keyID, secret, found = strings.Cut(rest, "_")
+	if !found || len(keyID) != 16 || secret == "" {
+		return "", "", false
+	}
+	return keyID, secret, true
+}
+
+// authenticateAPIKey returns the agent that owns rawKey, or nil if no agent
+// does. The current key and a rotated-out key still inside its grace window
+// are both accepted.
+func authenticateAPIKey(ctx context.Context, db *sql.DB, rawKey string, now time.Time) (*Agent, error) {
+	keyID, secret, ok := splitAPIKey(rawKey)
+	if !ok {
+		return nil, nil
+	}
+
+	// bcrypt dominates request latency, so it gets its own span
+	ctx, span := tracer.Start(ctx, "authenticateAPIKey")
+	defer span.End()
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, name, owner, workspace_id, key_id, api_key_hash, previous_key_id, previous_key_hash, previous_key_expires_at,
+			key_rotated_at, key_expires_at, scopes, role, created_at, last_seen_at
+		FROM agents WHERE key_id = ? OR previous_key_id = ?`,
+		keyID, keyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query agents: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a Agent
+		var currentID, previousID, previousHash, scopesStr string
+		var previousExpiresAt *time.Time
+		if err := rows.Scan(&a.ID, &a.Name, &a.Owner, &a.WorkspaceID, &currentID, &a.APIKeyHash, &previousID, &previousHash, &previousExpiresAt,
+			&a.KeyRotatedAt, &a.KeyExpiresAt, &scopesStr, &a.Role, &a.CreatedAt, &a.LastSeenAt); err != nil {
+			return nil, fmt.Errorf("scan agent: %w", err)
+		}
+
+		matched := currentID == keyID && bcrypt.CompareHashAndPassword([]byte(a.APIKeyHash), []byte(secret)) == nil
+		// A rotated-out key keeps working until its grace window ends
+		if !matched && previousID == keyID && previousHash != "" && previousExpiresAt != nil && now.Before(*previousExpiresAt) {
+			matched = bcrypt.CompareHashAndPassword([]byte(previousHash), []byte(secret)) == nil
+		}
+		if matched {
+			if err := json.Unmarshal([]byte(scopesStr), &a.Scopes); err != nil {
+				a.Scopes = []string{}
+			}
+			return &a, nil
+		}
+	}
+	return nil, rows.Err()
+}
+
+// KeyRotation describes the result of rotating an agent's API key.
+type KeyRotation struct {
+	APIKey               string    `json:"api_key"`
+	KeyRotatedAt         time.Time `json:"key_rotated_at"`
+	PreviousKeyExpiresAt time.Time `json:"previous_key_expires_at"`
+}
+
+// rotateAgentKey issues a new API key for an agent. The current key becomes
+// the previous key and stays valid for the grace window; any older previous
+// key stops working immediately. It returns sql.ErrNoRows if the agent does
+// not exist, its key has been revoked, or it is a human agent.
+func rotateAgentKey(ctx context.Context, db *sql.DB, agentID string, grace time.Duration) (KeyRotation, error) {
+	keyID, rawKey, hash, err := generateAPIKey()
+	if err != nil {
+		return KeyRotation{}, err
+	}
+
+	now := time.Now()
+	rotation := KeyRotation{
+		APIKey:               rawKey,
+		KeyRotatedAt:         now,
+		PreviousKeyExpiresAt: now.Add(grace),
+	}
+
+	res, err := db.ExecContext(ctx,
+		`UPDATE agents
+		SET previous_key_id = key_id, previous_key_hash = api_key_hash, previous_key_expires_at = ?,
+			key_id = ?, api_key_hash = ?, key_rotated_at = ?
+		WHERE id = ? AND api_key_hash != '' AND kind = ?`,
+		rotation.PreviousKeyExpiresAt, keyID, hash, rotation.KeyRotatedAt, agentID, agentKindAgent,
+	)
+	if err != nil {
+		return KeyRotation{}, fmt.Errorf("update agent key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return KeyRotation{}, sql.ErrNoRows
+	}
+	return rotation, nil
+}
+
+// ReissuedKey is a new API key given to an agent in place of its legacy
+// one.
+type ReissuedKey struct {
+	AgentName string
+	APIKey    string
+}
+
+// listLegacyKeyAgents returns the names of the agents still holding a
+// legacy key, which no longer authenticates.
+func listLegacyKeyAgents(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM agents WHERE key_id = '' AND api_key_hash != '' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// reissueLegacyKeys gives every agent still holding a legacy key a new
+// one, as rotateAgentKey does, and returns the new keys. The legacy keys
+// are refused already, so they get no grace window.
+func reissueLegacyKeys(ctx context.Context, db *sql.DB) ([]ReissuedKey, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, name FROM agents WHERE key_id = '' AND api_key_hash != '' ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("query legacy keys: %w", err)
+	}
+	type legacy struct{ id, name string }
+	var agents []legacy
+	for rows.Next() {
+		var a legacy
+		if err := rows.Scan(&a.id, &a.name); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan legacy key: %w", err)
+		}
+		agents = append(agents, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query legacy keys: %w", err)
+	}
+
+	var keys []ReissuedKey
+	for _, a := range agents {
+		rotation, err := rotateAgentKey(ctx, db, a.id, 0)
+		if err == sql.ErrNoRows {
+			continue // revoked since
+		}
+		if err != nil {
+			return keys, err
+		}
+		keys = append(keys, ReissuedKey{AgentName: a.name, APIKey: rotation.APIKey})
+	}
+	return keys, nil
+}
+
+// handleRotateKey issues a new API key for the requesting agent. The key used
+// to make the request keeps working for cfg.KeyRotationGrace.
+func handleRotateKey(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	rotation, err := rotateAgentKey(r.Context(), db, agent.ID, cfg.KeyRotationGrace)
+	if err == sql.ErrNoRows {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "agent not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("rotate key: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to rotate api key"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rotation)
+}
+
+// keyExpiryWarning is how far ahead the admin agents page flags keys that are
+// about to expire.
+const keyExpiryWarning = 7 * 24 * time.Hour
+
+// KeyExpired reports whether the agent's API key has passed its expiry.
+func (a *Agent) KeyExpired(now time.Time) bool {
+	return a.KeyExpiresAt != nil && !now.Before(*a.KeyExpiresAt)
+}
+
+// KeyExpiresSoon reports whether the agent's API key expires within
+// keyExpiryWarning but has not expired yet.
+func (a *Agent) KeyExpiresSoon(now time.Time) bool {
+	return a.KeyExpiresAt != nil && !a.KeyExpired(now) && a.KeyExpiresAt.Sub(now) <= keyExpiryWarning
+}
+
+// parseKeyExpiry parses the optional "expires_at" date field of an agent form.
+// Keys expire at the start of the given day, UTC. An empty value means the
+// key never expires.
+func parseKeyExpiry(r *http.Request) (*time.Time, error) {
+	v := r.FormValue("expires_at")
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return nil, fmt.Errorf("expires_at must be a date (YYYY-MM-DD)")
+	}
+	return &t, nil
+}
@@ -0,0 +1,42 @@
+package hive
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLegacyKeysAreRefusedUntilReissued(t *testing.T) {
+	srv, ts, key := startTestServer(t, nil)
+	db := srv.DB()
+	ctx := context.Background()
+
+	// A key from before key ids: the whole key is the secret
+	legacy := "0123456789abcdef0123456789abcdef"
+	hash, err := bcrypt.GenerateFromPassword([]byte(legacy), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "UPDATE agents SET key_id = '', api_key_hash = ?", string(hash)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{legacy, key} {
+		if status, _ := do(t, ts, k, "GET", "/api/v1/sync", ""); status != http.StatusUnauthorized {
+			t.Errorf("legacy agent with %q: status %d, want 401", k, status)
+		}
+	}
+
+	keys, err := reissueLegacyKeys(ctx, db)
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("reissueLegacyKeys: %v, %v", keys, err)
+	}
+	if status, _ := do(t, ts, keys[0].APIKey, "GET", "/api/v1/sync", ""); status != http.StatusOK {
+		t.Errorf("re-issued key: status %d, want 200", status)
+	}
+	if status, _ := do(t, ts, legacy, "GET", "/api/v1/sync", ""); status != http.StatusUnauthorized {
+		t.Errorf("legacy key after re-issue: status %d, want 401", status)
+	}
+}
@@ -0,0 +1,331 @@
+package hive
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// attachmentColumns is the metadata select list scanned by scanAttachment.
+// Queries using it must alias attachments as att and join agents as a.
+const attachmentColumns = `att.id, att.thread_id, att.reply_id, att.agent_id, a.name, att.filename, att.content_type, att.size, att.sha256, att.created_at`
+
+// scanAttachment scans a row selected with attachmentColumns.
+func scanAttachment(row rowScanner) (Attachment, error) {
+	var att Attachment
+	err := row.Scan(&att.ID, &att.ThreadID, &att.ReplyID, &att.AgentID, &att.AgentName, &att.Filename, &att.ContentType, &att.Size, &att.SHA256, &att.CreatedAt)
+	return att, err
+}
+
+// threadAttachments returns metadata for every attachment on a thread and its
+// replies, oldest first.
+func threadAttachments(ctx context.Context, db *sql.DB, threadID string) ([]Attachment, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT "+attachmentColumns+`
+		FROM attachments att
+		JOIN agents a ON att.agent_id = a.id
+		WHERE att.thread_id = ?
+		ORDER BY att.created_at ASC`, threadID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		att, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, att)
+	}
+	return attachments, rows.Err()
+}
+
+// attachToThread splits attachments between a thread and its replies.
+func attachToThread(t *Thread, attachments []Attachment) {
+	byReply := make(map[string][]Attachment)
+	for _, att := range attachments {
+		if att.ReplyID != nil {
+			byReply[*att.ReplyID] = append(byReply[*att.ReplyID], att)
+		} else {
+			t.Attachments = append(t.Attachments, att)
+		}
+	}
+	for i := range t.Replies {
+		t.Replies[i].Attachments = byReply[t.Replies[i].ID]
+	}
+}
+
+// errAttachmentTooLarge is returned by readUpload when the file exceeds the limit.
+var errAttachmentTooLarge = errors.New("attachment too large")
+
+// readUpload reads the "file" part of a multipart upload, enforcing maxBytes.
+func readUpload(w http.ResponseWriter, r *http.Request, maxBytes int64) (filename, contentType string, data []byte, err error) {
+	// Allow some slack for multipart framing; the file itself is checked below.
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1<<20)
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return "", "", nil, errAttachmentTooLarge
+		}
+		return "", "", nil, fmt.Errorf("parse multipart form: %w", err)
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return "", "", nil, fmt.Errorf("missing file field: %w", err)
+	}
+	defer file.Close()
+
+	data, err = io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return "", "", nil, fmt.Errorf("read upload: %w", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return "", "", nil, errAttachmentTooLarge
+	}
+
+	filename = filepath.Base(header.Filename)
+	if filename == "." || filename == string(filepath.Separator) {
+		filename = "attachment"
+	}
+	contentType = header.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(filename))
+	}
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	return filename, contentType, data, nil
+}
+
+// storeAttachment handles a multipart upload for a thread or reply.
+func storeAttachment(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request, agent *Agent, threadID string, replyID *string) {
+	filename, contentType, data, err := readUpload(w, r, cfg.MaxAttachmentBytes)
+	if err == errAttachmentTooLarge {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{
+			"error": fmt.Sprintf("attachment exceeds %d bytes", cfg.MaxAttachmentBytes),
+		})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "multipart body with a \"file\" field is required"})
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	att := Attachment{
+		ID:          uuid.New().String(),
+		ThreadID:    threadID,
+		ReplyID:     replyID,
+		AgentID:     agent.ID,
+		AgentName:   agent.Name,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		CreatedAt:   time.Now(),
+	}
+
+	_, err = db.ExecContext(r.Context(),
+		`INSERT INTO attachments (id, thread_id, reply_id, agent_id, filename, content_type, size, sha256, data, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		att.ID, att.ThreadID, att.ReplyID, att.AgentID, att.Filename, att.ContentType, att.Size, att.SHA256, data, att.CreatedAt,
+	)
+	if err != nil {
+		log.Printf("store attachment: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to store attachment"})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, att)
+}
+
+// handleUploadThreadAttachment attaches a file to a thread.
+func handleUploadThreadAttachment(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing thread id"})
+		return
+	}
+
+	// Verify the thread exists and the agent can read it
+	if err := requireVisible(r.Context(), db, agent, threadID); err != nil {
+		writeStoreError(w, err, "failed to query thread")
+		return
+	}
+
+	storeAttachment(db, cfg, w, r, agent, threadID, nil)
+}
+
+// handleUploadReplyAttachment attaches a file to a reply.
+func handleUploadReplyAttachment(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	replyID := r.PathValue("id")
+	if replyID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing reply id"})
+		return
+	}
+
+	// Verify reply exists
+	var threadID string
+	err := db.QueryRowContext(r.Context(), "SELECT thread_id FROM replies WHERE id = ?", replyID).Scan(&threadID)
+	if err == nil {
+		err = requireVisible(r.Context(), db, agent, threadID)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "reply not found"})
+		return
+	}
+
+	storeAttachment(db, cfg, w, r, agent, threadID, &replyID)
+}
+
+// handleListThreadAttachments lists attachment metadata for a thread and its replies.
+func handleListThreadAttachments(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing thread id"})
+		return
+	}
+	if err := requireVisible(r.Context(), db, agent, threadID); err != nil {
+		writeStoreError(w, err, "failed to query thread")
+		return
+	}
+
+	attachments, err := threadAttachments(r.Context(), db, threadID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query attachments"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, attachments)
+}
+
+// serveAttachment writes the attachment content with download headers.
+// It reports whether the attachment was found in a thread agent can read.
+func serveAttachment(db *sql.DB, w http.ResponseWriter, r *http.Request, agent *Agent, id string) (bool, error) {
+	visible, args := visibleThreadCondition(agent, "att.thread_id")
+	var filename, contentType, sum string
+	var data []byte
+	err := db.QueryRowContext(r.Context(),
+		"SELECT att.filename, att.content_type, att.sha256, att.data FROM attachments att WHERE att.id = ? AND "+visible,
+		append([]interface{}{id}, args...)...,
+	).Scan(&filename, &contentType, &sum, &data)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+sum+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+	return true, nil
+}
+
+// handleDownloadAttachment returns the raw attachment content.
+func handleDownloadAttachment(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	found, err := serveAttachment(db, w, r, agent, r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load attachment"})
+		return
+	}
+	if !found {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "attachment not found"})
+	}
+}
+
+// handleDeleteAttachment deletes an attachment uploaded by the requesting agent.
+func handleDeleteAttachment(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	attachmentID := r.PathValue("id")
+	if attachmentID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing attachment id"})
+		return
+	}
+
+	// Check if attachment exists and verify ownership
+	var ownerID string
+	err := db.QueryRowContext(r.Context(), "SELECT agent_id FROM attachments WHERE id = ?", attachmentID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "attachment not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query attachment"})
+		return
+	}
+	if ownerID != agent.ID {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "you can only delete your own attachments"})
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM attachments WHERE id = ?", attachmentID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to delete attachment"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDashboardAttachment serves an attachment on a public thread to a
+// logged-in dashboard user.
+func handleDashboardAttachment(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	found, err := serveAttachment(db, w, r, nil, r.PathValue("id"))
+	if err != nil {
+		log.Printf("dashboard attachment error: %v", err)
+		http.Error(w, "failed to load attachment", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "attachment not found", http.StatusNotFound)
+	}
+}
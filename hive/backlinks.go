@@ -0,0 +1,109 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A thread or reply body that names another thread or reply, by its ID or
+// by a dashboard or API link (which contain the ID), is recorded as a
+// reference when written, so the cited thread can list what refers to it.
+
+var referencePattern = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+
+// parseReferences returns the unique IDs in body, in order of first
+// appearance.
+func parseReferences(body string) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, id := range referencePattern.FindAllString(body, -1) {
+		id = strings.ToLower(id)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// recordReferences replaces the stored references from a thread or reply
+// with the threads and replies cited in body. IDs of anything else, and
+// citations of the thread itself, are ignored. As with recordMentions,
+// threadID is the parent thread for replies.
+func recordReferences(ctx context.Context, db dbtx, threadID string, replyID *string, body string) error {
+	if replyID != nil {
+		if _, err := db.ExecContext(ctx, "DELETE FROM thread_references WHERE reply_id = ?", *replyID); err != nil {
+			return fmt.Errorf("clear reply references: %w", err)
+		}
+	} else {
+		if _, err := db.ExecContext(ctx, "DELETE FROM thread_references WHERE thread_id = ? AND reply_id IS NULL", threadID); err != nil {
+			return fmt.Errorf("clear thread references: %w", err)
+		}
+	}
+
+	now := time.Now()
+	for _, id := range parseReferences(body) {
+		targetThreadID, targetReplyID := id, (*string)(nil)
+		var found bool
+		if err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM threads WHERE id = ?)", id).Scan(&found); err != nil {
+			return fmt.Errorf("look up referenced thread: %w", err)
+		}
+		if !found {
+			err := db.QueryRowContext(ctx, "SELECT thread_id FROM replies WHERE id = ?", id).Scan(&targetThreadID)
+			if err == sql.ErrNoRows {
+				continue
+			}
+			if err != nil {
+				return fmt.Errorf("look up referenced reply: %w", err)
+			}
+			targetReplyID = &id
+		}
+		if targetThreadID == threadID {
+			continue
+		}
+
+		_, err := db.ExecContext(ctx,
+			`INSERT INTO thread_references (id, thread_id, reply_id, target_thread_id, target_reply_id, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			uuid.New().String(), threadID, replyID, targetThreadID, targetReplyID, now,
+		)
+		if err != nil {
+			return fmt.Errorf("insert reference: %w", err)
+		}
+	}
+	return nil
+}
+
+// threadBacklinks returns the threads and replies that cite a thread or any
+// of its replies, oldest first, leaving out threads agent can't read.
+func threadBacklinks(ctx context.Context, db dbtx, agent *Agent, threadID string) ([]Backlink, error) {
+	cond, args := visibleCondition(agent)
+	rows, err := db.QueryContext(ctx,
+		`SELECT ref.thread_id, t.title, ref.reply_id, a.name, ref.target_reply_id, ref.created_at
+		FROM thread_references ref
+		JOIN threads t ON ref.thread_id = t.id
+		LEFT JOIN replies r ON ref.reply_id = r.id
+		JOIN agents a ON a.id = COALESCE(r.agent_id, t.agent_id)
+		WHERE ref.target_thread_id = ? AND t.publish_at IS NULL AND `+cond+`
+		ORDER BY ref.created_at ASC`, append([]interface{}{threadID}, args...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query backlinks: %w", err)
+	}
+	defer rows.Close()
+
+	backlinks := []Backlink{}
+	for rows.Next() {
+		var b Backlink
+		if err := rows.Scan(&b.ThreadID, &b.ThreadTitle, &b.ReplyID, &b.AgentName, &b.TargetReplyID, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan backlink: %w", err)
+		}
+		backlinks = append(backlinks, b)
+	}
+	return backlinks, rows.Err()
+}
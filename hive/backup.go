@@ -0,0 +1,328 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+// Backups use SQLite's online backup API, which copies the database page by
+// page inside a read transaction. Copying forum.db directly is unsafe while
+// the server runs, since recent writes may still be in the WAL.
+
+// sqliteBackuper is implemented by modernc's SQLite connections.
+type sqliteBackuper interface {
+	NewBackup(dstURI string) (*sqlite.Backup, error)
+	NewRestore(srcURI string) (*sqlite.Backup, error)
+}
+
+// withBackuper runs fn on one of db's underlying SQLite connections.
+func withBackuper(ctx context.Context, db *sql.DB, fn func(sqliteBackuper) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Raw(func(dc any) error {
+		if tc, ok := dc.(*tracedConn); ok {
+			dc = tc.Conn
+		}
+		b, ok := dc.(sqliteBackuper)
+		if !ok {
+			return errors.New("database driver does not support backups")
+		}
+		return fn(b)
+	})
+}
+
+// runBackup copies every page and releases the backup.
+func runBackup(b *sqlite.Backup) error {
+	if _, err := b.Step(-1); err != nil {
+		b.Finish()
+		return err
+	}
+	return b.Finish()
+}
+
+// snapshotDB writes a consistent copy of the live database to a new file at
+// path and checks it with verifySnapshot.
+func snapshotDB(ctx context.Context, db *sql.DB, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("snapshot %s already exists", path)
+	}
+	err := withBackuper(ctx, db, func(b sqliteBackuper) error {
+		bck, err := b.NewBackup(path)
+		if err != nil {
+			return err
+		}
+		return runBackup(bck)
+	})
+	if err == nil {
+		err = detachSnapshot(path)
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("back up to %s: %w", path, err)
+	}
+	if err := verifySnapshot(path); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// detachSnapshot takes a fresh snapshot out of WAL mode, which it inherits
+// from the live database, so the file stands alone without -wal and -shm
+// companions.
+func detachSnapshot(path string) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	_, err = db.Exec("PRAGMA journal_mode=DELETE")
+	return err
+}
+
+// verifySnapshot checks that the file at path is an intact forum database.
+func verifySnapshot(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("open snapshot: %w", err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("check snapshot %s: %w", path, err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return fmt.Errorf("check snapshot %s: %w", path, err)
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("check snapshot %s: %w", path, err)
+	}
+	if len(problems) > 0 {
+		if len(problems) > 3 {
+			problems = append(problems[:3], "...")
+		}
+		return fmt.Errorf("snapshot %s is corrupt: %s", path, strings.Join(problems, "; "))
+	}
+
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('agents', 'threads')").Scan(&tables); err != nil {
+		return fmt.Errorf("check snapshot %s: %w", path, err)
+	}
+	if tables != 2 {
+		return fmt.Errorf("%s is not a forum database", path)
+	}
+	return nil
+}
+
+// restoreDB replaces the database at dbPath with the snapshot at src, before
+// the server opens it. The snapshot is verified first, and the current
+// database, if any, is saved alongside as <dbPath>.pre-restore-<time>. On
+// success src is renamed to <src>.restored so a restart doesn't restore it
+// again.
+func restoreDB(dbPath, src string) error {
+	if err := verifySnapshot(src); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", dbPath, err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	if info, err := os.Stat(dbPath); err == nil && info.Size() > 0 {
+		saved := fmt.Sprintf("%s.pre-restore-%s", dbPath, time.Now().UTC().Format("20060102-150405"))
+		if err := snapshotDB(ctx, db, saved); err != nil {
+			return fmt.Errorf("save current database: %w", err)
+		}
+		log.Printf("restore: saved the current database to %s", saved)
+	}
+
+	err = withBackuper(ctx, db, func(b sqliteBackuper) error {
+		bck, err := b.NewRestore(src)
+		if err != nil {
+			return err
+		}
+		return runBackup(bck)
+	})
+	if err != nil {
+		return fmt.Errorf("restore from %s: %w", src, err)
+	}
+	if _, err := db.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("checkpoint restored database: %w", err)
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+	if err := verifySnapshot(dbPath); err != nil {
+		return fmt.Errorf("restored database failed verification: %w", err)
+	}
+
+	if err := os.Rename(src, src+".restored"); err != nil {
+		return fmt.Errorf("restored, but could not rename %s (unset RESTORE_FROM before restarting): %w", src, err)
+	}
+	log.Printf("restore: restored %s from %s", dbPath, src)
+	return nil
+}
+
+// backupFilename names a snapshot taken now.
+func backupFilename() string {
+	return fmt.Sprintf("forum-%s.db", time.Now().UTC().Format("20060102-150405"))
+}
+
+// sendSnapshot snapshots the database to a temporary file and streams it as
+// a download.
+func sendSnapshot(ctx context.Context, db *sql.DB, w http.ResponseWriter) error {
+	dir, err := os.MkdirTemp("", "forum-backup-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "forum.db")
+	if err := snapshotDB(ctx, db, path); err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", backupFilename()))
+	if info, err := f.Stat(); err == nil {
+		w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		log.Printf("backup: send: %v", err)
+	}
+	return nil
+}
+
+// BackupFile is a snapshot saved in BACKUP_DIR.
+type BackupFile struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// saveSnapshot snapshots the database into dir as name, or a timestamped
+// name if name is empty.
+func saveSnapshot(ctx context.Context, db *sql.DB, dir, name string) (BackupFile, error) {
+	if dir == "" {
+		return BackupFile{}, inputError("server-side backups are disabled (set BACKUP_DIR)")
+	}
+	if name == "" {
+		name = backupFilename()
+	}
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".db") {
+		return BackupFile{}, inputError("name must be a plain file name ending in .db")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return BackupFile{}, err
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return BackupFile{}, inputError("a backup with that name already exists")
+	}
+	if err := snapshotDB(ctx, db, path); err != nil {
+		return BackupFile{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return BackupFile{}, err
+	}
+	return BackupFile{Name: name, Size: info.Size(), CreatedAt: info.ModTime().UTC()}, nil
+}
+
+// handleBackup streams a verified snapshot of the database. Requires the
+// admin scope.
+func handleBackup(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requireScope(w, agent, scopeAdmin) {
+		return
+	}
+
+	if err := sendSnapshot(r.Context(), db, w); err != nil {
+		log.Printf("backup: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to create backup"})
+		return
+	}
+	log.Printf("backup downloaded by %s", agent.Name)
+}
+
+// handleSaveBackup writes a verified snapshot into BACKUP_DIR on the server.
+// Requires the admin scope.
+func handleSaveBackup(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requireScope(w, agent, scopeAdmin) {
+		return
+	}
+
+	var input struct {
+		Name string `json:"name"`
+	}
+	if r.ContentLength != 0 {
+		if err := readJSON(r, &input); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+	}
+
+	saved, err := saveSnapshot(r.Context(), db, cfg.BackupDir, input.Name)
+	if err != nil {
+		writeStoreError(w, err, "failed to create backup")
+		return
+	}
+	log.Printf("backup %s saved by %s", saved.Name, agent.Name)
+
+	writeJSON(w, http.StatusCreated, saved)
+}
+
+// handleAdminBackup downloads a verified snapshot from the admin panel.
+func handleAdminBackup(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := sendSnapshot(r.Context(), db, w); err != nil {
+		log.Printf("admin backup: %v", err)
+		http.Error(w, "failed to create backup", http.StatusInternalServerError)
+		return
+	}
+	if admin := AdminFromContext(r.Context()); admin != nil {
+		log.Printf("backup downloaded by admin %s", admin.Username)
+	}
+}
@@ -0,0 +1,226 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBatchOperations caps the operations in one batch.
+const maxBatchOperations = 50
+
+// Batch operation kinds.
+const (
+	batchCreateThread = "create_thread"
+	batchCreateReply  = "create_reply"
+	batchAddStatus    = "add_status"
+)
+
+// batchOperation is one write in a batch. Which fields apply depends on Op,
+// and match the request body of the corresponding endpoint. Any ID may be
+// "$N" to refer to what operation N of the batch created.
+type batchOperation struct {
+	Op string `json:"op"`
+
+	// create_thread
+	Title        string     `json:"title"`
+	Tags         []string   `json:"tags"`
+	Priority     string     `json:"priority"`
+	DueAt        *time.Time `json:"due_at"`
+	PublishAt    *time.Time `json:"publish_at"`
+	Visibility   string     `json:"visibility"`
+	Participants []string   `json:"participants"`
+
+	// create_thread and create_reply
+	Body string `json:"body"`
+
+	// create_reply, and add_status on a thread
+	ThreadID      string  `json:"thread_id"`
+	ParentReplyID *string `json:"parent_reply_id"`
+
+	// add_status, on a thread or a reply
+	ReplyID     string  `json:"reply_id"`
+	Tag         string  `json:"tag"`
+	ReferenceID *string `json:"reference_id"`
+	// Unblock, with resolved on a thread, clears the blocked tags that
+	// reference it.
+	Unblock bool `json:"unblock"`
+}
+
+// batchResult is what one batch operation created.
+type batchResult struct {
+	Op     string     `json:"op"`
+	ID     string     `json:"id"`
+	Thread *Thread    `json:"thread,omitempty"`
+	Reply  *Reply     `json:"reply,omitempty"`
+	Status *StatusTag `json:"status,omitempty"`
+}
+
+// runBatch applies ops in order in one transaction. If any operation fails
+// none of them take effect, and the error names the operation. Events go
+// out only once the batch has committed.
+func runBatch(ctx context.Context, db *sql.DB, bus publisher, agent *Agent, ops []batchOperation) ([]batchResult, error) {
+	if len(ops) == 0 {
+		return nil, inputError("operations are required")
+	}
+	if len(ops) > maxBatchOperations {
+		return nil, inputError(fmt.Sprintf("at most %d operations per batch", maxBatchOperations))
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin batch: %w", err)
+	}
+	defer tx.Rollback()
+
+	var events pendingEvents
+	results := make([]batchResult, 0, len(ops))
+	for i, op := range ops {
+		res, err := runBatchOperation(ctx, tx, &events, agent, op, results)
+		if err != nil {
+			return nil, batchError(i, op.Op, err)
+		}
+		results = append(results, res)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit batch: %w", err)
+	}
+	for _, e := range events {
+		bus.Publish(e)
+	}
+	return results, nil
+}
+
+// runBatchOperation applies one operation, given the results of the ones
+// before it.
+func runBatchOperation(ctx context.Context, tx *sql.Tx, bus publisher, agent *Agent, op batchOperation, earlier []batchResult) (batchResult, error) {
+	resolve := func(id string) (string, error) {
+		if !strings.HasPrefix(id, "$") {
+			return id, nil
+		}
+		n, err := strconv.Atoi(id[1:])
+		if err != nil || n < 0 || n >= len(earlier) {
+			return "", inputError(fmt.Sprintf("%s does not refer to an earlier operation", id))
+		}
+		return earlier[n].ID, nil
+	}
+	resolveOptional := func(id *string) (*string, error) {
+		if id == nil {
+			return nil, nil
+		}
+		resolved, err := resolve(*id)
+		return &resolved, err
+	}
+
+	switch op.Op {
+	case batchCreateThread:
+		t, err := createThread(ctx, tx, bus, agent, op.Title, op.Body, op.Tags, op.DueAt, op.Priority, op.PublishAt, op.Visibility, op.Participants)
+		if err != nil {
+			return batchResult{}, err
+		}
+		return batchResult{Op: op.Op, ID: t.ID, Thread: &t}, nil
+
+	case batchCreateReply:
+		threadID, err := resolve(op.ThreadID)
+		if err != nil {
+			return batchResult{}, err
+		}
+		parentReplyID, err := resolveOptional(op.ParentReplyID)
+		if err != nil {
+			return batchResult{}, err
+		}
+		reply, err := createReply(ctx, tx, bus, agent, threadID, op.Body, parentReplyID)
+		if err != nil {
+			return batchResult{}, err
+		}
+		return batchResult{Op: op.Op, ID: reply.ID, Reply: &reply}, nil
+
+	case batchAddStatus:
+		if (op.ThreadID == "") == (op.ReplyID == "") {
+			return batchResult{}, inputError("exactly one of thread_id and reply_id is required")
+		}
+		referenceID, err := resolveOptional(op.ReferenceID)
+		if err != nil {
+			return batchResult{}, err
+		}
+		var st StatusTag
+		if op.ThreadID != "" {
+			threadID, err := resolve(op.ThreadID)
+			if err != nil {
+				return batchResult{}, err
+			}
+			st, err = createThreadStatus(ctx, tx, bus, agent, threadID, op.Tag, referenceID, op.Unblock)
+			if err != nil {
+				return batchResult{}, err
+			}
+		} else {
+			replyID, err := resolve(op.ReplyID)
+			if err != nil {
+				return batchResult{}, err
+			}
+			st, err = createReplyStatus(ctx, tx, bus, agent, replyID, op.Tag, referenceID)
+			if err != nil {
+				return batchResult{}, err
+			}
+		}
+		return batchResult{Op: op.Op, ID: st.ID, Status: &st}, nil
+
+	default:
+		return batchResult{}, inputError(fmt.Sprintf("unknown op %q (use create_thread, create_reply, or add_status)", op.Op))
+	}
+}
+
+// batchError prefixes err with the failed operation, keeping its kind so
+// it maps to the same status code.
+func batchError(index int, op string, err error) error {
+	prefix := fmt.Sprintf("operation %d (%s): ", index, op)
+	switch e := err.(type) {
+	case quarantineError:
+		// The batch rolls back, taking the quarantined copy with it
+		return inputError(prefix + e.Error() + "; send it outside a batch to have it reviewed")
+	case inputError:
+		return inputError(prefix + e.Error())
+	case fieldError:
+		e.msg = prefix + e.msg
+		return e
+	case notFoundError:
+		return notFoundError(prefix + e.Error())
+	case conflictError:
+		return conflictError(prefix + e.Error())
+	default:
+		return fmt.Errorf("%s%w", prefix, err)
+	}
+}
+
+// handleBatch applies a list of creates in one transaction, so an agent can
+// record a finished task in one round trip.
+func handleBatch(db *sql.DB, bus *EventBus, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var input struct {
+		Operations []batchOperation `json:"operations"`
+	}
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	results, err := runBatch(r.Context(), db, bus, agent, input.Operations)
+	if err != nil {
+		writeStoreError(w, err, "failed to run batch")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"results": results,
+	})
+}
@@ -0,0 +1,185 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Bulk actions apply one change to many threads at once, for cleaning up
+// after a misbehaving agent. They run in one transaction: if any thread is
+// missing, none is changed.
+
+// maxBulkThreads caps the threads in one bulk action.
+const maxBulkThreads = 500
+
+// Bulk thread actions.
+const (
+	bulkArchive   = "archive"
+	bulkUnarchive = "unarchive"
+	bulkDelete    = "delete"
+	bulkTag       = "tag"
+	bulkMove      = "move"
+)
+
+// bulkThreadInput is a bulk action on the threads in IDs. Tags applies to
+// tag, which adds them to each thread's tags, and Workspace to move, which
+// moves each thread to the workspace with that ID or name.
+type bulkThreadInput struct {
+	Action    string   `json:"action"`
+	IDs       []string `json:"ids"`
+	Tags      []string `json:"tags"`
+	Workspace string   `json:"workspace"`
+}
+
+// bulkUpdateThreads applies the action to every thread in in.IDs and
+// returns how many threads it changed.
+func bulkUpdateThreads(ctx context.Context, db *sql.DB, in bulkThreadInput) (int, error) {
+	if len(in.IDs) == 0 {
+		return 0, inputError("ids is required")
+	}
+	if len(in.IDs) > maxBulkThreads {
+		return 0, inputError(fmt.Sprintf("at most %d threads per bulk action", maxBulkThreads))
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin bulk action: %w", err)
+	}
+	defer tx.Rollback()
+
+	var query string
+	var args []interface{}
+	switch in.Action {
+	case bulkArchive:
+		query = "UPDATE threads SET archived = 1, archived_at = COALESCE(archived_at, ?) WHERE id = ?"
+		args = []interface{}{time.Now()}
+	case bulkUnarchive:
+		query = "UPDATE threads SET archived = 0, archived_at = NULL WHERE id = ?"
+	case bulkDelete:
+		query = "DELETE FROM threads WHERE id = ?"
+	case bulkTag:
+		var tags []string
+		for _, tag := range in.Tags {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) == 0 {
+			return 0, inputError("tags is required")
+		}
+		tagsJSON, err := json.Marshal(tags)
+		if err != nil {
+			return 0, fmt.Errorf("marshal tags: %w", err)
+		}
+		// Append the tags each thread doesn't already have, in order.
+		query = `UPDATE threads SET tags = (
+				SELECT json_group_array(value) FROM (
+					SELECT value FROM json_each(threads.tags)
+					UNION ALL
+					SELECT DISTINCT n.value FROM json_each(?) n
+					WHERE n.value NOT IN (SELECT value FROM json_each(threads.tags))
+				)
+			), updated_at = ?
+			WHERE id = ?`
+		args = []interface{}{string(tagsJSON), time.Now()}
+	case bulkMove:
+		if in.Workspace == "" {
+			return 0, inputError("workspace is required")
+		}
+		workspaceID, err := resolveWorkspace(ctx, tx, in.Workspace)
+		if err != nil {
+			return 0, err
+		}
+		query = "UPDATE threads SET workspace_id = ? WHERE id = ?"
+		args = []interface{}{workspaceID}
+	default:
+		return 0, inputError(fmt.Sprintf("action must be one of %s, %s, %s, %s, or %s", bulkArchive, bulkUnarchive, bulkDelete, bulkTag, bulkMove))
+	}
+
+	changed := 0
+	seen := make(map[string]bool, len(in.IDs))
+	for _, id := range in.IDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		res, err := tx.ExecContext(ctx, query, append(args, id)...)
+		if err != nil {
+			return 0, fmt.Errorf("%s thread %s: %w", in.Action, id, err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return 0, notFoundError(fmt.Sprintf("thread %q not found", id))
+		}
+		changed++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit bulk action: %w", err)
+	}
+	return changed, nil
+}
+
+// handleBulkThreads applies a bulk action to threads in any workspace.
+// Requires the admin scope.
+func handleBulkThreads(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requireScope(w, agent, scopeAdmin) {
+		return
+	}
+
+	var input bulkThreadInput
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	n, err := bulkUpdateThreads(r.Context(), db, input)
+	if err != nil {
+		writeStoreError(w, err, "failed to apply bulk action")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"action": input.Action, "threads": n})
+}
+
+// handleAdminBulkThreads applies the bulk action chosen in the threads list
+// to the checked threads.
+func handleAdminBulkThreads(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	_, err := bulkUpdateThreads(r.Context(), db, bulkThreadInput{
+		Action:    r.FormValue("action"),
+		IDs:       r.Form["ids"],
+		Tags:      strings.Split(r.FormValue("tags"), ","),
+		Workspace: r.FormValue("to_workspace"),
+	})
+	switch err.(type) {
+	case nil:
+	case inputError, notFoundError:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		log.Printf("admin bulk threads: %v", err)
+		http.Error(w, "failed to apply bulk action", http.StatusInternalServerError)
+		return
+	}
+
+	redirect := "/admin/threads"
+	if ws := r.FormValue("workspace"); ws != "" {
+		redirect += "?" + url.Values{"workspace": {ws}}.Encode()
+	}
+	http.Redirect(w, r, redirect, http.StatusSeeOther)
+}
@@ -0,0 +1,250 @@
+package hive
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hot read paths polled by many agents at once (the active context,
+// announcements, the dashboard feed) keep their results for a short TTL.
+// The traced driver counts the writes to each table of each database, and
+// a cached result is dropped as soon as a table it read from is written in
+// the database it was read from, so the TTL only
+// bounds staleness from what the counts can't see: time passing (an
+// announcement expiring, a thread falling overdue) and the agents table,
+// which every request writes to and which isn't counted against caches.
+
+// defaultCacheTTL is how long a cached read is kept unless configured
+// otherwise.
+const defaultCacheTTL = 5 * time.Second
+
+// maxCacheEntries caps how many results a cache keeps; past it, expired
+// entries are swept, and if none have expired the cache starts over.
+const maxCacheEntries = 10000
+
+// tableWrites counts the committed writes to each table of one database.
+// Writes to cached tables are passed on to relays, which tell other
+// replicas. A nil *tableWrites counts nothing.
+type tableWrites struct {
+	mu     sync.Mutex
+	counts map[string]uint64
+	relays map[*Cluster]struct{}
+}
+
+func newTableWrites() *tableWrites {
+	return &tableWrites{counts: map[string]uint64{}, relays: map[*Cluster]struct{}{}}
+}
+
+// dbWrites holds the counts of each database opened with openTracedDB, so
+// that servers sharing a process, each with its own database, don't see
+// each other's writes.
+var dbWrites = struct {
+	sync.Mutex
+	m map[*sql.DB]*tableWrites
+}{m: map[*sql.DB]*tableWrites{}}
+
+// writesOf returns the counts of db, or nil if it isn't counted.
+func writesOf(db *sql.DB) *tableWrites {
+	dbWrites.Lock()
+	defer dbWrites.Unlock()
+	return dbWrites.m[db]
+}
+
+// forgetWrites drops the counts of db once it is closed.
+func forgetWrites(db *sql.DB) {
+	dbWrites.Lock()
+	defer dbWrites.Unlock()
+	delete(dbWrites.m, db)
+}
+
+// cachedTables are the tables some cache reads from. The caches are
+// declared once for the package, so this is the same for every server.
+var cachedTables = map[string]bool{}
+
+// count records a committed write to each of tables.
+func (w *tableWrites) count(tables []string) {
+	if w == nil || len(tables) == 0 {
+		return
+	}
+	w.add(tables)
+
+	var cached []string
+	for _, table := range tables {
+		if cachedTables[table] && !slices.Contains(cached, table) {
+			cached = append(cached, table)
+		}
+	}
+	if len(cached) == 0 {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for c := range w.relays {
+		c.relayWrites(cached)
+	}
+}
+
+// add counts a write to each of tables, made here or on another replica.
+func (w *tableWrites) add(tables []string) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, table := range tables {
+		w.counts[table]++
+	}
+}
+
+// addRelay passes writes to cached tables on to c from now on.
+func (w *tableWrites) addRelay(c *Cluster) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.relays[c] = struct{}{}
+}
+
+// removeRelay stops passing writes on to c.
+func (w *tableWrites) removeRelay(c *Cluster) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.relays, c)
+}
+
+// sum adds up the writes counted so far to tables, which changes whenever
+// any of them is written.
+func (w *tableWrites) sum(tables []string) uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var n uint64
+	for _, table := range tables {
+		n += w.counts[table]
+	}
+	return n
+}
+
+// writtenTable returns the table an INSERT, REPLACE, UPDATE, or DELETE
+// statement writes to, or "" if query isn't one.
+func writtenTable(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) < 2 {
+		return ""
+	}
+	// The table follows INTO, FROM, or the UPDATE and its OR clause
+	var after string
+	switch strings.ToUpper(fields[0]) {
+	case "INSERT", "REPLACE":
+		after = "INTO"
+	case "DELETE":
+		after = "FROM"
+	case "UPDATE":
+		fields = fields[1:]
+		if len(fields) > 2 && strings.EqualFold(fields[0], "OR") {
+			fields = fields[2:]
+		}
+		return tableName(fields[0])
+	default:
+		return ""
+	}
+	for i, field := range fields[:len(fields)-1] {
+		if strings.EqualFold(field, after) {
+			return tableName(fields[i+1])
+		}
+	}
+	return ""
+}
+
+// tableName strips a column list and quoting from the table in a
+// statement.
+func tableName(field string) string {
+	if i := strings.IndexByte(field, '('); i >= 0 {
+		field = field[:i]
+	}
+	return strings.ToLower(strings.Trim(field, "\"`[]"))
+}
+
+// readCache keeps results read from tables for the TTL of the server
+// reading them, keyed by the database and what they were read for.
+type readCache struct {
+	tables  []string
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+}
+
+// cacheKey keeps the results of servers sharing a process apart.
+type cacheKey struct {
+	db  *sql.DB
+	key string
+}
+
+type cacheEntry struct {
+	value   interface{}
+	writes  uint64
+	expires time.Time
+}
+
+// newReadCache returns a cache of results read from tables.
+func newReadCache(tables ...string) *readCache {
+	for _, table := range tables {
+		cachedTables[table] = true
+	}
+	return &readCache{tables: tables, entries: map[cacheKey]cacheEntry{}}
+}
+
+// get returns the result cached for key in db, or loads, caches for ttl,
+// and returns it if there is none or the tables have been written since it
+// was loaded. A zero ttl turns caching off, as does a database whose
+// writes aren't counted. Errors aren't cached.
+func (c *readCache) get(db *sql.DB, ttl time.Duration, key string, load func() (interface{}, error)) (interface{}, error) {
+	counts := writesOf(db)
+	if ttl <= 0 || counts == nil {
+		return load()
+	}
+	// Counted before loading, so a write during the load makes the
+	// result stale rather than hiding it
+	writes := counts.sum(c.tables)
+	now := time.Now()
+
+	k := cacheKey{db, key}
+	c.mu.Lock()
+	e, ok := c.entries[k]
+	c.mu.Unlock()
+	if ok && e.writes == writes && now.Before(e.expires) {
+		return e.value, nil
+	}
+
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxCacheEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCacheEntries {
+			clear(c.entries)
+		}
+	}
+	c.entries[k] = cacheEntry{value: value, writes: writes, expires: now.Add(ttl)}
+	return value, nil
+}
+
+// The caches of the hot read paths, with the tables each reads from.
+var (
+	activeContextCache = newReadCache("threads", "replies", "status_tags", "votes", "thread_participants", "announcements")
+	announcementsCache = newReadCache("announcements")
+	feedCache          = newReadCache("threads", "replies", "status_tags", "votes", "thread_participants", "thread_tags")
+)
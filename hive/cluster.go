@@ -0,0 +1,252 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Replicas of the forum behind a load balancer share a database, but each
+// has its own event bus and read caches. With a shared event bus
+// configured, every replica relays the events it publishes, and the cached
+// tables it writes, through a Redis pub/sub channel: event streams on any
+// replica see events from all of them, and caches drop results that
+// another replica's writes made stale.
+
+// clusterQueue is how many messages may wait to be relayed before new ones
+// are dropped.
+const clusterQueue = 1024
+
+// clusterTimeout bounds connecting to Redis and publishing a message.
+const clusterTimeout = 5 * time.Second
+
+// clusterMaxBackoff caps the wait between attempts to reconnect to Redis.
+const clusterMaxBackoff = 30 * time.Second
+
+// clusterMessage is what replicas send each other: an event, or the tables
+// a committed write changed.
+type clusterMessage struct {
+	Origin string   `json:"origin"`
+	Event  *Event   `json:"event,omitempty"`
+	Tables []string `json:"tables,omitempty"`
+}
+
+// Cluster connects a server's event bus and read caches to the other
+// replicas through a Redis channel.
+type Cluster struct {
+	opts    *redis.Options
+	channel string
+	// id marks this replica's messages, which come back to it on the
+	// channel and are skipped.
+	id  string
+	bus *EventBus
+	// writes are the server's database's write counts.
+	writes *tableWrites
+	out    chan clusterMessage
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// NewCluster returns the shared event bus configured for bus and the
+// caches of db, or nil if there is none. It doesn't connect until started.
+func NewCluster(cfg Config, bus *EventBus, db *sql.DB) (*Cluster, error) {
+	if cfg.EventBusURL == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(cfg.EventBusURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT_BUS_URL: %w", err)
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid EVENT_BUS_URL %q (use redis:// or rediss://)", u.Redacted())
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid EVENT_BUS_URL %q (no host)", u.Redacted())
+	}
+	if cfg.EventBusChannel == "" {
+		return nil, errors.New("invalid EVENT_BUS_CHANNEL (must not be empty)")
+	}
+	opts, err := redis.ParseURL(cfg.EventBusURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid EVENT_BUS_URL %q: %w", u.Redacted(), err)
+	}
+	// A lone user in redis://secret@host is taken as the password
+	if u.User != nil {
+		if _, ok := u.User.Password(); !ok {
+			opts.Username, opts.Password = "", opts.Username
+		}
+	}
+	opts.DialTimeout = clusterTimeout
+	return &Cluster{
+		opts:    opts,
+		channel: cfg.EventBusChannel,
+		id:      uuid.New().String(),
+		bus:     bus,
+		writes:  writesOf(db),
+		out:     make(chan clusterMessage, clusterQueue),
+	}, nil
+}
+
+// Start relays this replica's events and writes to the others, and theirs
+// to it, until ctx is done. Lost connections are retried; what is
+// published while one is down doesn't reach the other side.
+func (c *Cluster) Start(ctx context.Context) {
+	if c == nil {
+		return
+	}
+	client := redis.NewClient(c.opts)
+	c.bus.setRelay(c.relayEvent)
+	c.writes.addRelay(c)
+	context.AfterFunc(ctx, func() {
+		c.bus.setRelay(nil)
+		c.writes.removeRelay(c)
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		c.publish(ctx, client)
+	}()
+	go func() {
+		defer wg.Done()
+		c.subscribe(ctx, client)
+	}()
+	go func() {
+		wg.Wait()
+		client.Close()
+	}()
+}
+
+// relayEvent queues an event published here for the other replicas.
+func (c *Cluster) relayEvent(e Event) {
+	c.send(clusterMessage{Origin: c.id, Event: &e})
+}
+
+// relayWrites queues the cached tables a commit here wrote to for the
+// other replicas.
+func (c *Cluster) relayWrites(tables []string) {
+	c.send(clusterMessage{Origin: c.id, Tables: tables})
+}
+
+// send queues m without blocking, dropping it if the queue is full.
+func (c *Cluster) send(m clusterMessage) {
+	select {
+	case c.out <- m:
+	default:
+		c.mu.Lock()
+		c.dropped++
+		n := c.dropped
+		c.mu.Unlock()
+		// Logged on the first drop and every hundredth after, so an outage
+		// doesn't flood the log
+		if n%100 == 1 {
+			log.Printf("event bus: relay queue full; %d messages dropped", n)
+		}
+	}
+}
+
+// publish sends queued messages to the channel until ctx is done. A
+// message that fails to send is tried again, with backoff, before the
+// next.
+func (c *Cluster) publish(ctx context.Context, client *redis.Client) {
+	backoff := time.Second
+	for {
+		var m clusterMessage
+		select {
+		case <-ctx.Done():
+			return
+		case m = <-c.out:
+		}
+		payload, err := json.Marshal(m)
+		if err != nil {
+			log.Printf("event bus: marshal message: %v", err)
+			continue
+		}
+		for {
+			pubCtx, cancel := context.WithTimeout(ctx, clusterTimeout)
+			err := client.Publish(pubCtx, c.channel, payload).Err()
+			cancel()
+			if err == nil {
+				backoff = time.Second
+				break
+			}
+			if !retryAfter(ctx, "publish", err, &backoff) {
+				return
+			}
+		}
+	}
+}
+
+// subscribe applies the other replicas' messages from the channel until
+// ctx is done. The client subscribes again whenever it reconnects.
+func (c *Cluster) subscribe(ctx context.Context, client *redis.Client) {
+	pubsub := client.Subscribe(ctx, c.channel)
+	// Waiting for a message doesn't end with ctx; closing does
+	stop := context.AfterFunc(ctx, func() { pubsub.Close() })
+	defer stop()
+	backoff := time.Second
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if !retryAfter(ctx, "subscribe", err, &backoff) {
+				return
+			}
+			continue
+		}
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			backoff = time.Second
+			log.Printf("event bus: subscribed to %s on %s", c.channel, c.opts.Addr)
+		case *redis.Message:
+			c.receive(msg.Payload)
+		}
+	}
+}
+
+// receive applies a message from the channel unless this replica sent it:
+// events go to this bus's subscribers, and writes make this replica's
+// caches stale.
+func (c *Cluster) receive(payload string) {
+	var m clusterMessage
+	if err := json.Unmarshal([]byte(payload), &m); err != nil {
+		log.Printf("event bus: bad message: %v", err)
+		return
+	}
+	if m.Origin == c.id {
+		return
+	}
+	if len(m.Tables) > 0 {
+		c.writes.add(m.Tables)
+	}
+	if m.Event != nil {
+		c.bus.deliver(*m.Event, true)
+	}
+}
+
+// retryAfter logs a failure to name and waits out backoff before the next
+// try, doubling it up to clusterMaxBackoff. It reports false if ctx ended
+// instead.
+func retryAfter(ctx context.Context, name string, err error, backoff *time.Duration) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	log.Printf("event bus: %s: %v; retrying in %s", name, err, *backoff)
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(*backoff):
+	}
+	*backoff = min(*backoff*2, clusterMaxBackoff)
+	return true
+}
@@ -0,0 +1,316 @@
+package hive
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Port      string
+	DBPath    string
+	AdminUser string
+	AdminPass string
+
+	// SQLite tunes the database connection: its pragmas, pool size, how
+	// transactions lock, and retries when the database is busy.
+	SQLite SQLiteOptions
+
+	// CacheTTL is how long hot reads (the active context, announcements,
+	// and the dashboard feed) are cached; writes to what they read drop
+	// them sooner. Zero disables the cache.
+	CacheTTL time.Duration
+
+	// MaxAttachmentBytes caps the size of a single uploaded attachment.
+	MaxAttachmentBytes int64
+
+	// Limits caps the length of titles, bodies, and tags, the tags on a
+	// thread, and the size of API request bodies.
+	Limits Limits
+
+	// RateLimitReads and RateLimitWrites are per-agent requests per minute
+	// for GET and non-GET API routes. Zero disables the limit.
+	RateLimitReads  int
+	RateLimitWrites int
+
+	// KeyRotationGrace is how long an agent's previous API key keeps working
+	// after the key is rotated.
+	KeyRotationGrace time.Duration
+
+	// AdminRequireTOTP forces every admin to enroll in TOTP two-factor
+	// authentication before using the admin panel.
+	AdminRequireTOTP bool
+
+	// AdminSessionTTL is how long an admin login lasts, however active.
+	// AdminSessionIdle ends it sooner after that long without a request;
+	// zero turns the idle timeout off.
+	AdminSessionTTL  time.Duration
+	AdminSessionIdle time.Duration
+
+	// DashboardAuthRequired puts the dashboard behind user logins, which
+	// last DashboardSessionTTL. Otherwise anyone can read it.
+	DashboardAuthRequired bool
+	DashboardSessionTTL   time.Duration
+
+	// PasswordResetTTL is how long a dashboard user's password reset link
+	// works.
+	PasswordResetTTL time.Duration
+
+	// GRPCPort is the port for the gRPC API. Empty disables it.
+	GRPCPort string
+
+	// AdminAllowedIPs and APIAllowedIPs are comma-separated addresses and
+	// CIDR ranges the admin panel and the API (HTTP and gRPC) may be
+	// reached from. Empty allows any. Requests from TrustedProxies are
+	// judged by the client address they forward in X-Forwarded-For.
+	AdminAllowedIPs string
+	APIAllowedIPs   string
+	TrustedProxies  string
+
+	// EventBusURL is a Redis server, redis://[user:password@]host:port or
+	// rediss:// for TLS, through which replicas sharing the database share
+	// events and cache invalidation on EventBusChannel. Empty runs a single
+	// instance.
+	EventBusURL     string
+	EventBusChannel string
+
+	// FeedToken is the token= query parameter that unlocks the Atom feeds.
+	// Empty disables the feeds.
+	FeedToken string
+
+	// OTLPEndpoint is the OTLP/HTTP collector that receives trace spans.
+	// Empty disables tracing. OTelServiceName names this service in traces.
+	OTLPEndpoint    string
+	OTelServiceName string
+
+	// ShutdownTimeout is how long shutdown waits for in-flight requests.
+	ShutdownTimeout time.Duration
+
+	// RequestTimeout is the deadline of API requests other than the event
+	// stream, exports, backups, and uploads, and of unary gRPC calls. Zero
+	// sets none.
+	RequestTimeout time.Duration
+
+	// BackupDir is where POST /api/v1/backup saves snapshots. Empty disables
+	// server-side snapshots; downloads still work.
+	BackupDir string
+
+	// RestoreFrom is a snapshot to restore over DBPath at startup.
+	RestoreFrom string
+
+	// RetentionArchiveAfter archives threads idle this long, and
+	// RetentionPurgeAfter deletes threads archived this long. Zero disables
+	// either policy. The policies run every RetentionInterval; with
+	// RetentionDryRun they only report what they would do.
+	RetentionArchiveAfter time.Duration
+	RetentionPurgeAfter   time.Duration
+	RetentionInterval     time.Duration
+	RetentionDryRun       bool
+
+	// MaintenanceWindow is a daily UTC range, "03:00-04:00", in which
+	// MaintenanceTasks (comma-separated) run once. Empty disables scheduled
+	// maintenance.
+	MaintenanceWindow string
+	MaintenanceTasks  string
+
+	// PublishInterval is how often scheduled threads are checked for
+	// publishing. Zero disables scheduled publishing.
+	PublishInterval time.Duration
+
+	// SLAInterval is how often threads are checked for SLA breaches to
+	// escalate. Zero disables escalation; breaches are still listed.
+	SLAInterval time.Duration
+
+	// EmbeddingsProvider enables semantic search: "openai" for an
+	// OpenAI-compatible endpoint at EmbeddingsURL serving EmbeddingsModel,
+	// or "hash" for local word hashing. Empty disables it. Threads and
+	// replies missed when created are embedded every EmbeddingsInterval.
+	EmbeddingsProvider string
+	EmbeddingsURL      string
+	EmbeddingsAPIKey   string
+	EmbeddingsModel    string
+	EmbeddingsInterval time.Duration
+
+	// SummaryProvider enables thread summaries: "openai" for an
+	// OpenAI-compatible chat completions endpoint at SummaryURL serving
+	// SummaryModel, or "extract" for local sentence extraction. Empty
+	// disables them.
+	SummaryProvider string
+	SummaryURL      string
+	SummaryAPIKey   string
+	SummaryModel    string
+
+	// AutoTag is what happens to the tags suggested for a new thread:
+	// "suggest" lists them in the response, "apply" adds them to the
+	// thread, and "off" skips suggesting. With AutoTagProvider "openai", a
+	// chat model at AutoTagURL serving AutoTagModel suggests tags as well
+	// as the tag rules.
+	AutoTag         string
+	AutoTagProvider string
+	AutoTagURL      string
+	AutoTagAPIKey   string
+	AutoTagModel    string
+
+	// DuplicateThreads is what happens when a new thread closely resembles
+	// existing ones: "warn" lists them in the response, "reject" refuses
+	// the thread unless forced, and "off" skips the check.
+	DuplicateThreads string
+
+	// PublicURL is where users reach the forum, such as
+	// https://forum.example.com, for links in Discord notifications and
+	// emails.
+	PublicURL string
+
+	// SMTPHost enables email through this SMTP server, which is sent mail
+	// with STARTTLS if it offers it, and authenticated with SMTPUsername
+	// and SMTPPassword if set. Notifications go out every EmailInterval and
+	// digests daily at EmailDigestHour UTC.
+	SMTPHost        string
+	SMTPPort        string
+	SMTPUsername    string
+	SMTPPassword    string
+	SMTPFrom        string
+	EmailInterval   time.Duration
+	EmailDigestHour int
+}
+
+func LoadConfig() Config {
+	return Config{
+		Port:      envOrDefault("PORT", "8080"),
+		DBPath:    envOrDefault("DB_PATH", "./forum.db"),
+		AdminUser: envOrDefault("ADMIN_USER", "admin"),
+		AdminPass: envOrDefault("ADMIN_PASS", "changeme"),
+
+		SQLite: SQLiteOptions{
+			BusyTimeout:  envDurationOrDefault("SQLITE_BUSY_TIMEOUT", defaultSQLiteOptions.BusyTimeout),
+			Synchronous:  envOrDefault("SQLITE_SYNCHRONOUS", defaultSQLiteOptions.Synchronous),
+			CacheSize:    envInt64OrDefault("SQLITE_CACHE_SIZE", defaultSQLiteOptions.CacheSize),
+			MmapSize:     envInt64OrDefault("SQLITE_MMAP_SIZE", defaultSQLiteOptions.MmapSize),
+			MaxOpenConns: int(envInt64OrDefault("SQLITE_MAX_OPEN_CONNS", int64(defaultSQLiteOptions.MaxOpenConns))),
+			TxLock:       envOrDefault("SQLITE_TXLOCK", defaultSQLiteOptions.TxLock),
+			BusyRetries:  int(envInt64OrDefault("SQLITE_BUSY_RETRIES", int64(defaultSQLiteOptions.BusyRetries))),
+		},
+
+		CacheTTL: envDurationOrDefault("CACHE_TTL", defaultCacheTTL),
+
+		MaxAttachmentBytes: envInt64OrDefault("MAX_ATTACHMENT_BYTES", 10<<20),
+
+		Limits: Limits{
+			MaxTitleLength:  int(envInt64OrDefault("MAX_TITLE_LENGTH", int64(defaultLimits.MaxTitleLength))),
+			MaxBodyLength:   int(envInt64OrDefault("MAX_BODY_LENGTH", int64(defaultLimits.MaxBodyLength))),
+			MaxTagLength:    int(envInt64OrDefault("MAX_TAG_LENGTH", int64(defaultLimits.MaxTagLength))),
+			MaxTags:         int(envInt64OrDefault("MAX_TAGS", int64(defaultLimits.MaxTags))),
+			MaxRequestBytes: envInt64OrDefault("MAX_REQUEST_BYTES", defaultLimits.MaxRequestBytes),
+		},
+
+		RateLimitReads:  int(envInt64OrDefault("RATE_LIMIT_READS", 600)),
+		RateLimitWrites: int(envInt64OrDefault("RATE_LIMIT_WRITES", 120)),
+
+		KeyRotationGrace: envDurationOrDefault("KEY_ROTATION_GRACE", 24*time.Hour),
+
+		AdminRequireTOTP: envBoolOrDefault("ADMIN_REQUIRE_TOTP", false),
+		AdminSessionTTL:  envDurationOrDefault("ADMIN_SESSION_TTL", 12*time.Hour),
+		AdminSessionIdle: envDurationOrDefault("ADMIN_SESSION_IDLE", time.Hour),
+
+		DashboardAuthRequired: envOrDefault("DASHBOARD_AUTH", "none") == "required",
+		DashboardSessionTTL:   envDurationOrDefault("DASHBOARD_SESSION_TTL", 24*time.Hour),
+
+		PasswordResetTTL: envDurationOrDefault("PASSWORD_RESET_TTL", time.Hour),
+
+		GRPCPort: envOrDefault("GRPC_PORT", ""),
+
+		AdminAllowedIPs: envOrDefault("ADMIN_ALLOWED_IPS", ""),
+		APIAllowedIPs:   envOrDefault("API_ALLOWED_IPS", ""),
+		TrustedProxies:  envOrDefault("TRUSTED_PROXIES", ""),
+
+		EventBusURL:     envOrDefault("EVENT_BUS_URL", ""),
+		EventBusChannel: envOrDefault("EVENT_BUS_CHANNEL", "agentic-forum"),
+
+		FeedToken: envOrDefault("FEED_TOKEN", ""),
+
+		OTLPEndpoint:    envOrDefault("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		OTelServiceName: envOrDefault("OTEL_SERVICE_NAME", "agentic-forum"),
+
+		ShutdownTimeout: envDurationOrDefault("SHUTDOWN_TIMEOUT", 30*time.Second),
+		RequestTimeout:  envDurationOrDefault("REQUEST_TIMEOUT", 30*time.Second),
+
+		BackupDir:   envOrDefault("BACKUP_DIR", ""),
+		RestoreFrom: envOrDefault("RESTORE_FROM", ""),
+
+		RetentionArchiveAfter: envDurationOrDefault("RETENTION_ARCHIVE_AFTER", 0),
+		RetentionPurgeAfter:   envDurationOrDefault("RETENTION_PURGE_AFTER", 0),
+		RetentionInterval:     envDurationOrDefault("RETENTION_INTERVAL", time.Hour),
+		RetentionDryRun:       envBoolOrDefault("RETENTION_DRY_RUN", false),
+
+		MaintenanceWindow: envOrDefault("MAINTENANCE_WINDOW", ""),
+		MaintenanceTasks:  envOrDefault("MAINTENANCE_TASKS", "checkpoint,analyze,integrity-check"),
+
+		PublishInterval: envDurationOrDefault("PUBLISH_INTERVAL", 30*time.Second),
+
+		SLAInterval: envDurationOrDefault("SLA_INTERVAL", time.Minute),
+
+		EmbeddingsProvider: envOrDefault("EMBEDDINGS_PROVIDER", ""),
+		EmbeddingsURL:      envOrDefault("EMBEDDINGS_URL", "https://api.openai.com/v1/embeddings"),
+		EmbeddingsAPIKey:   envOrDefault("EMBEDDINGS_API_KEY", ""),
+		EmbeddingsModel:    envOrDefault("EMBEDDINGS_MODEL", "text-embedding-3-small"),
+		EmbeddingsInterval: envDurationOrDefault("EMBEDDINGS_INTERVAL", 5*time.Minute),
+
+		SummaryProvider: envOrDefault("SUMMARY_PROVIDER", ""),
+		SummaryURL:      envOrDefault("SUMMARY_URL", "https://api.openai.com/v1/chat/completions"),
+		SummaryAPIKey:   envOrDefault("SUMMARY_API_KEY", ""),
+		SummaryModel:    envOrDefault("SUMMARY_MODEL", "gpt-4o-mini"),
+
+		AutoTag:         envOrDefault("AUTO_TAG", autoTagSuggest),
+		AutoTagProvider: envOrDefault("AUTO_TAG_PROVIDER", ""),
+		AutoTagURL:      envOrDefault("AUTO_TAG_URL", "https://api.openai.com/v1/chat/completions"),
+		AutoTagAPIKey:   envOrDefault("AUTO_TAG_API_KEY", ""),
+		AutoTagModel:    envOrDefault("AUTO_TAG_MODEL", "gpt-4o-mini"),
+
+		DuplicateThreads: envOrDefault("DUPLICATE_THREADS", duplicatesWarn),
+
+		PublicURL: envOrDefault("PUBLIC_URL", ""),
+
+		SMTPHost:        envOrDefault("SMTP_HOST", ""),
+		SMTPPort:        envOrDefault("SMTP_PORT", "587"),
+		SMTPUsername:    envOrDefault("SMTP_USERNAME", ""),
+		SMTPPassword:    envOrDefault("SMTP_PASSWORD", ""),
+		SMTPFrom:        envOrDefault("SMTP_FROM", "Agentic Forum <forum@localhost>"),
+		EmailInterval:   envDurationOrDefault("EMAIL_INTERVAL", time.Minute),
+		EmailDigestHour: int(envInt64OrDefault("EMAIL_DIGEST_HOUR", 9)),
+	}
+}
+
+func envOrDefault(key, fallback string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func envInt64OrDefault(key string, fallback int64) int64 {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+func envBoolOrDefault(key string, fallback bool) bool {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
+
+func envDurationOrDefault(key string, fallback time.Duration) time.Duration {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
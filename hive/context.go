@@ -0,0 +1,305 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// handleAgentContext returns what a specific agent has been doing:
+// their profile, recent threads, recent replies, and active status tags.
+func handleAgentContext(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	agentID := r.PathValue("id")
+	if agentID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing agent id"})
+		return
+	}
+
+	// Query agent record
+	a, err := scanAgent(db.QueryRowContext(r.Context(), "SELECT "+agentColumns+" FROM agents WHERE id = ? AND workspace_id = ?", agentID, agent.WorkspaceID))
+	if err == sql.ErrNoRows {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "agent not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query agent"})
+		return
+	}
+	a.hideKeyDetails()
+
+	// Only what the requesting agent can read
+	visible, visibleArgs := visibleCondition(agent)
+
+	// Query last 10 threads by this agent
+	threads, err := queryThreads(r.Context(), db,
+		"SELECT " + threadColumns + `
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		WHERE t.agent_id = ? AND `+publishedCondition+` AND `+unmergedCondition+` AND `+visible+`
+		ORDER BY t.created_at DESC
+		LIMIT 10`, append([]interface{}{agentID}, visibleArgs...)...,
+	)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query threads"})
+		return
+	}
+
+	// Query last 10 replies by this agent (with thread title for context)
+	type ReplyWithThreadTitle struct {
+		Reply
+		ThreadTitle string `json:"thread_title"`
+	}
+
+	replyRows, err := db.QueryContext(r.Context(),
+		`SELECT r.id, r.thread_id, r.agent_id, a.name, r.body, r.created_at, r.updated_at, t.title
+		FROM replies r
+		JOIN agents a ON r.agent_id = a.id
+		JOIN threads t ON r.thread_id = t.id
+		WHERE r.agent_id = ? AND `+visible+`
+		ORDER BY r.created_at DESC
+		LIMIT 10`, append([]interface{}{agentID}, visibleArgs...)...,
+	)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query replies"})
+		return
+	}
+	defer replyRows.Close()
+
+	replies := []ReplyWithThreadTitle{}
+	for replyRows.Next() {
+		var rr ReplyWithThreadTitle
+		if err := replyRows.Scan(&rr.ID, &rr.ThreadID, &rr.AgentID, &rr.AgentName, &rr.Body, &rr.CreatedAt, &rr.UpdatedAt, &rr.ThreadTitle); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to scan reply"})
+			return
+		}
+		replies = append(replies, rr)
+	}
+	if err := replyRows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to iterate replies"})
+		return
+	}
+
+	// Query active status tags applied by this agent
+	visibleStatus, visibleStatusArgs := visibleStatusCondition(agent)
+	statuses, err := queryStatusTags(r.Context(), db,
+		`SELECT `+statusColumns+` `+statusJoins+`
+		WHERE s.agent_id = ? AND s.superseded_by IS NULL AND `+visibleStatus+`
+		ORDER BY s.created_at DESC`, append([]interface{}{agentID}, visibleStatusArgs...)...,
+	)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query status tags"})
+		return
+	}
+
+	writeJSONWithETag(w, r, http.StatusOK, map[string]interface{}{
+		"agent":           a,
+		"recent_threads":  threads,
+		"recent_replies":  replies,
+		"active_statuses": statuses,
+	})
+}
+
+// handleActiveContext returns an overview of all currently active work:
+// announcements, in-progress items, needs-review items, blocked items, overdue
+// threads, and recent threads. Agents poll it, so it is cached per agent.
+func handleActiveContext(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	overview, err := activeContextCache.get(db, cfg.CacheTTL, agent.ID, func() (interface{}, error) {
+		return activeContext(r.Context(), db, agent)
+	})
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSONWithETag(w, r, http.StatusOK, overview)
+}
+
+// activeContext queries the overview handleActiveContext returns. Its
+// errors are fit to show the client.
+func activeContext(ctx context.Context, db *sql.DB, agent *Agent) (map[string]interface{}, error) {
+	// Query active announcements
+	announcements, err := listActiveAnnouncements(ctx, db, agent)
+	if err != nil {
+		return nil, errors.New("failed to query announcements")
+	}
+
+	// Only threads the requesting agent can read
+	visible, visibleArgs := visibleCondition(agent)
+
+	// Helper to query threads matching a condition
+	threadsWhere := func(where, orderBy string, args ...interface{}) ([]Thread, error) {
+		return queryThreads(ctx, db,
+			"SELECT " + threadColumns + `
+			FROM threads t
+			JOIN agents a ON t.agent_id = a.id
+			WHERE `+publishedCondition+` AND `+unmergedCondition+` AND `+visible+` AND `+where+`
+			ORDER BY `+orderBy, append(visibleArgs, args...)...,
+		)
+	}
+
+	// Helper to query threads by status tag, most urgent first
+	queryThreadsByStatus := func(tag string) ([]Thread, error) {
+		return threadsWhere(
+			"EXISTS (SELECT 1 FROM status_tags s WHERE s.thread_id = t.id AND s.tag = ? AND s.superseded_by IS NULL)",
+			priorityRank+" DESC, t.created_at DESC", tag,
+		)
+	}
+
+	inProgress, err := queryThreadsByStatus("in-progress")
+	if err != nil {
+		return nil, errors.New("failed to query in-progress threads")
+	}
+
+	needsReview, err := queryThreadsByStatus("needs-review")
+	if err != nil {
+		return nil, errors.New("failed to query needs-review threads")
+	}
+
+	blocked, err := queryThreadsByStatus("blocked")
+	if err != nil {
+		return nil, errors.New("failed to query blocked threads")
+	}
+
+	overdue, err := threadsWhere(overdueCondition, "t.due_at ASC", time.Now().UTC())
+	if err != nil {
+		return nil, errors.New("failed to query overdue threads")
+	}
+
+	// Query last 20 threads
+	recentThreads, err := queryThreads(ctx, db,
+		"SELECT " + threadColumns + `
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		WHERE `+publishedCondition+` AND `+unmergedCondition+` AND `+visible+`
+		ORDER BY t.created_at DESC
+		LIMIT 20`, visibleArgs...,
+	)
+	if err != nil {
+		return nil, errors.New("failed to query recent threads")
+	}
+
+	return map[string]interface{}{
+		"announcements":  announcements,
+		"in_progress":    inProgress,
+		"needs_review":   needsReview,
+		"blocked":        blocked,
+		"overdue":        overdue,
+		"recent_threads": recentThreads,
+	}, nil
+}
+
+// DependencyNode is one end of a dependency edge.
+type DependencyNode struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	AgentName string `json:"agent_name"`
+}
+
+// DependencyEdge records that Source depends on, or is blocked by, DependsOn.
+type DependencyEdge struct {
+	Source    DependencyNode `json:"source"`
+	DependsOn DependencyNode `json:"depends_on"`
+	Status    string         `json:"status"`
+}
+
+// queryDependencies returns the dependency graph: all status_tags where
+// the tag is "depends-on" or "blocked", reference_id is not null, and the
+// tag has not been superseded, with source and target thread/reply info
+// joined. Edges to or from threads agent can't read are left out.
+func queryDependencies(ctx context.Context, db *sql.DB, agent *Agent) ([]DependencyEdge, error) {
+	visible, args := visibleStatusCondition(agent)
+	visibleRef, refArgs := visibleReferenceCondition(agent)
+
+	// Join to get source thread info and referenced thread info.
+	rows, err := db.QueryContext(ctx,
+		`SELECT
+			s.tag,
+			COALESCE(s.thread_id, s.reply_id) AS source_id,
+			COALESCE(t_src.title, t_reply_src.title) AS source_title,
+			COALESCE(a_src.name, a_reply_src.name) AS source_agent,
+			s.reference_id,
+			COALESCE(t_ref.title, t_reply_ref.title, '') AS ref_title,
+			COALESCE(a_ref.name, a_reply_ref.name, '') AS ref_agent
+		FROM status_tags s
+		LEFT JOIN threads t_src ON s.thread_id = t_src.id
+		LEFT JOIN agents a_src ON t_src.agent_id = a_src.id
+		LEFT JOIN replies r_src ON s.reply_id = r_src.id
+		LEFT JOIN threads t_reply_src ON r_src.thread_id = t_reply_src.id
+		LEFT JOIN agents a_reply_src ON r_src.agent_id = a_reply_src.id
+		LEFT JOIN threads t_ref ON s.reference_id = t_ref.id
+		LEFT JOIN agents a_ref ON t_ref.agent_id = a_ref.id
+		LEFT JOIN replies r_ref ON s.reference_id = r_ref.id
+		LEFT JOIN threads t_reply_ref ON r_ref.thread_id = t_reply_ref.id
+		LEFT JOIN agents a_reply_ref ON r_ref.agent_id = a_reply_ref.id
+		WHERE s.tag IN ('depends-on', 'blocked')
+		AND s.reference_id IS NOT NULL AND s.superseded_by IS NULL
+		AND `+visible+` AND `+visibleRef+`
+		ORDER BY s.created_at DESC`, append(args, refArgs...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dependencies := []DependencyEdge{}
+	for rows.Next() {
+		var edge DependencyEdge
+		if err := rows.Scan(
+			&edge.Status,
+			&edge.Source.ID, &edge.Source.Title, &edge.Source.AgentName,
+			&edge.DependsOn.ID, &edge.DependsOn.Title, &edge.DependsOn.AgentName,
+		); err != nil {
+			return nil, err
+		}
+		dependencies = append(dependencies, edge)
+	}
+	return dependencies, rows.Err()
+}
+
+// handleDependencies returns the dependency graph across threads and replies,
+// as JSON or, with ?format=dot or ?format=mermaid, as graph source.
+func handleDependencies(store StatusStore, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "dot" && format != "mermaid" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid format (use json, dot, or mermaid)"})
+		return
+	}
+
+	dependencies, err := store.Dependencies(r.Context(), agent)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query dependencies"})
+		return
+	}
+
+	switch format {
+	case "dot":
+		writeWithETag(w, r, http.StatusOK, "text/vnd.graphviz; charset=utf-8", []byte(renderDependencyDOT(dependencies)))
+		return
+	case "mermaid":
+		writeWithETag(w, r, http.StatusOK, "text/plain; charset=utf-8", []byte(renderDependencyMermaid(dependencies)))
+		return
+	}
+
+	writeJSONWithETag(w, r, http.StatusOK, map[string]interface{}{
+		"dependencies": dependencies,
+	})
+}
@@ -0,0 +1,234 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Dependency cycles are found between threads: a depends-on or blocked tag
+// on a reply counts for the reply's thread, and a reference to a reply
+// counts as one to its thread. Dependencies within a single thread are
+// ordering, not deadlock, and are left out.
+
+// dependencyGraph maps each thread to the threads it waits on through
+// depends-on and blocked tags in effect.
+func dependencyGraph(ctx context.Context, db dbtx) (map[string][]string, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT COALESCE(s.thread_id, r_src.thread_id), COALESCE(t_ref.id, r_ref.thread_id)
+		FROM status_tags s
+		LEFT JOIN replies r_src ON s.reply_id = r_src.id
+		LEFT JOIN threads t_ref ON s.reference_id = t_ref.id
+		LEFT JOIN replies r_ref ON s.reference_id = r_ref.id
+		WHERE s.tag IN ('depends-on', 'blocked')
+		AND s.reference_id IS NOT NULL AND s.superseded_by IS NULL`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query dependencies: %w", err)
+	}
+	defer rows.Close()
+
+	graph := map[string][]string{}
+	for rows.Next() {
+		var from, to *string
+		if err := rows.Scan(&from, &to); err != nil {
+			return nil, fmt.Errorf("scan dependency: %w", err)
+		}
+		if from == nil || to == nil || *from == *to {
+			continue
+		}
+		graph[*from] = append(graph[*from], *to)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate dependencies: %w", err)
+	}
+	for id, deps := range graph {
+		sort.Strings(deps)
+		graph[id] = deps
+	}
+	return graph, nil
+}
+
+// dependencyPath returns the shortest path of thread IDs from one thread to
+// another along graph, including both ends, or nil if there is none.
+func dependencyPath(graph map[string][]string, from, to string) []string {
+	prev := map[string]string{from: ""}
+	queue := []string{from}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == to {
+			path := []string{}
+			for ; id != ""; id = prev[id] {
+				path = append([]string{id}, path...)
+			}
+			return path
+		}
+		for _, next := range graph[id] {
+			if _, seen := prev[next]; !seen {
+				prev[next] = id
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// checkDependencyCycle rejects a depends-on or blocked tag from threadID on
+// referenceID if the referenced thread already waits on threadID.
+func checkDependencyCycle(ctx context.Context, db dbtx, threadID, tag string, referenceID *string) error {
+	if (tag != "depends-on" && tag != statusBlocked) || referenceID == nil {
+		return nil
+	}
+	refs, err := referencedThreads(ctx, db, []string{*referenceID})
+	if err != nil {
+		return err
+	}
+	if len(refs) == 0 || refs[0].ID == threadID {
+		return nil
+	}
+	graph, err := dependencyGraph(ctx, db)
+	if err != nil {
+		return err
+	}
+	path := dependencyPath(graph, refs[0].ID, threadID)
+	if path == nil {
+		return nil
+	}
+	return conflictError(fmt.Sprintf("%s would create a dependency cycle: %s",
+		tag, strings.Join(append([]string{threadID}, path...), " → ")))
+}
+
+// dependencyCycles returns one cycle for each set of threads that wait on
+// one another, leaving out cycles through threads agent can't read. Each
+// thread in a cycle waits on the next, and the last on the first.
+func dependencyCycles(ctx context.Context, db *sql.DB, agent *Agent) ([][]DependencyNode, error) {
+	graph, err := dependencyGraph(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	cycles := [][]DependencyNode{}
+	for _, component := range stronglyConnected(graph) {
+		if len(component) < 2 {
+			continue
+		}
+		// Walk the shortest way round from the lowest ID, staying inside
+		// the component.
+		inside := map[string]bool{}
+		for _, id := range component {
+			inside[id] = true
+		}
+		sub := map[string][]string{}
+		for _, id := range component {
+			for _, next := range graph[id] {
+				if inside[next] {
+					sub[id] = append(sub[id], next)
+				}
+			}
+		}
+		start := component[0]
+		var ids []string
+		for _, next := range sub[start] {
+			if p := dependencyPath(sub, next, start); p != nil && (ids == nil || len(p) < len(ids)) {
+				ids = p
+			}
+		}
+		ids = append([]string{start}, ids[:len(ids)-1]...)
+
+		hidden := false
+		for _, id := range ids {
+			visible, err := threadVisible(ctx, db, agent, id)
+			if err != nil {
+				return nil, err
+			}
+			hidden = hidden || !visible
+		}
+		if hidden {
+			continue
+		}
+
+		nodes, err := referencedThreads(ctx, db, ids)
+		if err != nil {
+			return nil, err
+		}
+		cycles = append(cycles, nodes)
+	}
+	return cycles, nil
+}
+
+// stronglyConnected returns the strongly connected components of graph
+// using Tarjan's algorithm, each sorted by ID, in order of their lowest ID.
+func stronglyConnected(graph map[string][]string) [][]string {
+	ids := make([]string, 0, len(graph))
+	for id := range graph {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	index := map[string]int{}
+	low := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var components [][]string
+
+	var visit func(id string)
+	visit = func(id string) {
+		index[id] = len(index)
+		low[id] = index[id]
+		stack = append(stack, id)
+		onStack[id] = true
+		for _, next := range graph[id] {
+			if _, seen := index[next]; !seen {
+				visit(next)
+				low[id] = min(low[id], low[next])
+			} else if onStack[next] {
+				low[id] = min(low[id], index[next])
+			}
+		}
+		if low[id] != index[id] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == id {
+				break
+			}
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	for _, id := range ids {
+		if _, seen := index[id]; !seen {
+			visit(id)
+		}
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i][0] < components[j][0] })
+	return components
+}
+
+// handleDependencyCycles lists the dependency cycles between threads.
+func handleDependencyCycles(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	cycles, err := dependencyCycles(r.Context(), db, agent)
+	if err != nil {
+		writeStoreError(w, err, "failed to query dependency cycles")
+		return
+	}
+
+	writeJSONWithETag(w, r, http.StatusOK, map[string]interface{}{
+		"cycles": cycles,
+	})
+}
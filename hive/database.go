@@ -0,0 +1,483 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// InMemory is the DBPath of a database kept in memory instead of a file,
+// for tests and embedding. Each InitDB of it opens a new, empty database,
+// which is gone once its last connection closes.
+const InMemory = ":memory:"
+
+// InitDB opens the database at dbPath, or a new in-memory one if dbPath is
+// InMemory, and migrates it.
+func InitDB(dbPath string, opts SQLiteOptions) (*sql.DB, error) {
+	if err := opts.check(); err != nil {
+		return nil, err
+	}
+	if dbPath == InMemory {
+		// The memdb VFS shares the database between the pool's connections,
+		// where a plain :memory: would give each one its own
+		dbPath = "file:/" + uuid.New().String() + "?vfs=memdb"
+	}
+	// Writers wait for each other rather than failing with SQLITE_BUSY, now
+	// that background workers write alongside requests. A pragma in the DSN
+	// applies to every pooled connection, so foreign keys are enforced on
+	// all of them.
+	db := openTracedDB(opts.dsn(dbPath), opts.BusyRetries)
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+
+	// Enable WAL mode for better concurrent read performance
+	if _, err := db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+		return nil, fmt.Errorf("set WAL mode: %w", err)
+	}
+
+	if err := migrate(db); err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+
+	return db, nil
+}
+
+func migrate(db *sql.DB) error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS agents (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		owner TEXT NOT NULL,
+		api_key_hash TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS threads (
+		id TEXT PRIMARY KEY,
+		agent_id TEXT NOT NULL REFERENCES agents(id),
+		title TEXT NOT NULL,
+		body TEXT NOT NULL,
+		tags TEXT DEFAULT '[]',
+		pinned INTEGER DEFAULT 0,
+		archived INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS replies (
+		id TEXT PRIMARY KEY,
+		thread_id TEXT NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		agent_id TEXT NOT NULL REFERENCES agents(id),
+		body TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS status_tags (
+		id TEXT PRIMARY KEY,
+		thread_id TEXT REFERENCES threads(id) ON DELETE CASCADE,
+		reply_id TEXT REFERENCES replies(id) ON DELETE CASCADE,
+		agent_id TEXT NOT NULL REFERENCES agents(id),
+		tag TEXT NOT NULL CHECK(tag IN ('acknowledged','depends-on','blocked','resolved','in-progress','needs-review')),
+		reference_id TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		CHECK(
+			(thread_id IS NOT NULL AND reply_id IS NULL) OR
+			(thread_id IS NULL AND reply_id IS NOT NULL)
+		)
+	);
+
+	CREATE TABLE IF NOT EXISTS announcements (
+		id TEXT PRIMARY KEY,
+		title TEXT NOT NULL,
+		body TEXT NOT NULL,
+		active INTEGER DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS thread_templates (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		title_pattern TEXT NOT NULL DEFAULT '{title}',
+		body TEXT NOT NULL DEFAULT '',
+		tags TEXT NOT NULL DEFAULT '[]',
+		default_status TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS tag_rules (
+		id TEXT PRIMARY KEY,
+		tag TEXT NOT NULL,
+		keywords TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS inbound_sources (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		kind TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		agent_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+		last_received_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS inbound_threads (
+		source_id TEXT NOT NULL REFERENCES inbound_sources(id) ON DELETE CASCADE,
+		external_key TEXT NOT NULL,
+		thread_id TEXT NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		PRIMARY KEY (source_id, external_key)
+	);
+
+	CREATE TABLE IF NOT EXISTS discord_channels (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		webhook_url TEXT NOT NULL,
+		workspace_id TEXT NOT NULL DEFAULT '',
+		events TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS email_preferences (
+		owner TEXT PRIMARY KEY,
+		email TEXT NOT NULL,
+		immediate INTEGER NOT NULL DEFAULT 1,
+		digest INTEGER NOT NULL DEFAULT 1,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS email_state (
+		name TEXT PRIMARY KEY,
+		sent_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS content_filters (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		pattern TEXT NOT NULL DEFAULT '',
+		max_links INTEGER NOT NULL DEFAULT 0,
+		action TEXT NOT NULL,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS quarantine (
+		id TEXT PRIMARY KEY,
+		kind TEXT NOT NULL,
+		agent_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+		target_id TEXT NOT NULL DEFAULT '',
+		body TEXT NOT NULL,
+		payload TEXT NOT NULL DEFAULT '{}',
+		filter_name TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS admins (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_login_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS admin_recovery_codes (
+		admin_id TEXT NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+		code_hash TEXT NOT NULL,
+		used_at DATETIME,
+		PRIMARY KEY (admin_id, code_hash)
+	);
+
+	CREATE TABLE IF NOT EXISTS users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL UNIQUE,
+		password_hash TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS mentions (
+		id TEXT PRIMARY KEY,
+		agent_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+		thread_id TEXT NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		reply_id TEXT REFERENCES replies(id) ON DELETE CASCADE,
+		mentioned_by TEXT NOT NULL REFERENCES agents(id),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS subscriptions (
+		agent_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+		thread_id TEXT NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (agent_id, thread_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS notifications (
+		id TEXT PRIMARY KEY,
+		agent_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+		kind TEXT NOT NULL,
+		thread_id TEXT NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		reply_id TEXT REFERENCES replies(id) ON DELETE CASCADE,
+		status_id TEXT REFERENCES status_tags(id) ON DELETE SET NULL,
+		actor_id TEXT NOT NULL REFERENCES agents(id),
+		read_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS votes (
+		thread_id TEXT NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		agent_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+		value INTEGER NOT NULL CHECK(value IN (-1, 1)),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (thread_id, agent_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS attachments (
+		id TEXT PRIMARY KEY,
+		thread_id TEXT NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		reply_id TEXT REFERENCES replies(id) ON DELETE CASCADE,
+		agent_id TEXT NOT NULL REFERENCES agents(id),
+		filename TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		sha256 TEXT NOT NULL,
+		data BLOB NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS thread_references (
+		id TEXT PRIMARY KEY,
+		thread_id TEXT NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		reply_id TEXT REFERENCES replies(id) ON DELETE CASCADE,
+		target_thread_id TEXT NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		target_reply_id TEXT REFERENCES replies(id) ON DELETE CASCADE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS thread_reads (
+		agent_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+		thread_id TEXT NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		last_read_at DATETIME NOT NULL,
+		PRIMARY KEY (agent_id, thread_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS thread_participants (
+		thread_id TEXT NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		agent_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+		added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (thread_id, agent_id)
+	);
+
+	CREATE TABLE IF NOT EXISTS workspaces (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS messages (
+		id TEXT PRIMARY KEY,
+		sender_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+		recipient_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+		body TEXT NOT NULL,
+		read_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+		agent_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+		key TEXT NOT NULL,
+		request_hash TEXT NOT NULL,
+		status INTEGER NOT NULL DEFAULT 0,
+		content_type TEXT NOT NULL DEFAULT '',
+		body BLOB,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (agent_id, key)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_threads_agent ON threads(agent_id);
+	CREATE INDEX IF NOT EXISTS idx_threads_created ON threads(created_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_replies_thread ON replies(thread_id);
+	CREATE INDEX IF NOT EXISTS idx_status_tags_thread ON status_tags(thread_id);
+	CREATE INDEX IF NOT EXISTS idx_status_tags_reply ON status_tags(reply_id);
+	CREATE INDEX IF NOT EXISTS idx_status_tags_tag ON status_tags(tag);
+	CREATE INDEX IF NOT EXISTS idx_mentions_agent ON mentions(agent_id, created_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_mentions_thread ON mentions(thread_id);
+	CREATE INDEX IF NOT EXISTS idx_mentions_reply ON mentions(reply_id);
+	CREATE INDEX IF NOT EXISTS idx_subscriptions_thread ON subscriptions(thread_id);
+	CREATE INDEX IF NOT EXISTS idx_thread_reads_thread ON thread_reads(thread_id);
+	CREATE INDEX IF NOT EXISTS idx_notifications_agent ON notifications(agent_id, created_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_attachments_thread ON attachments(thread_id);
+	CREATE INDEX IF NOT EXISTS idx_attachments_sha256 ON attachments(sha256);
+	CREATE INDEX IF NOT EXISTS idx_thread_references_thread ON thread_references(thread_id);
+	CREATE INDEX IF NOT EXISTS idx_thread_references_reply ON thread_references(reply_id);
+	CREATE INDEX IF NOT EXISTS idx_thread_references_target ON thread_references(target_thread_id);
+	CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys(created_at);
+	CREATE INDEX IF NOT EXISTS idx_thread_participants_agent ON thread_participants(agent_id);
+	CREATE INDEX IF NOT EXISTS idx_messages_recipient ON messages(recipient_id, created_at DESC);
+	CREATE INDEX IF NOT EXISTS idx_messages_sender ON messages(sender_id, created_at DESC);
+	`
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema. ALTER TABLE ADD COLUMN has no
+	// IF NOT EXISTS in SQLite, so each is applied only when missing.
+	columns := []struct {
+		table, column, definition string
+	}{
+		{"replies", "parent_reply_id", "TEXT REFERENCES replies(id) ON DELETE SET NULL"},
+		{"agents", "scopes", `TEXT NOT NULL DEFAULT '["read","write"]'`},
+		{"agents", "previous_key_hash", "TEXT NOT NULL DEFAULT ''"},
+		{"agents", "previous_key_expires_at", "DATETIME"},
+		{"agents", "key_rotated_at", "DATETIME"},
+		{"agents", "key_expires_at", "DATETIME"},
+		{"agents", "key_id", "TEXT NOT NULL DEFAULT ''"},
+		{"agents", "previous_key_id", "TEXT NOT NULL DEFAULT ''"},
+		{"agents", "role", "TEXT NOT NULL DEFAULT 'worker'"},
+		{"agents", "heartbeat_at", "DATETIME"},
+		{"agents", "status_text", "TEXT NOT NULL DEFAULT ''"},
+		{"agents", "capabilities", "TEXT NOT NULL DEFAULT '[]'"},
+		{"agents", "model", "TEXT NOT NULL DEFAULT ''"},
+		{"agents", "toolset", "TEXT NOT NULL DEFAULT '[]'"},
+		{"agents", "description", "TEXT NOT NULL DEFAULT ''"},
+		{"threads", "archived_at", "DATETIME"},
+		{"threads", "locked", "INTEGER NOT NULL DEFAULT 0"},
+		{"status_tags", "superseded_by", "TEXT"},
+		{"threads", "due_at", "DATETIME"},
+		{"threads", "priority", "TEXT NOT NULL DEFAULT 'normal'"},
+		{"threads", "publish_at", "DATETIME"},
+		{"threads", "merged_into", "TEXT REFERENCES threads(id) ON DELETE SET NULL"},
+		{"threads", "visibility", "TEXT NOT NULL DEFAULT 'public'"},
+		{"agents", "workspace_id", "TEXT NOT NULL DEFAULT 'default'"},
+		{"threads", "workspace_id", "TEXT NOT NULL DEFAULT 'default'"},
+		{"announcements", "workspace_id", "TEXT NOT NULL DEFAULT ''"},
+		{"announcements", "agent_id", "TEXT REFERENCES agents(id) ON DELETE SET NULL"},
+		{"announcements", "target_teams", "TEXT NOT NULL DEFAULT '[]'"},
+		{"announcements", "target_capabilities", "TEXT NOT NULL DEFAULT '[]'"},
+		{"announcements", "expires_at", "DATETIME"},
+		{"admins", "totp_secret", "TEXT NOT NULL DEFAULT ''"},
+		{"admins", "totp_enabled", "INTEGER NOT NULL DEFAULT 0"},
+		{"admins", "totp_last_counter", "INTEGER NOT NULL DEFAULT 0"},
+		{"users", "disabled_at", "DATETIME"},
+		{"users", "must_change_password", "INTEGER NOT NULL DEFAULT 0"},
+		{"users", "role", "TEXT NOT NULL DEFAULT 'viewer'"},
+		{"agents", "kind", "TEXT NOT NULL DEFAULT 'agent'"},
+		{"agents", "user_id", "TEXT REFERENCES users(id) ON DELETE SET NULL"},
+		{"threads", "last_activity_at", "DATETIME"},
+	}
+	for _, c := range columns {
+		if err := addColumnIfMissing(db, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	indexes := `
+	CREATE INDEX IF NOT EXISTS idx_replies_parent ON replies(parent_reply_id);
+	CREATE INDEX IF NOT EXISTS idx_agents_key_id ON agents(key_id);
+	CREATE INDEX IF NOT EXISTS idx_agents_previous_key_id ON agents(previous_key_id);
+	CREATE INDEX IF NOT EXISTS idx_status_tags_superseded ON status_tags(superseded_by);
+	CREATE INDEX IF NOT EXISTS idx_threads_due ON threads(due_at);
+	CREATE INDEX IF NOT EXISTS idx_threads_publish ON threads(publish_at);
+	CREATE INDEX IF NOT EXISTS idx_threads_merged ON threads(merged_into);
+	CREATE INDEX IF NOT EXISTS idx_agents_workspace ON agents(workspace_id);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_agents_user ON agents(user_id);
+	CREATE INDEX IF NOT EXISTS idx_threads_workspace ON threads(workspace_id);
+	CREATE INDEX IF NOT EXISTS idx_threads_last_activity ON threads(last_activity_at);
+	`
+	if _, err := db.Exec(indexes); err != nil {
+		return err
+	}
+	// Agents and threads from before workspaces live in the default one
+	if _, err := db.Exec("INSERT INTO workspaces (id, name) VALUES (?, ?) ON CONFLICT DO NOTHING", defaultWorkspaceID, defaultWorkspaceID); err != nil {
+		return fmt.Errorf("create default workspace: %w", err)
+	}
+	if err := migrateChanges(db); err != nil {
+		return err
+	}
+	if _, err := db.Exec(lastActivityTriggers); err != nil {
+		return fmt.Errorf("create last activity triggers: %w", err)
+	}
+	if err := backfillLastActivity(db); err != nil {
+		return err
+	}
+	if _, err := db.Exec(threadTagsSchema); err != nil {
+		return fmt.Errorf("create thread tags: %w", err)
+	}
+	if err := backfillThreadTags(db); err != nil {
+		return err
+	}
+	if _, err := db.Exec(searchIndexSchema); err != nil {
+		return fmt.Errorf("create search index: %w", err)
+	}
+	if err := backfillSearchIndex(db); err != nil {
+		return err
+	}
+	if _, err := db.Exec(embeddingsSchema); err != nil {
+		return fmt.Errorf("create embeddings: %w", err)
+	}
+	if _, err := db.Exec(summariesSchema); err != nil {
+		return fmt.Errorf("create thread summaries: %w", err)
+	}
+	if _, err := db.Exec(slaSchema); err != nil {
+		return fmt.Errorf("create sla policies: %w", err)
+	}
+	if _, err := db.Exec(maintenanceSchema); err != nil {
+		return fmt.Errorf("create maintenance runs: %w", err)
+	}
+	if _, err := db.Exec(usageSchema); err != nil {
+		return fmt.Errorf("create agent usage: %w", err)
+	}
+	if _, err := db.Exec(adminSessionsSchema); err != nil {
+		return fmt.Errorf("create admin sessions: %w", err)
+	}
+	if _, err := db.Exec(passwordResetsSchema); err != nil {
+		return fmt.Errorf("create password resets: %w", err)
+	}
+	if _, err := db.Exec(loginFailuresSchema); err != nil {
+		return fmt.Errorf("create login failures: %w", err)
+	}
+	if _, err := db.Exec(userSessionsSchema); err != nil {
+		return fmt.Errorf("create user sessions: %w", err)
+	}
+	if _, err := db.Exec(leasesSchema); err != nil {
+		return fmt.Errorf("create leases: %w", err)
+	}
+	return backfillSuperseded(context.Background(), db)
+}
+
+// addColumnIfMissing adds a column to table unless it already exists.
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return fmt.Errorf("scan %s columns: %w", table, err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate %s columns: %w", table, err)
+	}
+	rows.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("add %s.%s: %w", table, column, err)
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Dependent is a thread, or a reply in one, that waits on another thread
+// through a depends-on or blocked tag in effect.
+type Dependent struct {
+	// StatusID and Status are the depends-on or blocked tag.
+	StatusID string `json:"status_id"`
+	Status   string `json:"status"`
+	// ThreadID and ThreadTitle are the waiting thread, or the thread of the
+	// waiting reply ReplyID.
+	ThreadID    string  `json:"thread_id"`
+	ThreadTitle string  `json:"thread_title"`
+	ReplyID     *string `json:"reply_id,omitempty"`
+	// CurrentStatus is the waiting thread's current status.
+	CurrentStatus string `json:"current_status"`
+	// ReferenceID is what the tag references: the thread itself or one of
+	// its replies.
+	ReferenceID string `json:"reference_id"`
+	// AgentID and AgentName are the agent that added the tag.
+	AgentID   string    `json:"agent_id"`
+	AgentName string    `json:"agent_name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// threadDependents returns what waits on a thread or its replies through
+// depends-on and blocked tags in effect, newest first, leaving out threads
+// agent can't read.
+func threadDependents(ctx context.Context, db *sql.DB, agent *Agent, threadID string) ([]Dependent, error) {
+	visible, args := visibleStatusCondition(agent)
+	rows, err := db.QueryContext(ctx,
+		`SELECT s.id, s.tag, t.id, t.title, s.reply_id, s.reference_id, s.agent_id, a.name, s.created_at,
+			`+currentStatusColumn+`
+		FROM status_tags s
+		JOIN agents a ON s.agent_id = a.id
+		JOIN threads t ON t.id = COALESCE(s.thread_id, (SELECT r.thread_id FROM replies r WHERE r.id = s.reply_id))
+		WHERE s.tag IN ('depends-on', 'blocked') AND s.superseded_by IS NULL
+		AND (s.reference_id = ? OR s.reference_id IN (SELECT r.id FROM replies r WHERE r.thread_id = ?))
+		AND `+visible+`
+		ORDER BY s.created_at DESC`, append([]interface{}{threadID, threadID}, args...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query dependents: %w", err)
+	}
+	defer rows.Close()
+
+	dependents := []Dependent{}
+	for rows.Next() {
+		var d Dependent
+		if err := rows.Scan(&d.StatusID, &d.Status, &d.ThreadID, &d.ThreadTitle, &d.ReplyID, &d.ReferenceID, &d.AgentID, &d.AgentName, &d.CreatedAt, &d.CurrentStatus); err != nil {
+			return nil, fmt.Errorf("scan dependent: %w", err)
+		}
+		dependents = append(dependents, d)
+	}
+	return dependents, rows.Err()
+}
+
+// handleThreadDependents lists what waits on a thread the requesting agent
+// can read, so an agent finishing it knows whom to tell.
+func handleThreadDependents(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if err := requireVisible(r.Context(), db, agent, threadID); err != nil {
+		writeStoreError(w, err, "failed to query thread")
+		return
+	}
+
+	dependents, err := threadDependents(r.Context(), db, agent, threadID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query dependents"})
+		return
+	}
+	writeJSONWithETag(w, r, http.StatusOK, dependents)
+}
+
+// propagateResolution tells whoever waits on a thread that st resolved it:
+// for each depends-on or blocked tag in effect on another thread, or a
+// reply in one, that references the thread or one of its replies, the
+// tag's author and the waiting thread's author are notified and a
+// dependency.resolved event carries the tag. With unblock, the blocked
+// tags among them are superseded by st, as a thread's own blocked tags
+// are when it is resolved.
+func propagateResolution(ctx context.Context, db dbtx, bus publisher, agent *Agent, threadID string, st StatusTag, unblock bool) error {
+	rows, err := db.QueryContext(ctx,
+		`SELECT s.id, s.thread_id, s.reply_id, w.id, w.agent_id, s.agent_id, a.name, s.tag, s.reference_id, s.created_at
+		FROM status_tags s
+		JOIN agents a ON s.agent_id = a.id
+		JOIN threads w ON w.id = COALESCE(s.thread_id, (SELECT r.thread_id FROM replies r WHERE r.id = s.reply_id))
+		WHERE s.tag IN ('depends-on', 'blocked') AND s.superseded_by IS NULL AND w.id != ?
+		AND (s.reference_id = ? OR s.reference_id IN (SELECT r.id FROM replies r WHERE r.thread_id = ?))
+		ORDER BY s.created_at`, threadID, threadID, threadID,
+	)
+	if err != nil {
+		return fmt.Errorf("query dependents: %w", err)
+	}
+	type waiting struct {
+		threadID, authorID string
+		tag                StatusTag
+	}
+	var dependents []waiting
+	for rows.Next() {
+		var d waiting
+		if err := rows.Scan(&d.tag.ID, &d.tag.ThreadID, &d.tag.ReplyID, &d.threadID, &d.authorID, &d.tag.AgentID, &d.tag.AgentName, &d.tag.Tag, &d.tag.ReferenceID, &d.tag.CreatedAt); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan dependent: %w", err)
+		}
+		dependents = append(dependents, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate dependents: %w", err)
+	}
+
+	for _, d := range dependents {
+		if unblock && d.tag.Tag == statusBlocked {
+			if _, err := db.ExecContext(ctx, "UPDATE status_tags SET superseded_by = ? WHERE id = ?", st.ID, d.tag.ID); err != nil {
+				return fmt.Errorf("supersede blocked tag: %w", err)
+			}
+			d.tag.SupersededBy = &st.ID
+		}
+		recipients := []string{d.tag.AgentID}
+		if d.authorID != d.tag.AgentID {
+			recipients = append(recipients, d.authorID)
+		}
+		for _, recipient := range recipients {
+			if recipient == agent.ID {
+				continue
+			}
+			if err := notifyDependent(ctx, db, recipient, d.threadID, d.tag.ReplyID, st.ID, agent.ID); err != nil {
+				return err
+			}
+		}
+		bus.Publish(Event{Kind: eventDependencyResolved, ThreadID: d.threadID, Status: &d.tag, CreatedAt: st.CreatedAt})
+	}
+	return nil
+}
+
+// notifyDependent notifies an agent that the thread one of its threads, or
+// a reply in it, waited on was resolved by the status tag statusID, if the
+// agent can read its thread.
+func notifyDependent(ctx context.Context, db dbtx, agentID, threadID string, replyID *string, statusID, actorID string) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO notifications (id, agent_id, kind, thread_id, reply_id, status_id, actor_id, created_at)
+		SELECT ?, v.id, ?, t.id, ?, ?, ?, ?
+		FROM agents v, threads t
+		WHERE v.id = ? AND t.id = ? AND `+readerCondition,
+		uuid.New().String(), notificationUnblocked, replyID, statusID, actorID, time.Now(), agentID, threadID,
+	)
+	if err != nil {
+		return fmt.Errorf("insert notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,411 @@
+package hive
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Discord notifications let teams supervise their agents from Discord.
+// Admins add Discord channels in the admin panel by their webhook URLs,
+// each for one workspace or all of them and for some event kinds, and
+// every such event on a published, public thread is posted to the channel
+// as an embed linking to the thread on the dashboard at PUBLIC_URL.
+
+// discordEventKinds are the events a channel can be sent, in the order the
+// admin panel offers them.
+var discordEventKinds = []string{eventThreadCreated, eventReplyCreated, eventStatusCreated, eventThreadMerged}
+
+// discordTimeout bounds each post to Discord.
+const discordTimeout = 10 * time.Second
+
+// maxDiscordDescription caps the excerpt of a post in an embed. Discord
+// allows 4096 characters.
+const maxDiscordDescription = 500
+
+// maxDiscordRetryAfter caps how long a rate-limited post waits to retry.
+const maxDiscordRetryAfter = 30 * time.Second
+
+// Embed colors by event kind.
+var discordColors = map[string]int{
+	eventThreadCreated: 0x5865f2,
+	eventReplyCreated:  0x99aab5,
+	eventStatusCreated: 0xfee75c,
+	eventThreadMerged:  0xeb459e,
+}
+
+// DiscordChannel is a Discord channel webhook that events are posted to.
+type DiscordChannel struct {
+	ID         string
+	Name       string
+	WebhookURL string
+	// WorkspaceID limits the channel to one workspace's threads; empty
+	// means every workspace.
+	WorkspaceID string
+	// Events are the event kinds posted.
+	Events    []string
+	CreatedAt time.Time
+}
+
+// wants reports whether the channel is sent events of kind.
+func (ch DiscordChannel) wants(kind string) bool {
+	return slices.Contains(ch.Events, kind)
+}
+
+// createDiscordChannel adds a Discord channel.
+func createDiscordChannel(ctx context.Context, db *sql.DB, name, webhookURL, workspaceID string, events []string) (DiscordChannel, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return DiscordChannel{}, inputError("name is required")
+	}
+	if u, err := url.Parse(webhookURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || !strings.Contains(u.Path, "/api/webhooks/") {
+		return DiscordChannel{}, inputError("webhook URL must be a Discord webhook URL (https://discord.com/api/webhooks/...)")
+	}
+	if len(events) == 0 {
+		return DiscordChannel{}, inputError("choose at least one event")
+	}
+	for _, kind := range events {
+		if !slices.Contains(discordEventKinds, kind) {
+			return DiscordChannel{}, inputError(fmt.Sprintf("unknown event %q", kind))
+		}
+	}
+	if workspaceID != "" {
+		var err error
+		if workspaceID, err = resolveWorkspace(ctx, db, workspaceID); err != nil {
+			return DiscordChannel{}, err
+		}
+	}
+
+	eventsJSON, err := json.Marshal(events)
+	if err != nil {
+		return DiscordChannel{}, fmt.Errorf("marshal discord events: %w", err)
+	}
+	ch := DiscordChannel{
+		ID: uuid.New().String(), Name: name, WebhookURL: webhookURL, WorkspaceID: workspaceID,
+		Events: events, CreatedAt: time.Now(),
+	}
+	_, err = db.ExecContext(ctx,
+		"INSERT INTO discord_channels (id, name, webhook_url, workspace_id, events, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		ch.ID, ch.Name, ch.WebhookURL, ch.WorkspaceID, string(eventsJSON), ch.CreatedAt)
+	if err != nil {
+		return DiscordChannel{}, fmt.Errorf("insert discord channel: %w", err)
+	}
+	return ch, nil
+}
+
+// listDiscordChannels returns the Discord channels by name.
+func listDiscordChannels(ctx context.Context, db *sql.DB) ([]DiscordChannel, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT id, name, webhook_url, workspace_id, events, created_at FROM discord_channels ORDER BY name, created_at")
+	if err != nil {
+		return nil, fmt.Errorf("query discord channels: %w", err)
+	}
+	defer rows.Close()
+
+	channels := []DiscordChannel{}
+	for rows.Next() {
+		var ch DiscordChannel
+		var events string
+		if err := rows.Scan(&ch.ID, &ch.Name, &ch.WebhookURL, &ch.WorkspaceID, &events, &ch.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan discord channel: %w", err)
+		}
+		if err := json.Unmarshal([]byte(events), &ch.Events); err != nil {
+			return nil, fmt.Errorf("decode discord channel events: %w", err)
+		}
+		channels = append(channels, ch)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate discord channels: %w", err)
+	}
+	return channels, nil
+}
+
+// Discord posts events to the Discord channels admins have added.
+type Discord struct {
+	db        *sql.DB
+	publicURL string
+	client    *http.Client
+}
+
+func NewDiscord(db *sql.DB, cfg Config) *Discord {
+	return &Discord{
+		db:        db,
+		publicURL: strings.TrimSuffix(cfg.PublicURL, "/"),
+		client:    &http.Client{Timeout: discordTimeout},
+	}
+}
+
+// Start posts events to Discord as they're published until ctx is done.
+// Events are posted one at a time, so a slow or rate-limited channel delays
+// the rest; events published meanwhile beyond the bus's buffer are dropped.
+func (d *Discord) Start(ctx context.Context, bus *EventBus) {
+	events, unsubscribe := bus.SubscribeLocal()
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if err := d.notify(ctx, e); err != nil && ctx.Err() == nil {
+					log.Printf("discord: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// discordThread is what an embed shows of the thread an event is on.
+type discordThread struct {
+	title, workspaceID, visibility string
+}
+
+// notify posts e to the channels that want it.
+func (d *Discord) notify(ctx context.Context, e Event) error {
+	channels, err := listDiscordChannels(ctx, d.db)
+	if err != nil {
+		return err
+	}
+	var wanted []DiscordChannel
+	for _, ch := range channels {
+		if ch.wants(e.Kind) {
+			wanted = append(wanted, ch)
+		}
+	}
+	if len(wanted) == 0 {
+		return nil
+	}
+
+	var t discordThread
+	var publishAt *time.Time
+	err = d.db.QueryRowContext(ctx,
+		"SELECT title, workspace_id, visibility, publish_at FROM threads WHERE id = ?", e.ThreadID,
+	).Scan(&t.title, &t.workspaceID, &t.visibility, &publishAt)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("query thread %s: %w", e.ThreadID, err)
+	}
+	// Discord is outside the forum: only what the public dashboard shows
+	// goes there
+	if publishAt != nil || (t.visibility != visibilityPublic && t.visibility != "") {
+		return nil
+	}
+
+	embed := d.embed(e, t.title)
+	for _, ch := range wanted {
+		if ch.WorkspaceID != "" && ch.WorkspaceID != t.workspaceID {
+			continue
+		}
+		if err := d.post(ctx, ch.WebhookURL, embed); err != nil {
+			log.Printf("discord: post %s to %s: %v", e.Kind, ch.Name, err)
+		}
+	}
+	return nil
+}
+
+// embed renders an event on the thread titled title as a Discord embed.
+func (d *Discord) embed(e Event, title string) map[string]interface{} {
+	embed := map[string]interface{}{
+		"color":     discordColors[e.Kind],
+		"timestamp": e.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	var author, description string
+	switch {
+	case e.Kind == eventThreadCreated && e.Thread != nil:
+		author = e.Thread.AgentName
+		embed["title"] = title
+		description = e.Thread.Body
+		var fields []map[string]interface{}
+		if e.Thread.Priority != "" && e.Thread.Priority != "normal" {
+			fields = append(fields, map[string]interface{}{"name": "Priority", "value": e.Thread.Priority, "inline": true})
+		}
+		if len(e.Thread.Tags) > 0 {
+			fields = append(fields, map[string]interface{}{"name": "Tags", "value": strings.Join(e.Thread.Tags, ", "), "inline": true})
+		}
+		if fields != nil {
+			embed["fields"] = fields
+		}
+	case e.Kind == eventReplyCreated && e.Reply != nil:
+		author = e.Reply.AgentName
+		embed["title"] = "Re: " + title
+		description = e.Reply.Body
+	case e.Kind == eventStatusCreated && e.Status != nil:
+		author = e.Status.AgentName
+		embed["title"] = title
+		description = fmt.Sprintf("Tagged **%s**", e.Status.Tag)
+		if e.Status.ReplyID != nil {
+			description += " on a reply"
+		}
+	case e.Kind == eventThreadMerged:
+		embed["title"] = title
+		description = "Merged into another thread."
+	default:
+		embed["title"] = title
+	}
+	if len(description) > maxDiscordDescription {
+		description = strings.ToValidUTF8(description[:maxDiscordDescription], "") + "…"
+	}
+	embed["description"] = description
+	if author != "" {
+		embed["author"] = map[string]string{"name": author}
+	}
+	if d.publicURL != "" {
+		threadID := e.ThreadID
+		if e.Kind == eventThreadMerged && e.Thread != nil && e.Thread.MergedInto != nil {
+			threadID = *e.Thread.MergedInto
+		}
+		embed["url"] = d.publicURL + "/dashboard/threads/" + threadID
+	}
+	return embed
+}
+
+// post sends an embed to a Discord webhook, retrying once if Discord asks
+// it to wait.
+func (d *Discord) post(ctx context.Context, webhookURL string, embed map[string]interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"username": "Agentic Forum",
+		"embeds":   []map[string]interface{}{embed},
+		// Never ping anyone from forum content
+		"allowed_mentions": map[string]interface{}{"parse": []string{}},
+	})
+	if err != nil {
+		return fmt.Errorf("marshal discord message: %w", err)
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("build discord request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return err
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if resp.StatusCode/100 == 2 {
+			return nil
+		}
+		if resp.StatusCode != http.StatusTooManyRequests || attempt > 0 {
+			return fmt.Errorf("discord returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+		}
+
+		var limit struct {
+			RetryAfter float64 `json:"retry_after"`
+		}
+		json.Unmarshal(msg, &limit)
+		wait := min(time.Duration(limit.RetryAfter*float64(time.Second)), maxDiscordRetryAfter)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// handleAdminDiscord lists the Discord channels.
+func handleAdminDiscord(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	channels, err := listDiscordChannels(r.Context(), db)
+	if err != nil {
+		log.Printf("admin discord query error: %v", err)
+		http.Error(w, "failed to load discord channels", http.StatusInternalServerError)
+		return
+	}
+	workspaces, err := listWorkspaces(r.Context(), db)
+	if err != nil {
+		log.Printf("admin discord workspaces query error: %v", err)
+		http.Error(w, "failed to load workspaces", http.StatusInternalServerError)
+		return
+	}
+
+	renderAdminTemplate(w, r, "discord.html", map[string]interface{}{
+		"Channels":       channels,
+		"Workspaces":     workspaces,
+		"WorkspaceNames": workspaceNames(workspaces),
+		"EventKinds":     discordEventKinds,
+		"PublicURL":      cfg.PublicURL,
+		"Tested":         r.URL.Query().Get("tested"),
+	})
+}
+
+// handleAdminCreateDiscord adds a Discord channel.
+func handleAdminCreateDiscord(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	_, err := createDiscordChannel(r.Context(), db, r.FormValue("name"), strings.TrimSpace(r.FormValue("webhook_url")),
+		r.FormValue("workspace"), r.Form["events"])
+	switch err.(type) {
+	case nil:
+	case inputError, notFoundError:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		log.Printf("admin create discord channel: %v", err)
+		http.Error(w, "failed to create discord channel", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/discord", http.StatusSeeOther)
+}
+
+// handleAdminTestDiscord posts a test message to a Discord channel.
+func handleAdminTestDiscord(db *sql.DB, discord *Discord, w http.ResponseWriter, r *http.Request) {
+	var name, webhookURL string
+	err := db.QueryRowContext(r.Context(), "SELECT name, webhook_url FROM discord_channels WHERE id = ?", r.PathValue("id")).Scan(&name, &webhookURL)
+	if err == sql.ErrNoRows {
+		http.Error(w, "discord channel not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("admin test discord channel: %v", err)
+		http.Error(w, "failed to load discord channel", http.StatusInternalServerError)
+		return
+	}
+
+	embed := map[string]interface{}{
+		"title":       "Test message",
+		"description": "This channel will receive forum events.",
+		"color":       discordColors[eventThreadCreated],
+	}
+	if err := discord.post(r.Context(), webhookURL, embed); err != nil {
+		http.Error(w, fmt.Sprintf("posting to %s failed: %v", name, err), http.StatusBadGateway)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/discord?tested="+r.PathValue("id"), http.StatusSeeOther)
+}
+
+// handleAdminDeleteDiscord deletes a Discord channel.
+func handleAdminDeleteDiscord(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	channelID := r.PathValue("id")
+	if channelID == "" {
+		http.Error(w, "missing discord channel id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM discord_channels WHERE id = ?", channelID); err != nil {
+		log.Printf("admin delete discord channel error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/discord", http.StatusSeeOther)
+}
@@ -0,0 +1,24 @@
+package hive
+
+import "time"
+
+// overdueCondition matches threads, aliased t, that are past their due date
+// and neither resolved nor archived. Its one parameter is the current time
+// in UTC: due dates are stored in UTC so that they compare as text.
+const overdueCondition = `t.due_at IS NOT NULL AND t.due_at < ? AND t.archived = 0
+	AND NOT EXISTS (SELECT 1 FROM status_tags s WHERE s.thread_id = t.id AND s.tag = 'resolved' AND s.superseded_by IS NULL)`
+
+// utcTime returns t in UTC, or nil if t is nil.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// isOverdue reports whether the thread is past its due date at now and
+// still open: not resolved or archived.
+func (t Thread) isOverdue(now time.Time) bool {
+	return t.DueAt != nil && t.DueAt.Before(now) && t.CurrentStatus != statusResolved && !t.Archived
+}
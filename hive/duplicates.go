@@ -0,0 +1,81 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"sort"
+)
+
+// Agents working in parallel often file the same work item twice. Before a
+// thread is created, the threads its author can read that best match its
+// words in the full-text index are compared with it word for word, and
+// those alike enough are its possible duplicates. Depending on
+// DUPLICATE_THREADS they're returned with the new thread, or the thread is
+// refused with 409 until its author retries with ?force=true.
+
+// Duplicate thread policies.
+const (
+	duplicatesWarn   = "warn"
+	duplicatesReject = "reject"
+	duplicatesOff    = "off"
+)
+
+var validDuplicatePolicies = map[string]bool{
+	duplicatesWarn:   true,
+	duplicatesReject: true,
+	duplicatesOff:    true,
+}
+
+// duplicateThreshold is the similarity, from 0 to 1, at which an existing
+// thread is a possible duplicate.
+const duplicateThreshold = 0.6
+
+// maxDuplicateCandidates caps the threads compared with a new one, and
+// maxDuplicates the possible duplicates reported.
+const (
+	maxDuplicateCandidates = 20
+	maxDuplicates          = 5
+)
+
+// findDuplicates returns the published threads agent can read that closely
+// resemble a new thread with title and body, most alike first, each with
+// its similarity as its score.
+func findDuplicates(ctx context.Context, db *sql.DB, agent *Agent, title, body string) ([]SemanticResult, error) {
+	counts := threadTermCounts(title, body)
+	candidates, err := matchTerms(ctx, db, agent, relatedTerms(title, body), "", maxDuplicateCandidates)
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates := []SemanticResult{}
+	for _, c := range candidates {
+		similarity := termSimilarity(counts, threadTermCounts(c.Thread.Title, c.Thread.Body))
+		if similarity >= duplicateThreshold {
+			duplicates = append(duplicates, SemanticResult{Thread: c.Thread, Score: math.Round(similarity*1e4) / 1e4})
+		}
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool { return duplicates[i].Score > duplicates[j].Score })
+	if len(duplicates) > maxDuplicates {
+		duplicates = duplicates[:maxDuplicates]
+	}
+	return duplicates, nil
+}
+
+// termSimilarity returns the cosine similarity of two threads' word counts,
+// from 0 for no words in common to 1 for the same words in the same
+// proportions.
+func termSimilarity(a, b map[string]int) float64 {
+	var dot, normA, normB float64
+	for word, n := range a {
+		dot += float64(n * b[word])
+		normA += float64(n * n)
+	}
+	for _, n := range b {
+		normB += float64(n * n)
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(normA*normB)
+}
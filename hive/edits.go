@@ -0,0 +1,269 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// The functions in this file edit and delete forum content for the store,
+// as those in store.go create it.
+
+// ThreadUpdate is the fields of a thread an update sets. Nil fields are
+// left alone.
+type ThreadUpdate struct {
+	Title    *string
+	Body     *string
+	Tags     []string
+	Priority *string
+	// SetDueAt sets the due date to DueAt, clearing it if DueAt is nil.
+	SetDueAt   bool
+	DueAt      *time.Time
+	Visibility *string
+}
+
+// updateThread sets the fields u sets on a thread agent owns and can read,
+// and returns the thread. A quarantined body edit is kept for review and
+// reported as a quarantineError, with nothing else changed.
+func updateThread(ctx context.Context, db dbtx, agent *Agent, threadID string, u ThreadUpdate) (Thread, error) {
+	return inTx(ctx, db, nil, func(tx dbtx, _ publisher) (Thread, error) {
+		visible, visibleArgs := visibleCondition(agent)
+		var ownerID string
+		var scheduled bool
+		err := tx.QueryRowContext(ctx,
+			"SELECT t.agent_id, t.publish_at IS NOT NULL FROM threads t WHERE t.id = ? AND "+visible,
+			append([]interface{}{threadID}, visibleArgs...)...,
+		).Scan(&ownerID, &scheduled)
+		if err == sql.ErrNoRows {
+			return Thread{}, notFoundError("thread not found")
+		}
+		if err != nil {
+			return Thread{}, fmt.Errorf("query thread: %w", err)
+		}
+		if ownerID != agent.ID {
+			return Thread{}, forbiddenError("you can only update your own threads")
+		}
+
+		limits := limitsFrom(ctx)
+		var setClauses []string
+		var args []interface{}
+		if u.Title != nil {
+			if *u.Title == "" {
+				return Thread{}, inputError("title cannot be empty")
+			}
+			if err := checkText("title", *u.Title, limits.MaxTitleLength); err != nil {
+				return Thread{}, err
+			}
+			setClauses = append(setClauses, "title = ?")
+			args = append(args, *u.Title)
+		}
+		if u.Tags != nil {
+			if err := checkTags(limits, u.Tags); err != nil {
+				return Thread{}, err
+			}
+			tagsJSON, err := json.Marshal(u.Tags)
+			if err != nil {
+				return Thread{}, fmt.Errorf("marshal tags: %w", err)
+			}
+			setClauses = append(setClauses, "tags = ?")
+			args = append(args, string(tagsJSON))
+		}
+		if u.Priority != nil {
+			if !validPriorities[*u.Priority] {
+				return Thread{}, inputError("invalid priority (use low, normal, high, or critical)")
+			}
+			setClauses = append(setClauses, "priority = ?")
+			args = append(args, *u.Priority)
+		}
+		if u.SetDueAt {
+			setClauses = append(setClauses, "due_at = ?")
+			args = append(args, utcTime(u.DueAt))
+		}
+		if u.Visibility != nil {
+			if !validVisibilities[*u.Visibility] {
+				return Thread{}, inputError("invalid visibility (use public, participants, or team)")
+			}
+			setClauses = append(setClauses, "visibility = ?")
+			args = append(args, *u.Visibility)
+		}
+		// The body goes last: content filters may quarantine the edit, which
+		// must only happen once everything else is valid
+		var body string
+		if u.Body != nil {
+			if *u.Body == "" {
+				return Thread{}, inputError("body cannot be empty")
+			}
+			if err := checkText("body", *u.Body, limits.MaxBodyLength); err != nil {
+				return Thread{}, err
+			}
+			body, err = checkContent(ctx, tx, agent, quarantinedThreadEdit, threadID, *u.Body, nil)
+			if err != nil {
+				return Thread{}, err
+			}
+			setClauses = append(setClauses, "body = ?")
+			args = append(args, body)
+		}
+		if len(setClauses) == 0 {
+			return Thread{}, inputError("no fields to update")
+		}
+
+		setClauses = append(setClauses, "updated_at = ?")
+		args = append(args, time.Now(), threadID)
+		query := fmt.Sprintf("UPDATE threads SET %s WHERE id = ?", strings.Join(setClauses, ", "))
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return Thread{}, fmt.Errorf("update thread: %w", err)
+		}
+
+		// Scheduled threads record mentions and references when they are
+		// published
+		if u.Body != nil && !scheduled {
+			if err := recordMentions(ctx, tx, threadID, nil, agent.ID, body); err != nil {
+				log.Printf("record thread mentions: %v", err)
+			}
+			if err := recordReferences(ctx, tx, threadID, nil, body); err != nil {
+				log.Printf("record thread references: %v", err)
+			}
+		}
+
+		t, err := scanThread(tx.QueryRowContext(ctx,
+			"SELECT "+threadColumns+`
+			FROM threads t
+			JOIN agents a ON t.agent_id = a.id
+			WHERE t.id = ?`, threadID,
+		))
+		if err != nil {
+			return Thread{}, fmt.Errorf("load updated thread: %w", err)
+		}
+		return t, nil
+	})
+}
+
+// deleteThread deletes a thread agent can read, with its replies and status
+// tags. Only its author or a moderator may.
+func deleteThread(ctx context.Context, db dbtx, agent *Agent, threadID string) error {
+	_, err := inTx(ctx, db, nil, func(tx dbtx, _ publisher) (struct{}, error) {
+		visible, visibleArgs := visibleCondition(agent)
+		var ownerID string
+		err := tx.QueryRowContext(ctx,
+			"SELECT t.agent_id FROM threads t WHERE t.id = ? AND "+visible,
+			append([]interface{}{threadID}, visibleArgs...)...,
+		).Scan(&ownerID)
+		if err == sql.ErrNoRows {
+			return struct{}{}, notFoundError("thread not found")
+		}
+		if err != nil {
+			return struct{}{}, fmt.Errorf("query thread: %w", err)
+		}
+		if ownerID != agent.ID && !agent.Can(permModerate) {
+			return struct{}{}, forbiddenError("you can only delete your own threads")
+		}
+
+		// Replies and status tags go with it
+		if _, err := tx.ExecContext(ctx, "DELETE FROM threads WHERE id = ?", threadID); err != nil {
+			return struct{}{}, fmt.Errorf("delete thread: %w", err)
+		}
+		return struct{}{}, nil
+	})
+	return err
+}
+
+// updateReply replaces the body of a reply agent owns and returns the
+// reply. A quarantined edit is kept for review and reported as a
+// quarantineError.
+func updateReply(ctx context.Context, db dbtx, agent *Agent, replyID, body string) (Reply, error) {
+	return inTx(ctx, db, nil, func(tx dbtx, _ publisher) (Reply, error) {
+		var ownerID string
+		err := tx.QueryRowContext(ctx, "SELECT agent_id FROM replies WHERE id = ?", replyID).Scan(&ownerID)
+		if err == sql.ErrNoRows {
+			return Reply{}, notFoundError("reply not found")
+		}
+		if err != nil {
+			return Reply{}, fmt.Errorf("query reply: %w", err)
+		}
+		if ownerID != agent.ID {
+			return Reply{}, forbiddenError("you can only update your own replies")
+		}
+
+		if body == "" {
+			return Reply{}, inputError("body is required")
+		}
+		if err := checkText("body", body, limitsFrom(ctx).MaxBodyLength); err != nil {
+			return Reply{}, err
+		}
+		body, err = checkContent(ctx, tx, agent, quarantinedReplyEdit, replyID, body, nil)
+		if err != nil {
+			return Reply{}, err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE replies SET body = ?, updated_at = ? WHERE id = ?", body, time.Now(), replyID); err != nil {
+			return Reply{}, fmt.Errorf("update reply: %w", err)
+		}
+
+		reply, err := scanReply(tx.QueryRowContext(ctx, "SELECT "+replyColumns+" "+replyJoins+" WHERE r.id = ?", replyID))
+		if err != nil {
+			return Reply{}, fmt.Errorf("load updated reply: %w", err)
+		}
+		reply.Statuses = []StatusTag{}
+
+		if err := recordMentions(ctx, tx, reply.ThreadID, &reply.ID, agent.ID, reply.Body); err != nil {
+			log.Printf("record reply mentions: %v", err)
+		}
+		if err := recordReferences(ctx, tx, reply.ThreadID, &reply.ID, reply.Body); err != nil {
+			log.Printf("record reply references: %v", err)
+		}
+		return reply, nil
+	})
+}
+
+// deleteReply deletes a reply. Only its author or a moderator may.
+func deleteReply(ctx context.Context, db dbtx, agent *Agent, replyID string) error {
+	_, err := inTx(ctx, db, nil, func(tx dbtx, _ publisher) (struct{}, error) {
+		var ownerID string
+		err := tx.QueryRowContext(ctx, "SELECT agent_id FROM replies WHERE id = ?", replyID).Scan(&ownerID)
+		if err == sql.ErrNoRows {
+			return struct{}{}, notFoundError("reply not found")
+		}
+		if err != nil {
+			return struct{}{}, fmt.Errorf("query reply: %w", err)
+		}
+		if ownerID != agent.ID && !agent.Can(permModerate) {
+			return struct{}{}, forbiddenError("you can only delete your own replies")
+		}
+
+		if _, err := tx.ExecContext(ctx, "DELETE FROM replies WHERE id = ?", replyID); err != nil {
+			return struct{}{}, fmt.Errorf("delete reply: %w", err)
+		}
+		return struct{}{}, nil
+	})
+	return err
+}
+
+// deleteStatus deletes a status tag, restoring any it superseded. Only its
+// author or a moderator may.
+func deleteStatus(ctx context.Context, db dbtx, agent *Agent, statusID string) error {
+	_, err := inTx(ctx, db, nil, func(tx dbtx, _ publisher) (struct{}, error) {
+		var ownerID string
+		err := tx.QueryRowContext(ctx, "SELECT agent_id FROM status_tags WHERE id = ?", statusID).Scan(&ownerID)
+		if err == sql.ErrNoRows {
+			return struct{}{}, notFoundError("status tag not found")
+		}
+		if err != nil {
+			return struct{}{}, fmt.Errorf("query status tag: %w", err)
+		}
+		if ownerID != agent.ID && !agent.Can(permModerate) {
+			return struct{}{}, forbiddenError("you can only delete your own status tags")
+		}
+
+		if err := restoreSuperseded(ctx, tx, statusID); err != nil {
+			return struct{}{}, err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM status_tags WHERE id = ?", statusID); err != nil {
+			return struct{}{}, fmt.Errorf("delete status tag: %w", err)
+		}
+		return struct{}{}, nil
+	})
+	return err
+}
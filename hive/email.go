@@ -0,0 +1,600 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Email keeps people in the loop when they aren't watching the dashboard.
+// Recipients are owners: the owner of a set of agents, or a dashboard user,
+// whose human agent is owned by their username. Each owner with an email
+// address chooses immediate notifications, sent within EMAIL_INTERVAL when
+// one of their agents is mentioned or added to a thread as a participant,
+// and a daily digest of the unresolved threads their agents are working
+// on, sent at EMAIL_DIGEST_HOUR UTC. Admins set any owner's address and
+// preferences in the admin panel; dashboard users set their own.
+
+// maxDigestThreads caps the threads listed in a digest.
+const maxDigestThreads = 50
+
+// Names of the email_state rows recording how far the mailer has got.
+const (
+	emailStateImmediate = "immediate"
+	emailStateDigest    = "digest"
+)
+
+// EmailPreference is where and what an owner is emailed.
+type EmailPreference struct {
+	Owner string
+	Email string
+	// Immediate sends mentions and participant additions as they happen.
+	Immediate bool
+	// Digest sends the daily digest of unresolved threads.
+	Digest    bool
+	UpdatedAt time.Time
+}
+
+// setEmailPreference saves an owner's email address and preferences.
+func setEmailPreference(ctx context.Context, db *sql.DB, p EmailPreference) error {
+	p.Owner = strings.TrimSpace(p.Owner)
+	if p.Owner == "" {
+		return inputError("owner is required")
+	}
+	addr, err := mail.ParseAddress(strings.TrimSpace(p.Email))
+	if err != nil {
+		return inputError("invalid email address")
+	}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO email_preferences (owner, email, immediate, digest, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (owner) DO UPDATE SET email = excluded.email, immediate = excluded.immediate,
+			digest = excluded.digest, updated_at = excluded.updated_at`,
+		p.Owner, addr.Address, p.Immediate, p.Digest, time.Now())
+	if err != nil {
+		return fmt.Errorf("save email preference: %w", err)
+	}
+	return nil
+}
+
+// loadEmailPreference returns an owner's email preference, or nil if they
+// have none.
+func loadEmailPreference(ctx context.Context, db *sql.DB, owner string) (*EmailPreference, error) {
+	var p EmailPreference
+	err := db.QueryRowContext(ctx,
+		"SELECT owner, email, immediate, digest, updated_at FROM email_preferences WHERE owner = ?", owner,
+	).Scan(&p.Owner, &p.Email, &p.Immediate, &p.Digest, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query email preference: %w", err)
+	}
+	return &p, nil
+}
+
+// listEmailPreferences returns every owner's email preference by owner.
+func listEmailPreferences(ctx context.Context, db *sql.DB) ([]EmailPreference, error) {
+	rows, err := db.QueryContext(ctx, "SELECT owner, email, immediate, digest, updated_at FROM email_preferences ORDER BY owner")
+	if err != nil {
+		return nil, fmt.Errorf("query email preferences: %w", err)
+	}
+	defer rows.Close()
+
+	prefs := []EmailPreference{}
+	for rows.Next() {
+		var p EmailPreference
+		if err := rows.Scan(&p.Owner, &p.Email, &p.Immediate, &p.Digest, &p.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan email preference: %w", err)
+		}
+		prefs = append(prefs, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate email preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+// Mailer sends notification and digest emails over SMTP. A nil *Mailer
+// means email is disabled.
+type Mailer struct {
+	db        *sql.DB
+	addr      string
+	auth      smtp.Auth
+	from      string
+	publicURL string
+	interval  time.Duration
+	// digestHour is the hour of the day, UTC, digests are sent.
+	digestHour int
+}
+
+// NewMailer sets up SMTP from cfg, returning nil if SMTP_HOST is unset.
+func NewMailer(db *sql.DB, cfg Config) (*Mailer, error) {
+	if cfg.SMTPHost == "" {
+		return nil, nil
+	}
+	if _, err := mail.ParseAddress(cfg.SMTPFrom); err != nil {
+		return nil, fmt.Errorf("invalid SMTP_FROM %q: %w", cfg.SMTPFrom, err)
+	}
+	if cfg.EmailDigestHour < 0 || cfg.EmailDigestHour > 23 {
+		return nil, fmt.Errorf("invalid EMAIL_DIGEST_HOUR %d (use 0 to 23)", cfg.EmailDigestHour)
+	}
+	m := &Mailer{
+		db:         db,
+		addr:       net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+		from:       cfg.SMTPFrom,
+		publicURL:  strings.TrimSuffix(cfg.PublicURL, "/"),
+		interval:   cfg.EmailInterval,
+		digestHour: cfg.EmailDigestHour,
+	}
+	if cfg.SMTPUsername != "" {
+		m.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return m, nil
+}
+
+// Start sends notifications every interval, and the digest once a day,
+// until ctx is done.
+func (m *Mailer) Start(ctx context.Context) {
+	if m == nil || m.interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			n, err := m.sendNotifications(ctx, time.Now())
+			if err != nil && ctx.Err() == nil {
+				log.Printf("mailer: %v", err)
+			}
+			if n > 0 {
+				log.Printf("mailer: sent %d notification emails", n)
+			}
+			n, err = m.sendDigests(ctx, time.Now())
+			if err != nil && ctx.Err() == nil {
+				log.Printf("mailer: %v", err)
+			}
+			if n > 0 {
+				log.Printf("mailer: sent %d digest emails", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// send emails a plain-text message to one address.
+func (m *Mailer) send(to, subject, body string) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", m.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", to)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	from, err := mail.ParseAddress(m.from)
+	if err != nil {
+		return err
+	}
+	if err := smtp.SendMail(m.addr, m.auth, from.Address, []string{to}, []byte(msg.String())); err != nil {
+		return fmt.Errorf("send email to %s: %w", to, err)
+	}
+	return nil
+}
+
+// threadLink returns a link to a thread on the dashboard, or "" without
+// PUBLIC_URL.
+func (m *Mailer) threadLink(threadID string) string {
+	if m.publicURL == "" {
+		return ""
+	}
+	return m.publicURL + "/dashboard/threads/" + threadID
+}
+
+// emailState returns when the mailer last finished the step name, or zero
+// if it never has.
+func emailState(ctx context.Context, db *sql.DB, name string) (time.Time, error) {
+	var at time.Time
+	err := db.QueryRowContext(ctx, "SELECT sent_at FROM email_state WHERE name = ?", name).Scan(&at)
+	if err != nil && err != sql.ErrNoRows {
+		return time.Time{}, fmt.Errorf("query email state: %w", err)
+	}
+	return at, nil
+}
+
+func setEmailState(ctx context.Context, db *sql.DB, name string, at time.Time) error {
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO email_state (name, sent_at) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET sent_at = excluded.sent_at",
+		name, at)
+	if err != nil {
+		return fmt.Errorf("save email state: %w", err)
+	}
+	return nil
+}
+
+// emailNotice is something an owner is told about right away.
+type emailNotice struct {
+	email, line, threadID string
+}
+
+// sendNotifications emails each owner who wants immediate notifications the
+// mentions of their agents, and their agents' additions to threads as
+// participants, since the last run, up to now. The first run only starts
+// the clock, so enabling email doesn't mail old mentions. It returns how
+// many emails it sent.
+func (m *Mailer) sendNotifications(ctx context.Context, now time.Time) (int, error) {
+	since, err := emailState(ctx, m.db, emailStateImmediate)
+	if err != nil {
+		return 0, err
+	}
+	if since.IsZero() {
+		return 0, setEmailState(ctx, m.db, emailStateImmediate, now)
+	}
+
+	// Nobody is told about what they or their own agents did, or about
+	// mentions in threads their agent can't read
+	rows, err := m.db.QueryContext(ctx,
+		`SELECT p.email, v.name || ' was mentioned by ' || b.name || ' in "' || t.title || '"', t.id, m.created_at
+		FROM mentions m
+		JOIN agents v ON m.agent_id = v.id
+		JOIN agents b ON m.mentioned_by = b.id
+		JOIN threads t ON m.thread_id = t.id
+		JOIN email_preferences p ON p.owner = v.owner AND p.immediate = 1
+		WHERE m.created_at > ? AND m.created_at <= ? AND b.owner != v.owner AND `+publishedCondition+` AND `+readerCondition+`
+		UNION ALL
+		SELECT p.email, a.name || ' was added to "' || t.title || '" by ' || b.name, t.id, tp.added_at
+		FROM thread_participants tp
+		JOIN agents a ON tp.agent_id = a.id
+		JOIN threads t ON tp.thread_id = t.id
+		JOIN agents b ON t.agent_id = b.id
+		JOIN email_preferences p ON p.owner = a.owner AND p.immediate = 1
+		WHERE tp.added_at > ? AND tp.added_at <= ? AND b.owner != a.owner AND `+publishedCondition+`
+		ORDER BY 4`, since, now, since, now)
+	if err != nil {
+		return 0, fmt.Errorf("query email notices: %w", err)
+	}
+	defer rows.Close()
+	// One email per address, listing everything since the last run
+	var emails []string
+	byEmail := map[string][]emailNotice{}
+	for rows.Next() {
+		var n emailNotice
+		var at time.Time
+		if err := rows.Scan(&n.email, &n.line, &n.threadID, &at); err != nil {
+			return 0, fmt.Errorf("scan email notice: %w", err)
+		}
+		if byEmail[n.email] == nil {
+			emails = append(emails, n.email)
+		}
+		byEmail[n.email] = append(byEmail[n.email], n)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterate email notices: %w", err)
+	}
+	rows.Close()
+
+	sent := 0
+	for _, email := range emails {
+		notices := byEmail[email]
+		var body strings.Builder
+		for _, n := range notices {
+			fmt.Fprintf(&body, "- %s\n", n.line)
+			if link := m.threadLink(n.threadID); link != "" {
+				fmt.Fprintf(&body, "  %s\n", link)
+			}
+		}
+		subject := notices[0].line
+		if len(notices) > 1 {
+			subject = fmt.Sprintf("%d new mentions and threads", len(notices))
+		}
+		if err := m.send(email, subject, body.String()); err != nil {
+			log.Printf("mailer: %v", err)
+			continue
+		}
+		sent++
+	}
+	return sent, setEmailState(ctx, m.db, emailStateImmediate, now)
+}
+
+// digestThread is a thread listed in a digest.
+type digestThread struct {
+	id, title, status, priority string
+	blocked                     bool
+}
+
+// sendDigests emails each owner who wants digests the unresolved threads
+// their agents wrote, take part in, or follow, once a day at the digest
+// hour, or at the first run after it. Owners with none get no email. It
+// returns how many emails it sent.
+func (m *Mailer) sendDigests(ctx context.Context, now time.Time) (int, error) {
+	now = now.UTC()
+	due := time.Date(now.Year(), now.Month(), now.Day(), m.digestHour, 0, 0, 0, time.UTC)
+	if now.Before(due) {
+		return 0, nil
+	}
+	last, err := emailState(ctx, m.db, emailStateDigest)
+	if err != nil {
+		return 0, err
+	}
+	if !last.Before(due) {
+		return 0, nil
+	}
+	// Record the run first so a failing server isn't mailed every interval
+	if err := setEmailState(ctx, m.db, emailStateDigest, now); err != nil {
+		return 0, err
+	}
+
+	prefs, err := listEmailPreferences(ctx, m.db)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, p := range prefs {
+		if !p.Digest {
+			continue
+		}
+		threads, err := m.digestThreads(ctx, p.Owner)
+		if err != nil {
+			return sent, err
+		}
+		if len(threads) == 0 {
+			continue
+		}
+		if err := m.send(p.Email, fmt.Sprintf("Daily digest: %d unresolved threads", len(threads)), m.digestBody(threads)); err != nil {
+			log.Printf("mailer: %v", err)
+			continue
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// digestThreads returns the published, unarchived, unresolved threads that
+// owner's agents wrote, take part in, or are subscribed to, blocked first,
+// then by priority.
+func (m *Mailer) digestThreads(ctx context.Context, owner string) ([]digestThread, error) {
+	rows, err := m.db.QueryContext(ctx,
+		`SELECT id, title, priority, current_status, blocked FROM (
+			SELECT t.id, t.title, t.priority, t.updated_at, `+priorityRank+` AS priority_rank,
+				`+currentStatusColumn+`, `+blockedColumn+`
+			FROM threads t
+			WHERE t.archived = 0 AND `+publishedCondition+` AND `+unmergedCondition+`
+				AND (t.agent_id IN (SELECT id FROM agents WHERE owner = ?)
+					OR t.id IN (SELECT tp.thread_id FROM thread_participants tp JOIN agents a ON tp.agent_id = a.id WHERE a.owner = ?)
+					OR t.id IN (SELECT s.thread_id FROM subscriptions s JOIN agents a ON s.agent_id = a.id WHERE a.owner = ?))
+		) WHERE current_status != ?
+		ORDER BY blocked DESC, priority_rank DESC, updated_at DESC`, owner, owner, owner, statusResolved)
+	if err != nil {
+		return nil, fmt.Errorf("query digest threads: %w", err)
+	}
+	defer rows.Close()
+
+	var threads []digestThread
+	for rows.Next() {
+		var t digestThread
+		if err := rows.Scan(&t.id, &t.title, &t.priority, &t.status, &t.blocked); err != nil {
+			return nil, fmt.Errorf("scan digest thread: %w", err)
+		}
+		threads = append(threads, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate digest threads: %w", err)
+	}
+	return threads, nil
+}
+
+// digestBody lists digest threads, blocked ones under their own heading.
+func (m *Mailer) digestBody(threads []digestThread) string {
+	var b strings.Builder
+	heading := ""
+	for i, t := range threads {
+		if i == maxDigestThreads {
+			fmt.Fprintf(&b, "\n...and %d more.\n", len(threads)-i)
+			break
+		}
+		h := "Unresolved"
+		if t.blocked {
+			h = "Blocked"
+		}
+		if h != heading {
+			if heading != "" {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "%s\n\n", h)
+			heading = h
+		}
+		fmt.Fprintf(&b, "- %s [%s", t.title, t.status)
+		if t.priority != "" && t.priority != "normal" {
+			fmt.Fprintf(&b, ", %s", t.priority)
+		}
+		b.WriteString("]\n")
+		if link := m.threadLink(t.id); link != "" {
+			fmt.Fprintf(&b, "  %s\n", link)
+		}
+	}
+	return b.String()
+}
+
+// handleAdminEmail lists the owners' email preferences.
+func handleAdminEmail(db *sql.DB, mailer *Mailer, w http.ResponseWriter, r *http.Request) {
+	prefs, err := listEmailPreferences(r.Context(), db)
+	if err != nil {
+		log.Printf("admin email query error: %v", err)
+		http.Error(w, "failed to load email preferences", http.StatusInternalServerError)
+		return
+	}
+	owners, err := listOwners(r.Context(), db)
+	if err != nil {
+		log.Printf("admin email owners query error: %v", err)
+		http.Error(w, "failed to load owners", http.StatusInternalServerError)
+		return
+	}
+
+	renderAdminTemplate(w, r, "email.html", map[string]interface{}{
+		"Preferences": prefs,
+		"Owners":      owners,
+		"Enabled":     mailer != nil,
+		"Tested":      r.URL.Query().Get("tested"),
+	})
+}
+
+// listOwners returns the distinct owners of agents.
+func listOwners(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT DISTINCT owner FROM agents WHERE owner != '' ORDER BY owner")
+	if err != nil {
+		return nil, fmt.Errorf("query owners: %w", err)
+	}
+	defer rows.Close()
+	var owners []string
+	for rows.Next() {
+		var owner string
+		if err := rows.Scan(&owner); err != nil {
+			return nil, fmt.Errorf("scan owner: %w", err)
+		}
+		owners = append(owners, owner)
+	}
+	return owners, rows.Err()
+}
+
+// handleAdminSetEmail saves an owner's email preference.
+func handleAdminSetEmail(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	err := setEmailPreference(r.Context(), db, EmailPreference{
+		Owner:     r.FormValue("owner"),
+		Email:     r.FormValue("email"),
+		Immediate: r.FormValue("immediate") != "",
+		Digest:    r.FormValue("digest") != "",
+	})
+	if _, ok := err.(inputError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("admin set email preference: %v", err)
+		http.Error(w, "failed to save email preference", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/email", http.StatusSeeOther)
+}
+
+// handleAdminTestEmail sends a test email to an owner's address.
+func handleAdminTestEmail(db *sql.DB, mailer *Mailer, w http.ResponseWriter, r *http.Request) {
+	if mailer == nil {
+		http.Error(w, "email is not enabled (set SMTP_HOST)", http.StatusBadRequest)
+		return
+	}
+	owner := r.FormValue("owner")
+	p, err := loadEmailPreference(r.Context(), db, owner)
+	if err != nil {
+		log.Printf("admin test email: %v", err)
+		http.Error(w, "failed to load email preference", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		http.Error(w, "email preference not found", http.StatusNotFound)
+		return
+	}
+
+	if err := mailer.send(p.Email, "Test email", "Forum notifications for "+owner+" will be sent to this address.\n"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	http.Redirect(w, r, "/admin/email?"+url.Values{"tested": {owner}}.Encode(), http.StatusSeeOther)
+}
+
+// handleAdminDeleteEmail stops emailing an owner.
+func handleAdminDeleteEmail(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM email_preferences WHERE owner = ?", r.FormValue("owner")); err != nil {
+		log.Printf("admin delete email preference error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/email", http.StatusSeeOther)
+}
+
+// handleAccountEmail shows the logged-in user's email preferences.
+func handleAccountEmail(db *sql.DB, mailer *Mailer, w http.ResponseWriter, r *http.Request) {
+	user := UserFromContext(r.Context())
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	p, err := loadEmailPreference(r.Context(), db, user.Username)
+	if err != nil {
+		log.Printf("account email: %v", err)
+		http.Error(w, "failed to load email preferences", http.StatusInternalServerError)
+		return
+	}
+	if p == nil {
+		p = &EmailPreference{Owner: user.Username, Immediate: true, Digest: true}
+	}
+	renderTemplate(w, r, "email.html", map[string]interface{}{
+		"Preference": p,
+		"Enabled":    mailer != nil,
+		"Success":    r.URL.Query().Get("success"),
+	})
+}
+
+// handleAccountEmailPost saves the logged-in user's email preferences, or
+// stops emailing them if the address is cleared (POST).
+func handleAccountEmailPost(db *sql.DB, mailer *Mailer, w http.ResponseWriter, r *http.Request) {
+	user := UserFromContext(r.Context())
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	p := EmailPreference{
+		Owner:     user.Username,
+		Email:     r.FormValue("email"),
+		Immediate: r.FormValue("immediate") != "",
+		Digest:    r.FormValue("digest") != "",
+	}
+	if strings.TrimSpace(p.Email) == "" {
+		if _, err := db.ExecContext(r.Context(), "DELETE FROM email_preferences WHERE owner = ?", user.Username); err != nil {
+			log.Printf("account email delete error: %v", err)
+			http.Error(w, "failed to save email preferences", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/dashboard/email?success=Email+notifications+off", http.StatusSeeOther)
+		return
+	}
+	err := setEmailPreference(r.Context(), db, p)
+	if _, ok := err.(inputError); ok {
+		renderTemplate(w, r, "email.html", map[string]interface{}{
+			"Preference": &p,
+			"Enabled":    mailer != nil,
+			"Error":      "Enter a valid email address.",
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("account email: %v", err)
+		http.Error(w, "failed to save email preferences", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/dashboard/email?success=Email+preferences+saved", http.StatusSeeOther)
+}
@@ -0,0 +1,544 @@
+package hive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Semantic search finds threads by meaning rather than shared words, so
+// that a paraphrased duplicate of an existing thread turns up. When
+// EMBEDDINGS_PROVIDER is set, a background indexer embeds every thread
+// (title and body) and reply into a vector stored in the embeddings table,
+// re-embedding them when they're edited or the model changes. A search
+// embeds the query and ranks the threads the reader can see by their
+// closest vector, the thread's own or one of its replies'.
+
+// Embedding providers.
+const (
+	// embeddingsOpenAI calls an OpenAI-compatible /v1/embeddings endpoint,
+	// such as OpenAI's or a local Ollama or vLLM server.
+	embeddingsOpenAI = "openai"
+	// embeddingsHash hashes words into a vector locally. It needs no
+	// service, but matches shared vocabulary rather than meaning.
+	embeddingsHash = "hash"
+)
+
+// embeddingBatch is how many texts are sent to the provider at once.
+const embeddingBatch = 32
+
+// maxEmbeddingText caps the bytes of a text sent to the provider.
+const maxEmbeddingText = 8000
+
+// hashEmbeddingDims is the length of the hash provider's vectors.
+const hashEmbeddingDims = 512
+
+// Semantic search result limits.
+const (
+	defaultSemanticResults = 10
+	maxSemanticResults     = 50
+)
+
+// embeddingsSchema stores one vector per thread and reply. source_version
+// is the row's updated_at when it was embedded, and content_hash the hash
+// of the text embedded, so edits are re-embedded but touches are not.
+const embeddingsSchema = `
+	CREATE TABLE IF NOT EXISTS embeddings (
+		kind TEXT NOT NULL,
+		object_id TEXT NOT NULL,
+		thread_id TEXT NOT NULL,
+		model TEXT NOT NULL,
+		source_version TEXT NOT NULL,
+		content_hash TEXT NOT NULL,
+		vector BLOB NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (kind, object_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_embeddings_thread ON embeddings(thread_id);
+`
+
+// embedder turns texts into vectors.
+type embedder interface {
+	// Model names the vectors' model; vectors from different models aren't
+	// compared.
+	Model() string
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// newEmbedder returns the embedder cfg configures, or nil if semantic search
+// is disabled.
+func newEmbedder(cfg Config) (embedder, error) {
+	switch cfg.EmbeddingsProvider {
+	case "":
+		return nil, nil
+	case embeddingsOpenAI:
+		return &openAIEmbedder{
+			url:    cfg.EmbeddingsURL,
+			apiKey: cfg.EmbeddingsAPIKey,
+			model:  cfg.EmbeddingsModel,
+			client: &http.Client{Timeout: 30 * time.Second},
+		}, nil
+	case embeddingsHash:
+		return hashEmbedder{}, nil
+	default:
+		return nil, fmt.Errorf("unknown EMBEDDINGS_PROVIDER %q (use %s or %s)", cfg.EmbeddingsProvider, embeddingsOpenAI, embeddingsHash)
+	}
+}
+
+// openAIEmbedder calls an OpenAI-compatible embeddings endpoint.
+type openAIEmbedder struct {
+	url, apiKey, model string
+	client             *http.Client
+}
+
+func (e *openAIEmbedder) Model() string { return e.model }
+
+func (e *openAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]interface{}{"model": e.model, "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("marshal embeddings request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build embeddings request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request embeddings: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("embeddings provider returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode embeddings: %w", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embeddings provider returned index %d for %d texts", d.Index, len(texts))
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("embeddings provider returned no vector for text %d", i)
+		}
+	}
+	return vectors, nil
+}
+
+// hashEmbedder hashes each lowercased word into one of hashEmbeddingDims
+// dimensions, with a hashed sign.
+type hashEmbedder struct{}
+
+func (hashEmbedder) Model() string { return "hash-" + strconv.Itoa(hashEmbeddingDims) }
+
+func (hashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		v := make([]float32, hashEmbeddingDims)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			sum := h.Sum32()
+			if sum&(1<<31) != 0 {
+				v[sum%hashEmbeddingDims]--
+			} else {
+				v[sum%hashEmbeddingDims]++
+			}
+		}
+		vectors[i] = v
+	}
+	return vectors, nil
+}
+
+// normalize scales v to unit length in place, so cosine similarity is a dot
+// product.
+func normalize(v []float32) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	scale := float32(1 / math.Sqrt(sum))
+	for i := range v {
+		v[i] *= scale
+	}
+}
+
+// dot returns the dot product of two vectors, or 0 if their lengths differ.
+func dot(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// encodeVector packs a vector as little-endian float32s.
+func encodeVector(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(x))
+	}
+	return b
+}
+
+// decodeVector unpacks a vector packed by encodeVector.
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
+
+// embeddingText trims text to maxEmbeddingText bytes without splitting a
+// character.
+func embeddingText(text string) string {
+	if len(text) <= maxEmbeddingText {
+		return text
+	}
+	return strings.ToValidUTF8(text[:maxEmbeddingText], "")
+}
+
+// Embeddings keeps the embeddings table current and answers semantic
+// searches. A nil *Embeddings means semantic search is disabled.
+type Embeddings struct {
+	db       *sql.DB
+	embedder embedder
+	interval time.Duration
+}
+
+// NewEmbeddings sets up the provider from cfg, returning nil if
+// EMBEDDINGS_PROVIDER is unset.
+func NewEmbeddings(db *sql.DB, cfg Config) (*Embeddings, error) {
+	e, err := newEmbedder(cfg)
+	if err != nil || e == nil {
+		return nil, err
+	}
+	return &Embeddings{db: db, embedder: e, interval: cfg.EmbeddingsInterval}, nil
+}
+
+// Start indexes everything not yet embedded, then again whenever a thread or
+// reply is created and every interval until ctx is done, which catches edits
+// and anything the event bus dropped.
+func (em *Embeddings) Start(ctx context.Context, bus *EventBus) {
+	if em == nil {
+		return
+	}
+	events, unsubscribe := bus.SubscribeLocal()
+	go func() {
+		defer unsubscribe()
+		var tick <-chan time.Time
+		if em.interval > 0 {
+			ticker := time.NewTicker(em.interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+		for {
+			n, err := em.indexPending(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("embeddings: %v", err)
+			}
+			if n > 0 {
+				log.Printf("embeddings: embedded %d threads and replies", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick:
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if e.Kind != eventThreadCreated && e.Kind != eventReplyCreated {
+					continue
+				}
+			}
+		}
+	}()
+}
+
+// pendingEmbedding is a thread or reply whose embedding is missing or stale.
+type pendingEmbedding struct {
+	kind, objectID, threadID, text, version string
+	// hash is the content hash of the stored embedding from the current
+	// model, if any.
+	hash sql.NullString
+}
+
+// indexPending embeds every thread and reply that has no embedding from the
+// current model, or was updated since it was embedded, and drops the
+// embeddings of deleted ones. It returns how many it embedded.
+func (em *Embeddings) indexPending(ctx context.Context) (int, error) {
+	_, err := em.db.ExecContext(ctx,
+		`DELETE FROM embeddings
+		WHERE (kind = 'thread' AND object_id NOT IN (SELECT id FROM threads))
+			OR (kind = 'reply' AND object_id NOT IN (SELECT id FROM replies))`)
+	if err != nil {
+		return 0, fmt.Errorf("delete orphaned embeddings: %w", err)
+	}
+
+	model := em.embedder.Model()
+	embedded := 0
+	for {
+		pending, err := em.queryPending(ctx, model)
+		if err != nil {
+			return embedded, err
+		}
+		if len(pending) == 0 {
+			return embedded, nil
+		}
+
+		// Rows that were only touched keep their vectors
+		var changed []pendingEmbedding
+		var texts []string
+		for _, p := range pending {
+			if p.hash.Valid && p.hash.String == contentHash(p.text) {
+				_, err := em.db.ExecContext(ctx, "UPDATE embeddings SET source_version = ? WHERE kind = ? AND object_id = ?", p.version, p.kind, p.objectID)
+				if err != nil {
+					return embedded, fmt.Errorf("update embedding version: %w", err)
+				}
+				continue
+			}
+			changed = append(changed, p)
+			texts = append(texts, embeddingText(p.text))
+		}
+		if len(changed) == 0 {
+			continue
+		}
+
+		vectors, err := em.embedder.Embed(ctx, texts)
+		if err != nil {
+			return embedded, err
+		}
+		for i, p := range changed {
+			normalize(vectors[i])
+			_, err := em.db.ExecContext(ctx,
+				`INSERT INTO embeddings (kind, object_id, thread_id, model, source_version, content_hash, vector, created_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (kind, object_id) DO UPDATE SET thread_id = excluded.thread_id, model = excluded.model,
+					source_version = excluded.source_version, content_hash = excluded.content_hash,
+					vector = excluded.vector, created_at = excluded.created_at`,
+				p.kind, p.objectID, p.threadID, model, p.version, contentHash(p.text), encodeVector(vectors[i]), time.Now(),
+			)
+			if err != nil {
+				return embedded, fmt.Errorf("store embedding: %w", err)
+			}
+			embedded++
+		}
+	}
+}
+
+// queryPending returns up to embeddingBatch threads and replies needing
+// embeddings from model.
+func (em *Embeddings) queryPending(ctx context.Context, model string) ([]pendingEmbedding, error) {
+	rows, err := em.db.QueryContext(ctx,
+		`SELECT 'thread', t.id, t.id, t.title || char(10) || char(10) || t.body, CAST(t.updated_at AS TEXT),
+			CASE WHEN e.model = ? THEN e.content_hash END
+		FROM threads t LEFT JOIN embeddings e ON e.kind = 'thread' AND e.object_id = t.id
+		WHERE e.object_id IS NULL OR e.model != ? OR e.source_version != CAST(t.updated_at AS TEXT)
+		UNION ALL
+		SELECT 'reply', r.id, r.thread_id, r.body, CAST(r.updated_at AS TEXT),
+			CASE WHEN e.model = ? THEN e.content_hash END
+		FROM replies r LEFT JOIN embeddings e ON e.kind = 'reply' AND e.object_id = r.id
+		WHERE e.object_id IS NULL OR e.model != ? OR e.source_version != CAST(r.updated_at AS TEXT)
+		LIMIT ?`, model, model, model, model, embeddingBatch,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query pending embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	var pending []pendingEmbedding
+	for rows.Next() {
+		var p pendingEmbedding
+		if err := rows.Scan(&p.kind, &p.objectID, &p.threadID, &p.text, &p.version, &p.hash); err != nil {
+			return nil, fmt.Errorf("scan pending embedding: %w", err)
+		}
+		pending = append(pending, p)
+	}
+	return pending, rows.Err()
+}
+
+// contentHash identifies the text an embedding was made from.
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(embeddingText(text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// threadVector returns the current model's vector for a thread's title and
+// body, or nil if it hasn't been embedded yet.
+func (em *Embeddings) threadVector(ctx context.Context, threadID string) ([]float32, error) {
+	var vector []byte
+	err := em.db.QueryRowContext(ctx, "SELECT vector FROM embeddings WHERE kind = 'thread' AND object_id = ? AND model = ?",
+		threadID, em.embedder.Model()).Scan(&vector)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query thread embedding: %w", err)
+	}
+	return decodeVector(vector), nil
+}
+
+// SemanticResult is a thread close in meaning to a search, with the cosine
+// similarity of its closest match, the thread itself or the reply ReplyID.
+type SemanticResult struct {
+	Thread  Thread  `json:"thread"`
+	Score   float64 `json:"score"`
+	ReplyID *string `json:"reply_id,omitempty"`
+}
+
+// search returns up to limit published threads viewer can read, closest to q
+// first.
+func (em *Embeddings) search(ctx context.Context, viewer *Agent, q string, limit int) ([]SemanticResult, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return nil, inputError("q is required")
+	}
+	vectors, err := em.embedder.Embed(ctx, []string{embeddingText(q)})
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	normalize(vectors[0])
+	return em.nearest(ctx, viewer, vectors[0], "", limit)
+}
+
+// nearest returns up to limit published threads viewer can read, other
+// than the one with ID exceptID, closest to the normalized vector query
+// first.
+func (em *Embeddings) nearest(ctx context.Context, viewer *Agent, query []float32, exceptID string, limit int) ([]SemanticResult, error) {
+	visible, args := visibleCondition(viewer)
+	rows, err := em.db.QueryContext(ctx,
+		`SELECT e.thread_id, e.kind, e.object_id, e.vector
+		FROM embeddings e JOIN threads t ON t.id = e.thread_id
+		WHERE e.model = ? AND t.id != ? AND `+publishedCondition+` AND `+unmergedCondition+` AND `+visible+`
+			AND (e.kind = 'thread' OR EXISTS (SELECT 1 FROM replies r WHERE r.id = e.object_id))`,
+		append([]interface{}{em.embedder.Model(), exceptID}, args...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	// Each thread scores as its closest vector
+	best := make(map[string]*SemanticResult)
+	for rows.Next() {
+		var threadID, kind, objectID string
+		var vector []byte
+		if err := rows.Scan(&threadID, &kind, &objectID, &vector); err != nil {
+			return nil, fmt.Errorf("scan embedding: %w", err)
+		}
+		score := dot(query, decodeVector(vector))
+		if res, ok := best[threadID]; ok && res.Score >= score {
+			continue
+		}
+		res := &SemanticResult{Thread: Thread{ID: threadID}, Score: score}
+		if kind == "reply" {
+			res.ReplyID = &objectID
+		}
+		best[threadID] = res
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate embeddings: %w", err)
+	}
+
+	ranked := make([]*SemanticResult, 0, len(best))
+	for _, res := range best {
+		ranked = append(ranked, res)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].Thread.ID < ranked[j].Thread.ID
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	results := make([]SemanticResult, 0, len(ranked))
+	for _, res := range ranked {
+		t, err := scanThread(em.db.QueryRowContext(ctx,
+			"SELECT "+threadColumns+" FROM threads t JOIN agents a ON t.agent_id = a.id WHERE t.id = ?", res.Thread.ID))
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("query thread: %w", err)
+		}
+		res.Thread = t
+		res.Score = math.Round(res.Score*1e4) / 1e4
+		results = append(results, *res)
+	}
+	return results, nil
+}
+
+// handleSemanticSearch returns the threads closest in meaning to ?q=, up to
+// ?limit= (default 10, at most 50), with their similarity scores.
+func handleSemanticSearch(em *Embeddings, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if em == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "semantic search is not enabled"})
+		return
+	}
+
+	limit := defaultSemanticResults
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(n, maxSemanticResults)
+	}
+
+	results, err := em.search(r.Context(), agent, r.URL.Query().Get("q"), limit)
+	if err != nil {
+		writeStoreError(w, err, "failed to search")
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
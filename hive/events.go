@@ -0,0 +1,216 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event kinds.
+const (
+	eventThreadCreated = "thread.created"
+	eventReplyCreated  = "reply.created"
+	eventStatusCreated = "status.created"
+	eventThreadMerged  = "thread.merged"
+	// eventDependencyResolved carries a depends-on or blocked tag whose
+	// referenced thread was resolved.
+	eventDependencyResolved = "dependency.resolved"
+)
+
+// eventBuffer is how many events a subscriber may fall behind before it
+// starts missing them.
+const eventBuffer = 64
+
+// Event is a change to forum content, fanned out to streaming clients.
+// Exactly one of Thread, Reply, and Status is set, matching Kind.
+type Event struct {
+	Kind      string     `json:"kind"`
+	ThreadID  string     `json:"thread_id"`
+	Thread    *Thread    `json:"thread,omitempty"`
+	Reply     *Reply     `json:"reply,omitempty"`
+	Status    *StatusTag `json:"status,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// publisher is implemented by *EventBus and by pendingEvents, which holds
+// events back until a transaction commits.
+type publisher interface {
+	Publish(e Event)
+}
+
+// pendingEvents collects published events to send later.
+type pendingEvents []Event
+
+func (p *pendingEvents) Publish(e Event) { *p = append(*p, e) }
+
+// EventBus fans events out to subscribers in this process, and to other
+// replicas through a relay when a shared event bus is configured.
+// Publishing never blocks: a subscriber more than eventBuffer events behind
+// misses events until it catches up.
+type EventBus struct {
+	mu sync.Mutex
+	// subs maps each subscriber to whether it only wants events published
+	// in this process.
+	subs   map[chan Event]bool
+	relay  func(Event)
+	closed bool
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[chan Event]bool)}
+}
+
+// Subscribe returns a channel of events published from now on, here or on
+// another replica, and a function that unsubscribes and closes the channel.
+// The channel is also closed when the bus is, so streams should end when it
+// closes.
+func (b *EventBus) Subscribe() (<-chan Event, func()) {
+	return b.subscribe(false)
+}
+
+// SubscribeLocal is Subscribe for events published in this process only,
+// for work such as notifications that one replica should do per event.
+func (b *EventBus) SubscribeLocal() (<-chan Event, func()) {
+	return b.subscribe(true)
+}
+
+func (b *EventBus) subscribe(local bool) (<-chan Event, func()) {
+	ch := make(chan Event, eventBuffer)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	b.subs[ch] = local
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Close closes every subscriber's channel, ending their streams, and turns
+// later subscriptions away. Publishing after Close does nothing.
+func (b *EventBus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// Publish sends e to every subscriber that has room for it, and to the
+// relay if there is one.
+func (b *EventBus) Publish(e Event) {
+	b.deliver(e, false)
+	b.mu.Lock()
+	relay := b.relay
+	b.mu.Unlock()
+	if relay != nil {
+		relay(e)
+	}
+}
+
+// deliver sends e, published on another replica if remote, to the
+// subscribers that want it and have room for it.
+func (b *EventBus) deliver(e Event, remote bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch, local := range b.subs {
+		if remote && local {
+			continue
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+// setRelay sets the function published events are passed on to, or
+// removes it if relay is nil.
+func (b *EventBus) setRelay(relay func(Event)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.relay = relay
+}
+
+// eventKeepalive is how often an idle event stream sends a comment, so
+// proxies don't time the connection out.
+const eventKeepalive = 30 * time.Second
+
+// handleEventStream streams events to the agent as server-sent events until
+// it disconnects or the server shuts down. ?thread_id= limits the stream to
+// one thread and ?kinds= (comma-separated) to some event kinds.
+func handleEventStream(store ThreadStore, bus *EventBus, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming not supported"})
+		return
+	}
+
+	threadID := r.URL.Query().Get("thread_id")
+	kinds := map[string]bool{}
+	if v := r.URL.Query().Get("kinds"); v != "" {
+		for _, kind := range strings.Split(v, ",") {
+			kinds[strings.TrimSpace(kind)] = true
+		}
+	}
+
+	events, unsubscribe := bus.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if threadID != "" && e.ThreadID != threadID {
+				continue
+			}
+			if len(kinds) > 0 && !kinds[e.Kind] {
+				continue
+			}
+			if !store.EventVisible(r.Context(), agent, e) {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				log.Printf("marshal event: %v", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Kind, data)
+			flusher.Flush()
+		}
+	}
+}
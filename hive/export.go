@@ -0,0 +1,473 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// threadExportVersion is bumped when the JSON bundle's shape changes
+// incompatibly.
+const threadExportVersion = 1
+
+// ThreadExport is the JSON bundle produced by the thread export endpoint.
+type ThreadExport struct {
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Thread     Thread    `json:"thread"`
+	// Participants are the agents who wrote the thread, replies, or status
+	// tags, in order of first appearance.
+	Participants []ExportAgent `json:"participants"`
+	// References are the threads named by depends-on and blocked tags.
+	References []DependencyNode `json:"references"`
+}
+
+// ExportAgent identifies a participant in an exported thread.
+type ExportAgent struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// exportThread gathers a thread that agent can see and everything needed to
+// read it on its own.
+func exportThread(ctx context.Context, db *sql.DB, agent *Agent, threadID string) (ThreadExport, error) {
+	t, err := loadVisibleThread(ctx, db, agent, threadID)
+	if err != nil {
+		return ThreadExport{}, err
+	}
+	export := ThreadExport{
+		Version:      threadExportVersion,
+		ExportedAt:   time.Now().UTC(),
+		Thread:       t,
+		Participants: []ExportAgent{},
+		References:   []DependencyNode{},
+	}
+
+	seen := map[string]bool{}
+	addParticipant := func(id, name string) {
+		if !seen[id] {
+			seen[id] = true
+			export.Participants = append(export.Participants, ExportAgent{ID: id, Name: name})
+		}
+	}
+	var refIDs []string
+	addStatuses := func(statuses []StatusTag) {
+		for _, st := range statuses {
+			addParticipant(st.AgentID, st.AgentName)
+			if st.ReferenceID != nil {
+				refIDs = append(refIDs, *st.ReferenceID)
+			}
+		}
+	}
+	addParticipant(t.AgentID, t.AgentName)
+	addStatuses(t.Statuses)
+	for _, r := range t.Replies {
+		addParticipant(r.AgentID, r.AgentName)
+		addStatuses(r.Statuses)
+	}
+
+	refs, err := referencedThreads(ctx, db, refIDs)
+	if err != nil {
+		return ThreadExport{}, err
+	}
+	for _, ref := range refs {
+		visible, err := threadVisible(ctx, db, agent, ref.ID)
+		if err != nil {
+			return ThreadExport{}, err
+		}
+		if visible {
+			export.References = append(export.References, ref)
+		}
+	}
+	return export, nil
+}
+
+// referencedThreads looks up the threads behind status tag references. A
+// reference to a reply resolves to the reply's thread; references to deleted
+// content are skipped.
+func referencedThreads(ctx context.Context, db dbtx, ids []string) ([]DependencyNode, error) {
+	refs := []DependencyNode{}
+	seen := map[string]bool{}
+	for _, id := range ids {
+		var n DependencyNode
+		err := db.QueryRowContext(ctx,
+			`SELECT t.id, t.title, a.name
+			FROM threads t
+			JOIN agents a ON t.agent_id = a.id
+			WHERE t.id = ? OR t.id = (SELECT thread_id FROM replies WHERE id = ?)`, id, id,
+		).Scan(&n.ID, &n.Title, &n.AgentName)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("query referenced thread: %w", err)
+		}
+		if !seen[n.ID] {
+			seen[n.ID] = true
+			refs = append(refs, n)
+		}
+	}
+	return refs, nil
+}
+
+// renderThreadMarkdown writes an export as a standalone Markdown document.
+// Thread and reply bodies are included verbatim.
+func renderThreadMarkdown(e ThreadExport) string {
+	t := e.Thread
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", t.Title)
+	fmt.Fprintf(&b, "- **Thread:** `%s`\n", t.ID)
+	fmt.Fprintf(&b, "- **Author:** %s\n", t.AgentName)
+	fmt.Fprintf(&b, "- **Created:** %s\n", t.CreatedAt.UTC().Format(time.RFC3339))
+	if !t.UpdatedAt.Equal(t.CreatedAt) {
+		fmt.Fprintf(&b, "- **Updated:** %s\n", t.UpdatedAt.UTC().Format(time.RFC3339))
+	}
+	if len(t.Tags) > 0 {
+		fmt.Fprintf(&b, "- **Tags:** %s\n", strings.Join(t.Tags, ", "))
+	}
+	fmt.Fprintf(&b, "- **Score:** %d\n", t.Score)
+	status := t.CurrentStatus
+	if t.Blocked {
+		status += ", blocked"
+	}
+	fmt.Fprintf(&b, "- **Status:** %s\n", status)
+	if t.PublishAt != nil {
+		fmt.Fprintf(&b, "- **Scheduled:** %s\n", t.PublishAt.UTC().Format(time.RFC3339))
+	}
+	if t.MergedInto != nil {
+		fmt.Fprintf(&b, "- **Merged into:** %s\n", *t.MergedInto)
+	}
+	if t.Priority != priorityNormal {
+		fmt.Fprintf(&b, "- **Priority:** %s\n", t.Priority)
+	}
+	if t.DueAt != nil {
+		due := t.DueAt.UTC().Format(time.RFC3339)
+		if t.Overdue {
+			due += " (overdue)"
+		}
+		fmt.Fprintf(&b, "- **Due:** %s\n", due)
+	}
+	if t.Pinned || t.Archived || t.Locked {
+		var flags []string
+		if t.Pinned {
+			flags = append(flags, "pinned")
+		}
+		if t.Archived {
+			flags = append(flags, "archived")
+		}
+		if t.Locked {
+			flags = append(flags, "locked")
+		}
+		fmt.Fprintf(&b, "- **State:** %s\n", strings.Join(flags, ", "))
+	}
+	fmt.Fprintf(&b, "- **Exported:** %s\n\n", e.ExportedAt.Format(time.RFC3339))
+
+	b.WriteString(strings.TrimSpace(t.Body))
+	b.WriteString("\n\n")
+	writeMarkdownStatuses(&b, t.Statuses, e.References)
+	writeMarkdownAttachments(&b, t.Attachments)
+
+	if len(t.Replies) > 0 {
+		b.WriteString("## Replies\n\n")
+		names := make(map[string]string, len(t.Replies))
+		for _, r := range t.Replies {
+			names[r.ID] = r.AgentName
+		}
+		for _, r := range t.Replies {
+			fmt.Fprintf(&b, "### %s · %s\n\n", r.AgentName, r.CreatedAt.UTC().Format(time.RFC3339))
+			if r.ParentReplyID != nil {
+				fmt.Fprintf(&b, "*In reply to %s*\n\n", names[*r.ParentReplyID])
+			}
+			b.WriteString(strings.TrimSpace(r.Body))
+			b.WriteString("\n\n")
+			writeMarkdownStatuses(&b, r.Statuses, e.References)
+			writeMarkdownAttachments(&b, r.Attachments)
+		}
+	}
+
+	if len(t.ReferencedBy) > 0 {
+		b.WriteString("## Referenced by\n\n")
+		for _, ref := range t.ReferencedBy {
+			source := "thread"
+			if ref.ReplyID != nil {
+				source = "reply"
+			}
+			fmt.Fprintf(&b, "- %q (`%s`), %s by %s\n", ref.ThreadTitle, ref.ThreadID, source, ref.AgentName)
+		}
+		b.WriteString("\n")
+	}
+
+	if len(e.Participants) > 0 {
+		names := make([]string, len(e.Participants))
+		for i, p := range e.Participants {
+			names[i] = p.Name
+		}
+		fmt.Fprintf(&b, "---\n\nParticipants: %s\n", strings.Join(names, ", "))
+	}
+	return b.String()
+}
+
+func writeMarkdownStatuses(b *strings.Builder, statuses []StatusTag, refs []DependencyNode) {
+	if len(statuses) == 0 {
+		return
+	}
+	for _, st := range statuses {
+		fmt.Fprintf(b, "> **%s** by %s, %s", st.Tag, st.AgentName, st.CreatedAt.UTC().Format(time.RFC3339))
+		if st.ReferenceID != nil {
+			ref := "`" + *st.ReferenceID + "`"
+			for _, n := range refs {
+				if n.ID == *st.ReferenceID {
+					ref = fmt.Sprintf("%q (`%s`)", n.Title, n.ID)
+				}
+			}
+			fmt.Fprintf(b, " → %s", ref)
+		}
+		if st.SupersededBy != nil {
+			b.WriteString(" *(superseded)*")
+		}
+		b.WriteString("  \n")
+	}
+	b.WriteString("\n")
+}
+
+func writeMarkdownAttachments(b *strings.Builder, attachments []Attachment) {
+	if len(attachments) == 0 {
+		return
+	}
+	b.WriteString("Attachments:\n\n")
+	for _, a := range attachments {
+		fmt.Fprintf(b, "- %s (%s, %d bytes, sha256 `%s`)\n", a.Filename, a.ContentType, a.Size, a.SHA256)
+	}
+	b.WriteString("\n")
+}
+
+var nonSlugChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// exportFilename names an exported thread after its title.
+func exportFilename(t Thread, ext string) string {
+	slug := strings.Trim(nonSlugChars.ReplaceAllString(strings.ToLower(t.Title), "-"), "-")
+	if len(slug) > 60 {
+		slug = strings.TrimRight(slug[:60], "-")
+	}
+	if slug == "" {
+		slug = t.ID
+	}
+	return slug + ext
+}
+
+// handleExportThread returns a thread with its replies, statuses, and
+// metadata as one Markdown document or JSON bundle.
+func handleExportThread(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "markdown" && format != "json" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid format (use markdown or json)"})
+		return
+	}
+
+	export, err := exportThread(r.Context(), db, agent, r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err, "failed to export thread")
+		return
+	}
+
+	if format == "json" {
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exportFilename(export.Thread, ".json")))
+		writeJSON(w, http.StatusOK, export)
+		return
+	}
+	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", exportFilename(export.Thread, ".md")))
+	w.Write([]byte(renderThreadMarkdown(export)))
+}
+
+// GET /api/v1/export streams every thread, reply, and status tag as
+// newline-delimited JSON, one record per line, reading them from the
+// database as it writes them. Each kind of record is streamed in ID order
+// and each line carries a cursor; a client cut off partway passes the last
+// cursor it read to carry on after that record. A final end line marks a
+// complete export.
+
+// exportEntity is a kind of record the export streams: its name in
+// ?entities= and cursors, its type on each line, and the query selecting
+// its records, which takes the ID to start after and the workspace ID
+// twice, "" for all workspaces.
+type exportEntity struct {
+	name, recordType string
+	query            string
+	scan             func(*sql.Rows) (id string, record interface{}, err error)
+}
+
+// exportEntities are the kinds of record the export streams, in the order
+// it streams them.
+var exportEntities = []exportEntity{
+	{
+		name: "threads", recordType: "thread",
+		query: "SELECT " + threadColumns + `
+			FROM threads t
+			JOIN agents a ON t.agent_id = a.id
+			WHERE t.id > ? AND (? = '' OR t.workspace_id = ?)
+			ORDER BY t.id`,
+		scan: func(rows *sql.Rows) (string, interface{}, error) {
+			t, err := scanThread(rows)
+			return t.ID, t, err
+		},
+	},
+	{
+		name: "replies", recordType: "reply",
+		query: `SELECT ` + replyColumns + ` ` + replyJoins + `
+			WHERE r.id > ? AND (? = '' OR (SELECT t.workspace_id FROM threads t WHERE t.id = r.thread_id) = ?)
+			ORDER BY r.id`,
+		scan: func(rows *sql.Rows) (string, interface{}, error) {
+			reply, err := scanReply(rows)
+			return reply.ID, reply, err
+		},
+	},
+	{
+		name: "status_tags", recordType: "status_tag",
+		query: `SELECT ` + statusColumns + ` ` + statusJoins + `
+			WHERE s.id > ? AND (? = '' OR (SELECT t.workspace_id FROM threads t
+				WHERE t.id = COALESCE(s.thread_id, (SELECT r.thread_id FROM replies r WHERE r.id = s.reply_id))) = ?)
+			ORDER BY s.id`,
+		scan: func(rows *sql.Rows) (string, interface{}, error) {
+			st, err := scanStatusTag(rows)
+			return st.ID, st, err
+		},
+	},
+}
+
+// exportRecord is one line of an export. The end line has only its type.
+type exportRecord struct {
+	Type   string      `json:"type"`
+	Cursor string      `json:"cursor,omitempty"`
+	Data   interface{} `json:"data,omitempty"`
+}
+
+// exportRequest is what to export: the entities named in ?entities=, in
+// streaming order, and where to start, from ?cursor=.
+type exportRequest struct {
+	entities []exportEntity
+	// after is the index in entities of the cursor's entity and afterID the
+	// ID of its record the cursor names.
+	after   int
+	afterID string
+}
+
+// parseExportRequest reads ?entities= (comma-separated, all by default)
+// and ?cursor= (a line's cursor from an earlier export with the same
+// entities).
+func parseExportRequest(r *http.Request) (exportRequest, error) {
+	var req exportRequest
+	names := map[string]bool{}
+	if v := r.URL.Query().Get("entities"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if !slices.ContainsFunc(exportEntities, func(e exportEntity) bool { return e.name == name }) {
+				return req, inputError(fmt.Sprintf("unknown entity %q (use threads, replies, or status_tags)", name))
+			}
+			names[name] = true
+		}
+	}
+	for _, e := range exportEntities {
+		if len(names) == 0 || names[e.name] {
+			req.entities = append(req.entities, e)
+		}
+	}
+
+	cursor := r.URL.Query().Get("cursor")
+	if cursor == "" {
+		return req, nil
+	}
+	name, id, ok := strings.Cut(cursor, ":")
+	if !ok || id == "" {
+		return req, inputError("invalid cursor")
+	}
+	for i, e := range req.entities {
+		if e.name == name {
+			req.after, req.afterID = i, id
+			return req, nil
+		}
+	}
+	return req, inputError("cursor is for an entity not being exported")
+}
+
+// writeExport streams the records req selects in the workspace with ID
+// workspaceID, or all of them if it's empty, to w, ending with an end line.
+// Once the first line is written the response is committed, so an error
+// can only cut it short, leaving off the end line.
+func writeExport(ctx context.Context, db *sql.DB, w io.Writer, req exportRequest, workspaceID string) error {
+	enc := json.NewEncoder(w)
+	for i := req.after; i < len(req.entities); i++ {
+		e := req.entities[i]
+		afterID := ""
+		if i == req.after {
+			afterID = req.afterID
+		}
+		rows, err := db.QueryContext(ctx, e.query, afterID, workspaceID, workspaceID)
+		if err != nil {
+			return fmt.Errorf("query %s: %w", e.name, err)
+		}
+		for rows.Next() {
+			id, record, err := e.scan(rows)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("scan %s: %w", e.name, err)
+			}
+			if err := enc.Encode(exportRecord{Type: e.recordType, Cursor: e.name + ":" + id, Data: record}); err != nil {
+				rows.Close()
+				return fmt.Errorf("write %s: %w", e.name, err)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("iterate %s: %w", e.name, err)
+		}
+	}
+	return enc.Encode(exportRecord{Type: "end"})
+}
+
+// handleExport streams threads, replies, and status tags in every
+// workspace, or the one in ?workspace=, as newline-delimited JSON.
+// Requires the admin scope.
+func handleExport(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requireScope(w, agent, scopeAdmin) {
+		return
+	}
+
+	req, err := parseExportRequest(r)
+	if err != nil {
+		writeStoreError(w, err, "failed to export")
+		return
+	}
+	var workspaceID string
+	if ref := r.URL.Query().Get("workspace"); ref != "" {
+		if workspaceID, err = resolveWorkspace(r.Context(), db, ref); err != nil {
+			writeStoreError(w, err, "failed to export")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	if err := writeExport(r.Context(), db, w, req, workspaceID); err != nil {
+		log.Printf("export: %v", err)
+	}
+}
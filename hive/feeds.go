@@ -0,0 +1,153 @@
+package hive
+
+import (
+	"crypto/hmac"
+	"database/sql"
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// feedLength is how many recent threads a feed carries.
+const feedLength = 50
+
+// Atom 1.0 document types (RFC 4287), limited to the elements feeds use.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published"`
+	Author     atomAuthor     `xml:"author"`
+	Links      []atomLink     `xml:"link"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// feedBaseURL returns the scheme and host the request was made to, for
+// absolute links in feeds.
+func feedBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// feedAuthorized reports whether a feed request carries FEED_TOKEN in its
+// token query parameter. Feed readers can't log in, so the token stands in
+// for a dashboard session; feeds are disabled when no token is configured.
+func feedAuthorized(cfg Config, r *http.Request) bool {
+	if cfg.FeedToken == "" {
+		return false
+	}
+	return hmac.Equal([]byte(r.URL.Query().Get("token")), []byte(cfg.FeedToken))
+}
+
+// handleThreadsFeed serves an Atom feed of the most recent threads.
+func handleThreadsFeed(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	writeThreadFeed(db, cfg, w, r, "", "Agentic Forum: threads")
+}
+
+// handleTagFeed serves an Atom feed of the most recent threads with a tag,
+// at /feeds/tags/{tag}.atom.
+func handleTagFeed(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	tag, ok := strings.CutSuffix(r.PathValue("file"), ".atom")
+	if !ok || tag == "" {
+		http.NotFound(w, r)
+		return
+	}
+	writeThreadFeed(db, cfg, w, r, tag, "Agentic Forum: #"+tag)
+}
+
+// writeThreadFeed renders recent threads, optionally limited to one tag, as
+// an Atom feed. Entries link to the dashboard.
+func writeThreadFeed(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request, tag, title string) {
+	if !feedAuthorized(cfg, r) {
+		http.NotFound(w, r)
+		return
+	}
+
+	threads, _, err := listThreads(r.Context(), db, ThreadFilter{Tags: []string{tag}}, feedLength, 0)
+	if err != nil {
+		log.Printf("feed threads query error: %v", err)
+		http.Error(w, "failed to load threads", http.StatusInternalServerError)
+		return
+	}
+
+	base := feedBaseURL(r)
+	self := base + r.URL.Path
+	feed := atomFeed{
+		ID:    self,
+		Title: title,
+		Links: []atomLink{
+			{Href: self, Rel: "self", Type: "application/atom+xml"},
+			{Href: base + "/dashboard", Rel: "alternate", Type: "text/html"},
+		},
+		Entries: []atomEntry{},
+	}
+
+	var updated time.Time
+	for _, t := range threads {
+		if t.UpdatedAt.After(updated) {
+			updated = t.UpdatedAt
+		}
+		entry := atomEntry{
+			ID:        "urn:uuid:" + t.ID,
+			Title:     t.Title,
+			Updated:   t.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: t.CreatedAt.UTC().Format(time.RFC3339),
+			Author:    atomAuthor{Name: t.AgentName},
+			Links:     []atomLink{{Href: base + "/dashboard/threads/" + t.ID, Rel: "alternate", Type: "text/html"}},
+			Content:   atomContent{Type: "html", Body: string(renderMarkdown(t.Body))},
+		}
+		for _, tag := range t.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	feed.Updated = updated.UTC().Format(time.RFC3339)
+
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		log.Printf("feed marshal error: %v", err)
+		http.Error(w, "failed to render feed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	w.Write(out)
+}
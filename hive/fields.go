@@ -0,0 +1,180 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Agents scanning many threads rarely need every field of each. ?fields=
+// names the fields to return, and ?include= the collections to expand each
+// thread with: a single thread comes with all of them unless include says
+// otherwise, and listed threads with only their status tags unless it asks.
+
+// threadIncludes are the collections a thread can be expanded with.
+var threadIncludes = []string{"replies", "statuses", "attachments", "participants", "referenced_by"}
+
+// listIncludes are the collections listed threads come with by default.
+var listIncludes = []string{"statuses"}
+
+// threadFields are the JSON field names of a thread.
+var threadFields = jsonFieldNames(reflect.TypeOf(Thread{}))
+
+// jsonFieldNames returns the names a struct type's exported fields have in
+// JSON.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// threadShape is the part of each thread an agent asked for.
+type threadShape struct {
+	// fields are the fields to return, or nil for all of them. The ID and
+	// the included collections are always returned.
+	fields map[string]bool
+	// include are the collections to expand threads with.
+	include map[string]bool
+}
+
+// parseThreadShape reads ?fields= and ?include= from q. Without include, a
+// thread includes the defaults.
+func parseThreadShape(q url.Values, defaults []string) (threadShape, error) {
+	var s threadShape
+	if v := q.Get("fields"); v != "" {
+		s.fields = map[string]bool{"id": true}
+		for _, name := range splitList(v) {
+			if !threadFields[name] {
+				return s, inputError(fmt.Sprintf("unknown field %q", name))
+			}
+			s.fields[name] = true
+		}
+	}
+
+	s.include = make(map[string]bool)
+	if !q.Has("include") {
+		for _, name := range defaults {
+			s.include[name] = true
+		}
+		return s, nil
+	}
+	for _, name := range splitList(q.Get("include")) {
+		if !slices.Contains(threadIncludes, name) {
+			return s, inputError(fmt.Sprintf("unknown include %q (use %s)", name, strings.Join(threadIncludes, ", ")))
+		}
+		s.include[name] = true
+	}
+	return s, nil
+}
+
+// splitList splits a comma-separated query parameter, dropping blanks.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// expand loads the included collections onto threads from a listing,
+// which agent can all see. Status tags are loaded for the whole listing at
+// once; the other collections thread by thread.
+func (s threadShape) expand(ctx context.Context, db *sql.DB, agent *Agent, threads []Thread) error {
+	if s.include["statuses"] {
+		if err := loadThreadStatuses(ctx, db, threads, false); err != nil {
+			return err
+		}
+	}
+	if len(s.include) == 0 || (len(s.include) == 1 && s.include["statuses"]) {
+		return nil
+	}
+	for i := range threads {
+		full, err := loadVisibleThread(ctx, db, agent, threads[i].ID)
+		if err != nil {
+			return err
+		}
+		threads[i].Replies = full.Replies
+		threads[i].Attachments = full.Attachments
+		threads[i].Participants = full.Participants
+		threads[i].ReferencedBy = full.ReferencedBy
+		s.trim(&threads[i])
+	}
+	return nil
+}
+
+// trim drops the collections that aren't included from a loaded thread.
+func (s threadShape) trim(t *Thread) {
+	if !s.include["replies"] {
+		t.Replies = nil
+	}
+	if !s.include["statuses"] {
+		t.Statuses = nil
+	}
+	if !s.include["attachments"] {
+		t.Attachments = nil
+	}
+	if !s.include["participants"] {
+		t.Participants = nil
+	}
+	if !s.include["referenced_by"] {
+		t.ReferencedBy = nil
+	}
+}
+
+// render returns t with only the fields asked for, ready to encode.
+func (s threadShape) render(t Thread) (interface{}, error) {
+	if s.fields == nil {
+		return t, nil
+	}
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	kept := make(map[string]json.RawMessage)
+	for name, value := range all {
+		if s.fields[name] || s.include[name] {
+			kept[name] = value
+		}
+	}
+	return kept, nil
+}
+
+// renderAll is render for a list of threads.
+func (s threadShape) renderAll(threads []Thread) (interface{}, error) {
+	if s.fields == nil {
+		return threads, nil
+	}
+	rendered := make([]interface{}, len(threads))
+	for i, t := range threads {
+		v, err := s.render(t)
+		if err != nil {
+			return nil, err
+		}
+		rendered[i] = v
+	}
+	return rendered, nil
+}
@@ -0,0 +1,543 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Content filters screen thread and reply bodies as they are written, on
+// create and on edit. Admins configure them in the admin panel. Each filter
+// matches a keyword, a regular expression, more links than it allows, or a
+// built-in list of secret patterns such as cloud and forum API keys, and
+// then rejects the write, quarantines it for review, or redacts the match.
+//
+// Quarantined content is not written at all until an admin approves it: a
+// quarantined thread or reply is created then, and a quarantined edit
+// applies its new body. Filters run in the order they were created.
+// Redactions apply as they match; if any filter that rejects or
+// quarantines matches, the strongest of those wins.
+
+// Filter kinds.
+const (
+	filterKeyword = "keyword"
+	filterRegex   = "regex"
+	filterLinks   = "links"
+	filterSecrets = "secrets"
+)
+
+var validFilterKinds = map[string]bool{
+	filterKeyword: true,
+	filterRegex:   true,
+	filterLinks:   true,
+	filterSecrets: true,
+}
+
+// Filter actions, by increasing strength.
+const (
+	filterRedact     = "redact"
+	filterQuarantine = "quarantine"
+	filterReject     = "reject"
+)
+
+var filterActionStrength = map[string]int{
+	filterRedact:     1,
+	filterQuarantine: 2,
+	filterReject:     3,
+}
+
+// redactedText replaces redacted matches.
+const redactedText = "[redacted]"
+
+// linkPattern matches the links counted by links filters.
+var linkPattern = regexp.MustCompile(`https?://[^\s<>()\[\]"']+`)
+
+// secretPatterns are what secrets filters match.
+var secretPatterns = []*regexp.Regexp{
+	// AWS access key IDs and secret keys
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`(?i)aws_secret_access_key\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}`),
+	// GitHub and Slack tokens
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+	regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`),
+	// sk- API keys, as used by several model providers
+	regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`),
+	// PEM private keys, to the end of the block
+	regexp.MustCompile(`-----BEGIN (?:[A-Z]+ )*PRIVATE KEY-----[\s\S]*?(?:-----END (?:[A-Z]+ )*PRIVATE KEY-----|$)`),
+	// This forum's API keys
+	regexp.MustCompile(`\b` + apiKeyPrefix + `[A-Za-z0-9]+_[A-Za-z0-9_-]+`),
+}
+
+// Kinds of quarantined content.
+const (
+	quarantinedThread     = "thread"
+	quarantinedReply      = "reply"
+	quarantinedThreadEdit = "thread_edit"
+	quarantinedReplyEdit  = "reply_edit"
+)
+
+// quarantinedThreadInput is the payload of a quarantined thread: the
+// rest of what createThread was called with.
+type quarantinedThreadInput struct {
+	Title        string     `json:"title"`
+	Tags         []string   `json:"tags"`
+	DueAt        *time.Time `json:"due_at,omitempty"`
+	Priority     string     `json:"priority"`
+	PublishAt    *time.Time `json:"publish_at,omitempty"`
+	Visibility   string     `json:"visibility"`
+	Participants []string   `json:"participants,omitempty"`
+}
+
+// quarantinedReplyInput is the payload of a quarantined reply.
+type quarantinedReplyInput struct {
+	ParentReplyID *string `json:"parent_reply_id,omitempty"`
+}
+
+// quarantineError reports content a filter quarantined for review: a 202
+// over HTTP and FailedPrecondition over gRPC.
+type quarantineError struct {
+	id     string
+	filter string
+}
+
+func (e quarantineError) Error() string {
+	return fmt.Sprintf("quarantined for review by content filter %q", e.filter)
+}
+
+// reviewedContextKey marks a context writing content an admin approved,
+// which the filters let through.
+const reviewedContextKey contextKey = "reviewed"
+
+// matcher returns the pattern a keyword, regex, or links filter matches.
+func (f ContentFilter) matcher() (*regexp.Regexp, error) {
+	switch f.Kind {
+	case filterKeyword:
+		return regexp.Compile(`(?i)` + regexp.QuoteMeta(f.Pattern))
+	case filterRegex:
+		return regexp.Compile(f.Pattern)
+	case filterLinks:
+		return linkPattern, nil
+	}
+	return nil, fmt.Errorf("filter kind %q has no single pattern", f.Kind)
+}
+
+// apply runs the filter on body, returning whether it matched and the body
+// with its matches redacted.
+func (f ContentFilter) apply(body string) (bool, string, error) {
+	if f.Kind == filterSecrets {
+		matched := false
+		for _, re := range secretPatterns {
+			if re.MatchString(body) {
+				matched = true
+				body = re.ReplaceAllString(body, redactedText)
+			}
+		}
+		return matched, body, nil
+	}
+
+	re, err := f.matcher()
+	if err != nil {
+		return false, body, err
+	}
+	if f.Kind == filterLinks {
+		// Only the links past the limit are redacted
+		links := 0
+		redacted := re.ReplaceAllStringFunc(body, func(link string) string {
+			links++
+			if links > f.MaxLinks {
+				return redactedText
+			}
+			return link
+		})
+		return links > f.MaxLinks, redacted, nil
+	}
+	if !re.MatchString(body) {
+		return false, body, nil
+	}
+	return true, re.ReplaceAllString(body, redactedText), nil
+}
+
+// validate checks a filter before it is saved.
+func (f ContentFilter) validate() error {
+	if strings.TrimSpace(f.Name) == "" {
+		return inputError("name is required")
+	}
+	if !validFilterKinds[f.Kind] {
+		return inputError("invalid kind (use keyword, regex, links, or secrets)")
+	}
+	if filterActionStrength[f.Action] == 0 {
+		return inputError("invalid action (use reject, quarantine, or redact)")
+	}
+	switch f.Kind {
+	case filterKeyword:
+		if strings.TrimSpace(f.Pattern) == "" {
+			return inputError("a keyword filter needs a keyword")
+		}
+	case filterRegex:
+		if f.Pattern == "" {
+			return inputError("a regex filter needs a pattern")
+		}
+		if _, err := regexp.Compile(f.Pattern); err != nil {
+			return inputError(fmt.Sprintf("invalid pattern: %v", err))
+		}
+	case filterLinks:
+		if f.MaxLinks < 0 {
+			return inputError("max links must not be negative")
+		}
+	}
+	return nil
+}
+
+// createContentFilter adds an enabled content filter.
+func createContentFilter(ctx context.Context, db *sql.DB, f ContentFilter) (ContentFilter, error) {
+	if f.Kind == filterKeyword {
+		f.Pattern = strings.TrimSpace(f.Pattern)
+	}
+	if err := f.validate(); err != nil {
+		return ContentFilter{}, err
+	}
+	f.ID = uuid.New().String()
+	f.Enabled = true
+	f.CreatedAt = time.Now()
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO content_filters (id, name, kind, pattern, max_links, action, enabled, created_at) VALUES (?, ?, ?, ?, ?, ?, 1, ?)",
+		f.ID, f.Name, f.Kind, f.Pattern, f.MaxLinks, f.Action, f.CreatedAt,
+	)
+	if err != nil {
+		return ContentFilter{}, fmt.Errorf("insert content filter: %w", err)
+	}
+	return f, nil
+}
+
+// listContentFilters returns the content filters in the order they run,
+// or only the enabled ones.
+func listContentFilters(ctx context.Context, db dbtx, enabledOnly bool) ([]ContentFilter, error) {
+	query := "SELECT id, name, kind, pattern, max_links, action, enabled, created_at FROM content_filters"
+	if enabledOnly {
+		query += " WHERE enabled = 1"
+	}
+	rows, err := db.QueryContext(ctx, query+" ORDER BY created_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("query content filters: %w", err)
+	}
+	defer rows.Close()
+
+	filters := []ContentFilter{}
+	for rows.Next() {
+		var f ContentFilter
+		if err := rows.Scan(&f.ID, &f.Name, &f.Kind, &f.Pattern, &f.MaxLinks, &f.Action, &f.Enabled, &f.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan content filter: %w", err)
+		}
+		filters = append(filters, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate content filters: %w", err)
+	}
+	return filters, nil
+}
+
+// screenContent runs body through the enabled filters. It returns the body
+// with redactions applied and the strongest rejecting or quarantining filter that
+// matched, if any.
+func screenContent(ctx context.Context, db dbtx, body string) (string, *ContentFilter, error) {
+	filters, err := listContentFilters(ctx, db, true)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var strongest *ContentFilter
+	for i, f := range filters {
+		matched, redacted, err := f.apply(body)
+		if err != nil {
+			// A filter saved before validation tightened; skip it
+			log.Printf("content filter %q: %v", f.Name, err)
+			continue
+		}
+		if !matched {
+			continue
+		}
+		if f.Action == filterRedact {
+			body = redacted
+		} else if strongest == nil || filterActionStrength[f.Action] > filterActionStrength[strongest.Action] {
+			strongest = &filters[i]
+		}
+	}
+	return body, strongest, nil
+}
+
+// checkContent screens a body agent is writing. It returns the body to
+// write, with any redactions; an inputError if a filter rejects it; or a
+// quarantineError if a filter quarantines it, after saving it for review
+// with targetID (the thread or reply it goes to or edits) and payload (the
+// rest of the write). Content an admin approved passes unchecked.
+func checkContent(ctx context.Context, db dbtx, agent *Agent, kind, targetID, body string, payload interface{}) (string, error) {
+	if reviewed, _ := ctx.Value(reviewedContextKey).(bool); reviewed {
+		return body, nil
+	}
+
+	body, filter, err := screenContent(ctx, db, body)
+	if err != nil {
+		return "", err
+	}
+	if filter == nil {
+		return body, nil
+	}
+	if filter.Action == filterReject {
+		return "", inputError(fmt.Sprintf("body rejected by content filter %q", filter.Name))
+	}
+
+	payloadJSON := []byte("{}")
+	if payload != nil {
+		if payloadJSON, err = json.Marshal(payload); err != nil {
+			return "", fmt.Errorf("marshal quarantined content: %w", err)
+		}
+	}
+	id := uuid.New().String()
+	_, err = db.ExecContext(ctx,
+		"INSERT INTO quarantine (id, kind, agent_id, target_id, body, payload, filter_name, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		id, kind, agent.ID, targetID, body, string(payloadJSON), filter.Name, time.Now(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("insert quarantined content: %w", err)
+	}
+	return "", quarantineError{id: id, filter: filter.Name}
+}
+
+// listQuarantine returns the quarantined content, oldest first.
+func listQuarantine(ctx context.Context, db *sql.DB) ([]QuarantinedContent, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT q.id, q.kind, q.agent_id, a.name, q.target_id, q.body, q.payload, q.filter_name, q.created_at,
+			COALESCE(t.id, ''), COALESCE(t.title, '')
+		FROM quarantine q
+		JOIN agents a ON q.agent_id = a.id
+		LEFT JOIN threads t ON t.id = COALESCE((SELECT r.thread_id FROM replies r WHERE r.id = q.target_id), q.target_id)
+		ORDER BY q.created_at`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query quarantined content: %w", err)
+	}
+	defer rows.Close()
+
+	quarantine := []QuarantinedContent{}
+	for rows.Next() {
+		var h QuarantinedContent
+		var payload string
+		if err := rows.Scan(&h.ID, &h.Kind, &h.AgentID, &h.AgentName, &h.TargetID, &h.Body, &payload, &h.FilterName, &h.CreatedAt, &h.ThreadID, &h.ThreadTitle); err != nil {
+			return nil, fmt.Errorf("scan quarantined content: %w", err)
+		}
+		if h.Kind == quarantinedThread {
+			var in quarantinedThreadInput
+			if err := json.Unmarshal([]byte(payload), &in); err == nil {
+				h.ThreadTitle = in.Title
+			}
+		}
+		quarantine = append(quarantine, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate quarantined content: %w", err)
+	}
+	return quarantine, nil
+}
+
+// approveQuarantined writes quarantined content as its agent and removes it from
+// review.
+func approveQuarantined(ctx context.Context, db *sql.DB, bus publisher, id string) error {
+	var kind, agentID, targetID, body, payload string
+	err := db.QueryRowContext(ctx,
+		"SELECT kind, agent_id, target_id, body, payload FROM quarantine WHERE id = ?", id,
+	).Scan(&kind, &agentID, &targetID, &body, &payload)
+	if err == sql.ErrNoRows {
+		return notFoundError("quarantined content not found")
+	}
+	if err != nil {
+		return fmt.Errorf("query quarantined content: %w", err)
+	}
+	agent, err := scanAgent(db.QueryRowContext(ctx, "SELECT "+agentColumns+" FROM agents WHERE id = ?", agentID))
+	if err != nil {
+		return fmt.Errorf("query quarantined content agent: %w", err)
+	}
+
+	ctx = context.WithValue(ctx, reviewedContextKey, true)
+	switch kind {
+	case quarantinedThread:
+		var in quarantinedThreadInput
+		if err := json.Unmarshal([]byte(payload), &in); err != nil {
+			return fmt.Errorf("decode quarantined thread: %w", err)
+		}
+		_, err = createThread(ctx, db, bus, &agent, in.Title, body, in.Tags, in.DueAt, in.Priority, in.PublishAt, in.Visibility, in.Participants)
+	case quarantinedReply:
+		var in quarantinedReplyInput
+		if err := json.Unmarshal([]byte(payload), &in); err != nil {
+			return fmt.Errorf("decode quarantined reply: %w", err)
+		}
+		_, err = createReply(ctx, db, bus, &agent, targetID, body, in.ParentReplyID)
+	case quarantinedThreadEdit:
+		err = applyQuarantinedEdit(ctx, db, "threads", targetID, targetID, nil, agentID, body)
+	case quarantinedReplyEdit:
+		var threadID string
+		if err := db.QueryRowContext(ctx, "SELECT thread_id FROM replies WHERE id = ?", targetID).Scan(&threadID); err == sql.ErrNoRows {
+			return notFoundError("the edited reply no longer exists")
+		} else if err != nil {
+			return fmt.Errorf("query edited reply: %w", err)
+		}
+		err = applyQuarantinedEdit(ctx, db, "replies", targetID, threadID, &targetID, agentID, body)
+	default:
+		return fmt.Errorf("unknown quarantined content kind %q", kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, "DELETE FROM quarantine WHERE id = ?", id); err != nil {
+		return fmt.Errorf("delete quarantined content: %w", err)
+	}
+	return nil
+}
+
+// applyQuarantinedEdit sets the body of a thread or reply from an approved edit and
+// records its mentions and references. table must be a trusted constant.
+func applyQuarantinedEdit(ctx context.Context, db *sql.DB, table, id, threadID string, replyID *string, agentID, body string) error {
+	res, err := db.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET body = ?, updated_at = ? WHERE id = ?", table), body, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("apply quarantined edit: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return notFoundError("the edited content no longer exists")
+	}
+	if err := recordMentions(ctx, db, threadID, replyID, agentID, body); err != nil {
+		log.Printf("record quarantined edit mentions: %v", err)
+	}
+	if err := recordReferences(ctx, db, threadID, replyID, body); err != nil {
+		log.Printf("record quarantined edit references: %v", err)
+	}
+	return nil
+}
+
+// handleAdminFilters lists the content filters and the content awaiting
+// review.
+func handleAdminFilters(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	filters, err := listContentFilters(r.Context(), db, false)
+	if err != nil {
+		log.Printf("admin filters query error: %v", err)
+		http.Error(w, "failed to load filters", http.StatusInternalServerError)
+		return
+	}
+	quarantine, err := listQuarantine(r.Context(), db)
+	if err != nil {
+		log.Printf("admin quarantined content query error: %v", err)
+		http.Error(w, "failed to load quarantined content", http.StatusInternalServerError)
+		return
+	}
+
+	renderAdminTemplate(w, r, "filters.html", map[string]interface{}{
+		"Filters":    filters,
+		"Quarantine": quarantine,
+	})
+}
+
+// handleAdminCreateFilter creates a content filter.
+func handleAdminCreateFilter(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	f := ContentFilter{
+		Name:    r.FormValue("name"),
+		Kind:    r.FormValue("kind"),
+		Pattern: r.FormValue("pattern"),
+		Action:  r.FormValue("action"),
+	}
+	if f.Kind == filterLinks {
+		n, err := strconv.Atoi(r.FormValue("max_links"))
+		if err != nil {
+			http.Error(w, "max links must be a number", http.StatusBadRequest)
+			return
+		}
+		f.MaxLinks = n
+	}
+
+	_, err := createContentFilter(r.Context(), db, f)
+	if _, ok := err.(inputError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("admin create filter: %v", err)
+		http.Error(w, "failed to create filter", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/filters", http.StatusSeeOther)
+}
+
+// handleAdminToggleFilter enables or disables a content filter.
+func handleAdminToggleFilter(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	filterID := r.PathValue("id")
+	if filterID == "" {
+		http.Error(w, "missing filter id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "UPDATE content_filters SET enabled = NOT enabled WHERE id = ?", filterID); err != nil {
+		log.Printf("admin toggle filter error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/filters", http.StatusSeeOther)
+}
+
+// handleAdminDeleteFilter deletes a content filter.
+func handleAdminDeleteFilter(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	filterID := r.PathValue("id")
+	if filterID == "" {
+		http.Error(w, "missing filter id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM content_filters WHERE id = ?", filterID); err != nil {
+		log.Printf("admin delete filter error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/filters", http.StatusSeeOther)
+}
+
+// handleAdminApproveQuarantined writes quarantined content and removes it from review.
+func handleAdminApproveQuarantined(db *sql.DB, bus *EventBus, w http.ResponseWriter, r *http.Request) {
+	err := approveQuarantined(r.Context(), db, bus, r.PathValue("id"))
+	switch err.(type) {
+	case nil:
+	case inputError, notFoundError, conflictError:
+		http.Error(w, "can't approve: "+err.Error(), http.StatusBadRequest)
+		return
+	default:
+		log.Printf("admin approve quarantined content: %v", err)
+		http.Error(w, "failed to approve quarantined content", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/filters", http.StatusSeeOther)
+}
+
+// handleAdminDiscardQuarantined drops quarantined content without writing it.
+func handleAdminDiscardQuarantined(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	quarantinedID := r.PathValue("id")
+	if quarantinedID == "" {
+		http.Error(w, "missing quarantined content id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM quarantine WHERE id = ?", quarantinedID); err != nil {
+		log.Printf("admin discard quarantined content error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/filters", http.StatusSeeOther)
+}
@@ -0,0 +1,206 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// dependencyNodes returns the distinct nodes of edges in order of first
+// appearance.
+func dependencyNodes(edges []DependencyEdge) []DependencyNode {
+	nodes := []DependencyNode{}
+	seen := map[string]bool{}
+	for _, e := range edges {
+		for _, n := range []DependencyNode{e.Source, e.DependsOn} {
+			if !seen[n.ID] {
+				seen[n.ID] = true
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	return nodes
+}
+
+// renderDependencyDOT writes the dependency graph in Graphviz DOT. Each
+// node is labeled with its title and author; blocked edges are red.
+func renderDependencyDOT(edges []DependencyEdge) string {
+	quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", " ")
+
+	var b strings.Builder
+	b.WriteString("digraph dependencies {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, n := range dependencyNodes(edges) {
+		fmt.Fprintf(&b, "  \"%s\" [label=\"%s\\n%s\"];\n", quote.Replace(n.ID), quote.Replace(n.Title), quote.Replace(n.AgentName))
+	}
+	for _, e := range edges {
+		attrs := fmt.Sprintf("label=\"%s\"", e.Status)
+		if e.Status == statusBlocked {
+			attrs += ", color=red, fontcolor=red"
+		}
+		fmt.Fprintf(&b, "  \"%s\" -> \"%s\" [%s];\n", quote.Replace(e.Source.ID), quote.Replace(e.DependsOn.ID), attrs)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// renderDependencyMermaid writes the dependency graph as a Mermaid
+// flowchart. Node IDs are numbered since Mermaid IDs can't hold every
+// character; blocked edges are thick.
+func renderDependencyMermaid(edges []DependencyEdge) string {
+	quote := strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;", "\n", " ")
+
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	ids := map[string]string{}
+	for i, n := range dependencyNodes(edges) {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(&b, "  %s[\"%s<br/><small>%s</small>\"]\n", ids[n.ID], quote.Replace(n.Title), quote.Replace(n.AgentName))
+	}
+	for _, e := range edges {
+		arrow := "-->"
+		if e.Status == statusBlocked {
+			arrow = "==>"
+		}
+		fmt.Fprintf(&b, "  %s %s|%s| %s\n", ids[e.Source.ID], arrow, e.Status, ids[e.DependsOn.ID])
+	}
+	return b.String()
+}
+
+// dependencyGraphNode is a thread in the dashboard's dependency graph.
+type dependencyGraphNode struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	AgentName string `json:"agent_name"`
+	Status    string `json:"status"`
+	Blocked   bool   `json:"blocked"`
+	// InCycle is set on threads that wait, through some chain of
+	// dependencies, on themselves.
+	InCycle bool `json:"in_cycle"`
+}
+
+// dependencyGraphEdge records that the thread Source waits on the thread
+// Target through a depends-on or blocked tag.
+type dependencyGraphEdge struct {
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Tag     string `json:"tag"`
+	InCycle bool   `json:"in_cycle"`
+}
+
+// publicDependencyGraph returns the dependencies in effect between public
+// threads, counted between threads as in dependencyGraph, with each
+// thread's status and the threads and edges on cycles marked.
+func publicDependencyGraph(ctx context.Context, db *sql.DB) ([]dependencyGraphNode, []dependencyGraphEdge, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT DISTINCT COALESCE(s.thread_id, r_src.thread_id), COALESCE(t_ref.id, r_ref.thread_id), s.tag
+		FROM status_tags s
+		LEFT JOIN replies r_src ON s.reply_id = r_src.id
+		LEFT JOIN threads t_ref ON s.reference_id = t_ref.id
+		LEFT JOIN replies r_ref ON s.reference_id = r_ref.id
+		WHERE s.tag IN ('depends-on', 'blocked')
+		AND s.reference_id IS NOT NULL AND s.superseded_by IS NULL`,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query dependencies: %w", err)
+	}
+	defer rows.Close()
+
+	var edges []dependencyGraphEdge
+	var ids []interface{}
+	seen := map[string]bool{}
+	for rows.Next() {
+		var from, to *string
+		var tag string
+		if err := rows.Scan(&from, &to, &tag); err != nil {
+			return nil, nil, fmt.Errorf("scan dependency: %w", err)
+		}
+		if from == nil || to == nil || *from == *to {
+			continue
+		}
+		edges = append(edges, dependencyGraphEdge{Source: *from, Target: *to, Tag: tag})
+		for _, id := range []string{*from, *to} {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("iterate dependencies: %w", err)
+	}
+
+	nodes := []dependencyGraphNode{}
+	if len(ids) > 0 {
+		rows, err := db.QueryContext(ctx,
+			`SELECT t.id, t.title, a.name, `+currentStatusColumn+`, `+blockedColumn+`
+			FROM threads t
+			JOIN agents a ON t.agent_id = a.id
+			WHERE t.id IN (?`+strings.Repeat(", ?", len(ids)-1)+`) AND `+publicCondition+`
+			ORDER BY t.created_at`, ids...,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("query dependency threads: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var n dependencyGraphNode
+			if err := rows.Scan(&n.ID, &n.Title, &n.AgentName, &n.Status, &n.Blocked); err != nil {
+				return nil, nil, fmt.Errorf("scan dependency thread: %w", err)
+			}
+			nodes = append(nodes, n)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, nil, fmt.Errorf("iterate dependency threads: %w", err)
+		}
+	}
+
+	// Leave out edges to or from threads that aren't public, then find the
+	// cycles among the rest.
+	public := map[string]bool{}
+	for _, n := range nodes {
+		public[n.ID] = true
+	}
+	visible := []dependencyGraphEdge{}
+	graph := map[string][]string{}
+	for _, e := range edges {
+		if public[e.Source] && public[e.Target] {
+			visible = append(visible, e)
+			graph[e.Source] = append(graph[e.Source], e.Target)
+		}
+	}
+	component := map[string]int{}
+	for i, c := range stronglyConnected(graph) {
+		if len(c) > 1 {
+			for _, id := range c {
+				component[id] = i + 1
+			}
+		}
+	}
+	for i := range nodes {
+		nodes[i].InCycle = component[nodes[i].ID] != 0
+	}
+	for i, e := range visible {
+		visible[i].InCycle = component[e.Source] != 0 && component[e.Source] == component[e.Target]
+	}
+	return nodes, visible, nil
+}
+
+// handleDashboardDependencyGraph returns the dependencies between public
+// threads as JSON for the dashboard's graph.
+func handleDashboardDependencyGraph(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	nodes, edges, err := publicDependencyGraph(r.Context(), db)
+	if err != nil {
+		log.Printf("dashboard dependency graph error: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load dependencies"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"nodes": nodes,
+		"edges": edges,
+	})
+}
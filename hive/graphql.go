@@ -0,0 +1,1551 @@
+package hive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// This file implements the subset of GraphQL the forum API needs: query
+// operations with fields, aliases, arguments, variables, fragments, and the
+// @skip/@include directives, executed against object types declared in Go.
+// Mutations, subscriptions, interfaces, unions, enums, and introspection are
+// not supported; the schema is published as SDL instead (see gqlSchema.sdl).
+
+// gqlMaxDepth bounds how deeply selections may nest, so a single query
+// cannot walk thread → replies → thread → ... indefinitely.
+const gqlMaxDepth = 10
+
+// gqlMaxCost bounds the work a single query may ask for, counted in objects
+// returned and resolver calls. Depth alone doesn't bound it: a few nested
+// lists of 100 multiply to millions of queries. Queries are costed before
+// they run, assuming every list comes back full, and execution stops at the
+// same budget in case a list without a limit comes back longer than
+// assumed.
+const gqlMaxCost = 10000
+
+// gqlDefaultListSize is how long a list without a limit argument or a
+// declared maxItems is assumed to be when costing a query.
+const gqlDefaultListSize = 10
+
+// gqlLocation is a line and column in a query document, both 1-based.
+type gqlLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// gqlError is an error as it appears in a GraphQL response.
+type gqlError struct {
+	Message   string        `json:"message"`
+	Locations []gqlLocation `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+func (e *gqlError) Error() string { return e.Message }
+
+func gqlErrorf(loc gqlLocation, format string, args ...interface{}) *gqlError {
+	return &gqlError{Message: fmt.Sprintf(format, args...), Locations: []gqlLocation{loc}}
+}
+
+// --- Lexer ---
+
+type gqlTokenKind int
+
+const (
+	gqlEOF gqlTokenKind = iota
+	gqlPunct
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+type gqlToken struct {
+	kind  gqlTokenKind
+	value string
+	loc   gqlLocation
+}
+
+// gqlLexer splits a query document into tokens. Like the parser, it reports
+// syntax errors by panicking with a *gqlError, recovered in parseGraphQL.
+type gqlLexer struct {
+	src       string
+	pos       int
+	line      int
+	lineStart int
+}
+
+func (l *gqlLexer) loc() gqlLocation {
+	return gqlLocation{Line: l.line, Column: l.pos - l.lineStart + 1}
+}
+
+func (l *gqlLexer) peekByte() byte {
+	if l.pos < len(l.src) {
+		return l.src[l.pos]
+	}
+	return 0
+}
+
+func (l *gqlLexer) newline() {
+	l.line++
+	l.lineStart = l.pos
+}
+
+// skipIgnored skips whitespace, commas, and comments.
+func (l *gqlLexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case ' ', '\t', ',':
+			l.pos++
+		case '\n':
+			l.pos++
+			l.newline()
+		case '\r':
+			l.pos++
+			if l.peekByte() == '\n' {
+				l.pos++
+			}
+			l.newline()
+		case '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' && l.src[l.pos] != '\r' {
+				l.pos++
+			}
+		default:
+			if strings.HasPrefix(l.src[l.pos:], "\ufeff") {
+				l.pos += len("\ufeff")
+				continue
+			}
+			return
+		}
+	}
+}
+
+func isGQLNameStart(c byte) bool {
+	return c == '_' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+func isGQLDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *gqlLexer) next() gqlToken {
+	l.skipIgnored()
+	loc := l.loc()
+	if l.pos >= len(l.src) {
+		return gqlToken{kind: gqlEOF, loc: loc}
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.IndexByte("!$&():=@[]{}|", c) >= 0:
+		l.pos++
+		return gqlToken{kind: gqlPunct, value: string(c), loc: loc}
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return gqlToken{kind: gqlPunct, value: "...", loc: loc}
+	case isGQLNameStart(c):
+		start := l.pos
+		for l.pos < len(l.src) && (isGQLNameStart(l.src[l.pos]) || isGQLDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return gqlToken{kind: gqlName, value: l.src[start:l.pos], loc: loc}
+	case c == '-' || isGQLDigit(c):
+		return l.number(loc)
+	case strings.HasPrefix(l.src[l.pos:], `"""`):
+		return l.blockString(loc)
+	case c == '"':
+		return l.string(loc)
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	panic(gqlErrorf(loc, "Syntax Error: Unexpected character %q.", r))
+}
+
+func (l *gqlLexer) digits() {
+	if !isGQLDigit(l.peekByte()) {
+		panic(gqlErrorf(l.loc(), "Syntax Error: Invalid number, expected digit."))
+	}
+	for isGQLDigit(l.peekByte()) {
+		l.pos++
+	}
+}
+
+func (l *gqlLexer) number(loc gqlLocation) gqlToken {
+	start := l.pos
+	kind := gqlInt
+	if l.peekByte() == '-' {
+		l.pos++
+	}
+	if l.peekByte() == '0' {
+		l.pos++
+		if isGQLDigit(l.peekByte()) {
+			panic(gqlErrorf(l.loc(), "Syntax Error: Invalid number, unexpected digit after 0."))
+		}
+	} else {
+		l.digits()
+	}
+	if l.peekByte() == '.' {
+		kind = gqlFloat
+		l.pos++
+		l.digits()
+	}
+	if c := l.peekByte(); c == 'e' || c == 'E' {
+		kind = gqlFloat
+		l.pos++
+		if c := l.peekByte(); c == '+' || c == '-' {
+			l.pos++
+		}
+		l.digits()
+	}
+	if c := l.peekByte(); c == '.' || isGQLNameStart(c) {
+		panic(gqlErrorf(l.loc(), "Syntax Error: Invalid number, unexpected %q.", c))
+	}
+	return gqlToken{kind: kind, value: l.src[start:l.pos], loc: loc}
+}
+
+func (l *gqlLexer) string(loc gqlLocation) gqlToken {
+	l.pos++ // opening quote
+	var b strings.Builder
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' || l.src[l.pos] == '\r' {
+			panic(gqlErrorf(l.loc(), "Syntax Error: Unterminated string."))
+		}
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return gqlToken{kind: gqlString, value: b.String(), loc: loc}
+		case '\\':
+			escLoc := l.loc()
+			l.pos++
+			esc := l.peekByte()
+			l.pos++
+			switch esc {
+			case '"', '\\', '/':
+				b.WriteByte(esc)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					panic(gqlErrorf(escLoc, "Syntax Error: Invalid Unicode escape sequence."))
+				}
+				n, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					panic(gqlErrorf(escLoc, "Syntax Error: Invalid Unicode escape sequence."))
+				}
+				b.WriteRune(rune(n))
+				l.pos += 4
+			default:
+				panic(gqlErrorf(escLoc, "Syntax Error: Invalid character escape sequence \\%c.", esc))
+			}
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+}
+
+func (l *gqlLexer) blockString(loc gqlLocation) gqlToken {
+	l.pos += 3
+	var raw strings.Builder
+	for {
+		if l.pos >= len(l.src) {
+			panic(gqlErrorf(l.loc(), "Syntax Error: Unterminated string."))
+		}
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			l.pos += 3
+			return gqlToken{kind: gqlString, value: blockStringValue(raw.String()), loc: loc}
+		}
+		if strings.HasPrefix(l.src[l.pos:], `\"""`) {
+			raw.WriteString(`"""`)
+			l.pos += 4
+			continue
+		}
+		c := l.src[l.pos]
+		raw.WriteByte(c)
+		l.pos++
+		if c == '\n' || (c == '\r' && l.peekByte() != '\n') {
+			l.newline()
+		}
+	}
+}
+
+// blockStringValue removes the common indentation and surrounding blank
+// lines from a block string, as the spec requires.
+func blockStringValue(raw string) string {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	lines := strings.Split(strings.ReplaceAll(raw, "\r", "\n"), "\n")
+
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+
+	for len(lines) > 0 && strings.TrimLeft(lines[0], " \t") == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimLeft(lines[len(lines)-1], " \t") == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// --- Parser ---
+
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlFragment
+}
+
+type gqlOperation struct {
+	kind       string // query, mutation, or subscription
+	name       string
+	vars       []*gqlVarDef
+	selections []gqlSelection
+	loc        gqlLocation
+}
+
+type gqlVarDef struct {
+	name         string
+	typ          *gqlTypeRef
+	defaultValue *gqlValue
+	loc          gqlLocation
+}
+
+type gqlFragment struct {
+	name          string
+	typeCondition string
+	selections    []gqlSelection
+	loc           gqlLocation
+}
+
+// gqlSelection is a *gqlFieldNode, *gqlFragmentSpread, or *gqlInlineFragment.
+type gqlSelection interface{}
+
+type gqlFieldNode struct {
+	alias      string
+	name       string
+	args       []*gqlArgNode
+	directives []*gqlDirective
+	selections []gqlSelection
+	loc        gqlLocation
+}
+
+func (f *gqlFieldNode) arg(name string) *gqlArgNode {
+	for _, a := range f.args {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+type gqlFragmentSpread struct {
+	name       string
+	directives []*gqlDirective
+	loc        gqlLocation
+}
+
+type gqlInlineFragment struct {
+	typeCondition string
+	directives    []*gqlDirective
+	selections    []gqlSelection
+	loc           gqlLocation
+}
+
+type gqlArgNode struct {
+	name  string
+	value gqlValue
+	loc   gqlLocation
+}
+
+type gqlDirective struct {
+	name string
+	args []*gqlArgNode
+	loc  gqlLocation
+}
+
+type gqlValueKind int
+
+const (
+	gqlVariableValue gqlValueKind = iota
+	gqlIntValue
+	gqlFloatValue
+	gqlStringValue
+	gqlBooleanValue
+	gqlNullValue
+	gqlEnumValue
+	gqlListValue
+	gqlObjectValue
+)
+
+// gqlValue is a literal or variable reference in a query document.
+type gqlValue struct {
+	kind   gqlValueKind
+	raw    string // name for variables and enums, text for scalars
+	list   []gqlValue
+	fields []gqlObjectField
+	loc    gqlLocation
+}
+
+type gqlObjectField struct {
+	name  string
+	value gqlValue
+}
+
+// gqlEnumLiteral is an enum value written in a query. The schema has no enum
+// types, so it never coerces to a scalar.
+type gqlEnumLiteral string
+
+// gqlTypeRef is a type reference such as [Thread!]!.
+type gqlTypeRef struct {
+	name    string      // named type, when elem is nil
+	elem    *gqlTypeRef // element type of a list
+	nonNull bool
+}
+
+func (t *gqlTypeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// namedType returns the type name with list and non-null wrappers removed.
+func (t *gqlTypeRef) namedType() string {
+	for t.elem != nil {
+		t = t.elem
+	}
+	return t.name
+}
+
+type gqlParser struct {
+	lex *gqlLexer
+	tok gqlToken
+}
+
+func newGQLParser(src string) *gqlParser {
+	p := &gqlParser{lex: &gqlLexer{src: src, line: 1}}
+	p.advance()
+	return p
+}
+
+// parseGraphQL parses a query document.
+func parseGraphQL(src string) (doc *gqlDocument, err *gqlError) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*gqlError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, e
+		}
+	}()
+
+	p := newGQLParser(src)
+	doc = &gqlDocument{fragments: map[string]*gqlFragment{}}
+	for p.tok.kind != gqlEOF {
+		switch {
+		case p.peek("{"):
+			op := &gqlOperation{kind: "query", loc: p.tok.loc}
+			op.selections = p.selectionSet()
+			doc.operations = append(doc.operations, op)
+		case p.keyword("query"), p.keyword("mutation"), p.keyword("subscription"):
+			doc.operations = append(doc.operations, p.operation())
+		case p.keyword("fragment"):
+			f := p.fragment()
+			if _, dup := doc.fragments[f.name]; dup {
+				panic(gqlErrorf(f.loc, "There can be only one fragment named %q.", f.name))
+			}
+			doc.fragments[f.name] = f
+		default:
+			panic(p.unexpected())
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &gqlError{Message: "Document contains no operations."}
+	}
+	return doc, nil
+}
+
+// mustParseGQLType parses a type reference declared in the schema.
+func mustParseGQLType(s string) *gqlTypeRef {
+	p := newGQLParser(s)
+	t := p.typeRef()
+	if p.tok.kind != gqlEOF {
+		panic(fmt.Sprintf("graphql: invalid type reference %q", s))
+	}
+	return t
+}
+
+func (p *gqlParser) advance() {
+	p.tok = p.lex.next()
+}
+
+func (p *gqlParser) peek(punct string) bool {
+	return p.tok.kind == gqlPunct && p.tok.value == punct
+}
+
+func (p *gqlParser) keyword(name string) bool {
+	return p.tok.kind == gqlName && p.tok.value == name
+}
+
+func (p *gqlParser) unexpected() *gqlError {
+	switch p.tok.kind {
+	case gqlEOF:
+		return gqlErrorf(p.tok.loc, "Syntax Error: Unexpected <EOF>.")
+	case gqlString:
+		return gqlErrorf(p.tok.loc, "Syntax Error: Unexpected string %q.", p.tok.value)
+	}
+	return gqlErrorf(p.tok.loc, "Syntax Error: Unexpected %q.", p.tok.value)
+}
+
+func (p *gqlParser) expect(punct string) {
+	if !p.peek(punct) {
+		panic(p.unexpected())
+	}
+	p.advance()
+}
+
+func (p *gqlParser) name() string {
+	if p.tok.kind != gqlName {
+		panic(p.unexpected())
+	}
+	name := p.tok.value
+	p.advance()
+	return name
+}
+
+func (p *gqlParser) operation() *gqlOperation {
+	op := &gqlOperation{kind: p.tok.value, loc: p.tok.loc}
+	p.advance()
+	if p.tok.kind == gqlName {
+		op.name = p.name()
+	}
+	if p.peek("(") {
+		p.advance()
+		for !p.peek(")") {
+			v := &gqlVarDef{loc: p.tok.loc}
+			p.expect("$")
+			v.name = p.name()
+			p.expect(":")
+			v.typ = p.typeRef()
+			if p.peek("=") {
+				p.advance()
+				d := p.value(true)
+				v.defaultValue = &d
+			}
+			p.directives()
+			op.vars = append(op.vars, v)
+		}
+		p.advance()
+	}
+	p.directives()
+	op.selections = p.selectionSet()
+	return op
+}
+
+func (p *gqlParser) fragment() *gqlFragment {
+	f := &gqlFragment{loc: p.tok.loc}
+	p.advance()
+	if p.keyword("on") {
+		panic(p.unexpected())
+	}
+	f.name = p.name()
+	if !p.keyword("on") {
+		panic(p.unexpected())
+	}
+	p.advance()
+	f.typeCondition = p.name()
+	p.directives()
+	f.selections = p.selectionSet()
+	return f
+}
+
+func (p *gqlParser) typeRef() *gqlTypeRef {
+	var t *gqlTypeRef
+	if p.peek("[") {
+		p.advance()
+		t = &gqlTypeRef{elem: p.typeRef()}
+		p.expect("]")
+	} else {
+		t = &gqlTypeRef{name: p.name()}
+	}
+	if p.peek("!") {
+		p.advance()
+		t.nonNull = true
+	}
+	return t
+}
+
+func (p *gqlParser) selectionSet() []gqlSelection {
+	p.expect("{")
+	if p.peek("}") {
+		panic(p.unexpected())
+	}
+	var sels []gqlSelection
+	for !p.peek("}") {
+		sels = append(sels, p.selection())
+	}
+	p.advance()
+	return sels
+}
+
+func (p *gqlParser) selection() gqlSelection {
+	if p.peek("...") {
+		loc := p.tok.loc
+		p.advance()
+		if p.tok.kind == gqlName && p.tok.value != "on" {
+			return &gqlFragmentSpread{name: p.name(), directives: p.directives(), loc: loc}
+		}
+		frag := &gqlInlineFragment{loc: loc}
+		if p.keyword("on") {
+			p.advance()
+			frag.typeCondition = p.name()
+		}
+		frag.directives = p.directives()
+		frag.selections = p.selectionSet()
+		return frag
+	}
+
+	f := &gqlFieldNode{loc: p.tok.loc, name: p.name()}
+	if p.peek(":") {
+		p.advance()
+		f.alias = f.name
+		f.name = p.name()
+	}
+	f.args = p.arguments(false)
+	f.directives = p.directives()
+	if p.peek("{") {
+		f.selections = p.selectionSet()
+	}
+	return f
+}
+
+func (p *gqlParser) arguments(constant bool) []*gqlArgNode {
+	if !p.peek("(") {
+		return nil
+	}
+	p.advance()
+	var args []*gqlArgNode
+	for !p.peek(")") {
+		a := &gqlArgNode{loc: p.tok.loc, name: p.name()}
+		p.expect(":")
+		a.value = p.value(constant)
+		args = append(args, a)
+	}
+	p.advance()
+	return args
+}
+
+func (p *gqlParser) directives() []*gqlDirective {
+	var dirs []*gqlDirective
+	for p.peek("@") {
+		d := &gqlDirective{loc: p.tok.loc}
+		p.advance()
+		d.name = p.name()
+		d.args = p.arguments(false)
+		dirs = append(dirs, d)
+	}
+	return dirs
+}
+
+// value parses a value. Variables are not allowed in constant values such as
+// variable defaults.
+func (p *gqlParser) value(constant bool) gqlValue {
+	v := gqlValue{loc: p.tok.loc, raw: p.tok.value}
+	switch p.tok.kind {
+	case gqlPunct:
+		switch p.tok.value {
+		case "$":
+			if constant {
+				break
+			}
+			p.advance()
+			v.kind = gqlVariableValue
+			v.raw = p.name()
+			return v
+		case "[":
+			p.advance()
+			v.kind = gqlListValue
+			for !p.peek("]") {
+				v.list = append(v.list, p.value(constant))
+			}
+			p.advance()
+			return v
+		case "{":
+			p.advance()
+			v.kind = gqlObjectValue
+			for !p.peek("}") {
+				name := p.name()
+				p.expect(":")
+				v.fields = append(v.fields, gqlObjectField{name: name, value: p.value(constant)})
+			}
+			p.advance()
+			return v
+		}
+	case gqlInt:
+		v.kind = gqlIntValue
+		p.advance()
+		return v
+	case gqlFloat:
+		v.kind = gqlFloatValue
+		p.advance()
+		return v
+	case gqlString:
+		v.kind = gqlStringValue
+		p.advance()
+		return v
+	case gqlName:
+		switch p.tok.value {
+		case "true", "false":
+			v.kind = gqlBooleanValue
+		case "null":
+			v.kind = gqlNullValue
+		default:
+			v.kind = gqlEnumValue
+		}
+		p.advance()
+		return v
+	}
+	panic(p.unexpected())
+}
+
+// --- Schema ---
+
+// gqlObject is an object type in a schema.
+type gqlObject struct {
+	name        string
+	description string
+	fields      []*gqlField
+}
+
+func (o *gqlObject) field(name string) *gqlField {
+	for _, f := range o.fields {
+		if f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// gqlField is a field of an object type.
+type gqlField struct {
+	name        string
+	typ         string // type reference in SDL, e.g. "[Thread!]!"
+	description string
+	args        []*gqlArg
+	// resolve computes the field's value from its parent. When nil, the
+	// struct field with the same JSON name is read from the parent.
+	resolve func(p gqlParams) (interface{}, error)
+	// maxItems is, for list fields without a limit argument, about how many
+	// items they return, for costing queries. Zero means
+	// gqlDefaultListSize.
+	maxItems int
+
+	typeRef *gqlTypeRef
+}
+
+func (f *gqlField) arg(name string) *gqlArg {
+	for _, a := range f.args {
+		if a.name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// gqlArg is an argument of a field. Arguments are scalars or lists of
+// scalars.
+type gqlArg struct {
+	name         string
+	typ          string
+	defaultValue interface{} // nil for no default
+
+	typeRef *gqlTypeRef
+}
+
+// gqlParams is passed to field resolvers.
+type gqlParams struct {
+	ctx    context.Context
+	source interface{}
+	args   map[string]interface{}
+}
+
+var gqlScalars = map[string]bool{"ID": true, "String": true, "Int": true, "Float": true, "Boolean": true}
+
+// gqlSchema is an executable schema.
+type gqlSchema struct {
+	query   *gqlObject
+	types   []*gqlObject
+	objects map[string]*gqlObject
+}
+
+// newGQLSchema builds a schema from its root query type and the other object
+// types it references. It panics on undeclared types, since the schema is
+// fixed at compile time.
+func newGQLSchema(query *gqlObject, types ...*gqlObject) *gqlSchema {
+	s := &gqlSchema{query: query, types: append([]*gqlObject{query}, types...), objects: map[string]*gqlObject{}}
+	for _, o := range s.types {
+		s.objects[o.name] = o
+	}
+	for _, o := range s.types {
+		for _, f := range o.fields {
+			f.typeRef = mustParseGQLType(f.typ)
+			if name := f.typeRef.namedType(); s.objects[name] == nil && !gqlScalars[name] {
+				panic(fmt.Sprintf("graphql: %s.%s has unknown type %s", o.name, f.name, name))
+			}
+			for _, a := range f.args {
+				a.typeRef = mustParseGQLType(a.typ)
+				if !gqlScalars[a.typeRef.namedType()] {
+					panic(fmt.Sprintf("graphql: %s.%s argument %s is not a scalar", o.name, f.name, a.name))
+				}
+			}
+		}
+	}
+	return s
+}
+
+// sdl renders the schema in the GraphQL schema definition language.
+func (s *gqlSchema) sdl() string {
+	var b strings.Builder
+	for i, o := range s.types {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if o.description != "" {
+			fmt.Fprintf(&b, "%s\n", strconv.Quote(o.description))
+		}
+		fmt.Fprintf(&b, "type %s {\n", o.name)
+		for _, f := range o.fields {
+			if f.description != "" {
+				fmt.Fprintf(&b, "  %s\n", strconv.Quote(f.description))
+			}
+			b.WriteString("  " + f.name)
+			if len(f.args) > 0 {
+				args := make([]string, len(f.args))
+				for j, a := range f.args {
+					args[j] = a.name + ": " + a.typ
+					if a.defaultValue != nil {
+						d, _ := json.Marshal(a.defaultValue)
+						args[j] += " = " + string(d)
+					}
+				}
+				b.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			b.WriteString(": " + f.typ + "\n")
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+// --- Validation ---
+
+type gqlValidator struct {
+	schema    *gqlSchema
+	doc       *gqlDocument
+	op        *gqlOperation
+	spreading map[string]bool
+	errs      []*gqlError
+}
+
+func (v *gqlValidator) errorf(loc gqlLocation, format string, args ...interface{}) {
+	v.errs = append(v.errs, gqlErrorf(loc, format, args...))
+}
+
+func (v *gqlValidator) validate() []*gqlError {
+	if v.op.kind != "query" {
+		v.errorf(v.op.loc, "Only query operations are supported, not %s.", v.op.kind)
+		return v.errs
+	}
+	seen := map[string]bool{}
+	for _, d := range v.op.vars {
+		if seen[d.name] {
+			v.errorf(d.loc, "There can be only one variable named \"$%s\".", d.name)
+		}
+		seen[d.name] = true
+		if !gqlScalars[d.typ.namedType()] {
+			v.errorf(d.loc, "Variable \"$%s\" cannot be non-input type %q.", d.name, d.typ)
+		}
+	}
+	v.selections(v.schema.query, v.op.selections, 1)
+	return v.errs
+}
+
+func (v *gqlValidator) selections(t *gqlObject, sels []gqlSelection, depth int) {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *gqlFieldNode:
+			v.directives(s.directives)
+			if s.name == "__typename" {
+				if len(s.selections) > 0 {
+					v.errorf(s.loc, "Field \"__typename\" must not have a selection since type \"String!\" has no subfields.")
+				}
+				continue
+			}
+			f := t.field(s.name)
+			if f == nil {
+				v.errorf(s.loc, "Cannot query field %q on type %q.", s.name, t.name)
+				continue
+			}
+			v.arguments(f, s)
+			obj := v.schema.objects[f.typeRef.namedType()]
+			switch {
+			case obj != nil && len(s.selections) == 0:
+				v.errorf(s.loc, "Field %q of type %q must have a selection of subfields.", s.name, f.typ)
+			case obj == nil && len(s.selections) > 0:
+				v.errorf(s.loc, "Field %q must not have a selection since type %q has no subfields.", s.name, f.typ)
+			case obj != nil && depth >= gqlMaxDepth:
+				v.errorf(s.loc, "Query exceeds the maximum depth of %d.", gqlMaxDepth)
+			case obj != nil:
+				v.selections(obj, s.selections, depth+1)
+			}
+		case *gqlInlineFragment:
+			v.directives(s.directives)
+			if s.typeCondition != "" && !v.typeCondition(s.typeCondition, t, s.loc) {
+				continue
+			}
+			v.selections(t, s.selections, depth)
+		case *gqlFragmentSpread:
+			v.directives(s.directives)
+			frag := v.doc.fragments[s.name]
+			if frag == nil {
+				v.errorf(s.loc, "Unknown fragment %q.", s.name)
+				continue
+			}
+			if v.spreading[s.name] {
+				v.errorf(s.loc, "Cannot spread fragment %q within itself.", s.name)
+				continue
+			}
+			if !v.typeCondition(frag.typeCondition, t, s.loc) {
+				continue
+			}
+			v.spreading[s.name] = true
+			v.selections(t, frag.selections, depth)
+			delete(v.spreading, s.name)
+		}
+	}
+}
+
+func (v *gqlValidator) typeCondition(name string, t *gqlObject, loc gqlLocation) bool {
+	if v.schema.objects[name] == nil {
+		v.errorf(loc, "Unknown type %q.", name)
+		return false
+	}
+	if name != t.name {
+		v.errorf(loc, "Fragment cannot be spread here as objects of type %q can never be of type %q.", t.name, name)
+		return false
+	}
+	return true
+}
+
+func (v *gqlValidator) arguments(f *gqlField, node *gqlFieldNode) {
+	for _, a := range node.args {
+		if f.arg(a.name) == nil {
+			v.errorf(a.loc, "Unknown argument %q on field %q.", a.name, f.name)
+		}
+		v.variables(a.value)
+	}
+	for _, a := range f.args {
+		if a.typeRef.nonNull && a.defaultValue == nil && node.arg(a.name) == nil {
+			v.errorf(node.loc, "Field %q argument %q of type %q is required, but it was not provided.", f.name, a.name, a.typ)
+		}
+	}
+}
+
+func (v *gqlValidator) directives(dirs []*gqlDirective) {
+	for _, d := range dirs {
+		if d.name != "skip" && d.name != "include" {
+			v.errorf(d.loc, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		found := false
+		for _, a := range d.args {
+			if a.name != "if" {
+				v.errorf(a.loc, "Unknown argument %q on directive \"@%s\".", a.name, d.name)
+			}
+			found = true
+			v.variables(a.value)
+		}
+		if !found {
+			v.errorf(d.loc, "Directive \"@%s\" argument \"if\" of type \"Boolean!\" is required, but it was not provided.", d.name)
+		}
+	}
+}
+
+// cost estimates the work of running sels on an object of type t: one for
+// each object returned and each resolver called, taking lists to be as
+// long as their limit argument allows. It runs after validation, once
+// variables are known, and saturates rather than overflowing.
+func (v *gqlValidator) cost(e *gqlExecutor, t *gqlObject, sels []gqlSelection) int {
+	total := 0
+	for _, sel := range sels {
+		var c int
+		switch s := sel.(type) {
+		case *gqlFieldNode:
+			if !e.included(s.directives) || s.name == "__typename" {
+				continue
+			}
+			f := t.field(s.name)
+			if f.resolve != nil {
+				c = 1
+			}
+			if obj := v.schema.objects[f.typeRef.namedType()]; obj != nil {
+				items := 1
+				if f.typeRef.elem != nil {
+					items = gqlListSize(e, f, s)
+				}
+				c = gqlAddCost(c, gqlMulCost(items, gqlAddCost(1, v.cost(e, obj, s.selections))))
+			}
+		case *gqlInlineFragment:
+			if !e.included(s.directives) {
+				continue
+			}
+			c = v.cost(e, t, s.selections)
+		case *gqlFragmentSpread:
+			if !e.included(s.directives) {
+				continue
+			}
+			c = v.cost(e, t, v.doc.fragments[s.name].selections)
+		}
+		total = gqlAddCost(total, c)
+	}
+	return total
+}
+
+// gqlListSize is how many items a list field is assumed to return: its
+// limit, if it takes one, or else its maxItems.
+func gqlListSize(e *gqlExecutor, f *gqlField, node *gqlFieldNode) int {
+	if f.arg("limit") != nil {
+		// A bad limit fails when the field resolves; cost it at the most
+		// it could be
+		args, err := e.arguments(f, node)
+		if limit, ok := args["limit"].(int); err == nil && ok && limit > 0 {
+			return limit
+		}
+		return gqlMaxCost
+	}
+	if f.maxItems > 0 {
+		return f.maxItems
+	}
+	return gqlDefaultListSize
+}
+
+func gqlAddCost(a, b int) int {
+	return min(a+b, gqlMaxCost+1)
+}
+
+func gqlMulCost(a, b int) int {
+	if a != 0 && b > (gqlMaxCost+1)/a {
+		return gqlMaxCost + 1
+	}
+	return min(a*b, gqlMaxCost+1)
+}
+
+// variables checks that every variable used in val is defined by the operation.
+func (v *gqlValidator) variables(val gqlValue) {
+	switch val.kind {
+	case gqlVariableValue:
+		for _, d := range v.op.vars {
+			if d.name == val.raw {
+				return
+			}
+		}
+		v.errorf(val.loc, "Variable \"$%s\" is not defined.", val.raw)
+	case gqlListValue:
+		for _, item := range val.list {
+			v.variables(item)
+		}
+	case gqlObjectValue:
+		for _, f := range val.fields {
+			v.variables(f.value)
+		}
+	}
+}
+
+// --- Execution ---
+
+// gqlResponse is the body of a GraphQL response. Data is nil when the request
+// failed before execution began.
+type gqlResponse struct {
+	Data   *gqlOrderedMap `json:"data,omitempty"`
+	Errors []*gqlError    `json:"errors,omitempty"`
+}
+
+// gqlOrderedMap is a JSON object that keeps the order of the fields selected
+// in the query.
+type gqlOrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *gqlOrderedMap) set(key string, value interface{}) {
+	m.keys = append(m.keys, key)
+	m.values[key] = value
+}
+
+func (m *gqlOrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// execute parses, validates, and runs a query.
+func (s *gqlSchema) execute(ctx context.Context, query, operationName string, variables map[string]interface{}) gqlResponse {
+	doc, perr := parseGraphQL(query)
+	if perr != nil {
+		return gqlResponse{Errors: []*gqlError{perr}}
+	}
+
+	var op *gqlOperation
+	switch {
+	case operationName == "" && len(doc.operations) == 1:
+		op = doc.operations[0]
+	case operationName == "":
+		return gqlResponse{Errors: []*gqlError{{Message: "Must provide operation name if query contains multiple operations."}}}
+	default:
+		for _, o := range doc.operations {
+			if o.name == operationName {
+				op = o
+			}
+		}
+		if op == nil {
+			return gqlResponse{Errors: []*gqlError{{Message: fmt.Sprintf("Unknown operation named %q.", operationName)}}}
+		}
+	}
+
+	v := &gqlValidator{schema: s, doc: doc, op: op, spreading: map[string]bool{}}
+	if errs := v.validate(); len(errs) > 0 {
+		return gqlResponse{Errors: errs}
+	}
+
+	e := &gqlExecutor{schema: s, doc: doc, ctx: ctx}
+	if errs := e.coerceVariables(op, variables); len(errs) > 0 {
+		return gqlResponse{Errors: errs}
+	}
+	if v.cost(e, s.query, op.selections) > gqlMaxCost {
+		return gqlResponse{Errors: []*gqlError{gqlErrorf(op.loc, "Query costs more than the maximum of %d; ask for fewer or smaller lists.", gqlMaxCost)}}
+	}
+
+	data := e.selectionSet(s.query, nil, op.selections, nil)
+	return gqlResponse{Data: data, Errors: e.errors}
+}
+
+type gqlExecutor struct {
+	schema *gqlSchema
+	doc    *gqlDocument
+	ctx    context.Context
+	vars   map[string]interface{}
+	errors []*gqlError
+	// spent counts objects completed and resolvers called, against
+	// gqlMaxCost.
+	spent int
+}
+
+func (e *gqlExecutor) coerceVariables(op *gqlOperation, provided map[string]interface{}) []*gqlError {
+	var errs []*gqlError
+	e.vars = map[string]interface{}{}
+	for _, d := range op.vars {
+		raw, ok := provided[d.name]
+		if !ok {
+			if d.defaultValue != nil {
+				raw, ok = e.literal(*d.defaultValue), true
+			} else if d.typ.nonNull {
+				errs = append(errs, gqlErrorf(d.loc, "Variable \"$%s\" of required type %q was not provided.", d.name, d.typ))
+			}
+		}
+		if !ok {
+			continue
+		}
+		val, err := coerceGQLInput(raw, d.typ)
+		if err != nil {
+			errs = append(errs, gqlErrorf(d.loc, "Variable \"$%s\" got invalid value %s; %v.", d.name, gqlInspect(raw), err))
+			continue
+		}
+		e.vars[d.name] = val
+	}
+	return errs
+}
+
+// literal converts a value in the query to a Go value, substituting variables.
+func (e *gqlExecutor) literal(v gqlValue) interface{} {
+	switch v.kind {
+	case gqlVariableValue:
+		return e.vars[v.raw]
+	case gqlIntValue:
+		if n, err := strconv.Atoi(v.raw); err == nil {
+			return n
+		}
+		f, _ := strconv.ParseFloat(v.raw, 64)
+		return f
+	case gqlFloatValue:
+		f, _ := strconv.ParseFloat(v.raw, 64)
+		return f
+	case gqlStringValue:
+		return v.raw
+	case gqlBooleanValue:
+		return v.raw == "true"
+	case gqlEnumValue:
+		return gqlEnumLiteral(v.raw)
+	case gqlListValue:
+		list := make([]interface{}, len(v.list))
+		for i, item := range v.list {
+			list[i] = e.literal(item)
+		}
+		return list
+	case gqlObjectValue:
+		obj := make(map[string]interface{}, len(v.fields))
+		for _, f := range v.fields {
+			obj[f.name] = e.literal(f.value)
+		}
+		return obj
+	}
+	return nil
+}
+
+// coerceGQLInput converts an input value to the Go type for t: int, float64,
+// string, bool, or []interface{} of those.
+func coerceGQLInput(v interface{}, t *gqlTypeRef) (interface{}, error) {
+	if v == nil {
+		if t.nonNull {
+			return nil, fmt.Errorf("expected non-null %s", t)
+		}
+		return nil, nil
+	}
+	if t.elem != nil {
+		items, ok := v.([]interface{})
+		if !ok {
+			items = []interface{}{v}
+		}
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := coerceGQLInput(item, t.elem)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = c
+		}
+		return out, nil
+	}
+
+	switch t.name {
+	case "Int":
+		switch n := v.(type) {
+		case int:
+			if n >= math.MinInt32 && n <= math.MaxInt32 {
+				return n, nil
+			}
+		case float64:
+			if n == math.Trunc(n) && n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		}
+	case "Float":
+		switch n := v.(type) {
+		case int:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		}
+	case "String":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "ID":
+		switch id := v.(type) {
+		case string:
+			return id, nil
+		case int:
+			return strconv.Itoa(id), nil
+		case float64:
+			if id == math.Trunc(id) {
+				return strconv.FormatFloat(id, 'f', -1, 64), nil
+			}
+		}
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("%s cannot represent %s", t.name, gqlInspect(v))
+}
+
+func gqlInspect(v interface{}) string {
+	if e, ok := v.(gqlEnumLiteral); ok {
+		return string(e)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// arguments coerces a field's arguments, applying defaults.
+func (e *gqlExecutor) arguments(f *gqlField, node *gqlFieldNode) (map[string]interface{}, error) {
+	args := make(map[string]interface{}, len(f.args))
+	for _, a := range f.args {
+		var raw interface{}
+		provided := false
+		if n := node.arg(a.name); n != nil {
+			if n.value.kind == gqlVariableValue {
+				raw, provided = e.vars[n.value.raw]
+			} else {
+				raw, provided = e.literal(n.value), true
+			}
+		}
+		if !provided {
+			if a.defaultValue != nil {
+				args[a.name] = a.defaultValue
+			} else if a.typeRef.nonNull {
+				return nil, fmt.Errorf("Argument %q of required type %q was not provided.", a.name, a.typ)
+			}
+			continue
+		}
+		val, err := coerceGQLInput(raw, a.typeRef)
+		if err != nil {
+			return nil, fmt.Errorf("Argument %q has invalid value %s; %v.", a.name, gqlInspect(raw), err)
+		}
+		args[a.name] = val
+	}
+	return args, nil
+}
+
+// included evaluates @skip and @include.
+func (e *gqlExecutor) included(dirs []*gqlDirective) bool {
+	for _, d := range dirs {
+		var cond bool
+		for _, a := range d.args {
+			cond, _ = e.literal(a.value).(bool)
+		}
+		if (d.name == "skip" && cond) || (d.name == "include" && !cond) {
+			return false
+		}
+	}
+	return true
+}
+
+// gqlFieldGroups holds selected fields grouped by response key, in order.
+type gqlFieldGroups struct {
+	keys  []string
+	nodes map[string][]*gqlFieldNode
+}
+
+func (e *gqlExecutor) collectFields(t *gqlObject, sels []gqlSelection, g *gqlFieldGroups, visited map[string]bool) {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *gqlFieldNode:
+			if !e.included(s.directives) {
+				continue
+			}
+			key := s.alias
+			if key == "" {
+				key = s.name
+			}
+			if _, ok := g.nodes[key]; !ok {
+				g.keys = append(g.keys, key)
+			}
+			g.nodes[key] = append(g.nodes[key], s)
+		case *gqlInlineFragment:
+			if !e.included(s.directives) || (s.typeCondition != "" && s.typeCondition != t.name) {
+				continue
+			}
+			e.collectFields(t, s.selections, g, visited)
+		case *gqlFragmentSpread:
+			if !e.included(s.directives) || visited[s.name] {
+				continue
+			}
+			visited[s.name] = true
+			if frag := e.doc.fragments[s.name]; frag != nil && frag.typeCondition == t.name {
+				e.collectFields(t, frag.selections, g, visited)
+			}
+		}
+	}
+}
+
+func (e *gqlExecutor) selectionSet(t *gqlObject, source interface{}, sels []gqlSelection, path []interface{}) *gqlOrderedMap {
+	g := &gqlFieldGroups{nodes: map[string][]*gqlFieldNode{}}
+	e.collectFields(t, sels, g, map[string]bool{})
+
+	out := &gqlOrderedMap{values: make(map[string]interface{}, len(g.keys))}
+	for _, key := range g.keys {
+		out.set(key, e.field(t, source, g.nodes[key], gqlAppendPath(path, key)))
+	}
+	return out
+}
+
+func (e *gqlExecutor) field(t *gqlObject, source interface{}, nodes []*gqlFieldNode, path []interface{}) interface{} {
+	node := nodes[0]
+	if node.name == "__typename" {
+		return t.name
+	}
+	f := t.field(node.name)
+
+	args, err := e.arguments(f, node)
+	if err != nil {
+		e.fail(err, node, path)
+		return nil
+	}
+
+	var val interface{}
+	if f.resolve != nil {
+		if !e.spend(path) {
+			return nil
+		}
+		val, err = f.resolve(gqlParams{ctx: e.ctx, source: source, args: args})
+		if err != nil {
+			e.fail(err, node, path)
+			return nil
+		}
+	} else {
+		val = gqlDefaultResolve(source, f.name)
+	}
+
+	var sels []gqlSelection
+	for _, n := range nodes {
+		sels = append(sels, n.selections...)
+	}
+	return e.complete(f.typeRef, val, sels, path)
+}
+
+// spend charges one unit of work to the query, reporting whether it is
+// still within budget. Only the first value over budget reports an error;
+// it and everything after it are null.
+func (e *gqlExecutor) spend(path []interface{}) bool {
+	e.spent++
+	if e.spent <= gqlMaxCost {
+		return true
+	}
+	if e.spent == gqlMaxCost+1 {
+		e.errors = append(e.errors, &gqlError{Message: fmt.Sprintf("Query exceeded the maximum cost of %d while running; ask for fewer or smaller lists.", gqlMaxCost), Path: path})
+	}
+	return false
+}
+
+func (e *gqlExecutor) fail(err error, node *gqlFieldNode, path []interface{}) {
+	e.errors = append(e.errors, &gqlError{Message: err.Error(), Locations: []gqlLocation{node.loc}, Path: path})
+}
+
+// complete shapes a resolved value according to its type: lists element by
+// element, objects by executing their selections, and scalars as is.
+func (e *gqlExecutor) complete(t *gqlTypeRef, val interface{}, sels []gqlSelection, path []interface{}) interface{} {
+	rv := reflect.ValueOf(val)
+	if !rv.IsValid() {
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+	case reflect.Slice:
+		if rv.IsNil() && t.elem != nil {
+			return []interface{}{}
+		}
+	}
+	if rv.Kind() == reflect.Pointer {
+		rv = rv.Elem()
+		val = rv.Interface()
+	}
+
+	if t.elem != nil {
+		if rv.Kind() != reflect.Slice {
+			return nil
+		}
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = e.complete(t.elem, rv.Index(i).Interface(), sels, gqlAppendPath(path, i))
+		}
+		return items
+	}
+	if obj := e.schema.objects[t.name]; obj != nil {
+		if !e.spend(path) {
+			return nil
+		}
+		return e.selectionSet(obj, val, sels, path)
+	}
+	return val
+}
+
+func gqlAppendPath(path []interface{}, elem interface{}) []interface{} {
+	p := make([]interface{}, len(path)+1)
+	copy(p, path)
+	p[len(path)] = elem
+	return p
+}
+
+// gqlDefaultResolve reads the struct field whose JSON name is name.
+func gqlDefaultResolve(source interface{}, name string) interface{} {
+	rv := reflect.ValueOf(source)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		tag, _, _ := strings.Cut(rt.Field(i).Tag.Get("json"), ",")
+		if tag == name {
+			return rv.Field(i).Interface()
+		}
+		// Look through embedded structs
+		if rt.Field(i).Anonymous && tag == "" {
+			if v := gqlDefaultResolve(rv.Field(i).Interface(), name); v != nil {
+				return v
+			}
+		}
+	}
+	return nil
+}
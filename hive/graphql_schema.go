@@ -0,0 +1,616 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// graphQLPath is where the GraphQL endpoint is served. Queries are read-only,
+// so requests to it count as reads whatever their method.
+const graphQLPath = "/api/v1/graphql"
+
+// gqlInternalError logs err and returns an error safe to show clients.
+func gqlInternalError(what string, err error) error {
+	log.Printf("graphql: %s: %v", what, err)
+	return fmt.Errorf("failed to %s", what)
+}
+
+func gqlStringArg(args map[string]interface{}, name string) string {
+	s, _ := args[name].(string)
+	return s
+}
+
+func gqlIntArg(args map[string]interface{}, name string) int {
+	n, _ := args[name].(int)
+	return n
+}
+
+func gqlBoolArg(args map[string]interface{}, name string) *bool {
+	if b, ok := args[name].(bool); ok {
+		return &b
+	}
+	return nil
+}
+
+func gqlStringsArg(args map[string]interface{}, name string) []string {
+	list, _ := args[name].([]interface{})
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// gqlLimitArg reads a page size argument, rejecting values outside 1..100.
+func gqlLimitArg(args map[string]interface{}) (int, error) {
+	limit := gqlIntArg(args, "limit")
+	if limit < 1 || limit > 100 {
+		return 0, fmt.Errorf("limit must be between 1 and 100")
+	}
+	return limit, nil
+}
+
+// --- Queries ---
+
+func gqlQueryAgent(ctx context.Context, db *sql.DB, where string, args ...interface{}) (*Agent, error) {
+	a, err := scanAgent(db.QueryRowContext(ctx, "SELECT "+agentColumns+" FROM agents WHERE "+where, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, gqlInternalError("query agent", err)
+	}
+	return &a, nil
+}
+
+// gqlQueryThread returns the thread with the given ID, or nil if there is
+// none that agent can see.
+func gqlQueryThread(ctx context.Context, db *sql.DB, agent *Agent, id string) (*Thread, error) {
+	t, err := scanThread(db.QueryRowContext(ctx,
+		"SELECT "+threadColumns+`
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		WHERE t.id = ?`, id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, gqlInternalError("query thread", err)
+	}
+	if !t.visibleTo(agent) {
+		return nil, nil
+	}
+	return &t, nil
+}
+
+// gqlQueryReplies selects replies in threads agent can read matching where,
+// which may refer to replies as r. The remainder of the query (ordering,
+// limits) follows where.
+func gqlQueryReplies(ctx context.Context, db *sql.DB, agent *Agent, where string, args ...interface{}) ([]Reply, error) {
+	visible, visibleArgs := visibleThreadCondition(agent, "r.thread_id")
+	replies, err := queryReplies(context.Background(), db,
+		`SELECT `+replyColumns+` `+replyJoins+`
+		WHERE `+visible+` AND `+where, append(visibleArgs, args...)...,
+	)
+	if err != nil {
+		return nil, gqlInternalError("query replies", err)
+	}
+	return replies, nil
+}
+
+// gqlQueryStatuses selects status tags on threads and replies agent can read
+// matching where, which may refer to status tags as s, newest first.
+func gqlQueryStatuses(ctx context.Context, db *sql.DB, agent *Agent, where string, args ...interface{}) ([]StatusTag, error) {
+	visible, visibleArgs := visibleStatusCondition(agent)
+	statuses, err := queryStatusTags(context.Background(), db,
+		`SELECT `+statusColumns+` `+statusJoins+`
+		WHERE `+visible+` AND `+where+`
+		ORDER BY s.created_at DESC`, append(visibleArgs, args...)...,
+	)
+	if err != nil {
+		return nil, gqlInternalError("query status tags", err)
+	}
+	return statuses, nil
+}
+
+// gqlFilterStatuses applies the tags and exclude arguments of a statuses field.
+func gqlFilterStatuses(statuses []StatusTag, args map[string]interface{}) []StatusTag {
+	include := map[string]bool{}
+	for _, tag := range gqlStringsArg(args, "tags") {
+		include[tag] = true
+	}
+	exclude := map[string]bool{}
+	for _, tag := range gqlStringsArg(args, "exclude") {
+		exclude[tag] = true
+	}
+
+	filtered := []StatusTag{}
+	for _, st := range statuses {
+		if (len(include) == 0 || include[st.Tag]) && !exclude[st.Tag] {
+			filtered = append(filtered, st)
+		}
+	}
+	return filtered
+}
+
+func gqlQueryAttachments(ctx context.Context, db *sql.DB, where string, args ...interface{}) ([]Attachment, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT "+attachmentColumns+`
+		FROM attachments att
+		JOIN agents a ON att.agent_id = a.id
+		WHERE `+where+`
+		ORDER BY att.created_at ASC`, args...,
+	)
+	if err != nil {
+		return nil, gqlInternalError("query attachments", err)
+	}
+	defer rows.Close()
+
+	attachments := []Attachment{}
+	for rows.Next() {
+		att, err := scanAttachment(rows)
+		if err != nil {
+			return nil, gqlInternalError("scan attachment", err)
+		}
+		attachments = append(attachments, att)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, gqlInternalError("iterate attachments", err)
+	}
+	return attachments, nil
+}
+
+// --- Schema ---
+
+// statusesField is the statuses field shared by threads, replies, and agents.
+func statusesField(description string, load func(ctx context.Context, agent *Agent, source interface{}) ([]StatusTag, error)) *gqlField {
+	return &gqlField{
+		name: "statuses", typ: "[StatusTag!]!", description: description,
+		args: []*gqlArg{{name: "tags", typ: "[String!]"}, {name: "exclude", typ: "[String!]"}},
+		resolve: func(p gqlParams) (interface{}, error) {
+			statuses, err := load(p.ctx, AgentFromContext(p.ctx), p.source)
+			if err != nil {
+				return nil, err
+			}
+			return gqlFilterStatuses(statuses, p.args), nil
+		},
+	}
+}
+
+// newForumGraphQLSchema builds the GraphQL schema for the agent API.
+func newForumGraphQLSchema(db *sql.DB) *gqlSchema {
+	agentByID := func(ctx context.Context, id string) (interface{}, error) {
+		return gqlQueryAgent(ctx, db, "id = ?", id)
+	}
+
+	query := &gqlObject{name: "Query", fields: []*gqlField{
+		{name: "me", typ: "Agent!", description: "The agent making the request.",
+			resolve: func(p gqlParams) (interface{}, error) {
+				return AgentFromContext(p.ctx), nil
+			}},
+		{name: "thread", typ: "Thread", args: []*gqlArg{{name: "id", typ: "ID!"}},
+			resolve: func(p gqlParams) (interface{}, error) {
+				return gqlQueryThread(p.ctx, db, AgentFromContext(p.ctx), gqlStringArg(p.args, "id"))
+			}},
+		{name: "threads", typ: "[Thread!]!", description: "Threads with tag and all of tags (or any of them, if tag_mode is \"any\"), sorted by created_at (the default), updated_at, last_activity, reply_count, score, or priority, in descending order unless order is \"asc\". With unread, only threads with replies or a body you have not read.",
+			args: []*gqlArg{
+				{name: "tag", typ: "String"},
+				{name: "tags", typ: "[String!]"},
+				{name: "tag_mode", typ: "String", defaultValue: "all"},
+				{name: "agent", typ: "String"},
+				{name: "status", typ: "String"},
+				{name: "priority", typ: "String"},
+				{name: "pinned", typ: "Boolean"},
+				{name: "archived", typ: "Boolean"},
+				{name: "unread", typ: "Boolean"},
+				{name: "sort", typ: "String", defaultValue: "created_at"},
+				{name: "order", typ: "String", defaultValue: "desc"},
+				{name: "limit", typ: "Int", defaultValue: 20},
+				{name: "offset", typ: "Int", defaultValue: 0},
+			},
+			resolve: func(p gqlParams) (interface{}, error) {
+				filter := ThreadFilter{
+					Tags:     append(gqlStringsArg(p.args, "tags"), gqlStringArg(p.args, "tag")),
+					Agent:    gqlStringArg(p.args, "agent"),
+					Status:   gqlStringArg(p.args, "status"),
+					Priority: gqlStringArg(p.args, "priority"),
+					Pinned:   gqlBoolArg(p.args, "pinned"),
+					Archived: gqlBoolArg(p.args, "archived"),
+					Viewer:   AgentFromContext(p.ctx),
+				}
+				if unread := gqlBoolArg(p.args, "unread"); unread != nil && *unread {
+					filter.UnreadBy = AgentFromContext(p.ctx).ID
+				}
+				switch gqlStringArg(p.args, "tag_mode") {
+				case "", "all":
+				case "any":
+					filter.AnyTag = true
+				default:
+					return nil, fmt.Errorf("invalid tag_mode (use all or any)")
+				}
+				var err error
+				filter.Sort, filter.Ascending, err = parseThreadSort(gqlStringArg(p.args, "sort"), gqlStringArg(p.args, "order"))
+				if err != nil {
+					return nil, err
+				}
+				limit, err := gqlLimitArg(p.args)
+				if err != nil {
+					return nil, err
+				}
+				threads, _, err := listThreads(p.ctx, db, filter, limit, max(gqlIntArg(p.args, "offset"), 0))
+				if err != nil {
+					return nil, gqlInternalError("query threads", err)
+				}
+				return threads, nil
+			}},
+		{name: "reply", typ: "Reply", args: []*gqlArg{{name: "id", typ: "ID!"}},
+			resolve: func(p gqlParams) (interface{}, error) {
+				replies, err := gqlQueryReplies(p.ctx, db, AgentFromContext(p.ctx), "r.id = ?", gqlStringArg(p.args, "id"))
+				if err != nil || len(replies) == 0 {
+					return nil, err
+				}
+				return replies[0], nil
+			}},
+		{name: "agent", typ: "Agent", description: "Look up an agent in your workspace by id or name.",
+			args: []*gqlArg{{name: "id", typ: "ID"}, {name: "name", typ: "String"}},
+			resolve: func(p gqlParams) (interface{}, error) {
+				workspaceID := AgentFromContext(p.ctx).WorkspaceID
+				if id := gqlStringArg(p.args, "id"); id != "" {
+					return gqlQueryAgent(p.ctx, db, "id = ? AND workspace_id = ?", id, workspaceID)
+				}
+				if name := gqlStringArg(p.args, "name"); name != "" {
+					return gqlQueryAgent(p.ctx, db, "name = ? AND workspace_id = ?", name, workspaceID)
+				}
+				return nil, fmt.Errorf("agent requires id or name")
+			}},
+		{name: "agents", typ: "[Agent!]!", description: "Agents in your workspace by name, or only those listing capability if given.",
+			args: []*gqlArg{{name: "capability", typ: "String"}},
+			resolve: func(p gqlParams) (interface{}, error) {
+				query := "SELECT " + agentColumns + " FROM agents WHERE workspace_id = ?"
+				args := []interface{}{AgentFromContext(p.ctx).WorkspaceID}
+				if capability := gqlStringArg(p.args, "capability"); capability != "" {
+					query += " AND " + capabilityCondition
+					args = append(args, capability)
+				}
+				rows, err := db.QueryContext(p.ctx, query+" ORDER BY name", args...)
+				if err != nil {
+					return nil, gqlInternalError("query agents", err)
+				}
+				defer rows.Close()
+				agents := []Agent{}
+				for rows.Next() {
+					a, err := scanAgent(rows)
+					if err != nil {
+						return nil, gqlInternalError("scan agent", err)
+					}
+					agents = append(agents, a)
+				}
+				return agents, rows.Err()
+			}},
+		{name: "statuses", typ: "[StatusTag!]!", description: "Status tags with the given tag, on any thread or reply, that have not been superseded.",
+			args: []*gqlArg{{name: "tag", typ: "String!"}},
+			resolve: func(p gqlParams) (interface{}, error) {
+				return gqlQueryStatuses(p.ctx, db, AgentFromContext(p.ctx), "s.tag = ? AND s.superseded_by IS NULL", gqlStringArg(p.args, "tag"))
+			}},
+		{name: "dependencies", typ: "[Dependency!]!", description: "Every depends-on and blocked status that references other work.",
+			resolve: func(p gqlParams) (interface{}, error) {
+				deps, err := queryDependencies(p.ctx, db, AgentFromContext(p.ctx))
+				if err != nil {
+					return nil, gqlInternalError("query dependencies", err)
+				}
+				return deps, nil
+			}},
+	}}
+
+	thread := &gqlObject{name: "Thread", fields: []*gqlField{
+		{name: "id", typ: "ID!"},
+		{name: "title", typ: "String!"},
+		{name: "body", typ: "String!", description: "Markdown."},
+		{name: "tags", typ: "[String!]!"},
+		{name: "pinned", typ: "Boolean!"},
+		{name: "archived", typ: "Boolean!"},
+		{name: "locked", typ: "Boolean!", description: "Locked threads take no new replies or status tags."},
+		{name: "priority", typ: "String!", description: "low, normal, high, or critical."},
+		{name: "current_status", typ: "String!", description: "open, in-progress, needs-review, or resolved, from the thread's status tags."},
+		{name: "blocked", typ: "Boolean!", description: "Whether a blocked status is in effect."},
+		{name: "due_at", typ: "String"},
+		{name: "overdue", typ: "Boolean!", description: "Past due_at and neither resolved nor archived."},
+		{name: "publish_at", typ: "String", description: "When a scheduled thread will be published; null once it is."},
+		{name: "merged_into", typ: "ID", description: "The thread this one was merged into, if any."},
+		{name: "visibility", typ: "String!", description: "public, participants, or team."},
+		{name: "workspace_id", typ: "ID!"},
+		{name: "participants", typ: "[Participant!]!", description: "Agents added to the thread, who can read it whatever its visibility.",
+			resolve: func(p gqlParams) (interface{}, error) {
+				participants, err := threadParticipants(p.ctx, db, p.source.(Thread).ID)
+				if err != nil {
+					return nil, gqlInternalError("query participants", err)
+				}
+				return participants, nil
+			}},
+		{name: "score", typ: "Int!", description: "Sum of votes."},
+		{name: "reply_count", typ: "Int!", description: "Replies on the thread."},
+		{name: "last_reply_at", typ: "String", description: "When the latest reply was posted, or null without replies."},
+		{name: "last_reply_agent", typ: "String", description: "Name of the agent that posted the latest reply, or null without replies.",
+			resolve: func(p gqlParams) (interface{}, error) {
+				if name := p.source.(Thread).LastReplyAgent; name != "" {
+					return name, nil
+				}
+				return nil, nil
+			}},
+		{name: "unread_reply_count", typ: "Int!", description: "Replies by other agents since you last fetched the thread over REST or marked it read. Queries here don't mark threads read.",
+			resolve: func(p gqlParams) (interface{}, error) {
+				threads := []Thread{p.source.(Thread)}
+				if err := countUnreadReplies(p.ctx, db, AgentFromContext(p.ctx).ID, threads); err != nil {
+					return nil, gqlInternalError("count unread replies", err)
+				}
+				return *threads[0].UnreadReplyCount, nil
+			}},
+		{name: "created_at", typ: "String!"},
+		{name: "updated_at", typ: "String!"},
+		{name: "last_activity_at", typ: "String!", description: "When the thread last got a reply or status tag, or was created if it has had neither."},
+		{name: "agent_id", typ: "ID!"},
+		{name: "agent_name", typ: "String!"},
+		{name: "agent", typ: "Agent!",
+			resolve: func(p gqlParams) (interface{}, error) {
+				return agentByID(p.ctx, p.source.(Thread).AgentID)
+			}},
+		{name: "replies", typ: "[Reply!]!", description: "Replies in tree order: each reply directly follows its parent. Page through long threads with offset; reply_count is the total.",
+			args: []*gqlArg{{name: "limit", typ: "Int", defaultValue: 50}, {name: "offset", typ: "Int", defaultValue: 0}},
+			resolve: func(p gqlParams) (interface{}, error) {
+				limit, err := gqlLimitArg(p.args)
+				if err != nil {
+					return nil, err
+				}
+				// Threads only get here if the agent can read them, and
+				// their replies with them
+				offset := max(gqlIntArg(p.args, "offset"), 0)
+				replies, err := loadReplyPage(p.ctx, db, p.source.(Thread).ID, limit, offset)
+				if err != nil {
+					return nil, gqlInternalError("query replies", err)
+				}
+				return replies, nil
+			}},
+		statusesField("Status tags on the thread itself, newest first.", func(ctx context.Context, agent *Agent, source interface{}) ([]StatusTag, error) {
+			return gqlQueryStatuses(ctx, db, agent, "s.thread_id = ?", source.(Thread).ID)
+		}),
+		{name: "attachments", typ: "[Attachment!]!", description: "Files attached to the thread itself.",
+			resolve: func(p gqlParams) (interface{}, error) {
+				return gqlQueryAttachments(p.ctx, db, "att.thread_id = ? AND att.reply_id IS NULL", p.source.(Thread).ID)
+			}},
+		{name: "referenced_by", typ: "[Backlink!]!", description: "Threads and replies whose bodies cite this thread or one of its replies, oldest first.",
+			resolve: func(p gqlParams) (interface{}, error) {
+				backlinks, err := threadBacklinks(p.ctx, db, AgentFromContext(p.ctx), p.source.(Thread).ID)
+				if err != nil {
+					return nil, gqlInternalError("query backlinks", err)
+				}
+				return backlinks, nil
+			}},
+	}}
+
+	reply := &gqlObject{name: "Reply", fields: []*gqlField{
+		{name: "id", typ: "ID!"},
+		{name: "thread_id", typ: "ID!"},
+		{name: "parent_reply_id", typ: "ID"},
+		{name: "depth", typ: "Int!", description: "Nesting level; replies to the thread are at depth 0.",
+			resolve: func(p gqlParams) (interface{}, error) {
+				var depth int
+				err := db.QueryRowContext(p.ctx,
+					`WITH RECURSIVE ancestors(id, parent_reply_id) AS (
+						SELECT id, parent_reply_id FROM replies WHERE id = ?
+						UNION ALL
+						SELECT r.id, r.parent_reply_id FROM replies r JOIN ancestors ON r.id = ancestors.parent_reply_id
+					)
+					SELECT COUNT(*) - 1 FROM ancestors`, p.source.(Reply).ID,
+				).Scan(&depth)
+				if err != nil {
+					return nil, gqlInternalError("compute reply depth", err)
+				}
+				return depth, nil
+			}},
+		{name: "body", typ: "String!", description: "Markdown."},
+		{name: "created_at", typ: "String!"},
+		{name: "updated_at", typ: "String!"},
+		{name: "agent_id", typ: "ID!"},
+		{name: "agent_name", typ: "String!"},
+		{name: "agent", typ: "Agent!",
+			resolve: func(p gqlParams) (interface{}, error) {
+				return agentByID(p.ctx, p.source.(Reply).AgentID)
+			}},
+		{name: "thread", typ: "Thread!",
+			resolve: func(p gqlParams) (interface{}, error) {
+				return gqlQueryThread(p.ctx, db, AgentFromContext(p.ctx), p.source.(Reply).ThreadID)
+			}},
+		statusesField("Status tags on the reply, newest first.", func(ctx context.Context, agent *Agent, source interface{}) ([]StatusTag, error) {
+			return gqlQueryStatuses(ctx, db, agent, "s.reply_id = ?", source.(Reply).ID)
+		}),
+		{name: "attachments", typ: "[Attachment!]!",
+			resolve: func(p gqlParams) (interface{}, error) {
+				return gqlQueryAttachments(p.ctx, db, "att.reply_id = ?", p.source.(Reply).ID)
+			}},
+	}}
+
+	status := &gqlObject{name: "StatusTag", fields: []*gqlField{
+		{name: "id", typ: "ID!"},
+		{name: "tag", typ: "String!"},
+		{name: "reference_id", typ: "ID", description: "The thread or reply a depends-on or blocked status points at."},
+		{name: "superseded_by", typ: "ID", description: "The later status that replaced this one, if any."},
+		{name: "created_at", typ: "String!"},
+		{name: "thread_id", typ: "ID"},
+		{name: "reply_id", typ: "ID"},
+		{name: "agent_id", typ: "ID!"},
+		{name: "agent_name", typ: "String!"},
+		{name: "agent", typ: "Agent!",
+			resolve: func(p gqlParams) (interface{}, error) {
+				return agentByID(p.ctx, p.source.(StatusTag).AgentID)
+			}},
+		{name: "thread", typ: "Thread", description: "The tagged thread, for thread statuses.",
+			resolve: func(p gqlParams) (interface{}, error) {
+				st := p.source.(StatusTag)
+				if st.ThreadID == nil {
+					return nil, nil
+				}
+				return gqlQueryThread(p.ctx, db, AgentFromContext(p.ctx), *st.ThreadID)
+			}},
+		{name: "reply", typ: "Reply", description: "The tagged reply, for reply statuses.",
+			resolve: func(p gqlParams) (interface{}, error) {
+				st := p.source.(StatusTag)
+				if st.ReplyID == nil {
+					return nil, nil
+				}
+				replies, err := gqlQueryReplies(p.ctx, db, AgentFromContext(p.ctx), "r.id = ?", *st.ReplyID)
+				if err != nil || len(replies) == 0 {
+					return nil, err
+				}
+				return replies[0], nil
+			}},
+	}}
+
+	agent := &gqlObject{name: "Agent", fields: []*gqlField{
+		{name: "id", typ: "ID!"},
+		{name: "name", typ: "String!"},
+		{name: "owner", typ: "String!"},
+		{name: "workspace_id", typ: "ID!"},
+		{name: "role", typ: "String!"},
+		{name: "kind", typ: "String!", description: "agent, or human for a dashboard user posting from the dashboard."},
+		{name: "created_at", typ: "String!"},
+		{name: "last_seen_at", typ: "String!"},
+		{name: "presence", typ: "String!", description: "online, idle, or offline, from the agent's heartbeats."},
+		{name: "status_text", typ: "String"},
+		{name: "heartbeat_at", typ: "String"},
+		{name: "capabilities", typ: "[String!]!"},
+		{name: "model", typ: "String"},
+		{name: "toolset", typ: "[String!]!"},
+		{name: "description", typ: "String"},
+		{name: "threads", typ: "[Thread!]!", description: "The agent's threads, newest first.",
+			args: []*gqlArg{{name: "limit", typ: "Int", defaultValue: 10}},
+			resolve: func(p gqlParams) (interface{}, error) {
+				limit, err := gqlLimitArg(p.args)
+				if err != nil {
+					return nil, err
+				}
+				threads, _, err := listThreads(p.ctx, db, ThreadFilter{Agent: p.source.(Agent).Name, Viewer: AgentFromContext(p.ctx)}, limit, 0)
+				if err != nil {
+					return nil, gqlInternalError("query threads", err)
+				}
+				return threads, nil
+			}},
+		{name: "replies", typ: "[Reply!]!", description: "The agent's replies, newest first.",
+			args: []*gqlArg{{name: "limit", typ: "Int", defaultValue: 10}},
+			resolve: func(p gqlParams) (interface{}, error) {
+				limit, err := gqlLimitArg(p.args)
+				if err != nil {
+					return nil, err
+				}
+				return gqlQueryReplies(p.ctx, db, AgentFromContext(p.ctx), "r.agent_id = ? ORDER BY r.created_at DESC LIMIT ?", p.source.(Agent).ID, limit)
+			}},
+		statusesField("Status tags the agent has applied, newest first.", func(ctx context.Context, agent *Agent, source interface{}) ([]StatusTag, error) {
+			return gqlQueryStatuses(ctx, db, agent, "s.agent_id = ?", source.(Agent).ID)
+		}),
+	}}
+
+	attachment := &gqlObject{name: "Attachment", fields: []*gqlField{
+		{name: "id", typ: "ID!"},
+		{name: "filename", typ: "String!"},
+		{name: "content_type", typ: "String!"},
+		{name: "size", typ: "Int!", description: "Size in bytes."},
+		{name: "sha256", typ: "String!"},
+		{name: "created_at", typ: "String!"},
+		{name: "agent_id", typ: "ID!"},
+		{name: "agent_name", typ: "String!"},
+		{name: "url", typ: "String!", description: "Path to download the file from, with the same API key.",
+			resolve: func(p gqlParams) (interface{}, error) {
+				return "/api/v1/attachments/" + p.source.(Attachment).ID, nil
+			}},
+	}}
+
+	dependency := &gqlObject{name: "Dependency", description: "An edge in the dependency graph: source depends on, or is blocked by, depends_on.", fields: []*gqlField{
+		{name: "status", typ: "String!", description: "depends-on or blocked."},
+		{name: "source", typ: "DependencyNode!"},
+		{name: "depends_on", typ: "DependencyNode!"},
+	}}
+
+	node := &gqlObject{name: "DependencyNode", description: "A thread or reply in the dependency graph.", fields: []*gqlField{
+		{name: "id", typ: "ID!"},
+		{name: "title", typ: "String!", description: "Title of the thread, or of the reply's thread."},
+		{name: "agent_name", typ: "String!"},
+	}}
+
+	backlink := &gqlObject{name: "Backlink", description: "A thread or reply that cites another thread.", fields: []*gqlField{
+		{name: "thread_id", typ: "ID!", description: "The citing thread, or the citing reply's thread."},
+		{name: "thread_title", typ: "String!"},
+		{name: "reply_id", typ: "ID", description: "The citing reply, if the citation is in a reply."},
+		{name: "agent_name", typ: "String!"},
+		{name: "target_reply_id", typ: "ID", description: "The cited reply, if a reply rather than the thread was cited."},
+		{name: "created_at", typ: "String!"},
+	}}
+
+	participant := &gqlObject{name: "Participant", description: "An agent added to a thread.", fields: []*gqlField{
+		{name: "agent_id", typ: "ID!"},
+		{name: "agent_name", typ: "String!"},
+		{name: "added_at", typ: "String!"},
+	}}
+
+	return newGQLSchema(query, thread, reply, status, agent, attachment, dependency, node, backlink, participant)
+}
+
+// --- Handlers ---
+
+// handleGraphQL executes a GraphQL query sent as a JSON body on POST or as
+// query parameters on GET. Requests that fail before execution get a 400;
+// errors while resolving fields are reported alongside the data.
+func handleGraphQL(schema *gqlSchema, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var req struct {
+		Query         string                 `json:"query"`
+		OperationName string                 `json:"operationName"`
+		Variables     map[string]interface{} `json:"variables"`
+	}
+	badRequest := func(msg string) {
+		writeJSON(w, http.StatusBadRequest, gqlResponse{Errors: []*gqlError{{Message: msg}}})
+	}
+
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				badRequest("variables must be a JSON object")
+				return
+			}
+		}
+	} else if err := readJSON(r, &req); err != nil {
+		badRequest("invalid JSON body")
+		return
+	}
+	if req.Query == "" {
+		badRequest("query is required")
+		return
+	}
+
+	resp := schema.execute(r.Context(), req.Query, req.OperationName, req.Variables)
+	status := http.StatusOK
+	if resp.Data == nil {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, resp)
+}
+
+// handleGraphQLSchema serves the schema in SDL, since the endpoint does not
+// support introspection queries.
+func handleGraphQLSchema(schema *gqlSchema, w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte(schema.sdl()))
+}
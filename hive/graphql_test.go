@@ -0,0 +1,300 @@
+package hive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type gqlTestItem struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func gqlTestItems(n int) []gqlTestItem {
+	items := make([]gqlTestItem, n)
+	for i := range items {
+		items[i] = gqlTestItem{ID: fmt.Sprint(i + 1), Name: fmt.Sprintf("item %d", i+1)}
+	}
+	return items
+}
+
+// newGQLTestSchema is a small schema exercising the engine without a
+// database: echo returns its argument, items and children return as many
+// items as their limit, and all returns far more items than it declares.
+func newGQLTestSchema() *gqlSchema {
+	item := &gqlObject{name: "Item", fields: []*gqlField{
+		{name: "id", typ: "ID!"},
+		{name: "name", typ: "String!"},
+		{name: "children", typ: "[Item!]!", args: []*gqlArg{{name: "limit", typ: "Int", defaultValue: 5}},
+			resolve: func(p gqlParams) (interface{}, error) {
+				return gqlTestItems(gqlIntArg(p.args, "limit")), nil
+			}},
+		{name: "broken", typ: "String", resolve: func(p gqlParams) (interface{}, error) {
+			return nil, errors.New("broken on purpose")
+		}},
+	}}
+	query := &gqlObject{name: "Query", fields: []*gqlField{
+		{name: "echo", typ: "String", args: []*gqlArg{{name: "s", typ: "String!"}},
+			resolve: func(p gqlParams) (interface{}, error) {
+				return gqlStringArg(p.args, "s"), nil
+			}},
+		{name: "sum", typ: "Int!", args: []*gqlArg{{name: "values", typ: "[Int!]!"}},
+			resolve: func(p gqlParams) (interface{}, error) {
+				total := 0
+				for _, v := range p.args["values"].([]interface{}) {
+					total += v.(int)
+				}
+				return total, nil
+			}},
+		{name: "items", typ: "[Item!]!", args: []*gqlArg{{name: "limit", typ: "Int", defaultValue: 2}},
+			resolve: func(p gqlParams) (interface{}, error) {
+				return gqlTestItems(gqlIntArg(p.args, "limit")), nil
+			}},
+		{name: "all", typ: "[Item!]!", maxItems: 2,
+			resolve: func(p gqlParams) (interface{}, error) {
+				return gqlTestItems(gqlMaxCost * 2), nil
+			}},
+		{name: "nothing", typ: "Item"},
+	}}
+	return newGQLSchema(query, item)
+}
+
+func runGQL(t *testing.T, query string, vars map[string]interface{}) (string, []*gqlError) {
+	t.Helper()
+	resp := newGQLTestSchema().execute(context.Background(), query, "", vars)
+	if resp.Data == nil {
+		return "", resp.Errors
+	}
+	b, err := json.Marshal(resp.Data)
+	if err != nil {
+		t.Fatalf("marshal data: %v", err)
+	}
+	return string(b), resp.Errors
+}
+
+func wantGQLError(t *testing.T, errs []*gqlError, substr string) *gqlError {
+	t.Helper()
+	for _, e := range errs {
+		if strings.Contains(e.Message, substr) {
+			return e
+		}
+	}
+	t.Fatalf("want an error containing %q, got %v", substr, errs)
+	return nil
+}
+
+func TestParseGraphQL(t *testing.T) {
+	doc, err := parseGraphQL(`
+		# a comment
+		query Named($id: ID!, $n: Int = 3) @skip(if: false) {
+			a: echo(s: "x") ...F
+			... on Query { items(limit: $n) { id } }
+		}
+		fragment F on Query { sum(values: [1, 2]) }
+		{ echo(s: """block""") }`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(doc.operations) != 2 {
+		t.Fatalf("got %d operations, want 2", len(doc.operations))
+	}
+	op := doc.operations[0]
+	if op.name != "Named" || op.kind != "query" || len(op.vars) != 2 || len(op.selections) != 3 {
+		t.Errorf("got operation %+v", op)
+	}
+	if loc := doc.operations[1].loc; loc != (gqlLocation{8, 3}) {
+		t.Errorf("got anonymous operation at %v, want 8:3", loc)
+	}
+	if d := op.vars[1]; d.typ.String() != "Int" || d.defaultValue == nil || d.defaultValue.raw != "3" {
+		t.Errorf("got variable %+v", d)
+	}
+	if f := op.selections[0].(*gqlFieldNode); f.alias != "a" || f.name != "echo" {
+		t.Errorf("got field %+v", f)
+	}
+	if doc.fragments["F"] == nil || doc.fragments["F"].typeCondition != "Query" {
+		t.Errorf("got fragments %v", doc.fragments)
+	}
+}
+
+func TestParseGraphQLErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		msg   string
+		loc   gqlLocation
+	}{
+		{`{ echo(s: "unterminated) }`, "Unterminated string", gqlLocation{1, 27}},
+		{"{\n  echo(s: 01) }", "unexpected digit after 0", gqlLocation{2, 12}},
+		{`{ echo(s: "\u12") }`, "Invalid Unicode escape sequence", gqlLocation{1, 12}},
+		{`{ echo`, "Unexpected <EOF>", gqlLocation{1, 7}},
+		{`{ echo(s: "\q") }`, `Invalid character escape sequence \q`, gqlLocation{1, 12}},
+		{`{ echo ^ }`, "Unexpected character", gqlLocation{1, 8}},
+		{`fragment F on Query { a } fragment F on Query { b } { a }`, `only one fragment named "F"`, gqlLocation{1, 27}},
+		{`fragment F on Query { a }`, "no operations", gqlLocation{}},
+	}
+	for _, tt := range tests {
+		_, err := parseGraphQL(tt.query)
+		if err == nil {
+			t.Errorf("%q: parsed, want error %q", tt.query, tt.msg)
+			continue
+		}
+		if !strings.Contains(err.Message, tt.msg) {
+			t.Errorf("%q: got error %q, want %q", tt.query, err.Message, tt.msg)
+		}
+		if tt.loc != (gqlLocation{}) && (len(err.Locations) != 1 || err.Locations[0] != tt.loc) {
+			t.Errorf("%q: got locations %v, want %v", tt.query, err.Locations, tt.loc)
+		}
+	}
+}
+
+func TestGraphQLStrings(t *testing.T) {
+	tests := []struct{ literal, want string }{
+		{`"tab\there \u00e9 \"q\""`, "tab\there é \"q\""},
+		{`"""
+			first
+			  indented
+		"""`, "first\n  indented"},
+		{`"""a \""" b"""`, `a """ b`},
+	}
+	for _, tt := range tests {
+		data, errs := runGQL(t, `{ echo(s: `+tt.literal+`) }`, nil)
+		if len(errs) > 0 {
+			t.Errorf("%s: errors %v", tt.literal, errs)
+			continue
+		}
+		want, _ := json.Marshal(map[string]string{"echo": tt.want})
+		if data != string(want) {
+			t.Errorf("%s: got %s, want %s", tt.literal, data, want)
+		}
+	}
+}
+
+func TestGraphQLValidation(t *testing.T) {
+	tests := []struct{ query, msg string }{
+		{`{ nope }`, `Cannot query field "nope" on type "Query"`},
+		{`{ items }`, "must have a selection of subfields"},
+		{`{ echo(s: "x") { id } }`, "must not have a selection"},
+		{`{ echo }`, `argument "s" of type "String!" is required`},
+		{`{ echo(s: "x", t: 1) }`, `Unknown argument "t"`},
+		{`{ echo(s: $s) }`, `Variable "$s" is not defined`},
+		{`{ ...A } fragment A on Query { ...A }`, `Cannot spread fragment "A" within itself`},
+		{`{ ...Missing }`, `Unknown fragment "Missing"`},
+		{`{ ... on Item { id } }`, "can never be of type"},
+		{`{ echo(s: "x") @defer }`, `Unknown directive "@defer"`},
+		{`mutation { echo(s: "x") }`, "Only query operations are supported"},
+		{`{ items { ` + strings.Repeat("children { ", 9) + "id" + strings.Repeat(" }", 9) + ` } }`, "maximum depth of 10"},
+	}
+	for _, tt := range tests {
+		data, errs := runGQL(t, tt.query, nil)
+		if data != "" {
+			t.Errorf("%q: ran, want validation error %q", tt.query, tt.msg)
+			continue
+		}
+		wantGQLError(t, errs, tt.msg)
+	}
+}
+
+func TestGraphQLExecution(t *testing.T) {
+	tests := []struct {
+		query string
+		vars  map[string]interface{}
+		want  string
+	}{
+		{`{ b: echo(s: "2") a: echo(s: "1") }`, nil, `{"b":"2","a":"1"}`},
+		{`{ items { id ...N } } fragment N on Item { name }`, nil, `{"items":[{"id":"1","name":"item 1"},{"id":"2","name":"item 2"}]}`},
+		{`{ items(limit: 1) { ... on Item { id } id __typename } }`, nil, `{"items":[{"id":"1","__typename":"Item"}]}`},
+		{`query($n: Int) { items(limit: $n) { id } }`, map[string]interface{}{"n": float64(3)}, `{"items":[{"id":"1"},{"id":"2"},{"id":"3"}]}`},
+		{`query($n: Int = 1) { items(limit: $n) { id } }`, nil, `{"items":[{"id":"1"}]}`},
+		{`query($skip: Boolean!) { a: echo(s: "a") @skip(if: $skip) b: echo(s: "b") @include(if: $skip) }`, map[string]interface{}{"skip": true}, `{"b":"b"}`},
+		{`{ sum(values: [1, 2, 3]) }`, nil, `{"sum":6}`},
+		{`query($v: [Int!]!) { sum(values: $v) }`, map[string]interface{}{"v": float64(4)}, `{"sum":4}`},
+		{`{ nothing { id } }`, nil, `{"nothing":null}`},
+	}
+	for _, tt := range tests {
+		data, errs := runGQL(t, tt.query, tt.vars)
+		if len(errs) > 0 {
+			t.Errorf("%q: errors %v", tt.query, errs)
+			continue
+		}
+		if data != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.query, data, tt.want)
+		}
+	}
+}
+
+func TestGraphQLExecutionErrors(t *testing.T) {
+	data, errs := runGQL(t, `{ items(limit: 1) { id broken } }`, nil)
+	if data != `{"items":[{"id":"1","broken":null}]}` {
+		t.Errorf("got %s", data)
+	}
+	e := wantGQLError(t, errs, "broken on purpose")
+	if path, _ := json.Marshal(e.Path); string(path) != `["items",0,"broken"]` {
+		t.Errorf("got path %s", path)
+	}
+
+	_, errs = runGQL(t, `query($n: Int) { items(limit: $n) { id } }`, map[string]interface{}{"n": "three"})
+	wantGQLError(t, errs, `Variable "$n" got invalid value "three"`)
+
+	_, errs = runGQL(t, `query($n: Int!) { items(limit: $n) { id } }`, nil)
+	wantGQLError(t, errs, `Variable "$n" of required type "Int!" was not provided`)
+
+	_, errs = runGQL(t, `query($n: Int) { items(limit: $n) { id } }`, map[string]interface{}{"n": 1.5})
+	wantGQLError(t, errs, "Int cannot represent 1.5")
+}
+
+func TestGraphQLCost(t *testing.T) {
+	// 90 items with 100 children each is within budget
+	data, errs := runGQL(t, `{ items(limit: 90) { id children(limit: 100) { id } } }`, nil)
+	if len(errs) > 0 || data == "" {
+		t.Fatalf("errors %v", errs)
+	}
+
+	// One level further is not, whether limits are literals, variables, or
+	// defaults multiplied by aliases and fragments
+	for _, q := range []string{
+		`{ items(limit: 100) { children(limit: 100) { children(limit: 100) { id } } } }`,
+		`query($n: Int!) { items(limit: $n) { children(limit: $n) { children(limit: $n) { id } } } }`,
+		`{ items(limit: 100) { ...C } } fragment C on Item { a: children(limit: 100) { id } b: children(limit: 100) { id } }`,
+	} {
+		data, errs := runGQL(t, q, map[string]interface{}{"n": float64(100)})
+		if data != "" {
+			t.Errorf("%q: ran, want it rejected for cost", q)
+			continue
+		}
+		wantGQLError(t, errs, "more than the maximum of 10000")
+	}
+
+	// Skipped fields cost nothing
+	if _, errs := runGQL(t, `{ items(limit: 50) { children(limit: 100) { children(limit: 100) @skip(if: true) { id } } } }`, nil); len(errs) > 0 {
+		t.Errorf("skipped selection: errors %v", errs)
+	}
+
+	// A list longer than it declares is stopped while running
+	data, errs = runGQL(t, `{ all { id } }`, nil)
+	wantGQLError(t, errs, "exceeded the maximum cost of 10000 while running")
+	var got struct{ All []*gqlTestItem }
+	if err := json.Unmarshal([]byte(data), &got); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+	completed := 0
+	for _, it := range got.All {
+		if it != nil {
+			completed++
+		}
+	}
+	if completed != gqlMaxCost-1 {
+		t.Errorf("completed %d items, want %d", completed, gqlMaxCost-1)
+	}
+}
+
+func TestGraphQLSDL(t *testing.T) {
+	sdl := newGQLTestSchema().sdl()
+	for _, want := range []string{"type Query {", "  items(limit: Int = 2): [Item!]!", "  echo(s: String!): String", "type Item {"} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("SDL missing %q:\n%s", want, sdl)
+		}
+	}
+}
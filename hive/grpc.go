@@ -0,0 +1,450 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ashton/agentic-forum/forumpb"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcWriteMethods are the RPCs that need the write scope and count against
+// the write rate limit. Everything else is a read.
+var grpcWriteMethods = map[string]bool{
+	forumpb.Forum_CreateThread_FullMethodName: true,
+	forumpb.Forum_CreateReply_FullMethodName:  true,
+	forumpb.Forum_CreateStatus_FullMethodName: true,
+}
+
+// grpcServer implements forumpb.ForumServer on the same store functions as
+// the REST API, through store.
+type grpcServer struct {
+	forumpb.UnimplementedForumServer
+	store Store
+	bus   *EventBus
+}
+
+// newGRPCServer returns a gRPC server for the Forum service that
+// authenticates, scope-checks, rate-limits, and counts the usage of every
+// call, and applies the API's allowlist, like the REST API.
+func newGRPCServer(db *sql.DB, bus *EventBus, limits Limits, limiter *RateLimiter, usage *Usage, network *NetworkPolicy, timeout time.Duration) *grpc.Server {
+	store := newSQLStore(db, bus, limits)
+	auth := &grpcAuth{agents: store, limiter: limiter, usage: usage, network: network, timeout: timeout}
+	srv := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.UnaryInterceptor(auth.unary),
+		grpc.StreamInterceptor(auth.stream),
+	)
+	forumpb.RegisterForumServer(srv, &grpcServer{store: store, bus: bus})
+	return srv
+}
+
+// grpcAuth authenticates gRPC calls by the "authorization: Bearer <key>"
+// metadata and applies the API's allowlist, API key scopes, and rate
+// limits.
+type grpcAuth struct {
+	agents  AgentStore
+	limiter *RateLimiter
+	usage   *Usage
+	network *NetworkPolicy
+	// timeout is the deadline of unary calls, as RequestTimeout, unless
+	// the client sets a sooner one.
+	timeout time.Duration
+}
+
+// authorize returns ctx carrying the calling agent, or a status error.
+func (a *grpcAuth) authorize(ctx context.Context, method string) (context.Context, error) {
+	if err := a.network.allowGRPC(ctx); err != nil {
+		return nil, err
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	var auth string
+	if values := md.Get("authorization"); len(values) > 0 {
+		auth = values[0]
+	}
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid authorization metadata")
+	}
+
+	now := time.Now()
+	agent, err := a.agents.AuthenticateAPIKey(ctx, strings.TrimPrefix(auth, "Bearer "), now)
+	if err != nil {
+		log.Printf("grpc api key auth: %v", err)
+		return nil, status.Error(codes.Internal, "internal error")
+	}
+	if agent == nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid api key")
+	}
+	if agent.KeyExpired(now) {
+		return nil, status.Error(codes.Unauthenticated, "api key expired")
+	}
+
+	// Update last_seen_at
+	go func() {
+		a.agents.TouchAgent(context.Background(), agent.ID, now)
+	}()
+
+	write := grpcWriteMethods[method]
+	scope := scopeRead
+	if write {
+		scope = scopeWrite
+	}
+	if !agent.HasScope(scope) {
+		return nil, status.Error(codes.PermissionDenied, "api key lacks the \""+scope+"\" scope")
+	}
+
+	if d := a.limiter.take(agent.ID, write, now); !d.allowed {
+		return nil, status.Error(codes.ResourceExhausted, d.exceeded())
+	}
+
+	return context.WithValue(ctx, agentContextKey, agent), nil
+}
+
+func (a *grpcAuth) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if a.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.timeout)
+		defer cancel()
+	}
+	ctx, err := a.authorize(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := handler(ctx, req)
+	a.usage.record(AgentFromContext(ctx).ID, time.Now(), grpcUsageStatus(err), messageSize(req), messageSize(resp))
+	return resp, err
+}
+
+func (a *grpcAuth) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authorize(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	s := &agentStream{ServerStream: ss, ctx: ctx}
+	err = handler(srv, s)
+	a.usage.record(AgentFromContext(ctx).ID, time.Now(), grpcUsageStatus(err), s.bytesIn, s.bytesOut)
+	return err
+}
+
+// agentStream is a ServerStream whose context carries the calling agent,
+// counting the bytes of the messages it carries.
+type agentStream struct {
+	grpc.ServerStream
+	ctx               context.Context
+	bytesIn, bytesOut int64
+}
+
+func (s *agentStream) Context() context.Context { return s.ctx }
+
+func (s *agentStream) SendMsg(m interface{}) error {
+	s.bytesOut += messageSize(m)
+	return s.ServerStream.SendMsg(m)
+}
+
+func (s *agentStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.bytesIn += messageSize(m)
+	}
+	return err
+}
+
+// messageSize is the encoded size of a protobuf message, or 0 for anything
+// else.
+func messageSize(m interface{}) int64 {
+	if pm, ok := m.(proto.Message); ok {
+		return int64(proto.Size(pm))
+	}
+	return 0
+}
+
+// grpcUsageStatus maps a call's error to the HTTP status usage counts it
+// as: server errors for failures on the forum's side, client errors for the
+// rest.
+func grpcUsageStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	switch status.Code(err) {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Internal, codes.Unknown, codes.Unavailable, codes.DataLoss, codes.DeadlineExceeded, codes.Unimplemented:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// grpcError converts an error from a store function to a status error.
+func grpcError(err error, what string) error {
+	switch e := err.(type) {
+	case quarantineError:
+		return status.Error(codes.FailedPrecondition, e.Error()+" (quarantine id "+e.id+")")
+	case inputError, fieldError:
+		return status.Error(codes.InvalidArgument, e.Error())
+	case notFoundError:
+		return status.Error(codes.NotFound, e.Error())
+	case conflictError:
+		return status.Error(codes.FailedPrecondition, e.Error())
+	case forbiddenError:
+		return status.Error(codes.PermissionDenied, e.Error())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return status.Error(codes.DeadlineExceeded, "request timed out")
+	}
+	log.Printf("grpc: %s: %v", what, err)
+	return status.Error(codes.Internal, "failed to "+what)
+}
+
+// optionalString maps an empty proto string to a nil pointer.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func threadProto(t Thread) *forumpb.Thread {
+	pb := &forumpb.Thread{
+		Id:        t.ID,
+		AgentId:   t.AgentID,
+		AgentName: t.AgentName,
+		Title:     t.Title,
+		Body:      t.Body,
+		Tags:      t.Tags,
+		Pinned:    t.Pinned,
+		Archived:  t.Archived,
+		Score:     int32(t.Score),
+		CreatedAt: timestamppb.New(t.CreatedAt),
+		UpdatedAt: timestamppb.New(t.UpdatedAt),
+	}
+	for _, reply := range t.Replies {
+		pb.Replies = append(pb.Replies, replyProto(reply))
+	}
+	for _, st := range t.Statuses {
+		pb.Statuses = append(pb.Statuses, statusProto(st))
+	}
+	return pb
+}
+
+func replyProto(r Reply) *forumpb.Reply {
+	pb := &forumpb.Reply{
+		Id:            r.ID,
+		ThreadId:      r.ThreadID,
+		ParentReplyId: derefString(r.ParentReplyID),
+		Depth:         int32(r.Depth),
+		AgentId:       r.AgentID,
+		AgentName:     r.AgentName,
+		Body:          r.Body,
+		CreatedAt:     timestamppb.New(r.CreatedAt),
+		UpdatedAt:     timestamppb.New(r.UpdatedAt),
+	}
+	for _, st := range r.Statuses {
+		pb.Statuses = append(pb.Statuses, statusProto(st))
+	}
+	return pb
+}
+
+func statusProto(st StatusTag) *forumpb.StatusTag {
+	return &forumpb.StatusTag{
+		Id:          st.ID,
+		ThreadId:    derefString(st.ThreadID),
+		ReplyId:     derefString(st.ReplyID),
+		AgentId:     st.AgentID,
+		AgentName:   st.AgentName,
+		Tag:         st.Tag,
+		ReferenceId: derefString(st.ReferenceID),
+		CreatedAt:   timestamppb.New(st.CreatedAt),
+	}
+}
+
+func eventProto(e Event) *forumpb.Event {
+	pb := &forumpb.Event{
+		Kind:      e.Kind,
+		ThreadId:  e.ThreadID,
+		CreatedAt: timestamppb.New(e.CreatedAt),
+	}
+	switch {
+	case e.Thread != nil:
+		pb.Payload = &forumpb.Event_Thread{Thread: threadProto(*e.Thread)}
+	case e.Reply != nil:
+		pb.Payload = &forumpb.Event_Reply{Reply: replyProto(*e.Reply)}
+	case e.Status != nil:
+		pb.Payload = &forumpb.Event_Status{Status: statusProto(*e.Status)}
+	}
+	return pb
+}
+
+func (s *grpcServer) CreateThread(ctx context.Context, req *forumpb.CreateThreadRequest) (*forumpb.Thread, error) {
+	thread, err := s.store.CreateThread(ctx, AgentFromContext(ctx), req.GetTitle(), req.GetBody(), req.GetTags(), nil, "", nil, "", nil)
+	if err != nil {
+		return nil, grpcError(err, "create thread")
+	}
+	return threadProto(thread), nil
+}
+
+func (s *grpcServer) ListThreads(ctx context.Context, req *forumpb.ListThreadsRequest) (*forumpb.ListThreadsResponse, error) {
+	page := int(req.GetPage())
+	if page < 1 {
+		page = 1
+	}
+	perPage := int(req.GetPerPage())
+	if perPage < 1 {
+		perPage = 20
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+
+	filter := ThreadFilter{
+		Tags:     []string{req.GetTag()},
+		Agent:    req.GetAgent(),
+		Status:   req.GetStatus(),
+		Pinned:   req.Pinned,
+		Archived: req.Archived,
+		Viewer:   AgentFromContext(ctx),
+	}
+	var err error
+	filter.Sort, filter.Ascending, err = parseThreadSort(req.GetSort(), "")
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	threads, total, err := s.store.ListThreads(ctx, filter, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, grpcError(err, "query threads")
+	}
+
+	resp := &forumpb.ListThreadsResponse{TotalCount: int32(total)}
+	for _, t := range threads {
+		resp.Threads = append(resp.Threads, threadProto(t))
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) GetThread(ctx context.Context, req *forumpb.GetThreadRequest) (*forumpb.Thread, error) {
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing thread id")
+	}
+	thread, err := s.store.VisibleThread(ctx, AgentFromContext(ctx), req.GetId())
+	if err != nil {
+		return nil, grpcError(err, "query thread")
+	}
+	return threadProto(thread), nil
+}
+
+func (s *grpcServer) CreateReply(ctx context.Context, req *forumpb.CreateReplyRequest) (*forumpb.Reply, error) {
+	if req.GetThreadId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing thread id")
+	}
+	reply, err := s.store.CreateReply(ctx, AgentFromContext(ctx), req.GetThreadId(), req.GetBody(), optionalString(req.GetParentReplyId()))
+	if err != nil {
+		return nil, grpcError(err, "create reply")
+	}
+	return replyProto(reply), nil
+}
+
+func (s *grpcServer) CreateStatus(ctx context.Context, req *forumpb.CreateStatusRequest) (*forumpb.StatusTag, error) {
+	agent := AgentFromContext(ctx)
+	referenceID := optionalString(req.GetReferenceId())
+
+	var st StatusTag
+	var err error
+	switch target := req.GetTarget().(type) {
+	case *forumpb.CreateStatusRequest_ThreadId:
+		st, err = s.store.CreateThreadStatus(ctx, agent, target.ThreadId, req.GetTag(), referenceID, false)
+	case *forumpb.CreateStatusRequest_ReplyId:
+		st, err = s.store.CreateReplyStatus(ctx, agent, target.ReplyId, req.GetTag(), referenceID)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "thread_id or reply_id is required")
+	}
+	if err != nil {
+		return nil, grpcError(err, "create status tag")
+	}
+	return statusProto(st), nil
+}
+
+func (s *grpcServer) ListStatuses(ctx context.Context, req *forumpb.ListStatusesRequest) (*forumpb.ListStatusesResponse, error) {
+	if req.GetTag() == "" {
+		return nil, status.Error(codes.InvalidArgument, "tag is required")
+	}
+	statuses, err := s.store.StatusesByTag(ctx, AgentFromContext(ctx), req.GetTag())
+	if err != nil {
+		return nil, grpcError(err, "query status tags")
+	}
+
+	resp := &forumpb.ListStatusesResponse{}
+	for _, st := range statuses {
+		resp.Statuses = append(resp.Statuses, statusProto(st))
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) GetDependencies(ctx context.Context, req *forumpb.GetDependenciesRequest) (*forumpb.GetDependenciesResponse, error) {
+	edges, err := s.store.Dependencies(ctx, AgentFromContext(ctx))
+	if err != nil {
+		return nil, grpcError(err, "query dependencies")
+	}
+
+	resp := &forumpb.GetDependenciesResponse{}
+	for _, edge := range edges {
+		resp.Dependencies = append(resp.Dependencies, &forumpb.Dependency{
+			Status:    edge.Status,
+			Source:    &forumpb.DependencyNode{Id: edge.Source.ID, Title: edge.Source.Title, AgentName: edge.Source.AgentName},
+			DependsOn: &forumpb.DependencyNode{Id: edge.DependsOn.ID, Title: edge.DependsOn.Title, AgentName: edge.DependsOn.AgentName},
+		})
+	}
+	return resp, nil
+}
+
+func (s *grpcServer) StreamEvents(req *forumpb.StreamEventsRequest, stream forumpb.Forum_StreamEventsServer) error {
+	kinds := map[string]bool{}
+	for _, kind := range req.GetKinds() {
+		kinds[kind] = true
+	}
+
+	events, unsubscribe := s.bus.Subscribe()
+	defer unsubscribe()
+
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if req.GetThreadId() != "" && e.ThreadID != req.GetThreadId() {
+				continue
+			}
+			if len(kinds) > 0 && !kinds[e.Kind] {
+				continue
+			}
+			if !s.store.EventVisible(ctx, AgentFromContext(ctx), e) {
+				continue
+			}
+			if err := stream.Send(eventProto(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,1232 @@
+package hive
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// adminTemplates holds parsed templates for each admin page.
+var adminTemplates map[string]*template.Template
+
+// adminLoginTemplate is the standalone login template (no layout).
+var adminLoginTemplate *template.Template
+
+func init() {
+	adminTemplates = make(map[string]*template.Template)
+
+	layoutPath := "templates/admin/layout.html"
+	pages := []string{"dashboard.html", "analytics.html", "threads.html", "agents.html", "announcements.html", "workspaces.html", "filters.html", "search.html", "users.html", "admins.html", "security.html", "import.html", "retention.html", "maintenance.html", "templates.html", "tagging.html", "inbound.html", "discord.html", "email.html", "sla.html", "usage.html"}
+
+	for _, page := range pages {
+		pagePath := "templates/admin/" + page
+		tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templateFS, layoutPath, pagePath)
+		if err != nil {
+			log.Fatalf("failed to parse admin template %s: %v", page, err)
+		}
+		adminTemplates[page] = tmpl
+	}
+
+	// Parse standalone login template
+	loginPath := "templates/admin/login.html"
+	var err error
+	adminLoginTemplate, err = template.New("").Funcs(templateFuncs).ParseFS(templateFS, loginPath)
+	if err != nil {
+		log.Fatalf("failed to parse admin login template: %v", err)
+	}
+}
+
+// noStore keeps a page showing a new secret out of caches. Such pages are
+// rendered in the response to the POST that made the secret, never
+// redirected to, so the secret stays out of URLs, logs, and history.
+func noStore(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// renderAdminTemplate executes the named admin template with data, adding
+// the CSRF token that every admin form must submit.
+func renderAdminTemplate(w http.ResponseWriter, r *http.Request, name string, data map[string]interface{}) {
+	tmpl, ok := adminTemplates[name]
+	if !ok {
+		http.Error(w, "template not found", http.StatusInternalServerError)
+		return
+	}
+	data["CSRFToken"] = CSRFToken(r.Context())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.ExecuteTemplate(w, "admin-layout", data); err != nil {
+		log.Printf("admin template error: %v", err)
+		http.Error(w, "template rendering error", http.StatusInternalServerError)
+	}
+}
+
+// handleAdminLogin renders the login page (GET).
+func handleAdminLogin(cfg Config, w http.ResponseWriter, r *http.Request) {
+	renderAdminLogin(w, r, http.StatusOK, "")
+}
+
+// handleAdminLoginPost processes the login form (POST). Each step, the
+// password and then any second factor, is refused while too many recent
+// failures of it lock out the admin or the address (see loginLockout).
+func handleAdminLoginPost(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	username := r.FormValue("username")
+	password := r.FormValue("password")
+
+	code := r.FormValue("code")
+
+	addr := requestAddr(r)
+	// throttle refuses a step that is locked out, reporting whether it did
+	throttle := func(step, admin string) bool {
+		wait, err := loginLockout(r.Context(), db, step, admin, addr, time.Now())
+		if err != nil {
+			log.Printf("admin login: %v", err)
+			http.Error(w, "failed to check login attempts", http.StatusInternalServerError)
+			return true
+		}
+		if wait > 0 {
+			log.Printf("admin login: %s step locked out for %s from %s", step, admin, addr)
+			renderAdminLogin(w, r, http.StatusTooManyRequests, lockoutMessage(wait))
+			return true
+		}
+		return false
+	}
+	fail := func(step, admin, msg string) {
+		if err := recordLoginFailure(r.Context(), db, step, admin, addr, time.Now()); err != nil {
+			log.Printf("admin login: %v", err)
+		}
+		renderAdminLogin(w, r, http.StatusOK, msg)
+	}
+
+	if throttle(loginStepPassword, username) {
+		return
+	}
+
+	// Look up admin
+	var admin Admin
+	err := db.QueryRowContext(r.Context(),
+		"SELECT id, username, password_hash, totp_secret, totp_enabled FROM admins WHERE username = ?",
+		username,
+	).Scan(&admin.ID, &admin.Username, &admin.PasswordHash, &admin.TOTPSecret, &admin.TOTPEnabled)
+
+	if err != nil || bcrypt.CompareHashAndPassword([]byte(admin.PasswordHash), []byte(password)) != nil {
+		fail(loginStepPassword, username, "Invalid username or password.")
+		return
+	}
+
+	// Second factor, for admins who have enrolled
+	if admin.TOTPEnabled {
+		if code == "" {
+			renderAdminLogin(w, r, http.StatusOK, "Enter the code from your authenticator app or a recovery code.")
+			return
+		}
+		if throttle(loginStepCode, admin.ID) {
+			return
+		}
+		ok, err := checkAdminSecondFactor(r.Context(), db, &admin, code)
+		if err != nil {
+			log.Printf("admin login: second factor check: %v", err)
+		}
+		if !ok {
+			fail(loginStepCode, admin.ID, "Invalid authentication code.")
+			return
+		}
+	}
+
+	if err := clearLoginFailures(r.Context(), db, &admin); err != nil {
+		log.Printf("admin login: clear failures: %v", err)
+	}
+
+	if _, err := db.ExecContext(r.Context(), "UPDATE admins SET last_login_at = ? WHERE id = ?", time.Now(), admin.ID); err != nil {
+		log.Printf("admin login: failed to record login: %v", err)
+	}
+
+	token, expires, err := createAdminSession(r.Context(), db, cfg, admin.ID, r)
+	if err != nil {
+		log.Printf("admin login: %v", err)
+		http.Error(w, "failed to start session", http.StatusInternalServerError)
+		return
+	}
+	setAdminSessionCookie(w, token, expires)
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// renderAdminLogin renders the admin login page with status and an error
+// message.
+func renderAdminLogin(w http.ResponseWriter, r *http.Request, status int, errMsg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := adminLoginTemplate.ExecuteTemplate(w, "admin-login", map[string]interface{}{
+		"Error":     errMsg,
+		"CSRFToken": CSRFToken(r.Context()),
+	}); err != nil {
+		log.Printf("admin login template error: %v", err)
+		http.Error(w, "template rendering error", http.StatusInternalServerError)
+	}
+}
+
+// handleAdminLogout ends the session the request was made in, if it is
+// still going, and forgets its cookie.
+func handleAdminLogout(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(adminSessionCookie); err == nil {
+		if _, err := db.ExecContext(r.Context(),
+			"DELETE FROM admin_sessions WHERE token_hash = ?", hashToken(cookie.Value),
+		); err != nil {
+			log.Printf("admin logout error: %v", err)
+		}
+	}
+	clearAdminSessionCookie(w)
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
+// handleAdminDashboard shows overview stats and recent activity.
+func handleAdminDashboard(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	var agentCount, threadCount, replyCount, statusTagCount int
+
+	db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM agents").Scan(&agentCount)
+	db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM threads").Scan(&threadCount)
+	db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM replies").Scan(&replyCount)
+	db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM status_tags").Scan(&statusTagCount)
+
+	// Fetch recent threads for activity summary
+	recentThreads, err := queryThreads(r.Context(), db,
+		"SELECT "+threadColumns+`
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		ORDER BY t.created_at DESC
+		LIMIT 10`,
+	)
+	if err != nil {
+		log.Printf("admin dashboard threads query error: %v", err)
+		http.Error(w, "failed to load dashboard", http.StatusInternalServerError)
+		return
+	}
+
+	renderAdminTemplate(w, r, "dashboard.html", map[string]interface{}{
+		"AgentCount":     agentCount,
+		"ThreadCount":    threadCount,
+		"ReplyCount":     replyCount,
+		"StatusTagCount": statusTagCount,
+		"RecentThreads":  recentThreads,
+	})
+}
+
+// handleAdminThreads lists all threads, or those of the workspace in
+// ?workspace=, with admin actions.
+func handleAdminThreads(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage := 25
+	offset := (page - 1) * perPage
+
+	workspaceID, workspaces, err := adminWorkspaceFilter(db, r)
+	if err != nil {
+		log.Printf("admin threads workspaces query error: %v", err)
+		http.Error(w, "failed to load threads", http.StatusInternalServerError)
+		return
+	}
+
+	// Get total count
+	var totalCount int
+	db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM threads WHERE ? = '' OR workspace_id = ?", workspaceID, workspaceID).Scan(&totalCount)
+	totalPages := (totalCount + perPage - 1) / perPage
+	if totalPages < 1 {
+		totalPages = 1
+	}
+
+	threads, err := queryThreads(r.Context(), db,
+		"SELECT "+threadColumns+`
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		WHERE ? = '' OR t.workspace_id = ?
+		ORDER BY t.created_at DESC
+		LIMIT ? OFFSET ?`, workspaceID, workspaceID, perPage, offset,
+	)
+	if err != nil {
+		log.Printf("admin threads query error: %v", err)
+		http.Error(w, "failed to load threads", http.StatusInternalServerError)
+		return
+	}
+
+	renderAdminTemplate(w, r, "threads.html", map[string]interface{}{
+		"Threads":        threads,
+		"Page":           page,
+		"TotalPages":     totalPages,
+		"PrevPage":       page - 1,
+		"NextPage":       page + 1,
+		"Workspace":      workspaceID,
+		"Workspaces":     workspaces,
+		"WorkspaceNames": workspaceNames(workspaces),
+	})
+}
+
+// handleAdminDeleteThread deletes a thread by ID.
+func handleAdminDeleteThread(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		http.Error(w, "missing thread id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM threads WHERE id = ?", threadID); err != nil {
+		log.Printf("admin delete thread error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/threads", http.StatusSeeOther)
+}
+
+// handleAdminPinThread toggles the pinned status of a thread.
+func handleAdminPinThread(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		http.Error(w, "missing thread id", http.StatusBadRequest)
+		return
+	}
+
+	if err := toggleThreadPinned(r.Context(), db, threadID); err != nil {
+		log.Printf("admin pin thread error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/threads", http.StatusSeeOther)
+}
+
+// handleAdminArchiveThread toggles the archived status of a thread.
+func handleAdminArchiveThread(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		http.Error(w, "missing thread id", http.StatusBadRequest)
+		return
+	}
+
+	if err := toggleThreadArchived(r.Context(), db, threadID); err != nil {
+		log.Printf("admin archive thread error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/threads", http.StatusSeeOther)
+}
+
+// handleAdminLockThread toggles whether a thread is locked.
+func handleAdminLockThread(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		http.Error(w, "missing thread id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "UPDATE threads SET locked = NOT locked WHERE id = ?", threadID); err != nil {
+		log.Printf("admin lock thread error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/threads", http.StatusSeeOther)
+}
+
+// handleAdminAgents lists all agents, or those of the workspace in
+// ?workspace=, and handles the create agent form display.
+func handleAdminAgents(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	renderAdminAgents(db, w, r, map[string]interface{}{})
+}
+
+// renderAdminAgents renders the Agents page with data, which may carry a
+// new API key to show once.
+func renderAdminAgents(db *sql.DB, w http.ResponseWriter, r *http.Request, data map[string]interface{}) {
+	workspaceID, workspaces, err := adminWorkspaceFilter(db, r)
+	if err != nil {
+		log.Printf("admin agents workspaces query error: %v", err)
+		http.Error(w, "failed to load agents", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := db.QueryContext(r.Context(),
+		`SELECT id, name, owner, workspace_id, scopes, role, key_rotated_at, key_expires_at, created_at, last_seen_at, heartbeat_at, status_text, kind FROM agents
+		WHERE ? = '' OR workspace_id = ?
+		ORDER BY created_at DESC`, workspaceID, workspaceID,
+	)
+	if err != nil {
+		log.Printf("admin agents query error: %v", err)
+		http.Error(w, "failed to load agents", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	var agents []Agent
+	for rows.Next() {
+		var a Agent
+		var scopesStr string
+		if err := rows.Scan(&a.ID, &a.Name, &a.Owner, &a.WorkspaceID, &scopesStr, &a.Role, &a.KeyRotatedAt, &a.KeyExpiresAt, &a.CreatedAt, &a.LastSeenAt, &a.HeartbeatAt, &a.StatusText, &a.Kind); err != nil {
+			log.Printf("admin agents scan error: %v", err)
+			continue
+		}
+		a.Presence = a.PresenceAt(now)
+		if err := json.Unmarshal([]byte(scopesStr), &a.Scopes); err != nil {
+			a.Scopes = []string{}
+		}
+		agents = append(agents, a)
+	}
+
+	var expiringSoon, stale []Agent
+	for _, a := range agents {
+		if a.KeyExpiresSoon(now) {
+			expiringSoon = append(expiringSoon, a)
+		}
+		if a.Stale(now) {
+			stale = append(stale, a)
+		}
+	}
+
+	data["Agents"] = agents
+	data["ExpiringSoon"] = expiringSoon
+	data["Stale"] = stale
+	data["Now"] = now
+	data["Workspace"] = workspaceID
+	data["Workspaces"] = workspaces
+	data["WorkspaceNames"] = workspaceNames(workspaces)
+
+	legacy, err := listLegacyKeyAgents(r.Context(), db)
+	if err != nil {
+		log.Printf("admin agents legacy keys query error: %v", err)
+	}
+	data["LegacyKeys"] = legacy
+
+	renderAdminTemplate(w, r, "agents.html", data)
+}
+
+// handleAdminCreateAgent creates a new agent with a generated API key.
+func handleAdminCreateAgent(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	expiresAt, err := parseKeyExpiry(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := r.FormValue("name")
+	agent, rawAPIKey, err := createAgent(r.Context(), db, name, r.FormValue("owner"), r.FormValue("workspace"), r.Form["scopes"], r.FormValue("role"), expiresAt)
+	if _, ok := err.(inputError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("admin create agent: %v", err)
+		http.Error(w, "failed to create agent", http.StatusInternalServerError)
+		return
+	}
+
+	// Show the raw key this once
+	noStore(w)
+	renderAdminAgents(db, w, r, map[string]interface{}{
+		"FlashAPIKey":    rawAPIKey,
+		"FlashAgentName": agent.Name,
+	})
+}
+
+// parseScopesForm validates the "scopes" checkboxes of an agent form and
+// returns them JSON-encoded for storage.
+func parseScopesForm(r *http.Request) (string, error) {
+	scopes := r.Form["scopes"]
+	if len(scopes) == 0 {
+		return "", fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !validScopes[scope] {
+			return "", fmt.Errorf("invalid scope %q", scope)
+		}
+	}
+	scopesJSON, err := json.Marshal(scopes)
+	if err != nil {
+		return "", err
+	}
+	return string(scopesJSON), nil
+}
+
+// handleAdminUpdateAgentScopes replaces the scopes on an agent's API key.
+func handleAdminUpdateAgentScopes(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("id")
+	if agentID == "" {
+		http.Error(w, "missing agent id", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	scopesJSON, err := parseScopesForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "UPDATE agents SET scopes = ? WHERE id = ?", scopesJSON, agentID); err != nil {
+		log.Printf("admin update agent scopes error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/agents", http.StatusSeeOther)
+}
+
+// handleAdminUpdateAgentRole changes an agent's role.
+func handleAdminUpdateAgentRole(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("id")
+	if agentID == "" {
+		http.Error(w, "missing agent id", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	role := r.FormValue("role")
+	if !validRoles[role] {
+		http.Error(w, "invalid role", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "UPDATE agents SET role = ? WHERE id = ?", role, agentID); err != nil {
+		log.Printf("admin update agent role error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/agents", http.StatusSeeOther)
+}
+
+// handleAdminUpdateAgentExpiry sets or clears the expiry on an agent's API key.
+func handleAdminUpdateAgentExpiry(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("id")
+	if agentID == "" {
+		http.Error(w, "missing agent id", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	expiresAt, err := parseKeyExpiry(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "UPDATE agents SET key_expires_at = ? WHERE id = ? AND kind = ?", expiresAt, agentID, agentKindAgent); err != nil {
+		log.Printf("admin update agent expiry error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/agents", http.StatusSeeOther)
+}
+
+// handleAdminRotateAgentKey issues a new API key for an agent. The old key
+// keeps working for cfg.KeyRotationGrace so the agent can be redeployed.
+func handleAdminRotateAgentKey(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("id")
+	if agentID == "" {
+		http.Error(w, "missing agent id", http.StatusBadRequest)
+		return
+	}
+
+	var name string
+	if err := db.QueryRowContext(r.Context(), "SELECT name FROM agents WHERE id = ? AND kind = ?", agentID, agentKindAgent).Scan(&name); err != nil {
+		http.Error(w, "agent not found", http.StatusNotFound)
+		return
+	}
+
+	rotation, err := rotateAgentKey(r.Context(), db, agentID, cfg.KeyRotationGrace)
+	if err == sql.ErrNoRows {
+		http.Error(w, "agent key has been revoked", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("admin rotate agent key: %v", err)
+		http.Error(w, "failed to rotate API key", http.StatusInternalServerError)
+		return
+	}
+
+	noStore(w)
+	renderAdminAgents(db, w, r, map[string]interface{}{
+		"FlashAPIKey":    rotation.APIKey,
+		"FlashAgentName": name,
+		"FlashRotated":   true,
+	})
+}
+
+// handleAdminReissueLegacyKeys gives every agent still holding a legacy key
+// a new one and shows them once.
+func handleAdminReissueLegacyKeys(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	keys, err := reissueLegacyKeys(r.Context(), db)
+	if err != nil {
+		log.Printf("admin reissue legacy keys: %v", err)
+		if len(keys) == 0 {
+			http.Error(w, "failed to re-issue API keys", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	noStore(w)
+	renderAdminAgents(db, w, r, map[string]interface{}{
+		"ReissuedKeys": keys,
+	})
+}
+
+// handleAdminRevokeAgent revokes an agent's API key by clearing the hash.
+func handleAdminRevokeAgent(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("id")
+	if agentID == "" {
+		http.Error(w, "missing agent id", http.StatusBadRequest)
+		return
+	}
+
+	if err := revokeAgent(r.Context(), db, agentID); err != nil {
+		log.Printf("admin revoke agent error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/agents", http.StatusSeeOther)
+}
+
+// handleAdminAnnouncements lists all announcements.
+func handleAdminAnnouncements(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	workspaces, err := listWorkspaces(r.Context(), db)
+	if err != nil {
+		log.Printf("admin announcements workspaces query error: %v", err)
+		http.Error(w, "failed to load announcements", http.StatusInternalServerError)
+		return
+	}
+
+	announcements, err := listAnnouncements(r.Context(), db)
+	if err != nil {
+		log.Printf("admin announcements: %v", err)
+		http.Error(w, "failed to load announcements", http.StatusInternalServerError)
+		return
+	}
+
+	renderAdminTemplate(w, r, "announcements.html", map[string]interface{}{
+		"Announcements":  announcements,
+		"Workspaces":     workspaces,
+		"WorkspaceNames": workspaceNames(workspaces),
+	})
+}
+
+// handleAdminCreateAnnouncement creates a new announcement for the
+// workspace in the form, or for all workspaces, targeted at the
+// comma-separated teams and capabilities and expiring at expires_at (UTC).
+func handleAdminCreateAnnouncement(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	err := createAnnouncementFromForm(r.Context(), db, r, nil)
+	if _, ok := err.(inputError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("admin create announcement error: %v", err)
+		http.Error(w, "failed to create announcement", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/announcements", http.StatusSeeOther)
+}
+
+// handleAdminToggleAnnouncement toggles the active status of an announcement.
+// Reactivating an expired announcement clears its expiry.
+func handleAdminToggleAnnouncement(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	annID := r.PathValue("id")
+	if annID == "" {
+		http.Error(w, "missing announcement id", http.StatusBadRequest)
+		return
+	}
+
+	if err := toggleAnnouncement(r.Context(), db, annID); err != nil {
+		log.Printf("admin toggle announcement error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/announcements", http.StatusSeeOther)
+}
+
+// handleAdminUsers lists all users.
+func handleAdminUsers(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	renderAdminUsers(db, cfg, w, r, map[string]interface{}{})
+}
+
+// renderAdminUsers renders the Users page with data, which may carry a
+// reset link to show once.
+func renderAdminUsers(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request, data map[string]interface{}) {
+	rows, err := db.QueryContext(r.Context(),
+		`SELECT id, username, created_at, disabled_at, must_change_password, role FROM users ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		log.Printf("admin users query error: %v", err)
+		http.Error(w, "failed to load users", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username, &u.CreatedAt, &u.DisabledAt, &u.MustChangePassword, &u.Role); err != nil {
+			log.Printf("admin users scan error: %v", err)
+			continue
+		}
+		users = append(users, u)
+	}
+
+	data["Users"] = users
+	data["ResetTTL"] = formatDuration(cfg.PasswordResetTTL)
+
+	// Check for success message
+	if success := r.URL.Query().Get("success"); success != "" {
+		data["Success"] = success
+	}
+
+	renderAdminTemplate(w, r, "users.html", data)
+}
+
+// handleAdminCreateUser creates a new user with a password and a role,
+// viewer if none is given.
+func handleAdminCreateUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	username := r.FormValue("username")
+	password := r.FormValue("password")
+
+	if username == "" || password == "" {
+		http.Error(w, "username and password are required", http.StatusBadRequest)
+		return
+	}
+	role := r.FormValue("role")
+	if role == "" {
+		role = userRoleViewer
+	}
+	if !validUserRoles[role] {
+		http.Error(w, "role must be viewer or moderator", http.StatusBadRequest)
+		return
+	}
+
+	id := uuid.New().String()
+
+	// Hash the password with bcrypt
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("admin create user: failed to hash password: %v", err)
+		http.Error(w, "failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	_, err = db.ExecContext(r.Context(),
+		`INSERT INTO users (id, username, password_hash, created_at, must_change_password, role) VALUES (?, ?, ?, ?, 1, ?)`,
+		id, username, string(hash), now, role,
+	)
+	if err != nil {
+		log.Printf("admin create user: insert error: %v", err)
+		http.Error(w, "failed to create user (username may already exist)", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users?success=User+created+successfully", http.StatusSeeOther)
+}
+
+// handleAdminDeleteUser deletes a user by ID.
+func handleAdminDeleteUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	if userID == "" {
+		http.Error(w, "missing user id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM users WHERE id = ?", userID); err != nil {
+		log.Printf("admin delete user error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// handleAdminSetUserPassword resets a user's password, which they must
+// change at their next login.
+func handleAdminSetUserPassword(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	if userID == "" {
+		http.Error(w, "missing user id", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	password := r.FormValue("password")
+	if password == "" {
+		http.Error(w, "password is required", http.StatusBadRequest)
+		return
+	}
+
+	if err := setUserPassword(r.Context(), db, userID, password, true, ""); err != nil {
+		log.Printf("admin set user password error: %v", err)
+		http.Error(w, "failed to set password", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users?success=Password+updated", http.StatusSeeOther)
+}
+
+// handleAdminSetUserRole makes a user a viewer or a moderator.
+func handleAdminSetUserRole(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	if userID == "" {
+		http.Error(w, "missing user id", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	role := r.FormValue("role")
+	if !validUserRoles[role] {
+		http.Error(w, "role must be viewer or moderator", http.StatusBadRequest)
+		return
+	}
+
+	res, err := db.ExecContext(r.Context(), "UPDATE users SET role = ? WHERE id = ?", role, userID)
+	if err != nil {
+		log.Printf("admin set user role error: %v", err)
+		http.Error(w, "failed to set role", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/users?success=Role+updated", http.StatusSeeOther)
+}
+
+// handleAdminSetUserDisabled disables a user, which logs them out and keeps
+// them from logging in, or re-enables them.
+func handleAdminSetUserDisabled(db *sql.DB, disabled bool, w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	if userID == "" {
+		http.Error(w, "missing user id", http.StatusBadRequest)
+		return
+	}
+
+	var disabledAt *time.Time
+	if disabled {
+		now := time.Now()
+		disabledAt = &now
+	}
+	if _, err := db.ExecContext(r.Context(), "UPDATE users SET disabled_at = ? WHERE id = ?", disabledAt, userID); err != nil {
+		log.Printf("admin set user disabled error: %v", err)
+	}
+	if disabled {
+		if err := deleteUserSessions(r.Context(), db, userID, ""); err != nil {
+			log.Printf("admin set user disabled error: %v", err)
+		}
+	}
+
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
+
+// handleAdminAdmins lists all admin accounts.
+func handleAdminAdmins(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	rows, err := db.QueryContext(r.Context(),
+		`SELECT id, username, created_at, last_login_at, totp_enabled FROM admins ORDER BY created_at ASC`,
+	)
+	if err != nil {
+		log.Printf("admin admins query error: %v", err)
+		http.Error(w, "failed to load admins", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	var admins []Admin
+	for rows.Next() {
+		var a Admin
+		if err := rows.Scan(&a.ID, &a.Username, &a.CreatedAt, &a.LastLoginAt, &a.TOTPEnabled); err != nil {
+			log.Printf("admin admins scan error: %v", err)
+			continue
+		}
+		admins = append(admins, a)
+	}
+
+	sessions, err := countAdminSessions(r.Context(), db, cfg)
+	if err != nil {
+		log.Printf("admin admins sessions error: %v", err)
+	}
+
+	data := map[string]interface{}{
+		"Admins":   admins,
+		"Current":  AdminFromContext(r.Context()),
+		"Sessions": sessions,
+	}
+
+	// Check for success message
+	if success := r.URL.Query().Get("success"); success != "" {
+		data["Success"] = success
+	}
+
+	renderAdminTemplate(w, r, "admins.html", data)
+}
+
+// handleAdminCreateAdmin creates a new admin account with a password.
+func handleAdminCreateAdmin(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	username := r.FormValue("username")
+	password := r.FormValue("password")
+
+	if username == "" || password == "" {
+		http.Error(w, "username and password are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := createAdmin(r.Context(), db, username, password); err != nil {
+		log.Printf("admin create admin: %v", err)
+		http.Error(w, "failed to create admin (username may already exist)", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/admins?success=Admin+created+successfully", http.StatusSeeOther)
+}
+
+// handleAdminSetAdminPassword replaces an admin account's password.
+func handleAdminSetAdminPassword(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	adminID := r.PathValue("id")
+	if adminID == "" {
+		http.Error(w, "missing admin id", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	password := r.FormValue("password")
+	if password == "" {
+		http.Error(w, "password is required", http.StatusBadRequest)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("admin set password: failed to hash password: %v", err)
+		http.Error(w, "failed to hash password", http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "UPDATE admins SET password_hash = ? WHERE id = ?", string(hash), adminID); err != nil {
+		log.Printf("admin set password error: %v", err)
+		http.Error(w, "failed to set password", http.StatusInternalServerError)
+		return
+	}
+	// The old password's sessions end with it, except the one setting it
+	if _, err := deleteAdminSessions(r.Context(), db, adminID, adminSessionFromContext(r.Context())); err != nil {
+		log.Printf("admin set password: end sessions: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/admins?success=Password+updated", http.StatusSeeOther)
+}
+
+// handleAdminLogoutAdmin ends every session of another admin's, logging
+// them out everywhere. The session making the request is kept.
+func handleAdminLogoutAdmin(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	adminID := r.PathValue("id")
+	if adminID == "" {
+		http.Error(w, "missing admin id", http.StatusBadRequest)
+		return
+	}
+
+	n, err := deleteAdminSessions(r.Context(), db, adminID, adminSessionFromContext(r.Context()))
+	if err != nil {
+		log.Printf("admin logout admin error: %v", err)
+		http.Error(w, "failed to end sessions", http.StatusInternalServerError)
+		return
+	}
+
+	msg := fmt.Sprintf("Ended %d sessions", n)
+	http.Redirect(w, r, "/admin/admins?success="+url.QueryEscape(msg), http.StatusSeeOther)
+}
+
+// handleAdminDeleteAdmin deletes an admin account. Admins cannot delete
+// themselves, which also guarantees at least one admin remains.
+func handleAdminDeleteAdmin(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	adminID := r.PathValue("id")
+	if adminID == "" {
+		http.Error(w, "missing admin id", http.StatusBadRequest)
+		return
+	}
+
+	if current := AdminFromContext(r.Context()); current != nil && current.ID == adminID {
+		http.Error(w, "you cannot delete your own admin account", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM admins WHERE id = ?", adminID); err != nil {
+		log.Printf("admin delete admin error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/admins", http.StatusSeeOther)
+}
+
+// renderAdminSecurity renders the two-factor settings page for the current
+// admin, merging extra into the template data.
+func renderAdminSecurity(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request, admin *Admin, extra map[string]interface{}) {
+	var remaining int
+	db.QueryRowContext(r.Context(),
+		"SELECT COUNT(*) FROM admin_recovery_codes WHERE admin_id = ? AND used_at IS NULL", admin.ID,
+	).Scan(&remaining)
+
+	sessions, err := listAdminSessions(r.Context(), db, cfg, admin.ID, adminSessionFromContext(r.Context()))
+	if err != nil {
+		log.Printf("admin security sessions error: %v", err)
+	}
+
+	data := map[string]interface{}{
+		"Admin":          admin,
+		"Required":       cfg.AdminRequireTOTP,
+		"RemainingCodes": remaining,
+		"Sessions":       sessions,
+		"SessionTTL":     formatDuration(cfg.AdminSessionTTL),
+		"Success":        r.URL.Query().Get("success"),
+	}
+	if cfg.AdminSessionIdle > 0 {
+		data["SessionIdle"] = formatDuration(cfg.AdminSessionIdle)
+	}
+	for k, v := range extra {
+		data[k] = v
+	}
+	renderAdminTemplate(w, r, "security.html", data)
+}
+
+// renderTOTPSetup shows the QR code and secret for an enrollment in progress.
+func renderTOTPSetup(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request, admin *Admin, errMsg string) {
+	uri := totpProvisioningURI(admin.Username, admin.TOTPSecret)
+	qr, err := totpQRDataURI(uri)
+	if err != nil {
+		log.Printf("admin totp setup: %v", err)
+	}
+	renderAdminSecurity(db, cfg, w, r, admin, map[string]interface{}{
+		"SetupSecret": admin.TOTPSecret,
+		"SetupURI":    uri,
+		"SetupQR":     template.URL(qr),
+		"Error":       errMsg,
+	})
+}
+
+// handleAdminSecurity shows the current admin's two-factor settings.
+func handleAdminSecurity(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	renderAdminSecurity(db, cfg, w, r, AdminFromContext(r.Context()), nil)
+}
+
+// handleAdminRevokeSession ends one of the current admin's sessions. Ending
+// the one in use logs out.
+func handleAdminRevokeSession(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	admin := AdminFromContext(r.Context())
+	sessionID := r.PathValue("id")
+	found, err := deleteAdminSession(r.Context(), db, admin.ID, sessionID)
+	if err != nil {
+		log.Printf("admin revoke session error: %v", err)
+		http.Error(w, "failed to end session", http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	if sessionID == adminSessionFromContext(r.Context()) {
+		clearAdminSessionCookie(w)
+		http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/admin/security?success=Session+ended", http.StatusSeeOther)
+}
+
+// handleAdminRevokeSessions ends the current admin's other sessions, or
+// with all=1 every session including this one, which logs out.
+func handleAdminRevokeSessions(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	admin := AdminFromContext(r.Context())
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+	all := r.FormValue("all") == "1"
+	keep := adminSessionFromContext(r.Context())
+	if all {
+		keep = ""
+	}
+	n, err := deleteAdminSessions(r.Context(), db, admin.ID, keep)
+	if err != nil {
+		log.Printf("admin revoke sessions error: %v", err)
+		http.Error(w, "failed to end sessions", http.StatusInternalServerError)
+		return
+	}
+	if all {
+		clearAdminSessionCookie(w)
+		http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+		return
+	}
+	msg := fmt.Sprintf("Ended %d other sessions", n)
+	http.Redirect(w, r, "/admin/security?success="+url.QueryEscape(msg), http.StatusSeeOther)
+}
+
+// handleAdminTOTPSetup starts TOTP enrollment by generating a new secret.
+// The secret is not enforced until confirmed with handleAdminTOTPEnable.
+func handleAdminTOTPSetup(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	admin := AdminFromContext(r.Context())
+	if admin.TOTPEnabled {
+		http.Error(w, "two-factor authentication is already enabled", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		log.Printf("admin totp setup: %v", err)
+		http.Error(w, "failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+	if _, err := db.ExecContext(r.Context(), "UPDATE admins SET totp_secret = ? WHERE id = ?", secret, admin.ID); err != nil {
+		log.Printf("admin totp setup error: %v", err)
+		http.Error(w, "failed to save secret", http.StatusInternalServerError)
+		return
+	}
+	admin.TOTPSecret = secret
+
+	renderTOTPSetup(db, cfg, w, r, admin, "")
+}
+
+// handleAdminTOTPEnable confirms enrollment with a code from the
+// authenticator app and issues recovery codes.
+func handleAdminTOTPEnable(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	admin := AdminFromContext(r.Context())
+	if admin.TOTPEnabled {
+		http.Error(w, "two-factor authentication is already enabled", http.StatusBadRequest)
+		return
+	}
+	if admin.TOTPSecret == "" {
+		http.Redirect(w, r, "/admin/security", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	counter, ok := verifyTOTP(admin.TOTPSecret, r.FormValue("code"), time.Now())
+	if !ok {
+		renderTOTPSetup(db, cfg, w, r, admin, "That code didn't match. Check your device's clock and try again.")
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(),
+		"UPDATE admins SET totp_enabled = 1, totp_last_counter = ? WHERE id = ?", counter, admin.ID,
+	); err != nil {
+		log.Printf("admin totp enable error: %v", err)
+		http.Error(w, "failed to enable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	admin.TOTPEnabled = true
+
+	codes, err := replaceRecoveryCodes(r.Context(), db, admin.ID)
+	if err != nil {
+		log.Printf("admin totp enable: %v", err)
+		http.Error(w, "failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	renderAdminSecurity(db, cfg, w, r, admin, map[string]interface{}{"RecoveryCodes": codes})
+}
+
+// handleAdminTOTPDisable turns off two-factor authentication for the current
+// admin after checking a current code.
+func handleAdminTOTPDisable(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	admin := AdminFromContext(r.Context())
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	if admin.TOTPEnabled {
+		ok, err := checkAdminSecondFactor(r.Context(), db, admin, r.FormValue("code"))
+		if err != nil {
+			log.Printf("admin totp disable: %v", err)
+		}
+		if !ok {
+			renderAdminSecurity(db, cfg, w, r, admin, map[string]interface{}{"Error": "Invalid authentication code."})
+			return
+		}
+	}
+
+	if err := resetAdminTOTP(r.Context(), db, admin.ID); err != nil {
+		log.Printf("admin totp disable: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/security", http.StatusSeeOther)
+}
+
+// handleAdminRegenerateRecoveryCodes replaces the current admin's recovery
+// codes after checking a current code.
+func handleAdminRegenerateRecoveryCodes(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	admin := AdminFromContext(r.Context())
+	if !admin.TOTPEnabled {
+		http.Redirect(w, r, "/admin/security", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	ok, err := checkAdminSecondFactor(r.Context(), db, admin, r.FormValue("code"))
+	if err != nil {
+		log.Printf("admin recovery codes: %v", err)
+	}
+	if !ok {
+		renderAdminSecurity(db, cfg, w, r, admin, map[string]interface{}{"Error": "Invalid authentication code."})
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(r.Context(), db, admin.ID)
+	if err != nil {
+		log.Printf("admin recovery codes: %v", err)
+		http.Error(w, "failed to generate recovery codes", http.StatusInternalServerError)
+		return
+	}
+
+	renderAdminSecurity(db, cfg, w, r, admin, map[string]interface{}{"RecoveryCodes": codes})
+}
+
+// handleAdminResetAdminTOTP clears another admin's two-factor enrollment,
+// for when they have lost their device and recovery codes.
+func handleAdminResetAdminTOTP(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	adminID := r.PathValue("id")
+	if adminID == "" {
+		http.Error(w, "missing admin id", http.StatusBadRequest)
+		return
+	}
+
+	if err := resetAdminTOTP(r.Context(), db, adminID); err != nil {
+		log.Printf("admin reset totp: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/admins?success=Two-factor+authentication+reset", http.StatusSeeOther)
+}
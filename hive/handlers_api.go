@@ -0,0 +1,935 @@
+package hive
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeJSON writes a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONWithETag writes a JSON response tagged with a strong ETag derived
+// from the encoded body. If the request's If-None-Match matches, it responds
+// 304 Not Modified without a body.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to encode response"})
+		return
+	}
+	writeWithETag(w, r, status, "application/json", append(body, '\n'))
+}
+
+// writeWithETag writes body with an ETag computed from it, or just 304 Not
+// Modified if the client already has it.
+func writeWithETag(w http.ResponseWriter, r *http.Request, status int, contentType string, body []byte) {
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag,
+// using the weak comparison required for If-None-Match.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// readJSON decodes a JSON request body into v.
+func readJSON(r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// threadColumns is the select list scanned by scanThread. Queries using it
+// must alias threads as t and join agents as a.
+const threadColumns = `t.id, t.agent_id, a.name, t.title, t.body, t.tags, t.pinned, t.archived, t.locked, t.priority, t.due_at, t.publish_at, t.merged_into, t.visibility, t.workspace_id, t.created_at, t.updated_at, t.last_activity_at,
+		a.owner, ` + participantIDsColumn + `,
+		COALESCE((SELECT SUM(v.value) FROM votes v WHERE v.thread_id = t.id), 0) AS score,
+		` + currentStatusColumn + `,
+		` + blockedColumn + `,
+		(SELECT COUNT(*) FROM replies rc WHERE rc.thread_id = t.id) AS reply_count,
+		(SELECT rl.created_at FROM replies rl WHERE rl.thread_id = t.id ORDER BY rl.created_at DESC LIMIT 1) AS last_reply_at,
+		(SELECT al.name FROM replies rl JOIN agents al ON rl.agent_id = al.id WHERE rl.thread_id = t.id ORDER BY rl.created_at DESC LIMIT 1) AS last_reply_agent`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanThread scans a row selected with threadColumns.
+func scanThread(row rowScanner) (Thread, error) {
+	var t Thread
+	var tagsStr, participantsStr string
+	var pinned, archived, locked, blocked int
+	var lastReplyAgent sql.NullString
+	if err := row.Scan(&t.ID, &t.AgentID, &t.AgentName, &t.Title, &t.Body, &tagsStr, &pinned, &archived, &locked, &t.Priority, &t.DueAt, &t.PublishAt, &t.MergedInto, &t.Visibility, &t.WorkspaceID, &t.CreatedAt, &t.UpdatedAt, &t.LastActivityAt, &t.authorOwner, &participantsStr, &t.Score, &t.CurrentStatus, &blocked, &t.ReplyCount, &t.LastReplyAt, &lastReplyAgent); err != nil {
+		return t, err
+	}
+	t.Pinned = pinned != 0
+	t.Archived = archived != 0
+	t.Locked = locked != 0
+	t.Blocked = blocked != 0
+	t.LastReplyAgent = lastReplyAgent.String
+	t.Overdue = t.isOverdue(time.Now())
+	t.Tasks = countTasks(t.Body)
+	if err := json.Unmarshal([]byte(tagsStr), &t.Tags); err != nil {
+		t.Tags = []string{}
+	}
+	if err := json.Unmarshal([]byte(participantsStr), &t.participantIDs); err != nil {
+		t.participantIDs = nil
+	}
+	return t, nil
+}
+
+// handleCreateThread creates a new thread, from a thread template if the
+// template query parameter names one.
+func handleCreateThread(store ThreadStore, db *sql.DB, bus *EventBus, tagger *Tagger, duplicatePolicy string, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var input struct {
+		Title        string     `json:"title"`
+		Body         string     `json:"body"`
+		Tags         []string   `json:"tags"`
+		DueAt        *time.Time `json:"due_at"`
+		Priority     string     `json:"priority"`
+		PublishAt    *time.Time `json:"publish_at"`
+		Visibility   string     `json:"visibility"`
+		Participants []string   `json:"participants"`
+	}
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	// Bad input is turned away before the duplicate search and the tagger
+	// spend anything on it. A template can fill in the title and body.
+	template := r.URL.Query().Get("template")
+	if err := checkThreadInput(r.Context(), template != "", input.Title, input.Body, input.Tags, input.Priority, input.Visibility); err != nil {
+		writeStoreError(w, err, "failed to create thread")
+		return
+	}
+
+	var duplicates []SemanticResult
+	if duplicatePolicy != duplicatesOff {
+		var err error
+		duplicates, err = findDuplicates(r.Context(), db, agent, input.Title, input.Body)
+		if err != nil {
+			writeStoreError(w, err, "failed to create thread")
+			return
+		}
+		force := r.URL.Query().Get("force")
+		if duplicatePolicy == duplicatesReject && len(duplicates) > 0 && force != "true" && force != "1" {
+			writeJSON(w, http.StatusConflict, map[string]interface{}{
+				"error":      "thread looks like a duplicate; retry with ?force=true to create it anyway",
+				"code":       "duplicate_thread",
+				"duplicates": duplicates,
+			})
+			return
+		}
+	}
+
+	var autoTags []string
+	if tagger != nil {
+		var err error
+		autoTags, err = tagger.suggest(r.Context(), agent, input.Title, input.Body, input.Tags)
+		if err != nil {
+			writeStoreError(w, err, "failed to create thread")
+			return
+		}
+		if tagger.mode == autoTagApply {
+			input.Tags = append(input.Tags, autoTags...)
+		}
+	}
+
+	var thread Thread
+	var err error
+	if template != "" {
+		thread, err = createThreadFromTemplate(r.Context(), db, bus, agent, template, input.Title, input.Body, input.Tags, input.DueAt, input.Priority, input.PublishAt, input.Visibility, input.Participants)
+	} else {
+		thread, err = store.CreateThread(r.Context(), agent, input.Title, input.Body, input.Tags, input.DueAt, input.Priority, input.PublishAt, input.Visibility, input.Participants)
+	}
+	if err != nil {
+		writeStoreError(w, err, "failed to create thread")
+		return
+	}
+
+	if len(duplicates) > 0 {
+		thread.Duplicates = duplicates
+	}
+	if len(autoTags) > 0 {
+		thread.AutoTags = autoTags
+	}
+	writeJSON(w, http.StatusCreated, thread)
+}
+
+// checkThreadInput checks the fields of a new thread that can be checked
+// without the database, as createThread will. With fromTemplate the title
+// and body may be left for the template to fill in.
+func checkThreadInput(ctx context.Context, fromTemplate bool, title, body string, tags []string, priority, visibility string) error {
+	if !fromTemplate && (title == "" || body == "") {
+		return inputError("title and body are required")
+	}
+	if err := checkThreadFields(limitsFrom(ctx), title, body, tags); err != nil {
+		return err
+	}
+	if _, err := checkPriority(priority); err != nil {
+		return err
+	}
+	_, err := checkVisibility(visibility)
+	return err
+}
+
+// handleListThreads lists threads with optional filters and pagination.
+func handleListThreads(store ThreadStore, db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	// Parse pagination
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 {
+		perPage = 20
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	offset := (page - 1) * perPage
+
+	// Parse filters
+	q := r.URL.Query()
+	shape, err := parseThreadShape(q, listIncludes)
+	if err != nil {
+		writeStoreError(w, err, "invalid fields")
+		return
+	}
+	filter := ThreadFilter{
+		Tags:     q["tag"],
+		Agent:    q.Get("agent"),
+		Status:   q.Get("status"),
+		Priority: q.Get("priority"),
+		Viewer:   agent,
+	}
+	if v := q.Get("pinned"); v != "" {
+		pinned := v == "true" || v == "1"
+		filter.Pinned = &pinned
+	}
+	if v := q.Get("archived"); v != "" {
+		archived := v == "true" || v == "1"
+		filter.Archived = &archived
+	}
+	for _, p := range []struct {
+		name string
+		dest **time.Time
+	}{
+		{"created_after", &filter.CreatedAfter},
+		{"created_before", &filter.CreatedBefore},
+		{"updated_after", &filter.UpdatedAfter},
+	} {
+		if v := q.Get(p.name); v != "" {
+			at, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": p.name + " must be an RFC 3339 timestamp"})
+				return
+			}
+			*p.dest = &at
+		}
+	}
+	if v := q.Get("has_replies"); v != "" {
+		hasReplies := v == "true" || v == "1"
+		filter.HasReplies = &hasReplies
+	}
+	if v := q.Get("scheduled"); v == "true" || v == "1" {
+		filter.ScheduledBy = agent.ID
+	}
+	if v := q.Get("unread"); v == "true" || v == "1" {
+		filter.UnreadBy = agent.ID
+	}
+
+	switch q.Get("tag_mode") {
+	case "", "all":
+	case "any":
+		filter.AnyTag = true
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid tag_mode (use all or any)"})
+		return
+	}
+	filter.Sort, filter.Ascending, err = parseThreadSort(q.Get("sort"), q.Get("order"))
+	if err != nil {
+		writeStoreError(w, err, "invalid sort")
+		return
+	}
+
+	threads, totalCount, err := store.ListThreads(r.Context(), filter, perPage, offset)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query threads"})
+		return
+	}
+	if err := countUnreadReplies(r.Context(), db, agent.ID, threads); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to count unread replies"})
+		return
+	}
+	if err := shape.expand(r.Context(), db, agent, threads); err != nil {
+		writeStoreError(w, err, "failed to query threads")
+		return
+	}
+	body, err := shape.renderAll(threads)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to encode response"})
+		return
+	}
+
+	// Set pagination headers
+	w.Header().Set("X-Total-Count", strconv.Itoa(totalCount))
+	w.Header().Set("X-Page", strconv.Itoa(page))
+	w.Header().Set("X-Per-Page", strconv.Itoa(perPage))
+
+	writeJSONWithETag(w, r, http.StatusOK, body)
+}
+
+// ThreadFilter selects threads to list. Empty fields don't filter.
+type ThreadFilter struct {
+	// Tags keeps threads with all of these tags, or with any of them if
+	// AnyTag is set.
+	Tags     []string
+	AnyTag   bool
+	Agent    string
+	Status   string
+	Priority string
+	Pinned   *bool
+	Archived *bool
+	// CreatedAfter, CreatedBefore, and UpdatedAfter keep threads created
+	// or last edited in a range.
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	// HasReplies keeps threads with replies, or if false, without any.
+	HasReplies *bool
+	// Sort is a key of threadSorts, or empty for created_at, descending
+	// unless Ascending is set.
+	Sort      string
+	Ascending bool
+	// ScheduledBy lists the scheduled threads of the agent with this ID
+	// instead of published threads.
+	ScheduledBy string
+	// UnreadBy keeps threads with something new for the agent with this ID.
+	UnreadBy string
+	// Viewer keeps threads this agent can read, or only public threads if
+	// nil.
+	Viewer *Agent
+}
+
+// listThreads returns up to limit threads matching f, skipping offset, along
+// with the total number of matches, in the order f sorts them.
+func listThreads(ctx context.Context, db *sql.DB, f ThreadFilter, limit, offset int) ([]Thread, int, error) {
+	var conditions []string
+	var args []interface{}
+	joins := "JOIN agents a ON t.agent_id = a.id"
+
+	if f.ScheduledBy != "" {
+		conditions = append(conditions, "t.publish_at IS NOT NULL AND t.agent_id = ?")
+		args = append(args, f.ScheduledBy)
+	} else {
+		conditions = append(conditions, publishedCondition, unmergedCondition)
+	}
+	if cond, condArgs := tagCondition(f.Tags, f.AnyTag); cond != "" {
+		conditions = append(conditions, cond)
+		args = append(args, condArgs...)
+	}
+	if f.Agent != "" {
+		conditions = append(conditions, "a.name = ?")
+		args = append(args, f.Agent)
+	}
+	if f.Status != "" {
+		joins += " JOIN status_tags st ON st.thread_id = t.id"
+		conditions = append(conditions, "st.tag = ? AND st.superseded_by IS NULL")
+		args = append(args, f.Status)
+	}
+	if f.Priority != "" {
+		conditions = append(conditions, "t.priority = ?")
+		args = append(args, f.Priority)
+	}
+	if f.Pinned != nil {
+		conditions = append(conditions, "t.pinned = ?")
+		args = append(args, *f.Pinned)
+	}
+	if f.Archived != nil {
+		conditions = append(conditions, "t.archived = ?")
+		args = append(args, *f.Archived)
+	}
+	if f.CreatedAfter != nil {
+		conditions = append(conditions, "t.created_at > ?")
+		args = append(args, *f.CreatedAfter)
+	}
+	if f.CreatedBefore != nil {
+		conditions = append(conditions, "t.created_at < ?")
+		args = append(args, *f.CreatedBefore)
+	}
+	if f.UpdatedAfter != nil {
+		conditions = append(conditions, "t.updated_at > ?")
+		args = append(args, *f.UpdatedAfter)
+	}
+	if f.HasReplies != nil {
+		cond := "EXISTS (SELECT 1 FROM replies r WHERE r.thread_id = t.id)"
+		if !*f.HasReplies {
+			cond = "NOT " + cond
+		}
+		conditions = append(conditions, cond)
+	}
+	if f.UnreadBy != "" {
+		cond, condArgs := unreadCondition(f.UnreadBy)
+		conditions = append(conditions, cond)
+		args = append(args, condArgs...)
+	}
+	visible, visibleArgs := visibleCondition(f.Viewer)
+	conditions = append(conditions, visible)
+	args = append(args, visibleArgs...)
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	orderBy := threadOrderBy(f.Sort, f.Ascending)
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(DISTINCT t.id) FROM threads t %s %s", joins, whereClause)
+	if err := db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count threads: %w", err)
+	}
+
+	query := fmt.Sprintf(
+		"SELECT DISTINCT "+threadColumns+`
+		FROM threads t %s %s
+		ORDER BY %s
+		LIMIT ? OFFSET ?`, joins, whereClause, orderBy,
+	)
+	threads, err := queryThreads(ctx, db, query, append(args, limit, offset)...)
+	return threads, total, err
+}
+
+// handleGetThread retrieves a single thread with its replies and status tags.
+func handleGetThread(store ThreadStore, db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing thread id"})
+		return
+	}
+
+	shape, err := parseThreadShape(r.URL.Query(), threadIncludes)
+	if err != nil {
+		writeStoreError(w, err, "invalid fields")
+		return
+	}
+
+	// Long threads can be fetched a page of replies at a time
+	q := r.URL.Query()
+	limit, offset := -1, 0
+	if q.Has("replies_page") || q.Has("replies_per_page") {
+		page, perPage := replyPage(q.Get("replies_page"), q.Get("replies_per_page"))
+		limit, offset = perPage, (page-1)*perPage
+	}
+
+	// Replies posted while the thread loads stay unread
+	readAt := time.Now()
+	t, err := store.VisibleThreadPage(r.Context(), agent, threadID, limit, offset)
+	if err != nil {
+		writeStoreError(w, err, "failed to query thread")
+		return
+	}
+	if t.MergedInto != nil {
+		// The stub still answers, for clients that don't follow redirects
+		w.Header().Set("Location", "/api/v1/threads/"+*t.MergedInto)
+		writeJSON(w, http.StatusMovedPermanently, t)
+		return
+	}
+
+	// Report what was new before this fetch, then mark it read
+	fetched := []Thread{t}
+	if err := countUnreadReplies(r.Context(), db, agent.ID, fetched); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to count unread replies"})
+		return
+	}
+	t = fetched[0]
+	if err := markThreadRead(r.Context(), db, agent.ID, threadID, readAt); err != nil {
+		log.Printf("get thread: %v", err)
+	}
+
+	shape.trim(&t)
+	body, err := shape.render(t)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to encode response"})
+		return
+	}
+	writeJSONWithETag(w, r, http.StatusOK, body)
+}
+
+// handleListReplies returns a page of a thread's replies in tree order,
+// without marking the thread read.
+func handleListReplies(store ThreadStore, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	page, perPage := replyPage(r.URL.Query().Get("page"), r.URL.Query().Get("per_page"))
+	t, err := store.VisibleThreadPage(r.Context(), agent, r.PathValue("id"), perPage, (page-1)*perPage)
+	if err != nil {
+		writeStoreError(w, err, "failed to query replies")
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(t.ReplyCount))
+	w.Header().Set("X-Page", strconv.Itoa(page))
+	w.Header().Set("X-Per-Page", strconv.Itoa(perPage))
+
+	writeJSONWithETag(w, r, http.StatusOK, t.Replies)
+}
+
+// replyPage parses a page number and page size of replies, defaulting to
+// the first page of 20 and allowing at most 100 per page.
+func replyPage(pageParam, perPageParam string) (int, int) {
+	page, _ := strconv.Atoi(pageParam)
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(perPageParam)
+	if perPage < 1 {
+		perPage = 20
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	return page, perPage
+}
+
+// threadUpdate is the request body of a thread update, read into a
+// ThreadUpdate.
+type threadUpdate struct {
+	Title    *string  `json:"title"`
+	Body     *string  `json:"body"`
+	Tags     []string `json:"tags"`
+	Priority *string  `json:"priority"`
+	// DueAt is a timestamp to set the due date, or null to clear it.
+	DueAt      json.RawMessage `json:"due_at"`
+	Visibility *string         `json:"visibility"`
+}
+
+// handleUpdateThread updates an existing thread owned by the requesting agent.
+func handleUpdateThread(store ThreadStore, w http.ResponseWriter, r *http.Request) {
+	serveThreadUpdate(store, w, r, func(input *threadUpdate) error {
+		return readJSON(r, input)
+	})
+}
+
+// serveThreadUpdate updates an existing thread owned by the requesting
+// agent with the fields decode reads from the request. An inputError from
+// decode is reported as is; any other error as invalid JSON.
+func serveThreadUpdate(store ThreadStore, w http.ResponseWriter, r *http.Request, decode func(*threadUpdate) error) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing thread id"})
+		return
+	}
+
+	var input threadUpdate
+	if err := decode(&input); err != nil {
+		if _, ok := err.(inputError); !ok {
+			err = inputError("invalid JSON body")
+		}
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	u := ThreadUpdate{
+		Title:      input.Title,
+		Body:       input.Body,
+		Tags:       input.Tags,
+		Priority:   input.Priority,
+		SetDueAt:   input.DueAt != nil,
+		Visibility: input.Visibility,
+	}
+	if u.SetDueAt {
+		if err := json.Unmarshal(input.DueAt, &u.DueAt); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "due_at must be an RFC 3339 timestamp or null"})
+			return
+		}
+	}
+
+	t, err := store.UpdateThread(r.Context(), agent, threadID, u)
+	if err != nil {
+		writeStoreError(w, err, "failed to update thread")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+// handleDeleteThread deletes a thread owned by the requesting agent.
+func handleDeleteThread(store ThreadStore, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing thread id"})
+		return
+	}
+
+	if err := store.DeleteThread(r.Context(), agent, threadID); err != nil {
+		writeStoreError(w, err, "failed to delete thread")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Valid status tags that can be applied to threads and replies.
+var validStatusTags = map[string]bool{
+	"acknowledged": true,
+	"depends-on":   true,
+	"blocked":      true,
+	"resolved":     true,
+	"in-progress":  true,
+	"needs-review": true,
+}
+
+// handleCreateReply creates a new reply on a thread.
+func handleCreateReply(store ReplyStore, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing thread id"})
+		return
+	}
+
+	var input struct {
+		Body          string  `json:"body"`
+		ParentReplyID *string `json:"parent_reply_id"`
+	}
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	reply, err := store.CreateReply(r.Context(), agent, threadID, input.Body, input.ParentReplyID)
+	if err != nil {
+		writeStoreError(w, err, "failed to create reply")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, reply)
+}
+
+// orderReplyTree arranges replies depth-first so each reply directly follows
+// its parent, setting Depth on each. Siblings keep their input order, and
+// replies whose parent is missing are treated as top-level.
+func orderReplyTree(replies []Reply) []Reply {
+	known := make(map[string]bool, len(replies))
+	for _, reply := range replies {
+		known[reply.ID] = true
+	}
+	children := make(map[string][]Reply)
+	var roots []Reply
+	for _, reply := range replies {
+		if reply.ParentReplyID != nil && known[*reply.ParentReplyID] {
+			children[*reply.ParentReplyID] = append(children[*reply.ParentReplyID], reply)
+		} else {
+			roots = append(roots, reply)
+		}
+	}
+
+	ordered := make([]Reply, 0, len(replies))
+	var walk func(list []Reply, depth int)
+	walk = func(list []Reply, depth int) {
+		for _, reply := range list {
+			reply.Depth = depth
+			ordered = append(ordered, reply)
+			walk(children[reply.ID], depth+1)
+		}
+	}
+	walk(roots, 0)
+	return ordered
+}
+
+// handleUpdateReply updates a reply owned by the requesting agent.
+func handleUpdateReply(store ReplyStore, w http.ResponseWriter, r *http.Request) {
+	serveReplyUpdate(store, w, r, func(body *string) error {
+		var input struct {
+			Body string `json:"body"`
+		}
+		err := readJSON(r, &input)
+		*body = input.Body
+		return err
+	})
+}
+
+// serveReplyUpdate replaces the body of a reply owned by the requesting
+// agent with the one decode reads from the request. An inputError from
+// decode is reported as is; any other error as invalid JSON.
+func serveReplyUpdate(store ReplyStore, w http.ResponseWriter, r *http.Request, decode func(body *string) error) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	replyID := r.PathValue("id")
+	if replyID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing reply id"})
+		return
+	}
+
+	var body string
+	if err := decode(&body); err != nil {
+		if _, ok := err.(inputError); !ok {
+			err = inputError("invalid JSON body")
+		}
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	reply, err := store.UpdateReply(r.Context(), agent, replyID, body)
+	if err != nil {
+		writeStoreError(w, err, "failed to update reply")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, reply)
+}
+
+// handleDeleteReply deletes a reply owned by the requesting agent.
+func handleDeleteReply(store ReplyStore, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	replyID := r.PathValue("id")
+	if replyID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing reply id"})
+		return
+	}
+
+	if err := store.DeleteReply(r.Context(), agent, replyID); err != nil {
+		writeStoreError(w, err, "failed to delete reply")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleCreateThreadStatus adds a status tag to a thread.
+func handleCreateThreadStatus(store StatusStore, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing thread id"})
+		return
+	}
+
+	var input struct {
+		Tag         string  `json:"tag"`
+		ReferenceID *string `json:"reference_id"`
+		// Unblock, with resolved, clears the blocked tags that reference
+		// the thread.
+		Unblock bool `json:"unblock"`
+	}
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	st, err := store.CreateThreadStatus(r.Context(), agent, threadID, input.Tag, input.ReferenceID, input.Unblock)
+	if err != nil {
+		writeStoreError(w, err, "failed to create status tag")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, st)
+}
+
+// handleCreateReplyStatus adds a status tag to a reply.
+func handleCreateReplyStatus(store StatusStore, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	replyID := r.PathValue("id")
+	if replyID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing reply id"})
+		return
+	}
+
+	var input struct {
+		Tag         string  `json:"tag"`
+		ReferenceID *string `json:"reference_id"`
+	}
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	st, err := store.CreateReplyStatus(r.Context(), agent, replyID, input.Tag, input.ReferenceID)
+	if err != nil {
+		writeStoreError(w, err, "failed to create status tag")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, st)
+}
+
+// handleDeleteStatus deletes a status tag owned by the requesting agent.
+func handleDeleteStatus(store StatusStore, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	statusID := r.PathValue("id")
+	if statusID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing status tag id"})
+		return
+	}
+
+	if err := store.DeleteStatus(r.Context(), agent, statusID); err != nil {
+		writeStoreError(w, err, "failed to delete status tag")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleQueryStatus queries status tags by tag value with context previews.
+func handleQueryStatus(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	tag := r.URL.Query().Get("tag")
+	if tag == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "tag query parameter is required"})
+		return
+	}
+
+	visible, visibleArgs := visibleStatusCondition(agent)
+	rows, err := db.QueryContext(r.Context(),
+		`SELECT s.id, s.thread_id, s.reply_id, s.agent_id, a.name, s.tag, s.reference_id, s.created_at,
+			COALESCE(t.title, ''),
+			COALESCE(
+				CASE WHEN s.reply_id IS NOT NULL THEN
+					CASE WHEN LENGTH(rep.body) > 100 THEN SUBSTR(rep.body, 1, 100) || '...' ELSE rep.body END
+				ELSE
+					CASE WHEN LENGTH(t.body) > 100 THEN SUBSTR(t.body, 1, 100) || '...' ELSE t.body END
+				END,
+			'')
+		FROM status_tags s
+		JOIN agents a ON s.agent_id = a.id
+		LEFT JOIN threads t ON s.thread_id = t.id
+		LEFT JOIN replies rep ON s.reply_id = rep.id
+		WHERE s.tag = ? AND s.superseded_by IS NULL AND `+visible+`
+		ORDER BY s.created_at DESC`, append([]interface{}{tag}, visibleArgs...)...,
+	)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query status tags"})
+		return
+	}
+	defer rows.Close()
+
+	type StatusTagWithPreview struct {
+		StatusTag
+		Preview string `json:"preview"`
+	}
+
+	results := []StatusTagWithPreview{}
+	for rows.Next() {
+		var st StatusTagWithPreview
+		var title string
+		if err := rows.Scan(&st.ID, &st.ThreadID, &st.ReplyID, &st.AgentID, &st.AgentName, &st.Tag, &st.ReferenceID, &st.CreatedAt, &title, &st.Preview); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to scan status tag"})
+			return
+		}
+		// For thread statuses, use the thread title as preview
+		if st.ThreadID != nil && st.ReplyID == nil && title != "" {
+			st.Preview = title
+		}
+		results = append(results, st)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to iterate status tags"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
@@ -0,0 +1,213 @@
+package hive
+
+import (
+	"database/sql"
+	"html/template"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userLoginTemplate holds the standalone login, forgotten password, and
+// password reset pages for users.
+var userLoginTemplate *template.Template
+
+func init() {
+	var err error
+	userLoginTemplate, err = template.New("").Funcs(templateFuncs).ParseFS(templateFS, "templates/login.html", "templates/reset.html")
+	if err != nil {
+		log.Fatalf("failed to parse user login template: %v", err)
+	}
+}
+
+// handleLogin renders the user login page (GET).
+func handleLogin(db *sql.DB, cfg Config, mailer *Mailer, w http.ResponseWriter, r *http.Request) {
+	// If already logged in, or there's nothing to log in to, redirect to
+	// dashboard
+	if !cfg.DashboardAuthRequired {
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+	if cookie, err := r.Cookie(userSessionCookie); err == nil {
+		user, _, err := lookupUserSession(r.Context(), db, cookie.Value, time.Now())
+		if err != nil {
+			log.Printf("user session lookup error: %v", err)
+		}
+		if user != nil && user.DisabledAt == nil {
+			http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+			return
+		}
+	}
+
+	data := map[string]interface{}{
+		"ResetByEmail": resetByEmail(cfg, mailer),
+	}
+	if r.URL.Query().Get("reset") != "" {
+		data["Notice"] = "Password changed. Log in with your new password."
+	}
+	renderUserAuthPage(w, r, "user-login", data)
+}
+
+// dummyPasswordHash is compared against the password given for a username
+// that doesn't exist, or has no password, so that such logins take as long
+// as a wrong password and don't give away which usernames exist.
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+	if err != nil {
+		log.Fatalf("failed to hash dummy password: %v", err)
+	}
+	return hash
+})
+
+// handleLoginPost processes the user login form (POST). Too many recent
+// failures lock out the username or the address (see loginLockout).
+func handleLoginPost(db *sql.DB, cfg Config, mailer *Mailer, w http.ResponseWriter, r *http.Request) {
+	if !cfg.DashboardAuthRequired {
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	username := r.FormValue("username")
+	password := r.FormValue("password")
+
+	addr := requestAddr(r)
+	wait, err := loginLockout(r.Context(), db, loginStepUserPassword, username, addr, time.Now())
+	if err != nil {
+		log.Printf("user login: %v", err)
+		http.Error(w, "failed to check login attempts", http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		log.Printf("user login: locked out for %s from %s", username, addr)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusTooManyRequests)
+		renderUserAuthPage(w, r, "user-login", map[string]interface{}{
+			"Error":        lockoutMessage(wait),
+			"ResetByEmail": resetByEmail(cfg, mailer),
+		})
+		return
+	}
+
+	// Look up user
+	var user User
+	err = db.QueryRowContext(r.Context(),
+		"SELECT id, username, password_hash, created_at, disabled_at FROM users WHERE username = ?",
+		username,
+	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt, &user.DisabledAt)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("user login: %v", err)
+		http.Error(w, "failed to look up user", http.StatusInternalServerError)
+		return
+	}
+	found := err == nil && user.PasswordHash != ""
+	hash := dummyPasswordHash()
+	if found {
+		hash = []byte(user.PasswordHash)
+	}
+	matched := bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil && found
+
+	loginError := ""
+	switch {
+	case !matched:
+		loginError = "Invalid username or password."
+		if err := recordLoginFailure(r.Context(), db, loginStepUserPassword, username, addr, time.Now()); err != nil {
+			log.Printf("user login: %v", err)
+		}
+	case user.DisabledAt != nil:
+		loginError = "This account is disabled."
+	}
+	if loginError != "" {
+		renderUserAuthPage(w, r, "user-login", map[string]interface{}{
+			"Error":        loginError,
+			"ResetByEmail": resetByEmail(cfg, mailer),
+		})
+		return
+	}
+
+	if err := clearUserLoginFailures(r.Context(), db, &user); err != nil {
+		log.Printf("user login: clear failures: %v", err)
+	}
+
+	token, expires, err := createUserSession(r.Context(), db, cfg, user.ID)
+	if err != nil {
+		log.Printf("user login: %v", err)
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+	setUserSessionCookie(w, token, expires)
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// handleLogout ends the user's session and redirects to login, which
+// redirects on to the dashboard if it doesn't need a login (POST).
+func handleLogout(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(userSessionCookie); err == nil {
+		if err := deleteUserSession(r.Context(), db, cookie.Value); err != nil {
+			log.Printf("user logout: %v", err)
+		}
+	}
+	clearUserSessionCookie(w)
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// handleAccountPassword renders the logged-in user's password change page
+// (GET).
+func handleAccountPassword(w http.ResponseWriter, r *http.Request) {
+	if UserFromContext(r.Context()) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	renderTemplate(w, r, "password.html", map[string]interface{}{
+		"Success":    r.URL.Query().Get("success"),
+		"MustChange": UserFromContext(r.Context()).MustChangePassword,
+	})
+}
+
+// handleAccountPasswordPost changes the logged-in user's password after
+// checking their current one, ending their other sessions (POST).
+func handleAccountPasswordPost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	user := UserFromContext(r.Context())
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	password := r.FormValue("password")
+	formError := ""
+	switch {
+	case bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(r.FormValue("current_password"))) != nil:
+		formError = "Current password is incorrect."
+	case password == "":
+		formError = "New password is required."
+	case password != r.FormValue("confirm_password"):
+		formError = "New passwords don't match."
+	case user.MustChangePassword && password == r.FormValue("current_password"):
+		formError = "Choose a password other than the one you were given."
+	}
+	if formError != "" {
+		renderTemplate(w, r, "password.html", map[string]interface{}{
+			"Error":      formError,
+			"MustChange": user.MustChangePassword,
+		})
+		return
+	}
+
+	if err := setUserPassword(r.Context(), db, user.ID, password, false, userSessionFromContext(r.Context())); err != nil {
+		log.Printf("account password: %v", err)
+		http.Error(w, "failed to change password", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/dashboard/password?success=Password+changed", http.StatusSeeOther)
+}
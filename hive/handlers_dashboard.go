@@ -0,0 +1,560 @@
+package hive
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dashboardTemplates holds parsed templates for each dashboard page.
+var dashboardTemplates map[string]*template.Template
+
+// templateFuncs provides helper functions available in all dashboard templates.
+var templateFuncs = template.FuncMap{
+	"renderMarkdown": renderMarkdown,
+	"truncate":       truncate,
+	"timeAgo":        timeAgo,
+	"linkMentions":   linkMentions,
+	"nestIndent":     nestIndent,
+	"formatBytes":    formatBytes,
+}
+
+func init() {
+	dashboardTemplates = make(map[string]*template.Template)
+
+	layoutPath := "templates/dashboard/layout.html"
+	pages := []string{"feed.html", "thread.html", "agent.html", "dependencies.html", "password.html", "email.html", "tags.html", "sla.html", "announcements.html"}
+
+	for _, page := range pages {
+		pagePath := "templates/dashboard/" + page
+		tmpl, err := template.New("").Funcs(templateFuncs).ParseFS(templateFS, layoutPath, pagePath)
+		if err != nil {
+			log.Fatalf("failed to parse template %s: %v", page, err)
+		}
+		dashboardTemplates[page] = tmpl
+	}
+}
+
+// renderMarkdown converts a markdown string to HTML.
+func renderMarkdown(md string) template.HTML {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(md), &buf); err != nil {
+		return template.HTML(template.HTMLEscapeString(md))
+	}
+	return template.HTML(buf.String())
+}
+
+// truncate shortens a string to n characters, adding "..." if truncated.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// nestIndent returns the left indent in rem for a nested reply, capped so
+// deep sub-discussions stay readable.
+func nestIndent(depth int) float64 {
+	if depth > 6 {
+		depth = 6
+	}
+	return float64(depth) * 1.25
+}
+
+// formatBytes renders a byte count with a binary unit suffix.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// timeAgo returns a human-readable relative time string.
+func timeAgo(t time.Time) string {
+	d := time.Since(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		m := int(d.Minutes())
+		if m == 1 {
+			return "1 minute ago"
+		}
+		return fmt.Sprintf("%d minutes ago", m)
+	case d < 24*time.Hour:
+		h := int(d.Hours())
+		if h == 1 {
+			return "1 hour ago"
+		}
+		return fmt.Sprintf("%d hours ago", h)
+	case d < 30*24*time.Hour:
+		days := int(d.Hours() / 24)
+		if days == 1 {
+			return "1 day ago"
+		}
+		return fmt.Sprintf("%d days ago", days)
+	default:
+		return t.Format("2006-01-02")
+	}
+}
+
+// renderTemplate executes the named template with data and writes the result.
+// The layout shows the logged-in user, if there is one, and forms get the
+// CSRF token.
+func renderTemplate(w http.ResponseWriter, r *http.Request, name string, data map[string]interface{}) {
+	tmpl, ok := dashboardTemplates[name]
+	if !ok {
+		http.Error(w, "template not found", http.StatusInternalServerError)
+		return
+	}
+	data["User"] = UserFromContext(r.Context())
+	data["CSRFToken"] = CSRFToken(r.Context())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("template error: %v", err)
+		http.Error(w, "template rendering error", http.StatusInternalServerError)
+	}
+}
+
+// feedPageSize is the number of threads on each page of the feed.
+const feedPageSize = 50
+
+// agentPageSize is the number of threads, and of replies, on each page of
+// an agent's profile.
+const agentPageSize = 20
+
+// feedStatuses are the statuses the feed can be narrowed to.
+var feedStatuses = []string{statusOpen, statusInProgress, statusNeedsReview, statusResolved, statusBlocked}
+
+// feedConditions turns the feed's filters in q into SQL conditions on
+// threads t joined with their agents a: ?q= (words in the thread or its
+// replies), ?tag=, ?agent= (name), ?status=, ?workspace= (ID or name), and
+// the dates ?from= and ?to= (inclusive).
+func feedConditions(r *http.Request, db *sql.DB) ([]string, []interface{}, error) {
+	q := r.URL.Query()
+	var conditions []string
+	var args []interface{}
+	if match := ftsQuery(q.Get("q")); match != "" {
+		conditions = append(conditions, `t.id IN (
+			SELECT object_id FROM search_index WHERE search_index MATCH ? AND kind = 'thread'
+			UNION
+			SELECT r.thread_id FROM search_index s JOIN replies r ON r.id = s.object_id
+			WHERE search_index MATCH ? AND s.kind = 'reply')`)
+		args = append(args, match, match)
+	}
+	if cond, condArgs := tagCondition([]string{q.Get("tag")}, false); cond != "" {
+		conditions = append(conditions, cond)
+		args = append(args, condArgs...)
+	}
+	if agent := q.Get("agent"); agent != "" {
+		conditions = append(conditions, "a.name = ?")
+		args = append(args, agent)
+	}
+	switch status := q.Get("status"); status {
+	case "":
+	case statusBlocked:
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM status_tags s WHERE s.thread_id = t.id AND s.superseded_by IS NULL AND s.tag = 'blocked')")
+	case statusOpen, statusInProgress, statusNeedsReview, statusResolved:
+		conditions = append(conditions, "t.id IN (SELECT id FROM (SELECT t.id, "+currentStatusColumn+" FROM threads t) WHERE current_status = ?)")
+		args = append(args, status)
+	default:
+		return nil, nil, inputError("status must be one of " + strings.Join(feedStatuses, ", "))
+	}
+	if ref := q.Get("workspace"); ref != "" {
+		workspaceID, err := resolveWorkspace(r.Context(), db, ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		conditions = append(conditions, "t.workspace_id = ?")
+		args = append(args, workspaceID)
+	}
+	for _, d := range []struct {
+		param, op string
+		days      int
+	}{{"from", ">=", 0}, {"to", "<", 1}} {
+		v := q.Get(d.param)
+		if v == "" {
+			continue
+		}
+		day, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			return nil, nil, inputError(d.param + " must be a date like 2006-01-02")
+		}
+		conditions = append(conditions, "t.created_at "+d.op+" ?")
+		args = append(args, day.AddDate(0, 0, d.days))
+	}
+	return conditions, args, nil
+}
+
+// dashboardPager is the current page of a paged list on the dashboard.
+// Items is the ID of the element holding the list's items, which
+// scroll.js appends the next page's items to as the reader reaches the end.
+type dashboardPager struct {
+	Items            string
+	Page, TotalPages int
+	PrevURL, NextURL string
+}
+
+// newDashboardPager pages total items, perPage at a time, reading the page
+// number from the query parameter param. It returns the pager and the
+// offset of the page's first item. Links to other pages keep the rest of
+// the query.
+func newDashboardPager(r *http.Request, items, param string, total, perPage int) (dashboardPager, int) {
+	p := dashboardPager{Items: items, TotalPages: max((total+perPage-1)/perPage, 1)}
+	p.Page, _ = strconv.Atoi(r.URL.Query().Get(param))
+	p.Page = min(max(p.Page, 1), p.TotalPages)
+
+	pageURL := func(page int) string {
+		q := url.Values{}
+		for key, values := range r.URL.Query() {
+			if values[0] != "" && key != param {
+				q.Set(key, values[0])
+			}
+		}
+		if page > 1 {
+			q.Set(param, strconv.Itoa(page))
+		}
+		if len(q) == 0 {
+			return r.URL.Path
+		}
+		return r.URL.Path + "?" + q.Encode()
+	}
+	if p.Page > 1 {
+		p.PrevURL = pageURL(p.Page - 1)
+	}
+	if p.Page < p.TotalPages {
+		p.NextURL = pageURL(p.Page + 1)
+	}
+	return p, (p.Page - 1) * perPage
+}
+
+// handleDashboardFeed shows the activity feed, newest threads first, a page
+// at a time and narrowed by the filters in feedConditions. Pinned threads
+// come first, then overdue ones.
+func handleDashboardFeed(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	workspaces, err := listWorkspaces(r.Context(), db)
+	if err != nil {
+		log.Printf("dashboard feed workspaces query error: %v", err)
+		http.Error(w, "failed to load feed", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Query":      q.Get("q"),
+		"Tag":        q.Get("tag"),
+		"Agent":      q.Get("agent"),
+		"Status":     q.Get("status"),
+		"Workspace":  q.Get("workspace"),
+		"From":       q.Get("from"),
+		"To":         q.Get("to"),
+		"Statuses":   feedStatuses,
+		"Workspaces": workspaces,
+	}
+	for _, param := range []string{"q", "tag", "agent", "status", "workspace", "from", "to"} {
+		if q.Get(param) != "" {
+			data["Filtered"] = true
+		}
+	}
+
+	filters, args, err := feedConditions(r, db)
+	switch err.(type) {
+	case nil:
+	case inputError, notFoundError:
+		data["Error"] = err.Error()
+		renderTemplate(w, r, "feed.html", data)
+		return
+	default:
+		log.Printf("dashboard feed filter error: %v", err)
+		http.Error(w, "failed to load feed", http.StatusInternalServerError)
+		return
+	}
+	where := strings.Join(append([]string{publishedCondition, unmergedCondition, publicCondition}, filters...), " AND ")
+
+	// The page is cached for everyone viewing the feed with these filters
+	cached, err := feedCache.get(db, cfg.CacheTTL, r.URL.RawQuery, func() (interface{}, error) {
+		return loadFeedPage(r, db, where, args)
+	})
+	if err != nil {
+		log.Printf("dashboard feed query error: %v", err)
+		http.Error(w, "failed to load feed", http.StatusInternalServerError)
+		return
+	}
+	page := cached.(feedPage)
+	data["Total"] = page.Total
+	data["Pager"] = page.Pager
+	data["Threads"] = page.Threads
+	renderTemplate(w, r, "feed.html", data)
+}
+
+// feedPage is a page of the dashboard feed.
+type feedPage struct {
+	Total   int
+	Pager   dashboardPager
+	Threads []Thread
+}
+
+// loadFeedPage loads the page of the feed r asks for, of the threads
+// matching where.
+func loadFeedPage(r *http.Request, db *sql.DB, where string, args []interface{}) (feedPage, error) {
+	var page feedPage
+	if err := db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM threads t JOIN agents a ON t.agent_id = a.id WHERE "+where, args...).Scan(&page.Total); err != nil {
+		return page, fmt.Errorf("count threads: %w", err)
+	}
+	pager, offset := newDashboardPager(r, "feed-threads", "page", page.Total, feedPageSize)
+	page.Pager = pager
+
+	threads, err := queryThreads(r.Context(), db,
+		"SELECT " + threadColumns + `
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		WHERE `+where+`
+		ORDER BY t.pinned DESC, (`+overdueCondition+`) DESC, t.created_at DESC
+		LIMIT ? OFFSET ?`, append(args, time.Now().UTC(), feedPageSize, offset)...,
+	)
+	if err != nil {
+		return page, err
+	}
+
+	// Fetch status tags for these threads
+	if err := loadThreadStatuses(r.Context(), db, threads, true); err != nil {
+		return page, err
+	}
+
+	page.Threads = threads
+	return page, nil
+}
+
+// handleDashboardThread shows a single thread with all replies.
+func handleDashboardThread(db *sql.DB, em *Embeddings, w http.ResponseWriter, r *http.Request) {
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		http.Error(w, "missing thread id", http.StatusBadRequest)
+		return
+	}
+
+	// Query thread with agent name
+	t, err := scanThread(db.QueryRowContext(r.Context(),
+		"SELECT " + threadColumns + `
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		WHERE t.id = ? AND `+publishedCondition+` AND `+publicCondition, threadID,
+	))
+	if err == sql.ErrNoRows {
+		http.Error(w, "thread not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("dashboard thread query error: %v", err)
+		http.Error(w, "failed to load thread", http.StatusInternalServerError)
+		return
+	}
+	if t.MergedInto != nil {
+		http.Redirect(w, r, "/dashboard/threads/"+*t.MergedInto, http.StatusMovedPermanently)
+		return
+	}
+
+	// Query replies
+	replies, err := queryReplies(r.Context(), db,
+		`SELECT `+replyColumns+` `+replyJoins+`
+		WHERE r.thread_id = ?
+		ORDER BY r.created_at ASC`, threadID,
+	)
+	if err != nil {
+		log.Printf("dashboard thread replies error: %v", err)
+		http.Error(w, "failed to load replies", http.StatusInternalServerError)
+		return
+	}
+	for i := range replies {
+		replies[i].Statuses = []StatusTag{}
+	}
+
+	// Query status tags for thread and its replies
+	statuses, err := queryStatusTags(r.Context(), db,
+		`SELECT `+statusColumns+` `+statusJoins+`
+		WHERE s.thread_id = ? OR s.reply_id IN (SELECT r.id FROM replies r WHERE r.thread_id = ?)
+		ORDER BY s.created_at ASC`, threadID, threadID,
+	)
+	if err != nil {
+		log.Printf("dashboard thread status error: %v", err)
+		http.Error(w, "failed to load status tags", http.StatusInternalServerError)
+		return
+	}
+
+	var threadStatuses []StatusTag
+	replyStatusMap := make(map[string][]StatusTag)
+	for _, st := range statuses {
+		if st.ReplyID != nil {
+			replyStatusMap[*st.ReplyID] = append(replyStatusMap[*st.ReplyID], st)
+		} else {
+			threadStatuses = append(threadStatuses, st)
+		}
+	}
+
+	for i := range replies {
+		if statuses, ok := replyStatusMap[replies[i].ID]; ok {
+			replies[i].Statuses = statuses
+		}
+	}
+
+	t.Replies = orderReplyTree(replies)
+	t.Statuses = threadStatuses
+
+	attachments, err := threadAttachments(r.Context(), db, threadID)
+	if err != nil {
+		log.Printf("dashboard thread attachments error: %v", err)
+		http.Error(w, "failed to load attachments", http.StatusInternalServerError)
+		return
+	}
+	attachToThread(&t, attachments)
+
+	t.ReferencedBy, err = threadBacklinks(r.Context(), db, nil, threadID)
+	if err != nil {
+		log.Printf("dashboard thread backlinks error: %v", err)
+	}
+
+	mentions, err := threadMentionLinks(r.Context(), db, threadID)
+	if err != nil {
+		log.Printf("dashboard thread mentions error: %v", err)
+	}
+
+	humans, err := humanAgentIDs(r.Context(), db)
+	if err != nil {
+		log.Printf("dashboard thread humans error: %v", err)
+	}
+
+	related, _, err := relatedThreads(r.Context(), db, em, nil, t, defaultRelatedThreads)
+	if err != nil {
+		log.Printf("dashboard related threads error: %v", err)
+	}
+
+	// ?reply_to= picks the reply the reply form answers
+	var replyTo *Reply
+	for i := range t.Replies {
+		if t.Replies[i].ID == r.URL.Query().Get("reply_to") {
+			replyTo = &t.Replies[i]
+		}
+	}
+
+	renderTemplate(w, r, "thread.html", map[string]interface{}{
+		"Thread":   t,
+		"Mentions": mentions,
+		"Humans":   humans,
+		"Related":  related,
+		"ReplyTo":  replyTo,
+		"Statuses": validStatusTags,
+		"Error":    r.URL.Query().Get("error"),
+		"Notice":   r.URL.Query().Get("notice"),
+	})
+}
+
+// handleDashboardAgent shows an agent's profile with their threads and
+// replies, newest first, a page at a time.
+func handleDashboardAgent(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agentID := r.PathValue("id")
+	if agentID == "" {
+		http.Error(w, "missing agent id", http.StatusBadRequest)
+		return
+	}
+
+	// Query agent
+	a, err := scanAgent(db.QueryRowContext(r.Context(), "SELECT "+agentColumns+" FROM agents WHERE id = ?", agentID))
+	if err == sql.ErrNoRows {
+		http.Error(w, "agent not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("dashboard agent query error: %v", err)
+		http.Error(w, "failed to load agent", http.StatusInternalServerError)
+		return
+	}
+
+	// Query a page of threads
+	var threadCount, replyCount int
+	err = db.QueryRowContext(r.Context(),
+		`SELECT
+			(SELECT COUNT(*) FROM threads t WHERE t.agent_id = ? AND `+publishedCondition+` AND `+unmergedCondition+` AND `+publicCondition+`),
+			(SELECT COUNT(*) FROM replies r JOIN threads t ON r.thread_id = t.id WHERE r.agent_id = ? AND `+publicCondition+`)`,
+		agentID, agentID,
+	).Scan(&threadCount, &replyCount)
+	if err != nil {
+		log.Printf("dashboard agent count error: %v", err)
+		http.Error(w, "failed to load agent", http.StatusInternalServerError)
+		return
+	}
+	threadPager, threadOffset := newDashboardPager(r, "agent-threads", "threads_page", threadCount, agentPageSize)
+	replyPager, replyOffset := newDashboardPager(r, "agent-replies", "replies_page", replyCount, agentPageSize)
+
+	threads, err := queryThreads(r.Context(), db,
+		"SELECT " + threadColumns + `
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		WHERE t.agent_id = ? AND `+publishedCondition+` AND `+unmergedCondition+` AND `+publicCondition+`
+		ORDER BY t.created_at DESC
+		LIMIT ? OFFSET ?`, agentID, agentPageSize, threadOffset,
+	)
+	if err != nil {
+		log.Printf("dashboard agent threads error: %v", err)
+		http.Error(w, "failed to load threads", http.StatusInternalServerError)
+		return
+	}
+
+	// Query a page of replies with thread titles
+	type ReplyWithThreadTitle struct {
+		Reply
+		ThreadTitle string
+	}
+
+	replyRows, err := db.QueryContext(r.Context(),
+		`SELECT r.id, r.thread_id, r.agent_id, a.name, r.body, r.created_at, r.updated_at, t.title
+		FROM replies r
+		JOIN agents a ON r.agent_id = a.id
+		JOIN threads t ON r.thread_id = t.id
+		WHERE r.agent_id = ? AND `+publicCondition+`
+		ORDER BY r.created_at DESC
+		LIMIT ? OFFSET ?`, agentID, agentPageSize, replyOffset,
+	)
+	if err != nil {
+		log.Printf("dashboard agent replies error: %v", err)
+		http.Error(w, "failed to load replies", http.StatusInternalServerError)
+		return
+	}
+	defer replyRows.Close()
+
+	var replies []ReplyWithThreadTitle
+	for replyRows.Next() {
+		var rr ReplyWithThreadTitle
+		if err := replyRows.Scan(&rr.ID, &rr.ThreadID, &rr.AgentID, &rr.AgentName, &rr.Body, &rr.CreatedAt, &rr.UpdatedAt, &rr.ThreadTitle); err != nil {
+			log.Printf("dashboard agent reply scan error: %v", err)
+			continue
+		}
+		replies = append(replies, rr)
+	}
+
+	renderTemplate(w, r, "agent.html", map[string]interface{}{
+		"Agent":       a,
+		"Threads":     threads,
+		"Replies":     replies,
+		"ThreadPager": threadPager,
+		"ReplyPager":  replyPager,
+	})
+}
+
+// handleDashboardDependencies shows the dependency graph between public
+// threads, which static/dependencies.js draws from
+// handleDashboardDependencyGraph.
+func handleDashboardDependencies(w http.ResponseWriter, r *http.Request) {
+	renderTemplate(w, r, "dependencies.html", map[string]interface{}{})
+}
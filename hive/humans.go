@@ -0,0 +1,147 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Moderators take part in threads from the dashboard as humans: each
+// posts through an agent record of kind human, created the first time they
+// post and named after them, so their replies and status tags flow through
+// the same filters, mentions, notifications, and events as an agent's.
+// Human agents have no API key, and read every workspace's threads, as the
+// dashboard shows them.
+
+// Kinds of agent.
+const (
+	agentKindAgent = "agent"
+	agentKindHuman = "human"
+)
+
+// userAgent returns the human agent that user posts as, creating it if
+// they haven't posted before.
+func userAgent(ctx context.Context, db *sql.DB, user *User) (*Agent, error) {
+	a, err := scanAgent(db.QueryRowContext(ctx, "SELECT "+agentColumns+" FROM agents WHERE user_id = ?", user.ID))
+	if err == nil {
+		return &a, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("query user agent: %w", err)
+	}
+
+	scopes, err := json.Marshal([]string{scopeRead, scopeWrite})
+	if err != nil {
+		return nil, fmt.Errorf("marshal scopes: %w", err)
+	}
+	// Inserted without checking first, so that two first posts at once don't
+	// both pass the check: names and user IDs are unique, and an insert that
+	// conflicts leaves either this user's agent, made by the other post, or
+	// none, if another agent has the name
+	now := time.Now()
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO agents (id, name, owner, workspace_id, api_key_hash, scopes, role, kind, user_id, created_at, last_seen_at)
+		VALUES (?, ?, ?, ?, '', ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		uuid.New().String(), user.Username, user.Username, defaultWorkspaceID, string(scopes), roleWorker, agentKindHuman, user.ID, now, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("insert user agent: %w", err)
+	}
+	a, err = scanAgent(db.QueryRowContext(ctx, "SELECT "+agentColumns+" FROM agents WHERE user_id = ?", user.ID))
+	if err == sql.ErrNoRows {
+		return nil, conflictError(fmt.Sprintf("an agent is already named %q; ask an admin to rename your user to post", user.Username))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query user agent: %w", err)
+	}
+	return &a, nil
+}
+
+// humanAgentIDs returns the IDs of the human agents, for telling their
+// posts apart from agents'.
+func humanAgentIDs(ctx context.Context, db *sql.DB) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id FROM agents WHERE kind = ?", agentKindHuman)
+	if err != nil {
+		return nil, fmt.Errorf("query human agents: %w", err)
+	}
+	defer rows.Close()
+
+	ids := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan human agent: %w", err)
+		}
+		ids[id] = true
+	}
+	return ids, rows.Err()
+}
+
+// dashboardPost runs post as the logged-in user's human agent and redirects
+// back to the thread: to fragment on success, or with the error or a notice
+// that the post awaits review.
+func dashboardPost(db *sql.DB, w http.ResponseWriter, r *http.Request, post func(agent *Agent) (fragment string, err error)) {
+	user := UserFromContext(r.Context())
+	if user == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	threadURL := "/dashboard/threads/" + url.PathEscape(r.PathValue("id"))
+	agent, err := userAgent(r.Context(), db, user)
+	fragment := ""
+	if err == nil {
+		fragment, err = post(agent)
+	}
+	switch err.(type) {
+	case nil:
+		http.Redirect(w, r, threadURL+fragment, http.StatusSeeOther)
+	case quarantineError:
+		http.Redirect(w, r, threadURL+"?"+url.Values{"notice": {err.Error()}}.Encode(), http.StatusSeeOther)
+	case inputError, fieldError, notFoundError, conflictError:
+		http.Redirect(w, r, threadURL+"?"+url.Values{"error": {err.Error()}}.Encode(), http.StatusSeeOther)
+	default:
+		log.Printf("dashboard post as %s: %v", user.Username, err)
+		http.Error(w, "failed to post", http.StatusInternalServerError)
+	}
+}
+
+// handleDashboardReply posts the logged-in user's reply to a thread, under
+// the reply in parent_reply_id if it's set.
+func handleDashboardReply(db *sql.DB, bus *EventBus, w http.ResponseWriter, r *http.Request) {
+	dashboardPost(db, w, r, func(agent *Agent) (string, error) {
+		var parentReplyID *string
+		if id := r.FormValue("parent_reply_id"); id != "" {
+			parentReplyID = &id
+		}
+		reply, err := createReply(r.Context(), db, bus, agent, r.PathValue("id"), r.FormValue("body"), parentReplyID)
+		if err != nil {
+			return "", err
+		}
+		return "#reply-" + reply.ID, nil
+	})
+}
+
+// handleDashboardStatus sets a status tag on a thread as the logged-in
+// user, referencing the thread in reference_id if it's set.
+func handleDashboardStatus(db *sql.DB, bus *EventBus, w http.ResponseWriter, r *http.Request) {
+	dashboardPost(db, w, r, func(agent *Agent) (string, error) {
+		var referenceID *string
+		if id := r.FormValue("reference_id"); id != "" {
+			referenceID = &id
+		}
+		_, err := createThreadStatus(r.Context(), db, bus, agent, r.PathValue("id"), r.FormValue("tag"), referenceID, r.FormValue("unblock") != "")
+		return "", err
+	})
+}
@@ -0,0 +1,232 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// testCSRFToken is the CSRF cookie and field dashboard test requests send.
+var testCSRFToken = strings.Repeat("ab", 32)
+
+// newTestUser adds a dashboard user with role to srv's database and
+// returns a session token logged in as them.
+func newTestUser(t *testing.T, srv *Server, username, role string) string {
+	t.Helper()
+	ctx := context.Background()
+	id := uuid.New().String()
+	if _, err := srv.DB().ExecContext(ctx,
+		"INSERT INTO users (id, username, password_hash, created_at, role) VALUES (?, ?, '', ?, ?)",
+		id, username, time.Now(), role,
+	); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	token, _, err := createUserSession(ctx, srv.DB(), srv.cfg, id)
+	if err != nil {
+		t.Fatalf("createUserSession: %v", err)
+	}
+	return token
+}
+
+// dashboardRequest sends a dashboard request in session, with form as a
+// POST body if it isn't nil, without following redirects, and returns the
+// response's status and body.
+func dashboardRequest(t *testing.T, ts *httptest.Server, session, path string, form url.Values) (int, string) {
+	t.Helper()
+	method, body := "GET", ""
+	if form != nil {
+		method = "POST"
+		form.Set(csrfFormField, testCSRFToken)
+		body = form.Encode()
+	}
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: testCSRFToken})
+	req.AddCookie(&http.Cookie{Name: userSessionCookie, Value: session})
+	client := ts.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func TestViewersCannotPost(t *testing.T) {
+	srv, ts, key := startTestServer(t, func(cfg *Config) { cfg.DashboardAuthRequired = true })
+	status, thread := do(t, ts, key, "POST", "/api/v1/threads", `{"title": "Read only", "body": "Viewers watch."}`)
+	if status != http.StatusCreated {
+		t.Fatalf("create thread: status %d (%v)", status, thread)
+	}
+	id, _ := thread["id"].(string)
+	viewer := newTestUser(t, srv, "vera", userRoleViewer)
+	moderator := newTestUser(t, srv, "mo", userRoleModerator)
+
+	if _, page := dashboardRequest(t, ts, viewer, "/dashboard/threads/"+id, nil); strings.Contains(page, "/replies") || strings.Contains(page, "/status") {
+		t.Error("viewer's thread page has the reply or status form")
+	}
+	if _, page := dashboardRequest(t, ts, moderator, "/dashboard/threads/"+id, nil); !strings.Contains(page, "/replies") {
+		t.Error("moderator's thread page has no reply form")
+	}
+
+	for _, post := range []struct {
+		path string
+		form url.Values
+	}{
+		{"/replies", url.Values{"body": {"I shouldn't."}}},
+		{"/status", url.Values{"tag": {"in-progress"}}},
+	} {
+		status, body := dashboardRequest(t, ts, viewer, "/dashboard/threads/"+id+post.path, post.form)
+		if status != http.StatusForbidden || !strings.Contains(body, "only moderators") {
+			t.Errorf("viewer POST %s: status %d (%s), want 403", post.path, status, body)
+		}
+	}
+	var replies, statuses, humans int
+	db := srv.DB()
+	db.QueryRow("SELECT COUNT(*) FROM replies").Scan(&replies)
+	db.QueryRow("SELECT COUNT(*) FROM status_tags").Scan(&statuses)
+	db.QueryRow("SELECT COUNT(*) FROM agents WHERE kind = ?", agentKindHuman).Scan(&humans)
+	if replies != 0 || statuses != 0 || humans != 0 {
+		t.Errorf("viewer's posts wrote %d replies, %d status tags, %d human agents", replies, statuses, humans)
+	}
+
+	if status, body := dashboardRequest(t, ts, moderator, "/dashboard/threads/"+id+"/replies", url.Values{"body": {"I can."}}); status != http.StatusSeeOther {
+		t.Errorf("moderator reply: status %d (%s), want 303", status, body)
+	}
+}
+
+func TestHumansPostInEveryWorkspace(t *testing.T) {
+	srv, ts, _ := startTestServer(t, func(cfg *Config) { cfg.DashboardAuthRequired = true })
+	ctx := context.Background()
+	db := srv.DB()
+	ws, err := createWorkspace(ctx, db, "elsewhere")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, key, err := createAgent(ctx, db, "remote", "tests", ws.ID, []string{scopeRead, scopeWrite}, roleWorker, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, thread := do(t, ts, key, "POST", "/api/v1/threads", `{"title": "Far away", "body": "In another workspace."}`)
+	if status != http.StatusCreated {
+		t.Fatalf("create thread: status %d (%v)", status, thread)
+	}
+	id, _ := thread["id"].(string)
+
+	moderator := newTestUser(t, srv, "mo", userRoleModerator)
+	status, body := dashboardRequest(t, ts, moderator, "/dashboard/threads/"+id+"/replies", url.Values{"body": {"Hello from the dashboard."}})
+	if status != http.StatusSeeOther {
+		t.Errorf("reply: status %d (%s), want 303", status, body)
+	}
+	var replies int
+	db.QueryRow("SELECT COUNT(*) FROM replies WHERE thread_id = ?", id).Scan(&replies)
+	if replies != 1 {
+		t.Errorf("%d replies on the other workspace's thread, want 1", replies)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	srv, _, _ := startTestServer(t, nil)
+	ctx := context.Background()
+	db := srv.DB()
+	user := &User{ID: uuid.New().String(), Username: "hana"}
+	if _, err := db.ExecContext(ctx, "INSERT INTO users (id, username, password_hash, created_at) VALUES (?, ?, '', ?)", user.ID, user.Username, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	// First posts at once make one agent
+	agents := make(chan *Agent, 4)
+	for range 4 {
+		go func() {
+			a, err := userAgent(ctx, db, user)
+			if err != nil {
+				t.Error(err)
+			}
+			agents <- a
+		}()
+	}
+	var id string
+	for range 4 {
+		a := <-agents
+		if a == nil {
+			continue
+		}
+		if id == "" {
+			id = a.ID
+		}
+		if a.ID != id || a.Kind != agentKindHuman {
+			t.Errorf("agent %s (%s), want one human agent %s", a.ID, a.Kind, id)
+		}
+	}
+
+	// Key operations leave it alone
+	if _, ok := revokeAgent(ctx, db, id).(notFoundError); !ok {
+		t.Error("revokeAgent: want not found")
+	}
+	if _, err := rotateAgentKey(ctx, db, id, time.Hour); err != sql.ErrNoRows {
+		t.Errorf("rotateAgentKey: %v, want sql.ErrNoRows", err)
+	}
+
+	// A name another agent has is refused
+	other := &User{ID: uuid.New().String(), Username: "tester"}
+	if _, err := db.ExecContext(ctx, "INSERT INTO users (id, username, password_hash, created_at) VALUES (?, ?, '', ?)", other.ID, other.Username, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := userAgent(ctx, db, other); !errors.As(err, new(conflictError)) {
+		t.Errorf("userAgent with a taken name: %v, want a conflict", err)
+	}
+}
+
+func TestUserLoginLockout(t *testing.T) {
+	srv, ts, _ := startTestServer(t, func(cfg *Config) { cfg.DashboardAuthRequired = true })
+	ctx := context.Background()
+	newTestUser(t, srv, "ada", userRoleViewer)
+	var id string
+	if err := srv.DB().QueryRowContext(ctx, "SELECT id FROM users WHERE username = 'ada'").Scan(&id); err != nil {
+		t.Fatal(err)
+	}
+	if err := setUserPassword(ctx, srv.DB(), id, "correct horse", false, ""); err != nil {
+		t.Fatal(err)
+	}
+	login := func(username, password string) (int, string) {
+		t.Helper()
+		return dashboardRequest(t, ts, "", "/login", url.Values{"username": {username}, "password": {password}})
+	}
+
+	if status, _ := login("ada", "correct horse"); status != http.StatusSeeOther {
+		t.Fatalf("login: status %d, want 303", status)
+	}
+	// Unknown usernames fail like wrong passwords, and count the same
+	for _, username := range []string{"ada", "ghost"} {
+		for i := 0; i < maxAccountLoginFailures; i++ {
+			if status, body := login(username, "wrong"); status != http.StatusOK || !strings.Contains(body, "Invalid username or password.") {
+				t.Fatalf("%s wrong password %d: status %d", username, i, status)
+			}
+		}
+	}
+	if status, body := login("ada", "correct horse"); status != http.StatusTooManyRequests || !strings.Contains(body, "Too many failed attempts") {
+		t.Errorf("locked out login: status %d, want 429", status)
+	}
+	if status, _ := login("ghost", "wrong"); status != http.StatusTooManyRequests {
+		t.Errorf("locked out unknown user: status %d, want 429", status)
+	}
+}
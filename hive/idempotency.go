@@ -0,0 +1,157 @@
+package hive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// An agent may send an Idempotency-Key header with a create request. The
+// first request with a key runs as usual, and if it succeeds its response is
+// stored; later requests with the same key get that response again instead
+// of creating a duplicate. Failed requests store nothing, so they can be
+// retried with the same key.
+
+const (
+	idempotencyHeader = "Idempotency-Key"
+	// idempotencyKeyTTL is how long a stored response is replayed.
+	idempotencyKeyTTL    = 24 * time.Hour
+	maxIdempotencyKeyLen = 255
+)
+
+// idempotencyRecorder passes a response through while keeping a copy.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(p)
+	return rec.ResponseWriter.Write(p)
+}
+
+// Idempotency replays stored responses for repeated Idempotency-Key
+// requests. It must run after APIKeyAuth, since keys belong to an agent.
+func Idempotency(db *sql.DB) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(idempotencyHeader)
+			agent := AgentFromContext(r.Context())
+			if key == "" || agent == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLen {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("Idempotency-Key must be at most %d characters", maxIdempotencyKeyLen)})
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "failed to read request body"})
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			sum := sha256.Sum256([]byte(r.Method + " " + r.URL.RequestURI() + "\n" + string(body)))
+			hash := hex.EncodeToString(sum[:])
+
+			stored, err := claimIdempotencyKey(r.Context(), db, agent.ID, key, hash, time.Now().UTC())
+			if err != nil {
+				writeStoreError(w, err, "failed to check idempotency key")
+				return
+			}
+			if stored != nil {
+				w.Header().Set("Content-Type", stored.contentType)
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(stored.status)
+				w.Write(stored.body)
+				return
+			}
+
+			rec := &idempotencyRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			// Use a fresh context: the response is stored even if the
+			// agent has already hung up, since that's when it will retry.
+			ctx := context.WithoutCancel(r.Context())
+			if rec.status >= 200 && rec.status < 300 {
+				_, err = db.ExecContext(ctx,
+					"UPDATE idempotency_keys SET status = ?, content_type = ?, body = ? WHERE agent_id = ? AND key = ?",
+					rec.status, rec.Header().Get("Content-Type"), rec.body.Bytes(), agent.ID, key,
+				)
+			} else {
+				_, err = db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE agent_id = ? AND key = ?", agent.ID, key)
+			}
+			if err != nil {
+				log.Printf("idempotency key %q: %v", key, err)
+			}
+		})
+	}
+}
+
+// storedResponse is a response saved for an idempotency key.
+type storedResponse struct {
+	status      int
+	contentType string
+	body        []byte
+}
+
+// claimIdempotencyKey reserves key for a request with the given hash. It
+// returns the stored response if the key was already used for the same
+// request, or nil if the caller should run the request. Keys older than
+// idempotencyKeyTTL are forgotten.
+func claimIdempotencyKey(ctx context.Context, db *sql.DB, agentID, key, hash string, now time.Time) (*storedResponse, error) {
+	if _, err := db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE created_at < ?", now.Add(-idempotencyKeyTTL)); err != nil {
+		return nil, fmt.Errorf("expire idempotency keys: %w", err)
+	}
+
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (agent_id, key, request_hash, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (agent_id, key) DO NOTHING`,
+		agentID, key, hash, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("claim idempotency key: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 1 {
+		return nil, nil
+	}
+
+	var storedHash string
+	var stored storedResponse
+	err = db.QueryRowContext(ctx,
+		"SELECT request_hash, status, content_type, body FROM idempotency_keys WHERE agent_id = ? AND key = ?",
+		agentID, key,
+	).Scan(&storedHash, &stored.status, &stored.contentType, &stored.body)
+	if err == sql.ErrNoRows {
+		// The first request failed and released the key in the meantime.
+		return claimIdempotencyKey(ctx, db, agentID, key, hash, now)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load idempotency key: %w", err)
+	}
+	if storedHash != hash {
+		return nil, conflictError("Idempotency-Key was already used for a different request")
+	}
+	if stored.status == 0 {
+		return nil, conflictError("a request with this Idempotency-Key is still in progress")
+	}
+	return &stored, nil
+}
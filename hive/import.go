@@ -0,0 +1,500 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// maxImportBytes caps the size of an import bundle.
+const maxImportBytes = 32 << 20
+
+// ImportBundle is a set of forum records to load with their IDs and
+// timestamps intact. Threads may carry their replies and statuses nested, as
+// returned by GET /threads/{id} and the thread export, or they may be listed
+// separately in Replies and Statuses. Replies must come after their parents.
+type ImportBundle struct {
+	Agents   []Agent     `json:"agents"`
+	Threads  []Thread    `json:"threads"`
+	Replies  []Reply     `json:"replies"`
+	Statuses []StatusTag `json:"statuses"`
+}
+
+// ImportResult counts what an import added.
+type ImportResult struct {
+	Agents   int `json:"agents"`
+	Threads  int `json:"threads"`
+	Replies  int `json:"replies"`
+	Statuses int `json:"statuses"`
+	// Skipped counts records left alone because their ID already existed.
+	Skipped int `json:"skipped"`
+	// APIKeys holds a new raw API key for each imported agent, by name. Keys
+	// aren't part of bundles, so these are the only way to act as an
+	// imported agent; they are not shown again.
+	APIKeys map[string]string `json:"api_keys"`
+}
+
+// flatten moves nested replies and statuses into the bundle's top-level
+// lists, filling in the IDs implied by nesting.
+func (b *ImportBundle) flatten() {
+	var replies []Reply
+	var statuses []StatusTag
+	for i := range b.Threads {
+		t := &b.Threads[i]
+		for _, st := range t.Statuses {
+			st.ThreadID, st.ReplyID = &t.ID, nil
+			statuses = append(statuses, st)
+		}
+		for _, r := range t.Replies {
+			r.ThreadID = t.ID
+			for _, st := range r.Statuses {
+				st.ThreadID, st.ReplyID = nil, &r.ID
+				statuses = append(statuses, st)
+			}
+			r.Statuses = nil
+			replies = append(replies, r)
+		}
+		t.Replies, t.Statuses = nil, nil
+	}
+	b.Replies = append(replies, b.Replies...)
+	b.Statuses = append(statuses, b.Statuses...)
+}
+
+// importer loads one bundle inside a transaction.
+type importer struct {
+	ctx          context.Context
+	tx           *sql.Tx
+	skipExisting bool
+	result       ImportResult
+}
+
+// exists reports whether table has a row with the given id. table is always
+// a constant.
+func (im *importer) exists(table, id string) (bool, error) {
+	var found bool
+	err := im.tx.QueryRowContext(im.ctx, "SELECT EXISTS(SELECT 1 FROM "+table+" WHERE id = ?)", id).Scan(&found)
+	if err != nil {
+		return false, fmt.Errorf("query %s: %w", table, err)
+	}
+	return found, nil
+}
+
+// claim checks that a record's ID is set and free. It returns false if the
+// record should be skipped.
+func (im *importer) claim(kind, table, id string) (bool, error) {
+	if id == "" {
+		return false, inputError(kind + " id is required")
+	}
+	found, err := im.exists(table, id)
+	if err != nil || !found {
+		return err == nil, err
+	}
+	if !im.skipExisting {
+		return false, inputError(fmt.Sprintf("%s %s already exists", kind, id))
+	}
+	im.result.Skipped++
+	return false, nil
+}
+
+// requireAgent checks that a record's author exists, in the bundle or
+// already in the database.
+func (im *importer) requireAgent(kind, id, agentID string) error {
+	found, err := im.exists("agents", agentID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return inputError(fmt.Sprintf("%s %s: agent %q not found", kind, id, agentID))
+	}
+	return nil
+}
+
+// timestamps defaults a missing creation time to now and a missing update
+// time to the creation time.
+func timestamps(created, updated time.Time) (time.Time, time.Time) {
+	if created.IsZero() {
+		created = time.Now()
+	}
+	if updated.IsZero() {
+		updated = created
+	}
+	return created, updated
+}
+
+func (im *importer) importAgent(a Agent) error {
+	ok, err := im.claim("agent", "agents", a.ID)
+	if err != nil || !ok {
+		return err
+	}
+	if a.Name == "" || a.Owner == "" {
+		return inputError(fmt.Sprintf("agent %s: name and owner are required", a.ID))
+	}
+	if len(a.Scopes) == 0 {
+		a.Scopes = []string{scopeRead, scopeWrite}
+	}
+	for _, scope := range a.Scopes {
+		if !validScopes[scope] {
+			return inputError(fmt.Sprintf("agent %s: invalid scope %q", a.ID, scope))
+		}
+	}
+	if a.Role == "" {
+		a.Role = roleWorker
+	}
+	if !validRoles[a.Role] {
+		return inputError(fmt.Sprintf("agent %s: invalid role", a.ID))
+	}
+	workspaceID := defaultWorkspaceID
+	if a.WorkspaceID != "" {
+		id, err := resolveWorkspace(im.ctx, im.tx, a.WorkspaceID)
+		if _, ok := err.(notFoundError); ok {
+			return inputError(fmt.Sprintf("agent %s: %v", a.ID, err))
+		}
+		if err != nil {
+			return err
+		}
+		workspaceID = id
+	}
+
+	var taken bool
+	if err := im.tx.QueryRowContext(im.ctx, "SELECT EXISTS(SELECT 1 FROM agents WHERE name = ?)", a.Name).Scan(&taken); err != nil {
+		return fmt.Errorf("check agent name: %w", err)
+	}
+	if taken {
+		return inputError(fmt.Sprintf("agent %s: an agent named %q already exists", a.ID, a.Name))
+	}
+
+	scopesJSON, err := json.Marshal(a.Scopes)
+	if err != nil {
+		return fmt.Errorf("marshal scopes: %w", err)
+	}
+	capabilities, err := normalizeProfileList("capabilities", a.Capabilities)
+	if err != nil {
+		return inputError(fmt.Sprintf("agent %s: %v", a.ID, err))
+	}
+	toolset, err := normalizeProfileList("toolset", a.Toolset)
+	if err != nil {
+		return inputError(fmt.Sprintf("agent %s: %v", a.ID, err))
+	}
+	capabilitiesJSON, err := json.Marshal(capabilities)
+	if err != nil {
+		return fmt.Errorf("marshal capabilities: %w", err)
+	}
+	toolsetJSON, err := json.Marshal(toolset)
+	if err != nil {
+		return fmt.Errorf("marshal toolset: %w", err)
+	}
+	keyID, rawAPIKey, hash, err := generateAPIKey()
+	if err != nil {
+		return err
+	}
+	created, lastSeen := timestamps(a.CreatedAt, a.LastSeenAt)
+	_, err = im.tx.ExecContext(im.ctx,
+		`INSERT INTO agents (id, name, owner, workspace_id, key_id, api_key_hash, scopes, role, capabilities, model, toolset, description, created_at, last_seen_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.ID, a.Name, a.Owner, workspaceID, keyID, hash, string(scopesJSON), a.Role, string(capabilitiesJSON), a.Model, string(toolsetJSON), a.Description, created, lastSeen,
+	)
+	if err != nil {
+		return fmt.Errorf("insert agent: %w", err)
+	}
+	im.result.Agents++
+	im.result.APIKeys[a.Name] = rawAPIKey
+	return nil
+}
+
+func (im *importer) importThread(t Thread) error {
+	ok, err := im.claim("thread", "threads", t.ID)
+	if err != nil || !ok {
+		return err
+	}
+	if t.Title == "" || t.Body == "" {
+		return inputError(fmt.Sprintf("thread %s: title and body are required", t.ID))
+	}
+	if err := im.requireAgent("thread", t.ID, t.AgentID); err != nil {
+		return err
+	}
+	priority, err := checkPriority(t.Priority)
+	if err != nil {
+		return inputError(fmt.Sprintf("thread %s: %s", t.ID, err))
+	}
+	visibility, err := checkVisibility(t.Visibility)
+	if err != nil {
+		return inputError(fmt.Sprintf("thread %s: %s", t.ID, err))
+	}
+	for _, p := range t.Participants {
+		if err := im.requireAgent("thread", t.ID, p.AgentID); err != nil {
+			return err
+		}
+	}
+	if t.MergedInto != nil {
+		found, err := im.exists("threads", *t.MergedInto)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return inputError(fmt.Sprintf("thread %s: merged into thread %q, which is not found (merge targets must come first)", t.ID, *t.MergedInto))
+		}
+	}
+	if t.Tags == nil {
+		t.Tags = []string{}
+	}
+	tagsJSON, err := json.Marshal(t.Tags)
+	if err != nil {
+		return fmt.Errorf("marshal tags: %w", err)
+	}
+
+	created, updated := timestamps(t.CreatedAt, t.UpdatedAt)
+	_, err = im.tx.ExecContext(im.ctx,
+		`INSERT INTO threads (id, agent_id, title, body, tags, pinned, archived, locked, priority, due_at, publish_at, merged_into, visibility, workspace_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, (SELECT workspace_id FROM agents WHERE id = ?), ?, ?)`,
+		t.ID, t.AgentID, t.Title, t.Body, string(tagsJSON), t.Pinned, t.Archived, t.Locked, priority, utcTime(t.DueAt), utcTime(t.PublishAt), t.MergedInto, visibility, t.AgentID, created, updated,
+	)
+	if err != nil {
+		return fmt.Errorf("insert thread: %w", err)
+	}
+	// Authors and participants follow the thread
+	followers := []string{t.AgentID}
+	for _, p := range t.Participants {
+		added := p.AddedAt
+		if added.IsZero() {
+			added = created
+		}
+		_, err = im.tx.ExecContext(im.ctx,
+			`INSERT INTO thread_participants (thread_id, agent_id, added_at) VALUES (?, ?, ?)
+			ON CONFLICT (thread_id, agent_id) DO NOTHING`,
+			t.ID, p.AgentID, added,
+		)
+		if err != nil {
+			return fmt.Errorf("insert participant: %w", err)
+		}
+		followers = append(followers, p.AgentID)
+	}
+	for _, agentID := range followers {
+		_, err = im.tx.ExecContext(im.ctx,
+			`INSERT INTO subscriptions (agent_id, thread_id, created_at) VALUES (?, ?, ?)
+			ON CONFLICT (agent_id, thread_id) DO NOTHING`,
+			agentID, t.ID, created,
+		)
+		if err != nil {
+			return fmt.Errorf("subscribe thread follower: %w", err)
+		}
+	}
+	im.result.Threads++
+	return nil
+}
+
+func (im *importer) importReply(r Reply) error {
+	ok, err := im.claim("reply", "replies", r.ID)
+	if err != nil || !ok {
+		return err
+	}
+	if r.Body == "" {
+		return inputError(fmt.Sprintf("reply %s: body is required", r.ID))
+	}
+	found, err := im.exists("threads", r.ThreadID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return inputError(fmt.Sprintf("reply %s: thread %q not found", r.ID, r.ThreadID))
+	}
+	if err := im.requireAgent("reply", r.ID, r.AgentID); err != nil {
+		return err
+	}
+	if r.ParentReplyID != nil {
+		var parentThreadID string
+		err := im.tx.QueryRowContext(im.ctx, "SELECT thread_id FROM replies WHERE id = ?", *r.ParentReplyID).Scan(&parentThreadID)
+		if err == sql.ErrNoRows || (err == nil && parentThreadID != r.ThreadID) {
+			return inputError(fmt.Sprintf("reply %s: parent reply not found in its thread (parents must come first)", r.ID))
+		}
+		if err != nil {
+			return fmt.Errorf("query parent reply: %w", err)
+		}
+	}
+
+	created, updated := timestamps(r.CreatedAt, r.UpdatedAt)
+	_, err = im.tx.ExecContext(im.ctx,
+		`INSERT INTO replies (id, thread_id, parent_reply_id, agent_id, body, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		r.ID, r.ThreadID, r.ParentReplyID, r.AgentID, r.Body, created, updated,
+	)
+	if err != nil {
+		return fmt.Errorf("insert reply: %w", err)
+	}
+	im.result.Replies++
+	return nil
+}
+
+func (im *importer) importStatus(st StatusTag) error {
+	ok, err := im.claim("status", "status_tags", st.ID)
+	if err != nil || !ok {
+		return err
+	}
+	if !validStatusTags[st.Tag] {
+		return inputError(fmt.Sprintf("status %s: invalid status tag", st.ID))
+	}
+	if (st.ThreadID == nil) == (st.ReplyID == nil) {
+		return inputError(fmt.Sprintf("status %s: exactly one of thread_id and reply_id is required", st.ID))
+	}
+	table, targetID := "threads", st.ThreadID
+	if st.ReplyID != nil {
+		table, targetID = "replies", st.ReplyID
+	}
+	found, err := im.exists(table, *targetID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return inputError(fmt.Sprintf("status %s: target %q not found", st.ID, *targetID))
+	}
+	if err := im.requireAgent("status", st.ID, st.AgentID); err != nil {
+		return err
+	}
+
+	created, _ := timestamps(st.CreatedAt, time.Time{})
+	_, err = im.tx.ExecContext(im.ctx,
+		`INSERT INTO status_tags (id, thread_id, reply_id, agent_id, tag, reference_id, superseded_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		st.ID, st.ThreadID, st.ReplyID, st.AgentID, st.Tag, st.ReferenceID, st.SupersededBy, created,
+	)
+	if err != nil {
+		return fmt.Errorf("insert status tag: %w", err)
+	}
+	im.result.Statuses++
+	return nil
+}
+
+// importBundle loads a bundle in one transaction: either every record is
+// added or none are. A record whose ID already exists fails the import,
+// unless skipExisting is set, in which case it is left as it is. Imports are
+// history, so they don't record mentions, notify subscribers, or publish
+// events.
+func importBundle(ctx context.Context, db *sql.DB, b ImportBundle, skipExisting bool) (ImportResult, error) {
+	b.flatten()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("begin import: %w", err)
+	}
+	defer tx.Rollback()
+	// Take the write lock before the first read. In WAL mode a transaction
+	// that reads and then writes fails if another connection wrote in
+	// between, and agents' last_seen_at is updated on every request.
+	if _, err := tx.ExecContext(ctx, "DELETE FROM agents WHERE 0"); err != nil {
+		return ImportResult{}, fmt.Errorf("lock database: %w", err)
+	}
+
+	im := &importer{ctx: ctx, tx: tx, skipExisting: skipExisting}
+	im.result.APIKeys = map[string]string{}
+	for _, a := range b.Agents {
+		if err := im.importAgent(a); err != nil {
+			return ImportResult{}, err
+		}
+	}
+	for _, t := range b.Threads {
+		if err := im.importThread(t); err != nil {
+			return ImportResult{}, err
+		}
+	}
+	for _, r := range b.Replies {
+		if err := im.importReply(r); err != nil {
+			return ImportResult{}, err
+		}
+	}
+	for _, st := range b.Statuses {
+		if err := im.importStatus(st); err != nil {
+			return ImportResult{}, err
+		}
+	}
+	// Bundles from before the status state machine don't say which
+	// statuses were superseded.
+	if err := backfillSuperseded(ctx, tx); err != nil {
+		return ImportResult{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return ImportResult{}, fmt.Errorf("commit import: %w", err)
+	}
+	return im.result, nil
+}
+
+// decodeImportBundle reads a bundle, rejecting unknown fields so that typos
+// don't silently drop data.
+func decodeImportBundle(r io.Reader) (ImportBundle, error) {
+	var b ImportBundle
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&b); err != nil {
+		return ImportBundle{}, inputError("invalid import bundle: " + err.Error())
+	}
+	return b, nil
+}
+
+// handleImport loads a JSON bundle of agents, threads, replies, and statuses.
+// Requires the admin scope.
+func handleImport(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requireScope(w, agent, scopeAdmin) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	b, err := decodeImportBundle(r.Body)
+	if err != nil {
+		writeStoreError(w, err, "failed to import")
+		return
+	}
+	result, err := importBundle(r.Context(), db, b, r.URL.Query().Get("skip_existing") == "true")
+	if err != nil {
+		writeStoreError(w, err, "failed to import")
+		return
+	}
+	log.Printf("import by %s: %d agents, %d threads, %d replies, %d statuses, %d skipped",
+		agent.Name, result.Agents, result.Threads, result.Replies, result.Statuses, result.Skipped)
+
+	writeJSON(w, http.StatusCreated, result)
+}
+
+// handleAdminImportPage renders the import form.
+func handleAdminImportPage(w http.ResponseWriter, r *http.Request) {
+	renderAdminTemplate(w, r, "import.html", map[string]interface{}{})
+}
+
+// handleAdminImport loads a bundle uploaded from the admin panel and shows
+// the result, including the new agents' API keys.
+func handleAdminImport(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes+1<<20)
+	file, _, err := r.FormFile("bundle")
+	if err != nil {
+		http.Error(w, "a bundle file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	b, err := decodeImportBundle(file)
+	var result ImportResult
+	if err == nil {
+		result, err = importBundle(r.Context(), db, b, r.FormValue("skip_existing") == "on")
+	}
+	if _, ok := err.(inputError); ok {
+		renderAdminTemplate(w, r, "import.html", map[string]interface{}{"Error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("admin import: %v", err)
+		http.Error(w, "failed to import", http.StatusInternalServerError)
+		return
+	}
+	if admin := AdminFromContext(r.Context()); admin != nil {
+		log.Printf("import by admin %s: %d agents, %d threads, %d replies, %d statuses, %d skipped",
+			admin.Username, result.Agents, result.Threads, result.Replies, result.Statuses, result.Skipped)
+	}
+
+	renderAdminTemplate(w, r, "import.html", map[string]interface{}{"Result": result})
+}
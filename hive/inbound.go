@@ -0,0 +1,622 @@
+package hive
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Inbound webhooks let external systems feed work into the forum. Admins
+// add an inbound source in the admin panel, naming the agent its threads
+// and replies are posted as, and point the system at
+// POST /api/v1/inbound/{name} with the source's secret. Each delivery is
+// turned into a new thread or, when it concerns something that already has
+// one (the same GitHub issue, failing workflow, or alert group), a reply
+// on that thread. GitHub issues, pull requests, and workflow runs and
+// Alertmanager notifications are understood as sent; anything else can
+// post a generic JSON event.
+
+// Inbound source kinds.
+const (
+	inboundGitHub       = "github"
+	inboundAlertmanager = "alertmanager"
+	inboundGeneric      = "generic"
+)
+
+var validInboundKinds = map[string]bool{
+	inboundGitHub:       true,
+	inboundAlertmanager: true,
+	inboundGeneric:      true,
+}
+
+// maxInboundBody caps the size of a delivery.
+const maxInboundBody = 1 << 20
+
+// inboundNamePattern is what a source's name, the last segment of its URL,
+// may look like.
+var inboundNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// InboundSource is an external system allowed to post to the forum.
+type InboundSource struct {
+	ID   string
+	Name string
+	// Kind is github, alertmanager, or generic.
+	Kind string
+	// Secret verifies deliveries, as the key of an X-Hub-Signature-256
+	// HMAC or a bearer token.
+	Secret         string
+	AgentID        string
+	AgentName      string
+	LastReceivedAt *time.Time
+	CreatedAt      time.Time
+}
+
+// verify reports whether a delivery carries the source's secret: as an
+// HMAC-SHA256 of the body in X-Hub-Signature-256, as GitHub signs
+// deliveries, or as a bearer token, for senders that can't sign.
+func (src InboundSource) verify(r *http.Request, body []byte) bool {
+	if sig := r.Header.Get("X-Hub-Signature-256"); sig != "" {
+		mac := hmac.New(sha256.New, []byte(src.Secret))
+		mac.Write(body)
+		return hmac.Equal([]byte(sig), []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(src.Secret)) == 1
+}
+
+// inboundEvent is what a delivery asks of the forum.
+type inboundEvent struct {
+	// key identifies what the event is about, so later events about it
+	// reply on the same thread. Empty always creates a thread.
+	key string
+	// title, body, tags, and priority make the thread if key has none.
+	title, body, priority string
+	tags                  []string
+	// update is the reply if key has a thread; empty means body.
+	update string
+	// onlyUpdate drops the event if key has no thread.
+	onlyUpdate bool
+	// status is a status tag to set on the thread.
+	status string
+	// forget unlinks key from its thread afterwards, so the next event
+	// about it starts a new thread.
+	forget bool
+}
+
+// InboundResult is what became of a delivery.
+type InboundResult struct {
+	// Action is "thread", "reply", or "ignored".
+	Action   string `json:"action"`
+	ThreadID string `json:"thread_id,omitempty"`
+	ReplyID  string `json:"reply_id,omitempty"`
+}
+
+// parseInbound turns a delivery from a source of kind into an event, or nil
+// if it's one the forum ignores.
+func parseInbound(kind string, r *http.Request, body []byte) (*inboundEvent, error) {
+	switch kind {
+	case inboundGitHub:
+		return parseGitHubEvent(r.Header.Get("X-GitHub-Event"), body)
+	case inboundAlertmanager:
+		return parseAlertmanagerEvent(body)
+	default:
+		return parseGenericEvent(body)
+	}
+}
+
+// gitHubIssue is the part of a GitHub issue or pull request the forum
+// uses.
+type gitHubIssue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	// PullRequest is set on issue_comment events for pull requests.
+	PullRequest *struct{} `json:"pull_request"`
+	Merged      bool      `json:"merged"`
+	Head        struct {
+		Ref string `json:"ref"`
+	} `json:"head"`
+	Base struct {
+		Ref string `json:"ref"`
+	} `json:"base"`
+}
+
+// parseGitHubEvent turns issues, issue_comment, pull_request, and
+// workflow_run events into forum events. Others, including GitHub's ping,
+// are ignored.
+func parseGitHubEvent(event string, body []byte) (*inboundEvent, error) {
+	var p struct {
+		Action      string       `json:"action"`
+		Issue       *gitHubIssue `json:"issue"`
+		PullRequest *gitHubIssue `json:"pull_request"`
+		Comment     struct {
+			Body    string `json:"body"`
+			HTMLURL string `json:"html_url"`
+		} `json:"comment"`
+		WorkflowRun struct {
+			Name       string `json:"name"`
+			RunNumber  int    `json:"run_number"`
+			HeadBranch string `json:"head_branch"`
+			HeadSHA    string `json:"head_sha"`
+			Conclusion string `json:"conclusion"`
+			HTMLURL    string `json:"html_url"`
+		} `json:"workflow_run"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+		Sender struct {
+			Login string `json:"login"`
+		} `json:"sender"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, inputError("invalid JSON payload")
+	}
+	repo, sender := p.Repository.FullName, p.Sender.Login
+
+	switch event {
+	case "issues", "pull_request":
+		issue, noun, keyPrefix, tag := p.Issue, "Issue", "issue", "issue"
+		if event == "pull_request" {
+			issue, noun, keyPrefix, tag = p.PullRequest, "Pull request", "pr", "pull-request"
+		}
+		if issue == nil {
+			return nil, inputError("payload has no " + strings.ToLower(noun))
+		}
+		ev := &inboundEvent{key: fmt.Sprintf("%s:%s#%d", keyPrefix, repo, issue.Number)}
+		link := fmt.Sprintf("[#%d](%s)", issue.Number, issue.HTMLURL)
+		switch p.Action {
+		case "opened":
+			ev.title = fmt.Sprintf("%s#%d: %s", repo, issue.Number, issue.Title)
+			ev.body = strings.TrimSpace(issue.Body)
+			if ev.body == "" {
+				ev.body = "_No description._"
+			}
+			ev.body += fmt.Sprintf("\n\n---\n%s %s opened by %s on GitHub.", noun, link, issue.User.Login)
+			if event == "pull_request" {
+				ev.body += fmt.Sprintf(" Merging `%s` into `%s`.", issue.Head.Ref, issue.Base.Ref)
+			}
+			ev.tags = []string{"github", tag}
+			for _, l := range issue.Labels {
+				ev.tags = append(ev.tags, l.Name)
+			}
+		case "closed":
+			ev.onlyUpdate, ev.status = true, statusResolved
+			ev.update = fmt.Sprintf("%s %s closed by %s on GitHub.", noun, link, sender)
+			if issue.Merged {
+				ev.update = fmt.Sprintf("%s %s merged by %s on GitHub.", noun, link, sender)
+			}
+		case "reopened":
+			ev.onlyUpdate = true
+			ev.update = fmt.Sprintf("%s %s reopened by %s on GitHub.", noun, link, sender)
+		default:
+			return nil, nil
+		}
+		return ev, nil
+
+	case "issue_comment":
+		if p.Action != "created" || p.Issue == nil {
+			return nil, nil
+		}
+		keyPrefix := "issue"
+		if p.Issue.PullRequest != nil {
+			keyPrefix = "pr"
+		}
+		return &inboundEvent{
+			key:        fmt.Sprintf("%s:%s#%d", keyPrefix, repo, p.Issue.Number),
+			onlyUpdate: true,
+			update:     fmt.Sprintf("%s [commented](%s) on GitHub:\n\n%s", sender, p.Comment.HTMLURL, p.Comment.Body),
+		}, nil
+
+	case "workflow_run":
+		run := p.WorkflowRun
+		if p.Action != "completed" {
+			return nil, nil
+		}
+		ev := &inboundEvent{key: fmt.Sprintf("ci:%s:%s:%s", repo, run.Name, run.HeadBranch)}
+		runLink := fmt.Sprintf("[#%d](%s)", run.RunNumber, run.HTMLURL)
+		sha := run.HeadSHA
+		if len(sha) > 7 {
+			sha = sha[:7]
+		}
+		switch run.Conclusion {
+		case "failure", "timed_out":
+			ev.title = fmt.Sprintf("CI failing: %s on %s (%s)", run.Name, run.HeadBranch, repo)
+			ev.body = fmt.Sprintf("Run %s of %s failed (%s) on `%s` at `%s`.", runLink, run.Name, run.Conclusion, run.HeadBranch, sha)
+			ev.update = fmt.Sprintf("Run %s failed again (%s) at `%s`.", runLink, run.Conclusion, sha)
+			ev.tags = []string{"ci", "github"}
+			ev.priority = "high"
+		case "success":
+			ev.onlyUpdate, ev.status, ev.forget = true, statusResolved, true
+			ev.update = fmt.Sprintf("Run %s passed at `%s`; CI is green again.", runLink, sha)
+		default:
+			return nil, nil
+		}
+		return ev, nil
+	}
+	return nil, nil
+}
+
+// parseAlertmanagerEvent turns an Alertmanager notification into a thread
+// per alert group, replied to while it fires and resolved when it clears.
+func parseAlertmanagerEvent(body []byte) (*inboundEvent, error) {
+	var p struct {
+		Status            string            `json:"status"`
+		GroupKey          string            `json:"groupKey"`
+		CommonLabels      map[string]string `json:"commonLabels"`
+		CommonAnnotations map[string]string `json:"commonAnnotations"`
+		Alerts            []struct {
+			Status       string            `json:"status"`
+			Labels       map[string]string `json:"labels"`
+			Annotations  map[string]string `json:"annotations"`
+			StartsAt     time.Time         `json:"startsAt"`
+			GeneratorURL string            `json:"generatorURL"`
+		} `json:"alerts"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, inputError("invalid JSON payload")
+	}
+	if p.GroupKey == "" {
+		return nil, inputError("payload has no groupKey")
+	}
+
+	name := p.CommonLabels["alertname"]
+	if name == "" {
+		name = "alert"
+	}
+	var list strings.Builder
+	for _, a := range p.Alerts {
+		fmt.Fprintf(&list, "- **%s** %s", a.Status, a.Labels["alertname"])
+		if s := a.Annotations["summary"]; s != "" {
+			fmt.Fprintf(&list, ": %s", s)
+		}
+		if a.GeneratorURL != "" {
+			fmt.Fprintf(&list, " ([source](%s))", a.GeneratorURL)
+		}
+		fmt.Fprintf(&list, ", since %s\n", a.StartsAt.UTC().Format(time.RFC3339))
+	}
+
+	ev := &inboundEvent{key: "alert:" + p.GroupKey}
+	if p.Status == "resolved" {
+		ev.onlyUpdate, ev.status, ev.forget = true, statusResolved, true
+		ev.update = "Resolved.\n\n" + list.String()
+		return ev, nil
+	}
+	ev.title = "Alert: " + name
+	if s := p.CommonAnnotations["summary"]; s != "" {
+		ev.title += " — " + s
+	}
+	ev.body = strings.TrimSpace(p.CommonAnnotations["description"]+"\n\n"+list.String()) + "\n"
+	ev.update = fmt.Sprintf("Still firing (%d alerts):\n\n%s", len(p.Alerts), list.String())
+	ev.tags = []string{"alert"}
+	switch p.CommonLabels["severity"] {
+	case "critical", "page":
+		ev.priority = "critical"
+	case "warning", "high":
+		ev.priority = "high"
+	}
+	return ev, nil
+}
+
+// parseGenericEvent reads an event the sender shaped for the forum:
+// {"title", "body", "tags", "priority", "key", "resolved"}. An event with
+// a key replies on the thread an earlier event with that key created, and
+// a resolved one resolves it.
+func parseGenericEvent(body []byte) (*inboundEvent, error) {
+	var p struct {
+		Title    string   `json:"title"`
+		Body     string   `json:"body"`
+		Tags     []string `json:"tags"`
+		Priority string   `json:"priority"`
+		Key      string   `json:"key"`
+		Resolved bool     `json:"resolved"`
+	}
+	if err := json.Unmarshal(body, &p); err != nil {
+		return nil, inputError("invalid JSON payload")
+	}
+	if p.Body == "" {
+		return nil, inputError("body is required")
+	}
+	ev := &inboundEvent{key: p.Key, title: p.Title, body: p.Body, tags: p.Tags, priority: p.Priority}
+	if p.Resolved {
+		if p.Key == "" {
+			return nil, inputError("a resolved event needs a key")
+		}
+		ev.onlyUpdate, ev.status, ev.forget = true, statusResolved, true
+	}
+	return ev, nil
+}
+
+// deliverInbound posts an event from src as agent: a reply if its key has
+// a thread, otherwise a new thread.
+func deliverInbound(ctx context.Context, db *sql.DB, bus *EventBus, src InboundSource, agent *Agent, ev *inboundEvent) (InboundResult, error) {
+	var threadID string
+	if ev.key != "" {
+		err := db.QueryRowContext(ctx,
+			"SELECT thread_id FROM inbound_threads WHERE source_id = ? AND external_key = ?", src.ID, ev.key,
+		).Scan(&threadID)
+		if err != nil && err != sql.ErrNoRows {
+			return InboundResult{}, fmt.Errorf("query inbound thread: %w", err)
+		}
+	}
+
+	result := InboundResult{Action: "reply", ThreadID: threadID}
+	if threadID != "" {
+		update := ev.update
+		if update == "" {
+			update = ev.body
+		}
+		reply, err := createReply(ctx, db, bus, agent, threadID, update, nil)
+		if _, ok := err.(notFoundError); ok {
+			// The thread was deleted; start over
+			threadID = ""
+		} else if err != nil {
+			return InboundResult{}, err
+		} else {
+			result.ReplyID = reply.ID
+		}
+	}
+	if threadID == "" {
+		if ev.onlyUpdate {
+			return InboundResult{Action: "ignored"}, nil
+		}
+		if ev.title == "" {
+			return InboundResult{}, inputError("title is required to start a thread")
+		}
+		// The thread is linked to its key as it is created, so that a
+		// failed link doesn't leave a thread the next event can't find
+		t, err := inTx(ctx, db, bus, func(tx dbtx, bus publisher) (Thread, error) {
+			t, err := addThread(ctx, tx, bus, agent, ev.title, ev.body, ev.tags, nil, ev.priority, nil, "", nil)
+			if err != nil || ev.key == "" {
+				return t, err
+			}
+			_, err = tx.ExecContext(ctx,
+				`INSERT INTO inbound_threads (source_id, external_key, thread_id) VALUES (?, ?, ?)
+				ON CONFLICT (source_id, external_key) DO UPDATE SET thread_id = excluded.thread_id`,
+				src.ID, ev.key, t.ID)
+			if err != nil {
+				return Thread{}, fmt.Errorf("link inbound thread: %w", err)
+			}
+			return t, nil
+		})
+		if err != nil {
+			return InboundResult{}, err
+		}
+		threadID = t.ID
+		result = InboundResult{Action: "thread", ThreadID: t.ID}
+	}
+
+	if ev.status != "" {
+		// The thread may already be past the status, such as an issue
+		// closed twice
+		if _, err := createThreadStatus(ctx, db, bus, agent, threadID, ev.status, nil, false); err != nil {
+			log.Printf("inbound %s: set %s on thread %s: %v", src.Name, ev.status, threadID, err)
+		}
+	}
+	if ev.forget {
+		if _, err := db.ExecContext(ctx,
+			"DELETE FROM inbound_threads WHERE source_id = ? AND external_key = ?", src.ID, ev.key); err != nil {
+			return InboundResult{}, fmt.Errorf("unlink inbound thread: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// inboundSourceColumns selects an InboundSource, aliased s, joined to its
+// agent aliased a.
+const inboundSourceColumns = "s.id, s.name, s.kind, s.secret, s.agent_id, a.name, s.last_received_at, s.created_at"
+
+func scanInboundSource(row rowScanner) (InboundSource, error) {
+	var src InboundSource
+	err := row.Scan(&src.ID, &src.Name, &src.Kind, &src.Secret, &src.AgentID, &src.AgentName, &src.LastReceivedAt, &src.CreatedAt)
+	return src, err
+}
+
+// createInboundSource adds an inbound source posting as the agent with ID
+// agentID, with a new secret.
+func createInboundSource(ctx context.Context, db *sql.DB, name, kind, agentID string) (InboundSource, error) {
+	if !inboundNamePattern.MatchString(name) {
+		return InboundSource{}, inputError("name must be 1-64 lowercase letters, digits, dashes, or underscores")
+	}
+	if !validInboundKinds[kind] {
+		return InboundSource{}, inputError("invalid kind (use github, alertmanager, or generic)")
+	}
+	var agentKind string
+	err := db.QueryRowContext(ctx, "SELECT kind FROM agents WHERE id = ?", agentID).Scan(&agentKind)
+	if err == sql.ErrNoRows || agentKind == agentKindHuman {
+		return InboundSource{}, inputError("choose an agent to post as")
+	}
+	if err != nil {
+		return InboundSource{}, fmt.Errorf("query inbound source agent: %w", err)
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return InboundSource{}, fmt.Errorf("generate inbound secret: %w", err)
+	}
+	src := InboundSource{
+		ID: uuid.New().String(), Name: name, Kind: kind, Secret: hex.EncodeToString(secret),
+		AgentID: agentID, CreatedAt: time.Now(),
+	}
+	_, err = db.ExecContext(ctx, "INSERT INTO inbound_sources (id, name, kind, secret, agent_id, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		src.ID, src.Name, src.Kind, src.Secret, src.AgentID, src.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return InboundSource{}, inputError("an inbound source with that name already exists")
+		}
+		return InboundSource{}, fmt.Errorf("insert inbound source: %w", err)
+	}
+	return src, nil
+}
+
+// handleInbound receives a delivery for the source named in the path.
+func handleInbound(db *sql.DB, bus *EventBus, w http.ResponseWriter, r *http.Request) {
+	src, err := scanInboundSource(db.QueryRowContext(r.Context(),
+		"SELECT "+inboundSourceColumns+" FROM inbound_sources s JOIN agents a ON s.agent_id = a.id WHERE s.name = ?",
+		r.PathValue("source")))
+	if err == sql.ErrNoRows {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown inbound source"})
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err, "failed to receive event")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInboundBody))
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": "event too large"})
+		return
+	}
+	if !src.verify(r, body) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid signature or token"})
+		return
+	}
+	if _, err := db.ExecContext(r.Context(), "UPDATE inbound_sources SET last_received_at = ? WHERE id = ?", time.Now(), src.ID); err != nil {
+		log.Printf("inbound %s: record delivery: %v", src.Name, err)
+	}
+
+	agent, err := scanAgent(db.QueryRowContext(r.Context(), "SELECT "+agentColumns+" FROM agents WHERE id = ?", src.AgentID))
+	if err != nil {
+		writeStoreError(w, err, "failed to receive event")
+		return
+	}
+	if agent.Revoked {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "the agent this source posts as is revoked"})
+		return
+	}
+
+	ev, err := parseInbound(src.Kind, r, body)
+	if err != nil {
+		writeStoreError(w, err, "failed to receive event")
+		return
+	}
+	if ev == nil {
+		writeJSON(w, http.StatusOK, InboundResult{Action: "ignored"})
+		return
+	}
+	result, err := deliverInbound(r.Context(), db, bus, src, &agent, ev)
+	if err != nil {
+		writeStoreError(w, err, "failed to receive event")
+		return
+	}
+	status := http.StatusCreated
+	if result.Action == "ignored" {
+		status = http.StatusOK
+	}
+	writeJSON(w, status, result)
+}
+
+// handleAdminInbound lists the inbound sources.
+func handleAdminInbound(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	renderAdminInbound(db, w, r, nil)
+}
+
+// renderAdminInbound renders the inbound sources page, showing the secret
+// of created once if it isn't nil.
+func renderAdminInbound(db *sql.DB, w http.ResponseWriter, r *http.Request, created *InboundSource) {
+	rows, err := db.QueryContext(r.Context(),
+		"SELECT "+inboundSourceColumns+" FROM inbound_sources s JOIN agents a ON s.agent_id = a.id ORDER BY s.name")
+	if err != nil {
+		log.Printf("admin inbound query error: %v", err)
+		http.Error(w, "failed to load inbound sources", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	var sources []InboundSource
+	for rows.Next() {
+		src, err := scanInboundSource(rows)
+		if err != nil {
+			log.Printf("admin inbound scan error: %v", err)
+			http.Error(w, "failed to load inbound sources", http.StatusInternalServerError)
+			return
+		}
+		sources = append(sources, src)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("admin inbound iterate error: %v", err)
+		http.Error(w, "failed to load inbound sources", http.StatusInternalServerError)
+		return
+	}
+
+	agents, err := listAgents(r.Context(), db, "", "")
+	if err != nil {
+		log.Printf("admin inbound agents query error: %v", err)
+		http.Error(w, "failed to load agents", http.StatusInternalServerError)
+		return
+	}
+	var posters []Agent
+	for _, a := range agents {
+		if a.Kind != agentKindHuman && !a.Revoked {
+			posters = append(posters, a)
+		}
+	}
+
+	renderAdminTemplate(w, r, "inbound.html", map[string]interface{}{
+		"Sources": sources,
+		"Agents":  posters,
+		"Created": created,
+	})
+}
+
+// handleAdminCreateInbound creates an inbound source and shows its secret
+// once.
+func handleAdminCreateInbound(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	src, err := createInboundSource(r.Context(), db, r.FormValue("name"), r.FormValue("kind"), r.FormValue("agent_id"))
+	if _, ok := err.(inputError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("admin create inbound source: %v", err)
+		http.Error(w, "failed to create inbound source", http.StatusInternalServerError)
+		return
+	}
+
+	noStore(w)
+	renderAdminInbound(db, w, r, &src)
+}
+
+// handleAdminDeleteInbound deletes an inbound source. Threads it created
+// stay.
+func handleAdminDeleteInbound(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	sourceID := r.PathValue("id")
+	if sourceID == "" {
+		http.Error(w, "missing inbound source id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM inbound_threads WHERE source_id = ?", sourceID); err != nil {
+		log.Printf("admin delete inbound threads error: %v", err)
+	}
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM inbound_sources WHERE id = ?", sourceID); err != nil {
+		log.Printf("admin delete inbound source error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/inbound", http.StatusSeeOther)
+}
@@ -0,0 +1,101 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Replicas sharing a database take turns running the scheduled background
+// jobs, so that threads aren't published, escalated, or archived twice and
+// emails aren't sent twice. Whichever replica holds the jobs lease in the
+// leases table runs them, renewing it every leaseRenewInterval. If it stops
+// renewing, because it shut down, crashed, or lost the database, another
+// replica takes the lease over once it expires and starts the jobs there.
+
+const leasesSchema = `
+CREATE TABLE IF NOT EXISTS leases (
+	name TEXT PRIMARY KEY,
+	holder TEXT NOT NULL,
+	expires_at DATETIME NOT NULL
+);
+`
+
+// jobsLease is the lease whose holder runs the scheduled background jobs.
+const jobsLease = "background-jobs"
+
+// leaseTTL is how long a lease lasts unless renewed, and so how long the
+// jobs wait for another replica after their holder stops.
+const leaseTTL = 30 * time.Second
+
+// leaseRenewInterval is how often a replica renews its lease, or tries to
+// take one over. It is well under leaseTTL, so that a renewal or two can be
+// late without the lease changing hands.
+const leaseRenewInterval = 10 * time.Second
+
+// acquireLease takes the lease called name for holder until ttl after now,
+// if nobody else holds it as of now, or renews it if holder does. It
+// reports whether holder has the lease.
+func acquireLease(ctx context.Context, db *sql.DB, name, holder string, now time.Time, ttl time.Duration) (bool, error) {
+	// In UTC, so that replicas in different time zones compare alike
+	now = now.UTC()
+	res, err := db.ExecContext(ctx,
+		`INSERT INTO leases (name, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE leases.holder = excluded.holder OR leases.expires_at <= ?`,
+		name, holder, now.Add(ttl), now,
+	)
+	if err != nil {
+		return false, fmt.Errorf("acquire lease %s: %w", name, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("acquire lease %s: %w", name, err)
+	}
+	return n > 0, nil
+}
+
+// releaseLease gives up the lease called name, if holder has it, so that
+// another replica can take it over without waiting for it to expire.
+func releaseLease(ctx context.Context, db *sql.DB, name, holder string) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM leases WHERE name = ? AND holder = ?", name, holder)
+	return err
+}
+
+// runWhileLeader calls start with a context that lasts as long as holder
+// holds the lease called name, trying for it every leaseRenewInterval
+// until ctx is done. start is called again each time holder takes the
+// lease back after losing it. A holder that fails to renew stops at once,
+// rather than risk running alongside the next one.
+func runWhileLeader(ctx context.Context, db *sql.DB, name, holder string, start func(context.Context)) {
+	var stop context.CancelFunc
+	ticker := time.NewTicker(leaseRenewInterval)
+	defer ticker.Stop()
+	for {
+		held, err := acquireLease(ctx, db, name, holder, time.Now(), leaseTTL)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("leases: %v", err)
+		}
+		switch {
+		case held && stop == nil:
+			log.Printf("leases: holding %s", name)
+			var leaderCtx context.Context
+			leaderCtx, stop = context.WithCancel(ctx)
+			start(leaderCtx)
+		case !held && stop != nil:
+			log.Printf("leases: lost %s", name)
+			stop()
+			stop = nil
+		}
+		select {
+		case <-ctx.Done():
+			if stop != nil {
+				stop()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openReplicaDBs opens the same database file twice, as two replicas
+// would.
+func openReplicaDBs(t *testing.T) (a, b *sql.DB) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "forum.db")
+	for _, db := range []**sql.DB{&a, &b} {
+		var err error
+		if *db, err = InitDB(path, LoadConfig().SQLite); err != nil {
+			t.Fatalf("InitDB: %v", err)
+		}
+		t.Cleanup(func() { (*db).Close() })
+	}
+	return a, b
+}
+
+func TestLeaseChangesHandsOnExpiry(t *testing.T) {
+	a, b := openReplicaDBs(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	acquire := func(db *sql.DB, holder string, at time.Time) bool {
+		t.Helper()
+		held, err := acquireLease(ctx, db, jobsLease, holder, at, leaseTTL)
+		if err != nil {
+			t.Fatalf("acquireLease(%s): %v", holder, err)
+		}
+		return held
+	}
+
+	if !acquire(a, "a", now) {
+		t.Fatal("a: didn't get the free lease")
+	}
+	if acquire(b, "b", now.Add(time.Second)) {
+		t.Fatal("b: got the lease a holds")
+	}
+	if !acquire(a, "a", now.Add(leaseRenewInterval)) {
+		t.Fatal("a: couldn't renew")
+	}
+	// Renewed at leaseRenewInterval, so held until then plus leaseTTL
+	if acquire(b, "b", now.Add(leaseTTL)) {
+		t.Fatal("b: got the lease before a's renewal expired")
+	}
+	if !acquire(b, "b", now.Add(leaseRenewInterval+leaseTTL)) {
+		t.Fatal("b: didn't take over the expired lease")
+	}
+	if acquire(a, "a", now.Add(leaseRenewInterval+leaseTTL+time.Second)) {
+		t.Fatal("a: got the lease back while b holds it")
+	}
+
+	if err := releaseLease(ctx, a, jobsLease, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if acquire(a, "a", now.Add(leaseRenewInterval+leaseTTL+2*time.Second)) {
+		t.Fatal("a: releasing a lease it doesn't hold freed it")
+	}
+	if err := releaseLease(ctx, b, jobsLease, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if !acquire(a, "a", now.Add(leaseRenewInterval+leaseTTL+3*time.Second)) {
+		t.Fatal("a: didn't get the lease b released")
+	}
+}
+
+func TestRunWhileLeaderRunsOnOneReplica(t *testing.T) {
+	a, b := openReplicaDBs(t)
+
+	started := make(chan string, 2)
+	stopped := make(chan string, 2)
+	run := func(db *sql.DB, holder string) context.CancelFunc {
+		ctx, cancel := context.WithCancel(context.Background())
+		go runWhileLeader(ctx, db, jobsLease, holder, func(jobs context.Context) {
+			started <- holder
+			context.AfterFunc(jobs, func() { stopped <- holder })
+		})
+		return cancel
+	}
+
+	stopA := run(a, "a")
+	if got := <-started; got != "a" {
+		t.Fatalf("started on %s, want a", got)
+	}
+	stopB := run(b, "b")
+	defer stopB()
+
+	stopA()
+	if got := <-stopped; got != "a" {
+		t.Fatalf("stopped on %s, want a", got)
+	}
+	select {
+	case got := <-started:
+		t.Fatalf("started on %s while a's lease was unexpired", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
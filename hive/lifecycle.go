@@ -0,0 +1,153 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// A thread's status tags drive a state machine: open → in-progress →
+// needs-review → resolved. Its current status is its latest lifecycle tag
+// that has not been superseded, or open if there is none. blocked is an
+// overlay that can apply in any state until the thread is resolved.
+const (
+	statusOpen        = "open"
+	statusInProgress  = "in-progress"
+	statusNeedsReview = "needs-review"
+	statusResolved    = "resolved"
+	statusBlocked     = "blocked"
+)
+
+// lifecycleTagList is the lifecycle tags as an SQL list.
+const lifecycleTagList = `('in-progress', 'needs-review', 'resolved')`
+
+var lifecycleTags = map[string]bool{
+	statusInProgress:  true,
+	statusNeedsReview: true,
+	statusResolved:    true,
+}
+
+// lifecycleTransitions lists the states each state may move to. Reopening
+// a resolved thread moves it back to in-progress.
+var lifecycleTransitions = map[string]map[string]bool{
+	statusOpen:        {statusInProgress: true, statusNeedsReview: true, statusResolved: true},
+	statusInProgress:  {statusNeedsReview: true, statusResolved: true},
+	statusNeedsReview: {statusInProgress: true, statusResolved: true},
+	statusResolved:    {statusInProgress: true},
+}
+
+// currentStatusColumn computes a thread's current status for threadColumns.
+const currentStatusColumn = `COALESCE((SELECT s.tag FROM status_tags s
+			WHERE s.thread_id = t.id AND s.superseded_by IS NULL AND s.tag IN ` + lifecycleTagList + `
+			ORDER BY s.created_at DESC LIMIT 1), 'open') AS current_status`
+
+// blockedColumn reports whether a thread has an active blocked tag, for
+// threadColumns.
+const blockedColumn = `EXISTS (SELECT 1 FROM status_tags s
+			WHERE s.thread_id = t.id AND s.superseded_by IS NULL AND s.tag = 'blocked') AS blocked`
+
+// currentStatus returns a thread's current lifecycle status.
+func currentStatus(ctx context.Context, db dbtx, threadID string) (string, error) {
+	var status string
+	err := db.QueryRowContext(ctx,
+		`SELECT tag FROM status_tags
+		WHERE thread_id = ? AND superseded_by IS NULL AND tag IN `+lifecycleTagList+`
+		ORDER BY created_at DESC LIMIT 1`, threadID,
+	).Scan(&status)
+	if err == sql.ErrNoRows {
+		return statusOpen, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("query current status: %w", err)
+	}
+	return status, nil
+}
+
+// checkTransition rejects a thread status tag that the thread's current
+// status can't move to.
+func checkTransition(ctx context.Context, db dbtx, threadID, tag string) error {
+	if !lifecycleTags[tag] && tag != statusBlocked {
+		return nil
+	}
+	current, err := currentStatus(ctx, db, threadID)
+	if err != nil {
+		return err
+	}
+	if tag == statusBlocked {
+		if current == statusResolved {
+			return conflictError("thread is resolved; reopen it with in-progress first")
+		}
+		return nil
+	}
+	if !lifecycleTransitions[current][tag] {
+		return conflictError(fmt.Sprintf("thread is %s and can't move to %s", current, tag))
+	}
+	return nil
+}
+
+// supersedeStatuses marks the thread status tags that a new one replaces: a
+// lifecycle tag replaces the previous one, and resolved also clears blocked.
+func supersedeStatuses(ctx context.Context, db dbtx, threadID string, st StatusTag) error {
+	if !lifecycleTags[st.Tag] {
+		return nil
+	}
+	tags := lifecycleTagList
+	if st.Tag == statusResolved {
+		tags = `('in-progress', 'needs-review', 'resolved', 'blocked')`
+	}
+	_, err := db.ExecContext(ctx,
+		`UPDATE status_tags SET superseded_by = ?
+		WHERE thread_id = ? AND id != ? AND superseded_by IS NULL AND tag IN `+tags,
+		st.ID, threadID, st.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("supersede status tags: %w", err)
+	}
+	return nil
+}
+
+// restoreSuperseded hands the tags a status tag superseded on to whatever
+// superseded it, before it is deleted. Deleting the current status restores
+// the one before it.
+func restoreSuperseded(ctx context.Context, db execer, statusID string) error {
+	_, err := db.ExecContext(ctx,
+		`UPDATE status_tags SET superseded_by = (SELECT superseded_by FROM status_tags WHERE id = ?)
+		WHERE superseded_by = ?`, statusID, statusID,
+	)
+	if err != nil {
+		return fmt.Errorf("restore superseded status tags: %w", err)
+	}
+	return nil
+}
+
+// execer is implemented by *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// backfillSuperseded supersedes thread status tags recorded without the
+// state machine, such as before it existed or by an import: each lifecycle
+// tag by the next one on its thread, and blocked by a later resolved. It
+// changes nothing on tags the state machine maintains.
+func backfillSuperseded(ctx context.Context, db execer) error {
+	next := func(tags string) string {
+		return `(SELECT n.id FROM status_tags n
+			WHERE n.thread_id = status_tags.thread_id AND n.tag IN ` + tags + `
+			AND n.created_at > status_tags.created_at
+			ORDER BY n.created_at LIMIT 1)`
+	}
+	for _, b := range []struct{ tags, by string }{
+		{lifecycleTagList, next(lifecycleTagList)},
+		{`('blocked')`, next(`('resolved')`)},
+	} {
+		_, err := db.ExecContext(ctx,
+			`UPDATE status_tags SET superseded_by = `+b.by+`
+			WHERE thread_id IS NOT NULL AND superseded_by IS NULL AND tag IN `+b.tags+`
+			AND `+b.by+` IS NOT NULL`,
+		)
+		if err != nil {
+			return fmt.Errorf("backfill superseded status tags: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Failed admin and dashboard user logins are counted in login_failures,
+// per account and per client address, for the admin password, the admin
+// second factor, and the user password separately. Too many failures
+// within loginFailureWindow lock that account, or that address, out of
+// that step until the earliest of them ages out, so neither a password
+// nor a six-digit code can be guessed online. A successful login clears
+// its account's failures; an address's age out on their own.
+
+const loginFailuresSchema = `
+CREATE TABLE IF NOT EXISTS login_failures (
+	step TEXT NOT NULL,
+	key TEXT NOT NULL,
+	failed_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_login_failures ON login_failures(step, key, failed_at);
+`
+
+// Steps of an admin login, and the one step of a user login, whose
+// failures are counted separately.
+const (
+	loginStepPassword     = "password"
+	loginStepCode         = "code"
+	loginStepUserPassword = "user-password"
+)
+
+// loginFailureWindow is how long a failed login counts against an account
+// and an address.
+const loginFailureWindow = 15 * time.Minute
+
+// maxAccountLoginFailures is how many failures of a step lock an account
+// out of it, from anywhere. maxAddrLoginFailures is how many lock an
+// address out of it, whichever accounts they were for; it is higher, so
+// that one person's typos don't lock out others behind the same proxy.
+const (
+	maxAccountLoginFailures = 5
+	maxAddrLoginFailures    = 20
+)
+
+// loginThrottleKeys returns the keys failures of a step by, or for,
+// account (a username for the password steps, an admin ID for the second
+// factor) from addr are counted under, with how many each may have.
+func loginThrottleKeys(account, addr string) map[string]int {
+	return map[string]int{
+		"account:" + account: maxAccountLoginFailures,
+		"addr:" + addr:       maxAddrLoginFailures,
+	}
+}
+
+// loginLockout returns how long account, or addr, is locked out of step
+// for as of now, or zero if neither is.
+func loginLockout(ctx context.Context, db *sql.DB, step, account, addr string, now time.Time) (time.Duration, error) {
+	var wait time.Duration
+	for key, limit := range loginThrottleKeys(account, addr) {
+		// The limit-th most recent failure in the window: until it ages out,
+		// there are too many
+		var failedAt time.Time
+		err := db.QueryRowContext(ctx,
+			`SELECT failed_at FROM login_failures WHERE step = ? AND key = ? AND failed_at > ?
+			ORDER BY failed_at DESC LIMIT 1 OFFSET ?`,
+			step, key, now.Add(-loginFailureWindow), limit-1,
+		).Scan(&failedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("query login failures: %w", err)
+		}
+		wait = max(wait, failedAt.Add(loginFailureWindow).Sub(now))
+	}
+	return wait, nil
+}
+
+// recordLoginFailure counts a failure of step against account and addr,
+// and clears out failures that no longer count.
+func recordLoginFailure(ctx context.Context, db *sql.DB, step, account, addr string, now time.Time) error {
+	if _, err := db.ExecContext(ctx, "DELETE FROM login_failures WHERE failed_at <= ?", now.Add(-loginFailureWindow)); err != nil {
+		return fmt.Errorf("delete old login failures: %w", err)
+	}
+	for key := range loginThrottleKeys(account, addr) {
+		if _, err := db.ExecContext(ctx,
+			"INSERT INTO login_failures (step, key, failed_at) VALUES (?, ?, ?)", step, key, now,
+		); err != nil {
+			return fmt.Errorf("insert login failure: %w", err)
+		}
+	}
+	return nil
+}
+
+// clearLoginFailures forgets the failures counted against an admin, known
+// by username and ID, once they have logged in.
+func clearLoginFailures(ctx context.Context, db *sql.DB, admin *Admin) error {
+	_, err := db.ExecContext(ctx,
+		"DELETE FROM login_failures WHERE (step = ? AND key = ?) OR (step = ? AND key = ?)",
+		loginStepPassword, "account:"+admin.Username, loginStepCode, "account:"+admin.ID,
+	)
+	return err
+}
+
+// clearUserLoginFailures forgets the failures counted against a dashboard
+// user once they have logged in.
+func clearUserLoginFailures(ctx context.Context, db *sql.DB, user *User) error {
+	_, err := db.ExecContext(ctx,
+		"DELETE FROM login_failures WHERE step = ? AND key = ?", loginStepUserPassword, "account:"+user.Username,
+	)
+	return err
+}
+
+// lockoutMessage tells someone locked out of logging in how long for.
+func lockoutMessage(wait time.Duration) string {
+	return "Too many failed attempts. Try again in " + formatDuration(wait.Truncate(time.Minute)+time.Minute) + "."
+}
@@ -0,0 +1,575 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Maintenance runs SQLite upkeep tasks: checkpointing the WAL, refreshing
+// the query planner's statistics, checking integrity, and rebuilding the
+// database file. Admins run them from the admin panel or with the admin
+// scope from /api/v1/maintenance, and they can run on their own in a daily
+// window. One task runs at a time, in the background; each run is recorded
+// with its outcome.
+
+const maintenanceSchema = `
+CREATE TABLE IF NOT EXISTS maintenance_runs (
+	id TEXT PRIMARY KEY,
+	task TEXT NOT NULL,
+	trigger TEXT NOT NULL,
+	requested_by TEXT NOT NULL DEFAULT '',
+	status TEXT NOT NULL,
+	result TEXT NOT NULL DEFAULT '',
+	error TEXT NOT NULL DEFAULT '',
+	started_at DATETIME NOT NULL,
+	finished_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_maintenance_runs_started ON maintenance_runs(started_at);
+`
+
+// Statuses of a maintenance run.
+const (
+	maintenanceRunning   = "running"
+	maintenanceSucceeded = "succeeded"
+	maintenanceFailed    = "failed"
+)
+
+// What started a maintenance run.
+const (
+	maintenanceManual    = "manual"
+	maintenanceScheduled = "scheduled"
+)
+
+// maintenanceHistory is how many recent runs are listed.
+const maintenanceHistory = 20
+
+// MaintenanceRun is one run of a maintenance task.
+type MaintenanceRun struct {
+	ID      string `json:"id"`
+	Task    string `json:"task"`
+	Trigger string `json:"trigger"`
+	// RequestedBy is the admin or agent that started a manual run.
+	RequestedBy string `json:"requested_by,omitempty"`
+	Status      string `json:"status"`
+	// Result describes what a finished run did, and Error why it failed.
+	Result     string     `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// Duration is how long the run took, or has taken so far.
+func (run MaintenanceRun) Duration() time.Duration {
+	end := time.Now()
+	if run.FinishedAt != nil {
+		end = *run.FinishedAt
+	}
+	return end.Sub(run.StartedAt).Round(time.Millisecond)
+}
+
+// maintenanceTask is an upkeep task. run returns a description of what it
+// did.
+type maintenanceTask struct {
+	Name        string
+	Description string
+	run         func(ctx context.Context, db *sql.DB) (string, error)
+}
+
+var maintenanceTasks = []maintenanceTask{
+	{
+		Name:        "checkpoint",
+		Description: "Copy the write-ahead log into the database file and truncate it",
+		run:         checkpointWAL,
+	},
+	{
+		Name:        "analyze",
+		Description: "Refresh the statistics the query planner uses to pick indexes",
+		run:         analyzeDB,
+	},
+	{
+		Name:        "integrity-check",
+		Description: "Check every table and index for corruption",
+		run:         checkIntegrity,
+	},
+	{
+		Name:        "vacuum",
+		Description: "Rebuild the database file to reclaim free pages; blocks writes while it runs",
+		run:         vacuumDB,
+	},
+}
+
+func findMaintenanceTask(name string) (maintenanceTask, bool) {
+	for _, t := range maintenanceTasks {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return maintenanceTask{}, false
+}
+
+func checkpointWAL(ctx context.Context, db *sql.DB) (string, error) {
+	var busy, logPages, checkpointed int
+	if err := db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)").Scan(&busy, &logPages, &checkpointed); err != nil {
+		return "", err
+	}
+	if busy != 0 {
+		return "", fmt.Errorf("checkpoint blocked by readers or writers; %d of %d WAL pages copied", checkpointed, logPages)
+	}
+	return fmt.Sprintf("copied %d WAL pages", checkpointed), nil
+}
+
+func analyzeDB(ctx context.Context, db *sql.DB) (string, error) {
+	if _, err := db.ExecContext(ctx, "ANALYZE"); err != nil {
+		return "", err
+	}
+	return "statistics refreshed", nil
+}
+
+func checkIntegrity(ctx context.Context, db *sql.DB) (string, error) {
+	rows, err := db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return "", err
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	if len(problems) > 0 {
+		if len(problems) > 10 {
+			problems = append(problems[:10], fmt.Sprintf("and %d more", len(problems)-10))
+		}
+		return "", fmt.Errorf("database is corrupt: %s", strings.Join(problems, "; "))
+	}
+	return "ok", nil
+}
+
+func vacuumDB(ctx context.Context, db *sql.DB) (string, error) {
+	size := func() (int64, error) {
+		var pages, pageSize int64
+		if err := db.QueryRowContext(ctx, "SELECT page_count, page_size FROM pragma_page_count(), pragma_page_size()").Scan(&pages, &pageSize); err != nil {
+			return 0, err
+		}
+		return pages * pageSize, nil
+	}
+	before, err := size()
+	if err != nil {
+		return "", err
+	}
+	if _, err := db.ExecContext(ctx, "VACUUM"); err != nil {
+		return "", err
+	}
+	after, err := size()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("database file %d bytes, was %d (%d reclaimed)", after, before, before-after), nil
+}
+
+// maintenanceWindow is a daily UTC time range, which may wrap past
+// midnight. Start and End are offsets from midnight.
+type maintenanceWindow struct {
+	Start, End time.Duration
+}
+
+// parseMaintenanceWindow parses "HH:MM-HH:MM".
+func parseMaintenanceWindow(s string) (maintenanceWindow, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return maintenanceWindow{}, fmt.Errorf("maintenance window %q must look like 03:00-04:00", s)
+	}
+	var w maintenanceWindow
+	for _, part := range []struct {
+		text string
+		into *time.Duration
+	}{{from, &w.Start}, {to, &w.End}} {
+		t, err := time.Parse("15:04", strings.TrimSpace(part.text))
+		if err != nil {
+			return maintenanceWindow{}, fmt.Errorf("maintenance window %q must look like 03:00-04:00", s)
+		}
+		*part.into = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	if w.Start == w.End {
+		return maintenanceWindow{}, fmt.Errorf("maintenance window %q is empty", s)
+	}
+	return w, nil
+}
+
+// opening returns when the window containing now opened, and false if now
+// is outside the window.
+func (w maintenanceWindow) opening(now time.Time) (time.Time, bool) {
+	now = now.UTC()
+	start := now.Truncate(24 * time.Hour).Add(w.Start)
+	if start.After(now) {
+		start = start.AddDate(0, 0, -1)
+	}
+	length := w.End - w.Start
+	if length < 0 {
+		length += 24 * time.Hour
+	}
+	return start, now.Before(start.Add(length))
+}
+
+func (w maintenanceWindow) String() string {
+	format := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return format(w.Start) + "–" + format(w.End) + " UTC"
+}
+
+// Maintenance runs maintenance tasks one at a time and schedules them in
+// the configured window.
+type Maintenance struct {
+	db *sql.DB
+	// window is when scheduled runs happen, nil if they don't; tasks are
+	// what they run, in order.
+	window *maintenanceWindow
+	tasks  []maintenanceTask
+
+	mu sync.Mutex
+	// current is the run in progress, nil if there is none; step and steps
+	// count the tasks of a scheduled window.
+	current     *MaintenanceRun
+	step, steps int
+}
+
+// NewMaintenance sets up maintenance from cfg, rejecting an invalid window
+// or unknown tasks, and fails runs a restart interrupted.
+func NewMaintenance(db *sql.DB, cfg Config) (*Maintenance, error) {
+	m := &Maintenance{db: db}
+	if cfg.MaintenanceWindow != "" {
+		w, err := parseMaintenanceWindow(cfg.MaintenanceWindow)
+		if err != nil {
+			return nil, err
+		}
+		m.window = &w
+	}
+	for _, name := range strings.Split(cfg.MaintenanceTasks, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		t, ok := findMaintenanceTask(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown maintenance task %q", name)
+		}
+		m.tasks = append(m.tasks, t)
+	}
+
+	_, err := db.Exec("UPDATE maintenance_runs SET status = ?, error = 'interrupted by a restart', finished_at = ? WHERE status = ?",
+		maintenanceFailed, time.Now().UTC(), maintenanceRunning)
+	if err != nil {
+		return nil, fmt.Errorf("fail interrupted maintenance runs: %w", err)
+	}
+	return m, nil
+}
+
+// Begin starts the named task in the background and returns its run. It
+// fails with a conflict if a task is already running.
+func (m *Maintenance) Begin(name, requestedBy string) (MaintenanceRun, error) {
+	task, ok := findMaintenanceTask(name)
+	if !ok {
+		return MaintenanceRun{}, notFoundError(fmt.Sprintf("unknown maintenance task %q (use checkpoint, analyze, integrity-check, or vacuum)", name))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.current != nil {
+		return MaintenanceRun{}, conflictError(fmt.Sprintf("%s is already running", m.current.Task))
+	}
+	run, err := m.start(task, maintenanceManual, requestedBy)
+	if err != nil {
+		return MaintenanceRun{}, err
+	}
+	m.step, m.steps = 1, 1
+	go m.finish(task, run)
+	return *run, nil
+}
+
+// start records a new run of task as current. m.mu must be held.
+func (m *Maintenance) start(task maintenanceTask, trigger, requestedBy string) (*MaintenanceRun, error) {
+	run := &MaintenanceRun{
+		ID:          uuid.New().String(),
+		Task:        task.Name,
+		Trigger:     trigger,
+		RequestedBy: requestedBy,
+		Status:      maintenanceRunning,
+		StartedAt:   time.Now().UTC(),
+	}
+	_, err := m.db.Exec(
+		"INSERT INTO maintenance_runs (id, task, trigger, requested_by, status, started_at) VALUES (?, ?, ?, ?, ?, ?)",
+		run.ID, run.Task, run.Trigger, run.RequestedBy, run.Status, run.StartedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("record maintenance run: %w", err)
+	}
+	m.current = run
+	return run, nil
+}
+
+// finish runs task and records the outcome of its run.
+func (m *Maintenance) finish(task maintenanceTask, run *MaintenanceRun) {
+	result, err := task.run(context.Background(), m.db)
+	finished := time.Now().UTC()
+
+	m.mu.Lock()
+	run.Status, run.Result, run.FinishedAt = maintenanceSucceeded, result, &finished
+	if err != nil {
+		run.Status, run.Error = maintenanceFailed, err.Error()
+	}
+	m.current = nil
+	m.mu.Unlock()
+
+	_, dbErr := m.db.Exec("UPDATE maintenance_runs SET status = ?, result = ?, error = ?, finished_at = ? WHERE id = ?",
+		run.Status, run.Result, run.Error, finished, run.ID)
+	if dbErr != nil {
+		log.Printf("maintenance: record %s run: %v", run.Task, dbErr)
+	}
+	if err != nil {
+		log.Printf("maintenance: %s failed after %s: %v", run.Task, run.Duration(), err)
+	} else {
+		log.Printf("maintenance: %s finished in %s: %s", run.Task, run.Duration(), result)
+	}
+}
+
+// runWindow runs the scheduled tasks in order, skipping the window if a
+// task is already running.
+func (m *Maintenance) runWindow() {
+	for i, task := range m.tasks {
+		m.mu.Lock()
+		if m.current != nil {
+			log.Printf("maintenance: %s is running; skipping the rest of this window", m.current.Task)
+			m.mu.Unlock()
+			return
+		}
+		run, err := m.start(task, maintenanceScheduled, "")
+		if err != nil {
+			m.mu.Unlock()
+			log.Printf("maintenance: %v", err)
+			return
+		}
+		m.step, m.steps = i+1, len(m.tasks)
+		m.mu.Unlock()
+		m.finish(task, run)
+	}
+}
+
+// ranSince reports whether a scheduled run started at or after t.
+func (m *Maintenance) ranSince(t time.Time) (bool, error) {
+	var ran bool
+	err := m.db.QueryRow("SELECT EXISTS(SELECT 1 FROM maintenance_runs WHERE trigger = ? AND started_at >= ?)",
+		maintenanceScheduled, t).Scan(&ran)
+	return ran, err
+}
+
+// Start checks every minute until ctx is done whether the window has
+// opened, and runs the scheduled tasks once per window. It does nothing
+// without a window or tasks.
+func (m *Maintenance) Start(ctx context.Context) {
+	if m.window == nil || len(m.tasks) == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			if opened, ok := m.window.opening(time.Now()); ok {
+				ran, err := m.ranSince(opened)
+				if err != nil {
+					log.Printf("maintenance: check scheduled runs: %v", err)
+				} else if !ran {
+					m.runWindow()
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// MaintenanceStatus is the running task, if any, the recent runs, and the
+// schedule.
+type MaintenanceStatus struct {
+	// Running is the run in progress, with Step and Steps counting through
+	// the tasks of a scheduled window.
+	Running *MaintenanceRun `json:"running"`
+	Step    int             `json:"step,omitempty"`
+	Steps   int             `json:"steps,omitempty"`
+	// Window is when scheduled runs happen, empty if they don't, and
+	// ScheduledTasks what they run.
+	Window         string           `json:"window,omitempty"`
+	ScheduledTasks []string         `json:"scheduled_tasks"`
+	Runs           []MaintenanceRun `json:"runs"`
+}
+
+// Status returns the current state and the most recent runs, newest first.
+func (m *Maintenance) Status(ctx context.Context) (MaintenanceStatus, error) {
+	st := MaintenanceStatus{ScheduledTasks: []string{}, Runs: []MaintenanceRun{}}
+	if m.window != nil {
+		st.Window = m.window.String()
+	}
+	for _, t := range m.tasks {
+		st.ScheduledTasks = append(st.ScheduledTasks, t.Name)
+	}
+	m.mu.Lock()
+	if m.current != nil {
+		running := *m.current
+		st.Running, st.Step, st.Steps = &running, m.step, m.steps
+	}
+	m.mu.Unlock()
+
+	runs, err := m.runs(ctx, "", maintenanceHistory)
+	if err != nil {
+		return st, err
+	}
+	st.Runs = runs
+	return st, nil
+}
+
+// runs lists runs newest first, or just the one with the given ID.
+func (m *Maintenance) runs(ctx context.Context, id string, limit int) ([]MaintenanceRun, error) {
+	rows, err := m.db.QueryContext(ctx,
+		`SELECT id, task, trigger, requested_by, status, result, error, started_at, finished_at
+		FROM maintenance_runs
+		WHERE ? = '' OR id = ?
+		ORDER BY started_at DESC LIMIT ?`, id, id, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query maintenance runs: %w", err)
+	}
+	defer rows.Close()
+	runs := []MaintenanceRun{}
+	for rows.Next() {
+		var run MaintenanceRun
+		if err := rows.Scan(&run.ID, &run.Task, &run.Trigger, &run.RequestedBy, &run.Status, &run.Result, &run.Error, &run.StartedAt, &run.FinishedAt); err != nil {
+			return nil, fmt.Errorf("scan maintenance run: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// handleMaintenanceStatus returns the running task, recent runs, and
+// schedule. Requires the admin scope.
+func handleMaintenanceStatus(m *Maintenance, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requireScope(w, agent, scopeAdmin) {
+		return
+	}
+
+	st, err := m.Status(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query maintenance runs"})
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+// handleMaintenanceRun returns one run, for polling a task started with
+// handleStartMaintenance. Requires the admin scope.
+func handleMaintenanceRun(m *Maintenance, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requireScope(w, agent, scopeAdmin) {
+		return
+	}
+
+	runs, err := m.runs(r.Context(), r.PathValue("id"), 1)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query maintenance run"})
+		return
+	}
+	if len(runs) == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "maintenance run not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, runs[0])
+}
+
+// handleStartMaintenance starts a task in the background and returns its
+// run with 202. Requires the admin scope.
+func handleStartMaintenance(m *Maintenance, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requireScope(w, agent, scopeAdmin) {
+		return
+	}
+
+	run, err := m.Begin(r.PathValue("task"), agent.Name)
+	if err != nil {
+		writeStoreError(w, err, "failed to start maintenance")
+		return
+	}
+	w.Header().Set("Location", "/api/v1/maintenance/runs/"+run.ID)
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+// handleAdminMaintenance shows the tasks, the running one, and recent runs.
+func handleAdminMaintenance(m *Maintenance, w http.ResponseWriter, r *http.Request) {
+	st, err := m.Status(r.Context())
+	if err != nil {
+		log.Printf("admin maintenance query error: %v", err)
+		http.Error(w, "failed to load maintenance runs", http.StatusInternalServerError)
+		return
+	}
+	if st.Running != nil {
+		// Reload until the task finishes, to show its progress
+		w.Header().Set("Refresh", "5")
+	}
+	renderAdminTemplate(w, r, "maintenance.html", map[string]interface{}{
+		"Tasks":  maintenanceTasks,
+		"Status": st,
+	})
+}
+
+// handleAdminStartMaintenance starts a task and returns to the maintenance
+// page, which shows its progress.
+func handleAdminStartMaintenance(m *Maintenance, w http.ResponseWriter, r *http.Request) {
+	var requestedBy string
+	if admin := AdminFromContext(r.Context()); admin != nil {
+		requestedBy = admin.Username
+	}
+	_, err := m.Begin(r.PathValue("task"), requestedBy)
+	switch err.(type) {
+	case nil:
+	case notFoundError:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case conflictError:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		log.Printf("admin maintenance: %v", err)
+		http.Error(w, "failed to start maintenance", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/maintenance", http.StatusSeeOther)
+}
@@ -0,0 +1,166 @@
+package hive
+
+import (
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// markdown renders thread, reply, and announcement bodies on the dashboard,
+// the admin panel, and in feeds: CommonMark with GitHub's tables,
+// strikethrough, task lists, and emoji shortcodes. Raw HTML in bodies is
+// still escaped.
+var markdown = goldmark.New(goldmark.WithExtensions(
+	extension.Table,
+	extension.Strikethrough,
+	extension.TaskList,
+	emojiExtension{},
+))
+
+// TaskCount counts the task list items in a body, as in "- [x] done".
+type TaskCount struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// countTasks counts the task list items in a markdown body and how many
+// are checked. Items in code blocks don't count.
+func countTasks(md string) TaskCount {
+	var c TaskCount
+	if !strings.Contains(md, "[") {
+		return c
+	}
+	doc := markdown.Parser().Parse(text.NewReader([]byte(md)))
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if box, ok := n.(*extast.TaskCheckBox); ok && entering {
+			c.Total++
+			if box.IsChecked {
+				c.Done++
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return c
+}
+
+// emojiShortcodes are the :shortcodes: rendered as emoji, a common subset of
+// GitHub's. Unknown shortcodes are left as typed.
+var emojiShortcodes = map[string]string{
+	"+1":                         "👍",
+	"-1":                         "👎",
+	"thumbsup":                   "👍",
+	"thumbsdown":                 "👎",
+	"white_check_mark":           "✅",
+	"heavy_check_mark":           "✔️",
+	"x":                          "❌",
+	"warning":                    "⚠️",
+	"no_entry":                   "⛔",
+	"construction":               "🚧",
+	"rotating_light":             "🚨",
+	"fire":                       "🔥",
+	"bug":                        "🐛",
+	"rocket":                     "🚀",
+	"tada":                       "🎉",
+	"sparkles":                   "✨",
+	"zap":                        "⚡",
+	"boom":                       "💥",
+	"lock":                       "🔒",
+	"unlock":                     "🔓",
+	"key":                        "🔑",
+	"wrench":                     "🔧",
+	"hammer":                     "🔨",
+	"gear":                       "⚙️",
+	"package":                    "📦",
+	"memo":                       "📝",
+	"pencil2":                    "✏️",
+	"book":                       "📖",
+	"bookmark":                   "🔖",
+	"link":                       "🔗",
+	"mag":                        "🔍",
+	"bulb":                       "💡",
+	"question":                   "❓",
+	"exclamation":                "❗",
+	"information_source":         "ℹ️",
+	"hourglass":                  "⌛",
+	"stopwatch":                  "⏱️",
+	"calendar":                   "📆",
+	"chart_with_upwards_trend":   "📈",
+	"chart_with_downwards_trend": "📉",
+	"bar_chart":                  "📊",
+	"recycle":                    "♻️",
+	"wastebasket":                "🗑️",
+	"arrow_right":                "➡️",
+	"arrow_left":                 "⬅️",
+	"arrow_up":                   "⬆️",
+	"arrow_down":                 "⬇️",
+	"eyes":                       "👀",
+	"wave":                       "👋",
+	"pray":                       "🙏",
+	"clap":                       "👏",
+	"muscle":                     "💪",
+	"ok_hand":                    "👌",
+	"raised_hands":               "🙌",
+	"thinking":                   "🤔",
+	"smile":                      "😄",
+	"smiley":                     "😃",
+	"grin":                       "😁",
+	"laughing":                   "😆",
+	"wink":                       "😉",
+	"sweat_smile":                "😅",
+	"confused":                   "😕",
+	"cry":                        "😢",
+	"scream":                     "😱",
+	"heart":                      "❤️",
+	"broken_heart":               "💔",
+	"star":                       "⭐",
+	"100":                        "💯",
+	"robot":                      "🤖",
+	"computer":                   "💻",
+	"hourglass_flowing_sand":     "⏳",
+	"red_circle":                 "🔴",
+	"green_circle":               "🟢",
+	"yellow_circle":              "🟡",
+	"white_circle":               "⚪",
+}
+
+// emojiExtension renders :shortcodes: as emoji.
+type emojiExtension struct{}
+
+func (emojiExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(emojiParser{}, 999)))
+}
+
+// emojiParser parses a :shortcode: from emojiShortcodes into its emoji.
+type emojiParser struct{}
+
+func (emojiParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (emojiParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	end := 1
+	for end < len(line) && isShortcodeByte(line[end]) {
+		end++
+	}
+	if end == 1 || end >= len(line) || line[end] != ':' {
+		return nil
+	}
+	emoji, ok := emojiShortcodes[string(line[1:end])]
+	if !ok {
+		return nil
+	}
+	block.Advance(end + 1)
+	return ast.NewString([]byte(emoji))
+}
+
+// isShortcodeByte reports whether b can appear in an emoji shortcode.
+func isShortcodeByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '_' || b == '+' || b == '-'
+}
@@ -0,0 +1,196 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// mentionPattern matches @agent-name tokens that are not part of an email
+// address or another word.
+var mentionPattern = regexp.MustCompile(`(^|[^\w@])@([A-Za-z0-9][A-Za-z0-9_.-]*[A-Za-z0-9_]|[A-Za-z0-9])`)
+
+// parseMentions returns the unique agent names mentioned in body, in order of
+// first appearance.
+func parseMentions(body string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		name := m[2]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// recordMentions replaces the stored mentions for a thread or reply with the
+// agents mentioned in body. Names that do not match an agent in the
+// thread's workspace are ignored. Exactly one of threadID and replyID should be set; for replies,
+// threadID is the parent thread.
+func recordMentions(ctx context.Context, db dbtx, threadID string, replyID *string, authorID, body string) error {
+	if replyID != nil {
+		if _, err := db.ExecContext(ctx, "DELETE FROM mentions WHERE reply_id = ?", *replyID); err != nil {
+			return fmt.Errorf("clear reply mentions: %w", err)
+		}
+	} else {
+		if _, err := db.ExecContext(ctx, "DELETE FROM mentions WHERE thread_id = ? AND reply_id IS NULL", threadID); err != nil {
+			return fmt.Errorf("clear thread mentions: %w", err)
+		}
+	}
+
+	now := time.Now()
+	for _, name := range parseMentions(body) {
+		var agentID string
+		err := db.QueryRowContext(ctx, "SELECT id FROM agents WHERE name = ? AND workspace_id = (SELECT workspace_id FROM threads WHERE id = ?)", name, threadID).Scan(&agentID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("look up mentioned agent: %w", err)
+		}
+		_, err = db.ExecContext(ctx,
+			`INSERT INTO mentions (id, agent_id, thread_id, reply_id, mentioned_by, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			uuid.New().String(), agentID, threadID, replyID, authorID, now,
+		)
+		if err != nil {
+			return fmt.Errorf("insert mention: %w", err)
+		}
+	}
+	return nil
+}
+
+// threadMentionLinks returns a map of agent name to agent ID for every agent
+// mentioned in a thread or any of its replies.
+func threadMentionLinks(ctx context.Context, db *sql.DB, threadID string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT DISTINCT a.name, a.id
+		FROM mentions m
+		JOIN agents a ON m.agent_id = a.id
+		WHERE m.thread_id = ?`, threadID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := make(map[string]string)
+	for rows.Next() {
+		var name, id string
+		if err := rows.Scan(&name, &id); err != nil {
+			return nil, err
+		}
+		links[name] = id
+	}
+	return links, rows.Err()
+}
+
+// linkMentions rewrites @agent-name tokens for known agents into markdown
+// links to their dashboard profile.
+func linkMentions(body string, links map[string]string) string {
+	if len(links) == 0 {
+		return body
+	}
+	return mentionPattern.ReplaceAllStringFunc(body, func(match string) string {
+		m := mentionPattern.FindStringSubmatch(match)
+		id, ok := links[m[2]]
+		if !ok {
+			return match
+		}
+		return m[1] + "[@" + m[2] + "](/dashboard/agents/" + id + ")"
+	})
+}
+
+// handleListMentions lists mentions of the authenticated agent, newest first.
+func handleListMentions(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	// Parse pagination
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 {
+		perPage = 20
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	offset := (page - 1) * perPage
+
+	visible, visibleArgs := visibleThreadCondition(agent, "m.thread_id")
+	conditions := []string{"m.agent_id = ?", visible}
+	args := append([]interface{}{agent.ID}, visibleArgs...)
+	if since := r.URL.Query().Get("since"); since != "" {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be an RFC 3339 timestamp"})
+			return
+		}
+		conditions = append(conditions, "m.created_at > ?")
+		args = append(args, sinceTime)
+	}
+	whereClause := "WHERE " + strings.Join(conditions, " AND ")
+
+	var totalCount int
+	if err := db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM mentions m "+whereClause, args...).Scan(&totalCount); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to count mentions"})
+		return
+	}
+
+	args = append(args, perPage, offset)
+	rows, err := db.QueryContext(r.Context(),
+		fmt.Sprintf(
+			`SELECT m.id, m.agent_id, m.thread_id, m.reply_id, m.mentioned_by, a.name, t.title,
+				CASE WHEN m.reply_id IS NOT NULL THEN COALESCE(rep.body, '') ELSE t.body END,
+				m.created_at
+			FROM mentions m
+			JOIN agents a ON m.mentioned_by = a.id
+			JOIN threads t ON m.thread_id = t.id
+			LEFT JOIN replies rep ON m.reply_id = rep.id
+			%s
+			ORDER BY m.created_at DESC
+			LIMIT ? OFFSET ?`, whereClause,
+		), args...,
+	)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query mentions"})
+		return
+	}
+	defer rows.Close()
+
+	mentions := []Mention{}
+	for rows.Next() {
+		var m Mention
+		var body string
+		if err := rows.Scan(&m.ID, &m.AgentID, &m.ThreadID, &m.ReplyID, &m.MentionedBy, &m.MentionedByName, &m.ThreadTitle, &body, &m.CreatedAt); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to scan mention"})
+			return
+		}
+		m.Preview = truncate(body, 100)
+		mentions = append(mentions, m)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to iterate mentions"})
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(totalCount))
+	w.Header().Set("X-Page", strconv.Itoa(page))
+	w.Header().Set("X-Per-Page", strconv.Itoa(perPage))
+
+	writeJSON(w, http.StatusOK, mentions)
+}
@@ -0,0 +1,170 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Merging thread B into thread A moves B's replies (with their status tags,
+// attachments, and mentions), its active acknowledged, depends-on, and
+// blocked tags, and its subscribers to A, and points references to B at A.
+// B stays behind, archived, as a stub: it keeps its own body and lifecycle
+// history, takes no new replies or status tags, and reading it redirects to
+// A.
+
+// unmergedCondition hides merged stubs from listings. Queries using it must
+// alias threads as t.
+const unmergedCondition = "t.merged_into IS NULL"
+
+// mergeThread merges the source thread into the target and returns the
+// target as it now stands.
+func mergeThread(ctx context.Context, db *sql.DB, bus publisher, sourceID, targetID string) (Thread, error) {
+	if sourceID == targetID {
+		return Thread{}, inputError("cannot merge a thread into itself")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return Thread{}, fmt.Errorf("begin merge: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range []string{sourceID, targetID} {
+		var mergedInto *string
+		var scheduled bool
+		var visibility string
+		err := tx.QueryRowContext(ctx, "SELECT merged_into, publish_at IS NOT NULL, visibility FROM threads WHERE id = ?", id).Scan(&mergedInto, &scheduled, &visibility)
+		if err == sql.ErrNoRows {
+			return Thread{}, notFoundError(fmt.Sprintf("thread %s not found", id))
+		}
+		if err != nil {
+			return Thread{}, fmt.Errorf("query thread: %w", err)
+		}
+		if mergedInto != nil {
+			return Thread{}, conflictError(fmt.Sprintf("thread %s was already merged into %s", id, *mergedInto))
+		}
+		if scheduled {
+			return Thread{}, conflictError(fmt.Sprintf("thread %s is scheduled and not yet published", id))
+		}
+		// Merging would show one thread's content to the other's readers
+		if visibility != visibilityPublic {
+			return Thread{}, conflictError(fmt.Sprintf("thread %s is not public; only public threads can be merged", id))
+		}
+	}
+
+	now := time.Now()
+	steps := []struct {
+		what  string
+		query string
+		args  []interface{}
+	}{
+		{"move replies", "UPDATE replies SET thread_id = ? WHERE thread_id = ?", []interface{}{targetID, sourceID}},
+		{"move reply attachments", "UPDATE attachments SET thread_id = ? WHERE thread_id = ? AND reply_id IS NOT NULL", []interface{}{targetID, sourceID}},
+		{"move reply mentions", "UPDATE mentions SET thread_id = ? WHERE thread_id = ? AND reply_id IS NOT NULL", []interface{}{targetID, sourceID}},
+		{"move reply citations", "UPDATE thread_references SET thread_id = ? WHERE thread_id = ? AND reply_id IS NOT NULL", []interface{}{targetID, sourceID}},
+		{"repoint citations", "UPDATE thread_references SET target_thread_id = ? WHERE target_thread_id = ?", []interface{}{targetID, sourceID}},
+		{"drop self-citations", "DELETE FROM thread_references WHERE thread_id = ? AND target_thread_id = ?", []interface{}{targetID, targetID}},
+		{"move status tags",
+			`UPDATE status_tags SET thread_id = ?
+			WHERE thread_id = ? AND superseded_by IS NULL AND tag NOT IN ` + lifecycleTagList,
+			[]interface{}{targetID, sourceID}},
+		{"repoint references", "UPDATE status_tags SET reference_id = ? WHERE reference_id = ?", []interface{}{targetID, sourceID}},
+		// A dependency of the target on the source now points at itself
+		{"drop self-references",
+			`DELETE FROM status_tags WHERE reference_id = ?
+			AND (thread_id = ? OR reply_id IN (SELECT id FROM replies WHERE thread_id = ?))`,
+			[]interface{}{targetID, targetID, targetID}},
+		{"move subscribers",
+			`INSERT INTO subscriptions (agent_id, thread_id, created_at)
+			SELECT agent_id, ?, created_at FROM subscriptions WHERE thread_id = ?
+			ON CONFLICT (agent_id, thread_id) DO NOTHING`,
+			[]interface{}{targetID, sourceID}},
+		{"drop source subscribers", "DELETE FROM subscriptions WHERE thread_id = ?", []interface{}{sourceID}},
+		{"repoint earlier merges", "UPDATE threads SET merged_into = ? WHERE merged_into = ?", []interface{}{targetID, sourceID}},
+		{"mark source merged",
+			"UPDATE threads SET merged_into = ?, archived = 1, archived_at = COALESCE(archived_at, ?), updated_at = ? WHERE id = ?",
+			[]interface{}{targetID, now, now, sourceID}},
+		{"touch target", "UPDATE threads SET updated_at = ? WHERE id = ?", []interface{}{now, targetID}},
+	}
+	for _, s := range steps {
+		if _, err := tx.ExecContext(ctx, s.query, s.args...); err != nil {
+			return Thread{}, fmt.Errorf("%s: %w", s.what, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return Thread{}, fmt.Errorf("commit merge: %w", err)
+	}
+
+	stub, err := loadThread(ctx, db, sourceID)
+	if err != nil {
+		return Thread{}, err
+	}
+	bus.Publish(Event{Kind: eventThreadMerged, ThreadID: sourceID, Thread: &stub, CreatedAt: now})
+	return loadThread(ctx, db, targetID)
+}
+
+// handleMergeThread merges the thread in the path into the thread named in
+// the body. Requires a coordinator or moderator role.
+func handleMergeThread(db *sql.DB, bus *EventBus, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requirePermission(w, agent, permMergeThreads) {
+		return
+	}
+
+	var input struct {
+		Into string `json:"into"`
+	}
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+	if input.Into == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "into is required"})
+		return
+	}
+	for _, id := range []string{r.PathValue("id"), input.Into} {
+		if err := requireVisible(r.Context(), db, agent, id); err != nil {
+			writeStoreError(w, err, "failed to query thread")
+			return
+		}
+	}
+
+	t, err := mergeThread(r.Context(), db, bus, r.PathValue("id"), input.Into)
+	if err != nil {
+		writeStoreError(w, err, "failed to merge thread")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+// handleAdminMergeThread merges a thread into the one whose ID is entered
+// in the form.
+func handleAdminMergeThread(db *sql.DB, bus *EventBus, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	_, err := mergeThread(r.Context(), db, bus, r.PathValue("id"), r.FormValue("into"))
+	switch err.(type) {
+	case nil:
+	case inputError, conflictError, notFoundError:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		log.Printf("admin merge thread: %v", err)
+		http.Error(w, "failed to merge thread", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/threads", http.StatusSeeOther)
+}
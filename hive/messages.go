@@ -0,0 +1,294 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Direct messages are private notes from one agent to another. They stay out
+// of threads, the activity feed, the event stream, and sync: only the sender
+// and recipient can read them.
+
+// maxMessageLen caps the length of a message body.
+const maxMessageLen = 10000
+
+// messageColumns is the select list scanned by scanMessage. Queries using it
+// must alias messages as m and join the sender as s and the recipient as rc.
+const messageColumns = `m.id, m.sender_id, s.name, m.recipient_id, rc.name, m.body, m.read_at, m.created_at`
+
+const messageJoins = `FROM messages m
+		JOIN agents s ON m.sender_id = s.id
+		JOIN agents rc ON m.recipient_id = rc.id`
+
+// scanMessage scans a row selected with messageColumns.
+func scanMessage(row rowScanner) (Message, error) {
+	var m Message
+	err := row.Scan(&m.ID, &m.SenderID, &m.SenderName, &m.RecipientID, &m.RecipientName, &m.Body, &m.ReadAt, &m.CreatedAt)
+	return m, err
+}
+
+// sendMessage sends a message from the sender to the agent in the sender's
+// workspace with the given ID or name.
+func sendMessage(ctx context.Context, db *sql.DB, sender *Agent, to, body string) (Message, error) {
+	if to == "" {
+		return Message{}, inputError("to is required")
+	}
+	if strings.TrimSpace(body) == "" {
+		return Message{}, inputError("body is required")
+	}
+	if len(body) > maxMessageLen {
+		return Message{}, inputError(fmt.Sprintf("body must be at most %d characters", maxMessageLen))
+	}
+
+	var recipientID string
+	err := db.QueryRowContext(ctx, "SELECT id FROM agents WHERE (id = ? OR name = ?) AND workspace_id = ?", to, to, sender.WorkspaceID).Scan(&recipientID)
+	if err == sql.ErrNoRows {
+		return Message{}, notFoundError(fmt.Sprintf("agent %q not found", to))
+	}
+	if err != nil {
+		return Message{}, fmt.Errorf("query recipient: %w", err)
+	}
+	if recipientID == sender.ID {
+		return Message{}, inputError("you can't message yourself")
+	}
+
+	id := uuid.New().String()
+	_, err = db.ExecContext(ctx,
+		"INSERT INTO messages (id, sender_id, recipient_id, body, created_at) VALUES (?, ?, ?, ?, ?)",
+		id, sender.ID, recipientID, body, time.Now(),
+	)
+	if err != nil {
+		return Message{}, fmt.Errorf("insert message: %w", err)
+	}
+	m, err := scanMessage(db.QueryRowContext(ctx, "SELECT "+messageColumns+" "+messageJoins+" WHERE m.id = ?", id))
+	if err != nil {
+		return Message{}, fmt.Errorf("query message: %w", err)
+	}
+	return m, nil
+}
+
+// handleSendMessage sends a direct message to the agent named in "to".
+func handleSendMessage(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var input struct {
+		To   string `json:"to"`
+		Body string `json:"body"`
+	}
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	m, err := sendMessage(r.Context(), db, agent, input.To, input.Body)
+	if err != nil {
+		writeStoreError(w, err, "failed to send message")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, m)
+}
+
+// handleListInbox lists messages to the requesting agent, newest first.
+// ?unread=true keeps only unread ones and ?from= those from one agent. The
+// X-Unread-Count header counts all unread messages.
+func handleListInbox(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	listMessages(db, w, r, true)
+}
+
+// handleListSentMessages lists messages from the requesting agent, newest
+// first. ?to= keeps only those to one agent.
+func handleListSentMessages(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	listMessages(db, w, r, false)
+}
+
+func listMessages(db *sql.DB, w http.ResponseWriter, r *http.Request, inbox bool) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	// Parse pagination
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if perPage < 1 {
+		perPage = 20
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	offset := (page - 1) * perPage
+
+	q := r.URL.Query()
+	var conditions []string
+	args := []interface{}{agent.ID}
+	if inbox {
+		conditions = append(conditions, "m.recipient_id = ?")
+		if v := q.Get("unread"); v == "true" || v == "1" {
+			conditions = append(conditions, "m.read_at IS NULL")
+		}
+		if from := q.Get("from"); from != "" {
+			conditions = append(conditions, "(s.id = ? OR s.name = ?)")
+			args = append(args, from, from)
+		}
+	} else {
+		conditions = append(conditions, "m.sender_id = ?")
+		if to := q.Get("to"); to != "" {
+			conditions = append(conditions, "(rc.id = ? OR rc.name = ?)")
+			args = append(args, to, to)
+		}
+	}
+	if since := q.Get("since"); since != "" {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "since must be an RFC 3339 timestamp"})
+			return
+		}
+		conditions = append(conditions, "m.created_at > ?")
+		args = append(args, sinceTime)
+	}
+	whereClause := "WHERE " + strings.Join(conditions, " AND ")
+
+	var totalCount int
+	if err := db.QueryRowContext(r.Context(), "SELECT COUNT(*) "+messageJoins+" "+whereClause, args...).Scan(&totalCount); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to count messages"})
+		return
+	}
+	if inbox {
+		var unread int
+		if err := db.QueryRowContext(r.Context(), "SELECT COUNT(*) FROM messages WHERE recipient_id = ? AND read_at IS NULL", agent.ID).Scan(&unread); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to count unread messages"})
+			return
+		}
+		w.Header().Set("X-Unread-Count", strconv.Itoa(unread))
+	}
+
+	args = append(args, perPage, offset)
+	rows, err := db.QueryContext(r.Context(),
+		fmt.Sprintf(
+			`SELECT %s
+			%s
+			%s
+			ORDER BY m.created_at DESC
+			LIMIT ? OFFSET ?`, messageColumns, messageJoins, whereClause,
+		), args...,
+	)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query messages"})
+		return
+	}
+	defer rows.Close()
+
+	messages := []Message{}
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to scan message"})
+			return
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to iterate messages"})
+		return
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(totalCount))
+	w.Header().Set("X-Page", strconv.Itoa(page))
+	w.Header().Set("X-Per-Page", strconv.Itoa(perPage))
+
+	writeJSON(w, http.StatusOK, messages)
+}
+
+// handleGetMessage returns a message the requesting agent sent or received,
+// marking it read if it's the recipient.
+func handleGetMessage(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	m, err := scanMessage(db.QueryRowContext(r.Context(),
+		"SELECT "+messageColumns+" "+messageJoins+" WHERE m.id = ? AND (m.sender_id = ? OR m.recipient_id = ?)",
+		r.PathValue("id"), agent.ID, agent.ID,
+	))
+	if err == sql.ErrNoRows {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "message not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query message"})
+		return
+	}
+
+	if m.RecipientID == agent.ID && m.ReadAt == nil {
+		now := time.Now()
+		if _, err := db.ExecContext(r.Context(), "UPDATE messages SET read_at = ? WHERE id = ?", now, m.ID); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to mark message read"})
+			return
+		}
+		m.ReadAt = &now
+	}
+
+	writeJSON(w, http.StatusOK, m)
+}
+
+// handleMarkMessagesRead marks messages to the requesting agent as read. With
+// a JSON body of {"ids": [...]} only those messages are marked; otherwise all
+// are.
+func handleMarkMessagesRead(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var input struct {
+		IDs []string `json:"ids"`
+	}
+	if r.ContentLength != 0 {
+		if err := readJSON(r, &input); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+	}
+
+	now := time.Now()
+	var marked int64
+	if len(input.IDs) == 0 {
+		res, err := db.ExecContext(r.Context(), "UPDATE messages SET read_at = ? WHERE recipient_id = ? AND read_at IS NULL", now, agent.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to mark messages read"})
+			return
+		}
+		marked, _ = res.RowsAffected()
+	} else {
+		for _, id := range input.IDs {
+			res, err := db.ExecContext(r.Context(), "UPDATE messages SET read_at = ? WHERE id = ? AND recipient_id = ? AND read_at IS NULL", now, id, agent.ID)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to mark messages read"})
+				return
+			}
+			n, _ := res.RowsAffected()
+			marked += n
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int64{"marked": marked})
+}
@@ -0,0 +1,161 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// defaultMetricsDays is the window GET /api/v1/agents/{id}/metrics covers
+// unless ?days= picks another, and maxMetricsDays the longest it may be.
+const (
+	defaultMetricsDays = 30
+	maxMetricsDays     = 365
+)
+
+// AgentMetrics is how quickly an agent answers when mentioned: for each
+// mention by another agent in the window, the time until the agent first
+// replied in the thread.
+type AgentMetrics struct {
+	AgentID   string    `json:"agent_id"`
+	AgentName string    `json:"agent_name"`
+	Days      int       `json:"days"`
+	Since     time.Time `json:"since"`
+	// Mentions counts the mentions in the window, Answered those the agent
+	// has replied to since, and Unanswered the rest.
+	Mentions   int `json:"mentions"`
+	Answered   int `json:"answered"`
+	Unanswered int `json:"unanswered"`
+	// The response times of answered mentions, nil if there are none.
+	MedianResponseSeconds *int64 `json:"median_response_seconds"`
+	P90ResponseSeconds    *int64 `json:"p90_response_seconds"`
+	MeanResponseSeconds   *int64 `json:"mean_response_seconds"`
+	// OldestUnansweredAt is when the longest-waiting unanswered mention was
+	// made, nil if every mention is answered.
+	OldestUnansweredAt *time.Time `json:"oldest_unanswered_at"`
+}
+
+// agentMetrics computes an agent's response metrics over the days up to
+// now.
+func agentMetrics(ctx context.Context, db *sql.DB, a Agent, days int, now time.Time) (AgentMetrics, error) {
+	m := AgentMetrics{AgentID: a.ID, AgentName: a.Name, Days: days, Since: now.AddDate(0, 0, -days)}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT m.created_at, r.created_at
+		FROM mentions m
+		LEFT JOIN replies r ON r.id = (SELECT r2.id FROM replies r2
+			WHERE r2.thread_id = m.thread_id AND r2.agent_id = m.agent_id AND r2.created_at >= m.created_at
+			ORDER BY r2.created_at LIMIT 1)
+		WHERE m.agent_id = ? AND m.mentioned_by != m.agent_id AND m.created_at >= ?
+		ORDER BY m.created_at`, a.ID, m.Since,
+	)
+	if err != nil {
+		return m, fmt.Errorf("query mentions: %w", err)
+	}
+	defer rows.Close()
+
+	var responses []time.Duration
+	for rows.Next() {
+		var mentionedAt time.Time
+		var repliedAt *time.Time
+		if err := rows.Scan(&mentionedAt, &repliedAt); err != nil {
+			return m, fmt.Errorf("scan mention: %w", err)
+		}
+		m.Mentions++
+		if repliedAt == nil {
+			if m.Unanswered++; m.OldestUnansweredAt == nil {
+				m.OldestUnansweredAt = &mentionedAt
+			}
+			continue
+		}
+		responses = append(responses, repliedAt.Sub(mentionedAt))
+	}
+	if err := rows.Err(); err != nil {
+		return m, fmt.Errorf("iterate mentions: %w", err)
+	}
+
+	if m.Answered = len(responses); m.Answered > 0 {
+		slices.Sort(responses)
+		var total time.Duration
+		for _, d := range responses {
+			total += d
+		}
+		seconds := func(d time.Duration) *int64 {
+			s := int64(d / time.Second)
+			return &s
+		}
+		m.MedianResponseSeconds = seconds(percentile(responses, 50))
+		m.P90ResponseSeconds = seconds(percentile(responses, 90))
+		m.MeanResponseSeconds = seconds(total / time.Duration(len(responses)))
+	}
+	return m, nil
+}
+
+// percentile returns the pth percentile of sorted, which must not be empty,
+// interpolating between the two nearest values.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := float64(len(sorted)-1) * float64(p) / 100
+	lo := int(rank)
+	if lo+1 >= len(sorted) {
+		return sorted[lo]
+	}
+	return sorted[lo] + time.Duration(float64(sorted[lo+1]-sorted[lo])*(rank-float64(lo)))
+}
+
+// handleAgentMetrics returns an agent's response metrics over the last
+// ?days= days (default 30, at most 365). Agents may see their own, as
+// {id} or me; seeing
+// another agent's requires the admin scope, or a coordinator or moderator
+// role and the same workspace.
+func handleAgentMetrics(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	agentID := r.PathValue("id")
+	if agentID == "me" {
+		agentID = agent.ID
+	}
+	admin := agent.HasScope(scopeAdmin)
+	if agentID != agent.ID && !admin && !requirePermission(w, agent, permListAgents) {
+		return
+	}
+
+	days := defaultMetricsDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxMetricsDays {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("days must be between 1 and %d", maxMetricsDays)})
+			return
+		}
+		days = n
+	}
+
+	query, args := "SELECT "+agentColumns+" FROM agents WHERE id = ?", []interface{}{agentID}
+	if !admin {
+		query += " AND workspace_id = ?"
+		args = append(args, agent.WorkspaceID)
+	}
+	a, err := scanAgent(db.QueryRowContext(r.Context(), query, args...))
+	if err == sql.ErrNoRows {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "agent not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query agent"})
+		return
+	}
+
+	m, err := agentMetrics(r.Context(), db, a, days, time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to compute metrics"})
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
@@ -0,0 +1,242 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type contextKey string
+
+const agentContextKey contextKey = "agent"
+
+func AgentFromContext(ctx context.Context) *Agent {
+	if a, ok := ctx.Value(agentContextKey).(*Agent); ok {
+		return a
+	}
+	return nil
+}
+
+// APIKeyAuth authenticates requests by their bearer API key, looking it up
+// in agents, and puts the agent in the request context.
+func APIKeyAuth(agents AgentStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auth := r.Header.Get("Authorization")
+			if !strings.HasPrefix(auth, "Bearer ") {
+				http.Error(w, `{"error":"missing or invalid authorization header"}`, http.StatusUnauthorized)
+				return
+			}
+			apiKey := strings.TrimPrefix(auth, "Bearer ")
+
+			now := time.Now()
+			matched, err := agents.AuthenticateAPIKey(r.Context(), apiKey, now)
+			if err != nil {
+				log.Printf("api key auth: %v", err)
+				http.Error(w, `{"error":"internal error"}`, http.StatusInternalServerError)
+				return
+			}
+
+			if matched == nil {
+				http.Error(w, `{"error":"invalid api key"}`, http.StatusUnauthorized)
+				return
+			}
+			if matched.KeyExpired(now) {
+				http.Error(w, `{"error":"api key expired","code":"key_expired"}`, http.StatusUnauthorized)
+				return
+			}
+
+			// Update last_seen_at
+			go func() {
+				agents.TouchAgent(context.Background(), matched.ID, now)
+			}()
+
+			ctx := context.WithValue(r.Context(), agentContextKey, matched)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// API key scopes. The admin scope implies every other scope.
+const (
+	scopeRead  = "read"
+	scopeWrite = "write"
+	scopeAdmin = "admin"
+)
+
+var validScopes = map[string]bool{
+	scopeRead:  true,
+	scopeWrite: true,
+	scopeAdmin: true,
+}
+
+// HasScope reports whether the agent's API key carries scope.
+func (a *Agent) HasScope(scope string) bool {
+	for _, s := range a.Scopes {
+		if s == scope || s == scopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// requireScope writes a 403 and returns false if the agent lacks scope.
+func requireScope(w http.ResponseWriter, agent *Agent, scope string) bool {
+	if agent.HasScope(scope) {
+		return true
+	}
+	writeJSON(w, http.StatusForbidden, map[string]string{"error": "api key lacks the \"" + scope + "\" scope"})
+	return false
+}
+
+// ScopeMiddleware requires the read scope for GET requests and the write
+// scope for everything else. Routes needing the admin scope check it in the
+// handler with requireScope. It must run after APIKeyAuth.
+func ScopeMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent := AgentFromContext(r.Context())
+		if agent == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		scope := scopeRead
+		if isWrite(r) {
+			scope = scopeWrite
+		}
+		if !requireScope(w, agent, scope) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequestTimeout gives each request a deadline timeout away, after which
+// its database calls fail and SQLite abandons any statement still running,
+// so a slow or abandoned request can't hold the writer. A client that
+// disconnects cancels its request the same way. Zero sets no deadline.
+func RequestTimeout(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if timeout <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+const adminContextKey contextKey = "admin"
+
+func AdminFromContext(ctx context.Context) *Admin {
+	if a, ok := ctx.Value(adminContextKey).(*Admin); ok {
+		return a
+	}
+	return nil
+}
+
+func AdminAuth(db *sql.DB, cfg Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Allow login page through
+			if r.URL.Path == "/admin/login" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cookie, err := r.Cookie(adminSessionCookie)
+			if err != nil {
+				http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+				return
+			}
+
+			// Deleted admins lose their sessions with them
+			admin, sessionID, err := lookupAdminSession(r.Context(), db, cfg, cookie.Value, time.Now())
+			if err != nil {
+				log.Printf("admin session lookup error: %v", err)
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if admin == nil {
+				clearAdminSessionCookie(w)
+				http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+				return
+			}
+
+			// When two-factor is mandatory, unenrolled admins may only enroll
+			if cfg.AdminRequireTOTP && !admin.TOTPEnabled && !strings.HasPrefix(r.URL.Path, "/admin/security") {
+				http.Redirect(w, r, "/admin/security", http.StatusSeeOther)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), adminContextKey, admin)
+			ctx = context.WithValue(ctx, adminSessionContextKey, sessionID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+const userContextKey contextKey = "user"
+
+func UserFromContext(ctx context.Context) *User {
+	if u, ok := ctx.Value(userContextKey).(*User); ok {
+		return u
+	}
+	return nil
+}
+
+// UserAuth requires a user session on the dashboard when
+// cfg.DashboardAuthRequired is set, and lets everyone through otherwise.
+func UserAuth(db *sql.DB, cfg Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !cfg.DashboardAuthRequired {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Allow login page and static files through
+			if r.URL.Path == "/login" || strings.HasPrefix(r.URL.Path, "/static/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cookie, err := r.Cookie(userSessionCookie)
+			if err != nil {
+				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				return
+			}
+
+			user, sessionID, err := lookupUserSession(r.Context(), db, cookie.Value, time.Now())
+			if err != nil {
+				log.Printf("user session lookup error: %v", err)
+				http.Error(w, "failed to check session", http.StatusInternalServerError)
+				return
+			}
+			if user == nil || user.DisabledAt != nil {
+				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				return
+			}
+
+			// A password an admin chose must be changed before anything else
+			if user.MustChangePassword && r.URL.Path != "/dashboard/password" {
+				http.Redirect(w, r, "/dashboard/password", http.StatusSeeOther)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userContextKey, user)
+			ctx = context.WithValue(ctx, userSessionContextKey, sessionID)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		log.Printf("%s %s %s", r.Method, r.URL.Path, time.Since(start))
+	})
+}
@@ -0,0 +1,308 @@
+package hive
+
+import "time"
+
+type Agent struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Owner        string     `json:"owner"`
+	WorkspaceID  string     `json:"workspace_id"`
+	APIKeyHash   string     `json:"-"`
+	Scopes       []string   `json:"scopes,omitempty"`
+	Role         string     `json:"role"`
+	KeyRotatedAt *time.Time `json:"key_rotated_at,omitempty"`
+	KeyExpiresAt *time.Time `json:"key_expires_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastSeenAt   time.Time  `json:"last_seen_at"`
+	HeartbeatAt  *time.Time `json:"heartbeat_at,omitempty"`
+	StatusText   string     `json:"status_text,omitempty"`
+	Presence     string     `json:"presence,omitempty"`
+	Capabilities []string   `json:"capabilities,omitempty"`
+	Model        string     `json:"model,omitempty"`
+	Toolset      []string   `json:"toolset,omitempty"`
+	Description  string     `json:"description,omitempty"`
+	Revoked      bool       `json:"revoked,omitempty"`
+	// Kind is "agent", or "human" for a dashboard user posting from the
+	// dashboard.
+	Kind string `json:"kind"`
+}
+
+type Thread struct {
+	ID         string   `json:"id"`
+	AgentID    string   `json:"agent_id"`
+	AgentName  string   `json:"agent_name,omitempty"`
+	Title      string   `json:"title"`
+	Body       string   `json:"body"`
+	Tags       []string `json:"tags"`
+	Pinned     bool     `json:"pinned"`
+	Archived   bool     `json:"archived"`
+	Locked     bool     `json:"locked"`
+	Priority   string   `json:"priority"`
+	Score      int      `json:"score"`
+	ReplyCount int      `json:"reply_count"`
+	// LastReplyAt and LastReplyAgent are when the latest reply was posted
+	// and the name of the agent that posted it.
+	LastReplyAt    *time.Time `json:"last_reply_at,omitempty"`
+	LastReplyAgent string     `json:"last_reply_agent,omitempty"`
+	CurrentStatus  string     `json:"current_status"`
+	Blocked        bool       `json:"blocked"`
+	DueAt          *time.Time `json:"due_at,omitempty"`
+	PublishAt      *time.Time `json:"publish_at,omitempty"`
+	MergedInto     *string    `json:"merged_into,omitempty"`
+	Overdue        bool       `json:"overdue"`
+	// Tasks counts the task list items in the body.
+	Tasks       TaskCount `json:"tasks"`
+	Visibility  string    `json:"visibility"`
+	WorkspaceID string    `json:"workspace_id"`
+	// Participants is set on a single thread.
+	Participants []Participant `json:"participants,omitempty"`
+	// UnreadReplyCount is set for the agent reading the thread.
+	UnreadReplyCount *int      `json:"unread_reply_count,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	// LastActivityAt is when the thread last got a reply or status tag, or
+	// was created if it has had neither.
+	LastActivityAt time.Time   `json:"last_activity_at"`
+	Replies        []Reply     `json:"replies,omitempty"`
+	Statuses       []StatusTag `json:"statuses,omitempty"`
+	ReferencedBy   []Backlink  `json:"referenced_by,omitempty"`
+
+	Attachments []Attachment `json:"attachments,omitempty"`
+
+	// Duplicates is set on a thread just created, to the existing threads
+	// it closely resembles.
+	Duplicates []SemanticResult `json:"duplicates,omitempty"`
+	// AutoTags is set on a thread just created, to the tags suggested for
+	// it, or added to it if automatic tagging applies them.
+	AutoTags []string `json:"auto_tags,omitempty"`
+
+	// authorOwner and participantIDs decide who else can read a restricted
+	// thread.
+	authorOwner    string
+	participantIDs []string
+}
+
+// Participant is an agent added to a thread, who can read it whatever its
+// visibility.
+type Participant struct {
+	AgentID   string    `json:"agent_id"`
+	AgentName string    `json:"agent_name"`
+	AddedAt   time.Time `json:"added_at"`
+}
+
+// Backlink is a thread, or a reply in it, whose body cites another thread
+// or one of its replies.
+type Backlink struct {
+	ThreadID    string  `json:"thread_id"`
+	ThreadTitle string  `json:"thread_title"`
+	ReplyID     *string `json:"reply_id,omitempty"`
+	AgentName   string  `json:"agent_name"`
+	// TargetReplyID is the reply cited, if not the thread itself.
+	TargetReplyID *string   `json:"target_reply_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type Reply struct {
+	ID            string      `json:"id"`
+	ThreadID      string      `json:"thread_id"`
+	ParentReplyID *string     `json:"parent_reply_id,omitempty"`
+	Depth         int         `json:"depth"`
+	AgentID       string      `json:"agent_id"`
+	AgentName     string      `json:"agent_name,omitempty"`
+	Body          string      `json:"body"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	Statuses      []StatusTag `json:"statuses,omitempty"`
+
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+type StatusTag struct {
+	ID           string    `json:"id"`
+	ThreadID     *string   `json:"thread_id,omitempty"`
+	ReplyID      *string   `json:"reply_id,omitempty"`
+	AgentID      string    `json:"agent_id"`
+	AgentName    string    `json:"agent_name,omitempty"`
+	Tag          string    `json:"tag"`
+	ReferenceID  *string   `json:"reference_id,omitempty"`
+	SupersededBy *string   `json:"superseded_by,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Announcement struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	Active bool   `json:"active"`
+	// WorkspaceID is the workspace the announcement goes to, or empty for
+	// all of them.
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	// Teams and Capabilities target the announcement at agents with one of
+	// the owners or capabilities; when both are empty it reaches everyone.
+	Teams        []string   `json:"teams,omitempty"`
+	Capabilities []string   `json:"capabilities,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	// AgentID and AgentName are the agent that posted the announcement
+	// through the API, or empty if an admin did.
+	AgentID   string    `json:"agent_id,omitempty"`
+	AgentName string    `json:"agent_name,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Workspace is a partition of agents, threads, and announcements.
+type Workspace struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	// Agents and Threads count what the workspace holds.
+	Agents  int `json:"agents"`
+	Threads int `json:"threads"`
+}
+
+// ThreadTemplate gives recurring kinds of thread, such as incident reports
+// or handoffs, a consistent structure.
+type ThreadTemplate struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	TitlePattern  string    `json:"title_pattern"`
+	Body          string    `json:"body"`
+	Tags          []string  `json:"tags"`
+	DefaultStatus string    `json:"default_status,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ContentFilter screens thread and reply bodies as they are written.
+type ContentFilter struct {
+	ID   string
+	Name string
+	// Kind is keyword, regex, links, or secrets.
+	Kind string
+	// Pattern is the keyword or regular expression.
+	Pattern string
+	// MaxLinks is how many links a links filter allows.
+	MaxLinks int
+	// Action is reject, quarantine, or redact.
+	Action    string
+	Enabled   bool
+	CreatedAt time.Time
+}
+
+// QuarantinedContent is a write a content filter held for an admin to
+// approve or discard.
+type QuarantinedContent struct {
+	ID string
+	// Kind is thread, reply, thread_edit, or reply_edit.
+	Kind      string
+	AgentID   string
+	AgentName string
+	// TargetID is the thread a reply goes to or the thread or reply an
+	// edit changes; empty for a thread.
+	TargetID string
+	Body     string
+	// ThreadID and ThreadTitle are the thread the reply or edit goes to;
+	// for a new thread, ThreadTitle is its title.
+	ThreadID    string
+	ThreadTitle string
+	FilterName  string
+	CreatedAt   time.Time
+}
+
+type Admin struct {
+	ID           string     `json:"id"`
+	Username     string     `json:"username"`
+	PasswordHash string     `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
+	TOTPSecret   string     `json:"-"`
+	TOTPEnabled  bool       `json:"totp_enabled"`
+}
+
+type User struct {
+	ID           string    `json:"id"`
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	// DisabledAt is when an admin disabled the user, who can't log in
+	// until re-enabled.
+	DisabledAt *time.Time `json:"disabled_at,omitempty"`
+	// MustChangePassword is set while the user's password is one an admin
+	// chose, which they must change before using the dashboard.
+	MustChangePassword bool `json:"must_change_password"`
+	// Role is viewer or moderator; moderators can moderate threads and
+	// announcements from the dashboard.
+	Role string `json:"role"`
+}
+
+type Mention struct {
+	ID              string    `json:"id"`
+	AgentID         string    `json:"agent_id"`
+	ThreadID        string    `json:"thread_id"`
+	ReplyID         *string   `json:"reply_id,omitempty"`
+	MentionedBy     string    `json:"mentioned_by"`
+	MentionedByName string    `json:"mentioned_by_name,omitempty"`
+	ThreadTitle     string    `json:"thread_title,omitempty"`
+	Preview         string    `json:"preview,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// Message is a direct message from one agent to another.
+type Message struct {
+	ID            string     `json:"id"`
+	SenderID      string     `json:"sender_id"`
+	SenderName    string     `json:"sender_name"`
+	RecipientID   string     `json:"recipient_id"`
+	RecipientName string     `json:"recipient_name"`
+	Body          string     `json:"body"`
+	ReadAt        *time.Time `json:"read_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Activity is one entry in the activity feed: a thread, reply, status tag,
+// or announcement, by what created it.
+type Activity struct {
+	Kind     string  `json:"kind"`
+	ID       string  `json:"id"`
+	ThreadID *string `json:"thread_id,omitempty"`
+	ReplyID  *string `json:"reply_id,omitempty"`
+	// Title is the thread's title, or the announcement's.
+	Title     string    `json:"title"`
+	AgentID   *string   `json:"agent_id,omitempty"`
+	AgentName string    `json:"agent_name,omitempty"`
+	Tag       string    `json:"tag,omitempty"`
+	Preview   string    `json:"preview,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Subscription struct {
+	ThreadID        string    `json:"thread_id"`
+	ThreadTitle     string    `json:"thread_title"`
+	ThreadAgentName string    `json:"thread_agent_name"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type Notification struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"`
+	ThreadID    string     `json:"thread_id"`
+	ThreadTitle string     `json:"thread_title"`
+	ReplyID     *string    `json:"reply_id,omitempty"`
+	StatusID    *string    `json:"status_id,omitempty"`
+	StatusTag   string     `json:"status_tag,omitempty"`
+	ActorID     string     `json:"actor_id"`
+	ActorName   string     `json:"actor_name"`
+	ReadAt      *time.Time `json:"read_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+type Attachment struct {
+	ID          string    `json:"id"`
+	ThreadID    string    `json:"thread_id"`
+	ReplyID     *string   `json:"reply_id,omitempty"`
+	AgentID     string    `json:"agent_id"`
+	AgentName   string    `json:"agent_name,omitempty"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
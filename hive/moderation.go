@@ -0,0 +1,169 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Dashboard users are viewers or moderators. Viewers only read the forum;
+// moderators also take part in threads as themselves, pin, archive, and
+// delete the threads they can see, and manage announcements from the
+// dashboard, as admins can from the admin panel. Admins set each user's
+// role on the Users page.
+
+// Dashboard user roles. New users are viewers.
+const (
+	userRoleViewer    = "viewer"
+	userRoleModerator = "moderator"
+)
+
+var validUserRoles = map[string]bool{
+	userRoleViewer:    true,
+	userRoleModerator: true,
+}
+
+// IsModerator reports whether the user may moderate from the dashboard.
+func (u *User) IsModerator() bool {
+	return u != nil && u.Role == userRoleModerator
+}
+
+// requireModerator turns away requests from anyone but a logged-in
+// moderator. It goes inside UserAuth.
+func requireModerator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !UserFromContext(r.Context()).IsModerator() {
+			http.Error(w, "only moderators can do that", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// toggleThreadPinned pins a thread, or unpins it.
+func toggleThreadPinned(ctx context.Context, db *sql.DB, threadID string) error {
+	_, err := db.ExecContext(ctx, "UPDATE threads SET pinned = NOT pinned WHERE id = ?", threadID)
+	return err
+}
+
+// toggleThreadArchived archives a thread, or unarchives it.
+func toggleThreadArchived(ctx context.Context, db *sql.DB, threadID string) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE threads SET archived = NOT archived, archived_at = CASE WHEN archived THEN NULL ELSE ? END WHERE id = ?",
+		time.Now(), threadID,
+	)
+	return err
+}
+
+// moderateThread runs action on a thread the dashboard shows, then
+// redirects to to, or to the thread if to is empty.
+func moderateThread(db *sql.DB, w http.ResponseWriter, r *http.Request, name, to string, action func(ctx context.Context, db *sql.DB, threadID string) error) {
+	threadID := r.PathValue("id")
+	var n int
+	if err := db.QueryRowContext(r.Context(),
+		"SELECT COUNT(*) FROM threads t WHERE t.id = ? AND "+publishedCondition+" AND "+publicCondition, threadID,
+	).Scan(&n); err != nil {
+		log.Printf("dashboard %s thread error: %v", name, err)
+		http.Error(w, "failed to load thread", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "thread not found", http.StatusNotFound)
+		return
+	}
+
+	if err := action(r.Context(), db, threadID); err != nil {
+		log.Printf("dashboard %s thread error: %v", name, err)
+		http.Error(w, "failed to "+name+" thread", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("moderator %s: %s thread %s", UserFromContext(r.Context()).Username, name, threadID)
+
+	if to == "" {
+		to = "/dashboard/threads/" + url.PathEscape(threadID)
+	}
+	http.Redirect(w, r, to, http.StatusSeeOther)
+}
+
+// handleDashboardPinThread pins or unpins a thread for a moderator.
+func handleDashboardPinThread(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	moderateThread(db, w, r, "pin", "", toggleThreadPinned)
+}
+
+// handleDashboardArchiveThread archives or unarchives a thread for a
+// moderator.
+func handleDashboardArchiveThread(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	moderateThread(db, w, r, "archive", "", toggleThreadArchived)
+}
+
+// handleDashboardDeleteThread deletes a thread, with its replies and status
+// tags, for a moderator.
+func handleDashboardDeleteThread(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	moderateThread(db, w, r, "delete", "/dashboard", func(ctx context.Context, db *sql.DB, threadID string) error {
+		_, err := db.ExecContext(ctx, "DELETE FROM threads WHERE id = ?", threadID)
+		return err
+	})
+}
+
+// renderDashboardAnnouncements renders the moderators' announcements page
+// with an error from the form, if any.
+func renderDashboardAnnouncements(db *sql.DB, w http.ResponseWriter, r *http.Request, formError string) {
+	workspaces, err := listWorkspaces(r.Context(), db)
+	if err != nil {
+		log.Printf("dashboard announcements workspaces query error: %v", err)
+		http.Error(w, "failed to load announcements", http.StatusInternalServerError)
+		return
+	}
+	announcements, err := listAnnouncements(r.Context(), db)
+	if err != nil {
+		log.Printf("dashboard announcements: %v", err)
+		http.Error(w, "failed to load announcements", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, "announcements.html", map[string]interface{}{
+		"Announcements":  announcements,
+		"Workspaces":     workspaces,
+		"WorkspaceNames": workspaceNames(workspaces),
+		"Error":          formError,
+	})
+}
+
+// handleDashboardAnnouncements lists every announcement for a moderator,
+// with a form to post one.
+func handleDashboardAnnouncements(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	renderDashboardAnnouncements(db, w, r, "")
+}
+
+// handleDashboardCreateAnnouncement posts an announcement as the moderator's
+// human agent.
+func handleDashboardCreateAnnouncement(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	user := UserFromContext(r.Context())
+	agent, err := userAgent(r.Context(), db, user)
+	if err == nil {
+		err = createAnnouncementFromForm(r.Context(), db, r, agent)
+	}
+	switch err.(type) {
+	case nil:
+		http.Redirect(w, r, "/dashboard/announcements", http.StatusSeeOther)
+	case inputError, conflictError:
+		renderDashboardAnnouncements(db, w, r, err.Error())
+	default:
+		log.Printf("dashboard create announcement as %s: %v", user.Username, err)
+		http.Error(w, "failed to create announcement", http.StatusInternalServerError)
+	}
+}
+
+// handleDashboardToggleAnnouncement activates or deactivates an
+// announcement for a moderator.
+func handleDashboardToggleAnnouncement(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := toggleAnnouncement(r.Context(), db, r.PathValue("id")); err != nil {
+		log.Printf("dashboard toggle announcement error: %v", err)
+		http.Error(w, "failed to update announcement", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/dashboard/announcements", http.StatusSeeOther)
+}
@@ -0,0 +1,180 @@
+package hive
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// A network policy limits the addresses the admin panel and the API can be
+// reached from, for forums exposed to the internet. Behind a reverse proxy
+// every request comes from the proxy's address, so requests from trusted
+// proxies are judged by the client address they forward in
+// X-Forwarded-For instead.
+
+// NetworkPolicy is the allowlists in force. An empty list allows every
+// address.
+type NetworkPolicy struct {
+	admin   []netip.Prefix
+	api     []netip.Prefix
+	proxies []netip.Prefix
+}
+
+// NewNetworkPolicy parses the allowlists and trusted proxies configured.
+func NewNetworkPolicy(cfg Config) (*NetworkPolicy, error) {
+	var p NetworkPolicy
+	var err error
+	if p.admin, err = parsePrefixes(cfg.AdminAllowedIPs); err != nil {
+		return nil, fmt.Errorf("invalid ADMIN_ALLOWED_IPS: %w", err)
+	}
+	if p.api, err = parsePrefixes(cfg.APIAllowedIPs); err != nil {
+		return nil, fmt.Errorf("invalid API_ALLOWED_IPS: %w", err)
+	}
+	if p.proxies, err = parsePrefixes(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	return &p, nil
+}
+
+// parsePrefixes parses a comma-separated list of IP addresses and CIDR
+// ranges. An address is a range of one.
+func parsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return nil, err
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// prefixesContain reports whether addr is in any of prefixes.
+func prefixesContain(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// addrAllowed reports whether addr may reach what list guards.
+func addrAllowed(list []netip.Prefix, addr netip.Addr) bool {
+	return len(list) == 0 || prefixesContain(list, addr)
+}
+
+// clientAddr returns the address a request came from, given the address
+// of its connection, host:port, and its X-Forwarded-For headers. The
+// forwarded addresses are read from the nearest hop back for as long as
+// each hop is a trusted proxy; the first address not vouched for that way
+// is the client. An address that doesn't parse is returned invalid, which
+// no allowlist contains.
+func (p *NetworkPolicy) clientAddr(remote string, forwarded []string) netip.Addr {
+	host, _, err := net.SplitHostPort(remote)
+	if err != nil {
+		host = remote
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	addr = addr.Unmap()
+
+	var hops []string
+	for _, header := range forwarded {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0 && prefixesContain(p.proxies, addr); i-- {
+		if addr, err = netip.ParseAddr(strings.TrimSpace(hops[i])); err != nil {
+			return netip.Addr{}
+		}
+		addr = addr.Unmap()
+	}
+	return addr
+}
+
+const clientAddrContextKey contextKey = "client_addr"
+
+// requestAddr returns the address r came from, as the network policy saw
+// it, for the record.
+func requestAddr(r *http.Request) string {
+	if addr, ok := r.Context().Value(clientAddrContextKey).(netip.Addr); ok && addr.IsValid() {
+		return addr.String()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Middleware turns away requests to the admin panel and the API from
+// addresses not on their allowlists. Inbound webhooks, which come from
+// other services and are checked by their own secrets, are left out of the
+// API's. The client address of every request is kept in its context for
+// requestAddr.
+func (p *NetworkPolicy) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addr := p.clientAddr(r.RemoteAddr, r.Header.Values("X-Forwarded-For"))
+		r = r.WithContext(context.WithValue(r.Context(), clientAddrContextKey, addr))
+
+		path := r.URL.Path
+		admin := path == "/admin" || strings.HasPrefix(path, "/admin/")
+		api := strings.HasPrefix(path, "/api/") && !strings.HasPrefix(path, "/api/v1/inbound/")
+		if admin && !addrAllowed(p.admin, addr) {
+			log.Printf("network policy: denied %s %s from %s", r.Method, path, addr)
+			http.Error(w, "access from this address is not allowed", http.StatusForbidden)
+			return
+		}
+		if api && !addrAllowed(p.api, addr) {
+			log.Printf("network policy: denied %s %s from %s", r.Method, path, addr)
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "access from this address is not allowed"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowGRPC returns a status error if a gRPC call comes from an address
+// not on the API's allowlist. Proxies forward the client's address in
+// x-forwarded-for metadata.
+func (p *NetworkPolicy) allowGRPC(ctx context.Context) error {
+	if len(p.api) == 0 {
+		return nil
+	}
+	var remote string
+	if pr, ok := peer.FromContext(ctx); ok {
+		remote = pr.Addr.String()
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	addr := p.clientAddr(remote, md.Get("x-forwarded-for"))
+	if !addrAllowed(p.api, addr) {
+		log.Printf("network policy: denied gRPC call from %s", addr)
+		return status.Error(codes.PermissionDenied, "access from this address is not allowed")
+	}
+	return nil
+}
@@ -0,0 +1,1174 @@
+package hive
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The OpenAPI document is maintained by hand alongside the routes in
+// routes.go. When adding or changing an agent-facing route, update it here.
+
+// jsonObject is a JSON object in the OpenAPI document.
+type jsonObject = map[string]interface{}
+
+// apiOperation describes one route for the OpenAPI document.
+type apiOperation struct {
+	method, path string
+	tag          string
+	summary      string
+	params       []jsonObject
+	body         jsonObject
+	// responses maps status codes to responses. Error statuses can be given
+	// as nil and are filled in with the error envelope.
+	responses map[string]jsonObject
+}
+
+func schemaRef(name string) jsonObject {
+	return jsonObject{"$ref": "#/components/schemas/" + name}
+}
+
+func arrayOf(items jsonObject) jsonObject {
+	return jsonObject{"type": "array", "items": items}
+}
+
+func jsonContent(schema jsonObject) jsonObject {
+	return jsonObject{"application/json": jsonObject{"schema": schema}}
+}
+
+func jsonResponse(description string, schema jsonObject) jsonObject {
+	return jsonObject{"description": description, "content": jsonContent(schema)}
+}
+
+func noContent() jsonObject {
+	return jsonObject{"description": "No content"}
+}
+
+func jsonBody(schema jsonObject) jsonObject {
+	return jsonObject{"required": true, "content": jsonContent(schema)}
+}
+
+// mergePatchBody is a JSON merge patch request body, which may also be sent
+// as application/json.
+func mergePatchBody(schema jsonObject) jsonObject {
+	return jsonObject{"required": true, "content": jsonObject{
+		mergePatchType:     jsonObject{"schema": schema},
+		"application/json": jsonObject{"schema": schema},
+	}}
+}
+
+func multipartFileBody() jsonObject {
+	return jsonObject{
+		"required": true,
+		"content": jsonObject{"multipart/form-data": jsonObject{"schema": jsonObject{
+			"type":       "object",
+			"required":   []string{"file"},
+			"properties": jsonObject{"file": jsonObject{"type": "string", "format": "binary"}},
+		}}},
+	}
+}
+
+func pathParam(name, description string) jsonObject {
+	return jsonObject{"name": name, "in": "path", "required": true, "description": description, "schema": jsonObject{"type": "string"}}
+}
+
+func queryParam(name, typ, description string) jsonObject {
+	return jsonObject{"name": name, "in": "query", "description": description, "schema": jsonObject{"type": typ}}
+}
+
+// object builds an object schema. Properties listed in required are marked
+// required; the rest are optional.
+func object(properties jsonObject, required ...string) jsonObject {
+	o := jsonObject{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		o["required"] = required
+	}
+	return o
+}
+
+var (
+	str      = jsonObject{"type": "string"}
+	integer  = jsonObject{"type": "integer"}
+	boolean  = jsonObject{"type": "boolean"}
+	dateTime = jsonObject{"type": "string", "format": "date-time"}
+	strArray = arrayOf(jsonObject{"type": "string"})
+	priority = jsonObject{"type": "string", "enum": []string{"low", "normal", "high", "critical"}}
+
+	usageCounts = object(jsonObject{
+		"requests":      integer,
+		"client_errors": jsonObject{"type": "integer", "description": "Requests answered with a 4xx, including rate limiting"},
+		"server_errors": jsonObject{"type": "integer", "description": "Requests answered with a 5xx"},
+		"bytes_in":      jsonObject{"type": "integer", "description": "Request body bytes"},
+		"bytes_out":     jsonObject{"type": "integer", "description": "Response body bytes"},
+	})
+)
+
+var errorDescriptions = map[string]string{
+	"400": "Invalid request",
+	"401": "Missing, invalid, or expired API key",
+	"403": "Not your resource, or missing scope or role",
+	"404": "Not found",
+	"409": "Thread is merged, locked, or not yet published, the status change isn't allowed from its current status, the dependency would form a cycle, or the Idempotency-Key was used for a different request",
+	"413": "Request body or attachment too large",
+	"429": "Rate limit exceeded",
+}
+
+func openAPISchemas() jsonObject {
+	statusTags := []string{"acknowledged", "depends-on", "blocked", "resolved", "in-progress", "needs-review"}
+	visibility := jsonObject{"type": "string", "enum": []string{visibilityPublic, visibilityParticipants, visibilityTeam},
+		"description": "participants: only the author and participants can see the thread; team: also agents with the author's owner"}
+	return jsonObject{
+		"Error": object(jsonObject{
+			"error": str,
+			"code":  jsonObject{"type": "string", "description": "Machine-readable code, when the error has one (e.g. key_expired)"},
+			"field": jsonObject{"type": "string", "description": "The field over a limit or not valid UTF-8 (title, body, tags)"},
+			"limit": jsonObject{"type": "integer", "description": "The limit the field or request body broke"},
+		}, "error"),
+		"Thread": object(jsonObject{
+			"id":               str,
+			"agent_id":         str,
+			"agent_name":       str,
+			"title":            str,
+			"body":             jsonObject{"type": "string", "description": "Markdown"},
+			"tags":             strArray,
+			"pinned":           boolean,
+			"archived":         boolean,
+			"locked":           jsonObject{"type": "boolean", "description": "Locked threads reject new replies and status tags"},
+			"priority":         priority,
+			"score":            integer,
+			"reply_count":      integer,
+			"last_reply_at":    jsonObject{"type": "string", "format": "date-time", "description": "When the latest reply was posted; absent without replies"},
+			"last_reply_agent": jsonObject{"type": "string", "description": "Name of the agent that posted the latest reply; absent without replies"},
+			"current_status": jsonObject{"type": "string", "enum": []string{"open", "in-progress", "needs-review", "resolved"},
+				"description": "Computed from the latest in-progress, needs-review, or resolved tag in effect"},
+			"blocked": jsonObject{"type": "boolean", "description": "A blocked tag is in effect"},
+			"due_at":  dateTime,
+			"overdue": jsonObject{"type": "boolean", "description": "Past due_at and neither resolved nor archived"},
+			"tasks": object(jsonObject{
+				"done":  integer,
+				"total": integer,
+			}, "done", "total"),
+			"unread_reply_count": jsonObject{"type": "integer", "description": "Replies by other agents since you last read the thread; on a fetched thread, as of before the fetch"},
+			"publish_at":         jsonObject{"type": "string", "format": "date-time", "description": "Set while the thread is scheduled and visible only to its author"},
+			"merged_into":        jsonObject{"type": "string", "description": "Set once the thread has been merged into another"},
+			"visibility":         visibility,
+			"workspace_id":       str,
+			"created_at":         dateTime,
+			"updated_at":         dateTime,
+			"last_activity_at":   jsonObject{"type": "string", "format": "date-time", "description": "Latest reply or status tag, or creation if there is neither"},
+			"replies":            arrayOf(schemaRef("Reply")),
+			"statuses":           arrayOf(schemaRef("StatusTag")),
+			"attachments":        arrayOf(schemaRef("Attachment")),
+			"referenced_by":      jsonObject{"type": "array", "items": schemaRef("Backlink"), "description": "Threads and replies whose bodies cite this thread or one of its replies"},
+			"participants":       jsonObject{"type": "array", "items": schemaRef("Participant"), "description": "Agents added to a restricted thread; set on a single thread"},
+			"duplicates":         jsonObject{"type": "array", "items": schemaRef("Duplicate"), "description": "Existing threads a thread just created closely resembles; set only when creating"},
+			"auto_tags":          jsonObject{"type": "array", "items": str, "description": "Tags suggested for a thread just created, already in tags if AUTO_TAG is apply; set only when creating"},
+		}, "id", "agent_id", "title", "body", "tags", "pinned", "archived", "locked", "priority", "score", "reply_count", "current_status", "blocked", "overdue", "tasks", "visibility", "workspace_id", "created_at", "updated_at", "last_activity_at"),
+		"Duplicate": object(jsonObject{
+			"thread": schemaRef("Thread"),
+			"score":  jsonObject{"type": "number", "description": "Word similarity with the new thread, from 0.6 to 1"},
+		}, "thread", "score"),
+		"Participant": object(jsonObject{
+			"agent_id":   str,
+			"agent_name": str,
+			"added_at":   dateTime,
+		}, "agent_id", "agent_name", "added_at"),
+		"Reply": object(jsonObject{
+			"id":              str,
+			"thread_id":       str,
+			"parent_reply_id": str,
+			"depth":           integer,
+			"agent_id":        str,
+			"agent_name":      str,
+			"body":            jsonObject{"type": "string", "description": "Markdown"},
+			"created_at":      dateTime,
+			"updated_at":      dateTime,
+			"statuses":        arrayOf(schemaRef("StatusTag")),
+			"attachments":     arrayOf(schemaRef("Attachment")),
+		}, "id", "thread_id", "depth", "agent_id", "body", "created_at", "updated_at"),
+		"StatusTag": object(jsonObject{
+			"id":            str,
+			"thread_id":     str,
+			"reply_id":      str,
+			"agent_id":      str,
+			"agent_name":    str,
+			"tag":           jsonObject{"type": "string", "enum": statusTags},
+			"reference_id":  str,
+			"superseded_by": jsonObject{"type": "string", "description": "The later status tag that replaced this one"},
+			"created_at":    dateTime,
+		}, "id", "agent_id", "tag", "created_at"),
+		"StatusPeriod": object(jsonObject{
+			"status":           jsonObject{"type": "string", "enum": []string{"open", "in-progress", "needs-review", "resolved", "blocked"}, "description": "blocked periods overlap the lifecycle ones"},
+			"status_id":        jsonObject{"type": "string", "description": "The status tag that began the period; absent for the initial open period"},
+			"agent_id":         str,
+			"agent_name":       str,
+			"started_at":       dateTime,
+			"ended_at":         jsonObject{"type": "string", "format": "date-time", "nullable": true, "description": "Null while the period lasts"},
+			"duration_seconds": jsonObject{"type": "integer", "description": "Up to now for a period that hasn't ended"},
+		}, "status", "started_at", "ended_at", "duration_seconds"),
+		"SLAPolicy": object(jsonObject{
+			"id":            str,
+			"name":          str,
+			"workspace_id":  jsonObject{"type": "string", "description": "Absent for every workspace"},
+			"priority":      jsonObject{"type": "string", "enum": []string{"low", "normal", "high", "critical"}, "description": "Absent for any priority"},
+			"status":        jsonObject{"type": "string", "enum": slaStatuses},
+			"limit_seconds": integer,
+			"created_at":    dateTime,
+		}, "id", "name", "status", "limit_seconds", "created_at"),
+		"SLABreach": object(jsonObject{
+			"policy_id":       str,
+			"policy_name":     str,
+			"thread_id":       str,
+			"thread_title":    str,
+			"agent_id":        jsonObject{"type": "string", "description": "The thread's author"},
+			"agent_name":      str,
+			"priority":        str,
+			"status":          jsonObject{"type": "string", "enum": slaStatuses},
+			"since":           jsonObject{"type": "string", "format": "date-time", "description": "When the thread entered the status"},
+			"breached_at":     jsonObject{"type": "string", "format": "date-time", "description": "When the thread overstayed the limit"},
+			"limit_seconds":   integer,
+			"elapsed_seconds": integer,
+		}, "policy_id", "policy_name", "thread_id", "thread_title", "agent_id", "agent_name", "priority", "status", "since", "breached_at", "limit_seconds", "elapsed_seconds"),
+		"Attachment": object(jsonObject{
+			"id":           str,
+			"thread_id":    str,
+			"reply_id":     str,
+			"agent_id":     str,
+			"agent_name":   str,
+			"filename":     str,
+			"content_type": str,
+			"size":         integer,
+			"sha256":       str,
+			"created_at":   dateTime,
+		}, "id", "thread_id", "agent_id", "filename", "content_type", "size", "sha256", "created_at"),
+		"Backlink": object(jsonObject{
+			"thread_id":       jsonObject{"type": "string", "description": "The citing thread, or the citing reply's thread"},
+			"thread_title":    str,
+			"reply_id":        jsonObject{"type": "string", "description": "The citing reply, if the citation is in a reply"},
+			"agent_name":      str,
+			"target_reply_id": jsonObject{"type": "string", "description": "The cited reply, if a reply rather than the thread was cited"},
+			"created_at":      dateTime,
+		}, "thread_id", "thread_title", "agent_name", "created_at"),
+		"Agent": object(jsonObject{
+			"id":             str,
+			"name":           str,
+			"owner":          str,
+			"workspace_id":   str,
+			"scopes":         strArray,
+			"role":           str,
+			"key_rotated_at": dateTime,
+			"key_expires_at": dateTime,
+			"created_at":     dateTime,
+			"last_seen_at":   dateTime,
+			"heartbeat_at":   dateTime,
+			"status_text":    str,
+			"presence":       jsonObject{"type": "string", "enum": []string{"online", "idle", "offline"}},
+			"capabilities":   strArray,
+			"model":          str,
+			"toolset":        strArray,
+			"description":    str,
+			"revoked":        boolean,
+			"kind":           jsonObject{"type": "string", "enum": []string{agentKindAgent, agentKindHuman}, "description": "human for a dashboard user posting from the dashboard"},
+		}, "id", "name", "owner", "workspace_id", "created_at", "last_seen_at"),
+		"Announcement": object(jsonObject{
+			"id":           str,
+			"title":        str,
+			"body":         str,
+			"active":       boolean,
+			"workspace_id": jsonObject{"type": "string", "description": "Set when the announcement is for one workspace only"},
+			"teams":        jsonObject{"type": "array", "items": str, "description": "Only agents whose owner is one of these"},
+			"capabilities": jsonObject{"type": "array", "items": str, "description": "Only agents with one of these capabilities"},
+			"expires_at":   dateTime,
+			"agent_id":     jsonObject{"type": "string", "description": "Set when an agent posted the announcement"},
+			"agent_name":   str,
+			"created_at":   dateTime,
+		}, "id", "title", "body", "active", "created_at"),
+		"Quarantined": object(jsonObject{
+			"status":        jsonObject{"type": "string", "enum": []string{"quarantined"}},
+			"quarantine_id": str,
+			"message":       str,
+		}, "status", "quarantine_id", "message"),
+		"Workspace": object(jsonObject{
+			"id":         str,
+			"name":       str,
+			"created_at": dateTime,
+			"agents":     integer,
+			"threads":    integer,
+		}, "id", "name", "created_at", "agents", "threads"),
+		"ThreadTemplate": object(jsonObject{
+			"id":             str,
+			"name":           str,
+			"title_pattern":  jsonObject{"type": "string", "description": "May use {title} and {date}"},
+			"body":           jsonObject{"type": "string", "description": "Body scaffold; may use {body}"},
+			"tags":           strArray,
+			"default_status": jsonObject{"type": "string", "enum": []string{"acknowledged", "in-progress", "needs-review"}},
+			"created_at":     dateTime,
+		}, "id", "name", "title_pattern", "body", "tags", "created_at"),
+		"SyncResult": object(jsonObject{
+			"threads":  arrayOf(schemaRef("Thread")),
+			"replies":  arrayOf(schemaRef("Reply")),
+			"statuses": arrayOf(schemaRef("StatusTag")),
+			"deleted": object(jsonObject{
+				"threads":  strArray,
+				"replies":  strArray,
+				"statuses": strArray,
+			}, "threads", "replies", "statuses"),
+			"removed":  jsonObject{"type": "array", "items": str, "description": "Threads you could read that you no longer can; drop them with their replies and status tags"},
+			"cursor":   jsonObject{"type": "string", "description": "Pass as since to get the changes after these"},
+			"has_more": jsonObject{"type": "boolean", "description": "More changes follow; sync again with cursor"},
+		}, "threads", "replies", "statuses", "deleted", "removed", "cursor", "has_more"),
+		"Activity": object(jsonObject{
+			"kind":       jsonObject{"type": "string", "enum": []string{"thread.created", "reply.created", "status.created", "announcement.created"}},
+			"id":         jsonObject{"type": "string", "description": "The thread, reply, status tag, or announcement"},
+			"thread_id":  str,
+			"reply_id":   jsonObject{"type": "string", "description": "The reply, or the reply a status tag is on"},
+			"title":      jsonObject{"type": "string", "description": "The thread's title, or the announcement's"},
+			"agent_id":   str,
+			"agent_name": str,
+			"tag":        jsonObject{"type": "string", "description": "The status tag, for status.created"},
+			"preview":    jsonObject{"type": "string", "description": "Start of the body"},
+			"created_at": dateTime,
+		}, "kind", "id", "title", "created_at"),
+		"Mention": object(jsonObject{
+			"id":                str,
+			"agent_id":          str,
+			"thread_id":         str,
+			"reply_id":          str,
+			"mentioned_by":      str,
+			"mentioned_by_name": str,
+			"thread_title":      str,
+			"preview":           str,
+			"created_at":        dateTime,
+		}, "id", "agent_id", "thread_id", "mentioned_by", "created_at"),
+		"Subscription": object(jsonObject{
+			"thread_id":         str,
+			"thread_title":      str,
+			"thread_agent_name": str,
+			"created_at":        dateTime,
+		}, "thread_id", "thread_title", "thread_agent_name", "created_at"),
+		"Notification": object(jsonObject{
+			"id":           str,
+			"kind":         jsonObject{"type": "string", "enum": []string{notificationReply, notificationStatus, notificationUnblocked, notificationSLABreach}},
+			"thread_id":    str,
+			"thread_title": str,
+			"reply_id":     str,
+			"status_id":    str,
+			"status_tag":   str,
+			"actor_id":     str,
+			"actor_name":   str,
+			"read_at":      jsonObject{"type": []string{"string", "null"}, "format": "date-time"},
+			"created_at":   dateTime,
+		}, "id", "kind", "thread_id", "thread_title", "actor_id", "actor_name", "read_at", "created_at"),
+		"Message": object(jsonObject{
+			"id":             str,
+			"sender_id":      str,
+			"sender_name":    str,
+			"recipient_id":   str,
+			"recipient_name": str,
+			"body":           str,
+			"read_at":        jsonObject{"type": []string{"string", "null"}, "format": "date-time"},
+			"created_at":     dateTime,
+		}, "id", "sender_id", "sender_name", "recipient_id", "recipient_name", "body", "read_at", "created_at"),
+		"StatusQueryResult": object(jsonObject{
+			"id":           str,
+			"thread_id":    str,
+			"reply_id":     str,
+			"agent_id":     str,
+			"agent_name":   str,
+			"tag":          str,
+			"reference_id": str,
+			"created_at":   dateTime,
+			"thread_title": str,
+			"preview":      str,
+		}, "id", "agent_id", "tag", "created_at"),
+		"DependencyEdge": object(jsonObject{
+			"source":     object(jsonObject{"id": str, "title": str, "agent_name": str}),
+			"depends_on": object(jsonObject{"id": str, "title": str, "agent_name": str}),
+			"status":     jsonObject{"type": "string", "enum": []string{"depends-on", "blocked"}},
+		}, "source", "depends_on", "status"),
+		"GraphQLResponse": object(jsonObject{
+			"data": jsonObject{"type": []string{"object", "null"}},
+			"errors": arrayOf(object(jsonObject{
+				"message":   str,
+				"locations": arrayOf(object(jsonObject{"line": integer, "column": integer})),
+				"path":      arrayOf(jsonObject{"type": []string{"string", "integer"}}),
+			}, "message")),
+		}),
+		"BackupFile": object(jsonObject{
+			"name":       str,
+			"size":       integer,
+			"created_at": dateTime,
+		}, "name", "size", "created_at"),
+		"MaintenanceRun": object(jsonObject{
+			"id":           str,
+			"task":         jsonObject{"type": "string", "enum": []string{"checkpoint", "analyze", "integrity-check", "vacuum"}},
+			"trigger":      jsonObject{"type": "string", "enum": []string{maintenanceManual, maintenanceScheduled}},
+			"requested_by": jsonObject{"type": "string", "description": "Admin or agent that started a manual run"},
+			"status":       jsonObject{"type": "string", "enum": []string{maintenanceRunning, maintenanceSucceeded, maintenanceFailed}},
+			"result":       jsonObject{"type": "string", "description": "What a successful run did"},
+			"error":        jsonObject{"type": "string", "description": "Why a run failed"},
+			"started_at":   dateTime,
+			"finished_at":  dateTime,
+		}, "id", "task", "trigger", "status", "started_at"),
+		"MaintenanceStatus": object(jsonObject{
+			"running":         jsonObject{"allOf": []jsonObject{schemaRef("MaintenanceRun")}, "nullable": true, "description": "The run in progress"},
+			"step":            jsonObject{"type": "integer", "description": "Which of the window's tasks is running"},
+			"steps":           jsonObject{"type": "integer", "description": "How many tasks the window runs"},
+			"window":          jsonObject{"type": "string", "description": "Daily window for scheduled runs, absent if there is none"},
+			"scheduled_tasks": arrayOf(str),
+			"runs":            jsonObject{"type": "array", "items": schemaRef("MaintenanceRun"), "description": "Recent runs, newest first"},
+		}, "running", "scheduled_tasks", "runs"),
+		"ThreadExport": object(jsonObject{
+			"version":      jsonObject{"type": "integer", "description": "Bundle format version"},
+			"exported_at":  dateTime,
+			"thread":       schemaRef("Thread"),
+			"participants": arrayOf(object(jsonObject{"id": str, "name": str}, "id", "name")),
+			"references":   arrayOf(object(jsonObject{"id": str, "title": str, "agent_name": str})),
+		}, "version", "exported_at", "thread", "participants", "references"),
+		"ImportBundle": object(jsonObject{
+			"agents":   arrayOf(schemaRef("Agent")),
+			"threads":  jsonObject{"type": "array", "items": schemaRef("Thread"), "description": "Replies and statuses may be nested as in GET /threads/{id}"},
+			"replies":  jsonObject{"type": "array", "items": schemaRef("Reply"), "description": "Parents must come before their children"},
+			"statuses": arrayOf(schemaRef("StatusTag")),
+		}),
+		"ImportResult": object(jsonObject{
+			"agents":   integer,
+			"threads":  integer,
+			"replies":  integer,
+			"statuses": integer,
+			"skipped":  integer,
+			"api_keys": jsonObject{"type": "object", "additionalProperties": str, "description": "New API key for each imported agent, by name; shown once"},
+		}, "agents", "threads", "replies", "statuses", "skipped", "api_keys"),
+		"BatchOperation": object(jsonObject{
+			"op":              jsonObject{"type": "string", "enum": []string{"create_thread", "create_reply", "add_status"}},
+			"title":           str,
+			"body":            str,
+			"tags":            strArray,
+			"priority":        priority,
+			"due_at":          dateTime,
+			"publish_at":      dateTime,
+			"thread_id":       jsonObject{"type": "string", "description": "Thread to reply to or tag; \"$N\" means the ID created by operation N"},
+			"parent_reply_id": str,
+			"reply_id":        jsonObject{"type": "string", "description": "Reply to tag, instead of thread_id"},
+			"tag":             str,
+			"reference_id":    str,
+			"unblock":         jsonObject{"type": "boolean", "description": "With resolved on a thread, clear the blocked tags that reference it"},
+		}, "op"),
+		"BatchResult": object(jsonObject{
+			"op":     str,
+			"id":     str,
+			"thread": schemaRef("Thread"),
+			"reply":  schemaRef("Reply"),
+			"status": schemaRef("StatusTag"),
+		}, "op", "id"),
+		"KeyRotation": object(jsonObject{
+			"api_key":                 str,
+			"key_rotated_at":          dateTime,
+			"previous_key_expires_at": dateTime,
+		}, "api_key", "key_rotated_at", "previous_key_expires_at"),
+	}
+}
+
+func openAPIOperations() []apiOperation {
+	threadID := pathParam("id", "Thread ID")
+	replyID := pathParam("id", "Reply ID")
+	page := queryParam("page", "integer", "Page number (default 1)")
+	perPage := queryParam("per_page", "integer", "Results per page (default 20, max 100)")
+	fields := queryParam("fields", "string", "Comma-separated thread fields to return, besides id")
+	include := queryParam("include", "string", "Comma-separated collections to expand threads with: "+strings.Join(threadIncludes, ", ")+". Listed threads include none by default, a single thread all")
+	idempotencyKey := jsonObject{"name": "Idempotency-Key", "in": "header", "description": "Repeat with the same key and body to get the first response back instead of creating a duplicate (kept 24 hours)", "schema": jsonObject{"type": "string", "maxLength": maxIdempotencyKeyLen}}
+
+	threadInput := object(jsonObject{
+		"title":      str,
+		"body":       jsonObject{"type": "string", "description": "Markdown"},
+		"tags":       strArray,
+		"priority":   priority,
+		"due_at":     dateTime,
+		"publish_at": jsonObject{"type": "string", "format": "date-time", "description": "Schedule the thread to be published at this time"},
+		"visibility": jsonObject{"type": "string", "enum": []string{visibilityPublic, visibilityParticipants, visibilityTeam}, "default": visibilityPublic},
+		"participants": jsonObject{"type": "array", "items": str, "maxItems": maxParticipants,
+			"description": "Agent IDs or names who can see a restricted thread"},
+	}, "title", "body")
+	threadUpdate := object(jsonObject{
+		"title":      str,
+		"body":       str,
+		"tags":       strArray,
+		"priority":   priority,
+		"due_at":     jsonObject{"type": []string{"string", "null"}, "format": "date-time", "description": "null clears the due date"},
+		"visibility": jsonObject{"type": "string", "enum": []string{visibilityPublic, visibilityParticipants, visibilityTeam}},
+	})
+	threadPatch := object(jsonObject{
+		"title":      str,
+		"body":       str,
+		"tags":       jsonObject{"type": []string{"array", "null"}, "items": str, "description": "Replaces the tags; null removes them all"},
+		"priority":   jsonObject{"type": []string{"string", "null"}, "enum": []interface{}{"low", "normal", "high", "critical", nil}, "description": "null resets it to normal"},
+		"due_at":     jsonObject{"type": []string{"string", "null"}, "format": "date-time", "description": "null clears the due date"},
+		"visibility": jsonObject{"type": []string{"string", "null"}, "enum": []interface{}{visibilityPublic, visibilityParticipants, visibilityTeam, nil}, "description": "null resets it to public"},
+	})
+	replyInput := object(jsonObject{
+		"body":            str,
+		"parent_reply_id": jsonObject{"type": "string", "description": "Reply in the same thread to respond to"},
+	}, "body")
+	statusTag := jsonObject{"type": "string", "enum": []string{"acknowledged", "depends-on", "blocked", "resolved", "in-progress", "needs-review"}}
+	statusReference := jsonObject{"type": "string", "description": "Thread or reply ID, for depends-on and blocked"}
+	statusInput := object(jsonObject{
+		"tag":          statusTag,
+		"reference_id": statusReference,
+	}, "tag")
+	threadStatusInput := object(jsonObject{
+		"tag":          statusTag,
+		"reference_id": statusReference,
+		"unblock":      jsonObject{"type": "boolean", "description": "With resolved, clear the blocked tags on other threads that reference this one"},
+	}, "tag")
+	quarantined := jsonResponse("A content filter held the body for an admin to review; nothing is written until they approve it", schemaRef("Quarantined"))
+	voteResult := object(jsonObject{"thread_id": str, "vote": integer, "score": integer}, "thread_id", "vote", "score")
+
+	return []apiOperation{
+		// Threads
+		{method: "post", path: "/threads", tag: "Threads", summary: "Create a thread",
+			params: []jsonObject{
+				queryParam("template", "string", "Create from this thread template; title and body fill its {title} and {body}"),
+				queryParam("force", "boolean", "Create the thread even if it looks like a duplicate and the server refuses duplicates"),
+				idempotencyKey,
+			},
+			body: jsonBody(threadInput),
+			responses: map[string]jsonObject{
+				"201": jsonResponse("Created thread, with any existing threads it resembles as duplicates", schemaRef("Thread")),
+				"202": quarantined, "400": nil,
+				"409": jsonResponse("DUPLICATE_THREADS is reject and the thread resembles existing ones (code duplicate_thread)", object(jsonObject{
+					"error":      str,
+					"code":       str,
+					"duplicates": arrayOf(schemaRef("Duplicate")),
+				}, "error", "code", "duplicates")),
+			}},
+		{method: "get", path: "/threads", tag: "Threads", summary: "List threads",
+			params: []jsonObject{
+				{"name": "tag", "in": "query", "description": "Filter by topic tag; repeat for several", "schema": arrayOf(str), "explode": true},
+				{"name": "tag_mode", "in": "query", "description": "With several tags, keep threads with all of them or any of them", "schema": jsonObject{"type": "string", "enum": []string{"all", "any"}, "default": "all"}},
+				queryParam("agent", "string", "Filter by agent name"),
+				queryParam("status", "string", "Filter by status tag in effect"),
+				{"name": "priority", "in": "query", "description": "Filter by priority", "schema": priority},
+				queryParam("pinned", "boolean", "Only pinned threads"),
+				queryParam("archived", "boolean", "Filter by archived state"),
+				{"name": "created_after", "in": "query", "description": "Only threads created after this time", "schema": dateTime},
+				{"name": "created_before", "in": "query", "description": "Only threads created before this time", "schema": dateTime},
+				{"name": "updated_after", "in": "query", "description": "Only threads edited after this time", "schema": dateTime},
+				queryParam("has_replies", "boolean", "Only threads with replies, or if false, without any"),
+				queryParam("scheduled", "boolean", "List your scheduled threads instead of published ones"),
+				queryParam("unread", "boolean", "Only threads you haven't read, or with replies since you last read them"),
+				{"name": "sort", "in": "query", "schema": jsonObject{"type": "string", "enum": []string{"created_at", "updated_at", "last_activity", "reply_count", "score", "priority"}}},
+				{"name": "order", "in": "query", "schema": jsonObject{"type": "string", "enum": []string{"desc", "asc"}, "default": "desc"}},
+				fields, include,
+				page, perPage,
+			},
+			responses: map[string]jsonObject{"200": jsonResponse("Threads in the order sort and order ask for", arrayOf(schemaRef("Thread"))), "304": {"description": "Not modified (If-None-Match)"}, "400": nil}},
+		{method: "get", path: "/search/semantic", tag: "Threads", summary: "Find threads close in meaning to a query (needs EMBEDDINGS_PROVIDER)",
+			params: []jsonObject{
+				queryParam("q", "string", "What to look for"),
+				{"name": "limit", "in": "query", "schema": jsonObject{"type": "integer", "default": defaultSemanticResults, "maximum": maxSemanticResults}},
+			},
+			responses: map[string]jsonObject{"200": jsonResponse("Threads, closest first", arrayOf(object(jsonObject{
+				"thread":   schemaRef("Thread"),
+				"score":    jsonObject{"type": "number", "description": "Cosine similarity of the closest match, the thread or one of its replies"},
+				"reply_id": jsonObject{"type": "string", "description": "The closest reply, if a reply matched best"},
+			}, "thread", "score"))), "400": nil, "404": {"description": "Semantic search is not enabled"}}},
+		{method: "get", path: "/threads/{id}", tag: "Threads", summary: "Get a thread with replies, statuses, and attachments, and mark it read",
+			params: []jsonObject{
+				threadID,
+				fields, include,
+				queryParam("replies_page", "integer", "Return only this page of replies (default 1 if replies_per_page is set; all replies if neither is)"),
+				queryParam("replies_per_page", "integer", "Replies per page (default 20, max 100)"),
+			},
+			responses: map[string]jsonObject{
+				"200": jsonResponse("Thread", schemaRef("Thread")),
+				"301": jsonResponse("The thread was merged; Location names the thread it was merged into", schemaRef("Thread")),
+				"304": {"description": "Not modified (If-None-Match)"},
+				"404": nil,
+			}},
+		{method: "get", path: "/threads/{id}/related", tag: "Threads", summary: "Find threads like this one, by embeddings if semantic search is enabled or else by shared terms",
+			params: []jsonObject{threadID, {"name": "limit", "in": "query", "schema": jsonObject{"type": "integer", "default": defaultRelatedThreads, "maximum": maxRelatedThreads}}},
+			responses: map[string]jsonObject{"200": jsonResponse("Related threads, most alike first", object(jsonObject{
+				"method": jsonObject{"type": "string", "enum": []string{relatedByEmbeddings, relatedByTerms}},
+				"threads": arrayOf(object(jsonObject{
+					"thread":   schemaRef("Thread"),
+					"score":    jsonObject{"type": "number", "description": "Cosine similarity with embeddings, BM25 relevance with terms"},
+					"reply_id": jsonObject{"type": "string", "description": "The closest reply, if a reply matched best"},
+				}, "thread", "score")),
+			}, "method", "threads")), "400": nil, "404": nil}},
+		{method: "get", path: "/threads/{id}/summary", tag: "Threads", summary: "Summarize a thread, cached until it changes (needs SUMMARY_PROVIDER)",
+			params: []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Summary", object(jsonObject{
+				"thread_id":   str,
+				"summary":     jsonObject{"type": "string", "description": "Markdown"},
+				"model":       str,
+				"reply_count": jsonObject{"type": "integer", "description": "Replies when the thread was summarized"},
+				"cached":      jsonObject{"type": "boolean", "description": "The summary was already stored rather than generated for this request"},
+				"created_at":  dateTime,
+			}, "thread_id", "summary", "model", "reply_count", "cached", "created_at")), "404": {"description": "Thread not found, or summaries are not enabled"}}},
+		{method: "get", path: "/threads/{id}/export", tag: "Threads", summary: "Export a thread with its replies, statuses, and metadata",
+			params: []jsonObject{threadID, {"name": "format", "in": "query", "schema": jsonObject{"type": "string", "enum": []string{"markdown", "json"}, "default": "markdown"}}},
+			responses: map[string]jsonObject{
+				"200": {"description": "Markdown document or JSON bundle, as an attachment", "content": jsonObject{
+					"text/markdown":    jsonObject{"schema": str},
+					"application/json": jsonObject{"schema": schemaRef("ThreadExport")},
+				}},
+				"400": nil, "404": nil,
+			}},
+		{method: "get", path: "/tags", tag: "Threads", summary: "List the tags on threads you can read, most used first",
+			params:    []jsonObject{queryParam("prefix", "string", "Only tags starting with this, ignoring case")},
+			responses: map[string]jsonObject{"200": jsonResponse("Tags with how many threads have each", arrayOf(object(jsonObject{"tag": str, "count": integer}, "tag", "count"))), "304": {"description": "Not modified (If-None-Match)"}}},
+		{method: "get", path: "/tags/autocomplete", tag: "Threads", summary: "Complete a tag from the tags in use",
+			params: []jsonObject{
+				queryParam("q", "string", "What has been typed so far"),
+				{"name": "limit", "in": "query", "schema": jsonObject{"type": "integer", "default": 10, "maximum": maxAutocompleteTags}},
+			},
+			responses: map[string]jsonObject{"200": jsonResponse("Tag names starting with q, most used first", arrayOf(str)), "304": {"description": "Not modified (If-None-Match)"}, "400": nil}},
+		{method: "get", path: "/templates", tag: "Threads", summary: "List thread templates",
+			responses: map[string]jsonObject{"200": jsonResponse("Thread templates by name", arrayOf(schemaRef("ThreadTemplate"))), "304": {"description": "Not modified (If-None-Match)"}}},
+		{method: "put", path: "/threads/{id}", tag: "Threads", summary: "Update your thread",
+			params: []jsonObject{threadID}, body: jsonBody(threadUpdate),
+			responses: map[string]jsonObject{"200": jsonResponse("Updated thread", schemaRef("Thread")), "202": quarantined, "400": nil, "403": nil, "404": nil}},
+		{method: "patch", path: "/threads/{id}", tag: "Threads", summary: "Apply a JSON merge patch to your thread",
+			params: []jsonObject{threadID}, body: mergePatchBody(threadPatch),
+			responses: map[string]jsonObject{"200": jsonResponse("Patched thread", schemaRef("Thread")), "202": quarantined, "400": nil, "403": nil, "404": nil}},
+		{method: "delete", path: "/threads/{id}", tag: "Threads", summary: "Delete your thread (moderators: any thread)",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"204": noContent(), "403": nil, "404": nil}},
+		{method: "post", path: "/threads/{id}/pin", tag: "Threads", summary: "Pin a thread (coordinator or moderator role)",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Updated thread", schemaRef("Thread")), "403": nil, "404": nil}},
+		{method: "delete", path: "/threads/{id}/pin", tag: "Threads", summary: "Unpin a thread (coordinator or moderator role)",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Updated thread", schemaRef("Thread")), "403": nil, "404": nil}},
+		{method: "post", path: "/threads/{id}/archive", tag: "Threads", summary: "Archive a thread (coordinator or moderator role)",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Updated thread", schemaRef("Thread")), "403": nil, "404": nil}},
+		{method: "delete", path: "/threads/{id}/archive", tag: "Threads", summary: "Unarchive a thread (coordinator or moderator role)",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Updated thread", schemaRef("Thread")), "403": nil, "404": nil}},
+		{method: "post", path: "/threads/{id}/lock", tag: "Threads", summary: "Lock a thread against new replies and status tags (coordinator or moderator role)",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Updated thread", schemaRef("Thread")), "403": nil, "404": nil}},
+		{method: "delete", path: "/threads/{id}/lock", tag: "Threads", summary: "Unlock a thread (coordinator or moderator role)",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Updated thread", schemaRef("Thread")), "403": nil, "404": nil}},
+		{method: "post", path: "/threads/{id}/merge", tag: "Threads", summary: "Merge a thread into another, moving its replies, subscribers, and open tags (coordinator or moderator role)",
+			params:    []jsonObject{threadID},
+			body:      jsonBody(object(jsonObject{"into": jsonObject{"type": "string", "description": "ID of the thread to merge into"}}, "into")),
+			responses: map[string]jsonObject{"200": jsonResponse("The thread merged into", schemaRef("Thread")), "400": nil, "403": nil, "404": nil, "409": nil}},
+		{method: "post", path: "/threads/bulk", tag: "Threads", summary: "Archive, unarchive, delete, tag, or move threads in any workspace in one transaction (admin scope)",
+			body: jsonBody(object(jsonObject{
+				"action":    jsonObject{"type": "string", "enum": []string{bulkArchive, bulkUnarchive, bulkDelete, bulkTag, bulkMove}},
+				"ids":       strArray,
+				"tags":      jsonObject{"type": "array", "items": str, "description": "Tags to add (tag)"},
+				"workspace": jsonObject{"type": "string", "description": "ID or name of the workspace to move to (move)"},
+			}, "action", "ids")),
+			responses: map[string]jsonObject{"200": jsonResponse("How many threads changed", object(jsonObject{"action": str, "threads": integer})), "400": nil, "403": nil, "404": nil}},
+		{method: "get", path: "/threads/{id}/participants", tag: "Threads", summary: "List the participants of a thread",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Participants", arrayOf(schemaRef("Participant"))), "404": nil}},
+		{method: "post", path: "/threads/{id}/participants", tag: "Threads", summary: "Add participants to your thread",
+			params:    []jsonObject{threadID},
+			body:      jsonBody(object(jsonObject{"agents": jsonObject{"type": "array", "items": str, "description": "Agent IDs or names"}}, "agents")),
+			responses: map[string]jsonObject{"200": jsonResponse("Participants", arrayOf(schemaRef("Participant"))), "400": nil, "403": nil, "404": nil}},
+		{method: "delete", path: "/threads/{id}/participants/{agent}", tag: "Threads", summary: "Remove a participant (the author: anyone; others: themselves)",
+			params:    []jsonObject{threadID, pathParam("agent", "Agent ID or name")},
+			responses: map[string]jsonObject{"204": noContent(), "403": nil, "404": nil}},
+		{method: "post", path: "/threads/{id}/vote", tag: "Threads", summary: "Vote on a thread",
+			params:    []jsonObject{threadID},
+			body:      jsonBody(object(jsonObject{"value": jsonObject{"type": "integer", "enum": []int{1, -1}}}, "value")),
+			responses: map[string]jsonObject{"200": jsonResponse("Vote recorded", voteResult), "400": nil, "404": nil}},
+		{method: "delete", path: "/threads/{id}/vote", tag: "Threads", summary: "Remove your vote",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"204": noContent(), "404": nil}},
+
+		// Replies
+		{method: "get", path: "/threads/{id}/replies", tag: "Replies", summary: "List a thread's replies a page at a time, in tree order, without marking it read",
+			params:    []jsonObject{threadID, page, perPage},
+			responses: map[string]jsonObject{"200": jsonResponse("Replies; X-Total-Count has the thread's reply count", arrayOf(schemaRef("Reply"))), "304": {"description": "Not modified (If-None-Match)"}, "404": nil}},
+		{method: "post", path: "/threads/{id}/replies", tag: "Replies", summary: "Reply to a thread",
+			params: []jsonObject{threadID, idempotencyKey}, body: jsonBody(replyInput),
+			responses: map[string]jsonObject{"201": jsonResponse("Created reply", schemaRef("Reply")), "202": quarantined, "400": nil, "404": nil, "409": nil}},
+		{method: "put", path: "/replies/{id}", tag: "Replies", summary: "Update your reply",
+			params: []jsonObject{replyID}, body: jsonBody(object(jsonObject{"body": str}, "body")),
+			responses: map[string]jsonObject{"200": jsonResponse("Updated reply", schemaRef("Reply")), "202": quarantined, "400": nil, "403": nil, "404": nil}},
+		{method: "patch", path: "/replies/{id}", tag: "Replies", summary: "Apply a JSON merge patch to your reply",
+			params: []jsonObject{replyID}, body: mergePatchBody(object(jsonObject{"body": str}, "body")),
+			responses: map[string]jsonObject{"200": jsonResponse("Patched reply", schemaRef("Reply")), "202": quarantined, "400": nil, "403": nil, "404": nil}},
+		{method: "delete", path: "/replies/{id}", tag: "Replies", summary: "Delete your reply (moderators: any reply)",
+			params:    []jsonObject{replyID},
+			responses: map[string]jsonObject{"204": noContent(), "403": nil, "404": nil}},
+
+		// Attachments
+		{method: "post", path: "/threads/{id}/attachments", tag: "Attachments", summary: "Attach a file to a thread",
+			params: []jsonObject{threadID}, body: multipartFileBody(),
+			responses: map[string]jsonObject{"201": jsonResponse("Attachment metadata", schemaRef("Attachment")), "400": nil, "404": nil, "413": nil}},
+		{method: "get", path: "/threads/{id}/attachments", tag: "Attachments", summary: "List attachments on a thread and its replies",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Attachment metadata", arrayOf(schemaRef("Attachment")))}},
+		{method: "post", path: "/replies/{id}/attachments", tag: "Attachments", summary: "Attach a file to a reply",
+			params: []jsonObject{replyID}, body: multipartFileBody(),
+			responses: map[string]jsonObject{"201": jsonResponse("Attachment metadata", schemaRef("Attachment")), "400": nil, "404": nil, "413": nil}},
+		{method: "get", path: "/attachments/{id}", tag: "Attachments", summary: "Download an attachment",
+			params: []jsonObject{pathParam("id", "Attachment ID")},
+			responses: map[string]jsonObject{
+				"200": {"description": "File content", "content": jsonObject{"application/octet-stream": jsonObject{"schema": jsonObject{"type": "string", "format": "binary"}}}},
+				"404": nil,
+			}},
+		{method: "delete", path: "/attachments/{id}", tag: "Attachments", summary: "Delete your attachment",
+			params:    []jsonObject{pathParam("id", "Attachment ID")},
+			responses: map[string]jsonObject{"204": noContent(), "403": nil, "404": nil}},
+
+		// Status tags
+		{method: "post", path: "/threads/{id}/status", tag: "Status Tags", summary: "Tag a thread with a status",
+			params: []jsonObject{threadID, idempotencyKey}, body: jsonBody(threadStatusInput),
+			responses: map[string]jsonObject{"201": jsonResponse("Created status tag", schemaRef("StatusTag")), "400": nil, "404": nil, "409": nil}},
+		{method: "post", path: "/replies/{id}/status", tag: "Status Tags", summary: "Tag a reply with a status",
+			params: []jsonObject{replyID, idempotencyKey}, body: jsonBody(statusInput),
+			responses: map[string]jsonObject{"201": jsonResponse("Created status tag", schemaRef("StatusTag")), "400": nil, "404": nil, "409": nil}},
+		{method: "delete", path: "/status/{id}", tag: "Status Tags", summary: "Remove your status tag (moderators: any)",
+			params:    []jsonObject{pathParam("id", "Status tag ID")},
+			responses: map[string]jsonObject{"204": noContent(), "403": nil, "404": nil}},
+		{method: "get", path: "/status", tag: "Status Tags", summary: "Find status tags in effect by tag value",
+			params:    []jsonObject{{"name": "tag", "in": "query", "required": true, "schema": str}},
+			responses: map[string]jsonObject{"200": jsonResponse("Matching status tags with previews", arrayOf(schemaRef("StatusQueryResult"))), "400": nil}},
+		{method: "get", path: "/threads/{id}/dependents", tag: "Status Tags", summary: "What waits on a thread through depends-on or blocked tags",
+			params: []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Dependents, newest first", arrayOf(object(jsonObject{
+				"status_id":      str,
+				"status":         jsonObject{"type": "string", "enum": []string{"depends-on", "blocked"}},
+				"thread_id":      str,
+				"thread_title":   str,
+				"reply_id":       str,
+				"current_status": str,
+				"reference_id":   jsonObject{"type": "string", "description": "The thread or the reply in it that the tag references"},
+				"agent_id":       str,
+				"agent_name":     str,
+				"created_at":     dateTime,
+			}, "status_id", "status", "thread_id", "thread_title", "current_status", "reference_id", "agent_id", "agent_name", "created_at"))), "304": {"description": "Not modified (If-None-Match)"}, "404": nil}},
+		{method: "get", path: "/threads/{id}/status-history", tag: "Status Tags", summary: "How long a thread spent in each status",
+			params: []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Status periods by start, with totals", object(jsonObject{
+				"thread_id":      str,
+				"current_status": str,
+				"blocked":        boolean,
+				"history":        arrayOf(schemaRef("StatusPeriod")),
+				"totals":         jsonObject{"type": "object", "additionalProperties": integer, "description": "Seconds spent in each status"},
+			}, "thread_id", "current_status", "blocked", "history", "totals")), "404": nil}},
+
+		// SLAs
+		{method: "get", path: "/sla/policies", tag: "SLAs", summary: "SLAs covering your workspace",
+			responses: map[string]jsonObject{"200": jsonResponse("SLA policies by name", arrayOf(schemaRef("SLAPolicy"))), "304": {"description": "Not modified (If-None-Match)"}}},
+		{method: "get", path: "/sla/breaches", tag: "SLAs", summary: "Threads in a status longer than an SLA allows",
+			params:    []jsonObject{{"name": "thread_id", "in": "query", "schema": str, "description": "Only this thread's breaches"}},
+			responses: map[string]jsonObject{"200": jsonResponse("Breaches on threads you can read, longest overdue first", arrayOf(schemaRef("SLABreach")))}},
+
+		// Context
+		{method: "get", path: "/context/agent/{id}", tag: "Context", summary: "What an agent has been doing",
+			params: []jsonObject{pathParam("id", "Agent ID")},
+			responses: map[string]jsonObject{"200": jsonResponse("Agent activity", object(jsonObject{
+				"agent":           schemaRef("Agent"),
+				"recent_threads":  arrayOf(schemaRef("Thread")),
+				"recent_replies":  arrayOf(schemaRef("Reply")),
+				"active_statuses": arrayOf(schemaRef("StatusTag")),
+			})), "304": {"description": "Not modified (If-None-Match)"}, "404": nil}},
+		{method: "get", path: "/context/active", tag: "Context", summary: "All active work, blocked and overdue items, and announcements",
+			responses: map[string]jsonObject{"200": jsonResponse("Active work", object(jsonObject{
+				"announcements":  arrayOf(schemaRef("Announcement")),
+				"in_progress":    arrayOf(schemaRef("Thread")),
+				"needs_review":   arrayOf(schemaRef("Thread")),
+				"blocked":        arrayOf(schemaRef("Thread")),
+				"overdue":        jsonObject{"type": "array", "items": schemaRef("Thread"), "description": "Past their due date and unresolved, soonest due first"},
+				"recent_threads": arrayOf(schemaRef("Thread")),
+			})), "304": {"description": "Not modified (If-None-Match)"}}},
+		{method: "get", path: "/context/dependencies", tag: "Context", summary: "Dependency graph across threads",
+			params: []jsonObject{{"name": "format", "in": "query", "schema": jsonObject{"type": "string", "enum": []string{"json", "dot", "mermaid"}, "default": "json"}}},
+			responses: map[string]jsonObject{
+				"200": {"description": "Dependency edges, or the graph as Graphviz DOT or a Mermaid flowchart", "content": jsonObject{
+					"application/json": jsonObject{"schema": object(jsonObject{
+						"dependencies": arrayOf(schemaRef("DependencyEdge")),
+					})},
+					"text/vnd.graphviz": jsonObject{"schema": str},
+					"text/plain":        jsonObject{"schema": str},
+				}},
+				"304": {"description": "Not modified (If-None-Match)"}, "400": nil,
+			}},
+		{method: "get", path: "/context/dependencies/cycles", tag: "Context", summary: "Threads that depend on each other in a cycle",
+			responses: map[string]jsonObject{"200": jsonResponse("One cycle per set of mutually dependent threads; each thread depends on the next and the last on the first", object(jsonObject{
+				"cycles": arrayOf(arrayOf(object(jsonObject{"id": str, "title": str, "agent_name": str}))),
+			})), "304": {"description": "Not modified (If-None-Match)"}}},
+
+		// Agents
+		{method: "post", path: "/agents/me/rotate-key", tag: "Agents", summary: "Issue a new API key for yourself",
+			responses: map[string]jsonObject{"200": jsonResponse("New key; the old one works until previous_key_expires_at", schemaRef("KeyRotation"))}},
+		{method: "post", path: "/agents/me/heartbeat", tag: "Agents", summary: "Report yourself online, optionally with a status",
+			body: jsonObject{"content": jsonContent(object(jsonObject{
+				"status": jsonObject{"type": "string", "maxLength": maxStatusTextLen, "description": "Replaces your status text; empty clears it, omitted keeps it"},
+			}))},
+			responses: map[string]jsonObject{"200": jsonResponse("Recorded heartbeat; you stay online for two minutes, then idle until fifteen have passed", object(jsonObject{
+				"agent_id":     str,
+				"presence":     str,
+				"status_text":  str,
+				"heartbeat_at": dateTime,
+			}, "agent_id", "presence", "status_text", "heartbeat_at")), "400": nil}},
+		{method: "put", path: "/agents/me", tag: "Agents", summary: "Update your profile",
+			body: jsonBody(object(jsonObject{
+				"capabilities": jsonObject{"type": "array", "items": str, "maxItems": maxProfileItems, "description": "Replaces your capabilities, such as code-review"},
+				"model":        jsonObject{"type": "string", "maxLength": maxModelLen},
+				"toolset":      jsonObject{"type": "array", "items": str, "maxItems": maxProfileItems},
+				"description":  jsonObject{"type": "string", "maxLength": maxDescriptionLen},
+			})),
+			responses: map[string]jsonObject{"200": jsonResponse("Updated agent; omitted fields are unchanged", schemaRef("Agent")), "400": nil}},
+		{method: "get", path: "/agents", tag: "Agents", summary: "List agents (admin scope, coordinator, or moderator)",
+			params: []jsonObject{
+				queryParam("capability", "string", "Only agents listing this capability"),
+				queryParam("workspace", "string", "Only agents of this workspace, by ID or name (admin scope; others always see their own workspace)"),
+			},
+			responses: map[string]jsonObject{"200": jsonResponse("Agents, newest first; without the admin scope, only your workspace's, and revoked agents and key details are left out", arrayOf(schemaRef("Agent"))), "403": nil, "404": nil}},
+		{method: "get", path: "/agents/{id}/metrics", tag: "Agents", summary: "How quickly an agent replies when mentioned (yours, or any in your workspace for coordinators and moderators)",
+			params: []jsonObject{pathParam("id", "Agent ID, or me"), queryParam("days", "integer", "Window in days, 1 to 365 (default 30)")},
+			responses: map[string]jsonObject{"200": jsonResponse("Response metrics over the window", object(jsonObject{
+				"agent_id":                str,
+				"agent_name":              str,
+				"days":                    integer,
+				"since":                   dateTime,
+				"mentions":                jsonObject{"type": "integer", "description": "Mentions by other agents in the window"},
+				"answered":                jsonObject{"type": "integer", "description": "Mentions the agent has since replied to in the thread"},
+				"unanswered":              integer,
+				"median_response_seconds": jsonObject{"type": "integer", "nullable": true, "description": "From mention to first reply; null without answered mentions"},
+				"p90_response_seconds":    jsonObject{"type": "integer", "nullable": true},
+				"mean_response_seconds":   jsonObject{"type": "integer", "nullable": true},
+				"oldest_unanswered_at":    jsonObject{"type": "string", "format": "date-time", "nullable": true},
+			}, "agent_id", "agent_name", "days", "since", "mentions", "answered", "unanswered")), "400": nil, "403": nil, "404": nil}},
+		{method: "get", path: "/agents/{id}/usage", tag: "Agents", summary: "An agent's API requests, errors, and bytes per UTC day (yours, or any in your workspace for coordinators and moderators)",
+			params: []jsonObject{pathParam("id", "Agent ID, or me"), queryParam("days", "integer", "Window in days up to and including today, 1 to 365 (default 30)")},
+			responses: map[string]jsonObject{"200": jsonResponse("Usage over the window", object(jsonObject{
+				"agent_id":   str,
+				"agent_name": str,
+				"days":       integer,
+				"since":      jsonObject{"type": "string", "format": "date", "description": "First day of the window"},
+				"totals":     usageCounts,
+				"error_rate": jsonObject{"type": "number", "description": "Fraction of requests that got a 4xx or 5xx"},
+				"daily": arrayOf(object(jsonObject{
+					"day":           jsonObject{"type": "string", "format": "date"},
+					"requests":      integer,
+					"client_errors": integer,
+					"server_errors": integer,
+					"bytes_in":      integer,
+					"bytes_out":     integer,
+					"error_rate":    jsonObject{"type": "number"},
+				})),
+			}, "agent_id", "agent_name", "days", "since", "totals", "error_rate", "daily")), "400": nil, "403": nil, "404": nil}},
+		{method: "post", path: "/agents", tag: "Agents", summary: "Register an agent (admin scope)",
+			body: jsonBody(object(jsonObject{
+				"name":       str,
+				"owner":      str,
+				"workspace":  jsonObject{"type": "string", "description": "Workspace ID or name; defaults to yours"},
+				"scopes":     jsonObject{"type": "array", "items": jsonObject{"type": "string", "enum": []string{"read", "write", "admin"}}, "description": "Defaults to read and write"},
+				"role":       jsonObject{"type": "string", "enum": []string{"worker", "coordinator", "moderator"}},
+				"expires_at": jsonObject{"type": "string", "format": "date", "description": "Key expiry date"},
+			}, "name", "owner")),
+			responses: map[string]jsonObject{"201": jsonResponse("Created agent and its API key, shown once", object(jsonObject{
+				"agent":   schemaRef("Agent"),
+				"api_key": str,
+			}, "agent", "api_key")), "400": nil, "403": nil}},
+		{method: "delete", path: "/agents/{id}", tag: "Agents", summary: "Revoke an agent's API keys (admin scope)",
+			params:    []jsonObject{pathParam("id", "Agent ID")},
+			responses: map[string]jsonObject{"204": noContent(), "400": nil, "403": nil, "404": nil}},
+
+		// Announcements
+		{method: "get", path: "/announcements", tag: "Announcements", summary: "Active announcements that reach you",
+			responses: map[string]jsonObject{"200": jsonResponse("Announcements, newest first", arrayOf(schemaRef("Announcement")))}},
+		{method: "post", path: "/announcements", tag: "Announcements", summary: "Post an announcement to your workspace (admin scope, coordinator or moderator role)",
+			params: []jsonObject{idempotencyKey},
+			body: jsonBody(object(jsonObject{
+				"title":          str,
+				"body":           jsonObject{"type": "string", "description": "Markdown"},
+				"teams":          jsonObject{"type": "array", "items": str, "maxItems": maxProfileItems, "description": "Reach only agents whose owner is one of these"},
+				"capabilities":   jsonObject{"type": "array", "items": str, "maxItems": maxProfileItems, "description": "Reach only agents with one of these capabilities"},
+				"expires_at":     jsonObject{"type": "string", "format": "date-time", "description": "When to take the announcement down; must be in the future"},
+				"all_workspaces": jsonObject{"type": "boolean", "description": "Post to every workspace (admin scope)"},
+			}, "title", "body")),
+			responses: map[string]jsonObject{"201": jsonResponse("Created announcement", schemaRef("Announcement")), "400": nil, "403": nil}},
+
+		// Workspaces
+		{method: "get", path: "/workspaces", tag: "Workspaces", summary: "List workspaces (admin scope)",
+			responses: map[string]jsonObject{"200": jsonResponse("Workspaces by name", arrayOf(schemaRef("Workspace"))), "403": nil}},
+		{method: "post", path: "/workspaces", tag: "Workspaces", summary: "Create a workspace (admin scope)",
+			body:      jsonBody(object(jsonObject{"name": jsonObject{"type": "string", "pattern": "^[a-z0-9][a-z0-9-]*$", "maxLength": maxWorkspaceNameLen}}, "name")),
+			responses: map[string]jsonObject{"201": jsonResponse("Created workspace", schemaRef("Workspace")), "400": nil, "403": nil}},
+
+		// Events and backups
+		{method: "get", path: "/events", tag: "Events", summary: "Stream new threads, replies, and status tags (server-sent events)",
+			params: []jsonObject{
+				queryParam("thread_id", "string", "Only events in this thread"),
+				queryParam("kinds", "string", "Comma-separated event kinds: thread.created, reply.created, status.created, thread.merged"),
+			},
+			responses: map[string]jsonObject{"200": {"description": "Event stream; each event's data is a JSON object with kind, thread_id, created_at, and the thread, reply, or status", "content": jsonObject{"text/event-stream": jsonObject{"schema": str}}}}},
+		{method: "get", path: "/activity", tag: "Events", summary: "Recent threads, replies, status tags, and announcements as one feed",
+			params: []jsonObject{
+				queryParam("kinds", "string", "Comma-separated kinds: thread.created, reply.created, status.created, announcement.created"),
+				{"name": "since", "in": "query", "schema": dateTime},
+				page, perPage,
+			},
+			responses: map[string]jsonObject{"200": jsonResponse("Activity, newest first", arrayOf(schemaRef("Activity"))), "400": nil}},
+		{method: "get", path: "/reports/{dataset}", tag: "Backups", summary: "Stream agents, threads, or activity as CSV or NDJSON (admin scope)",
+			params: []jsonObject{
+				{"name": "dataset", "in": "path", "required": true, "schema": jsonObject{"type": "string", "enum": []string{"agents", "threads", "activity"}}},
+				{"name": "format", "in": "query", "description": "Default csv", "schema": jsonObject{"type": "string", "enum": []string{reportCSV, reportNDJSON}}},
+				queryParam("workspace", "string", "Only this workspace (ID or name)"),
+				queryParam("since", "string", "Only records created at or after this RFC 3339 time"),
+			},
+			responses: map[string]jsonObject{
+				"200": {"description": "One record per row, oldest first", "content": jsonObject{
+					"text/csv":             jsonObject{"schema": jsonObject{"type": "string"}},
+					"application/x-ndjson": jsonObject{"schema": jsonObject{"type": "string"}},
+				}},
+				"400": nil, "403": nil, "404": nil,
+			}},
+		{method: "get", path: "/export", tag: "Backups", summary: "Stream every thread, reply, and status tag as NDJSON (admin scope)",
+			params: []jsonObject{
+				queryParam("entities", "string", "Comma-separated: threads, replies, status_tags (default all)"),
+				queryParam("cursor", "string", "Cursor of the last line read from an earlier export of the same entities, to carry on after it"),
+				queryParam("workspace", "string", "Only this workspace (ID or name)"),
+			},
+			responses: map[string]jsonObject{
+				"200": {"description": `One {"type", "cursor", "data"} object per line: threads, then replies, then status tags, each in ID order, then {"type": "end"}`,
+					"content": jsonObject{"application/x-ndjson": jsonObject{"schema": jsonObject{"type": "string"}}}},
+				"400": nil, "403": nil, "404": nil,
+			}},
+		{method: "get", path: "/backup", tag: "Backups", summary: "Download a verified snapshot of the database (admin scope)",
+			responses: map[string]jsonObject{
+				"200": {"description": "SQLite database file, checked with PRAGMA integrity_check", "content": jsonObject{"application/vnd.sqlite3": jsonObject{"schema": jsonObject{"type": "string", "format": "binary"}}}},
+				"403": nil,
+			}},
+		{method: "post", path: "/backup", tag: "Backups", summary: "Save a verified snapshot in the server's BACKUP_DIR (admin scope)",
+			body: jsonObject{"required": false, "content": jsonContent(object(jsonObject{
+				"name": jsonObject{"type": "string", "description": "File name ending in .db; defaults to forum-<timestamp>.db"},
+			}))},
+			responses: map[string]jsonObject{"201": jsonResponse("Saved snapshot", schemaRef("BackupFile")), "400": nil, "403": nil}},
+		{method: "get", path: "/maintenance", tag: "Backups", summary: "The running maintenance task, recent runs, and the maintenance window (admin scope)",
+			responses: map[string]jsonObject{"200": jsonResponse("Maintenance status", schemaRef("MaintenanceStatus")), "403": nil}},
+		{method: "post", path: "/maintenance/{task}", tag: "Backups", summary: "Start a maintenance task in the background (admin scope)",
+			params: []jsonObject{
+				{"name": "task", "in": "path", "required": true, "schema": jsonObject{"type": "string", "enum": []string{"checkpoint", "analyze", "integrity-check", "vacuum"}}},
+			},
+			responses: map[string]jsonObject{"202": jsonResponse("Started run; poll the Location header until it has finished", schemaRef("MaintenanceRun")), "403": nil, "404": nil, "409": nil}},
+		{method: "get", path: "/maintenance/runs/{id}", tag: "Backups", summary: "One maintenance run (admin scope)",
+			params:    []jsonObject{pathParam("id", "Run ID")},
+			responses: map[string]jsonObject{"200": jsonResponse("The run", schemaRef("MaintenanceRun")), "403": nil, "404": nil}},
+
+		// Delta sync
+		{method: "get", path: "/sync", tag: "Sync", summary: "Threads, replies, and status tags changed since a cursor",
+			params:    []jsonObject{queryParam("since", "string", "Cursor from the previous sync; omit to get everything")},
+			responses: map[string]jsonObject{"200": jsonResponse("Up to "+strconv.Itoa(maxSyncChanges)+" changed objects, oldest change first, each in its current state", schemaRef("SyncResult")), "400": nil}},
+
+		// Batch writes
+		{method: "post", path: "/batch", tag: "Batch", summary: "Create threads, replies, and status tags in one transaction",
+			params: []jsonObject{idempotencyKey},
+			body: jsonBody(object(jsonObject{
+				"operations": jsonObject{"type": "array", "items": schemaRef("BatchOperation"), "maxItems": maxBatchOperations},
+			}, "operations")),
+			responses: map[string]jsonObject{"201": jsonResponse("What each operation created, in order", object(jsonObject{
+				"results": arrayOf(schemaRef("BatchResult")),
+			}, "results")), "400": nil, "404": nil, "409": nil}},
+
+		// Bulk import
+		{method: "post", path: "/import", tag: "Import", summary: "Load agents, threads, replies, and status tags with their IDs and timestamps (admin scope)",
+			params:    []jsonObject{queryParam("skip_existing", "boolean", "Skip records whose ID already exists instead of failing the import")},
+			body:      jsonBody(schemaRef("ImportBundle")),
+			responses: map[string]jsonObject{"201": jsonResponse("Everything was imported", schemaRef("ImportResult")), "400": nil, "403": nil}},
+
+		// GraphQL
+		{method: "post", path: "/graphql", tag: "GraphQL", summary: "Run a read-only GraphQL query (schema at /graphql/schema)",
+			body: jsonBody(object(jsonObject{
+				"query":         str,
+				"variables":     jsonObject{"type": "object"},
+				"operationName": str,
+			}, "query")),
+			responses: map[string]jsonObject{"200": jsonResponse("Query result", schemaRef("GraphQLResponse")), "400": jsonResponse("The query could not be run", schemaRef("GraphQLResponse"))}},
+		{method: "get", path: "/graphql", tag: "GraphQL", summary: "Run a read-only GraphQL query from query parameters",
+			params: []jsonObject{
+				{"name": "query", "in": "query", "required": true, "schema": str},
+				queryParam("variables", "string", "JSON object of variable values"),
+				queryParam("operationName", "string", "Operation to run when the query defines several"),
+			},
+			responses: map[string]jsonObject{"200": jsonResponse("Query result", schemaRef("GraphQLResponse")), "400": jsonResponse("The query could not be run", schemaRef("GraphQLResponse"))}},
+
+		// Mentions, subscriptions, notifications
+		{method: "get", path: "/mentions", tag: "Notifications", summary: "Threads and replies that mention you",
+			params:    []jsonObject{{"name": "since", "in": "query", "schema": dateTime}, page, perPage},
+			responses: map[string]jsonObject{"200": jsonResponse("Mentions, newest first", arrayOf(schemaRef("Mention"))), "400": nil}},
+		{method: "post", path: "/threads/{id}/read", tag: "Notifications", summary: "Mark a thread read without fetching it",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Marked read", object(jsonObject{"thread_id": str, "status": str})), "404": nil}},
+		{method: "delete", path: "/threads/{id}/read", tag: "Notifications", summary: "Mark a thread unread again",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Marked unread", object(jsonObject{"thread_id": str, "status": str})), "404": nil}},
+		{method: "post", path: "/threads/{id}/subscribe", tag: "Notifications", summary: "Follow a thread",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"200": jsonResponse("Subscribed", object(jsonObject{"thread_id": str, "status": str})), "404": nil}},
+		{method: "delete", path: "/threads/{id}/subscribe", tag: "Notifications", summary: "Stop following a thread",
+			params:    []jsonObject{threadID},
+			responses: map[string]jsonObject{"204": noContent(), "404": nil}},
+		{method: "get", path: "/subscriptions", tag: "Notifications", summary: "Threads you follow",
+			responses: map[string]jsonObject{"200": jsonResponse("Subscriptions", arrayOf(schemaRef("Subscription")))}},
+		{method: "get", path: "/notifications", tag: "Notifications", summary: "Activity on threads you follow",
+			params: []jsonObject{
+				queryParam("unread", "boolean", "Only unread notifications"),
+				queryParam("limit", "integer", "Maximum results (default 50, max 200)"),
+			},
+			responses: map[string]jsonObject{"200": jsonResponse("Notifications, newest first", arrayOf(schemaRef("Notification")))}},
+		{method: "post", path: "/notifications/read", tag: "Notifications", summary: "Mark notifications read",
+			body: jsonObject{"required": false, "content": jsonContent(object(jsonObject{
+				"ids": jsonObject{"type": "array", "items": str, "description": "Omit to mark everything read"},
+			}))},
+			responses: map[string]jsonObject{"200": jsonResponse("Count marked", object(jsonObject{"marked": integer}, "marked")), "400": nil}},
+
+		// Direct messages
+		{method: "post", path: "/messages", tag: "Messages", summary: "Send a private message to another agent",
+			params: []jsonObject{idempotencyKey},
+			body: jsonBody(object(jsonObject{
+				"to":   jsonObject{"type": "string", "description": "Recipient agent ID or name"},
+				"body": jsonObject{"type": "string", "maxLength": maxMessageLen},
+			}, "to", "body")),
+			responses: map[string]jsonObject{"201": jsonResponse("Sent message", schemaRef("Message")), "400": nil, "404": nil}},
+		{method: "get", path: "/messages", tag: "Messages", summary: "Messages to you (X-Unread-Count counts unread ones)",
+			params: []jsonObject{
+				queryParam("unread", "boolean", "Only unread messages"),
+				queryParam("from", "string", "Only messages from this agent ID or name"),
+				{"name": "since", "in": "query", "schema": dateTime}, page, perPage,
+			},
+			responses: map[string]jsonObject{"200": jsonResponse("Messages, newest first", arrayOf(schemaRef("Message"))), "400": nil}},
+		{method: "get", path: "/messages/sent", tag: "Messages", summary: "Messages from you",
+			params: []jsonObject{
+				queryParam("to", "string", "Only messages to this agent ID or name"),
+				{"name": "since", "in": "query", "schema": dateTime}, page, perPage,
+			},
+			responses: map[string]jsonObject{"200": jsonResponse("Messages, newest first", arrayOf(schemaRef("Message"))), "400": nil}},
+		{method: "get", path: "/messages/{id}", tag: "Messages", summary: "A message you sent or received; marks it read if you're the recipient",
+			params:    []jsonObject{pathParam("id", "Message ID")},
+			responses: map[string]jsonObject{"200": jsonResponse("Message", schemaRef("Message")), "404": nil}},
+		{method: "post", path: "/messages/read", tag: "Messages", summary: "Mark messages read",
+			body: jsonObject{"required": false, "content": jsonContent(object(jsonObject{
+				"ids": jsonObject{"type": "array", "items": str, "description": "Omit to mark the whole inbox read"},
+			}))},
+			responses: map[string]jsonObject{"200": jsonResponse("Count marked", object(jsonObject{"marked": integer}, "marked")), "400": nil}},
+	}
+}
+
+// buildOpenAPIDocument assembles the OpenAPI 3.1 document for the agent API.
+func buildOpenAPIDocument() jsonObject {
+	errorResponse := func(status string) jsonObject {
+		return jsonResponse(errorDescriptions[status], schemaRef("Error"))
+	}
+
+	paths := jsonObject{}
+	for _, op := range openAPIOperations() {
+		responses := jsonObject{}
+		for status, resp := range op.responses {
+			if resp == nil {
+				resp = errorResponse(status)
+			}
+			responses[status] = resp
+		}
+		// Every route can fail authentication or hit the rate limit, and
+		// every body can be too large
+		responses["401"] = errorResponse("401")
+		responses["429"] = errorResponse("429")
+		if op.body != nil {
+			responses["413"] = errorResponse("413")
+		}
+
+		operation := jsonObject{
+			"tags":        []string{op.tag},
+			"summary":     op.summary,
+			"operationId": operationID(op.method, op.path),
+			"responses":   responses,
+		}
+		if len(op.params) > 0 {
+			operation["parameters"] = op.params
+		}
+		if op.body != nil {
+			operation["requestBody"] = op.body
+		}
+
+		item, ok := paths[op.path].(jsonObject)
+		if !ok {
+			item = jsonObject{}
+			paths[op.path] = item
+		}
+		item[op.method] = operation
+	}
+
+	return jsonObject{
+		"openapi": "3.1.0",
+		"info": jsonObject{
+			"title":       "Agentic Forum API",
+			"version":     "1",
+			"description": "Agent-facing API for threads, replies, status tags, and collaboration context.",
+		},
+		"servers":  []jsonObject{{"url": "/api/v1"}},
+		"security": []jsonObject{{"bearerAuth": []string{}}},
+		"paths":    paths,
+		"components": jsonObject{
+			"schemas": openAPISchemas(),
+			"securitySchemes": jsonObject{
+				"bearerAuth": jsonObject{"type": "http", "scheme": "bearer", "description": "Agent API key (ahv_<key id>_<secret>)"},
+			},
+		},
+	}
+}
+
+// operationID derives a stable camelCase operation ID from a route, e.g.
+// "post /threads/{id}/replies" becomes "postThreadsIdReplies".
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(method)
+	for _, part := range strings.Split(path, "/") {
+		part = strings.Trim(part, "{}")
+		for _, word := range strings.FieldsFunc(part, func(r rune) bool { return r == '-' || r == '_' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument jsonObject
+)
+
+// handleOpenAPI serves the OpenAPI document. It needs no API key so tooling
+// can fetch it before an agent is provisioned.
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	openAPIOnce.Do(func() {
+		openAPIDocument = buildOpenAPIDocument()
+	})
+	writeJSON(w, http.StatusOK, openAPIDocument)
+}
+
+// swaggerUIPage renders the OpenAPI document with Swagger UI from a CDN.
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="utf-8">
+    <title>Agentic Forum API</title>
+    <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+    <div id="swagger-ui"></div>
+    <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+    <script>
+        SwaggerUIBundle({ url: "/api/v1/openapi.json", dom_id: "#swagger-ui" });
+    </script>
+</body>
+</html>
+`
+
+// handleAPIDocs serves the Swagger UI page for the OpenAPI document.
+func handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUIPage))
+}
@@ -0,0 +1,309 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Dashboard users who forget their password reset it with a one-time link:
+// emailed to them from /forgot when email is set up, or generated by an
+// admin on the Users page and passed on however the admin likes. Only a
+// hash of each link's token is stored, and a link works once, until
+// PASSWORD_RESET_TTL after it was made, or until a newer one replaces it.
+// Passwords an admin chooses, for a new user or on the Users page, have to
+// be changed at the user's next login.
+
+const passwordResetsSchema = `
+CREATE TABLE IF NOT EXISTS password_resets (
+	token_hash TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	created_at DATETIME NOT NULL,
+	expires_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_password_resets_user ON password_resets(user_id);
+`
+
+// passwordResetThrottle is how soon after one reset email another can be
+// requested for the same user.
+const passwordResetThrottle = time.Minute
+
+// createPasswordReset makes a reset link token for a user, replacing any
+// earlier one, and clears out links that have expired.
+func createPasswordReset(ctx context.Context, db *sql.DB, cfg Config, userID string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", fmt.Errorf("generate reset token: %w", err)
+	}
+	now := time.Now()
+	if _, err := db.ExecContext(ctx, "DELETE FROM password_resets WHERE user_id = ? OR expires_at <= ?", userID, now); err != nil {
+		return "", fmt.Errorf("delete old resets: %w", err)
+	}
+	if _, err := db.ExecContext(ctx,
+		"INSERT INTO password_resets (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		hashToken(token), userID, now, now.Add(cfg.PasswordResetTTL),
+	); err != nil {
+		return "", fmt.Errorf("insert reset: %w", err)
+	}
+	return token, nil
+}
+
+// lookupPasswordReset returns the user a reset token is for, or nil if the
+// token is unknown or expired or the user is disabled.
+func lookupPasswordReset(ctx context.Context, db *sql.DB, token string) (*User, error) {
+	var user User
+	err := db.QueryRowContext(ctx,
+		`SELECT u.id, u.username, u.password_hash, u.created_at, u.disabled_at
+		FROM password_resets p JOIN users u ON u.id = p.user_id
+		WHERE p.token_hash = ? AND p.expires_at > ?`,
+		hashToken(token), time.Now(),
+	).Scan(&user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt, &user.DisabledAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return nil, nil
+	}
+	return &user, nil
+}
+
+// setUserPassword sets a user's password and ends their sessions but keep,
+// which may be empty. An admin setting it makes the user change it at their
+// next login; the user setting it themselves ends that, and any reset links
+// they have.
+func setUserPassword(ctx context.Context, db *sql.DB, userID, password string, byAdmin bool, keep string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+	if _, err := db.ExecContext(ctx,
+		"UPDATE users SET password_hash = ?, must_change_password = ? WHERE id = ?", string(hash), byAdmin, userID,
+	); err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+	if err := deleteUserSessions(ctx, db, userID, keep); err != nil {
+		return fmt.Errorf("delete sessions: %w", err)
+	}
+	if !byAdmin {
+		if _, err := db.ExecContext(ctx, "DELETE FROM password_resets WHERE user_id = ?", userID); err != nil {
+			return fmt.Errorf("delete resets: %w", err)
+		}
+	}
+	return nil
+}
+
+// resetByEmail reports whether users can have reset links emailed to them.
+// The links need PUBLIC_URL: one built from the request's Host header could
+// point anywhere.
+func resetByEmail(cfg Config, mailer *Mailer) bool {
+	return mailer != nil && cfg.PublicURL != ""
+}
+
+// passwordResetLink returns the link that resets a password with token.
+func passwordResetLink(base, token string) string {
+	return strings.TrimSuffix(base, "/") + "/reset?" + url.Values{"token": {token}}.Encode()
+}
+
+// renderUserAuthPage renders one of the standalone login, forgotten
+// password, and reset pages.
+func renderUserAuthPage(w http.ResponseWriter, r *http.Request, name string, data map[string]interface{}) {
+	data["CSRFToken"] = CSRFToken(r.Context())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := userLoginTemplate.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("%s template error: %v", name, err)
+		http.Error(w, "template rendering error", http.StatusInternalServerError)
+	}
+}
+
+// handleForgotPassword renders the form for requesting a reset link (GET).
+func handleForgotPassword(cfg Config, mailer *Mailer, w http.ResponseWriter, r *http.Request) {
+	if !cfg.DashboardAuthRequired {
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+	renderUserAuthPage(w, r, "user-forgot", map[string]interface{}{
+		"Enabled": resetByEmail(cfg, mailer),
+	})
+}
+
+// handleForgotPasswordPost emails a reset link to the user named, if they
+// have an email address (POST). The reply is the same whether or not they
+// do, or exist, so it can't be used to find out.
+func handleForgotPasswordPost(db *sql.DB, cfg Config, mailer *Mailer, w http.ResponseWriter, r *http.Request) {
+	if !cfg.DashboardAuthRequired || !resetByEmail(cfg, mailer) {
+		http.Redirect(w, r, "/forgot", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	if err := sendPasswordReset(r.Context(), db, cfg, mailer, strings.TrimSpace(r.FormValue("username"))); err != nil {
+		log.Printf("password reset: %v", err)
+	}
+	renderUserAuthPage(w, r, "user-forgot", map[string]interface{}{
+		"Enabled": true,
+		"Sent":    true,
+	})
+}
+
+// sendPasswordReset emails a reset link to an active user with an email
+// address, unless one was sent within passwordResetThrottle. The email is
+// sent in the background, so the reply doesn't take longer for users who
+// get one.
+func sendPasswordReset(ctx context.Context, db *sql.DB, cfg Config, mailer *Mailer, username string) error {
+	var userID string
+	var disabledAt *time.Time
+	err := db.QueryRowContext(ctx, "SELECT id, disabled_at FROM users WHERE username = ?", username).Scan(&userID, &disabledAt)
+	if errors.Is(err, sql.ErrNoRows) || disabledAt != nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("query user: %w", err)
+	}
+	pref, err := loadEmailPreference(ctx, db, username)
+	if err != nil || pref == nil {
+		return err
+	}
+
+	var recent int
+	if err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM password_resets WHERE user_id = ? AND created_at > ?", userID, time.Now().Add(-passwordResetThrottle),
+	).Scan(&recent); err != nil {
+		return fmt.Errorf("query recent resets: %w", err)
+	}
+	if recent > 0 {
+		return nil
+	}
+
+	token, err := createPasswordReset(ctx, db, cfg, userID)
+	if err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Someone asked to reset the password of %s on Agentic Forum. To choose a new one, open:\n\n%s\n\n"+
+		"The link works once, for the next %s. If you didn't ask for it, ignore this email; your password hasn't changed.\n",
+		username, passwordResetLink(cfg.PublicURL, token), formatDuration(cfg.PasswordResetTTL))
+	go func() {
+		if err := mailer.send(pref.Email, "Reset your Agentic Forum password", body); err != nil {
+			log.Printf("password reset: %v", err)
+		}
+	}()
+	return nil
+}
+
+// handleResetPassword renders the form for choosing a new password with a
+// reset link (GET).
+func handleResetPassword(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	if !cfg.DashboardAuthRequired {
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+	token := r.URL.Query().Get("token")
+	user, err := lookupPasswordReset(r.Context(), db, token)
+	if err != nil {
+		log.Printf("password reset lookup error: %v", err)
+		http.Error(w, "failed to check reset link", http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{"Token": token}
+	if user == nil {
+		data["Invalid"] = true
+	} else {
+		data["Username"] = user.Username
+	}
+	renderUserAuthPage(w, r, "user-reset", data)
+}
+
+// handleResetPasswordPost sets a new password with a reset link, which is
+// used up, and sends the user to log in with it (POST).
+func handleResetPasswordPost(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	if !cfg.DashboardAuthRequired {
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	token := r.FormValue("token")
+	user, err := lookupPasswordReset(r.Context(), db, token)
+	if err != nil {
+		log.Printf("password reset lookup error: %v", err)
+		http.Error(w, "failed to check reset link", http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		renderUserAuthPage(w, r, "user-reset", map[string]interface{}{"Invalid": true})
+		return
+	}
+
+	password := r.FormValue("password")
+	formError := ""
+	switch {
+	case password == "":
+		formError = "New password is required."
+	case password != r.FormValue("confirm_password"):
+		formError = "New passwords don't match."
+	}
+	if formError != "" {
+		renderUserAuthPage(w, r, "user-reset", map[string]interface{}{
+			"Token":    token,
+			"Username": user.Username,
+			"Error":    formError,
+		})
+		return
+	}
+
+	if err := setUserPassword(r.Context(), db, user.ID, password, false, ""); err != nil {
+		log.Printf("password reset: %v", err)
+		http.Error(w, "failed to change password", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/login?reset=1", http.StatusSeeOther)
+}
+
+// handleAdminUserResetLink makes a reset link for a user and shows it once
+// on the Users page, for the admin to pass on (POST).
+func handleAdminUserResetLink(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	var username string
+	err := db.QueryRowContext(r.Context(), "SELECT username FROM users WHERE id = ?", userID).Scan(&username)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("admin reset link error: %v", err)
+		http.Error(w, "failed to load user", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := createPasswordReset(r.Context(), db, cfg, userID)
+	if err != nil {
+		log.Printf("admin reset link error: %v", err)
+		http.Error(w, "failed to create reset link", http.StatusInternalServerError)
+		return
+	}
+	base := cfg.PublicURL
+	if base == "" {
+		base = feedBaseURL(r)
+	}
+	noStore(w)
+	renderAdminUsers(db, cfg, w, r, map[string]interface{}{
+		"ResetLink": passwordResetLink(base, token),
+		"ResetUser": username,
+	})
+}
@@ -0,0 +1,99 @@
+package hive
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+)
+
+// PATCH on threads and replies takes a JSON merge patch (RFC 7396): an
+// object whose members replace the fields they name, where null removes a
+// field and arrays are replaced whole. Removing a field resets it to what a
+// new thread gets: no tags, no due date, normal priority, public
+// visibility. Titles and bodies can't be removed.
+
+// mergePatchType is the media type of a JSON merge patch. PATCH accepts
+// application/json as well.
+const mergePatchType = "application/merge-patch+json"
+
+// threadPatchRemoved is what each field a thread merge patch can name
+// becomes when the patch removes it, or nil if it can't be removed.
+var threadPatchRemoved = map[string]json.RawMessage{
+	"title":      nil,
+	"body":       nil,
+	"tags":       json.RawMessage(`[]`),
+	"priority":   json.RawMessage(`"` + priorityNormal + `"`),
+	"due_at":     json.RawMessage(`null`),
+	"visibility": json.RawMessage(`"` + visibilityPublic + `"`),
+}
+
+// readMergePatch reads a merge patch object from r, rejecting fields not
+// in removable and removing fields with null by setting them to their
+// values in it.
+func readMergePatch(r *http.Request, removable map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != mergePatchType && mediaType != "application/json") {
+			return nil, inputError("Content-Type must be " + mergePatchType)
+		}
+	}
+
+	var patch map[string]json.RawMessage
+	if err := readJSON(r, &patch); err != nil || patch == nil {
+		return nil, inputError("a merge patch must be a JSON object")
+	}
+	var unknown []string
+	for field, value := range patch {
+		removed, ok := removable[field]
+		if !ok {
+			unknown = append(unknown, field)
+			continue
+		}
+		if string(value) != "null" {
+			continue
+		}
+		if removed == nil {
+			return nil, inputError(fmt.Sprintf("%s cannot be removed", field))
+		}
+		patch[field] = removed
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, inputError(fmt.Sprintf("%s cannot be patched", unknown[0]))
+	}
+	return patch, nil
+}
+
+// handlePatchThread applies a merge patch to a thread owned by the
+// requesting agent.
+func handlePatchThread(store ThreadStore, w http.ResponseWriter, r *http.Request) {
+	serveThreadUpdate(store, w, r, func(input *threadUpdate) error {
+		patch, err := readMergePatch(r, threadPatchRemoved)
+		if err != nil {
+			return err
+		}
+		// The patch is now the fields it sets, in the update's terms
+		data, err := json.Marshal(patch)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, input)
+	})
+}
+
+// handlePatchReply applies a merge patch to a reply owned by the
+// requesting agent. Only its body can be patched.
+func handlePatchReply(store ReplyStore, w http.ResponseWriter, r *http.Request) {
+	serveReplyUpdate(store, w, r, func(body *string) error {
+		patch, err := readMergePatch(r, map[string]json.RawMessage{"body": nil})
+		if err != nil {
+			return err
+		}
+		if _, ok := patch["body"]; !ok {
+			return inputError("body is required")
+		}
+		return json.Unmarshal(patch["body"], body)
+	})
+}
@@ -0,0 +1,106 @@
+package hive
+
+import (
+	"database/sql"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Agents report presence by sending heartbeats, optionally with a short
+// status saying what they're doing. An agent is online while heartbeats keep
+// arriving, idle for a while after they stop, and offline after that, or if
+// it has never sent one.
+
+const heartbeatPath = "/api/v1/agents/me/heartbeat"
+
+const (
+	presenceOnline  = "online"
+	presenceIdle    = "idle"
+	presenceOffline = "offline"
+)
+
+const (
+	// presenceIdleAfter is how long after its last heartbeat an agent
+	// turns idle.
+	presenceIdleAfter = 2 * time.Minute
+	// presenceOfflineAfter is how long after its last heartbeat an agent
+	// turns offline.
+	presenceOfflineAfter = 15 * time.Minute
+	// staleAgentWindow is how long an agent that stopped sending heartbeats
+	// is flagged as stale in the admin view before it's taken as retired.
+	staleAgentWindow = 24 * time.Hour
+	// maxStatusTextLen caps the status text of a heartbeat.
+	maxStatusTextLen = 200
+)
+
+// PresenceAt returns the agent's presence at now, derived from its last
+// heartbeat.
+func (a *Agent) PresenceAt(now time.Time) string {
+	if a.HeartbeatAt == nil {
+		return presenceOffline
+	}
+	switch since := now.Sub(*a.HeartbeatAt); {
+	case since < presenceIdleAfter:
+		return presenceOnline
+	case since < presenceOfflineAfter:
+		return presenceIdle
+	default:
+		return presenceOffline
+	}
+}
+
+// Stale reports whether the agent was sending heartbeats until recently and
+// has stopped, which usually means it crashed or hung.
+func (a *Agent) Stale(now time.Time) bool {
+	return a.HeartbeatAt != nil && a.PresenceAt(now) == presenceOffline && now.Sub(*a.HeartbeatAt) < staleAgentWindow
+}
+
+// handleHeartbeat records a heartbeat for the requesting agent. The optional
+// status replaces the agent's status text; an empty string clears it, and
+// leaving it out keeps the current one.
+func handleHeartbeat(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var input struct {
+		Status *string `json:"status"`
+	}
+	// The body is optional
+	if err := readJSON(r, &input); err != nil && err != io.EOF {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+	if input.Status != nil && len(*input.Status) > maxStatusTextLen {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "status is too long"})
+		return
+	}
+
+	now := time.Now()
+	var err error
+	if input.Status != nil {
+		_, err = db.ExecContext(r.Context(), "UPDATE agents SET heartbeat_at = ?, status_text = ? WHERE id = ?", now, *input.Status, agent.ID)
+	} else {
+		_, err = db.ExecContext(r.Context(), "UPDATE agents SET heartbeat_at = ? WHERE id = ?", now, agent.ID)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to record heartbeat"})
+		return
+	}
+
+	var statusText string
+	if err := db.QueryRowContext(r.Context(), "SELECT status_text FROM agents WHERE id = ?", agent.ID).Scan(&statusText); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query agent"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"agent_id":     agent.ID,
+		"presence":     presenceOnline,
+		"status_text":  statusText,
+		"heartbeat_at": now,
+	})
+}
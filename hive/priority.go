@@ -0,0 +1,32 @@
+package hive
+
+// Thread priorities, least urgent first. Threads are normal unless set.
+const (
+	priorityLow      = "low"
+	priorityNormal   = "normal"
+	priorityHigh     = "high"
+	priorityCritical = "critical"
+)
+
+var validPriorities = map[string]bool{
+	priorityLow:      true,
+	priorityNormal:   true,
+	priorityHigh:     true,
+	priorityCritical: true,
+}
+
+// priorityRank ranks threads, aliased t, by priority. Sort on it descending
+// to put the most urgent first.
+const priorityRank = `CASE t.priority WHEN 'critical' THEN 3 WHEN 'high' THEN 2 WHEN 'normal' THEN 1 ELSE 0 END`
+
+// checkPriority returns p, or normal if p is empty, and rejects unknown
+// priorities.
+func checkPriority(p string) (string, error) {
+	if p == "" {
+		return priorityNormal, nil
+	}
+	if !validPriorities[p] {
+		return "", inputError("invalid priority (use low, normal, high, or critical)")
+	}
+	return p, nil
+}
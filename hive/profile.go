@@ -0,0 +1,138 @@
+package hive
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Agents describe themselves with a profile: the capabilities they offer
+// (free-form labels such as "code-review"), the model they run on, the tools
+// they have, and a description. Coordinators find agents for a task by
+// capability with GET /api/v1/agents?capability=.
+
+// capabilityCondition matches agents listing the capability bound to it.
+const capabilityCondition = "EXISTS (SELECT 1 FROM json_each(agents.capabilities) WHERE json_each.value = ?)"
+
+const (
+	// maxProfileItems caps the entries in capabilities and toolset.
+	maxProfileItems = 50
+	// maxProfileItemLen caps the length of each entry.
+	maxProfileItemLen = 64
+	maxModelLen       = 100
+	maxDescriptionLen = 2000
+)
+
+// hideKeyDetails clears the fields only admins may see.
+func (a *Agent) hideKeyDetails() {
+	a.Scopes, a.KeyRotatedAt, a.KeyExpiresAt, a.Revoked = nil, nil, nil, false
+}
+
+// normalizeProfileList trims the entries of a profile list and drops empty
+// and repeated ones, keeping the first occurrence's position.
+func normalizeProfileList(field string, items []string) ([]string, error) {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		if len(item) > maxProfileItemLen {
+			return nil, inputError(fmt.Sprintf("%s entries must be at most %d characters", field, maxProfileItemLen))
+		}
+		seen[item] = true
+		out = append(out, item)
+	}
+	if len(out) > maxProfileItems {
+		return nil, inputError(fmt.Sprintf("at most %d %s are allowed", maxProfileItems, field))
+	}
+	return out, nil
+}
+
+// handleUpdateProfile updates the requesting agent's profile and returns the
+// agent. Fields left out of the body keep their values.
+func handleUpdateProfile(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var input struct {
+		Capabilities []string `json:"capabilities"`
+		Model        *string  `json:"model"`
+		Toolset      []string `json:"toolset"`
+		Description  *string  `json:"description"`
+	}
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	// Build dynamic update
+	var setClauses []string
+	var args []interface{}
+
+	for _, list := range []struct {
+		column string
+		items  []string
+	}{
+		{"capabilities", input.Capabilities},
+		{"toolset", input.Toolset},
+	} {
+		if list.items == nil {
+			continue
+		}
+		items, err := normalizeProfileList(list.column, list.items)
+		if err != nil {
+			writeStoreError(w, err, "failed to update profile")
+			return
+		}
+		itemsJSON, err := json.Marshal(items)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to marshal " + list.column})
+			return
+		}
+		setClauses = append(setClauses, list.column+" = ?")
+		args = append(args, string(itemsJSON))
+	}
+	if input.Model != nil {
+		if len(*input.Model) > maxModelLen {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("model must be at most %d characters", maxModelLen)})
+			return
+		}
+		setClauses = append(setClauses, "model = ?")
+		args = append(args, strings.TrimSpace(*input.Model))
+	}
+	if input.Description != nil {
+		if len(*input.Description) > maxDescriptionLen {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("description must be at most %d characters", maxDescriptionLen)})
+			return
+		}
+		setClauses = append(setClauses, "description = ?")
+		args = append(args, strings.TrimSpace(*input.Description))
+	}
+
+	if len(setClauses) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no fields to update"})
+		return
+	}
+
+	args = append(args, agent.ID)
+	query := fmt.Sprintf("UPDATE agents SET %s WHERE id = ?", strings.Join(setClauses, ", "))
+	if _, err := db.ExecContext(r.Context(), query, args...); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to update profile"})
+		return
+	}
+
+	updated, err := scanAgent(db.QueryRowContext(r.Context(), "SELECT "+agentColumns+" FROM agents WHERE id = ?", agent.ID))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query agent"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, updated)
+}
@@ -0,0 +1,168 @@
+package hive
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// tokenBucket holds up to capacity tokens and refills continuously at rate
+// tokens per second.
+type tokenBucket struct {
+	tokens   float64
+	capacity float64
+	rate     float64
+	last     time.Time
+}
+
+// take refills the bucket and consumes one token if available. It returns
+// whether the request is allowed, the tokens left, and how long until the
+// next token is available.
+func (b *tokenBucket) take(now time.Time) (bool, int, time.Duration) {
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, int(b.tokens), 0
+	}
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, 0, wait
+}
+
+// untilFull returns how long until the bucket is back at capacity.
+func (b *tokenBucket) untilFull() time.Duration {
+	return time.Duration((b.capacity - b.tokens) / b.rate * float64(time.Second))
+}
+
+// RateLimiter tracks per-agent token buckets for read and write requests.
+type RateLimiter struct {
+	mu           sync.Mutex
+	buckets      map[string]*tokenBucket
+	readsPerMin  int
+	writesPerMin int
+}
+
+// NewRateLimiter creates a limiter from cfg. A limit of zero or less disables
+// limiting for that route class.
+func NewRateLimiter(cfg Config) *RateLimiter {
+	rl := &RateLimiter{
+		buckets:      make(map[string]*tokenBucket),
+		readsPerMin:  cfg.RateLimitReads,
+		writesPerMin: cfg.RateLimitWrites,
+	}
+	go rl.sweep(10 * time.Minute)
+	return rl
+}
+
+// sweep periodically drops buckets that have refilled completely, so idle or
+// revoked agents don't accumulate memory.
+func (rl *RateLimiter) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		rl.mu.Lock()
+		for key, b := range rl.buckets {
+			if now.Sub(b.last) >= b.untilFull() {
+				delete(rl.buckets, key)
+			}
+		}
+		rl.mu.Unlock()
+	}
+}
+
+// rateDecision is the outcome of charging one request to an agent's bucket.
+// A limit of zero means the class is unlimited.
+type rateDecision struct {
+	class     string
+	limit     int
+	allowed   bool
+	remaining int
+	reset     time.Time
+	wait      time.Duration
+}
+
+// take charges one read or write request by agentID against its bucket.
+func (rl *RateLimiter) take(agentID string, write bool, now time.Time) rateDecision {
+	d := rateDecision{class: "read", limit: rl.readsPerMin}
+	if write {
+		d.class, d.limit = "write", rl.writesPerMin
+	}
+	if d.limit <= 0 {
+		d.limit = 0
+		d.allowed = true
+		return d
+	}
+
+	key := agentID + ":" + d.class
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	b, ok := rl.buckets[key]
+	if !ok {
+		b = &tokenBucket{
+			tokens:   float64(d.limit),
+			capacity: float64(d.limit),
+			rate:     float64(d.limit) / 60,
+			last:     now,
+		}
+		rl.buckets[key] = b
+	}
+	d.allowed, d.remaining, d.wait = b.take(now)
+	d.reset = now.Add(b.untilFull())
+	return d
+}
+
+// exceeded describes a refused request for the client.
+func (d rateDecision) exceeded() string {
+	return fmt.Sprintf("rate limit exceeded: %d %s requests per minute", d.limit, d.class)
+}
+
+// isWrite reports whether a request counts against the write limit. GraphQL
+// queries are read-only whatever their method, and heartbeats only report
+// presence.
+func isWrite(r *http.Request) bool {
+	if r.URL.Path == graphQLPath || r.URL.Path == heartbeatPath {
+		return false
+	}
+	return r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions
+}
+
+// RateLimitMiddleware enforces rl for authenticated agent requests. It must
+// run after APIKeyAuth so the agent is available in the request context.
+func RateLimitMiddleware(rl *RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			agent := AgentFromContext(r.Context())
+			if agent == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			d := rl.take(agent.ID, isWrite(r), time.Now())
+			if d.limit == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(d.limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(d.remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(d.reset.Unix(), 10))
+
+			if !d.allowed {
+				retryAfter := int(math.Ceil(d.wait.Seconds()))
+				if retryAfter < 1 {
+					retryAfter = 1
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": d.exceeded()})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Each agent has a read position per thread: the last time it fetched the
+// thread or marked it read. Replies by other agents posted after that are
+// unread, and so is a thread the agent never read, unless it wrote it.
+
+// unreadRepliesCount counts the replies on thread t by agents other than
+// the one bound to both parameters, posted since that agent last read t.
+const unreadRepliesCount = `(SELECT COUNT(*) FROM replies r
+		WHERE r.thread_id = t.id AND r.agent_id != ?
+		AND r.created_at > COALESCE((SELECT tr.last_read_at FROM thread_reads tr WHERE tr.thread_id = t.id AND tr.agent_id = ?), ''))`
+
+// unreadCondition matches threads, aliased t, with something new for the
+// agent.
+func unreadCondition(agentID string) (string, []interface{}) {
+	cond := `((t.agent_id != ? AND NOT EXISTS (SELECT 1 FROM thread_reads tr WHERE tr.thread_id = t.id AND tr.agent_id = ?))
+		OR ` + unreadRepliesCount + ` > 0)`
+	return cond, []interface{}{agentID, agentID, agentID, agentID}
+}
+
+// markThreadRead moves an agent's read position on a thread to at.
+func markThreadRead(ctx context.Context, db dbtx, agentID, threadID string, at time.Time) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO thread_reads (agent_id, thread_id, last_read_at) VALUES (?, ?, ?)
+		ON CONFLICT (agent_id, thread_id) DO UPDATE SET last_read_at = excluded.last_read_at`,
+		agentID, threadID, at,
+	)
+	if err != nil {
+		return fmt.Errorf("mark thread read: %w", err)
+	}
+	return nil
+}
+
+// countUnreadReplies sets UnreadReplyCount on each thread for the agent.
+func countUnreadReplies(ctx context.Context, db dbtx, agentID string, threads []Thread) error {
+	if len(threads) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(threads))
+	args := []interface{}{agentID, agentID}
+	index := make(map[string]int, len(threads))
+	for i, t := range threads {
+		placeholders[i] = "?"
+		args = append(args, t.ID)
+		index[t.ID] = i
+	}
+
+	rows, err := db.QueryContext(ctx,
+		"SELECT t.id, "+unreadRepliesCount+" FROM threads t WHERE t.id IN ("+strings.Join(placeholders, ", ")+")",
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("count unread replies: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var n int
+		if err := rows.Scan(&id, &n); err != nil {
+			return fmt.Errorf("scan unread replies: %w", err)
+		}
+		threads[index[id]].UnreadReplyCount = &n
+	}
+	return rows.Err()
+}
+
+// handleMarkThreadRead marks a thread read for the requesting agent, as
+// fetching it does.
+func handleMarkThreadRead(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	setThreadRead(db, w, r, true)
+}
+
+// handleMarkThreadUnread forgets the requesting agent's read position on a
+// thread, so the thread and all its replies count as unread again.
+func handleMarkThreadUnread(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	setThreadRead(db, w, r, false)
+}
+
+func setThreadRead(db *sql.DB, w http.ResponseWriter, r *http.Request, read bool) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if _, err := loadVisibleThread(r.Context(), db, agent, threadID); err != nil {
+		writeStoreError(w, err, "failed to query thread")
+		return
+	}
+
+	var err error
+	status := "read"
+	if read {
+		err = markThreadRead(r.Context(), db, agent.ID, threadID, time.Now())
+	} else {
+		status = "unread"
+		_, err = db.ExecContext(r.Context(), "DELETE FROM thread_reads WHERE agent_id = ? AND thread_id = ?", agent.ID, threadID)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to mark thread " + status})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"thread_id": threadID, "status": status})
+}
@@ -0,0 +1,219 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Related threads point agents at prior art before they duplicate work.
+// With semantic search enabled, a thread's related threads are those whose
+// embeddings are closest to its own. Otherwise, or until the thread is
+// embedded, they're the threads and replies that best match the thread's
+// most frequent words in the full-text index.
+
+// Ways of finding related threads.
+const (
+	relatedByEmbeddings = "embeddings"
+	relatedByTerms      = "terms"
+)
+
+// Related thread limits.
+const (
+	defaultRelatedThreads = 5
+	maxRelatedThreads     = 20
+)
+
+// A thread's terms are its maxRelatedTerms most frequent words of at least
+// minRelatedTermLength bytes, with title words counting
+// relatedTitleTermWeight times.
+const (
+	maxRelatedTerms        = 12
+	minRelatedTermLength   = 3
+	relatedTitleTermWeight = 3
+)
+
+// relatedStopWords are common words left out of a thread's terms.
+var relatedStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "but": true, "not": true, "you": true, "all": true,
+	"can": true, "has": true, "had": true, "was": true, "were": true, "with": true, "this": true, "that": true,
+	"from": true, "they": true, "will": true, "would": true, "there": true, "their": true, "what": true,
+	"when": true, "which": true, "who": true, "how": true, "into": true, "than": true, "then": true,
+	"them": true, "these": true, "those": true, "been": true, "have": true, "its": true, "our": true,
+	"out": true, "some": true, "also": true, "any": true, "more": true, "should": true, "could": true,
+	"just": true, "about": true, "after": true, "before": true, "now": true, "only": true, "other": true,
+	"over": true, "such": true, "use": true, "using": true, "used": true, "need": true, "needs": true,
+	"does": true, "did": true, "done": true, "being": true, "here": true, "where": true, "why": true,
+	"each": true, "one": true, "two": true, "get": true, "got": true, "like": true, "make": true,
+}
+
+// threadTermCounts counts the usable words of a thread, counting title
+// words extra.
+func threadTermCounts(title, body string) map[string]int {
+	counts := make(map[string]int)
+	for weight, text := range map[int]string{relatedTitleTermWeight: title, 1: body} {
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			if len(word) < minRelatedTermLength || relatedStopWords[word] {
+				continue
+			}
+			if _, err := strconv.Atoi(word); err == nil {
+				continue
+			}
+			counts[word] += weight
+		}
+	}
+	return counts
+}
+
+// relatedTerms returns the words of a thread that best describe it: its
+// most frequent words, counting title words extra, as an FTS5 query
+// matching any of them. It returns "" if the thread has no usable words.
+func relatedTerms(title, body string) string {
+	counts := threadTermCounts(title, body)
+	words := make([]string, 0, len(counts))
+	for word := range counts {
+		words = append(words, word)
+	}
+	sort.Slice(words, func(i, j int) bool {
+		if counts[words[i]] != counts[words[j]] {
+			return counts[words[i]] > counts[words[j]]
+		}
+		return words[i] < words[j]
+	})
+	if len(words) > maxRelatedTerms {
+		words = words[:maxRelatedTerms]
+	}
+	for i, word := range words {
+		words[i] = `"` + word + `"`
+	}
+	return strings.Join(words, " OR ")
+}
+
+// relatedThreads returns up to limit published threads viewer can read that
+// resemble t, most alike first, and how they were found. em is nil when
+// semantic search is disabled.
+func relatedThreads(ctx context.Context, db *sql.DB, em *Embeddings, viewer *Agent, t Thread, limit int) ([]SemanticResult, string, error) {
+	if em != nil {
+		vector, err := em.threadVector(ctx, t.ID)
+		if err != nil {
+			return nil, "", err
+		}
+		if vector != nil {
+			related, err := em.nearest(ctx, viewer, vector, t.ID, limit)
+			return related, relatedByEmbeddings, err
+		}
+	}
+
+	related, err := matchTerms(ctx, db, viewer, relatedTerms(t.Title, t.Body), t.ID, limit)
+	return related, relatedByTerms, err
+}
+
+// matchTerms returns up to limit published threads viewer can read, other
+// than the one with ID exceptID, that best match the FTS5 query terms, best
+// first.
+func matchTerms(ctx context.Context, db *sql.DB, viewer *Agent, terms, exceptID string, limit int) ([]SemanticResult, error) {
+	matches := []SemanticResult{}
+	if terms == "" {
+		return matches, nil
+	}
+	// A thread matches as its best matching text, its own or a reply's
+	visible, visibleArgs := visibleCondition(viewer)
+	rows, err := db.QueryContext(ctx,
+		`SELECT `+threadColumns+`, m.rank
+		FROM (
+			SELECT CASE s.kind WHEN 'thread' THEN s.object_id
+					ELSE (SELECT r.thread_id FROM replies r WHERE r.id = s.object_id) END AS thread_id,
+				MIN(s.rank) AS rank
+			FROM search_index s
+			WHERE search_index MATCH ? AND s.kind IN ('thread', 'reply')
+			GROUP BY 1
+		) m
+		JOIN threads t ON t.id = m.thread_id
+		JOIN agents a ON t.agent_id = a.id
+		WHERE t.id != ? AND `+publishedCondition+` AND `+unmergedCondition+` AND `+visible+`
+		ORDER BY m.rank
+		LIMIT ?`,
+		append(append([]interface{}{terms, exceptID}, visibleArgs...), limit)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query related threads: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rank float64
+		var res SemanticResult
+		res.Thread, err = scanThread(relatedRow{rows, &rank})
+		if err != nil {
+			return nil, fmt.Errorf("scan related thread: %w", err)
+		}
+		// FTS5 ranks are negated BM25 scores: lower is better
+		res.Score = math.Round(-rank*1e4) / 1e4
+		matches = append(matches, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate related threads: %w", err)
+	}
+	return matches, nil
+}
+
+// relatedRow scans a thread selected with threadColumns followed by its
+// rank.
+type relatedRow struct {
+	rows *sql.Rows
+	rank *float64
+}
+
+func (r relatedRow) Scan(dest ...interface{}) error {
+	return r.rows.Scan(append(dest, r.rank)...)
+}
+
+// handleRelatedThreads returns threads like the thread in the path, up to
+// ?limit= (default 5, at most 20), most alike first.
+func handleRelatedThreads(db *sql.DB, em *Embeddings, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	limit := defaultRelatedThreads
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(n, maxRelatedThreads)
+	}
+
+	// Authors see their own scheduled threads
+	visible, args := visibleCondition(agent)
+	t, err := scanThread(db.QueryRowContext(r.Context(),
+		"SELECT "+threadColumns+" FROM threads t JOIN agents a ON t.agent_id = a.id WHERE t.id = ? AND ("+publishedCondition+" OR t.agent_id = ?) AND "+visible,
+		append([]interface{}{r.PathValue("id"), agent.ID}, args...)...))
+	if err == sql.ErrNoRows {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "thread not found"})
+		return
+	}
+	if err != nil {
+		writeStoreError(w, err, "failed to find related threads")
+		return
+	}
+
+	related, method, err := relatedThreads(r.Context(), db, em, agent, t, limit)
+	if err != nil {
+		writeStoreError(w, err, "failed to find related threads")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"method": method, "threads": related})
+}
@@ -0,0 +1,308 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Reports stream agents, threads, the activity feed, or API usage out of
+// the forum as CSV or newline-delimited JSON, one record per row, for analysis in other
+// tools. Admins download them from the admin panel, and keys with the admin
+// scope from /api/v1/reports/{dataset}.
+
+// Report formats.
+const (
+	reportCSV    = "csv"
+	reportNDJSON = "ndjson"
+)
+
+// reportColumn is one column of a report. kind says how to render its
+// values: "text", "int", "bool", "time", or "json" (a JSON document
+// stored as text, embedded as-is in NDJSON).
+type reportColumn struct {
+	name, kind string
+}
+
+// reportDataset is a report: its columns and the query selecting them.
+// The query takes the workspace ID twice, "" for all workspaces, and a
+// lower bound on creation time.
+type reportDataset struct {
+	columns []reportColumn
+	query   string
+}
+
+var reportDatasets = map[string]reportDataset{
+	"agents": {
+		columns: []reportColumn{
+			{"id", "text"}, {"name", "text"}, {"owner", "text"}, {"workspace", "text"}, {"role", "text"},
+			{"scopes", "json"}, {"capabilities", "json"}, {"model", "text"}, {"description", "text"},
+			{"threads", "int"}, {"replies", "int"}, {"created_at", "time"}, {"last_seen_at", "time"},
+			{"heartbeat_at", "time"}, {"key_expires_at", "time"},
+		},
+		query: `SELECT a.id, a.name, a.owner, COALESCE(w.name, a.workspace_id), a.role,
+				a.scopes, a.capabilities, a.model, a.description,
+				(SELECT COUNT(*) FROM threads t WHERE t.agent_id = a.id),
+				(SELECT COUNT(*) FROM replies r WHERE r.agent_id = a.id),
+				a.created_at, a.last_seen_at, a.heartbeat_at, a.key_expires_at
+			FROM agents a LEFT JOIN workspaces w ON a.workspace_id = w.id
+			WHERE (? = '' OR a.workspace_id = ?) AND a.created_at >= ?
+			ORDER BY a.created_at`,
+	},
+	"threads": {
+		columns: []reportColumn{
+			{"id", "text"}, {"title", "text"}, {"agent", "text"}, {"workspace", "text"}, {"tags", "json"},
+			{"priority", "text"}, {"status", "text"}, {"pinned", "bool"}, {"archived", "bool"}, {"locked", "bool"},
+			{"visibility", "text"}, {"merged_into", "text"}, {"replies", "int"}, {"score", "int"},
+			{"due_at", "time"}, {"publish_at", "time"}, {"created_at", "time"}, {"updated_at", "time"}, {"body", "text"},
+		},
+		query: `SELECT t.id, t.title, a.name, COALESCE(w.name, t.workspace_id), t.tags,
+				t.priority, ` + currentStatusColumn + `, t.pinned, t.archived, t.locked,
+				t.visibility, t.merged_into,
+				(SELECT COUNT(*) FROM replies r WHERE r.thread_id = t.id),
+				COALESCE((SELECT SUM(v.value) FROM votes v WHERE v.thread_id = t.id), 0),
+				t.due_at, t.publish_at, t.created_at, t.updated_at, t.body
+			FROM threads t JOIN agents a ON t.agent_id = a.id LEFT JOIN workspaces w ON t.workspace_id = w.id
+			WHERE (? = '' OR t.workspace_id = ?) AND t.created_at >= ?
+			ORDER BY t.created_at`,
+	},
+	"activity": {
+		columns: []reportColumn{
+			{"kind", "text"}, {"id", "text"}, {"thread_id", "text"}, {"reply_id", "text"}, {"title", "text"},
+			{"agent", "text"}, {"tag", "text"}, {"workspace", "text"}, {"created_at", "time"},
+		},
+		query: `SELECT activity.kind, activity.id, activity.thread_id, activity.reply_id, activity.title,
+				activity.agent_name, activity.tag, COALESCE(w.name, activity.workspace_id), activity.created_at
+			FROM (` + activityUnion() + `) activity LEFT JOIN workspaces w ON activity.workspace_id = w.id
+			WHERE (? = '' OR activity.workspace_id IN ('', ?)) AND activity.created_at >= ?
+			ORDER BY activity.created_at, activity.id`,
+	},
+	// usage has a row per agent and UTC day it made requests on, and
+	// compares since by its date
+	"usage": {
+		columns: []reportColumn{
+			{"day", "text"}, {"agent_id", "text"}, {"agent", "text"}, {"owner", "text"}, {"workspace", "text"},
+			{"requests", "int"}, {"client_errors", "int"}, {"server_errors", "int"}, {"bytes_in", "int"}, {"bytes_out", "int"},
+		},
+		query: `SELECT u.day, a.id, a.name, a.owner, COALESCE(w.name, a.workspace_id),
+				u.requests, u.client_errors, u.server_errors, u.bytes_in, u.bytes_out
+			FROM agent_usage u JOIN agents a ON u.agent_id = a.id LEFT JOIN workspaces w ON a.workspace_id = w.id
+			WHERE (? = '' OR a.workspace_id = ?) AND u.day >= substr(?, 1, 10)
+			ORDER BY u.day, a.name`,
+	},
+}
+
+// activityUnion selects every kind of activity, with the columns named in
+// activitySources.
+func activityUnion() string {
+	var sources []string
+	for _, s := range activitySources {
+		sources = append(sources, s.query)
+	}
+	return strings.Join(sources, "\n\t\tUNION ALL\n\t\t")
+}
+
+// reportValue renders a scanned value for CSV.
+func reportValue(kind string, v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	case []byte:
+		return string(v)
+	case int64:
+		if kind == "bool" {
+			return strconv.FormatBool(v != 0)
+		}
+		return strconv.FormatInt(v, 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// reportJSONValue converts a scanned value for NDJSON.
+func reportJSONValue(kind string, v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return v.UTC()
+	case []byte:
+		return reportJSONValue(kind, string(v))
+	case int64:
+		if kind == "bool" {
+			return v != 0
+		}
+		return v
+	case string:
+		if kind == "json" && json.Valid([]byte(v)) {
+			return json.RawMessage(v)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// queryReport selects the named dataset in the workspace with ID
+// workspaceID, or all of them if it's empty, keeping records created at or
+// after since.
+func queryReport(ctx context.Context, db *sql.DB, name, workspaceID string, since time.Time) (*sql.Rows, error) {
+	rows, err := db.QueryContext(ctx, reportDatasets[name].query, workspaceID, workspaceID, since)
+	if err != nil {
+		return nil, fmt.Errorf("query %s report: %w", name, err)
+	}
+	return rows, nil
+}
+
+// writeReport streams rows selected by queryReport to w as format. Once the
+// first row is written the response is committed, so an error can only cut
+// it short.
+func writeReport(w http.ResponseWriter, rows *sql.Rows, name, format string) error {
+	dataset := reportDatasets[name]
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102-150405"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	var cw *csv.Writer
+	if format == reportCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw = csv.NewWriter(w)
+		header := make([]string, len(dataset.columns))
+		for i, c := range dataset.columns {
+			header[i] = c.name
+		}
+		cw.Write(header)
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+
+	values := make([]interface{}, len(dataset.columns))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	enc := json.NewEncoder(w)
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("scan %s report: %w", name, err)
+		}
+		if cw != nil {
+			record := make([]string, len(values))
+			for i, c := range dataset.columns {
+				record[i] = reportValue(c.kind, values[i])
+			}
+			if err := cw.Write(record); err != nil {
+				return fmt.Errorf("write %s report: %w", name, err)
+			}
+			continue
+		}
+		record := make(map[string]interface{}, len(values))
+		for i, c := range dataset.columns {
+			record[c.name] = reportJSONValue(c.kind, values[i])
+		}
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("write %s report: %w", name, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate %s report: %w", name, err)
+	}
+	if cw != nil {
+		cw.Flush()
+		return cw.Error()
+	}
+	return nil
+}
+
+// parseReportRequest reads the dataset from the path and ?format= (csv, the
+// default, or ndjson) and ?since= (RFC 3339) from the query.
+func parseReportRequest(r *http.Request) (name, format string, since time.Time, err error) {
+	name = r.PathValue("dataset")
+	if _, ok := reportDatasets[name]; !ok {
+		return "", "", since, notFoundError(fmt.Sprintf("unknown report %q (use agents, threads, activity, or usage)", name))
+	}
+	format = r.URL.Query().Get("format")
+	switch format {
+	case "":
+		format = reportCSV
+	case reportCSV, reportNDJSON:
+	default:
+		return "", "", since, inputError("format must be csv or ndjson")
+	}
+	if v := r.URL.Query().Get("since"); v != "" {
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			return "", "", since, inputError("since must be an RFC 3339 timestamp")
+		}
+	}
+	return name, format, since, nil
+}
+
+// handleReport streams a report of agents, threads, activity, or usage in
+// every workspace, or the one in ?workspace=. Requires the admin scope.
+func handleReport(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requireScope(w, agent, scopeAdmin) {
+		return
+	}
+
+	name, format, since, err := parseReportRequest(r)
+	if err != nil {
+		writeStoreError(w, err, "failed to export report")
+		return
+	}
+	var workspaceID string
+	if ref := r.URL.Query().Get("workspace"); ref != "" {
+		if workspaceID, err = resolveWorkspace(r.Context(), db, ref); err != nil {
+			writeStoreError(w, err, "failed to export report")
+			return
+		}
+	}
+
+	rows, err := queryReport(r.Context(), db, name, workspaceID, since)
+	if err != nil {
+		writeStoreError(w, err, "failed to export report")
+		return
+	}
+	defer rows.Close()
+	if err := writeReport(w, rows, name, format); err != nil {
+		log.Printf("report %s: %v", name, err)
+	}
+}
+
+// handleAdminReport downloads a report of agents, threads, activity, or
+// usage, in the workspace the page it's linked from is narrowed to.
+func handleAdminReport(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	name, format, since, err := parseReportRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	workspaceID, _, err := adminWorkspaceFilter(db, r)
+	if err != nil {
+		log.Printf("admin report workspaces query error: %v", err)
+		http.Error(w, "failed to export report", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := queryReport(r.Context(), db, name, workspaceID, since)
+	if err != nil {
+		log.Printf("admin report %s: %v", name, err)
+		http.Error(w, "failed to export report", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	if err := writeReport(w, rows, name, format); err != nil {
+		log.Printf("admin report %s: %v", name, err)
+	}
+}
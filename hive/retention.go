@@ -0,0 +1,333 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Retention policies archive idle threads and purge long-archived ones on a
+// schedule. Both are off unless configured. Pinned threads are never touched.
+
+// RetentionCandidate is a thread a policy would act on.
+type RetentionCandidate struct {
+	ID        string
+	Title     string
+	AgentName string
+	// Since is when the thread went idle (archive) or was archived (purge).
+	Since time.Time
+}
+
+// RetentionReport is the outcome of one policy run.
+type RetentionReport struct {
+	Policy  string
+	DryRun  bool
+	RanAt   time.Time
+	Threads []RetentionCandidate
+	Err     string
+}
+
+// retentionPolicy finds the threads older than a cutoff and acts on them.
+type retentionPolicy struct {
+	Name        string
+	Description string
+	// After is the age at which the policy applies. Zero disables it.
+	After time.Duration
+
+	candidates func(ctx context.Context, db *sql.DB, cutoff time.Time) ([]RetentionCandidate, error)
+	apply      func(ctx context.Context, db *sql.DB, threadID string, now time.Time) error
+}
+
+// Retention runs the retention policies and remembers each one's latest
+// report for the admin panel.
+type Retention struct {
+	db       *sql.DB
+	policies []*retentionPolicy
+	interval time.Duration
+	dryRun   bool
+
+	mu   sync.Mutex
+	last map[string]RetentionReport
+}
+
+// NewRetention sets up the policies from cfg.
+func NewRetention(db *sql.DB, cfg Config) *Retention {
+	return &Retention{
+		db: db,
+		policies: []*retentionPolicy{
+			{
+				Name:        "archive-idle",
+				Description: "Archive threads with no edits, replies, or status tags",
+				After:       cfg.RetentionArchiveAfter,
+				candidates:  idleThreads,
+				apply:       archiveIdleThread,
+			},
+			{
+				Name:        "purge-archived",
+				Description: "Delete archived threads with their replies, status tags, and attachments",
+				After:       cfg.RetentionPurgeAfter,
+				candidates:  longArchivedThreads,
+				apply:       purgeArchivedThread,
+			},
+		},
+		interval: cfg.RetentionInterval,
+		dryRun:   cfg.RetentionDryRun,
+		last:     map[string]RetentionReport{},
+	}
+}
+
+// idleThreads finds unarchived threads with no activity since cutoff.
+func idleThreads(ctx context.Context, db *sql.DB, cutoff time.Time) ([]RetentionCandidate, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT t.id, t.title, a.name, t.updated_at, t.archived_at
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		WHERE t.archived = 0 AND t.pinned = 0 AND `+publishedCondition+` AND t.updated_at < ?
+		AND NOT EXISTS (SELECT 1 FROM replies r WHERE r.thread_id = t.id AND r.updated_at >= ?)
+		AND NOT EXISTS (
+			SELECT 1 FROM status_tags s
+			WHERE s.created_at >= ?
+			AND (s.thread_id = t.id OR s.reply_id IN (SELECT id FROM replies WHERE thread_id = t.id))
+		)
+		ORDER BY t.updated_at`, cutoff, cutoff, cutoff,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query idle threads: %w", err)
+	}
+	candidates, err := scanRetentionCandidates(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Idle since the latest reply or status tag, if later than the last edit
+	for i := range candidates {
+		c := &candidates[i]
+		for _, q := range []string{
+			"SELECT updated_at FROM replies WHERE thread_id = ? ORDER BY updated_at DESC LIMIT 1",
+			`SELECT created_at FROM status_tags
+			WHERE thread_id = ? OR reply_id IN (SELECT id FROM replies WHERE thread_id = ?)
+			ORDER BY created_at DESC LIMIT 1`,
+		} {
+			var at time.Time
+			err := db.QueryRowContext(ctx, q, c.ID, c.ID).Scan(&at)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, fmt.Errorf("query thread activity: %w", err)
+			}
+			if at.After(c.Since) {
+				c.Since = at
+			}
+		}
+	}
+	return candidates, nil
+}
+
+// longArchivedThreads finds threads archived before cutoff. Threads archived
+// before archive times were recorded count from their last edit.
+func longArchivedThreads(ctx context.Context, db *sql.DB, cutoff time.Time) ([]RetentionCandidate, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT t.id, t.title, a.name, t.updated_at, t.archived_at
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		WHERE t.archived = 1 AND t.pinned = 0 AND COALESCE(t.archived_at, t.updated_at) < ?
+		ORDER BY COALESCE(t.archived_at, t.updated_at)`, cutoff,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query archived threads: %w", err)
+	}
+	return scanRetentionCandidates(rows)
+}
+
+// scanRetentionCandidates reads rows of id, title, author, updated_at, and
+// archived_at. Since is the archive time if there is one, else the last
+// edit.
+func scanRetentionCandidates(rows *sql.Rows) ([]RetentionCandidate, error) {
+	defer rows.Close()
+	candidates := []RetentionCandidate{}
+	for rows.Next() {
+		var c RetentionCandidate
+		var archivedAt *time.Time
+		if err := rows.Scan(&c.ID, &c.Title, &c.AgentName, &c.Since, &archivedAt); err != nil {
+			return nil, fmt.Errorf("scan thread: %w", err)
+		}
+		if archivedAt != nil {
+			c.Since = *archivedAt
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+func archiveIdleThread(ctx context.Context, db *sql.DB, threadID string, now time.Time) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE threads SET archived = 1, archived_at = ? WHERE id = ? AND archived = 0 AND pinned = 0",
+		now, threadID,
+	)
+	return err
+}
+
+// purgeArchivedThread deletes a thread, which cascades to its replies,
+// status tags, attachments, and notifications.
+func purgeArchivedThread(ctx context.Context, db *sql.DB, threadID string, now time.Time) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM threads WHERE id = ? AND archived = 1 AND pinned = 0", threadID)
+	return err
+}
+
+func (rt *Retention) policy(name string) *retentionPolicy {
+	for _, p := range rt.policies {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// RunPolicy runs the named policy, or with dryRun only reports what it
+// would do.
+func (rt *Retention) RunPolicy(ctx context.Context, name string, dryRun bool) (RetentionReport, error) {
+	p := rt.policy(name)
+	if p == nil {
+		return RetentionReport{}, notFoundError("no such retention policy")
+	}
+	if p.After <= 0 {
+		return RetentionReport{}, inputError("policy is disabled; set its age threshold first")
+	}
+
+	now := time.Now()
+	report := RetentionReport{Policy: p.Name, DryRun: dryRun, RanAt: now}
+	candidates, err := p.candidates(ctx, rt.db, now.Add(-p.After))
+	if err == nil && !dryRun {
+		for i, c := range candidates {
+			if err = p.apply(ctx, rt.db, c.ID, now); err != nil {
+				err = fmt.Errorf("%s %s: %w", p.Name, c.ID, err)
+				candidates = candidates[:i]
+				break
+			}
+		}
+	}
+	report.Threads = candidates
+	if err != nil {
+		report.Err = err.Error()
+	}
+
+	rt.mu.Lock()
+	rt.last[p.Name] = report
+	rt.mu.Unlock()
+	return report, err
+}
+
+// runAll runs every enabled policy. Threads archived by the first policy
+// are dated now, so the purge in the same pass leaves them alone.
+func (rt *Retention) runAll(ctx context.Context) {
+	for _, p := range rt.policies {
+		if p.After <= 0 {
+			continue
+		}
+		report, err := rt.RunPolicy(ctx, p.Name, rt.dryRun)
+		if err != nil {
+			log.Printf("retention: %v", err)
+		}
+		if len(report.Threads) == 0 {
+			continue
+		}
+		verb := "applied to"
+		if report.DryRun {
+			verb = "would apply to"
+		}
+		log.Printf("retention: %s %s %d threads", p.Name, verb, len(report.Threads))
+	}
+}
+
+// Start runs the enabled policies now and then every interval until ctx is
+// done. It does nothing if no policy is enabled.
+func (rt *Retention) Start(ctx context.Context) {
+	enabled := false
+	for _, p := range rt.policies {
+		enabled = enabled || p.After > 0
+	}
+	if !enabled || rt.interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(rt.interval)
+		defer ticker.Stop()
+		for {
+			rt.runAll(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// retentionPolicyView is a policy with its latest report, for the admin
+// panel.
+type retentionPolicyView struct {
+	Name        string
+	Description string
+	After       time.Duration
+	Last        *RetentionReport
+}
+
+// Age formats the policy's threshold, in days when it is a whole number of
+// them.
+func (v retentionPolicyView) Age() string {
+	if v.After > 0 && v.After%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%d days", v.After/(24*time.Hour))
+	}
+	return v.After.String()
+}
+
+func (rt *Retention) views() []retentionPolicyView {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	views := make([]retentionPolicyView, len(rt.policies))
+	for i, p := range rt.policies {
+		views[i] = retentionPolicyView{Name: p.Name, Description: p.Description, After: p.After}
+		if last, ok := rt.last[p.Name]; ok {
+			views[i].Last = &last
+		}
+	}
+	return views
+}
+
+// handleAdminRetention shows the retention policies and their latest runs.
+func handleAdminRetention(rt *Retention, w http.ResponseWriter, r *http.Request) {
+	renderAdminTemplate(w, r, "retention.html", map[string]interface{}{
+		"Policies": rt.views(),
+		"Interval": rt.interval,
+		"DryRun":   rt.dryRun,
+	})
+}
+
+// handleAdminRunRetention runs one policy, for real or as a dry run, and
+// shows the threads it affected or would affect.
+func handleAdminRunRetention(rt *Retention, dryRun bool, w http.ResponseWriter, r *http.Request) {
+	report, err := rt.RunPolicy(r.Context(), r.PathValue("name"), dryRun)
+	switch err.(type) {
+	case nil:
+	case notFoundError:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case inputError:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		log.Printf("admin retention: %v", err)
+	}
+	if admin := AdminFromContext(r.Context()); admin != nil && !dryRun {
+		log.Printf("retention: %s run by admin %s affected %d threads", report.Policy, admin.Username, len(report.Threads))
+	}
+
+	renderAdminTemplate(w, r, "retention.html", map[string]interface{}{
+		"Policies": rt.views(),
+		"Interval": rt.interval,
+		"DryRun":   rt.dryRun,
+		"Report":   report,
+	})
+}
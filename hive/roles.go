@@ -0,0 +1,144 @@
+package hive
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Agent roles. Every agent has exactly one role; new agents are workers.
+const (
+	roleWorker      = "worker"
+	roleCoordinator = "coordinator"
+	roleModerator   = "moderator"
+)
+
+var validRoles = map[string]bool{
+	roleWorker:      true,
+	roleCoordinator: true,
+	roleModerator:   true,
+}
+
+// Permissions granted by roles, checked per route with requirePermission.
+const (
+	permPinThreads     = "pin threads"
+	permArchiveThreads = "archive threads"
+	permLockThreads    = "lock threads"
+	permMergeThreads   = "merge threads"
+	permModerate       = "delete other agents' content"
+	permListAgents     = "list agents"
+	permAnnounce       = "post announcements"
+)
+
+// rolePermissions lists what each role may do beyond working on its own content.
+var rolePermissions = map[string]map[string]bool{
+	roleWorker:      {},
+	roleCoordinator: {permPinThreads: true, permArchiveThreads: true, permLockThreads: true, permMergeThreads: true, permListAgents: true, permAnnounce: true},
+	roleModerator:   {permPinThreads: true, permArchiveThreads: true, permLockThreads: true, permMergeThreads: true, permListAgents: true, permAnnounce: true, permModerate: true},
+}
+
+// Can reports whether the agent's role grants perm.
+func (a *Agent) Can(perm string) bool {
+	return rolePermissions[a.Role][perm]
+}
+
+// requirePermission writes a 403 and returns false if the agent's role lacks perm.
+func requirePermission(w http.ResponseWriter, agent *Agent, perm string) bool {
+	if err := checkPermission(agent, perm); err != nil {
+		writeStoreError(w, err, "")
+		return false
+	}
+	return true
+}
+
+// checkPermission returns a forbiddenError if the agent's role lacks perm.
+func checkPermission(agent *Agent, perm string) error {
+	if agent.Can(perm) {
+		return nil
+	}
+	return forbiddenError(fmt.Sprintf("agents with the %q role cannot %s", agent.Role, perm))
+}
+
+// setThreadFlag sets a boolean thread column on a thread agent can read,
+// if agent's role grants perm, and returns the thread. column must be a
+// trusted constant.
+func setThreadFlag(ctx context.Context, db dbtx, agent *Agent, perm, column, threadID string, value bool) (Thread, error) {
+	if err := checkPermission(agent, perm); err != nil {
+		return Thread{}, err
+	}
+	if err := requireVisible(ctx, db, agent, threadID); err != nil {
+		return Thread{}, err
+	}
+
+	query, args := fmt.Sprintf("UPDATE threads SET %s = ? WHERE id = ?", column), []interface{}{value, threadID}
+	if column == "archived" {
+		// archived_at dates the archive for the purge retention policy
+		query = "UPDATE threads SET archived = ?, archived_at = CASE WHEN ? THEN COALESCE(archived_at, ?) END WHERE id = ?"
+		args = []interface{}{value, value, time.Now(), threadID}
+	}
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return Thread{}, fmt.Errorf("update thread: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return Thread{}, notFoundError("thread not found")
+	}
+
+	t, err := scanThread(db.QueryRowContext(ctx,
+		"SELECT "+threadColumns+`
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		WHERE t.id = ?`, threadID,
+	))
+	if err != nil {
+		return Thread{}, fmt.Errorf("load updated thread: %w", err)
+	}
+	return t, nil
+}
+
+// handleSetThreadPinned pins or unpins a thread. Requires a coordinator or
+// moderator role.
+func handleSetThreadPinned(store ThreadStore, pinned bool, w http.ResponseWriter, r *http.Request) {
+	serveThreadFlag(w, r, func(agent *Agent, threadID string) (Thread, error) {
+		return store.SetThreadPinned(r.Context(), agent, threadID, pinned)
+	})
+}
+
+// handleSetThreadArchived archives or unarchives a thread. Requires a
+// coordinator or moderator role.
+func handleSetThreadArchived(store ThreadStore, archived bool, w http.ResponseWriter, r *http.Request) {
+	serveThreadFlag(w, r, func(agent *Agent, threadID string) (Thread, error) {
+		return store.SetThreadArchived(r.Context(), agent, threadID, archived)
+	})
+}
+
+// handleSetThreadLocked locks or unlocks a thread. Locked threads take no new
+// replies or status tags. Requires a coordinator or moderator role.
+func handleSetThreadLocked(store ThreadStore, locked bool, w http.ResponseWriter, r *http.Request) {
+	serveThreadFlag(w, r, func(agent *Agent, threadID string) (Thread, error) {
+		return store.SetThreadLocked(r.Context(), agent, threadID, locked)
+	})
+}
+
+// serveThreadFlag responds with the thread set sets a flag on for the
+// requesting agent.
+func serveThreadFlag(w http.ResponseWriter, r *http.Request, set func(agent *Agent, threadID string) (Thread, error)) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing thread id"})
+		return
+	}
+
+	t, err := set(agent, threadID)
+	if err != nil {
+		writeStoreError(w, err, "failed to update thread")
+		return
+	}
+	writeJSON(w, http.StatusOK, t)
+}
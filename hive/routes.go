@@ -0,0 +1,724 @@
+package hive
+
+import (
+	"database/sql"
+	"net/http"
+)
+
+func SetupRoutes(db *sql.DB, cfg Config, bus *EventBus, limiter *RateLimiter, retention *Retention, maintenance *Maintenance, embeddings *Embeddings, summaries *Summaries, tagger *Tagger, discord *Discord, mailer *Mailer, usage *Usage) http.Handler {
+	mux := http.NewServeMux()
+
+	// The API's core operations go through store, as the gRPC API's do
+	store := newSQLStore(db, bus, cfg.Limits)
+	keyAuth := APIKeyAuth(store)
+	rateLimit := RateLimitMiddleware(limiter)
+	limitBody := LimitRequestBody(cfg.Limits.MaxRequestBytes)
+	timeout := RequestTimeout(cfg.RequestTimeout)
+	trackUsage := TrackUsage(usage)
+	apiAuth := func(next http.Handler) http.Handler {
+		return timeout(keyAuth(trackUsage(rateLimit(ScopeMiddleware(limitBody(next))))))
+	}
+	// streamAuth is apiAuth for responses that may take longer than the
+	// request timeout to stream
+	streamAuth := func(next http.Handler) http.Handler {
+		return keyAuth(trackUsage(rateLimit(ScopeMiddleware(limitBody(next)))))
+	}
+	// uploadAuth is apiAuth for uploads and imports, which cap their own
+	// bodies
+	uploadAuth := func(next http.Handler) http.Handler {
+		return keyAuth(trackUsage(rateLimit(ScopeMiddleware(next))))
+	}
+	idempotent := Idempotency(db)
+	adminAuth := AdminAuth(db, cfg)
+	userAuth := UserAuth(db, cfg)
+
+	// API routes (agent-facing)
+	mux.Handle("POST /api/v1/threads", apiAuth(idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleCreateThread(store, db, bus, tagger, cfg.DuplicateThreads, w, r)
+	}))))
+	mux.Handle("GET /api/v1/threads", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListThreads(store, db, w, r)
+	})))
+	mux.Handle("POST /api/v1/threads/bulk", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleBulkThreads(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/threads/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleGetThread(store, db, w, r)
+	})))
+	mux.Handle("PUT /api/v1/threads/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateThread(store, w, r)
+	})))
+	mux.Handle("PATCH /api/v1/threads/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlePatchThread(store, w, r)
+	})))
+	mux.Handle("DELETE /api/v1/threads/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteThread(store, w, r)
+	})))
+	mux.Handle("GET /api/v1/threads/{id}/related", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleRelatedThreads(db, embeddings, w, r)
+	})))
+	mux.Handle("GET /api/v1/threads/{id}/summary", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleThreadSummary(summaries, w, r)
+	})))
+	mux.Handle("GET /api/v1/threads/{id}/export", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleExportThread(db, w, r)
+	})))
+	mux.Handle("POST /api/v1/batch", apiAuth(idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleBatch(db, bus, w, r)
+	}))))
+	mux.Handle("GET /api/v1/templates", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListTemplates(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/tags", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListTags(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/tags/autocomplete", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAutocompleteTags(db, w, r)
+	})))
+
+	// Participants of restricted threads
+	mux.Handle("GET /api/v1/threads/{id}/participants", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListParticipants(db, w, r)
+	})))
+	mux.Handle("POST /api/v1/threads/{id}/participants", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAddParticipants(db, w, r)
+	})))
+	mux.Handle("DELETE /api/v1/threads/{id}/participants/{agent}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveParticipant(db, w, r)
+	})))
+
+	// Attachments
+	mux.Handle("POST /api/v1/threads/{id}/attachments", uploadAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleUploadThreadAttachment(db, cfg, w, r)
+	})))
+	mux.Handle("GET /api/v1/threads/{id}/attachments", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListThreadAttachments(db, w, r)
+	})))
+	mux.Handle("POST /api/v1/replies/{id}/attachments", uploadAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleUploadReplyAttachment(db, cfg, w, r)
+	})))
+	mux.Handle("GET /api/v1/attachments/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDownloadAttachment(db, w, r)
+	})))
+	mux.Handle("DELETE /api/v1/attachments/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteAttachment(db, w, r)
+	})))
+
+	// Coordination (role-restricted)
+	mux.Handle("POST /api/v1/threads/{id}/pin", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleSetThreadPinned(store, true, w, r)
+	})))
+	mux.Handle("DELETE /api/v1/threads/{id}/pin", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleSetThreadPinned(store, false, w, r)
+	})))
+	mux.Handle("POST /api/v1/threads/{id}/archive", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleSetThreadArchived(store, true, w, r)
+	})))
+	mux.Handle("DELETE /api/v1/threads/{id}/archive", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleSetThreadArchived(store, false, w, r)
+	})))
+	mux.Handle("POST /api/v1/threads/{id}/lock", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleSetThreadLocked(store, true, w, r)
+	})))
+	mux.Handle("DELETE /api/v1/threads/{id}/lock", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleSetThreadLocked(store, false, w, r)
+	})))
+	mux.Handle("POST /api/v1/threads/{id}/merge", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleMergeThread(db, bus, w, r)
+	})))
+
+	// Votes
+	mux.Handle("POST /api/v1/threads/{id}/vote", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleVoteThread(store, w, r)
+	})))
+	mux.Handle("DELETE /api/v1/threads/{id}/vote", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleUnvoteThread(store, w, r)
+	})))
+
+	// Replies
+	mux.Handle("GET /api/v1/threads/{id}/replies", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListReplies(store, w, r)
+	})))
+	mux.Handle("POST /api/v1/threads/{id}/replies", apiAuth(idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleCreateReply(store, w, r)
+	}))))
+	mux.Handle("PUT /api/v1/replies/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateReply(store, w, r)
+	})))
+	mux.Handle("PATCH /api/v1/replies/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlePatchReply(store, w, r)
+	})))
+	mux.Handle("DELETE /api/v1/replies/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteReply(store, w, r)
+	})))
+
+	// Status tags
+	mux.Handle("POST /api/v1/threads/{id}/status", apiAuth(idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleCreateThreadStatus(store, w, r)
+	}))))
+	mux.Handle("POST /api/v1/replies/{id}/status", apiAuth(idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleCreateReplyStatus(store, w, r)
+	}))))
+	mux.Handle("DELETE /api/v1/status/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteStatus(store, w, r)
+	})))
+	mux.Handle("GET /api/v1/status", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleQueryStatus(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/threads/{id}/dependents", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleThreadDependents(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/threads/{id}/status-history", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleThreadStatusHistory(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/sla/policies", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListSLAPolicies(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/sla/breaches", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListSLABreaches(db, w, r)
+	})))
+
+	// Context endpoints
+	mux.Handle("GET /api/v1/context/agent/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAgentContext(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/context/active", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleActiveContext(db, cfg, w, r)
+	})))
+	mux.Handle("GET /api/v1/context/dependencies", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDependencies(store, w, r)
+	})))
+	mux.Handle("GET /api/v1/context/dependencies/cycles", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDependencyCycles(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/search/semantic", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleSemanticSearch(embeddings, w, r)
+	})))
+
+	// Agents (creating and revoking need the admin scope, listing the admin
+	// scope or a coordinator or moderator role)
+	mux.Handle("PUT /api/v1/agents/me", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleUpdateProfile(db, w, r)
+	})))
+	mux.Handle("POST /api/v1/agents/me/rotate-key", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleRotateKey(db, cfg, w, r)
+	})))
+	mux.Handle("POST "+heartbeatPath, apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleHeartbeat(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/agents", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListAgents(db, w, r)
+	})))
+	mux.Handle("POST /api/v1/agents", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleCreateAgent(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/agents/{id}/metrics", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAgentMetrics(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/agents/{id}/usage", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAgentUsage(db, usage, w, r)
+	})))
+	mux.Handle("DELETE /api/v1/agents/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleRevokeAgent(db, w, r)
+	})))
+
+	// Announcements (creating needs the admin scope or a coordinator or
+	// moderator role)
+	mux.Handle("GET /api/v1/announcements", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListAnnouncements(db, cfg, w, r)
+	})))
+	mux.Handle("POST /api/v1/announcements", apiAuth(idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleCreateAnnouncement(db, w, r)
+	}))))
+
+	// Workspaces
+	mux.Handle("GET /api/v1/workspaces", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListWorkspaces(db, w, r)
+	})))
+	mux.Handle("POST /api/v1/workspaces", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleCreateWorkspace(db, w, r)
+	})))
+
+	// Event stream
+	mux.Handle("GET /api/v1/events", streamAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleEventStream(store, bus, w, r)
+	})))
+
+	// Reports (admin scope)
+	mux.Handle("GET /api/v1/reports/{dataset}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleReport(db, w, r)
+	})))
+
+	// Content export (admin scope)
+	mux.Handle("GET /api/v1/export", streamAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleExport(db, w, r)
+	})))
+
+	// Backups (admin scope)
+	mux.Handle("GET /api/v1/backup", streamAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleBackup(db, w, r)
+	})))
+	mux.Handle("POST /api/v1/backup", streamAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleSaveBackup(db, cfg, w, r)
+	})))
+
+	// Database maintenance (admin scope)
+	mux.Handle("GET /api/v1/maintenance", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleMaintenanceStatus(maintenance, w, r)
+	})))
+	mux.Handle("POST /api/v1/maintenance/{task}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleStartMaintenance(maintenance, w, r)
+	})))
+	mux.Handle("GET /api/v1/maintenance/runs/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleMaintenanceRun(maintenance, w, r)
+	})))
+
+	// Bulk import (admin scope)
+	mux.Handle("POST /api/v1/import", uploadAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleImport(db, w, r)
+	})))
+
+	// Mentions
+	mux.Handle("GET /api/v1/mentions", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListMentions(db, w, r)
+	})))
+
+	// Activity feed
+	mux.Handle("GET /api/v1/activity", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListActivity(db, w, r)
+	})))
+
+	// Delta sync
+	mux.Handle("GET /api/v1/sync", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleSync(db, w, r)
+	})))
+
+	// Read tracking
+	mux.Handle("POST /api/v1/threads/{id}/read", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleMarkThreadRead(db, w, r)
+	})))
+	mux.Handle("DELETE /api/v1/threads/{id}/read", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleMarkThreadUnread(db, w, r)
+	})))
+
+	// Subscriptions and notifications
+	mux.Handle("POST /api/v1/threads/{id}/subscribe", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleSubscribe(db, w, r)
+	})))
+	mux.Handle("DELETE /api/v1/threads/{id}/subscribe", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleUnsubscribe(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/subscriptions", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListSubscriptions(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/notifications", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListNotifications(db, w, r)
+	})))
+	mux.Handle("POST /api/v1/notifications/read", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleMarkNotificationsRead(db, w, r)
+	})))
+
+	// Direct messages
+	mux.Handle("POST /api/v1/messages", apiAuth(idempotent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleSendMessage(db, w, r)
+	}))))
+	mux.Handle("GET /api/v1/messages", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListInbox(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/messages/sent", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleListSentMessages(db, w, r)
+	})))
+	mux.Handle("GET /api/v1/messages/{id}", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleGetMessage(db, w, r)
+	})))
+	mux.Handle("POST /api/v1/messages/read", apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleMarkMessagesRead(db, w, r)
+	})))
+
+	// GraphQL (read-only)
+	graphQL := newForumGraphQLSchema(db)
+	graphQLHandler := apiAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleGraphQL(graphQL, w, r)
+	}))
+	mux.Handle("GET "+graphQLPath, graphQLHandler)
+	mux.Handle("POST "+graphQLPath, graphQLHandler)
+	mux.HandleFunc("GET "+graphQLPath+"/schema", func(w http.ResponseWriter, r *http.Request) {
+		handleGraphQLSchema(graphQL, w, r)
+	})
+
+	// API description (no auth required)
+	mux.HandleFunc("GET /api/v1/openapi.json", handleOpenAPI)
+	mux.HandleFunc("GET /api/v1/docs", handleAPIDocs)
+
+	// User authentication routes (no auth required)
+	mux.HandleFunc("GET /login", func(w http.ResponseWriter, r *http.Request) {
+		handleLogin(db, cfg, mailer, w, r)
+	})
+	mux.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
+		handleLoginPost(db, cfg, mailer, w, r)
+	})
+	mux.HandleFunc("POST /logout", func(w http.ResponseWriter, r *http.Request) {
+		handleLogout(db, w, r)
+	})
+	mux.HandleFunc("GET /forgot", func(w http.ResponseWriter, r *http.Request) {
+		handleForgotPassword(cfg, mailer, w, r)
+	})
+	mux.HandleFunc("POST /forgot", func(w http.ResponseWriter, r *http.Request) {
+		handleForgotPasswordPost(db, cfg, mailer, w, r)
+	})
+	mux.HandleFunc("GET /reset", func(w http.ResponseWriter, r *http.Request) {
+		handleResetPassword(db, cfg, w, r)
+	})
+	mux.HandleFunc("POST /reset", func(w http.ResponseWriter, r *http.Request) {
+		handleResetPasswordPost(db, cfg, w, r)
+	})
+
+	// Root redirect
+	mux.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+	})
+
+	// Dashboard routes (user auth required with DASHBOARD_AUTH=required)
+	mux.Handle("GET /dashboard", userAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardFeed(db, cfg, w, r)
+	})))
+	mux.Handle("GET /dashboard/threads/{id}", userAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardThread(db, embeddings, w, r)
+	})))
+	mux.Handle("GET /dashboard/agents/{id}", userAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardAgent(db, w, r)
+	})))
+	mux.Handle("GET /dashboard/attachments/{id}", userAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardAttachment(db, w, r)
+	})))
+	mux.Handle("GET /dashboard/tags", userAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardTags(db, w, r)
+	})))
+	mux.Handle("GET /dashboard/sla", userAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardSLA(db, w, r)
+	})))
+	mux.Handle("GET /dashboard/dependencies", userAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardDependencies(w, r)
+	})))
+	mux.Handle("GET /dashboard/dependencies/graph", userAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardDependencyGraph(db, w, r)
+	})))
+	mux.Handle("GET /dashboard/password", userAuth(http.HandlerFunc(handleAccountPassword)))
+	mux.Handle("POST /dashboard/password", userAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAccountPasswordPost(db, w, r)
+	})))
+	mux.Handle("GET /dashboard/email", userAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAccountEmail(db, mailer, w, r)
+	})))
+	mux.Handle("POST /dashboard/email", userAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAccountEmailPost(db, mailer, w, r)
+	})))
+
+	// Dashboard moderation routes (moderator users only)
+	mux.Handle("POST /dashboard/threads/{id}/replies", userAuth(requireModerator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardReply(db, bus, w, r)
+	}))))
+	mux.Handle("POST /dashboard/threads/{id}/status", userAuth(requireModerator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardStatus(db, bus, w, r)
+	}))))
+	mux.Handle("POST /dashboard/threads/{id}/pin", userAuth(requireModerator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardPinThread(db, w, r)
+	}))))
+	mux.Handle("POST /dashboard/threads/{id}/archive", userAuth(requireModerator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardArchiveThread(db, w, r)
+	}))))
+	mux.Handle("POST /dashboard/threads/{id}/delete", userAuth(requireModerator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardDeleteThread(db, w, r)
+	}))))
+	mux.Handle("GET /dashboard/announcements", userAuth(requireModerator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardAnnouncements(db, w, r)
+	}))))
+	mux.Handle("POST /dashboard/announcements", userAuth(requireModerator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardCreateAnnouncement(db, w, r)
+	}))))
+	mux.Handle("POST /dashboard/announcements/{id}/toggle", userAuth(requireModerator(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDashboardToggleAnnouncement(db, w, r)
+	}))))
+
+	// Atom feeds (FEED_TOKEN auth)
+	mux.HandleFunc("GET /feeds/threads.atom", func(w http.ResponseWriter, r *http.Request) {
+		handleThreadsFeed(db, cfg, w, r)
+	})
+	mux.HandleFunc("GET /feeds/tags/{file}", func(w http.ResponseWriter, r *http.Request) {
+		handleTagFeed(db, cfg, w, r)
+	})
+
+	// Inbound webhooks (per-source secret auth)
+	mux.HandleFunc("POST /api/v1/inbound/{source}", func(w http.ResponseWriter, r *http.Request) {
+		handleInbound(db, bus, w, r)
+	})
+
+	// Admin routes (login pages bypass auth via middleware check)
+	mux.Handle("GET /admin/login", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminLogin(cfg, w, r)
+	})))
+	mux.Handle("POST /admin/login", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminLoginPost(db, cfg, w, r)
+	})))
+	mux.HandleFunc("POST /admin/logout", func(w http.ResponseWriter, r *http.Request) {
+		handleAdminLogout(db, w, r)
+	})
+	mux.Handle("GET /admin", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDashboard(db, w, r)
+	})))
+	mux.Handle("GET /admin/backup", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminBackup(db, w, r)
+	})))
+	mux.Handle("GET /admin/retention", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminRetention(retention, w, r)
+	})))
+	mux.Handle("POST /admin/retention/{name}/dry-run", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminRunRetention(retention, true, w, r)
+	})))
+	mux.Handle("POST /admin/retention/{name}/run", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminRunRetention(retention, false, w, r)
+	})))
+	mux.Handle("GET /admin/maintenance", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminMaintenance(maintenance, w, r)
+	})))
+	mux.Handle("POST /admin/maintenance/{task}", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminStartMaintenance(maintenance, w, r)
+	})))
+	mux.Handle("GET /admin/import", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminImportPage(w, r)
+	})))
+	mux.Handle("POST /admin/import", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminImport(db, w, r)
+	})))
+	mux.Handle("GET /admin/analytics", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminAnalytics(db, w, r)
+	})))
+	mux.Handle("GET /admin/usage", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminUsage(db, usage, w, r)
+	})))
+	mux.Handle("GET /admin/reports/{dataset}", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminReport(db, w, r)
+	})))
+	mux.Handle("GET /admin/search", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminSearch(db, w, r)
+	})))
+	mux.Handle("GET /admin/threads", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminThreads(db, w, r)
+	})))
+	mux.Handle("POST /admin/threads/bulk", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminBulkThreads(db, w, r)
+	})))
+	mux.Handle("POST /admin/threads/{id}/delete", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDeleteThread(db, w, r)
+	})))
+	mux.Handle("POST /admin/threads/{id}/pin", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminPinThread(db, w, r)
+	})))
+	mux.Handle("POST /admin/threads/{id}/archive", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminArchiveThread(db, w, r)
+	})))
+	mux.Handle("POST /admin/threads/{id}/lock", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminLockThread(db, w, r)
+	})))
+	mux.Handle("POST /admin/threads/{id}/merge", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminMergeThread(db, bus, w, r)
+	})))
+	mux.Handle("GET /admin/agents", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminAgents(db, w, r)
+	})))
+	mux.Handle("POST /admin/agents", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminCreateAgent(db, w, r)
+	})))
+	mux.Handle("POST /admin/agents/{id}/scopes", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminUpdateAgentScopes(db, w, r)
+	})))
+	mux.Handle("POST /admin/agents/{id}/role", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminUpdateAgentRole(db, w, r)
+	})))
+	mux.Handle("POST /admin/agents/{id}/expiry", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminUpdateAgentExpiry(db, w, r)
+	})))
+	mux.Handle("POST /admin/agents/{id}/rotate", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminRotateAgentKey(db, cfg, w, r)
+	})))
+	mux.Handle("POST /admin/agents/reissue-legacy", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminReissueLegacyKeys(db, w, r)
+	})))
+	mux.Handle("POST /admin/agents/{id}/revoke", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminRevokeAgent(db, w, r)
+	})))
+	mux.Handle("GET /admin/announcements", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminAnnouncements(db, w, r)
+	})))
+	mux.Handle("POST /admin/announcements", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminCreateAnnouncement(db, w, r)
+	})))
+	mux.Handle("POST /admin/announcements/{id}/toggle", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminToggleAnnouncement(db, w, r)
+	})))
+	mux.Handle("GET /admin/workspaces", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminWorkspaces(db, w, r)
+	})))
+	mux.Handle("POST /admin/workspaces", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminCreateWorkspace(db, w, r)
+	})))
+	mux.Handle("GET /admin/filters", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminFilters(db, w, r)
+	})))
+	mux.Handle("POST /admin/filters", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminCreateFilter(db, w, r)
+	})))
+	mux.Handle("POST /admin/filters/{id}/toggle", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminToggleFilter(db, w, r)
+	})))
+	mux.Handle("POST /admin/filters/{id}/delete", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDeleteFilter(db, w, r)
+	})))
+	mux.Handle("POST /admin/quarantine/{id}/approve", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminApproveQuarantined(db, bus, w, r)
+	})))
+	mux.Handle("POST /admin/quarantine/{id}/discard", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDiscardQuarantined(db, w, r)
+	})))
+	mux.Handle("GET /admin/tagging", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminTagRules(db, tagger, w, r)
+	})))
+	mux.Handle("POST /admin/tagging", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminCreateTagRule(db, w, r)
+	})))
+	mux.Handle("POST /admin/tagging/rename", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminRenameTag(db, w, r)
+	})))
+	mux.Handle("POST /admin/tagging/{id}/delete", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDeleteTagRule(db, w, r)
+	})))
+	mux.Handle("GET /admin/inbound", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminInbound(db, w, r)
+	})))
+	mux.Handle("POST /admin/inbound", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminCreateInbound(db, w, r)
+	})))
+	mux.Handle("POST /admin/inbound/{id}/delete", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDeleteInbound(db, w, r)
+	})))
+	mux.Handle("GET /admin/discord", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDiscord(db, cfg, w, r)
+	})))
+	mux.Handle("POST /admin/discord", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminCreateDiscord(db, w, r)
+	})))
+	mux.Handle("POST /admin/discord/{id}/test", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminTestDiscord(db, discord, w, r)
+	})))
+	mux.Handle("POST /admin/discord/{id}/delete", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDeleteDiscord(db, w, r)
+	})))
+	mux.Handle("GET /admin/sla", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminSLA(db, cfg, w, r)
+	})))
+	mux.Handle("POST /admin/sla", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminCreateSLA(db, w, r)
+	})))
+	mux.Handle("POST /admin/sla/{id}/delete", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDeleteSLA(db, w, r)
+	})))
+	mux.Handle("GET /admin/email", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminEmail(db, mailer, w, r)
+	})))
+	mux.Handle("POST /admin/email", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminSetEmail(db, w, r)
+	})))
+	mux.Handle("POST /admin/email/test", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminTestEmail(db, mailer, w, r)
+	})))
+	mux.Handle("POST /admin/email/delete", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDeleteEmail(db, w, r)
+	})))
+	mux.Handle("GET /admin/templates", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminTemplates(db, w, r)
+	})))
+	mux.Handle("POST /admin/templates", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminCreateTemplate(db, w, r)
+	})))
+	mux.Handle("POST /admin/templates/{id}/delete", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDeleteTemplate(db, w, r)
+	})))
+
+	// Admin user management routes
+	mux.Handle("GET /admin/users", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminUsers(db, cfg, w, r)
+	})))
+	mux.Handle("POST /admin/users", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminCreateUser(db, w, r)
+	})))
+	mux.Handle("POST /admin/users/{id}/password", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminSetUserPassword(db, w, r)
+	})))
+	mux.Handle("POST /admin/users/{id}/reset-link", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminUserResetLink(db, cfg, w, r)
+	})))
+	mux.Handle("POST /admin/users/{id}/role", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminSetUserRole(db, w, r)
+	})))
+	mux.Handle("POST /admin/users/{id}/disable", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminSetUserDisabled(db, true, w, r)
+	})))
+	mux.Handle("POST /admin/users/{id}/enable", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminSetUserDisabled(db, false, w, r)
+	})))
+	mux.Handle("POST /admin/users/{id}/delete", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDeleteUser(db, w, r)
+	})))
+
+	// Admin account management routes
+	mux.Handle("GET /admin/admins", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminAdmins(db, cfg, w, r)
+	})))
+	mux.Handle("POST /admin/admins", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminCreateAdmin(db, w, r)
+	})))
+	mux.Handle("POST /admin/admins/{id}/password", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminSetAdminPassword(db, w, r)
+	})))
+	mux.Handle("POST /admin/admins/{id}/reset-totp", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminResetAdminTOTP(db, w, r)
+	})))
+	mux.Handle("POST /admin/admins/{id}/logout", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminLogoutAdmin(db, w, r)
+	})))
+	mux.Handle("POST /admin/admins/{id}/delete", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminDeleteAdmin(db, w, r)
+	})))
+
+	// Two-factor authentication and sessions of the logged-in admin
+	mux.Handle("GET /admin/security", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminSecurity(db, cfg, w, r)
+	})))
+	mux.Handle("POST /admin/security/totp/setup", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminTOTPSetup(db, cfg, w, r)
+	})))
+	mux.Handle("POST /admin/security/totp/enable", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminTOTPEnable(db, cfg, w, r)
+	})))
+	mux.Handle("POST /admin/security/totp/disable", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminTOTPDisable(db, cfg, w, r)
+	})))
+	mux.Handle("POST /admin/security/recovery-codes", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminRegenerateRecoveryCodes(db, cfg, w, r)
+	})))
+	mux.Handle("POST /admin/security/sessions/revoke", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminRevokeSessions(db, w, r)
+	})))
+	mux.Handle("POST /admin/security/sessions/{id}/revoke", adminAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAdminRevokeSession(db, w, r)
+	})))
+
+	// Static files (served from embedded filesystem)
+	mux.Handle("GET /static/", http.FileServer(http.FS(staticFS)))
+
+	return LoggingMiddleware(CSRFMiddleware(TracingMiddleware(mux)))
+}
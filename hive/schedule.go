@@ -0,0 +1,138 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// A thread created with a future publish_at is scheduled: only its author
+// can see it, and it takes no replies or status tags, until the publisher
+// gets to it. Publishing dates the thread to that moment, records its
+// mentions, and announces it on the event stream as a new thread.
+
+// publishedCondition matches published threads, aliased t.
+const publishedCondition = "t.publish_at IS NULL"
+
+// scheduledFor returns publishAt in UTC if it is after now, or nil if a
+// thread with that publish time should go out immediately.
+func scheduledFor(publishAt *time.Time, now time.Time) *time.Time {
+	if publishAt == nil || !publishAt.After(now) {
+		return nil
+	}
+	return utcTime(publishAt)
+}
+
+// visibleTo reports whether agent can see the thread: any published thread
+// its visibility lets it read, and its own scheduled ones.
+func (t Thread) visibleTo(agent *Agent) bool {
+	if t.PublishAt != nil {
+		return agent != nil && t.AgentID == agent.ID
+	}
+	return t.readableBy(agent)
+}
+
+// loadVisibleThread is loadThread for threads agent can see, with only the
+// backlinks from threads it can see.
+func loadVisibleThread(ctx context.Context, db *sql.DB, agent *Agent, threadID string) (Thread, error) {
+	return loadVisibleThreadPage(ctx, db, agent, threadID, -1, 0)
+}
+
+// loadVisibleThreadPage is loadVisibleThread with a page of the replies, as
+// loadThreadPage has.
+func loadVisibleThreadPage(ctx context.Context, db *sql.DB, agent *Agent, threadID string, limit, offset int) (Thread, error) {
+	t, err := loadThreadPage(ctx, db, threadID, limit, offset)
+	if err != nil {
+		return Thread{}, err
+	}
+	if !t.visibleTo(agent) {
+		return Thread{}, notFoundError("thread not found")
+	}
+	t.ReferencedBy, err = threadBacklinks(ctx, db, agent, threadID)
+	if err != nil {
+		return Thread{}, err
+	}
+	return t, nil
+}
+
+// publishDue publishes the scheduled threads whose publish time has passed
+// and returns how many it published.
+func publishDue(ctx context.Context, db *sql.DB, bus *EventBus) (int, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id FROM threads WHERE publish_at IS NOT NULL AND publish_at <= ? ORDER BY publish_at`,
+		time.Now().UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("query scheduled threads: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan scheduled thread: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterate scheduled threads: %w", err)
+	}
+
+	published := 0
+	for _, id := range ids {
+		now := time.Now()
+		res, err := db.ExecContext(ctx,
+			`UPDATE threads SET publish_at = NULL, created_at = ?, updated_at = ?, last_activity_at = ? WHERE id = ? AND publish_at IS NOT NULL`,
+			now, now, now, id,
+		)
+		if err != nil {
+			return published, fmt.Errorf("publish thread %s: %w", id, err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue // deleted or published meanwhile
+		}
+		published++
+
+		t, err := loadThread(ctx, db, id)
+		if err != nil {
+			return published, err
+		}
+		if err := recordMentions(ctx, db, id, nil, t.AgentID, t.Body); err != nil {
+			log.Printf("record thread mentions: %v", err)
+		}
+		if err := recordReferences(ctx, db, id, nil, t.Body); err != nil {
+			log.Printf("record thread references: %v", err)
+		}
+		bus.Publish(Event{Kind: eventThreadCreated, ThreadID: id, Thread: &t, CreatedAt: now})
+	}
+	return published, nil
+}
+
+// StartPublisher publishes due threads now and then every interval until
+// ctx is done.
+func StartPublisher(ctx context.Context, db *sql.DB, bus *EventBus, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			n, err := publishDue(ctx, db, bus)
+			if err != nil {
+				log.Printf("publisher: %v", err)
+			}
+			if n > 0 {
+				log.Printf("publisher: published %d scheduled threads", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,273 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Threads, replies, agents, and announcements are indexed for full-text
+// search in search_index, an FTS5 table kept current by the triggers below
+// whatever code path writes them. The index holds its own copy of the text
+// rather than pointing at rowids, which VACUUM may renumber in tables keyed
+// by text IDs.
+
+// maxSearchResults caps the results of one search.
+const maxSearchResults = 100
+
+// Kinds of object in the search index.
+const (
+	searchThread       = "thread"
+	searchReply        = "reply"
+	searchAgent        = "agent"
+	searchAnnouncement = "announcement"
+)
+
+// searchSnippetStart and searchSnippetEnd bracket matched terms in
+// snippets until they're escaped and highlighted.
+const (
+	searchSnippetStart = "\x02"
+	searchSnippetEnd   = "\x03"
+)
+
+// searchSources are the indexed tables with the expressions giving the
+// title and body indexed for each row, where ROW is the row.
+var searchSources = []struct {
+	table, kind string
+	// columns are those whose updates re-index the row
+	columns     string
+	title, body string
+}{
+	{"threads", searchThread, "title, body", "ROW.title", "ROW.body"},
+	{"replies", searchReply, "body", "''", "ROW.body"},
+	{"agents", searchAgent, "name, owner, description", "ROW.name", "ROW.owner || ' ' || ROW.description"},
+	{"announcements", searchAnnouncement, "title, body", "ROW.title", "ROW.body"},
+}
+
+// searchIndexSchema is the schema for the search index and its triggers.
+var searchIndexSchema = func() string {
+	var b strings.Builder
+	b.WriteString(`
+	CREATE VIRTUAL TABLE IF NOT EXISTS search_index USING fts5(
+		kind UNINDEXED,
+		object_id UNINDEXED,
+		title,
+		body,
+		tokenize = 'porter unicode61'
+	);
+	`)
+	for _, s := range searchSources {
+		insert := fmt.Sprintf("\n\t\tINSERT INTO search_index (kind, object_id, title, body) VALUES ('%s', NEW.id, %s, %s);",
+			s.kind, strings.ReplaceAll(s.title, "ROW", "NEW"), strings.ReplaceAll(s.body, "ROW", "NEW"))
+		remove := fmt.Sprintf("\n\t\tDELETE FROM search_index WHERE kind = '%s' AND object_id = OLD.id;", s.kind)
+		fmt.Fprintf(&b, "CREATE TRIGGER IF NOT EXISTS index_%s_insert AFTER INSERT ON %s BEGIN%s\n\tEND;\n", s.table, s.table, insert)
+		fmt.Fprintf(&b, "CREATE TRIGGER IF NOT EXISTS index_%s_update AFTER UPDATE OF %s ON %s BEGIN%s%s\n\tEND;\n", s.table, s.columns, s.table, remove, insert)
+		fmt.Fprintf(&b, "CREATE TRIGGER IF NOT EXISTS index_%s_delete AFTER DELETE ON %s BEGIN%s\n\tEND;\n", s.table, s.table, remove)
+	}
+	return b.String()
+}()
+
+// backfillSearchIndex indexes everything already in a database that
+// predates the search index.
+func backfillSearchIndex(db *sql.DB) error {
+	var indexed bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM search_index)").Scan(&indexed); err != nil {
+		return fmt.Errorf("check search index: %w", err)
+	}
+	if indexed {
+		return nil
+	}
+	for _, s := range searchSources {
+		_, err := db.Exec(fmt.Sprintf("INSERT INTO search_index (kind, object_id, title, body) SELECT '%s', id, %s, %s FROM %s",
+			s.kind, strings.ReplaceAll(s.title, "ROW", s.table), strings.ReplaceAll(s.body, "ROW", s.table), s.table))
+		if err != nil {
+			return fmt.Errorf("index %s: %w", s.table, err)
+		}
+	}
+	return nil
+}
+
+// ftsQuery turns what someone typed into an FTS5 query matching everything
+// that has every word, or a word starting with it, so that FTS5 syntax in
+// the input is searched for rather than interpreted.
+func ftsQuery(q string) string {
+	var terms []string
+	for _, word := range strings.Fields(q) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// highlightSnippet escapes a snippet and highlights the terms bracketed by
+// searchSnippetStart and searchSnippetEnd.
+func highlightSnippet(snippet string) template.HTML {
+	escaped := template.HTMLEscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, searchSnippetStart, "<mark>")
+	escaped = strings.ReplaceAll(escaped, searchSnippetEnd, "</mark>")
+	return template.HTML(escaped)
+}
+
+// SearchResult is a thread, reply, agent, or announcement matching a
+// search.
+type SearchResult struct {
+	// Kind is thread, reply, agent, or announcement.
+	Kind string
+	ID   string
+	// Title is the thread's title (for a reply, its thread's), the agent's
+	// name, or the announcement's title.
+	Title string
+	// Snippet is the matching text with the matched terms highlighted.
+	Snippet template.HTML
+	// ThreadID is the thread, or the reply's thread.
+	ThreadID string
+	// AgentName is the author, the poster of an announcement, or the agent
+	// itself.
+	AgentName   string
+	WorkspaceID string
+	CreatedAt   time.Time
+}
+
+// searchFilter narrows a search.
+type searchFilter struct {
+	// Kind keeps one kind of object.
+	Kind string
+	// Agent keeps what the agent with this name wrote, or the agent.
+	Agent string
+	// From and To keep what was created in [From, To).
+	From, To *time.Time
+}
+
+// searchAll returns the best matches for q across every workspace, best
+// first.
+func searchAll(ctx context.Context, db *sql.DB, q string, f searchFilter) ([]SearchResult, error) {
+	match := ftsQuery(q)
+	if match == "" {
+		return nil, inputError("q is required")
+	}
+
+	createdAt := "COALESCE(t.created_at, r.created_at, ag.created_at, an.created_at)"
+	// Skip anything whose row, or reply's thread, is gone but still indexed
+	conditions := []string{"search_index MATCH ?", "COALESCE(t.id, rt.id, ag.id, an.id) IS NOT NULL"}
+	args := []interface{}{searchSnippetStart, searchSnippetEnd, match}
+	if f.Kind != "" {
+		conditions = append(conditions, "s.kind = ?")
+		args = append(args, f.Kind)
+	}
+	if f.Agent != "" {
+		conditions = append(conditions, "au.name = ?")
+		args = append(args, f.Agent)
+	}
+	if f.From != nil {
+		conditions = append(conditions, createdAt+" >= ?")
+		args = append(args, *f.From)
+	}
+	if f.To != nil {
+		conditions = append(conditions, createdAt+" < ?")
+		args = append(args, *f.To)
+	}
+	args = append(args, maxSearchResults)
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT s.kind, s.object_id, COALESCE(t.title, rt.title, ag.name, an.title, ''),
+			snippet(search_index, -1, ?, ?, '…', 24),
+			COALESCE(t.id, r.thread_id, ''), COALESCE(au.name, ''),
+			COALESCE(t.workspace_id, rt.workspace_id, ag.workspace_id, an.workspace_id, ''),
+			t.created_at, r.created_at, ag.created_at, an.created_at
+		FROM search_index s
+		LEFT JOIN threads t ON s.kind = 'thread' AND t.id = s.object_id
+		LEFT JOIN replies r ON s.kind = 'reply' AND r.id = s.object_id
+		LEFT JOIN threads rt ON rt.id = r.thread_id
+		LEFT JOIN agents ag ON s.kind = 'agent' AND ag.id = s.object_id
+		LEFT JOIN announcements an ON s.kind = 'announcement' AND an.id = s.object_id
+		LEFT JOIN agents au ON au.id = COALESCE(t.agent_id, r.agent_id, ag.id, an.agent_id)
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY rank
+		LIMIT ?`, args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("search: %w", err)
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var res SearchResult
+		var snippet string
+		var created [4]*time.Time
+		if err := rows.Scan(&res.Kind, &res.ID, &res.Title, &snippet, &res.ThreadID, &res.AgentName, &res.WorkspaceID,
+			&created[0], &created[1], &created[2], &created[3]); err != nil {
+			return nil, fmt.Errorf("scan search result: %w", err)
+		}
+		res.Snippet = highlightSnippet(snippet)
+		for _, c := range created {
+			if c != nil {
+				res.CreatedAt = *c
+			}
+		}
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate search results: %w", err)
+	}
+	return results, nil
+}
+
+// handleAdminSearch searches threads, replies, agents, and announcements in
+// every workspace for ?q=, narrowed by ?kind=, ?agent=, and the dates
+// ?from= and ?to= (inclusive).
+func handleAdminSearch(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	data := map[string]interface{}{
+		"Query": q.Get("q"),
+		"Kind":  q.Get("kind"),
+		"Agent": q.Get("agent"),
+		"From":  q.Get("from"),
+		"To":    q.Get("to"),
+	}
+
+	f := searchFilter{Kind: q.Get("kind"), Agent: q.Get("agent")}
+	for _, d := range []struct {
+		param string
+		dest  **time.Time
+		days  int
+	}{{"from", &f.From, 0}, {"to", &f.To, 1}} {
+		v := q.Get(d.param)
+		if v == "" {
+			continue
+		}
+		day, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			data["Error"] = d.param + " must be a date like 2006-01-02"
+			renderAdminTemplate(w, r, "search.html", data)
+			return
+		}
+		day = day.AddDate(0, 0, d.days)
+		*d.dest = &day
+	}
+
+	workspaces, err := listWorkspaces(r.Context(), db)
+	if err != nil {
+		log.Printf("admin search workspaces query error: %v", err)
+		http.Error(w, "failed to search", http.StatusInternalServerError)
+		return
+	}
+	data["WorkspaceNames"] = workspaceNames(workspaces)
+
+	if strings.TrimSpace(q.Get("q")) != "" {
+		results, err := searchAll(r.Context(), db, q.Get("q"), f)
+		if err != nil {
+			log.Printf("admin search error: %v", err)
+			http.Error(w, "failed to search", http.StatusInternalServerError)
+			return
+		}
+		data["Results"] = results
+		data["Searched"] = true
+	}
+
+	renderAdminTemplate(w, r, "search.html", data)
+}
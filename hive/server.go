@@ -0,0 +1,318 @@
+// Package hive is the Agentic Forum server: its database, HTTP and gRPC
+// APIs, dashboards, and background jobs. The agentic-forum command runs it
+// standalone; orchestration frameworks can embed it in-process instead,
+// and tests can run it against an in-memory database under httptest:
+//
+//	cfg := hive.LoadConfig()
+//	cfg.DBPath = hive.InMemory
+//	srv, err := hive.NewServer(cfg)
+//	if err != nil {
+//		return err
+//	}
+//	defer srv.Close()
+//	ts := httptest.NewServer(srv)
+//	defer ts.Close()
+//
+// Each server keeps to its own configuration, so several can share a
+// process.
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+)
+
+// Server is a forum. It is an http.Handler serving the HTTP API and
+// dashboards; GRPCServer serves the gRPC API. ListenAndServe runs both
+// standalone with the background jobs, or an embedding program can mount
+// the handler itself and call Start.
+type Server struct {
+	cfg Config
+	db  *sql.DB
+	// pinned keeps an in-memory database alive: it is dropped when its last
+	// connection closes.
+	pinned *sql.Conn
+	bus    *EventBus
+	// id names this replica as a lease holder.
+	id string
+
+	handler     http.Handler
+	grpc        *grpc.Server
+	retention   *Retention
+	maintenance *Maintenance
+	embeddings  *Embeddings
+	discord     *Discord
+	mailer      *Mailer
+	cluster     *Cluster
+	usage       *Usage
+
+	shutdownTracing func(context.Context) error
+	closeOnce       sync.Once
+	closeErr        error
+}
+
+// NewServer checks cfg, opens (restoring and migrating) the database, and
+// sets up the server. Nothing runs until it serves or Start is called.
+func NewServer(cfg Config) (*Server, error) {
+	if !validDuplicatePolicies[cfg.DuplicateThreads] {
+		return nil, fmt.Errorf("invalid DUPLICATE_THREADS %q (use warn, reject, or off)", cfg.DuplicateThreads)
+	}
+	if err := cfg.Limits.check(); err != nil {
+		return nil, fmt.Errorf("invalid limits: %w", err)
+	}
+	if cfg.CacheTTL < 0 {
+		return nil, fmt.Errorf("invalid CACHE_TTL %s (must not be negative)", cfg.CacheTTL)
+	}
+	if cfg.AdminSessionTTL <= 0 {
+		return nil, fmt.Errorf("invalid ADMIN_SESSION_TTL %s (must be positive)", cfg.AdminSessionTTL)
+	}
+	if cfg.AdminSessionIdle < 0 {
+		return nil, fmt.Errorf("invalid ADMIN_SESSION_IDLE %s (must not be negative)", cfg.AdminSessionIdle)
+	}
+	if cfg.PasswordResetTTL <= 0 {
+		return nil, fmt.Errorf("invalid PASSWORD_RESET_TTL %s (must be positive)", cfg.PasswordResetTTL)
+	}
+
+	shutdownTracing, err := initTracing(context.Background(), cfg)
+	if err != nil {
+		return nil, fmt.Errorf("init tracing: %w", err)
+	}
+
+	if cfg.RestoreFrom != "" {
+		if cfg.DBPath == InMemory {
+			return nil, errors.New("RESTORE_FROM needs a database file, not an in-memory database")
+		}
+		if err := restoreDB(cfg.DBPath, cfg.RestoreFrom); err != nil {
+			return nil, fmt.Errorf("restore database: %w", err)
+		}
+	}
+
+	db, err := InitDB(cfg.DBPath, cfg.SQLite)
+	if err != nil {
+		return nil, fmt.Errorf("init database: %w", err)
+	}
+	s := &Server{cfg: cfg, db: db, bus: NewEventBus(), id: uuid.New().String(), shutdownTracing: shutdownTracing}
+	if cfg.DBPath == InMemory {
+		if s.pinned, err = db.Conn(context.Background()); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("init database: %w", err)
+		}
+	}
+	if err := s.setup(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// setup creates the server's components and routes.
+func (s *Server) setup() error {
+	cfg, db := s.cfg, s.db
+	if err := bootstrapAdmin(db, cfg); err != nil {
+		return fmt.Errorf("bootstrap admin: %w", err)
+	}
+
+	limiter := NewRateLimiter(cfg)
+	s.retention = NewRetention(db, cfg)
+	var err error
+	if s.maintenance, err = NewMaintenance(db, cfg); err != nil {
+		return fmt.Errorf("set up maintenance: %w", err)
+	}
+	if s.embeddings, err = NewEmbeddings(db, cfg); err != nil {
+		return fmt.Errorf("set up embeddings: %w", err)
+	}
+	summaries, err := NewSummaries(db, cfg)
+	if err != nil {
+		return fmt.Errorf("set up summaries: %w", err)
+	}
+	tagger, err := NewTagger(db, cfg)
+	if err != nil {
+		return fmt.Errorf("set up automatic tagging: %w", err)
+	}
+	s.discord = NewDiscord(db, cfg)
+	if s.mailer, err = NewMailer(db, cfg); err != nil {
+		return fmt.Errorf("set up email: %w", err)
+	}
+	if s.cluster, err = NewCluster(cfg, s.bus, s.db); err != nil {
+		return fmt.Errorf("set up event bus: %w", err)
+	}
+	network, err := NewNetworkPolicy(cfg)
+	if err != nil {
+		return err
+	}
+	s.usage = NewUsage(db)
+	s.handler = network.Middleware(SetupRoutes(db, cfg, s.bus, limiter, s.retention, s.maintenance, s.embeddings, summaries, tagger, s.discord, s.mailer, s.usage))
+	s.grpc = newGRPCServer(db, s.bus, cfg.Limits, limiter, s.usage, network, cfg.RequestTimeout)
+	return nil
+}
+
+// ServeHTTP serves the HTTP API and dashboards.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r.WithContext(withLimits(r.Context(), s.cfg.Limits)))
+}
+
+// GRPCServer returns the gRPC API, to serve on a listener of the caller's.
+func (s *Server) GRPCServer() *grpc.Server {
+	return s.grpc
+}
+
+// DB returns the server's database.
+func (s *Server) DB() *sql.DB {
+	return s.db
+}
+
+// Store returns the forum operations the APIs share, publishing events to
+// the server's subscribers.
+func (s *Server) Store() Store {
+	return newSQLStore(s.db, s.bus, s.cfg.Limits)
+}
+
+// Start runs the background jobs, and connects to the shared event bus if
+// there is one, until ctx ends. Embeddings, Discord, and usage counting
+// run on every replica, for what happens there; the scheduled jobs
+// (retention, maintenance, scheduled publishing, announcement expiry, SLA
+// monitoring, and email) run only while this replica holds the jobs lease.
+func (s *Server) Start(ctx context.Context) {
+	s.cluster.Start(ctx)
+	go runWhileLeader(ctx, s.db, jobsLease, s.id, func(ctx context.Context) {
+		s.retention.Start(ctx)
+		s.maintenance.Start(ctx)
+		StartPublisher(ctx, s.db, s.bus, s.cfg.PublishInterval)
+		StartAnnouncementExpiry(ctx, s.db, announcementExpiryInterval)
+		StartSLAMonitor(ctx, s.db, s.cfg.SLAInterval)
+		s.mailer.Start(ctx)
+	})
+	s.embeddings.Start(ctx, s.bus)
+	s.discord.Start(ctx, s.bus)
+	s.usage.Start(ctx)
+}
+
+// ListenAndServe serves HTTP on the configured port, and gRPC on the gRPC
+// port if one is set, and runs the background jobs. When ctx ends (or
+// serving fails) it lets in-flight requests finish for up to the shutdown
+// timeout and closes the server.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	lis, err := net.Listen("tcp", ":"+s.cfg.Port)
+	if err != nil {
+		s.Close()
+		return fmt.Errorf("listen: %w", err)
+	}
+	var grpcLis net.Listener
+	if s.cfg.GRPCPort != "" {
+		if grpcLis, err = net.Listen("tcp", ":"+s.cfg.GRPCPort); err != nil {
+			lis.Close()
+			s.Close()
+			return fmt.Errorf("listen for gRPC: %w", err)
+		}
+	}
+
+	failed := make(chan error, 2)
+	if grpcLis != nil {
+		log.Printf("gRPC API listening on %s", grpcLis.Addr())
+		go func() {
+			if err := s.grpc.Serve(grpcLis); err != nil {
+				failed <- fmt.Errorf("gRPC server: %w", err)
+			}
+		}()
+	}
+	srv := &http.Server{Handler: s}
+	log.Printf("Agentic Forum listening on %s", lis.Addr())
+	go func() {
+		if err := srv.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+			failed <- fmt.Errorf("http server: %w", err)
+		}
+	}()
+
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	defer stopJobs()
+	s.Start(jobsCtx)
+
+	select {
+	case <-ctx.Done():
+	case err = <-failed:
+	}
+	stopJobs()
+
+	log.Printf("shutting down (waiting up to %s for in-flight requests)", s.cfg.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+	defer cancel()
+
+	// Event streams never finish on their own; end them first so they don't
+	// hold up the drain.
+	s.bus.Close()
+
+	if grpcLis != nil {
+		stopGRPC(shutdownCtx, s.grpc)
+	}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("http shutdown: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		log.Printf("close database: %v", err)
+	}
+	log.Printf("shutdown complete")
+	return err
+}
+
+// Close ends event streams, writes counted usage, gives up the jobs lease,
+// checkpoints and closes the database, and flushes traces. Requests still
+// being served fail; ListenAndServe drains them before closing. Closing
+// again does nothing.
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() {
+		s.bus.Close()
+
+		if s.usage != nil {
+			if err := s.usage.flush(context.Background()); err != nil {
+				log.Printf("usage: %v", err)
+			}
+		}
+		// So that another replica can run the jobs without waiting for the
+		// lease to expire
+		if err := releaseLease(context.Background(), s.db, jobsLease, s.id); err != nil {
+			log.Printf("leases: release %s: %v", jobsLease, err)
+		}
+		// Fold the WAL back into the main database file so a copied-off
+		// forum.db is complete
+		if _, err := s.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+			log.Printf("wal checkpoint: %v", err)
+		}
+		if s.pinned != nil {
+			s.pinned.Close()
+		}
+		s.closeErr = s.db.Close()
+		forgetWrites(s.db)
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownTimeout)
+		defer cancel()
+		if err := s.shutdownTracing(ctx); err != nil {
+			log.Printf("flush traces: %v", err)
+		}
+	})
+	return s.closeErr
+}
+
+// stopGRPC lets in-flight gRPC calls finish, cutting them off if ctx ends
+// first.
+func stopGRPC(ctx context.Context, s *grpc.Server) {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		log.Printf("grpc shutdown: %v; closing remaining calls", ctx.Err())
+		s.Stop()
+	}
+}
@@ -0,0 +1,274 @@
+package hive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestServer starts a server on an in-memory database under httptest,
+// with cfg changed by configure if it isn't nil, and returns it with the
+// API key of an agent that can read and write.
+func newTestServer(t *testing.T, configure func(*Config)) (*httptest.Server, string) {
+	t.Helper()
+	_, ts, key := startTestServer(t, configure)
+	return ts, key
+}
+
+// startTestServer is newTestServer, also returning the Server for tests
+// that look at its database.
+func startTestServer(t *testing.T, configure func(*Config)) (*Server, *httptest.Server, string) {
+	t.Helper()
+	cfg := LoadConfig()
+	cfg.DBPath = InMemory
+	if configure != nil {
+		configure(&cfg)
+	}
+	srv, err := NewServer(cfg)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	ts := httptest.NewServer(srv)
+	t.Cleanup(ts.Close)
+
+	_, key, err := createAgent(context.Background(), srv.DB(), "tester", "tests", "", []string{scopeRead, scopeWrite}, roleWorker, nil)
+	if err != nil {
+		t.Fatalf("createAgent: %v", err)
+	}
+	return srv, ts, key
+}
+
+// do sends a request with key, and a JSON body if body isn't empty, and
+// returns the response's status and decoded body.
+func do(t *testing.T, ts *httptest.Server, key, method, path, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	var out map[string]interface{}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("%s %s: decode: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode, out
+}
+
+func TestServerSmoke(t *testing.T) {
+	ts, key := newTestServer(t, nil)
+
+	if status, _ := do(t, ts, "", "GET", "/api/v1/threads", ""); status != http.StatusUnauthorized {
+		t.Errorf("threads without a key: status %d, want 401", status)
+	}
+
+	status, thread := do(t, ts, key, "POST", "/api/v1/threads", `{"title": "Smoke", "body": "Does it run?", "tags": ["test"]}`)
+	if status != http.StatusCreated {
+		t.Fatalf("create thread: status %d (%v), want 201", status, thread)
+	}
+	id, _ := thread["id"].(string)
+
+	status, reply := do(t, ts, key, "POST", "/api/v1/threads/"+id+"/replies", `{"body": "It runs."}`)
+	if status != http.StatusCreated {
+		t.Fatalf("create reply: status %d (%v), want 201", status, reply)
+	}
+
+	status, got := do(t, ts, key, "GET", "/api/v1/threads/"+id, "")
+	if status != http.StatusOK {
+		t.Fatalf("get thread: status %d, want 200", status)
+	}
+	if got["title"] != "Smoke" {
+		t.Errorf("get thread: title %v, want Smoke", got["title"])
+	}
+
+	resp, err := ts.Client().Get(ts.URL + "/dashboard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("dashboard: status %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestServersKeepTheirOwnLimits(t *testing.T) {
+	strict, strictKey := newTestServer(t, func(cfg *Config) { cfg.Limits.MaxTitleLength = 10 })
+	// Created after the strict one, which used to put its limits in force
+	// for both
+	lax, laxKey := newTestServer(t, nil)
+
+	thread := `{"title": "A title longer than ten characters", "body": "body"}`
+	status, body := do(t, strict, strictKey, "POST", "/api/v1/threads", thread)
+	if status != http.StatusBadRequest || body["field"] != "title" || body["limit"] != float64(10) {
+		t.Errorf("strict server: status %d (%v), want 400 for the title, limit 10", status, body)
+	}
+	if status, body := do(t, lax, laxKey, "POST", "/api/v1/threads", thread); status != http.StatusCreated {
+		t.Errorf("lax server: status %d (%v), want 201", status, body)
+	}
+
+	cfg := LoadConfig()
+	cfg.DBPath = InMemory
+	cfg.Limits.MaxTags = 0
+	if _, err := NewServer(cfg); err == nil || !strings.Contains(err.Error(), "invalid limits") {
+		t.Errorf("NewServer with no tags allowed: error %v, want invalid limits", err)
+	}
+}
+
+func TestServersCountTheirOwnWrites(t *testing.T) {
+	busy, ts, key := startTestServer(t, nil)
+	quiet, _, _ := startTestServer(t, nil)
+
+	tables := []string{"threads"}
+	before := writesOf(quiet.DB()).sum(tables)
+	busyBefore := writesOf(busy.DB()).sum(tables)
+	if status, _ := do(t, ts, key, "POST", "/api/v1/threads", `{"title": "Busy", "body": "Writing."}`); status != http.StatusCreated {
+		t.Fatalf("create thread: status %d", status)
+	}
+	if got := writesOf(busy.DB()).sum(tables); got == busyBefore {
+		t.Error("busy server: thread write not counted")
+	}
+	// Otherwise the quiet server's caches would be dropped for writes to
+	// another database
+	if got := writesOf(quiet.DB()).sum(tables); got != before {
+		t.Errorf("quiet server: thread writes went from %d to %d", before, got)
+	}
+
+	busy.Close()
+	if writesOf(busy.DB()) != nil {
+		t.Error("closed server's write counts kept")
+	}
+}
+
+func TestReplyPages(t *testing.T) {
+	ts, key := newTestServer(t, nil)
+	_, thread := do(t, ts, key, "POST", "/api/v1/threads", `{"title": "Long", "body": "Many replies."}`)
+	id, _ := thread["id"].(string)
+
+	// r0, r1 (answering r0), r2, r3 (answering r1): tree order r0 r1 r3 r2
+	var ids []string
+	for i, parent := range []int{-1, 0, -1, 1} {
+		body := fmt.Sprintf(`{"body": "r%d"}`, i)
+		if parent >= 0 {
+			body = fmt.Sprintf(`{"body": "r%d", "parent_reply_id": %q}`, i, ids[parent])
+		}
+		status, reply := do(t, ts, key, "POST", "/api/v1/threads/"+id+"/replies", body)
+		if status != http.StatusCreated {
+			t.Fatalf("reply %d: status %d (%v)", i, status, reply)
+		}
+		ids = append(ids, reply["id"].(string))
+	}
+
+	page := func(path string) ([]string, []int, string) {
+		t.Helper()
+		req, _ := http.NewRequest("GET", ts.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var replies []Reply
+		if strings.Contains(path, "/replies") {
+			err = json.NewDecoder(resp.Body).Decode(&replies)
+		} else {
+			var th Thread
+			err = json.NewDecoder(resp.Body).Decode(&th)
+			replies = th.Replies
+		}
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		var bodies []string
+		var depths []int
+		for _, r := range replies {
+			bodies = append(bodies, r.Body)
+			depths = append(depths, r.Depth)
+		}
+		return bodies, depths, resp.Header.Get("X-Total-Count")
+	}
+
+	for _, tt := range []struct {
+		path       string
+		wantBodies string
+		wantDepths string
+	}{
+		{"/api/v1/threads/" + id, "[r0 r1 r3 r2]", "[0 1 2 0]"},
+		{"/api/v1/threads/" + id + "?replies_per_page=2&replies_page=2", "[r3 r2]", "[2 0]"},
+		{"/api/v1/threads/" + id + "/replies?per_page=3", "[r0 r1 r3]", "[0 1 2]"},
+		{"/api/v1/threads/" + id + "/replies?per_page=3&page=2", "[r2]", "[0]"},
+		{"/api/v1/threads/" + id + "/replies?page=3", "[]", "[]"},
+	} {
+		bodies, depths, total := page(tt.path)
+		if fmt.Sprint(bodies) != tt.wantBodies || fmt.Sprint(depths) != tt.wantDepths {
+			t.Errorf("GET %s: replies %v at depths %v, want %s at %s", tt.path, bodies, depths, tt.wantBodies, tt.wantDepths)
+		}
+		if strings.Contains(tt.path, "/replies") && total != "4" {
+			t.Errorf("GET %s: X-Total-Count %q, want 4", tt.path, total)
+		}
+	}
+
+	_, resp := do(t, ts, key, "POST", "/api/v1/graphql",
+		fmt.Sprintf(`{"query": "{ thread(id: \"%s\") { replies(limit: 2, offset: 1) { body } } }"}`, id))
+	data, _ := resp["data"].(map[string]interface{})
+	thread, _ = data["thread"].(map[string]interface{})
+	if got := fmt.Sprint(thread["replies"]); got != "[map[body:r1] map[body:r3]]" {
+		t.Errorf("GraphQL replies(limit: 2, offset: 1) = %s, want r1 and r3", got)
+	}
+}
+
+func TestCreateThreadIsAtomic(t *testing.T) {
+	srv, ts, key := startTestServer(t, nil)
+	if _, _, err := createAgent(context.Background(), srv.DB(), "helper", "tests", "", []string{scopeRead}, roleWorker, nil); err != nil {
+		t.Fatalf("createAgent: %v", err)
+	}
+	// Adding participants fails after the thread is inserted
+	if _, err := srv.DB().Exec(`CREATE TRIGGER refuse_participants BEFORE INSERT ON thread_participants
+		BEGIN SELECT RAISE(ABORT, 'refused'); END`); err != nil {
+		t.Fatal(err)
+	}
+
+	status, _ := do(t, ts, key, "POST", "/api/v1/threads", `{"title": "Half", "body": "Made.", "participants": ["helper"]}`)
+	if status != http.StatusInternalServerError {
+		t.Fatalf("create thread: status %d, want 500", status)
+	}
+	var threads int
+	if err := srv.DB().QueryRow("SELECT COUNT(*) FROM threads").Scan(&threads); err != nil {
+		t.Fatal(err)
+	}
+	if threads != 0 {
+		t.Errorf("%d threads left behind by a failed create", threads)
+	}
+}
+
+func TestCreateThreadValidatesBeforeDuplicateCheck(t *testing.T) {
+	ts, key := newTestServer(t, func(cfg *Config) { cfg.DuplicateThreads = duplicatesReject })
+	body := `{"title": "Deploy fails on staging", "body": "The deploy to staging fails at the migration step."}`
+	if status, _ := do(t, ts, key, "POST", "/api/v1/threads", body); status != http.StatusCreated {
+		t.Fatalf("first thread: status %d", status)
+	}
+
+	// A duplicate with a bad priority is bad input, not a duplicate
+	status, resp := do(t, ts, key, "POST", "/api/v1/threads", strings.Replace(body, "{", `{"priority": "someday", `, 1))
+	if status != http.StatusBadRequest {
+		t.Errorf("duplicate with bad priority: status %d (%v), want 400", status, resp)
+	}
+	if status, _ := do(t, ts, key, "POST", "/api/v1/threads", body); status != http.StatusConflict {
+		t.Errorf("duplicate: status %d, want 409", status)
+	}
+}
@@ -0,0 +1,265 @@
+package hive
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Admin logins are sessions kept in admin_sessions. The cookie carries a
+// random token and only its SHA-256 hash is stored, so a copy of the
+// database can't be used to log in. A session ends when its admin logs
+// out, AdminSessionTTL after login however active it is, after
+// AdminSessionIdle without a request, or when it is revoked: from the
+// Security page, by another admin, or by a change of password.
+
+const adminSessionsSchema = `
+CREATE TABLE IF NOT EXISTS admin_sessions (
+	id TEXT PRIMARY KEY,
+	admin_id TEXT NOT NULL REFERENCES admins(id) ON DELETE CASCADE,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at DATETIME NOT NULL,
+	last_seen_at DATETIME NOT NULL,
+	expires_at DATETIME NOT NULL,
+	ip TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_admin_sessions_admin ON admin_sessions(admin_id);
+`
+
+// adminSessionCookie is the cookie holding an admin's session token.
+const adminSessionCookie = "admin_session"
+
+// adminSessionTouchInterval is how out of date a session's last_seen_at
+// may get before a request updates it, so that not every page view is a
+// write. Idle timeouts are accurate to within it, or to within half of
+// themselves if shorter.
+const adminSessionTouchInterval = time.Minute
+
+// maxSessionUserAgent caps the user agent stored with a session.
+const maxSessionUserAgent = 256
+
+const adminSessionContextKey contextKey = "admin_session"
+
+// adminSessionFromContext returns the ID of the session the request was
+// made in.
+func adminSessionFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(adminSessionContextKey).(string)
+	return id
+}
+
+// AdminSession is one of an admin's logins.
+type AdminSession struct {
+	ID         string    `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	// Current marks the session the list was requested from.
+	Current bool `json:"current"`
+}
+
+// newToken returns a random secret token for a session or link.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken returns the form a secret token is stored in.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// activeSessionCutoffs returns the times a live session must expire after
+// and have been seen after, as of now.
+func activeSessionCutoffs(cfg Config, now time.Time) (expires, seen time.Time) {
+	if cfg.AdminSessionIdle > 0 {
+		seen = now.Add(-cfg.AdminSessionIdle)
+	}
+	return now, seen
+}
+
+// createAdminSession starts a session for an admin logging in with r and
+// returns its token and when it expires. Sessions that have run out, the
+// admin's or anyone's, are cleared out on the way.
+func createAdminSession(ctx context.Context, db *sql.DB, cfg Config, adminID string, r *http.Request) (string, time.Time, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("generate session token: %w", err)
+	}
+
+	now := time.Now()
+	expires, seen := activeSessionCutoffs(cfg, now)
+	if _, err := db.ExecContext(ctx,
+		"DELETE FROM admin_sessions WHERE expires_at <= ? OR last_seen_at <= ?", expires, seen,
+	); err != nil {
+		log.Printf("admin sessions: delete expired: %v", err)
+	}
+
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxSessionUserAgent {
+		userAgent = userAgent[:maxSessionUserAgent]
+	}
+	expiresAt := now.Add(cfg.AdminSessionTTL)
+	if _, err := db.ExecContext(ctx,
+		`INSERT INTO admin_sessions (id, admin_id, token_hash, created_at, last_seen_at, expires_at, ip, user_agent)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		uuid.New().String(), adminID, hashToken(token), now, now, expiresAt, requestAddr(r), userAgent,
+	); err != nil {
+		return "", time.Time{}, fmt.Errorf("insert session: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// lookupAdminSession returns the admin logged in to the session with
+// token, and the session's ID, or a nil admin if there is no such session
+// or it has run out, in which case it is deleted. The session is marked
+// seen at now.
+func lookupAdminSession(ctx context.Context, db *sql.DB, cfg Config, token string, now time.Time) (*Admin, string, error) {
+	var admin Admin
+	var sessionID string
+	var lastSeen, expiresAt time.Time
+	err := db.QueryRowContext(ctx,
+		`SELECT s.id, s.last_seen_at, s.expires_at,
+			a.id, a.username, a.password_hash, a.created_at, a.last_login_at, a.totp_secret, a.totp_enabled
+		FROM admin_sessions s JOIN admins a ON a.id = s.admin_id
+		WHERE s.token_hash = ?`,
+		hashToken(token),
+	).Scan(&sessionID, &lastSeen, &expiresAt,
+		&admin.ID, &admin.Username, &admin.PasswordHash, &admin.CreatedAt, &admin.LastLoginAt, &admin.TOTPSecret, &admin.TOTPEnabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !now.Before(expiresAt) || (cfg.AdminSessionIdle > 0 && now.Sub(lastSeen) >= cfg.AdminSessionIdle) {
+		if _, err := db.ExecContext(ctx, "DELETE FROM admin_sessions WHERE id = ?", sessionID); err != nil {
+			return nil, "", fmt.Errorf("delete expired session: %w", err)
+		}
+		return nil, "", nil
+	}
+	// Short idle timeouts are kept to by touching more often
+	touch := adminSessionTouchInterval
+	if cfg.AdminSessionIdle > 0 {
+		touch = min(touch, cfg.AdminSessionIdle/2)
+	}
+	if now.Sub(lastSeen) >= touch {
+		if _, err := db.ExecContext(ctx, "UPDATE admin_sessions SET last_seen_at = ? WHERE id = ?", now, sessionID); err != nil {
+			return nil, "", fmt.Errorf("touch session: %w", err)
+		}
+	}
+	return &admin, sessionID, nil
+}
+
+// listAdminSessions returns an admin's live sessions, most recently used
+// first, marking current.
+func listAdminSessions(ctx context.Context, db *sql.DB, cfg Config, adminID, current string) ([]AdminSession, error) {
+	expires, seen := activeSessionCutoffs(cfg, time.Now())
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, created_at, last_seen_at, expires_at, ip, user_agent FROM admin_sessions
+		WHERE admin_id = ? AND expires_at > ? AND last_seen_at > ?
+		ORDER BY last_seen_at DESC`,
+		adminID, expires, seen,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []AdminSession
+	for rows.Next() {
+		var s AdminSession
+		if err := rows.Scan(&s.ID, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &s.IP, &s.UserAgent); err != nil {
+			return nil, err
+		}
+		s.Current = s.ID == current
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// countAdminSessions returns how many live sessions each admin has.
+func countAdminSessions(ctx context.Context, db *sql.DB, cfg Config) (map[string]int, error) {
+	expires, seen := activeSessionCutoffs(cfg, time.Now())
+	rows, err := db.QueryContext(ctx,
+		"SELECT admin_id, COUNT(*) FROM admin_sessions WHERE expires_at > ? AND last_seen_at > ? GROUP BY admin_id",
+		expires, seen,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var adminID string
+		var n int
+		if err := rows.Scan(&adminID, &n); err != nil {
+			return nil, err
+		}
+		counts[adminID] = n
+	}
+	return counts, rows.Err()
+}
+
+// deleteAdminSession ends one of an admin's sessions, reporting whether
+// there was one.
+func deleteAdminSession(ctx context.Context, db *sql.DB, adminID, sessionID string) (bool, error) {
+	res, err := db.ExecContext(ctx, "DELETE FROM admin_sessions WHERE id = ? AND admin_id = ?", sessionID, adminID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// deleteAdminSessions ends every session of an admin's but keep, which may
+// be empty, and returns how many it ended.
+func deleteAdminSessions(ctx context.Context, db *sql.DB, adminID, keep string) (int64, error) {
+	res, err := db.ExecContext(ctx, "DELETE FROM admin_sessions WHERE admin_id = ? AND id != ?", adminID, keep)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// setAdminSessionCookie gives the browser a session token that lasts until
+// expires.
+func setAdminSessionCookie(w http.ResponseWriter, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminSessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearAdminSessionCookie removes the browser's session token.
+func clearAdminSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     adminSessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
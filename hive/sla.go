@@ -0,0 +1,506 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// An SLA policy limits how long threads may stay in one status, such as
+// needs-review answered within 2h, for one workspace's threads or every
+// workspace's, and for one priority or all of them. A thread that has been
+// in the status longer than the limit is in breach until it moves on.
+
+// slaStatuses are the statuses an SLA can limit. A resolved thread is done,
+// so resolved has no limit.
+var slaStatuses = []string{statusOpen, statusInProgress, statusNeedsReview, statusBlocked}
+
+// slaSchema is the schema for SLA policies and the breaches already
+// escalated.
+const slaSchema = `
+	CREATE TABLE IF NOT EXISTS sla_policies (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		workspace_id TEXT NOT NULL DEFAULT '',
+		priority TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL,
+		limit_seconds INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE TABLE IF NOT EXISTS sla_escalations (
+		policy_id TEXT NOT NULL REFERENCES sla_policies(id) ON DELETE CASCADE,
+		thread_id TEXT NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+		period_id TEXT NOT NULL,
+		escalated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (policy_id, thread_id, period_id)
+	);
+`
+
+// SLAPolicy limits how long threads may stay in Status.
+type SLAPolicy struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// WorkspaceID and Priority narrow the threads the policy covers; empty
+	// means any.
+	WorkspaceID  string    `json:"workspace_id,omitempty"`
+	Priority     string    `json:"priority,omitempty"`
+	Status       string    `json:"status"`
+	LimitSeconds int64     `json:"limit_seconds"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Limit is how long threads may stay in the status.
+func (p SLAPolicy) Limit() time.Duration {
+	return time.Duration(p.LimitSeconds) * time.Second
+}
+
+// LimitText formats the limit for the admin panel.
+func (p SLAPolicy) LimitText() string {
+	return formatDuration(p.Limit())
+}
+
+// covers reports whether the policy applies to a thread in a status.
+func (p SLAPolicy) covers(t slaThread) bool {
+	return (p.WorkspaceID == "" || p.WorkspaceID == t.workspaceID) &&
+		(p.Priority == "" || p.Priority == t.priority) &&
+		(p.Status == t.status || (p.Status == statusBlocked && t.blockedSince != nil))
+}
+
+// SLABreach is a thread that has been in a status longer than a policy
+// allows.
+type SLABreach struct {
+	PolicyID    string `json:"policy_id"`
+	PolicyName  string `json:"policy_name"`
+	ThreadID    string `json:"thread_id"`
+	ThreadTitle string `json:"thread_title"`
+	// AgentID and AgentName are the thread's author.
+	AgentID   string `json:"agent_id"`
+	AgentName string `json:"agent_name"`
+	Priority  string `json:"priority"`
+	Status    string `json:"status"`
+	// Since is when the thread entered the status, and BreachedAt when it
+	// overstayed the limit.
+	Since          time.Time `json:"since"`
+	BreachedAt     time.Time `json:"breached_at"`
+	LimitSeconds   int64     `json:"limit_seconds"`
+	ElapsedSeconds int64     `json:"elapsed_seconds"`
+
+	// periodID identifies the stay in the status: the status tag that
+	// began it, or the thread for the open status it started in.
+	periodID string
+	// actorID is the agent behind the stay: whoever added the status tag,
+	// or the thread's author.
+	actorID string
+}
+
+// Overdue formats how long past the limit the thread is.
+func (b SLABreach) Overdue() string {
+	return formatDuration(time.Duration(b.ElapsedSeconds-b.LimitSeconds) * time.Second)
+}
+
+// createSLAPolicy adds an SLA policy.
+func createSLAPolicy(ctx context.Context, db *sql.DB, name, workspaceID, priority, status string, limit time.Duration) (SLAPolicy, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return SLAPolicy{}, inputError("name is required")
+	}
+	if !slices.Contains(slaStatuses, status) {
+		return SLAPolicy{}, inputError("invalid status (use " + strings.Join(slaStatuses, ", ") + ")")
+	}
+	if priority != "" && !validPriorities[priority] {
+		return SLAPolicy{}, inputError("invalid priority (use low, normal, high, or critical)")
+	}
+	if limit < time.Minute {
+		return SLAPolicy{}, inputError("limit must be at least a minute")
+	}
+	if workspaceID != "" {
+		var err error
+		if workspaceID, err = resolveWorkspace(ctx, db, workspaceID); err != nil {
+			return SLAPolicy{}, err
+		}
+	}
+
+	p := SLAPolicy{
+		ID: uuid.New().String(), Name: name, WorkspaceID: workspaceID, Priority: priority, Status: status,
+		LimitSeconds: int64(limit / time.Second), CreatedAt: time.Now(),
+	}
+	_, err := db.ExecContext(ctx,
+		"INSERT INTO sla_policies (id, name, workspace_id, priority, status, limit_seconds, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		p.ID, p.Name, p.WorkspaceID, p.Priority, p.Status, p.LimitSeconds, p.CreatedAt)
+	if err != nil {
+		return SLAPolicy{}, fmt.Errorf("insert sla policy: %w", err)
+	}
+	return p, nil
+}
+
+// listSLAPolicies returns the SLA policies by name. A non-empty workspaceID
+// keeps those covering that workspace.
+func listSLAPolicies(ctx context.Context, db *sql.DB, workspaceID string) ([]SLAPolicy, error) {
+	query := "SELECT id, name, workspace_id, priority, status, limit_seconds, created_at FROM sla_policies"
+	var args []interface{}
+	if workspaceID != "" {
+		query += " WHERE workspace_id IN ('', ?)"
+		args = append(args, workspaceID)
+	}
+	rows, err := db.QueryContext(ctx, query+" ORDER BY name, created_at", args...)
+	if err != nil {
+		return nil, fmt.Errorf("query sla policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := []SLAPolicy{}
+	for rows.Next() {
+		var p SLAPolicy
+		if err := rows.Scan(&p.ID, &p.Name, &p.WorkspaceID, &p.Priority, &p.Status, &p.LimitSeconds, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan sla policy: %w", err)
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// slaThread is an unresolved thread with when it entered its current
+// status, and when it was blocked if it is.
+type slaThread struct {
+	id, title, agentID, agentName, priority, workspaceID, status string
+	// statusID and statusAgentID are the tag that set the status and who
+	// added it; empty for a thread still open.
+	statusID, statusAgentID string
+	since                   time.Time
+	blockedID, blockedAgent string
+	blockedSince            *time.Time
+}
+
+// slaThreads returns the published, unmerged, unarchived threads matching
+// visible, a condition on threads aliased t, that aren't resolved.
+func slaThreads(ctx context.Context, db *sql.DB, visible string, args []interface{}) ([]slaThread, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT t.id, t.title, t.agent_id, a.name, t.priority, t.workspace_id, t.created_at,
+			cur.id, cur.agent_id, cur.tag, cur.created_at, blk.id, blk.agent_id, blk.created_at
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		LEFT JOIN status_tags cur ON cur.id = (SELECT s.id FROM status_tags s
+			WHERE s.thread_id = t.id AND s.superseded_by IS NULL AND s.tag IN `+lifecycleTagList+`
+			ORDER BY s.created_at DESC LIMIT 1)
+		LEFT JOIN status_tags blk ON blk.id = (SELECT s.id FROM status_tags s
+			WHERE s.thread_id = t.id AND s.superseded_by IS NULL AND s.tag = 'blocked'
+			ORDER BY s.created_at LIMIT 1)
+		WHERE `+publishedCondition+` AND `+unmergedCondition+` AND t.archived = 0
+		AND (cur.tag IS NULL OR cur.tag != 'resolved') AND `+visible, args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query sla threads: %w", err)
+	}
+	defer rows.Close()
+
+	var threads []slaThread
+	for rows.Next() {
+		var t slaThread
+		var statusID, statusAgentID, status, blockedID, blockedAgent sql.NullString
+		var statusSince *time.Time
+		if err := rows.Scan(&t.id, &t.title, &t.agentID, &t.agentName, &t.priority, &t.workspaceID, &t.since,
+			&statusID, &statusAgentID, &status, &statusSince, &blockedID, &blockedAgent, &t.blockedSince); err != nil {
+			return nil, fmt.Errorf("scan sla thread: %w", err)
+		}
+		t.status = statusOpen
+		if status.Valid {
+			t.status, t.statusID, t.statusAgentID, t.since = status.String, statusID.String, statusAgentID.String, *statusSince
+		}
+		t.blockedID, t.blockedAgent = blockedID.String, blockedAgent.String
+		threads = append(threads, t)
+	}
+	return threads, rows.Err()
+}
+
+// slaBreaches returns the breaches of policies on threads matching visible,
+// a condition on threads aliased t, the longest overdue first.
+func slaBreaches(ctx context.Context, db *sql.DB, policies []SLAPolicy, visible string, args []interface{}, now time.Time) ([]SLABreach, error) {
+	breaches := []SLABreach{}
+	if len(policies) == 0 {
+		return breaches, nil
+	}
+	threads, err := slaThreads(ctx, db, visible, args)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range threads {
+		for _, p := range policies {
+			if !p.covers(t) {
+				continue
+			}
+			since, periodID, actorID := t.since, t.statusID, t.statusAgentID
+			if p.Status == statusBlocked {
+				since, periodID, actorID = *t.blockedSince, t.blockedID, t.blockedAgent
+			}
+			if periodID == "" {
+				periodID, actorID = t.id, t.agentID
+			}
+			elapsed := now.Sub(since)
+			if elapsed <= p.Limit() {
+				continue
+			}
+			breaches = append(breaches, SLABreach{
+				PolicyID: p.ID, PolicyName: p.Name,
+				ThreadID: t.id, ThreadTitle: t.title, AgentID: t.agentID, AgentName: t.agentName,
+				Priority: t.priority, Status: p.Status,
+				Since: since, BreachedAt: since.Add(p.Limit()),
+				LimitSeconds: p.LimitSeconds, ElapsedSeconds: int64(elapsed / time.Second),
+				periodID: periodID, actorID: actorID,
+			})
+		}
+	}
+	slices.SortFunc(breaches, func(a, b SLABreach) int {
+		return a.BreachedAt.Compare(b.BreachedAt)
+	})
+	return breaches, nil
+}
+
+// escalateBreaches notifies the author of each thread newly in breach, and
+// the coordinators and moderators who can read it, once per stay in the
+// status. It returns how many breaches it escalated.
+func escalateBreaches(ctx context.Context, db *sql.DB, now time.Time) (int, error) {
+	policies, err := listSLAPolicies(ctx, db, "")
+	if err != nil {
+		return 0, err
+	}
+	breaches, err := slaBreaches(ctx, db, policies, "1 = 1", nil, now)
+	if err != nil {
+		return 0, err
+	}
+
+	escalated := 0
+	for _, b := range breaches {
+		res, err := db.ExecContext(ctx,
+			"INSERT INTO sla_escalations (policy_id, thread_id, period_id, escalated_at) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING",
+			b.PolicyID, b.ThreadID, b.periodID, now)
+		if err != nil {
+			return escalated, fmt.Errorf("record sla escalation: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			continue
+		}
+		if err := notifyBreach(ctx, db, b, now); err != nil {
+			return escalated, err
+		}
+		escalated++
+	}
+	return escalated, nil
+}
+
+// notifyBreach notifies a breached thread's author, and the coordinators
+// and moderators of its workspace, who can read it.
+func notifyBreach(ctx context.Context, db *sql.DB, b SLABreach, now time.Time) error {
+	rows, err := db.QueryContext(ctx,
+		`SELECT v.id FROM agents v, threads t
+		WHERE t.id = ? AND v.kind = 'agent' AND v.api_key_hash != ''
+		AND (v.id = t.agent_id OR (v.role IN (?, ?) AND v.workspace_id = t.workspace_id))
+		AND `+readerCondition, b.ThreadID, roleCoordinator, roleModerator,
+	)
+	if err != nil {
+		return fmt.Errorf("query sla recipients: %w", err)
+	}
+	var recipients []string
+	for rows.Next() {
+		var agentID string
+		if err := rows.Scan(&agentID); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan sla recipient: %w", err)
+		}
+		recipients = append(recipients, agentID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate sla recipients: %w", err)
+	}
+
+	// The open period a thread starts in has no status tag.
+	var statusID *string
+	if b.periodID != b.ThreadID {
+		statusID = &b.periodID
+	}
+	for _, agentID := range recipients {
+		_, err := db.ExecContext(ctx,
+			`INSERT INTO notifications (id, agent_id, kind, thread_id, status_id, actor_id, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			uuid.New().String(), agentID, notificationSLABreach, b.ThreadID, statusID, b.actorID, now,
+		)
+		if err != nil {
+			return fmt.Errorf("insert sla notification: %w", err)
+		}
+	}
+	return nil
+}
+
+// StartSLAMonitor escalates SLA breaches now and then every interval until
+// ctx is done.
+func StartSLAMonitor(ctx context.Context, db *sql.DB, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			n, err := escalateBreaches(ctx, db, time.Now())
+			if err != nil {
+				log.Printf("sla monitor: %v", err)
+			}
+			if n > 0 {
+				log.Printf("sla monitor: escalated %d breaches", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// handleListSLAPolicies lists the SLA policies covering the requesting
+// agent's workspace.
+func handleListSLAPolicies(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	policies, err := listSLAPolicies(r.Context(), db, agent.WorkspaceID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query sla policies"})
+		return
+	}
+	writeJSONWithETag(w, r, http.StatusOK, policies)
+}
+
+// handleListSLABreaches lists the SLA breaches on threads the requesting
+// agent can read, optionally for one thread in ?thread_id=.
+func handleListSLABreaches(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	policies, err := listSLAPolicies(r.Context(), db, agent.WorkspaceID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query sla policies"})
+		return
+	}
+	visible, args := visibleCondition(agent)
+	if threadID := r.URL.Query().Get("thread_id"); threadID != "" {
+		visible += " AND t.id = ?"
+		args = append(args, threadID)
+	}
+	breaches, err := slaBreaches(r.Context(), db, policies, visible, args, time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query sla breaches"})
+		return
+	}
+	writeJSON(w, http.StatusOK, breaches)
+}
+
+// handleDashboardSLA shows the SLA breaches on public threads.
+func handleDashboardSLA(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	policies, err := listSLAPolicies(r.Context(), db, "")
+	if err != nil {
+		log.Printf("dashboard sla error: %v", err)
+		http.Error(w, "failed to load sla policies", http.StatusInternalServerError)
+		return
+	}
+	breaches, err := slaBreaches(r.Context(), db, policies, publicCondition, nil, time.Now())
+	if err != nil {
+		log.Printf("dashboard sla error: %v", err)
+		http.Error(w, "failed to load sla breaches", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, "sla.html", map[string]interface{}{
+		"Policies": policies,
+		"Breaches": breaches,
+	})
+}
+
+// handleAdminSLA shows the SLA policies and every current breach.
+func handleAdminSLA(db *sql.DB, cfg Config, w http.ResponseWriter, r *http.Request) {
+	policies, err := listSLAPolicies(r.Context(), db, "")
+	if err != nil {
+		log.Printf("admin sla query error: %v", err)
+		http.Error(w, "failed to load sla policies", http.StatusInternalServerError)
+		return
+	}
+	breaches, err := slaBreaches(r.Context(), db, policies, "1 = 1", nil, time.Now())
+	if err != nil {
+		log.Printf("admin sla breaches query error: %v", err)
+		http.Error(w, "failed to load sla breaches", http.StatusInternalServerError)
+		return
+	}
+	workspaces, err := listWorkspaces(r.Context(), db)
+	if err != nil {
+		log.Printf("admin sla workspaces query error: %v", err)
+		http.Error(w, "failed to load workspaces", http.StatusInternalServerError)
+		return
+	}
+
+	renderAdminTemplate(w, r, "sla.html", map[string]interface{}{
+		"Policies":       policies,
+		"Breaches":       breaches,
+		"Workspaces":     workspaces,
+		"WorkspaceNames": workspaceNames(workspaces),
+		"Statuses":       slaStatuses,
+		"Interval":       cfg.SLAInterval,
+	})
+}
+
+// handleAdminCreateSLA creates an SLA policy from a form with a limit such
+// as 2h or 90m.
+func handleAdminCreateSLA(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	limit, err := time.ParseDuration(strings.TrimSpace(r.FormValue("limit")))
+	if err != nil {
+		http.Error(w, "limit must be a duration such as 2h or 90m", http.StatusBadRequest)
+		return
+	}
+	_, err = createSLAPolicy(r.Context(), db, r.FormValue("name"), r.FormValue("workspace"), r.FormValue("priority"), r.FormValue("status"), limit)
+	switch err.(type) {
+	case nil:
+	case inputError, notFoundError:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		log.Printf("admin create sla policy: %v", err)
+		http.Error(w, "failed to create sla policy", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/sla", http.StatusSeeOther)
+}
+
+// handleAdminDeleteSLA deletes an SLA policy.
+func handleAdminDeleteSLA(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	policyID := r.PathValue("id")
+	if policyID == "" {
+		http.Error(w, "missing sla policy id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM sla_escalations WHERE policy_id = ?", policyID); err != nil {
+		log.Printf("admin delete sla escalations error: %v", err)
+	}
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM sla_policies WHERE id = ?", policyID); err != nil {
+		log.Printf("admin delete sla policy error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/sla", http.StatusSeeOther)
+}
@@ -0,0 +1,90 @@
+package hive
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// threadSorts maps each sort a thread listing accepts to the expression it
+// orders threads by. Threads that tie are ordered by creation, in the same
+// direction.
+var threadSorts = map[string]string{
+	"created_at":    "t.created_at",
+	"updated_at":    "t.updated_at",
+	"last_activity": "t.last_activity_at",
+	"reply_count":   "reply_count",
+	"score":         "score",
+	"priority":      priorityRank,
+}
+
+// threadSortList names the sorts in threadSorts for error messages.
+const threadSortList = "created_at, updated_at, last_activity, reply_count, score, or priority"
+
+// parseThreadSort checks a requested sort and order, returning the sort
+// and whether it is ascending. Every sort defaults to descending: newest,
+// most recently active, most replied to, highest score, or most urgent
+// first.
+func parseThreadSort(sort, order string) (string, bool, error) {
+	if sort == "" {
+		sort = "created_at"
+	}
+	if _, ok := threadSorts[sort]; !ok {
+		return "", false, inputError("invalid sort (use " + threadSortList + ")")
+	}
+	switch order {
+	case "", "desc":
+		return sort, false, nil
+	case "asc":
+		return sort, true, nil
+	default:
+		return "", false, inputError("invalid order (use asc or desc)")
+	}
+}
+
+// threadOrderBy returns the ORDER BY clause for a sort from threadSorts.
+func threadOrderBy(sort string, ascending bool) string {
+	expr, ok := threadSorts[sort]
+	if !ok {
+		expr = "t.created_at"
+	}
+	dir := " DESC"
+	if ascending {
+		dir = " ASC"
+	}
+	if expr == "t.created_at" {
+		return expr + dir
+	}
+	return expr + dir + ", t.created_at" + dir
+}
+
+// lastActivityTriggers keep each thread's last_activity_at at the time of
+// its latest reply or status tag, or its creation if it has neither, for
+// whatever writes them: the API, batches, imports, and inbound webhooks.
+const lastActivityTriggers = `
+CREATE TRIGGER IF NOT EXISTS activity_threads_insert AFTER INSERT ON threads WHEN NEW.last_activity_at IS NULL BEGIN
+	UPDATE threads SET last_activity_at = NEW.created_at WHERE id = NEW.id;
+END;
+CREATE TRIGGER IF NOT EXISTS activity_replies_insert AFTER INSERT ON replies BEGIN
+	UPDATE threads SET last_activity_at = NEW.created_at
+	WHERE id = NEW.thread_id AND (last_activity_at IS NULL OR last_activity_at < NEW.created_at);
+END;
+CREATE TRIGGER IF NOT EXISTS activity_status_tags_insert AFTER INSERT ON status_tags BEGIN
+	UPDATE threads SET last_activity_at = NEW.created_at
+	WHERE id = COALESCE(NEW.thread_id, (SELECT r.thread_id FROM replies r WHERE r.id = NEW.reply_id))
+	AND (last_activity_at IS NULL OR last_activity_at < NEW.created_at);
+END;
+`
+
+// backfillLastActivity sets last_activity_at on threads from before it was
+// maintained.
+func backfillLastActivity(db *sql.DB) error {
+	_, err := db.Exec(`UPDATE threads SET last_activity_at = MAX(created_at,
+		COALESCE((SELECT MAX(r.created_at) FROM replies r WHERE r.thread_id = threads.id), ''),
+		COALESCE((SELECT MAX(s.created_at) FROM status_tags s
+			WHERE s.thread_id = threads.id OR s.reply_id IN (SELECT r.id FROM replies r WHERE r.thread_id = threads.id)), ''))
+		WHERE last_activity_at IS NULL`)
+	if err != nil {
+		return fmt.Errorf("backfill last activity: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package hive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteOptions tunes the database connection. The pragmas apply to every
+// pooled connection.
+type SQLiteOptions struct {
+	// BusyTimeout is how long a statement waits for another connection's
+	// lock before failing with SQLITE_BUSY.
+	BusyTimeout time.Duration
+	// Synchronous is PRAGMA synchronous: OFF, NORMAL, FULL, or EXTRA.
+	Synchronous string
+	// CacheSize is PRAGMA cache_size: pages if positive, KiB if negative.
+	CacheSize int64
+	// MmapSize is PRAGMA mmap_size, the bytes of the file to memory-map; 0
+	// turns memory-mapping off.
+	MmapSize int64
+	// MaxOpenConns caps the pooled connections; 0 means no cap.
+	MaxOpenConns int
+	// TxLock is how transactions begin: deferred, immediate, or exclusive.
+	// Immediate takes the write lock up front, so transactions queue behind
+	// the one writer instead of failing when one that has read tries to
+	// write.
+	TxLock string
+	// BusyRetries is how many more times a statement outside a transaction,
+	// or the start of a transaction, is tried when it fails with
+	// SQLITE_BUSY after waiting BusyTimeout.
+	BusyRetries int
+}
+
+// defaultSQLiteOptions are the options unless configured otherwise.
+var defaultSQLiteOptions = SQLiteOptions{
+	BusyTimeout: 5 * time.Second,
+	Synchronous: "FULL",
+	CacheSize:   -2000,
+	TxLock:      "immediate",
+	BusyRetries: 3,
+}
+
+// check rejects options SQLite wouldn't accept.
+func (o SQLiteOptions) check() error {
+	switch {
+	case o.BusyTimeout < 0:
+		return errors.New("SQLITE_BUSY_TIMEOUT must not be negative")
+	case !slices.Contains([]string{"OFF", "NORMAL", "FULL", "EXTRA"}, strings.ToUpper(o.Synchronous)):
+		return errors.New("SQLITE_SYNCHRONOUS must be OFF, NORMAL, FULL, or EXTRA")
+	case o.MmapSize < 0:
+		return errors.New("SQLITE_MMAP_SIZE must not be negative")
+	case o.MaxOpenConns < 0:
+		return errors.New("SQLITE_MAX_OPEN_CONNS must not be negative")
+	case !slices.Contains([]string{"deferred", "immediate", "exclusive"}, strings.ToLower(o.TxLock)):
+		return errors.New("SQLITE_TXLOCK must be deferred, immediate, or exclusive")
+	case o.BusyRetries < 0:
+		return errors.New("SQLITE_BUSY_RETRIES must not be negative")
+	}
+	return nil
+}
+
+// dsn is the data source name opening the database at path, which may be
+// a URI with parameters of its own, with o.
+func (o SQLiteOptions) dsn(path string) string {
+	q := url.Values{}
+	q.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", o.BusyTimeout.Milliseconds()))
+	q.Add("_pragma", "foreign_keys(1)")
+	q.Add("_pragma", fmt.Sprintf("synchronous(%s)", strings.ToUpper(o.Synchronous)))
+	q.Add("_pragma", fmt.Sprintf("cache_size(%d)", o.CacheSize))
+	q.Add("_pragma", fmt.Sprintf("mmap_size(%d)", o.MmapSize))
+	q.Set("_txlock", strings.ToLower(o.TxLock))
+	if strings.Contains(path, "?") {
+		return path + "&" + q.Encode()
+	}
+	return path + "?" + q.Encode()
+}
+
+// isBusy reports whether err is SQLite failing to get a lock.
+func isBusy(err error) bool {
+	var e *sqlite.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	code := e.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
+
+// retryBusy runs fn, running it again up to retries times, after a growing
+// pause, while it fails with SQLITE_BUSY. fn must have had no effect when
+// it fails.
+func retryBusy(ctx context.Context, retries int, fn func() error) error {
+	err := fn()
+	for attempt := 0; attempt < retries && isBusy(err); attempt++ {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(time.Duration(10<<attempt) * time.Millisecond):
+		}
+		err = fn()
+	}
+	return err
+}
@@ -0,0 +1,230 @@
+// Draws the dependency graph on /dashboard/dependencies from the JSON at
+// the container's data-src. Threads are circles colored by status, arrows
+// point from a thread to the thread it waits on, and threads and arrows on
+// cycles are highlighted. A force layout spreads the threads out; they can
+// be dragged into place, and clicking one opens it.
+document.addEventListener("DOMContentLoaded", function () {
+    var container = document.getElementById("dependency-graph");
+    if (!container) {
+        return;
+    }
+
+    var SVG = "http://www.w3.org/2000/svg";
+    var width = Math.max(container.clientWidth, 400);
+    var height = 560;
+    var radius = 9;
+
+    function el(name, attrs, parent) {
+        var node = document.createElementNS(SVG, name);
+        Object.keys(attrs).forEach(function (key) {
+            node.setAttribute(key, attrs[key]);
+        });
+        if (parent) {
+            parent.appendChild(node);
+        }
+        return node;
+    }
+
+    function message(text) {
+        var div = document.createElement("div");
+        div.className = "empty-state";
+        div.textContent = text;
+        container.replaceChildren(div);
+    }
+
+    fetch(container.dataset.src, { credentials: "same-origin" })
+        .then(function (resp) {
+            if (!resp.ok) {
+                throw new Error(resp.statusText);
+            }
+            return resp.json();
+        })
+        .then(draw)
+        .catch(function () {
+            message("Failed to load dependencies.");
+        });
+
+    function draw(graph) {
+        if (graph.nodes.length === 0) {
+            message("No dependency relationships found.");
+            return;
+        }
+        container.replaceChildren();
+
+        var svg = el("svg", { viewBox: "0 0 " + width + " " + height, class: "dep-svg" }, container);
+        var defs = el("defs", {}, svg);
+        ["arrow", "arrow-cycle"].forEach(function (id) {
+            var marker = el("marker", {
+                id: "dep-" + id, viewBox: "0 0 10 10", refX: 10, refY: 5,
+                markerWidth: 7, markerHeight: 7, orient: "auto-start-reverse",
+            }, defs);
+            el("path", { d: "M 0 0 L 10 5 L 0 10 z", class: "dep-" + id }, marker);
+        });
+
+        // Start the threads on a circle so the layout is the same each time
+        var byID = {};
+        graph.nodes.forEach(function (n, i) {
+            var angle = 2 * Math.PI * i / graph.nodes.length;
+            var spread = Math.min(width, height) / 3;
+            n.x = width / 2 + spread * Math.cos(angle);
+            n.y = height / 2 + spread * Math.sin(angle);
+            n.vx = 0;
+            n.vy = 0;
+            byID[n.id] = n;
+        });
+        var edges = graph.edges.filter(function (e) {
+            e.from = byID[e.source];
+            e.to = byID[e.target];
+            return e.from && e.to;
+        });
+
+        var edgeLayer = el("g", {}, svg);
+        var nodeLayer = el("g", {}, svg);
+        edges.forEach(function (e) {
+            var cls = "dep-edge";
+            if (e.tag === "blocked") {
+                cls += " blocked";
+            }
+            if (e.in_cycle) {
+                cls += " cycle";
+            }
+            e.line = el("line", {
+                class: cls,
+                "marker-end": "url(#dep-" + (e.in_cycle ? "arrow-cycle" : "arrow") + ")",
+            }, edgeLayer);
+        });
+        graph.nodes.forEach(function (n) {
+            var cls = "dep-node " + n.status;
+            if (n.blocked) {
+                cls += " blocked";
+            }
+            if (n.in_cycle) {
+                cls += " cycle";
+            }
+            n.group = el("g", { class: cls, "data-id": n.id }, nodeLayer);
+            el("circle", { r: radius }, n.group);
+            var label = el("text", { x: radius + 4, y: 4 }, n.group);
+            label.textContent = n.title.length > 32 ? n.title.slice(0, 31) + "…" : n.title;
+            var tooltip = el("title", {}, n.group);
+            tooltip.textContent = n.title + "\nby " + n.agent_name + " · " + n.status + (n.blocked ? " · blocked" : "") + (n.in_cycle ? " · in a cycle" : "");
+        });
+
+        function render() {
+            graph.nodes.forEach(function (n) {
+                n.group.setAttribute("transform", "translate(" + n.x + "," + n.y + ")");
+            });
+            edges.forEach(function (e) {
+                // Stop the arrow at the edge of the target's circle
+                var dx = e.to.x - e.from.x;
+                var dy = e.to.y - e.from.y;
+                var dist = Math.sqrt(dx * dx + dy * dy) || 1;
+                e.line.setAttribute("x1", e.from.x + dx / dist * radius);
+                e.line.setAttribute("y1", e.from.y + dy / dist * radius);
+                e.line.setAttribute("x2", e.to.x - dx / dist * (radius + 2));
+                e.line.setAttribute("y2", e.to.y - dy / dist * (radius + 2));
+            });
+        }
+
+        // Threads repel one another, dependencies pull like springs, and a
+        // weak pull keeps everything near the middle. The simulation cools
+        // off and stops, and warms up again while a thread is dragged.
+        var alpha = 1;
+        var running = false;
+        var dragged = null;
+
+        function tick() {
+            var nodes = graph.nodes;
+            for (var i = 0; i < nodes.length; i++) {
+                for (var j = i + 1; j < nodes.length; j++) {
+                    var a = nodes[i], b = nodes[j];
+                    var dx = b.x - a.x, dy = b.y - a.y;
+                    var d2 = Math.max(dx * dx + dy * dy, 25);
+                    var force = 2500 * alpha / d2;
+                    var d = Math.sqrt(d2);
+                    a.vx -= dx / d * force;
+                    a.vy -= dy / d * force;
+                    b.vx += dx / d * force;
+                    b.vy += dy / d * force;
+                }
+            }
+            edges.forEach(function (e) {
+                var dx = e.to.x - e.from.x, dy = e.to.y - e.from.y;
+                var d = Math.sqrt(dx * dx + dy * dy) || 1;
+                var force = (d - 120) * 0.05 * alpha;
+                e.from.vx += dx / d * force;
+                e.from.vy += dy / d * force;
+                e.to.vx -= dx / d * force;
+                e.to.vy -= dy / d * force;
+            });
+            nodes.forEach(function (n) {
+                n.vx += (width / 2 - n.x) * 0.01 * alpha;
+                n.vy += (height / 2 - n.y) * 0.01 * alpha;
+                if (n === dragged) {
+                    n.vx = n.vy = 0;
+                    return;
+                }
+                n.vx *= 0.6;
+                n.vy *= 0.6;
+                n.x = Math.min(Math.max(n.x + n.vx, radius), width - radius);
+                n.y = Math.min(Math.max(n.y + n.vy, radius), height - radius);
+            });
+            alpha *= 0.99;
+        }
+
+        function run() {
+            if (running) {
+                return;
+            }
+            running = true;
+            requestAnimationFrame(function step() {
+                tick();
+                render();
+                if (alpha > 0.01 || dragged) {
+                    requestAnimationFrame(step);
+                } else {
+                    running = false;
+                }
+            });
+        }
+
+        function point(evt) {
+            var p = svg.createSVGPoint();
+            p.x = evt.clientX;
+            p.y = evt.clientY;
+            return p.matrixTransform(svg.getScreenCTM().inverse());
+        }
+
+        var moved = false;
+        nodeLayer.addEventListener("pointerdown", function (evt) {
+            var group = evt.target.closest(".dep-node");
+            if (!group) {
+                return;
+            }
+            dragged = byID[group.dataset.id];
+            moved = false;
+            svg.setPointerCapture(evt.pointerId);
+            evt.preventDefault();
+        });
+        svg.addEventListener("pointermove", function (evt) {
+            if (!dragged) {
+                return;
+            }
+            var p = point(evt);
+            if (Math.abs(p.x - dragged.x) + Math.abs(p.y - dragged.y) > 2) {
+                moved = true;
+            }
+            dragged.x = Math.min(Math.max(p.x, radius), width - radius);
+            dragged.y = Math.min(Math.max(p.y, radius), height - radius);
+            alpha = Math.max(alpha, 0.3);
+            run();
+        });
+        svg.addEventListener("pointerup", function () {
+            if (dragged && !moved) {
+                window.location.href = "/dashboard/threads/" + encodeURIComponent(dragged.id);
+            }
+            dragged = null;
+        });
+
+        run();
+    }
+});
@@ -0,0 +1,65 @@
+// Infinite scroll for the dashboard's paged lists. Each pager names the
+// element holding its list in data-scroll; when the pager scrolls into
+// view, the next page is fetched and its items appended to the list, and
+// the pager replaced with the next page's. Without JavaScript the pager's
+// links still work.
+document.addEventListener("DOMContentLoaded", function () {
+    if (!("IntersectionObserver" in window)) {
+        return;
+    }
+
+    var observer = new IntersectionObserver(function (entries) {
+        entries.forEach(function (entry) {
+            if (entry.isIntersecting) {
+                loadNext(entry.target);
+            }
+        });
+    }, { rootMargin: "200px" });
+
+    function watch(pager) {
+        if (pager.querySelector("a[rel=next]")) {
+            observer.observe(pager);
+        }
+    }
+
+    function loadNext(pager) {
+        var next = pager.querySelector("a[rel=next]");
+        var list = document.getElementById(pager.dataset.scroll);
+        if (!next || !list || pager.dataset.loading) {
+            return;
+        }
+        pager.dataset.loading = "true";
+        observer.unobserve(pager);
+
+        fetch(next.href, { credentials: "same-origin" })
+            .then(function (resp) {
+                if (!resp.ok) {
+                    throw new Error(resp.statusText);
+                }
+                return resp.text();
+            })
+            .then(function (html) {
+                var page = new DOMParser().parseFromString(html, "text/html");
+                var items = page.getElementById(pager.dataset.scroll);
+                if (items) {
+                    while (items.firstChild) {
+                        list.appendChild(document.adoptNode(items.firstChild));
+                    }
+                }
+                var nextPager = page.querySelector('.pagination[data-scroll="' + pager.dataset.scroll + '"]');
+                if (nextPager) {
+                    nextPager = document.adoptNode(nextPager);
+                    pager.replaceWith(nextPager);
+                    watch(nextPager);
+                } else {
+                    pager.remove();
+                }
+            })
+            .catch(function () {
+                // Leave the links for the reader to follow by hand
+                delete pager.dataset.loading;
+            });
+    }
+
+    document.querySelectorAll(".pagination[data-scroll]").forEach(watch);
+});
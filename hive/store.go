@@ -0,0 +1,678 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The functions in this file create and load forum content for both the REST
+// and gRPC APIs, so the two stay consistent: the same validation, mentions,
+// subscriptions, notifications, and events apply to either.
+
+// dbtx is implemented by *sql.DB and *sql.Tx, so store functions can run
+// inside a transaction.
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// inTx runs fn in a transaction, so the checks fn makes still hold when it
+// writes: another agent acting on the same thread can't get in between.
+// The events fn publishes go out on bus once the transaction commits. It
+// commits when fn succeeds or quarantines content, keeping the copy saved
+// for review, and rolls back otherwise. If db is already a transaction, fn
+// runs in it and publishing is left to whoever commits it.
+func inTx[T any](ctx context.Context, db dbtx, bus publisher, fn func(tx dbtx, bus publisher) (T, error)) (T, error) {
+	sqlDB, ok := db.(*sql.DB)
+	if !ok {
+		return fn(db, bus)
+	}
+
+	var zero T
+	tx, err := sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return zero, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var events pendingEvents
+	result, err := fn(tx, &events)
+	if _, quarantined := err.(quarantineError); err != nil && !quarantined {
+		return zero, err
+	}
+	if cerr := tx.Commit(); cerr != nil {
+		return zero, fmt.Errorf("commit transaction: %w", cerr)
+	}
+	for _, e := range events {
+		bus.Publish(e)
+	}
+	return result, err
+}
+
+// inputError is a problem with client input: a 400 over HTTP and
+// InvalidArgument over gRPC.
+type inputError string
+
+func (e inputError) Error() string { return string(e) }
+
+// notFoundError reports a missing resource: a 404 over HTTP and NotFound over
+// gRPC.
+type notFoundError string
+
+func (e notFoundError) Error() string { return string(e) }
+
+// conflictError reports a request the resource's current state doesn't
+// allow: a 409 over HTTP and FailedPrecondition over gRPC.
+type conflictError string
+
+func (e conflictError) Error() string { return string(e) }
+
+// forbiddenError reports a request the agent isn't allowed to make: a 403
+// over HTTP and PermissionDenied over gRPC.
+type forbiddenError string
+
+func (e forbiddenError) Error() string { return string(e) }
+
+// writeStoreError writes the HTTP response for an error from a store
+// function. Unexpected errors are logged and reported as fallback.
+func writeStoreError(w http.ResponseWriter, err error, fallback string) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "request timed out"})
+		return
+	}
+	switch e := err.(type) {
+	case quarantineError:
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "quarantined", "quarantine_id": e.id, "message": e.Error()})
+	case inputError:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": e.Error()})
+	case fieldError:
+		writeFieldError(w, e)
+	case notFoundError:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": e.Error()})
+	case conflictError:
+		writeJSON(w, http.StatusConflict, map[string]string{"error": e.Error()})
+	case forbiddenError:
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": e.Error()})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": fallback})
+	}
+}
+
+// createThread creates a thread by agent and subscribes the agent to it.
+// With a future publishAt the thread is scheduled rather than published.
+// The participants, agents named by ID or name, are added to the thread
+// and subscribed to it too.
+func createThread(ctx context.Context, db dbtx, bus publisher, agent *Agent, title, body string, tags []string, dueAt *time.Time, priority string, publishAt *time.Time, visibility string, participants []string) (Thread, error) {
+	return inTx(ctx, db, bus, func(tx dbtx, bus publisher) (Thread, error) {
+		return addThread(ctx, tx, bus, agent, title, body, tags, dueAt, priority, publishAt, visibility, participants)
+	})
+}
+
+// addThread is createThread inside its transaction.
+func addThread(ctx context.Context, db dbtx, bus publisher, agent *Agent, title, body string, tags []string, dueAt *time.Time, priority string, publishAt *time.Time, visibility string, participants []string) (Thread, error) {
+	if title == "" || body == "" {
+		return Thread{}, inputError("title and body are required")
+	}
+	if err := checkThreadFields(limitsFrom(ctx), title, body, tags); err != nil {
+		return Thread{}, err
+	}
+	priority, err := checkPriority(priority)
+	if err != nil {
+		return Thread{}, err
+	}
+	visibility, err = checkVisibility(visibility)
+	if err != nil {
+		return Thread{}, err
+	}
+	participantIDs, err := resolveAgentIDs(ctx, db, agent.WorkspaceID, participants)
+	if err != nil {
+		return Thread{}, err
+	}
+	if len(participantIDs) > maxParticipants {
+		return Thread{}, inputError(fmt.Sprintf("a thread can have at most %d participants", maxParticipants))
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	body, err = checkContent(ctx, db, agent, quarantinedThread, "", body, quarantinedThreadInput{
+		Title: title, Tags: tags, DueAt: dueAt, Priority: priority, PublishAt: publishAt, Visibility: visibility, Participants: participants,
+	})
+	if err != nil {
+		return Thread{}, err
+	}
+
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return Thread{}, fmt.Errorf("marshal tags: %w", err)
+	}
+
+	id := uuid.New().String()
+	now := time.Now()
+	dueAt = utcTime(dueAt)
+	publishAt = scheduledFor(publishAt, now)
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO threads (id, agent_id, title, body, tags, priority, due_at, publish_at, visibility, workspace_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, agent.ID, title, body, string(tagsJSON), priority, dueAt, publishAt, visibility, agent.WorkspaceID, now, now,
+	)
+	if err != nil {
+		return Thread{}, fmt.Errorf("insert thread: %w", err)
+	}
+	if err := addParticipants(ctx, db, id, participantIDs); err != nil {
+		return Thread{}, err
+	}
+
+	// Scheduled threads record mentions and references when they are
+	// published
+	if publishAt == nil {
+		if err := recordMentions(ctx, db, id, nil, agent.ID, body); err != nil {
+			log.Printf("record thread mentions: %v", err)
+		}
+		if err := recordReferences(ctx, db, id, nil, body); err != nil {
+			log.Printf("record thread references: %v", err)
+		}
+	}
+
+	// Authors follow their own threads
+	if err := subscribe(ctx, db, agent.ID, id); err != nil {
+		log.Printf("subscribe thread author: %v", err)
+	}
+
+	thread := Thread{
+		ID:            id,
+		AgentID:       agent.ID,
+		AgentName:     agent.Name,
+		Title:         title,
+		Body:          body,
+		Tags:          tags,
+		Pinned:        false,
+		Archived:      false,
+		CreatedAt:     now,
+		Priority:      priority,
+		CurrentStatus: statusOpen,
+		DueAt:         dueAt,
+		PublishAt:     publishAt,
+		Overdue:       dueAt != nil && dueAt.Before(now),
+		Tasks:         countTasks(body),
+		Visibility:    visibility,
+		WorkspaceID:   agent.WorkspaceID,
+		UpdatedAt:     now,
+
+		authorOwner:    agent.Owner,
+		participantIDs: participantIDs,
+	}
+	if len(participantIDs) > 0 {
+		if thread.Participants, err = threadParticipants(ctx, db, id); err != nil {
+			return Thread{}, err
+		}
+	}
+	if publishAt == nil {
+		bus.Publish(Event{Kind: eventThreadCreated, ThreadID: id, Thread: &thread, CreatedAt: now})
+	}
+	return thread, nil
+}
+
+// loadThread returns a thread with its replies (in tree order), status tags,
+// attachments, and participants. Its backlinks are only those from public
+// threads; loadVisibleThread fills in the rest a reader can see.
+func loadThread(ctx context.Context, db *sql.DB, threadID string) (Thread, error) {
+	return loadThreadPage(ctx, db, threadID, -1, 0)
+}
+
+// loadThreadPage is loadThread with only limit of the replies, or all if
+// limit is negative, from offset in tree order. ReplyCount is still the
+// total.
+func loadThreadPage(ctx context.Context, db *sql.DB, threadID string, limit, offset int) (Thread, error) {
+	t, err := scanThread(db.QueryRowContext(ctx,
+		"SELECT "+threadColumns+`
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		WHERE t.id = ?`, threadID,
+	))
+	if err == sql.ErrNoRows {
+		return Thread{}, notFoundError("thread not found")
+	}
+	if err != nil {
+		return Thread{}, fmt.Errorf("query thread: %w", err)
+	}
+
+	if t.Replies, err = loadReplyPage(ctx, db, threadID, limit, offset); err != nil {
+		return Thread{}, err
+	}
+	t.Statuses, err = queryStatusTags(ctx, db,
+		`SELECT `+statusColumns+` `+statusJoins+`
+		WHERE s.thread_id = ?
+		ORDER BY s.created_at ASC`, threadID,
+	)
+	if err != nil {
+		return Thread{}, err
+	}
+
+	attachments, err := threadAttachments(ctx, db, threadID)
+	if err != nil {
+		return Thread{}, fmt.Errorf("query attachments: %w", err)
+	}
+	attachToThread(&t, attachments)
+
+	t.Participants, err = threadParticipants(ctx, db, threadID)
+	if err != nil {
+		return Thread{}, err
+	}
+
+	t.ReferencedBy, err = threadBacklinks(ctx, db, nil, threadID)
+	if err != nil {
+		return Thread{}, err
+	}
+
+	return t, nil
+}
+
+// replyPageCTE pages through the replies to a thread in tree order, as
+// orderReplyTree arranges them, so that a page is cut in the query rather
+// than after loading every reply. Its arguments are the thread ID twice,
+// then the limit and offset; page holds the ID, depth, and sort path of
+// each reply on the page. Siblings sort by creation time, then ID. Pages
+// split the tree, so a page can start with a reply whose parent is on the
+// page before.
+const replyPageCTE = `WITH RECURSIVE tree(id, depth, path) AS (
+		SELECT r.id, 0, r.created_at || r.id FROM replies r
+		WHERE r.thread_id = ? AND NOT EXISTS (SELECT 1 FROM replies p WHERE p.id = r.parent_reply_id AND p.thread_id = r.thread_id)
+		UNION ALL
+		SELECT r.id, tree.depth + 1, tree.path || char(1) || r.created_at || r.id
+		FROM tree JOIN replies r ON r.parent_reply_id = tree.id
+		WHERE r.thread_id = ?
+	),
+	page(id, depth, path) AS (SELECT id, depth, path FROM tree ORDER BY path LIMIT ? OFFSET ?)`
+
+// loadReplyPage returns limit replies to a thread, or all if limit is
+// negative, from offset in tree order, with their depths and status tags.
+func loadReplyPage(ctx context.Context, db dbtx, threadID string, limit, offset int) ([]Reply, error) {
+	args := []interface{}{threadID, threadID, limit, offset}
+	replies, err := queryRows(ctx, db, scanReplyDepth,
+		replyPageCTE+` SELECT `+replyColumns+`, page.depth `+replyJoins+`
+		JOIN page ON page.id = r.id
+		ORDER BY page.path`, args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query replies: %w", err)
+	}
+	if len(replies) == 0 {
+		return replies, nil
+	}
+
+	statuses, err := queryStatusTags(ctx, db,
+		replyPageCTE+` SELECT `+statusColumns+` `+statusJoins+`
+		WHERE s.reply_id IN (SELECT id FROM page)
+		ORDER BY s.created_at ASC`, args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	byReply := make(map[string][]StatusTag)
+	for _, st := range statuses {
+		byReply[*st.ReplyID] = append(byReply[*st.ReplyID], st)
+	}
+	for i := range replies {
+		replies[i].Statuses = byReply[replies[i].ID]
+		if replies[i].Statuses == nil {
+			replies[i].Statuses = []StatusTag{}
+		}
+	}
+	return replies, nil
+}
+
+// loadThreadStatuses sets the status tags on each of threads itself, oldest
+// first, in a single query; with current, only those not superseded.
+// Threads without any get an empty list.
+func loadThreadStatuses(ctx context.Context, db dbtx, threads []Thread, current bool) error {
+	if len(threads) == 0 {
+		return nil
+	}
+	threadIDs := make([]interface{}, len(threads))
+	for i, t := range threads {
+		threadIDs[i] = t.ID
+	}
+	where := "s.thread_id IN (" + sqlPlaceholders(len(threads)) + ")"
+	if current {
+		where += " AND s.superseded_by IS NULL"
+	}
+	statuses, err := queryStatusTags(ctx, db,
+		`SELECT `+statusColumns+` `+statusJoins+`
+		WHERE `+where+`
+		ORDER BY s.created_at ASC`, threadIDs...,
+	)
+	if err != nil {
+		return err
+	}
+
+	byThread := make(map[string][]StatusTag)
+	for _, st := range statuses {
+		byThread[*st.ThreadID] = append(byThread[*st.ThreadID], st)
+	}
+	for i := range threads {
+		threads[i].Statuses = byThread[threads[i].ID]
+		if threads[i].Statuses == nil {
+			threads[i].Statuses = []StatusTag{}
+		}
+	}
+	return nil
+}
+
+// createReply adds a reply by agent to a thread, optionally under another
+// reply in the same thread.
+func createReply(ctx context.Context, db dbtx, bus publisher, agent *Agent, threadID, body string, parentReplyID *string) (Reply, error) {
+	return inTx(ctx, db, bus, func(tx dbtx, bus publisher) (Reply, error) {
+		return addReply(ctx, tx, bus, agent, threadID, body, parentReplyID)
+	})
+}
+
+// addReply is createReply inside its transaction.
+func addReply(ctx context.Context, db dbtx, bus publisher, agent *Agent, threadID, body string, parentReplyID *string) (Reply, error) {
+	if err := requireUnlocked(ctx, db, agent, threadID); err != nil {
+		return Reply{}, err
+	}
+	if body == "" {
+		return Reply{}, inputError("body is required")
+	}
+	if err := checkText("body", body, limitsFrom(ctx).MaxBodyLength); err != nil {
+		return Reply{}, err
+	}
+
+	// A parent reply must belong to the same thread
+	depth := 0
+	if parentReplyID != nil {
+		var parentThreadID string
+		err := db.QueryRowContext(ctx, "SELECT thread_id FROM replies WHERE id = ?", *parentReplyID).Scan(&parentThreadID)
+		if err == sql.ErrNoRows || (err == nil && parentThreadID != threadID) {
+			return Reply{}, inputError("parent reply not found in this thread")
+		}
+		if err != nil {
+			return Reply{}, fmt.Errorf("query parent reply: %w", err)
+		}
+		err = db.QueryRowContext(ctx,
+			`WITH RECURSIVE ancestors(id, parent_reply_id) AS (
+				SELECT id, parent_reply_id FROM replies WHERE id = ?
+				UNION ALL
+				SELECT r.id, r.parent_reply_id FROM replies r JOIN ancestors ON r.id = ancestors.parent_reply_id
+			)
+			SELECT COUNT(*) FROM ancestors`, *parentReplyID,
+		).Scan(&depth)
+		if err != nil {
+			return Reply{}, fmt.Errorf("query parent reply depth: %w", err)
+		}
+	}
+
+	body, err := checkContent(ctx, db, agent, quarantinedReply, threadID, body, quarantinedReplyInput{ParentReplyID: parentReplyID})
+	if err != nil {
+		return Reply{}, err
+	}
+
+	id := uuid.New().String()
+	now := time.Now()
+
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO replies (id, thread_id, parent_reply_id, agent_id, body, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id, threadID, parentReplyID, agent.ID, body, now, now,
+	)
+	if err != nil {
+		return Reply{}, fmt.Errorf("insert reply: %w", err)
+	}
+
+	if err := recordMentions(ctx, db, threadID, &id, agent.ID, body); err != nil {
+		log.Printf("record reply mentions: %v", err)
+	}
+	if err := recordReferences(ctx, db, threadID, &id, body); err != nil {
+		log.Printf("record reply references: %v", err)
+	}
+	if err := notifySubscribers(ctx, db, threadID, agent.ID, notificationReply, &id, nil); err != nil {
+		log.Printf("notify subscribers: %v", err)
+	}
+
+	reply := Reply{
+		ID:            id,
+		ThreadID:      threadID,
+		ParentReplyID: parentReplyID,
+		Depth:         depth,
+		AgentID:       agent.ID,
+		AgentName:     agent.Name,
+		Body:          body,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Statuses:      []StatusTag{},
+	}
+	bus.Publish(Event{Kind: eventReplyCreated, ThreadID: threadID, Reply: &reply, CreatedAt: now})
+	return reply, nil
+}
+
+// requireUnlocked checks that a thread exists, agent can read it, and it is
+// open to new replies and status tags: neither merged, locked, nor
+// scheduled.
+func requireUnlocked(ctx context.Context, db dbtx, agent *Agent, threadID string) error {
+	visible, args := visibleCondition(agent)
+	var locked, readable bool
+	var publishAt *time.Time
+	var mergedInto *string
+	err := db.QueryRowContext(ctx,
+		"SELECT t.locked, t.publish_at, t.merged_into, "+visible+" FROM threads t WHERE t.id = ?",
+		append(args, threadID)...,
+	).Scan(&locked, &publishAt, &mergedInto, &readable)
+	if err == sql.ErrNoRows || (err == nil && !readable) {
+		return notFoundError("thread not found")
+	}
+	if err != nil {
+		return fmt.Errorf("query thread: %w", err)
+	}
+	if mergedInto != nil {
+		return conflictError(fmt.Sprintf("thread was merged into %s", *mergedInto))
+	}
+	if locked {
+		return conflictError("thread is locked")
+	}
+	if publishAt != nil {
+		return conflictError(fmt.Sprintf("thread is scheduled to publish at %s", publishAt.UTC().Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// createThreadStatus tags a thread with a status. Lifecycle tags must be a
+// valid transition from the thread's current status, and supersede it.
+// Resolving a thread tells whatever waits on it, and with unblock also
+// clears their blocked tags on it.
+func createThreadStatus(ctx context.Context, db dbtx, bus publisher, agent *Agent, threadID, tag string, referenceID *string, unblock bool) (StatusTag, error) {
+	return inTx(ctx, db, bus, func(tx dbtx, bus publisher) (StatusTag, error) {
+		return addThreadStatus(ctx, tx, bus, agent, threadID, tag, referenceID, unblock)
+	})
+}
+
+// addThreadStatus is createThreadStatus inside its transaction.
+func addThreadStatus(ctx context.Context, db dbtx, bus publisher, agent *Agent, threadID, tag string, referenceID *string, unblock bool) (StatusTag, error) {
+	if err := requireUnlocked(ctx, db, agent, threadID); err != nil {
+		return StatusTag{}, err
+	}
+	if err := checkTransition(ctx, db, threadID, tag); err != nil {
+		return StatusTag{}, err
+	}
+
+	st, err := insertStatus(ctx, db, bus, agent, threadID, StatusTag{ThreadID: &threadID}, tag, referenceID)
+	if err != nil {
+		return StatusTag{}, err
+	}
+	if tag == statusResolved {
+		if err := propagateResolution(ctx, db, bus, agent, threadID, st, unblock); err != nil {
+			return StatusTag{}, err
+		}
+	}
+	return st, nil
+}
+
+// createReplyStatus tags a reply with a status.
+func createReplyStatus(ctx context.Context, db dbtx, bus publisher, agent *Agent, replyID, tag string, referenceID *string) (StatusTag, error) {
+	return inTx(ctx, db, bus, func(tx dbtx, bus publisher) (StatusTag, error) {
+		return addReplyStatus(ctx, tx, bus, agent, replyID, tag, referenceID)
+	})
+}
+
+// addReplyStatus is createReplyStatus inside its transaction.
+func addReplyStatus(ctx context.Context, db dbtx, bus publisher, agent *Agent, replyID, tag string, referenceID *string) (StatusTag, error) {
+	// Verify reply exists
+	var threadID string
+	err := db.QueryRowContext(ctx, "SELECT thread_id FROM replies WHERE id = ?", replyID).Scan(&threadID)
+	if err == sql.ErrNoRows {
+		return StatusTag{}, notFoundError("reply not found")
+	}
+	if err != nil {
+		return StatusTag{}, fmt.Errorf("query reply: %w", err)
+	}
+	if err := requireUnlocked(ctx, db, agent, threadID); err != nil {
+		return StatusTag{}, err
+	}
+
+	st := StatusTag{ReplyID: &replyID}
+	return insertStatus(ctx, db, bus, agent, threadID, st, tag, referenceID)
+}
+
+// insertStatus stores a status tag whose thread or reply target is already
+// set on st, and notifies subscribers of threadID.
+func insertStatus(ctx context.Context, db dbtx, bus publisher, agent *Agent, threadID string, st StatusTag, tag string, referenceID *string) (StatusTag, error) {
+	if !validStatusTags[tag] {
+		return StatusTag{}, inputError("invalid status tag")
+	}
+	if err := checkDependencyCycle(ctx, db, threadID, tag, referenceID); err != nil {
+		return StatusTag{}, err
+	}
+
+	st.ID = uuid.New().String()
+	st.AgentID = agent.ID
+	st.AgentName = agent.Name
+	st.Tag = tag
+	st.ReferenceID = referenceID
+	st.CreatedAt = time.Now()
+
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO status_tags (id, thread_id, reply_id, agent_id, tag, reference_id, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		st.ID, st.ThreadID, st.ReplyID, agent.ID, tag, referenceID, st.CreatedAt,
+	)
+	if err != nil {
+		return StatusTag{}, fmt.Errorf("insert status tag: %w", err)
+	}
+	if st.ThreadID != nil {
+		if err := supersedeStatuses(ctx, db, threadID, st); err != nil {
+			return StatusTag{}, err
+		}
+	}
+
+	if err := notifySubscribers(ctx, db, threadID, agent.ID, notificationStatus, st.ReplyID, &st.ID); err != nil {
+		log.Printf("notify subscribers: %v", err)
+	}
+
+	bus.Publish(Event{Kind: eventStatusCreated, ThreadID: threadID, Status: &st, CreatedAt: st.CreatedAt})
+	return st, nil
+}
+
+// listStatusesByTag returns every status tag with the given tag that has not
+// been superseded, newest first, on threads and replies agent can read.
+func listStatusesByTag(ctx context.Context, db *sql.DB, agent *Agent, tag string) ([]StatusTag, error) {
+	visible, args := visibleStatusCondition(agent)
+	return queryStatusTags(ctx, db,
+		`SELECT `+statusColumns+` `+statusJoins+`
+		WHERE s.tag = ? AND s.superseded_by IS NULL AND `+visible+`
+		ORDER BY s.created_at DESC`, append([]interface{}{tag}, args...)...,
+	)
+}
+
+// replyColumns selects a reply, aliased r, and its author's name from
+// replyJoins, for scanReply.
+const (
+	replyColumns = `r.id, r.thread_id, r.parent_reply_id, r.agent_id, a.name, r.body, r.created_at, r.updated_at`
+	replyJoins   = `FROM replies r JOIN agents a ON r.agent_id = a.id`
+)
+
+// statusColumns selects a status tag, aliased s, and its author's name from
+// statusJoins, for scanStatusTag.
+const (
+	statusColumns = `s.id, s.thread_id, s.reply_id, s.agent_id, a.name, s.tag, s.reference_id, s.superseded_by, s.created_at`
+	statusJoins   = `FROM status_tags s JOIN agents a ON s.agent_id = a.id`
+)
+
+// scanReply scans a row selected with replyColumns.
+func scanReply(row rowScanner) (Reply, error) {
+	var r Reply
+	err := row.Scan(&r.ID, &r.ThreadID, &r.ParentReplyID, &r.AgentID, &r.AgentName, &r.Body, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// scanReplyDepth scans a row selected with replyColumns followed by the
+// reply's depth.
+func scanReplyDepth(row rowScanner) (Reply, error) {
+	var r Reply
+	err := row.Scan(&r.ID, &r.ThreadID, &r.ParentReplyID, &r.AgentID, &r.AgentName, &r.Body, &r.CreatedAt, &r.UpdatedAt, &r.Depth)
+	return r, err
+}
+
+// scanStatusTag scans a row selected with statusColumns.
+func scanStatusTag(row rowScanner) (StatusTag, error) {
+	var st StatusTag
+	err := row.Scan(&st.ID, &st.ThreadID, &st.ReplyID, &st.AgentID, &st.AgentName, &st.Tag, &st.ReferenceID, &st.SupersededBy, &st.CreatedAt)
+	return st, err
+}
+
+// queryRows runs query and scans each row it returns with scan. The result
+// is empty rather than nil when there are no rows.
+func queryRows[T any](ctx context.Context, db dbtx, scan func(rowScanner) (T, error), query string, args ...interface{}) ([]T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// queryThreads returns the threads selected with threadColumns by query.
+func queryThreads(ctx context.Context, db dbtx, query string, args ...interface{}) ([]Thread, error) {
+	threads, err := queryRows(ctx, db, scanThread, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query threads: %w", err)
+	}
+	return threads, nil
+}
+
+// queryReplies returns the replies selected with replyColumns by query.
+func queryReplies(ctx context.Context, db dbtx, query string, args ...interface{}) ([]Reply, error) {
+	replies, err := queryRows(ctx, db, scanReply, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query replies: %w", err)
+	}
+	return replies, nil
+}
+
+// queryStatusTags returns the status tags selected with statusColumns by
+// query.
+func queryStatusTags(ctx context.Context, db dbtx, query string, args ...interface{}) ([]StatusTag, error) {
+	statuses, err := queryRows(ctx, db, scanStatusTag, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query status tags: %w", err)
+	}
+	return statuses, nil
+}
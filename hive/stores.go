@@ -0,0 +1,193 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// The store interfaces are the forum operations the APIs share, so an API
+// can run on another backend, or on a fake in tests, without change.
+// sqlStore implements them with the store functions over SQLite. The gRPC
+// server, programs embedding the forum (through Server.Store), and the REST
+// handlers for the same operations go through them: API key
+// authentication, creating, reading, editing, and deleting threads,
+// replies, and status tags, votes, and pinning, archiving, and locking.
+// Everything else still runs its SQL in place, in the REST API (search,
+// participants, attachments, merges, subscriptions, messages, and the
+// admin endpoints among it), GraphQL, and the dashboards.
+
+// ThreadStore creates, reads, edits, and moderates threads.
+type ThreadStore interface {
+	CreateThread(ctx context.Context, agent *Agent, title, body string, tags []string, dueAt *time.Time, priority string, publishAt *time.Time, visibility string, participants []string) (Thread, error)
+	// ListThreads returns a page of the threads matching f and how many
+	// match in all.
+	ListThreads(ctx context.Context, f ThreadFilter, limit, offset int) ([]Thread, int, error)
+	// VisibleThread returns a thread with its replies and status tags if
+	// agent can read it, or a notFoundError.
+	VisibleThread(ctx context.Context, agent *Agent, threadID string) (Thread, error)
+	// VisibleThreadPage is VisibleThread with only limit of the replies,
+	// from offset in tree order. ReplyCount is still the total.
+	VisibleThreadPage(ctx context.Context, agent *Agent, threadID string, limit, offset int) (Thread, error)
+	// EventVisible reports whether agent can read the thread e is about.
+	EventVisible(ctx context.Context, agent *Agent, e Event) bool
+	// UpdateThread sets the fields u sets on a thread agent wrote, and
+	// returns the thread without its replies.
+	UpdateThread(ctx context.Context, agent *Agent, threadID string, u ThreadUpdate) (Thread, error)
+	// DeleteThread deletes a thread agent wrote or moderates.
+	DeleteThread(ctx context.Context, agent *Agent, threadID string) error
+	// VoteThread records agent's vote, 1 or -1, and returns the thread's
+	// score.
+	VoteThread(ctx context.Context, agent *Agent, threadID string, value int) (int, error)
+	UnvoteThread(ctx context.Context, agent *Agent, threadID string) error
+	// SetThreadPinned, SetThreadArchived, and SetThreadLocked need a role
+	// with the permission, and return the thread without its replies.
+	SetThreadPinned(ctx context.Context, agent *Agent, threadID string, pinned bool) (Thread, error)
+	SetThreadArchived(ctx context.Context, agent *Agent, threadID string, archived bool) (Thread, error)
+	SetThreadLocked(ctx context.Context, agent *Agent, threadID string, locked bool) (Thread, error)
+}
+
+// ReplyStore creates, edits, and deletes replies.
+type ReplyStore interface {
+	CreateReply(ctx context.Context, agent *Agent, threadID, body string, parentReplyID *string) (Reply, error)
+	UpdateReply(ctx context.Context, agent *Agent, replyID, body string) (Reply, error)
+	DeleteReply(ctx context.Context, agent *Agent, replyID string) error
+}
+
+// StatusStore creates, reads, and deletes status tags.
+type StatusStore interface {
+	CreateThreadStatus(ctx context.Context, agent *Agent, threadID, tag string, referenceID *string, unblock bool) (StatusTag, error)
+	CreateReplyStatus(ctx context.Context, agent *Agent, replyID, tag string, referenceID *string) (StatusTag, error)
+	// StatusesByTag returns the current status tags with tag that agent
+	// can read, newest first.
+	StatusesByTag(ctx context.Context, agent *Agent, tag string) ([]StatusTag, error)
+	// Dependencies returns the dependency graph agent can read.
+	Dependencies(ctx context.Context, agent *Agent) ([]DependencyEdge, error)
+	DeleteStatus(ctx context.Context, agent *Agent, statusID string) error
+}
+
+// AgentStore registers and authenticates agents and records their
+// activity.
+type AgentStore interface {
+	// CreateAgent registers an agent and returns it with its API key, which
+	// isn't stored.
+	CreateAgent(ctx context.Context, name, owner, workspace string, scopes []string, role string, expiresAt *time.Time) (Agent, string, error)
+	// AuthenticateAPIKey returns the agent rawKey belongs to, or nil if it
+	// belongs to none.
+	AuthenticateAPIKey(ctx context.Context, rawKey string, now time.Time) (*Agent, error)
+	// TouchAgent records that the agent was last seen at at.
+	TouchAgent(ctx context.Context, agentID string, at time.Time) error
+}
+
+// Store is every store interface.
+type Store interface {
+	ThreadStore
+	ReplyStore
+	StatusStore
+	AgentStore
+}
+
+// sqlStore is the Store over a SQLite database, publishing the events of
+// what it creates on bus and holding what is posted to limits.
+type sqlStore struct {
+	db     *sql.DB
+	bus    publisher
+	limits Limits
+}
+
+func newSQLStore(db *sql.DB, bus publisher, limits Limits) *sqlStore {
+	return &sqlStore{db: db, bus: bus, limits: limits}
+}
+
+func (s *sqlStore) CreateThread(ctx context.Context, agent *Agent, title, body string, tags []string, dueAt *time.Time, priority string, publishAt *time.Time, visibility string, participants []string) (Thread, error) {
+	return createThread(withLimits(ctx, s.limits), s.db, s.bus, agent, title, body, tags, dueAt, priority, publishAt, visibility, participants)
+}
+
+func (s *sqlStore) ListThreads(ctx context.Context, f ThreadFilter, limit, offset int) ([]Thread, int, error) {
+	return listThreads(ctx, s.db, f, limit, offset)
+}
+
+func (s *sqlStore) VisibleThread(ctx context.Context, agent *Agent, threadID string) (Thread, error) {
+	return loadVisibleThread(ctx, s.db, agent, threadID)
+}
+
+func (s *sqlStore) VisibleThreadPage(ctx context.Context, agent *Agent, threadID string, limit, offset int) (Thread, error) {
+	return loadVisibleThreadPage(ctx, s.db, agent, threadID, limit, offset)
+}
+
+func (s *sqlStore) EventVisible(ctx context.Context, agent *Agent, e Event) bool {
+	return eventVisible(ctx, s.db, agent, e)
+}
+
+func (s *sqlStore) UpdateThread(ctx context.Context, agent *Agent, threadID string, u ThreadUpdate) (Thread, error) {
+	return updateThread(withLimits(ctx, s.limits), s.db, agent, threadID, u)
+}
+
+func (s *sqlStore) DeleteThread(ctx context.Context, agent *Agent, threadID string) error {
+	return deleteThread(ctx, s.db, agent, threadID)
+}
+
+func (s *sqlStore) VoteThread(ctx context.Context, agent *Agent, threadID string, value int) (int, error) {
+	return voteThread(ctx, s.db, agent, threadID, value)
+}
+
+func (s *sqlStore) UnvoteThread(ctx context.Context, agent *Agent, threadID string) error {
+	return unvoteThread(ctx, s.db, agent, threadID)
+}
+
+func (s *sqlStore) SetThreadPinned(ctx context.Context, agent *Agent, threadID string, pinned bool) (Thread, error) {
+	return setThreadFlag(ctx, s.db, agent, permPinThreads, "pinned", threadID, pinned)
+}
+
+func (s *sqlStore) SetThreadArchived(ctx context.Context, agent *Agent, threadID string, archived bool) (Thread, error) {
+	return setThreadFlag(ctx, s.db, agent, permArchiveThreads, "archived", threadID, archived)
+}
+
+func (s *sqlStore) SetThreadLocked(ctx context.Context, agent *Agent, threadID string, locked bool) (Thread, error) {
+	return setThreadFlag(ctx, s.db, agent, permLockThreads, "locked", threadID, locked)
+}
+
+func (s *sqlStore) CreateReply(ctx context.Context, agent *Agent, threadID, body string, parentReplyID *string) (Reply, error) {
+	return createReply(withLimits(ctx, s.limits), s.db, s.bus, agent, threadID, body, parentReplyID)
+}
+
+func (s *sqlStore) UpdateReply(ctx context.Context, agent *Agent, replyID, body string) (Reply, error) {
+	return updateReply(withLimits(ctx, s.limits), s.db, agent, replyID, body)
+}
+
+func (s *sqlStore) DeleteReply(ctx context.Context, agent *Agent, replyID string) error {
+	return deleteReply(ctx, s.db, agent, replyID)
+}
+
+func (s *sqlStore) CreateThreadStatus(ctx context.Context, agent *Agent, threadID, tag string, referenceID *string, unblock bool) (StatusTag, error) {
+	return createThreadStatus(ctx, s.db, s.bus, agent, threadID, tag, referenceID, unblock)
+}
+
+func (s *sqlStore) CreateReplyStatus(ctx context.Context, agent *Agent, replyID, tag string, referenceID *string) (StatusTag, error) {
+	return createReplyStatus(ctx, s.db, s.bus, agent, replyID, tag, referenceID)
+}
+
+func (s *sqlStore) StatusesByTag(ctx context.Context, agent *Agent, tag string) ([]StatusTag, error) {
+	return listStatusesByTag(ctx, s.db, agent, tag)
+}
+
+func (s *sqlStore) Dependencies(ctx context.Context, agent *Agent) ([]DependencyEdge, error) {
+	return queryDependencies(ctx, s.db, agent)
+}
+
+func (s *sqlStore) DeleteStatus(ctx context.Context, agent *Agent, statusID string) error {
+	return deleteStatus(ctx, s.db, agent, statusID)
+}
+
+func (s *sqlStore) CreateAgent(ctx context.Context, name, owner, workspace string, scopes []string, role string, expiresAt *time.Time) (Agent, string, error) {
+	return createAgent(ctx, s.db, name, owner, workspace, scopes, role, expiresAt)
+}
+
+func (s *sqlStore) AuthenticateAPIKey(ctx context.Context, rawKey string, now time.Time) (*Agent, error) {
+	return authenticateAPIKey(ctx, s.db, rawKey, now)
+}
+
+func (s *sqlStore) TouchAgent(ctx context.Context, agentID string, at time.Time) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE agents SET last_seen_at = ? WHERE id = ?", at, agentID)
+	return err
+}
@@ -0,0 +1,169 @@
+package hive
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeStore is a Store of canned answers, recording what it was asked to
+// create or update.
+type fakeStore struct {
+	Store
+	agent   *Agent
+	replies []Reply
+	updates []ThreadUpdate
+	err     error
+}
+
+func (s *fakeStore) UpdateThread(ctx context.Context, agent *Agent, threadID string, u ThreadUpdate) (Thread, error) {
+	if s.err != nil {
+		return Thread{}, s.err
+	}
+	s.updates = append(s.updates, u)
+	return Thread{ID: threadID, AgentID: agent.ID}, nil
+}
+
+func (s *fakeStore) CreateReply(ctx context.Context, agent *Agent, threadID, body string, parentReplyID *string) (Reply, error) {
+	if s.err != nil {
+		return Reply{}, s.err
+	}
+	reply := Reply{ID: "r1", ThreadID: threadID, AgentID: agent.ID, Body: body, ParentReplyID: parentReplyID}
+	s.replies = append(s.replies, reply)
+	return reply, nil
+}
+
+func (s *fakeStore) AuthenticateAPIKey(ctx context.Context, rawKey string, now time.Time) (*Agent, error) {
+	if rawKey == "good" {
+		return s.agent, nil
+	}
+	return nil, nil
+}
+
+func (s *fakeStore) TouchAgent(ctx context.Context, agentID string, at time.Time) error {
+	return nil
+}
+
+func TestHandleCreateReplyWithFakeStore(t *testing.T) {
+	agent := &Agent{ID: "a1", Name: "tester"}
+	tests := []struct {
+		name       string
+		err        error
+		body       string
+		wantStatus int
+	}{
+		{"created", nil, `{"body": "hello"}`, http.StatusCreated},
+		{"bad input", inputError("body is required"), `{"body": ""}`, http.StatusBadRequest},
+		{"no thread", notFoundError("thread not found"), `{"body": "hello"}`, http.StatusNotFound},
+		{"not JSON", nil, `hello`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{err: tt.err}
+			r := httptest.NewRequest("POST", "/api/v1/threads/t1/replies", strings.NewReader(tt.body))
+			r.SetPathValue("id", "t1")
+			r = r.WithContext(context.WithValue(r.Context(), agentContextKey, agent))
+			w := httptest.NewRecorder()
+
+			handleCreateReply(store, w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d (%s), want %d", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+			var reply Reply
+			if err := json.NewDecoder(w.Body).Decode(&reply); err != nil {
+				t.Fatal(err)
+			}
+			if len(store.replies) != 1 || reply.ThreadID != "t1" || reply.AgentID != "a1" || reply.Body != "hello" {
+				t.Errorf("reply %+v, store got %+v", reply, store.replies)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateThreadWithFakeStore(t *testing.T) {
+	agent := &Agent{ID: "a1", Name: "tester"}
+	tests := []struct {
+		name       string
+		method     string
+		err        error
+		body       string
+		wantStatus int
+		wantUpdate string
+	}{
+		{"put", "PUT", nil, `{"title": "New", "due_at": null}`, http.StatusOK, "title New, due date cleared"},
+		{"patch", "PATCH", nil, `{"title": "New"}`, http.StatusOK, "title New, due date kept"},
+		{"bad due date", "PUT", nil, `{"due_at": "soon"}`, http.StatusBadRequest, ""},
+		{"not the author", "PUT", forbiddenError("you can only update your own threads"), `{"title": "New"}`, http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{err: tt.err}
+			r := httptest.NewRequest(tt.method, "/api/v1/threads/t1", strings.NewReader(tt.body))
+			r.SetPathValue("id", "t1")
+			r = r.WithContext(context.WithValue(r.Context(), agentContextKey, agent))
+			w := httptest.NewRecorder()
+
+			if tt.method == "PATCH" {
+				r.Header.Set("Content-Type", "application/merge-patch+json")
+				handlePatchThread(store, w, r)
+			} else {
+				handleUpdateThread(store, w, r)
+			}
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d (%s), want %d", w.Code, w.Body, tt.wantStatus)
+			}
+			if tt.wantUpdate == "" {
+				return
+			}
+			if len(store.updates) != 1 {
+				t.Fatalf("store got %d updates, want 1", len(store.updates))
+			}
+			u := store.updates[0]
+			got := "title " + *u.Title + ", due date kept"
+			if u.SetDueAt && u.DueAt == nil {
+				got = "title " + *u.Title + ", due date cleared"
+			}
+			if got != tt.wantUpdate {
+				t.Errorf("update %s, want %s", got, tt.wantUpdate)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthWithFakeStore(t *testing.T) {
+	store := &fakeStore{agent: &Agent{ID: "a1", Name: "tester"}}
+	var got *Agent
+	handler := APIKeyAuth(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = AgentFromContext(r.Context())
+	}))
+
+	for _, tt := range []struct {
+		auth       string
+		wantStatus int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer bad", http.StatusUnauthorized},
+		{"Bearer good", http.StatusOK},
+	} {
+		got = nil
+		r := httptest.NewRequest("GET", "/api/v1/threads", nil)
+		if tt.auth != "" {
+			r.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.wantStatus {
+			t.Errorf("%q: status %d, want %d", tt.auth, w.Code, tt.wantStatus)
+		}
+		if (got != nil) != (tt.wantStatus == http.StatusOK) {
+			t.Errorf("%q: handler saw agent %v", tt.auth, got)
+		}
+	}
+}
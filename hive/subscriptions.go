@@ -0,0 +1,270 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notification kinds delivered to thread subscribers, to the agents
+// waiting on a thread when it is resolved, and to those answerable for a
+// thread that breaches an SLA.
+const (
+	notificationReply     = "reply"
+	notificationStatus    = "status"
+	notificationUnblocked = "unblocked"
+	notificationSLABreach = "sla_breach"
+)
+
+// subscribe records that an agent follows a thread. Subscribing twice is a no-op.
+func subscribe(ctx context.Context, db dbtx, agentID, threadID string) error {
+	_, err := db.ExecContext(ctx,
+		`INSERT INTO subscriptions (agent_id, thread_id, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (agent_id, thread_id) DO NOTHING`,
+		agentID, threadID, time.Now(),
+	)
+	return err
+}
+
+// notifySubscribers creates a notification for every subscriber of a thread
+// who can read it, except the agent that caused the event.
+func notifySubscribers(ctx context.Context, db dbtx, threadID, actorID, kind string, replyID, statusID *string) error {
+	rows, err := db.QueryContext(ctx,
+		`SELECT v.id FROM subscriptions s
+		JOIN agents v ON s.agent_id = v.id
+		JOIN threads t ON s.thread_id = t.id
+		WHERE s.thread_id = ? AND s.agent_id != ? AND `+readerCondition, threadID, actorID,
+	)
+	if err != nil {
+		return fmt.Errorf("query subscribers: %w", err)
+	}
+	var subscribers []string
+	for rows.Next() {
+		var agentID string
+		if err := rows.Scan(&agentID); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan subscriber: %w", err)
+		}
+		subscribers = append(subscribers, agentID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate subscribers: %w", err)
+	}
+
+	now := time.Now()
+	for _, agentID := range subscribers {
+		_, err := db.ExecContext(ctx,
+			`INSERT INTO notifications (id, agent_id, kind, thread_id, reply_id, status_id, actor_id, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			uuid.New().String(), agentID, kind, threadID, replyID, statusID, actorID, now,
+		)
+		if err != nil {
+			return fmt.Errorf("insert notification: %w", err)
+		}
+	}
+	return nil
+}
+
+// handleSubscribe subscribes the requesting agent to a thread.
+func handleSubscribe(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing thread id"})
+		return
+	}
+
+	// Verify the thread exists and the agent can read it
+	if err := requireVisible(r.Context(), db, agent, threadID); err != nil {
+		writeStoreError(w, err, "failed to query thread")
+		return
+	}
+
+	if err := subscribe(r.Context(), db, agent.ID, threadID); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to subscribe"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"thread_id": threadID, "status": "subscribed"})
+}
+
+// handleUnsubscribe removes the requesting agent's subscription to a thread.
+func handleUnsubscribe(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing thread id"})
+		return
+	}
+
+	res, err := db.ExecContext(r.Context(), "DELETE FROM subscriptions WHERE agent_id = ? AND thread_id = ?", agent.ID, threadID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to unsubscribe"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "not subscribed to this thread"})
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleListSubscriptions lists the threads the requesting agent follows.
+func handleListSubscriptions(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	visible, args := visibleCondition(agent)
+	rows, err := db.QueryContext(r.Context(),
+		`SELECT s.thread_id, t.title, a.name, s.created_at
+		FROM subscriptions s
+		JOIN threads t ON s.thread_id = t.id
+		JOIN agents a ON t.agent_id = a.id
+		WHERE s.agent_id = ? AND `+visible+`
+		ORDER BY s.created_at DESC`, append([]interface{}{agent.ID}, args...)...,
+	)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query subscriptions"})
+		return
+	}
+	defer rows.Close()
+
+	subscriptions := []Subscription{}
+	for rows.Next() {
+		var s Subscription
+		if err := rows.Scan(&s.ThreadID, &s.ThreadTitle, &s.ThreadAgentName, &s.CreatedAt); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to scan subscription"})
+			return
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to iterate subscriptions"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, subscriptions)
+}
+
+// handleListNotifications lists notifications for the requesting agent,
+// newest first. Pass ?unread=true to only return unread notifications.
+func handleListNotifications(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 {
+		limit = 50
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	unreadClause := ""
+	if v := r.URL.Query().Get("unread"); v == "true" || v == "1" {
+		unreadClause = "AND n.read_at IS NULL"
+	}
+
+	visible, args := visibleCondition(agent)
+	args = append([]interface{}{agent.ID}, args...)
+	rows, err := db.QueryContext(r.Context(),
+		fmt.Sprintf(
+			`SELECT n.id, n.kind, n.thread_id, t.title, n.reply_id, n.status_id, COALESCE(st.tag, ''),
+				n.actor_id, a.name, n.read_at, n.created_at
+			FROM notifications n
+			JOIN threads t ON n.thread_id = t.id
+			JOIN agents a ON n.actor_id = a.id
+			LEFT JOIN status_tags st ON n.status_id = st.id
+			WHERE n.agent_id = ? AND %s %s
+			ORDER BY n.created_at DESC
+			LIMIT ?`, visible, unreadClause,
+		), append(args, limit)...,
+	)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query notifications"})
+		return
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.ID, &n.Kind, &n.ThreadID, &n.ThreadTitle, &n.ReplyID, &n.StatusID, &n.StatusTag, &n.ActorID, &n.ActorName, &n.ReadAt, &n.CreatedAt); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to scan notification"})
+			return
+		}
+		notifications = append(notifications, n)
+	}
+	if err := rows.Err(); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to iterate notifications"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, notifications)
+}
+
+// handleMarkNotificationsRead marks notifications as read. With a JSON body of
+// {"ids": [...]} only those notifications are marked; otherwise all are.
+func handleMarkNotificationsRead(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var input struct {
+		IDs []string `json:"ids"`
+	}
+	if r.ContentLength != 0 {
+		if err := readJSON(r, &input); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+			return
+		}
+	}
+
+	now := time.Now()
+	var marked int64
+	if len(input.IDs) == 0 {
+		res, err := db.ExecContext(r.Context(), "UPDATE notifications SET read_at = ? WHERE agent_id = ? AND read_at IS NULL", now, agent.ID)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to mark notifications read"})
+			return
+		}
+		marked, _ = res.RowsAffected()
+	} else {
+		for _, id := range input.IDs {
+			res, err := db.ExecContext(r.Context(), "UPDATE notifications SET read_at = ? WHERE id = ? AND agent_id = ? AND read_at IS NULL", now, id, agent.ID)
+			if err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to mark notifications read"})
+				return
+			}
+			n, _ := res.RowsAffected()
+			marked += n
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int64{"marked": marked})
+}
@@ -0,0 +1,322 @@
+package hive
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Summaries save agents from re-reading long threads. When
+// SUMMARY_PROVIDER is set, GET /api/v1/threads/{id}/summary asks the
+// provider to summarize the thread's transcript (title, body, replies, and
+// status tags) and caches the result in the thread_summaries table, keyed
+// by the transcript's hash. A new or edited reply, status tag, or thread
+// edit changes the hash, so the next request summarizes the thread again.
+
+// Summary providers.
+const (
+	// summaryOpenAI calls an OpenAI-compatible /v1/chat/completions
+	// endpoint, such as OpenAI's or a local Ollama or vLLM server.
+	summaryOpenAI = "openai"
+	// summaryExtract picks sentences from the thread locally. It needs no
+	// service, but only quotes the thread rather than condensing it.
+	summaryExtract = "extract"
+)
+
+// maxSummaryTranscript caps the bytes of a transcript sent to the provider.
+// Longer threads keep their opening post and as many of the latest replies
+// as fit.
+const maxSummaryTranscript = 48000
+
+// summaryPrompt instructs the provider how to summarize a transcript.
+const summaryPrompt = `You summarize threads from a forum where AI agents coordinate their work.
+Write a concise summary, at most 150 words of Markdown, for an agent who hasn't read the thread:
+what it is about, what was decided or done, where it stands now, what is still open, and who is doing what.
+Name agents as they appear. Don't add a title or preamble.`
+
+// summariesSchema caches one summary per thread. content_hash is the hash
+// of the transcript summarized.
+const summariesSchema = `
+	CREATE TABLE IF NOT EXISTS thread_summaries (
+		thread_id TEXT PRIMARY KEY REFERENCES threads(id) ON DELETE CASCADE,
+		model TEXT NOT NULL,
+		content_hash TEXT NOT NULL,
+		reply_count INTEGER NOT NULL,
+		summary TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+`
+
+// summarizer summarizes a thread transcript.
+type summarizer interface {
+	// Model names the summaries' model; summaries from another model are
+	// regenerated.
+	Model() string
+	Summarize(ctx context.Context, t Thread, transcript string) (string, error)
+}
+
+// newSummarizer returns the summarizer cfg configures, or nil if summaries
+// are disabled.
+func newSummarizer(cfg Config) (summarizer, error) {
+	switch cfg.SummaryProvider {
+	case "":
+		return nil, nil
+	case summaryOpenAI:
+		return openAISummarizer{newChatClient(cfg.SummaryURL, cfg.SummaryAPIKey, cfg.SummaryModel, 2*time.Minute)}, nil
+	case summaryExtract:
+		return extractSummarizer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown SUMMARY_PROVIDER %q (use %s or %s)", cfg.SummaryProvider, summaryOpenAI, summaryExtract)
+	}
+}
+
+// openAISummarizer asks an OpenAI-compatible chat model for summaries.
+type openAISummarizer struct {
+	chat *chatClient
+}
+
+func (s openAISummarizer) Model() string { return s.chat.model }
+
+func (s openAISummarizer) Summarize(ctx context.Context, t Thread, transcript string) (string, error) {
+	summary, err := s.chat.complete(ctx, summaryPrompt, transcript)
+	if err != nil {
+		return "", fmt.Errorf("summarize: %w", err)
+	}
+	return summary, nil
+}
+
+// extractSummarizer quotes the first sentence of the thread and of its
+// latest replies, with where the thread stands.
+type extractSummarizer struct{}
+
+// extractLatestReplies is how many of the latest replies the extract
+// provider quotes.
+const extractLatestReplies = 3
+
+func (extractSummarizer) Model() string { return summaryExtract }
+
+func (extractSummarizer) Summarize(ctx context.Context, t Thread, transcript string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s\n\n", t.AgentName, firstSentence(t.Body))
+	fmt.Fprintf(&b, "Status: %s", t.CurrentStatus)
+	if len(t.Replies) == 0 {
+		b.WriteString(". No replies yet.")
+		return b.String(), nil
+	}
+
+	var authors []string
+	seen := map[string]bool{}
+	for _, r := range t.Replies {
+		if !seen[r.AgentName] {
+			seen[r.AgentName] = true
+			authors = append(authors, r.AgentName)
+		}
+	}
+	replies := "replies"
+	if len(t.Replies) == 1 {
+		replies = "reply"
+	}
+	fmt.Fprintf(&b, ". %d %s from %s.\n\nLatest:\n", len(t.Replies), replies, strings.Join(authors, ", "))
+	latest := t.Replies[max(0, len(t.Replies)-extractLatestReplies):]
+	for _, r := range latest {
+		fmt.Fprintf(&b, "- %s: %s\n", r.AgentName, firstSentence(r.Body))
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// firstSentence returns the first sentence of a Markdown body on one line,
+// or its first 200 bytes if the sentence runs longer.
+func firstSentence(body string) string {
+	text := strings.Join(strings.Fields(body), " ")
+	if i := strings.IndexAny(text, ".!?"); i >= 0 {
+		text = text[:i+1]
+	}
+	if len(text) > 200 {
+		text = strings.ToValidUTF8(text[:200], "") + "…"
+	}
+	return text
+}
+
+// summaryTranscript renders a thread for the provider: its title, author,
+// tags, status, body, and replies with their status tags, dropping the
+// oldest replies if it would run past maxSummaryTranscript.
+func summaryTranscript(t Thread) string {
+	var head strings.Builder
+	fmt.Fprintf(&head, "# %s\n\nBy %s. Status: %s.", t.Title, t.AgentName, t.CurrentStatus)
+	if len(t.Tags) > 0 {
+		fmt.Fprintf(&head, " Tags: %s.", strings.Join(t.Tags, ", "))
+	}
+	fmt.Fprintf(&head, "\n\n%s\n", t.Body)
+	writeSummaryStatuses(&head, t.Statuses)
+
+	replies := make([]string, len(t.Replies))
+	for i, r := range t.Replies {
+		var b strings.Builder
+		fmt.Fprintf(&b, "\n## Reply by %s, %s\n\n%s\n", r.AgentName, r.CreatedAt.UTC().Format(time.RFC3339), r.Body)
+		writeSummaryStatuses(&b, r.Statuses)
+		replies[i] = b.String()
+	}
+
+	size := head.Len()
+	first := len(replies)
+	for first > 0 && size+len(replies[first-1]) <= maxSummaryTranscript {
+		first--
+		size += len(replies[first])
+	}
+	transcript := head.String()
+	if first > 0 {
+		transcript += fmt.Sprintf("\n(%d earlier replies omitted)\n", first)
+	}
+	transcript += strings.Join(replies[first:], "")
+	if len(transcript) > maxSummaryTranscript {
+		transcript = strings.ToValidUTF8(transcript[:maxSummaryTranscript], "")
+	}
+	return transcript
+}
+
+// writeSummaryStatuses lists status tags under a post in a transcript.
+func writeSummaryStatuses(b *strings.Builder, statuses []StatusTag) {
+	for _, st := range statuses {
+		fmt.Fprintf(b, "\n[%s tagged %s", st.AgentName, st.Tag)
+		if st.ReferenceID != nil {
+			fmt.Fprintf(b, " %s", *st.ReferenceID)
+		}
+		b.WriteString("]\n")
+	}
+}
+
+// ThreadSummary is a summary of a thread as it stood when summarized.
+type ThreadSummary struct {
+	ThreadID   string `json:"thread_id"`
+	Summary    string `json:"summary"`
+	Model      string `json:"model"`
+	ReplyCount int    `json:"reply_count"`
+	// Cached reports whether the summary was already stored.
+	Cached    bool      `json:"cached"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Summaries summarizes threads and caches the summaries. A nil *Summaries
+// means summaries are disabled.
+type Summaries struct {
+	db         *sql.DB
+	summarizer summarizer
+
+	// pending holds the summaries being generated, by content hash, so
+	// agents asking at once share one provider call.
+	mu      sync.Mutex
+	pending map[string]*pendingSummary
+}
+
+// pendingSummary is a summary being generated. done is closed once summary
+// and err are set.
+type pendingSummary struct {
+	done    chan struct{}
+	summary string
+	err     error
+}
+
+// NewSummaries sets up the provider from cfg, returning nil if
+// SUMMARY_PROVIDER is unset.
+func NewSummaries(db *sql.DB, cfg Config) (*Summaries, error) {
+	s, err := newSummarizer(cfg)
+	if err != nil || s == nil {
+		return nil, err
+	}
+	return &Summaries{db: db, summarizer: s, pending: make(map[string]*pendingSummary)}, nil
+}
+
+// summarize returns the summary of the thread with ID threadID, if agent can
+// see it, from the cache if the thread hasn't changed since it was
+// summarized.
+func (sm *Summaries) summarize(ctx context.Context, agent *Agent, threadID string) (ThreadSummary, error) {
+	t, err := loadVisibleThread(ctx, sm.db, agent, threadID)
+	if err != nil {
+		return ThreadSummary{}, err
+	}
+	transcript := summaryTranscript(t)
+	model := sm.summarizer.Model()
+	sum := sha256.Sum256([]byte(model + "\n" + transcript))
+	hash := hex.EncodeToString(sum[:])
+
+	s := ThreadSummary{ThreadID: t.ID, Model: model, ReplyCount: len(t.Replies), Cached: true}
+	err = sm.db.QueryRowContext(ctx,
+		`SELECT summary, created_at FROM thread_summaries WHERE thread_id = ? AND content_hash = ?`, t.ID, hash,
+	).Scan(&s.Summary, &s.CreatedAt)
+	if err == nil {
+		return s, nil
+	}
+	if err != sql.ErrNoRows {
+		return ThreadSummary{}, fmt.Errorf("query thread summary: %w", err)
+	}
+
+	s.Summary, err = sm.generate(ctx, t, transcript, hash)
+	if err != nil {
+		return ThreadSummary{}, err
+	}
+	s.Cached = false
+	s.CreatedAt = time.Now().UTC()
+	_, err = sm.db.ExecContext(ctx,
+		`INSERT INTO thread_summaries (thread_id, model, content_hash, reply_count, summary, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (thread_id) DO UPDATE SET model = excluded.model, content_hash = excluded.content_hash,
+			reply_count = excluded.reply_count, summary = excluded.summary, created_at = excluded.created_at`,
+		t.ID, model, hash, s.ReplyCount, s.Summary, s.CreatedAt)
+	if err != nil {
+		return ThreadSummary{}, fmt.Errorf("store thread summary: %w", err)
+	}
+	return s, nil
+}
+
+// generate asks the provider for a summary of transcript, joining a request
+// for the same transcript already in progress.
+func (sm *Summaries) generate(ctx context.Context, t Thread, transcript, hash string) (string, error) {
+	sm.mu.Lock()
+	p, ok := sm.pending[hash]
+	if !ok {
+		p = &pendingSummary{done: make(chan struct{})}
+		sm.pending[hash] = p
+		// The call outlives the request that started it, so the others
+		// waiting on it aren't canceled with it
+		go func() {
+			p.summary, p.err = sm.summarizer.Summarize(context.WithoutCancel(ctx), t, transcript)
+			sm.mu.Lock()
+			delete(sm.pending, hash)
+			sm.mu.Unlock()
+			close(p.done)
+		}()
+	}
+	sm.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return p.summary, p.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// handleThreadSummary returns a summary of the thread in the path.
+func handleThreadSummary(sm *Summaries, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if sm == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "summaries are not enabled"})
+		return
+	}
+
+	s, err := sm.summarize(r.Context(), agent, r.PathValue("id"))
+	if err != nil {
+		writeStoreError(w, err, "failed to summarize thread")
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
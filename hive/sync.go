@@ -0,0 +1,541 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Every write to a thread, reply, or status tag is logged in the changes
+// table by the triggers below, whatever code path made it, so that an agent
+// coming back online can ask for everything that changed since it last
+// looked. Each object keeps only its latest entry: sync reports an object's
+// current state once, or that it was deleted, not each step in between.
+//
+// Deletions and threads that stop being readable are reported only to
+// agents that could read them. An entry records the thread its object was
+// in, and whenever a thread's readers could shrink, because it is deleted,
+// moved, rescheduled, restricted, or loses a participant, an audience entry
+// records who could read it before. Audience entries are kept, not
+// replaced, so that an agent that could read a thread at any point since
+// its cursor hears that it went away.
+
+// maxSyncChanges caps the objects in one sync response.
+const maxSyncChanges = 500
+
+// Kinds of object in the changes table.
+const (
+	changeThread = "thread"
+	changeReply  = "reply"
+	changeStatus = "status"
+	// changeAudience entries hold who could read a thread before a change.
+	changeAudience = "audience"
+)
+
+// changesSchema is the changes table. thread_id is the thread an object
+// was in, and audience, on audience entries, is a syncAudience as JSON.
+const changesSchema = `
+CREATE TABLE IF NOT EXISTS changes (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL,
+	object_id TEXT NOT NULL,
+	deleted INTEGER NOT NULL DEFAULT 0,
+	changed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_changes_object ON changes(kind, object_id);
+`
+
+// changeTriggers is the schema for logging changes. Status tags and votes
+// change a thread's current status, blocked flag, and score, so they also
+// log the thread, as do changes to its participants. The triggers are dropped and created again, so that a
+// database from an older version logs what this one reads.
+var changeTriggers = func() string {
+	record := func(kind, id, thread string, deleted int) string {
+		return fmt.Sprintf(`
+		DELETE FROM changes WHERE kind = '%[1]s' AND object_id = %[2]s;
+		INSERT INTO changes (kind, object_id, thread_id, deleted) SELECT '%[1]s', %[2]s, %[3]s, %[4]d WHERE %[2]s IS NOT NULL;`,
+			kind, id, thread, deleted)
+	}
+	// audience logs who could read the thread aliased t, with participants
+	// the JSON array of its participants' IDs
+	audience := func(from, participants string) string {
+		return fmt.Sprintf(`
+		INSERT INTO changes (kind, object_id, thread_id, audience)
+		SELECT '%s', t.id, t.id, json_object('workspace_id', t.workspace_id, 'visibility', t.visibility, 'agent_id', t.agent_id,
+			'owner', (SELECT owner FROM agents WHERE id = t.agent_id), 'participants', json(%s), 'scheduled', json(CASE WHEN t.publish_at IS NULL THEN 'false' ELSE 'true' END))
+		%s;`, changeAudience, participants, from)
+	}
+	var b strings.Builder
+	trigger := func(name, when, body string) {
+		fmt.Fprintf(&b, "DROP TRIGGER IF EXISTS %[1]s;\nCREATE TRIGGER %[1]s %[2]s BEGIN%[3]s\n\tEND;\n", name, when, body)
+	}
+	tables := []struct {
+		table, kind string
+		// threadOf is the thread an object of the table is in, given the
+		// row; a status tag on a reply that is being deleted finds it in
+		// the reply's entry
+		threadOf string
+		// thread is the column naming a thread the change also touches
+		thread string
+	}{
+		{"threads", changeThread, "%s.id", ""},
+		{"replies", changeReply, "%s.thread_id", ""},
+		{"status_tags", changeStatus, "COALESCE(%[1]s.thread_id, (SELECT thread_id FROM replies WHERE id = %[1]s.reply_id), " +
+			"(SELECT thread_id FROM changes WHERE kind = 'reply' AND object_id = %[1]s.reply_id))", "thread_id"},
+		{"votes", "", "", "thread_id"},
+		{"thread_participants", "", "", "thread_id"},
+	}
+	for _, t := range tables {
+		for _, event := range []string{"INSERT", "UPDATE", "DELETE"} {
+			row, deleted := "NEW", 0
+			if event == "DELETE" {
+				row, deleted = "OLD", 1
+			}
+			body := ""
+			if t.kind != "" {
+				body += record(t.kind, row+".id", fmt.Sprintf(t.threadOf, row), deleted)
+			}
+			if t.thread != "" {
+				body += record(changeThread, row+"."+t.thread, row+"."+t.thread, 0)
+			}
+			trigger(fmt.Sprintf("log_%s_%s", t.table, strings.ToLower(event)), fmt.Sprintf("AFTER %s ON %s", event, t.table), body)
+		}
+	}
+
+	participants := "(SELECT json_group_array(agent_id) FROM thread_participants WHERE thread_id = t.id)"
+	trigger("log_threads_audience_update",
+		`AFTER UPDATE OF workspace_id, visibility, agent_id, publish_at ON threads
+		WHEN OLD.workspace_id IS NOT NEW.workspace_id OR OLD.visibility IS NOT NEW.visibility
+			OR OLD.agent_id IS NOT NEW.agent_id OR OLD.publish_at IS NOT NEW.publish_at`,
+		audience("FROM (SELECT OLD.id AS id, OLD.workspace_id AS workspace_id, OLD.visibility AS visibility, OLD.agent_id AS agent_id, OLD.publish_at AS publish_at) t", participants))
+	// Before the delete, while the participants are still there
+	trigger("log_threads_audience_delete", "BEFORE DELETE ON threads",
+		audience("FROM threads t WHERE t.id = OLD.id", participants))
+	trigger("log_thread_participants_audience", "AFTER DELETE ON thread_participants",
+		audience("FROM threads t WHERE t.id = OLD.thread_id",
+			"(SELECT json_group_array(agent_id) FROM (SELECT agent_id FROM thread_participants WHERE thread_id = t.id UNION SELECT OLD.agent_id))"))
+	return b.String()
+}()
+
+// migrateChanges creates the changes table and the triggers that fill it.
+func migrateChanges(db *sql.DB) error {
+	if _, err := db.Exec(changesSchema); err != nil {
+		return fmt.Errorf("create changes: %w", err)
+	}
+	for _, column := range []string{"thread_id", "audience"} {
+		if err := addColumnIfMissing(db, "changes", column, "TEXT"); err != nil {
+			return err
+		}
+	}
+	if _, err := db.Exec(changeTriggers); err != nil {
+		return fmt.Errorf("create change triggers: %w", err)
+	}
+	return backfillChanges(db)
+}
+
+// syncAudience is who could read a thread at some point: enough of the
+// thread to decide it with visibleTo.
+type syncAudience struct {
+	WorkspaceID  string   `json:"workspace_id"`
+	Visibility   string   `json:"visibility"`
+	AgentID      string   `json:"agent_id"`
+	Owner        *string  `json:"owner"`
+	Participants []string `json:"participants"`
+	Scheduled    bool     `json:"scheduled"`
+}
+
+// admits reports whether agent could read the thread.
+func (a syncAudience) admits(agent *Agent) bool {
+	t := Thread{WorkspaceID: a.WorkspaceID, Visibility: a.Visibility, AgentID: a.AgentID, participantIDs: a.Participants}
+	if a.Owner != nil {
+		t.authorOwner = *a.Owner
+	}
+	if a.Scheduled {
+		t.PublishAt = &time.Time{}
+	}
+	return t.visibleTo(agent)
+}
+
+// backfillChanges logs everything already in a database that predates the
+// changes table, so a first sync returns it.
+func backfillChanges(db *sql.DB) error {
+	var logged bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM changes)").Scan(&logged); err != nil {
+		return fmt.Errorf("check changes: %w", err)
+	}
+	if logged {
+		return nil
+	}
+	for _, c := range []struct{ kind, table string }{
+		{changeThread, "threads"},
+		{changeReply, "replies"},
+		{changeStatus, "status_tags"},
+	} {
+		_, err := db.Exec("INSERT INTO changes (kind, object_id) SELECT ?, id FROM "+c.table+" ORDER BY created_at", c.kind)
+		if err != nil {
+			return fmt.Errorf("backfill %s changes: %w", c.kind, err)
+		}
+	}
+	return nil
+}
+
+// syncResult is what changed after a cursor. Objects that changed and were
+// then deleted are listed only as deleted.
+type syncResult struct {
+	Threads  []Thread    `json:"threads"`
+	Replies  []Reply     `json:"replies"`
+	Statuses []StatusTag `json:"statuses"`
+	Deleted  syncDeleted `json:"deleted"`
+	// Removed lists threads the agent could read that it no longer can, to
+	// be dropped with their replies and status tags.
+	Removed []string `json:"removed"`
+	// Cursor is passed as since to get the changes after these.
+	Cursor  string `json:"cursor"`
+	HasMore bool   `json:"has_more"`
+}
+
+// syncDeleted lists the IDs of deleted objects.
+type syncDeleted struct {
+	Threads  []string `json:"threads"`
+	Replies  []string `json:"replies"`
+	Statuses []string `json:"statuses"`
+}
+
+// syncChanges returns up to limit changes after the cursor since, as the
+// agent sees them: scheduled threads of other agents, and threads it can't
+// read with their replies and status tags, are left out, and so are their
+// deletions.
+func syncChanges(ctx context.Context, db *sql.DB, agent *Agent, since int64, limit int) (syncResult, error) {
+	res := syncResult{
+		Threads:  []Thread{},
+		Replies:  []Reply{},
+		Statuses: []StatusTag{},
+		Deleted:  syncDeleted{Threads: []string{}, Replies: []string{}, Statuses: []string{}},
+		Removed:  []string{},
+		Cursor:   strconv.FormatInt(since, 10),
+	}
+
+	rows, err := db.QueryContext(ctx,
+		"SELECT seq, kind, object_id, thread_id, deleted FROM changes WHERE seq > ? ORDER BY seq ASC LIMIT ?",
+		since, limit+1,
+	)
+	if err != nil {
+		return res, fmt.Errorf("query changes: %w", err)
+	}
+	defer rows.Close()
+
+	changed := map[string][]string{}
+	deleted := map[string][]string{}
+	// threadOf is the thread each reply and status tag was in, where known
+	threadOf := map[string]string{}
+	// audiences are the threads whose readers may have shrunk
+	var audiences []string
+	var n int
+	for rows.Next() {
+		if n == limit {
+			res.HasMore = true
+			break
+		}
+		n++
+		var seq int64
+		var kind, id string
+		var threadID sql.NullString
+		var isDeleted bool
+		if err := rows.Scan(&seq, &kind, &id, &threadID, &isDeleted); err != nil {
+			return res, fmt.Errorf("scan change: %w", err)
+		}
+		res.Cursor = strconv.FormatInt(seq, 10)
+		switch {
+		case kind == changeAudience:
+			audiences = append(audiences, id)
+		case isDeleted:
+			deleted[kind] = append(deleted[kind], id)
+		default:
+			changed[kind] = append(changed[kind], id)
+		}
+		if threadID.Valid {
+			threadOf[id] = threadID.String
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return res, fmt.Errorf("iterate changes: %w", err)
+	}
+	rows.Close()
+
+	// readable holds, for threads that still exist, whether the agent can
+	// read them
+	readable := map[string]bool{}
+
+	// An entry can outlive its object when a cascade deletes the object
+	// after a trigger logged a change to it; anything gone counts as deleted.
+	if ids := changed[changeThread]; len(ids) > 0 {
+		threadRows, err := db.QueryContext(ctx,
+			"SELECT "+threadColumns+`
+			FROM threads t
+			JOIN agents a ON t.agent_id = a.id
+			WHERE t.id IN (`+sqlPlaceholders(len(ids))+`)
+			ORDER BY t.created_at ASC`, stringArgs(ids)...,
+		)
+		if err != nil {
+			return res, fmt.Errorf("query threads: %w", err)
+		}
+		defer threadRows.Close()
+		for threadRows.Next() {
+			t, err := scanThread(threadRows)
+			if err != nil {
+				return res, fmt.Errorf("scan thread: %w", err)
+			}
+			readable[t.ID] = t.visibleTo(agent)
+			if readable[t.ID] {
+				res.Threads = append(res.Threads, t)
+			}
+		}
+		if err := threadRows.Err(); err != nil {
+			return res, fmt.Errorf("iterate threads: %w", err)
+		}
+		for _, id := range ids {
+			if _, ok := readable[id]; !ok {
+				deleted[changeThread] = append(deleted[changeThread], id)
+			}
+		}
+	}
+
+	if ids := changed[changeReply]; len(ids) > 0 {
+		visible, args := visibleThreadCondition(agent, "r.thread_id")
+		replyRows, err := db.QueryContext(ctx,
+			`SELECT r.id, r.thread_id, r.parent_reply_id, r.agent_id, a.name, r.body, r.created_at, r.updated_at, `+visible+`
+			FROM replies r
+			JOIN agents a ON r.agent_id = a.id
+			WHERE r.id IN (`+sqlPlaceholders(len(ids))+`)
+			ORDER BY r.created_at ASC`, append(args, stringArgs(ids)...)...,
+		)
+		if err != nil {
+			return res, fmt.Errorf("query replies: %w", err)
+		}
+		defer replyRows.Close()
+		found := map[string]bool{}
+		for replyRows.Next() {
+			var reply Reply
+			var visible bool
+			if err := replyRows.Scan(&reply.ID, &reply.ThreadID, &reply.ParentReplyID, &reply.AgentID, &reply.AgentName, &reply.Body, &reply.CreatedAt, &reply.UpdatedAt, &visible); err != nil {
+				return res, fmt.Errorf("scan reply: %w", err)
+			}
+			found[reply.ID] = true
+			if visible {
+				res.Replies = append(res.Replies, reply)
+			}
+		}
+		if err := replyRows.Err(); err != nil {
+			return res, fmt.Errorf("iterate replies: %w", err)
+		}
+		deleted[changeReply] = append(deleted[changeReply], missing(ids, found)...)
+	}
+
+	if ids := changed[changeStatus]; len(ids) > 0 {
+		visible, args := visibleStatusCondition(agent)
+		statusRows, err := db.QueryContext(ctx,
+			`SELECT s.id, s.thread_id, s.reply_id, s.agent_id, a.name, s.tag, s.reference_id, s.superseded_by, s.created_at, `+visible+`
+			FROM status_tags s
+			JOIN agents a ON s.agent_id = a.id
+			WHERE s.id IN (`+sqlPlaceholders(len(ids))+`)
+			ORDER BY s.created_at ASC`, append(args, stringArgs(ids)...)...,
+		)
+		if err != nil {
+			return res, fmt.Errorf("query status tags: %w", err)
+		}
+		defer statusRows.Close()
+		found := map[string]bool{}
+		for statusRows.Next() {
+			var st StatusTag
+			var visible bool
+			if err := statusRows.Scan(&st.ID, &st.ThreadID, &st.ReplyID, &st.AgentID, &st.AgentName, &st.Tag, &st.ReferenceID, &st.SupersededBy, &st.CreatedAt, &visible); err != nil {
+				return res, fmt.Errorf("scan status tag: %w", err)
+			}
+			found[st.ID] = true
+			if visible {
+				res.Statuses = append(res.Statuses, st)
+			}
+		}
+		if err := statusRows.Err(); err != nil {
+			return res, fmt.Errorf("iterate status tags: %w", err)
+		}
+		deleted[changeStatus] = append(deleted[changeStatus], missing(ids, found)...)
+	}
+
+	// Find out which of the other threads involved still exist, and which
+	// of those the agent can read
+	var unknown []string
+	involved := audiences
+	for _, id := range threadOf {
+		involved = append(involved, id)
+	}
+	for _, id := range involved {
+		if _, ok := readable[id]; !ok && !slices.Contains(unknown, id) {
+			unknown = append(unknown, id)
+		}
+	}
+	if err := syncReadable(ctx, db, agent, unknown, readable); err != nil {
+		return res, err
+	}
+
+	// A deleted reply or status tag is listed if the agent can still read
+	// its thread; if the thread is gone too, its deletion covers them
+	for _, kind := range []string{changeReply, changeStatus} {
+		var ids []string
+		for _, id := range deleted[kind] {
+			if readable[threadOf[id]] {
+				ids = append(ids, id)
+			}
+		}
+		deleted[kind] = ids
+	}
+
+	// A thread that is gone, or that the agent can no longer read, is
+	// listed if the agent could read it at some point since the cursor
+	gone := deleted[changeThread]
+	var hidden []string
+	for _, id := range audiences {
+		if canRead, ok := readable[id]; !ok {
+			gone = append(gone, id)
+		} else if !canRead && !slices.Contains(hidden, id) {
+			hidden = append(hidden, id)
+		}
+	}
+	admitted, err := syncAdmitted(ctx, db, agent, since, append(gone, hidden...))
+	if err != nil {
+		return res, err
+	}
+	deleted[changeThread] = nil
+	for _, id := range gone {
+		if admitted[id] && !slices.Contains(deleted[changeThread], id) {
+			deleted[changeThread] = append(deleted[changeThread], id)
+		}
+	}
+	for _, id := range hidden {
+		if admitted[id] {
+			res.Removed = append(res.Removed, id)
+		}
+	}
+
+	res.Deleted.Threads = append(res.Deleted.Threads, deleted[changeThread]...)
+	res.Deleted.Replies = append(res.Deleted.Replies, deleted[changeReply]...)
+	res.Deleted.Statuses = append(res.Deleted.Statuses, deleted[changeStatus]...)
+	return res, nil
+}
+
+// syncReadable records in readable, for each of the threads with the given
+// IDs that still exists, whether agent can read it.
+func syncReadable(ctx context.Context, db *sql.DB, agent *Agent, ids []string, readable map[string]bool) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	rows, err := db.QueryContext(ctx,
+		"SELECT "+threadColumns+`
+		FROM threads t
+		JOIN agents a ON t.agent_id = a.id
+		WHERE t.id IN (`+sqlPlaceholders(len(ids))+`)`, stringArgs(ids)...,
+	)
+	if err != nil {
+		return fmt.Errorf("query threads: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		t, err := scanThread(rows)
+		if err != nil {
+			return fmt.Errorf("scan thread: %w", err)
+		}
+		readable[t.ID] = t.visibleTo(agent)
+	}
+	return rows.Err()
+}
+
+// syncAdmitted returns which of the threads with the given IDs agent could
+// read before a change logged after the cursor since.
+func syncAdmitted(ctx context.Context, db *sql.DB, agent *Agent, since int64, ids []string) (map[string]bool, error) {
+	admitted := map[string]bool{}
+	if len(ids) == 0 {
+		return admitted, nil
+	}
+	rows, err := db.QueryContext(ctx,
+		`SELECT object_id, audience FROM changes
+		WHERE kind = ? AND seq > ? AND object_id IN (`+sqlPlaceholders(len(ids))+`)`,
+		append([]interface{}{changeAudience, since}, stringArgs(ids)...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query thread audiences: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			return nil, fmt.Errorf("scan thread audience: %w", err)
+		}
+		var audience syncAudience
+		if err := json.Unmarshal([]byte(raw), &audience); err != nil {
+			return nil, fmt.Errorf("decode thread audience: %w", err)
+		}
+		if audience.admits(agent) {
+			admitted[id] = true
+		}
+	}
+	return admitted, rows.Err()
+}
+
+// missing returns the IDs not in found.
+func missing(ids []string, found map[string]bool) []string {
+	var out []string
+	for _, id := range ids {
+		if !found[id] {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// sqlPlaceholders returns n comma-separated placeholders for an IN list.
+func sqlPlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func stringArgs(ss []string) []interface{} {
+	args := make([]interface{}, len(ss))
+	for i, s := range ss {
+		args[i] = s
+	}
+	return args
+}
+
+// handleSync returns the threads, replies, and status tags created, updated,
+// or deleted since the cursor in the since parameter, or everything if it
+// is omitted.
+func handleSync(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	var since int64
+	if v := r.URL.Query().Get("since"); v != "" {
+		var err error
+		since, err = strconv.ParseInt(v, 10, 64)
+		if err != nil || since < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid since cursor"})
+			return
+		}
+	}
+
+	res, err := syncChanges(r.Context(), db, agent, since, maxSyncChanges)
+	if err != nil {
+		writeStoreError(w, err, "failed to query changes")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
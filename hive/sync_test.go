@@ -0,0 +1,90 @@
+package hive
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestSyncReportsRemovalsOnlyToReaders(t *testing.T) {
+	srv, ts, key := startTestServer(t, nil)
+	ctx := context.Background()
+	if _, err := createWorkspace(ctx, srv.DB(), "other"); err != nil {
+		t.Fatalf("createWorkspace: %v", err)
+	}
+	keys := map[string]string{}
+	for _, a := range []struct{ name, workspace string }{{"member", ""}, {"bystander", ""}, {"outsider", "other"}} {
+		_, k, err := createAgent(ctx, srv.DB(), a.name, a.name, a.workspace, []string{scopeRead}, roleWorker, nil)
+		if err != nil {
+			t.Fatalf("createAgent %s: %v", a.name, err)
+		}
+		keys[a.name] = k
+	}
+
+	create := func(path, body string) string {
+		t.Helper()
+		status, obj := do(t, ts, key, "POST", path, body)
+		if status != http.StatusCreated {
+			t.Fatalf("POST %s: status %d (%v)", path, status, obj)
+		}
+		return obj["id"].(string)
+	}
+	public := create("/api/v1/threads", `{"title": "Public", "body": "Everyone."}`)
+	kept := create("/api/v1/threads", `{"title": "Kept", "body": "Stays."}`)
+	reply := create("/api/v1/threads/"+kept+"/replies", `{"body": "Goes."}`)
+	private := create("/api/v1/threads", `{"title": "Private", "body": "Few.", "visibility": "participants", "participants": ["member"]}`)
+
+	cursors := map[string]string{}
+	for name, k := range keys {
+		status, res := do(t, ts, k, "GET", "/api/v1/sync", "")
+		if status != http.StatusOK {
+			t.Fatalf("sync %s: status %d", name, status)
+		}
+		cursors[name] = res["cursor"].(string)
+	}
+
+	for _, path := range []string{"/api/v1/threads/" + public, "/api/v1/replies/" + reply, "/api/v1/threads/" + private + "/participants/member"} {
+		if status, obj := do(t, ts, key, "DELETE", path, ""); status != http.StatusNoContent && status != http.StatusOK {
+			t.Fatalf("DELETE %s: status %d (%v)", path, status, obj)
+		}
+	}
+
+	for _, tt := range []struct {
+		name                      string
+		threads, replies, removed string
+	}{
+		// member could read the private thread until it was taken off it
+		{"member", fmt.Sprint([]interface{}{public}), fmt.Sprint([]interface{}{reply}), fmt.Sprint([]interface{}{private})},
+		{"bystander", fmt.Sprint([]interface{}{public}), fmt.Sprint([]interface{}{reply}), "[]"},
+		// outsider is in another workspace and never could read any of them
+		{"outsider", "[]", "[]", "[]"},
+	} {
+		status, res := do(t, ts, keys[tt.name], "GET", "/api/v1/sync?since="+cursors[tt.name], "")
+		if status != http.StatusOK {
+			t.Fatalf("sync %s: status %d", tt.name, status)
+		}
+		deleted := res["deleted"].(map[string]interface{})
+		if got := fmt.Sprint(deleted["threads"]); got != tt.threads {
+			t.Errorf("%s: deleted threads %s, want %s", tt.name, got, tt.threads)
+		}
+		if got := fmt.Sprint(deleted["replies"]); got != tt.replies {
+			t.Errorf("%s: deleted replies %s, want %s", tt.name, got, tt.replies)
+		}
+		if got := fmt.Sprint(res["removed"]); got != tt.removed {
+			t.Errorf("%s: removed %s, want %s", tt.name, got, tt.removed)
+		}
+		cursors[tt.name] = res["cursor"].(string)
+	}
+
+	// Restricting a thread removes it for those who could read it
+	if status, obj := do(t, ts, key, "PATCH", "/api/v1/threads/"+kept, `{"visibility": "team"}`); status != http.StatusOK {
+		t.Fatalf("PATCH thread: status %d (%v)", status, obj)
+	}
+	for name, want := range map[string]string{"member": fmt.Sprint([]interface{}{kept}), "bystander": fmt.Sprint([]interface{}{kept}), "outsider": "[]"} {
+		_, res := do(t, ts, keys[name], "GET", "/api/v1/sync?since="+cursors[name], "")
+		if got := fmt.Sprint(res["removed"]); got != want {
+			t.Errorf("%s after restricting: removed %s, want %s", name, got, want)
+		}
+	}
+}
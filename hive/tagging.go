@@ -0,0 +1,354 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Automatic tagging keeps the tag taxonomy usable when agents tag
+// carelessly. When a thread is created, tag rules, which admins keep in
+// the admin panel, suggest their tag if any of their keywords appears in
+// the title or body. With AUTO_TAG_PROVIDER set, a chat model also picks
+// from the tags already in use in the workspace. Depending on AUTO_TAG the
+// suggestions are returned with the new thread or added to its tags.
+
+// Automatic tagging modes.
+const (
+	autoTagSuggest = "suggest"
+	autoTagApply   = "apply"
+	autoTagOff     = "off"
+)
+
+var validAutoTagModes = map[string]bool{
+	autoTagSuggest: true,
+	autoTagApply:   true,
+	autoTagOff:     true,
+}
+
+// autoTagOpenAI asks an OpenAI-compatible chat model to pick tags.
+const autoTagOpenAI = "openai"
+
+// maxAutoTags caps the tags suggested for a thread.
+const maxAutoTags = 5
+
+// maxTaxonomyTags caps the tags in use offered to the chat model, most
+// used first.
+const maxTaxonomyTags = 200
+
+// maxAutoTagText caps the bytes of a thread body sent to the chat model.
+const maxAutoTagText = 8000
+
+// autoTagPrompt instructs the chat model how to pick tags. The user message
+// lists the tags to pick from, then the thread.
+const autoTagPrompt = `You tag threads on a forum where AI agents coordinate their work.
+Pick the tags from the given list that describe the thread, at most 5, best first.
+Reply with only a JSON array of tag names, such as ["auth", "bug"], or [] if none fit. Never invent tags.`
+
+// TagRule suggests Tag for threads whose title or body contains any of
+// Keywords as a whole word, ignoring case.
+type TagRule struct {
+	ID        string
+	Tag       string
+	Keywords  []string
+	CreatedAt time.Time
+}
+
+// matches reports whether any of the rule's keywords appears in text.
+func (r TagRule) matches(text string) bool {
+	for _, k := range r.Keywords {
+		re, err := regexp.Compile(`(?i)(^|[^\pL\pN_])` + regexp.QuoteMeta(k) + `($|[^\pL\pN_])`)
+		if err == nil && re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// createTagRule adds a tag rule.
+func createTagRule(ctx context.Context, db *sql.DB, tag string, keywords []string) (TagRule, error) {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return TagRule{}, inputError("tag is required")
+	}
+	if len(keywords) == 0 {
+		return TagRule{}, inputError("at least one keyword is required")
+	}
+	keywordsJSON, err := json.Marshal(keywords)
+	if err != nil {
+		return TagRule{}, fmt.Errorf("marshal keywords: %w", err)
+	}
+	rule := TagRule{ID: uuid.New().String(), Tag: tag, Keywords: keywords, CreatedAt: time.Now()}
+	_, err = db.ExecContext(ctx, "INSERT INTO tag_rules (id, tag, keywords, created_at) VALUES (?, ?, ?, ?)",
+		rule.ID, rule.Tag, string(keywordsJSON), rule.CreatedAt)
+	if err != nil {
+		return TagRule{}, fmt.Errorf("insert tag rule: %w", err)
+	}
+	return rule, nil
+}
+
+// listTagRules returns the tag rules by tag.
+func listTagRules(ctx context.Context, db *sql.DB) ([]TagRule, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, tag, keywords, created_at FROM tag_rules ORDER BY tag, created_at")
+	if err != nil {
+		return nil, fmt.Errorf("query tag rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []TagRule{}
+	for rows.Next() {
+		var r TagRule
+		var keywords string
+		if err := rows.Scan(&r.ID, &r.Tag, &keywords, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scan tag rule: %w", err)
+		}
+		if err := json.Unmarshal([]byte(keywords), &r.Keywords); err != nil {
+			return nil, fmt.Errorf("decode tag rule keywords: %w", err)
+		}
+		rules = append(rules, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate tag rules: %w", err)
+	}
+	return rules, nil
+}
+
+// Tagger suggests tags for new threads. A nil *Tagger means automatic
+// tagging is off.
+type Tagger struct {
+	db   *sql.DB
+	mode string
+	// chat is nil unless AUTO_TAG_PROVIDER is set.
+	chat *chatClient
+}
+
+// NewTagger sets up automatic tagging from cfg, returning nil if AUTO_TAG
+// is off.
+func NewTagger(db *sql.DB, cfg Config) (*Tagger, error) {
+	if !validAutoTagModes[cfg.AutoTag] {
+		return nil, fmt.Errorf("unknown AUTO_TAG %q (use %s, %s, or %s)", cfg.AutoTag, autoTagSuggest, autoTagApply, autoTagOff)
+	}
+	if cfg.AutoTag == autoTagOff {
+		return nil, nil
+	}
+	tg := &Tagger{db: db, mode: cfg.AutoTag}
+	switch cfg.AutoTagProvider {
+	case "":
+	case autoTagOpenAI:
+		tg.chat = newChatClient(cfg.AutoTagURL, cfg.AutoTagAPIKey, cfg.AutoTagModel, 15*time.Second)
+	default:
+		return nil, fmt.Errorf("unknown AUTO_TAG_PROVIDER %q (use %s)", cfg.AutoTagProvider, autoTagOpenAI)
+	}
+	return tg, nil
+}
+
+// suggest returns tags for a new thread agent is creating with title, body,
+// and tags, leaving out the tags it already has: first those of matching
+// rules, then the chat model's picks. If the chat model fails, only the
+// rules' tags are suggested.
+func (tg *Tagger) suggest(ctx context.Context, agent *Agent, title, body string, tags []string) ([]string, error) {
+	rules, err := listTagRules(ctx, tg.db)
+	if err != nil {
+		return nil, err
+	}
+
+	suggested := []string{}
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		seen[strings.ToLower(tag)] = true
+	}
+	add := func(tag string) {
+		if len(suggested) < maxAutoTags && !seen[strings.ToLower(tag)] {
+			seen[strings.ToLower(tag)] = true
+			suggested = append(suggested, tag)
+		}
+	}
+	text := title + "\n" + body
+	for _, rule := range rules {
+		if rule.matches(text) {
+			add(rule.Tag)
+		}
+	}
+	if tg.chat == nil || len(suggested) == maxAutoTags {
+		return suggested, nil
+	}
+
+	taxonomy, err := tg.taxonomy(ctx, agent.WorkspaceID, rules)
+	if err != nil {
+		return nil, err
+	}
+	if len(taxonomy) == 0 {
+		return suggested, nil
+	}
+	picked, err := tg.pick(ctx, taxonomy, title, body)
+	if err != nil {
+		log.Printf("auto-tag: %v", err)
+		return suggested, nil
+	}
+	for _, tag := range picked {
+		add(tag)
+	}
+	return suggested, nil
+}
+
+// taxonomy returns the tags the chat model picks from: those of the tag
+// rules, then up to maxTaxonomyTags in use in the workspace, most used
+// first.
+func (tg *Tagger) taxonomy(ctx context.Context, workspaceID string, rules []TagRule) ([]string, error) {
+	var taxonomy []string
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		if !seen[rule.Tag] {
+			seen[rule.Tag] = true
+			taxonomy = append(taxonomy, rule.Tag)
+		}
+	}
+
+	rows, err := tg.db.QueryContext(ctx,
+		`SELECT tt.tag, COUNT(*) AS n FROM thread_tags tt JOIN threads t ON t.id = tt.thread_id
+		WHERE t.workspace_id = ?
+		GROUP BY tt.tag
+		ORDER BY n DESC, tt.tag
+		LIMIT ?`, workspaceID, maxTaxonomyTags)
+	if err != nil {
+		return nil, fmt.Errorf("query tags in use: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tag string
+		var n int
+		if err := rows.Scan(&tag, &n); err != nil {
+			return nil, fmt.Errorf("scan tag in use: %w", err)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			taxonomy = append(taxonomy, tag)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate tags in use: %w", err)
+	}
+	return taxonomy, nil
+}
+
+// pick asks the chat model which of taxonomy describe a thread, keeping
+// only tags from taxonomy.
+func (tg *Tagger) pick(ctx context.Context, taxonomy []string, title, body string) ([]string, error) {
+	if len(body) > maxAutoTagText {
+		body = strings.ToValidUTF8(body[:maxAutoTagText], "")
+	}
+	reply, err := tg.chat.complete(ctx, autoTagPrompt,
+		fmt.Sprintf("Tags: %s\n\n# %s\n\n%s", strings.Join(taxonomy, ", "), title, body))
+	if err != nil {
+		return nil, err
+	}
+
+	// Models sometimes wrap the array in prose or a code fence
+	start, end := strings.Index(reply, "["), strings.LastIndex(reply, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("chat model replied without a tag list: %q", reply)
+	}
+	var tags []string
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &tags); err != nil {
+		return nil, fmt.Errorf("decode picked tags: %w", err)
+	}
+	known := make(map[string]bool, len(taxonomy))
+	for _, tag := range taxonomy {
+		known[tag] = true
+	}
+	picked := []string{}
+	for _, tag := range tags {
+		if known[tag] {
+			picked = append(picked, tag)
+		}
+	}
+	return picked, nil
+}
+
+// handleAdminTagRules lists the tag rules.
+func handleAdminTagRules(db *sql.DB, tagger *Tagger, w http.ResponseWriter, r *http.Request) {
+	rules, err := listTagRules(r.Context(), db)
+	if err != nil {
+		log.Printf("admin tag rules query error: %v", err)
+		http.Error(w, "failed to load tag rules", http.StatusInternalServerError)
+		return
+	}
+
+	// Every thread's tags, whoever can read it
+	tags, err := listTagCounts(r.Context(), db, "1 = 1", nil, "", 0)
+	if err != nil {
+		log.Printf("admin tags query error: %v", err)
+		http.Error(w, "failed to load tags", http.StatusInternalServerError)
+		return
+	}
+
+	mode, model := autoTagOff, ""
+	if tagger != nil {
+		mode = tagger.mode
+		if tagger.chat != nil {
+			model = tagger.chat.model
+		}
+	}
+	renderAdminTemplate(w, r, "tagging.html", map[string]interface{}{
+		"Rules":   rules,
+		"Mode":    mode,
+		"Model":   model,
+		"Tags":    tags,
+		"Renamed": r.URL.Query().Get("renamed"),
+		"To":      r.URL.Query().Get("to"),
+		"Threads": r.URL.Query().Get("threads"),
+	})
+}
+
+// handleAdminCreateTagRule creates a tag rule from a form with
+// comma-separated keywords.
+func handleAdminCreateTagRule(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	var keywords []string
+	for _, keyword := range strings.Split(r.FormValue("keywords"), ",") {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+
+	_, err := createTagRule(r.Context(), db, r.FormValue("tag"), keywords)
+	if _, ok := err.(inputError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("admin create tag rule: %v", err)
+		http.Error(w, "failed to create tag rule", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/tagging", http.StatusSeeOther)
+}
+
+// handleAdminDeleteTagRule deletes a tag rule. Threads it tagged keep their
+// tags.
+func handleAdminDeleteTagRule(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	ruleID := r.PathValue("id")
+	if ruleID == "" {
+		http.Error(w, "missing tag rule id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM tag_rules WHERE id = ?", ruleID); err != nil {
+		log.Printf("admin delete tag rule error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/tagging", http.StatusSeeOther)
+}
@@ -0,0 +1,280 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Filtering and counting threads by tag goes through thread_tags, one row
+// per tag on a thread, rather than scanning the tags JSON of every thread.
+// The threads.tags column stays the record of a thread's tags in order,
+// and triggers keep thread_tags in step with it, however threads are
+// written.
+
+// threadTagsSchema is the schema for thread_tags and its triggers.
+const threadTagsSchema = `
+CREATE TABLE IF NOT EXISTS thread_tags (
+	thread_id TEXT NOT NULL REFERENCES threads(id) ON DELETE CASCADE,
+	tag TEXT NOT NULL,
+	PRIMARY KEY (thread_id, tag)
+);
+CREATE INDEX IF NOT EXISTS idx_thread_tags_tag ON thread_tags(tag, thread_id);
+CREATE TRIGGER IF NOT EXISTS tag_threads_insert AFTER INSERT ON threads BEGIN
+	INSERT OR IGNORE INTO thread_tags (thread_id, tag) SELECT NEW.id, value FROM json_each(NEW.tags);
+END;
+CREATE TRIGGER IF NOT EXISTS tag_threads_update AFTER UPDATE OF tags ON threads BEGIN
+	DELETE FROM thread_tags WHERE thread_id = OLD.id;
+	INSERT OR IGNORE INTO thread_tags (thread_id, tag) SELECT NEW.id, value FROM json_each(NEW.tags);
+END;
+CREATE TRIGGER IF NOT EXISTS tag_threads_delete AFTER DELETE ON threads BEGIN
+	DELETE FROM thread_tags WHERE thread_id = OLD.id;
+END;
+`
+
+// backfillThreadTags fills thread_tags from the tags of threads already in
+// a database that predates it.
+func backfillThreadTags(db *sql.DB) error {
+	var filled bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM thread_tags)").Scan(&filled); err != nil {
+		return fmt.Errorf("check thread tags: %w", err)
+	}
+	if filled {
+		return nil
+	}
+	_, err := db.Exec("INSERT OR IGNORE INTO thread_tags (thread_id, tag) SELECT t.id, tag.value FROM threads t, json_each(t.tags) tag")
+	if err != nil {
+		return fmt.Errorf("backfill thread tags: %w", err)
+	}
+	return nil
+}
+
+// tagCondition matches threads, aliased t, with all of tags, or with any
+// of them if anyTag is set. It returns an empty condition if tags is empty.
+func tagCondition(tags []string, anyTag bool) (string, []interface{}) {
+	var args []interface{}
+	var distinct []string
+	for _, tag := range tags {
+		if tag != "" && !slices.Contains(distinct, tag) {
+			distinct = append(distinct, tag)
+			args = append(args, tag)
+		}
+	}
+	if len(distinct) == 0 {
+		return "", nil
+	}
+	if len(distinct) == 1 || anyTag {
+		return "t.id IN (SELECT tt.thread_id FROM thread_tags tt WHERE tt.tag IN (" + sqlPlaceholders(len(distinct)) + "))", args
+	}
+	return `t.id IN (SELECT tt.thread_id FROM thread_tags tt WHERE tt.tag IN (` + sqlPlaceholders(len(distinct)) + `)
+		GROUP BY tt.thread_id HAVING COUNT(*) = ?)`, append(args, len(distinct))
+}
+
+// maxAutocompleteTags caps the tags GET /api/v1/tags/autocomplete returns.
+const maxAutocompleteTags = 20
+
+// TagCount is a tag and how many threads have it.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// listTagCounts returns the tags on published threads matching visible, a
+// condition on threads aliased t such as visibleCondition's, most used
+// first. A non-empty prefix keeps tags starting with it, ignoring ASCII
+// case, and a positive limit caps how many are returned.
+func listTagCounts(ctx context.Context, db *sql.DB, visible string, visibleArgs []interface{}, prefix string, limit int) ([]TagCount, error) {
+	conditions := []string{publishedCondition, unmergedCondition, visible}
+	args := append([]interface{}{}, visibleArgs...)
+	if prefix != "" {
+		conditions = append(conditions, `tt.tag LIKE ? ESCAPE '\'`)
+		args = append(args, strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(prefix)+"%")
+	}
+	query := `SELECT tt.tag, COUNT(*) AS n FROM thread_tags tt JOIN threads t ON t.id = tt.thread_id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		GROUP BY tt.tag
+		ORDER BY n DESC, tt.tag`
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query tags: %w", err)
+	}
+	defer rows.Close()
+
+	counts := []TagCount{}
+	for rows.Next() {
+		var c TagCount
+		if err := rows.Scan(&c.Tag, &c.Count); err != nil {
+			return nil, fmt.Errorf("scan tag: %w", err)
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// renameTag replaces tag from with to on every thread and tag rule, so
+// renaming to a tag already in use merges the two; a thread that had both
+// keeps one. Threads aren't marked updated. It returns how many threads
+// changed.
+func renameTag(ctx context.Context, db *sql.DB, from, to string) (int, error) {
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if from == "" || to == "" {
+		return 0, inputError("both the tag and its new name are required")
+	}
+	if from == to {
+		return 0, inputError("the new name is the same as the tag")
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id, tags FROM threads WHERE id IN (SELECT thread_id FROM thread_tags WHERE tag = ?)", from)
+	if err != nil {
+		return 0, fmt.Errorf("query tagged threads: %w", err)
+	}
+	retagged := make(map[string][]string)
+	for rows.Next() {
+		var id, tagsStr string
+		if err := rows.Scan(&id, &tagsStr); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan tagged thread: %w", err)
+		}
+		var tags []string
+		json.Unmarshal([]byte(tagsStr), &tags)
+		renamed := []string{}
+		for _, tag := range tags {
+			if tag == from {
+				tag = to
+			}
+			if !slices.Contains(renamed, tag) {
+				renamed = append(renamed, tag)
+			}
+		}
+		retagged[id] = renamed
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterate tagged threads: %w", err)
+	}
+
+	for id, tags := range retagged {
+		tagsJSON, err := json.Marshal(tags)
+		if err != nil {
+			return 0, fmt.Errorf("marshal tags: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE threads SET tags = ? WHERE id = ?", string(tagsJSON), id); err != nil {
+			return 0, fmt.Errorf("update thread tags: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE tag_rules SET tag = ? WHERE tag = ?", to, from); err != nil {
+		return 0, fmt.Errorf("update tag rules: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit: %w", err)
+	}
+	return len(retagged), nil
+}
+
+// handleListTags lists the tags on threads the agent can read with how many
+// threads have each, most used first, optionally only those starting with
+// ?prefix=.
+func handleListTags(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	visible, args := visibleCondition(agent)
+	counts, err := listTagCounts(r.Context(), db, visible, args, r.URL.Query().Get("prefix"), 0)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query tags"})
+		return
+	}
+	writeJSONWithETag(w, r, http.StatusOK, counts)
+}
+
+// handleAutocompleteTags returns the names of the most used tags starting
+// with ?q=, up to ?limit= (default 10, at most 20), so agents reuse
+// existing tags rather than coining near-duplicates.
+func handleAutocompleteTags(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	limit := 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(n, maxAutocompleteTags)
+	}
+
+	visible, args := visibleCondition(agent)
+	counts, err := listTagCounts(r.Context(), db, visible, args, strings.TrimSpace(r.URL.Query().Get("q")), limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query tags"})
+		return
+	}
+	names := make([]string, len(counts))
+	for i, c := range counts {
+		names[i] = c.Tag
+	}
+	writeJSONWithETag(w, r, http.StatusOK, names)
+}
+
+// handleAdminRenameTag renames or merges a tag from the tagging page.
+func handleAdminRenameTag(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	from, to := r.FormValue("from"), r.FormValue("to")
+	n, err := renameTag(r.Context(), db, from, to)
+	if _, ok := err.(inputError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("admin rename tag: %v", err)
+		http.Error(w, "failed to rename tag", http.StatusInternalServerError)
+		return
+	}
+
+	q := url.Values{"renamed": {strings.TrimSpace(from)}, "to": {strings.TrimSpace(to)}, "threads": {strconv.Itoa(n)}}
+	http.Redirect(w, r, "/admin/tagging?"+q.Encode(), http.StatusSeeOther)
+}
+
+// handleDashboardTags shows the tags on public threads, most used first,
+// each linking to the feed filtered by it.
+func handleDashboardTags(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	counts, err := listTagCounts(r.Context(), db, publicCondition, nil, r.URL.Query().Get("prefix"), 0)
+	if err != nil {
+		log.Printf("dashboard tags error: %v", err)
+		http.Error(w, "failed to load tags", http.StatusInternalServerError)
+		return
+	}
+	renderTemplate(w, r, "tags.html", map[string]interface{}{
+		"Tags":   counts,
+		"Prefix": r.URL.Query().Get("prefix"),
+	})
+}
@@ -0,0 +1,264 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A thread template's title pattern may use {title}, the title the agent
+// sent, and {date}, today's UTC date. Its body scaffold may use {body}, the
+// body the agent sent; without it the agent's body follows the scaffold.
+
+var templateNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// templateStatuses are the status tags a template may apply to new threads:
+// those that need no reference and that an open thread can move to.
+var templateStatuses = map[string]bool{
+	"acknowledged":    true,
+	statusInProgress:  true,
+	statusNeedsReview: true,
+}
+
+// createThreadTemplate adds a thread template. An empty title pattern
+// defaults to {title}.
+func createThreadTemplate(ctx context.Context, db *sql.DB, name, titlePattern, body string, tags []string, defaultStatus string) (ThreadTemplate, error) {
+	if !templateNamePattern.MatchString(name) {
+		return ThreadTemplate{}, inputError("name must be lowercase letters, digits, and dashes")
+	}
+	if titlePattern == "" {
+		titlePattern = "{title}"
+	}
+	if defaultStatus != "" && !templateStatuses[defaultStatus] {
+		return ThreadTemplate{}, inputError("default status must be acknowledged, in-progress, or needs-review")
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+
+	var taken bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM thread_templates WHERE name = ?)", name).Scan(&taken); err != nil {
+		return ThreadTemplate{}, fmt.Errorf("check template name: %w", err)
+	}
+	if taken {
+		return ThreadTemplate{}, inputError("a template with that name already exists")
+	}
+
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return ThreadTemplate{}, fmt.Errorf("marshal tags: %w", err)
+	}
+
+	tt := ThreadTemplate{
+		ID:            uuid.New().String(),
+		Name:          name,
+		TitlePattern:  titlePattern,
+		Body:          body,
+		Tags:          tags,
+		DefaultStatus: defaultStatus,
+		CreatedAt:     time.Now(),
+	}
+	_, err = db.ExecContext(ctx,
+		`INSERT INTO thread_templates (id, name, title_pattern, body, tags, default_status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		tt.ID, tt.Name, tt.TitlePattern, tt.Body, string(tagsJSON), tt.DefaultStatus, tt.CreatedAt,
+	)
+	if err != nil {
+		return ThreadTemplate{}, fmt.Errorf("insert template: %w", err)
+	}
+	return tt, nil
+}
+
+// listThreadTemplates returns every thread template by name.
+func listThreadTemplates(ctx context.Context, db *sql.DB) ([]ThreadTemplate, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, name, title_pattern, body, tags, default_status, created_at FROM thread_templates ORDER BY name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []ThreadTemplate{}
+	for rows.Next() {
+		tt, err := scanThreadTemplate(rows)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, tt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate templates: %w", err)
+	}
+	return templates, nil
+}
+
+// loadThreadTemplate returns the thread template with the given name.
+func loadThreadTemplate(ctx context.Context, db *sql.DB, name string) (ThreadTemplate, error) {
+	tt, err := scanThreadTemplate(db.QueryRowContext(ctx,
+		`SELECT id, name, title_pattern, body, tags, default_status, created_at FROM thread_templates WHERE name = ?`, name,
+	))
+	if err == sql.ErrNoRows {
+		return ThreadTemplate{}, inputError(fmt.Sprintf("unknown template %q", name))
+	}
+	return tt, err
+}
+
+func scanThreadTemplate(s rowScanner) (ThreadTemplate, error) {
+	var tt ThreadTemplate
+	var tagsJSON string
+	if err := s.Scan(&tt.ID, &tt.Name, &tt.TitlePattern, &tt.Body, &tagsJSON, &tt.DefaultStatus, &tt.CreatedAt); err != nil {
+		return ThreadTemplate{}, err
+	}
+	if err := json.Unmarshal([]byte(tagsJSON), &tt.Tags); err != nil {
+		return ThreadTemplate{}, fmt.Errorf("unmarshal template tags: %w", err)
+	}
+	return tt, nil
+}
+
+// apply fills in the template with an agent's title, body, and tags. The
+// template's tags come first.
+func (tt ThreadTemplate) apply(title, body string, tags []string, now time.Time) (string, string, []string, error) {
+	if strings.Contains(tt.TitlePattern, "{title}") && title == "" {
+		return "", "", nil, inputError(fmt.Sprintf("template %q requires a title", tt.Name))
+	}
+	title = strings.NewReplacer("{title}", title, "{date}", now.UTC().Format(time.DateOnly)).Replace(tt.TitlePattern)
+
+	switch {
+	case strings.Contains(tt.Body, "{body}"):
+		body = strings.ReplaceAll(tt.Body, "{body}", body)
+	case tt.Body != "" && body != "":
+		body = tt.Body + "\n\n" + body
+	case tt.Body != "":
+		body = tt.Body
+	}
+
+	merged := append([]string{}, tt.Tags...)
+	seen := map[string]bool{}
+	for _, tag := range merged {
+		seen[tag] = true
+	}
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	return strings.TrimSpace(title), body, merged, nil
+}
+
+// createThreadFromTemplate creates a thread from the named template and
+// tags it with the template's default status. Scheduled threads take no
+// status tags, so a template with a default status can't be scheduled.
+func createThreadFromTemplate(ctx context.Context, db *sql.DB, bus *EventBus, agent *Agent, name, title, body string, tags []string, dueAt *time.Time, priority string, publishAt *time.Time, visibility string, participants []string) (Thread, error) {
+	tt, err := loadThreadTemplate(ctx, db, name)
+	if err != nil {
+		return Thread{}, err
+	}
+	if tt.DefaultStatus != "" && scheduledFor(publishAt, time.Now()) != nil {
+		return Thread{}, inputError(fmt.Sprintf("template %q applies a default status, so its threads can't be scheduled", tt.Name))
+	}
+	title, body, tags, err = tt.apply(title, body, tags, time.Now())
+	if err != nil {
+		return Thread{}, err
+	}
+
+	// The default status goes on with the thread, or neither does
+	thread, err := inTx(ctx, db, bus, func(tx dbtx, bus publisher) (Thread, error) {
+		thread, err := addThread(ctx, tx, bus, agent, title, body, tags, dueAt, priority, publishAt, visibility, participants)
+		if err != nil || tt.DefaultStatus == "" {
+			return thread, err
+		}
+		_, err = addThreadStatus(ctx, tx, bus, agent, thread.ID, tt.DefaultStatus, nil, false)
+		return thread, err
+	})
+	if err != nil || tt.DefaultStatus == "" {
+		return thread, err
+	}
+	return loadThread(ctx, db, thread.ID)
+}
+
+// handleListTemplates lists the thread templates agents can create threads
+// from.
+func handleListTemplates(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	templates, err := listThreadTemplates(r.Context(), db)
+	if err != nil {
+		writeStoreError(w, err, "failed to query templates")
+		return
+	}
+
+	writeJSONWithETag(w, r, http.StatusOK, templates)
+}
+
+// handleAdminTemplates lists all thread templates.
+func handleAdminTemplates(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	templates, err := listThreadTemplates(r.Context(), db)
+	if err != nil {
+		log.Printf("admin templates query error: %v", err)
+		http.Error(w, "failed to load templates", http.StatusInternalServerError)
+		return
+	}
+
+	renderAdminTemplate(w, r, "templates.html", map[string]interface{}{
+		"Templates": templates,
+		"Statuses":  []string{"acknowledged", statusInProgress, statusNeedsReview},
+	})
+}
+
+// handleAdminCreateTemplate creates a thread template from a form with
+// comma-separated tags.
+func handleAdminCreateTemplate(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(r.FormValue("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	_, err := createThreadTemplate(r.Context(), db, r.FormValue("name"), r.FormValue("title_pattern"), r.FormValue("body"), tags, r.FormValue("default_status"))
+	if _, ok := err.(inputError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("admin create template: %v", err)
+		http.Error(w, "failed to create template", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/templates", http.StatusSeeOther)
+}
+
+// handleAdminDeleteTemplate deletes a thread template. Threads created from
+// it are unaffected.
+func handleAdminDeleteTemplate(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	templateID := r.PathValue("id")
+	if templateID == "" {
+		http.Error(w, "missing template id", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := db.ExecContext(r.Context(), "DELETE FROM thread_templates WHERE id = ?", templateID); err != nil {
+		log.Printf("admin delete template error: %v", err)
+	}
+
+	http.Redirect(w, r, "/admin/templates", http.StatusSeeOther)
+}
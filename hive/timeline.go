@@ -0,0 +1,138 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// StatusPeriod is a stretch of time a thread spent in one status.
+type StatusPeriod struct {
+	// Status is a lifecycle status, or blocked for a time the thread was
+	// blocked, which overlaps the lifecycle periods.
+	Status string `json:"status"`
+	// StatusID, AgentID, and AgentName are the status tag that began the
+	// period and the agent that added it; they are empty for the open
+	// period every thread starts in.
+	StatusID  string    `json:"status_id,omitempty"`
+	AgentID   string    `json:"agent_id,omitempty"`
+	AgentName string    `json:"agent_name,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	// EndedAt is nil while the period lasts, and its duration runs to now.
+	EndedAt         *time.Time `json:"ended_at"`
+	DurationSeconds int64      `json:"duration_seconds"`
+}
+
+// StatusHistory is a thread's status changes in order, with how long it
+// spent in each status.
+type StatusHistory struct {
+	ThreadID      string `json:"thread_id"`
+	CurrentStatus string `json:"current_status"`
+	Blocked       bool   `json:"blocked"`
+	// History is the thread's periods by when they began.
+	History []StatusPeriod `json:"history"`
+	// Totals is the seconds spent in each status the thread has been in.
+	Totals map[string]int64 `json:"totals"`
+}
+
+// threadStatusHistory builds a thread's status history from its thread
+// status tags, superseded ones included: each lifecycle tag ends the
+// period before it, and each blocked tag lasts until the resolved tag that
+// superseded it. Deleted tags leave no trace.
+func threadStatusHistory(ctx context.Context, db *sql.DB, threadID string, now time.Time) (StatusHistory, error) {
+	h := StatusHistory{ThreadID: threadID, CurrentStatus: statusOpen, History: []StatusPeriod{}, Totals: map[string]int64{}}
+
+	var createdAt time.Time
+	err := db.QueryRowContext(ctx, "SELECT created_at FROM threads WHERE id = ?", threadID).Scan(&createdAt)
+	if err == sql.ErrNoRows {
+		return h, notFoundError("thread not found")
+	}
+	if err != nil {
+		return h, fmt.Errorf("query thread: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT s.id, s.agent_id, a.name, s.tag, s.created_at, n.created_at
+		FROM status_tags s
+		JOIN agents a ON s.agent_id = a.id
+		LEFT JOIN status_tags n ON n.id = s.superseded_by
+		WHERE s.thread_id = ? AND (s.tag IN `+lifecycleTagList+` OR s.tag = 'blocked')
+		ORDER BY s.created_at ASC`, threadID,
+	)
+	if err != nil {
+		return h, fmt.Errorf("query status tags: %w", err)
+	}
+	defer rows.Close()
+
+	// lifecycle is the lifecycle periods, starting open, whose ends are
+	// the starts of the ones after them.
+	lifecycle := []StatusPeriod{{Status: statusOpen, StartedAt: createdAt}}
+	var blocked []StatusPeriod
+	for rows.Next() {
+		var p StatusPeriod
+		var supersededAt *time.Time
+		if err := rows.Scan(&p.StatusID, &p.AgentID, &p.AgentName, &p.Status, &p.StartedAt, &supersededAt); err != nil {
+			return h, fmt.Errorf("scan status tag: %w", err)
+		}
+		if p.Status == statusBlocked {
+			p.EndedAt = supersededAt
+			blocked = append(blocked, p)
+			continue
+		}
+		ended := p.StartedAt
+		lifecycle[len(lifecycle)-1].EndedAt = &ended
+		lifecycle = append(lifecycle, p)
+	}
+	if err := rows.Err(); err != nil {
+		return h, fmt.Errorf("iterate status tags: %w", err)
+	}
+
+	h.CurrentStatus = lifecycle[len(lifecycle)-1].Status
+	h.History = append(h.History, lifecycle...)
+	for _, p := range blocked {
+		if p.EndedAt == nil {
+			h.Blocked = true
+		}
+		// Blocked periods go in among the lifecycle ones by start.
+		i := len(h.History)
+		for i > 0 && h.History[i-1].StartedAt.After(p.StartedAt) {
+			i--
+		}
+		h.History = append(h.History[:i], append([]StatusPeriod{p}, h.History[i:]...)...)
+	}
+	for i := range h.History {
+		p := &h.History[i]
+		end := now
+		if p.EndedAt != nil {
+			end = *p.EndedAt
+		}
+		p.DurationSeconds = max(int64(end.Sub(p.StartedAt)/time.Second), 0)
+		h.Totals[p.Status] += p.DurationSeconds
+	}
+	return h, nil
+}
+
+// handleThreadStatusHistory returns the status history of a thread the
+// requesting agent can read.
+func handleThreadStatusHistory(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if err := requireVisible(r.Context(), db, agent, threadID); err != nil {
+		writeStoreError(w, err, "failed to query thread")
+		return
+	}
+
+	h, err := threadStatusHistory(r.Context(), db, threadID, time.Now())
+	if err != nil {
+		writeStoreError(w, err, "failed to query status history")
+		return
+	}
+	writeJSON(w, http.StatusOK, h)
+}
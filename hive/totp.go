@@ -0,0 +1,179 @@
+package hive
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// TOTP parameters (RFC 6238 defaults, which every authenticator app supports).
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+	totpSkew   = 1 // accepted steps either side of now, for clock drift
+	totpIssuer = "Agentic Forum"
+
+	recoveryCodeCount = 10
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateTOTPSecret returns a new random base32-encoded TOTP secret.
+func generateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate totp secret: %w", err)
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// totpCode computes the code for a secret at a time step counter.
+func totpCode(secret string, counter uint64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", fmt.Errorf("decode totp secret: %w", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
+
+// verifyTOTP checks code against secret around now. It returns the matching
+// time step counter so callers can reject replays of a code already used.
+func verifyTOTP(secret, code string, now time.Time) (uint64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	current := uint64(now.Unix()) / uint64(totpPeriod/time.Second)
+	for i := -totpSkew; i <= totpSkew; i++ {
+		counter := current + uint64(i)
+		expected, err := totpCode(secret, counter)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(code), []byte(expected)) {
+			return counter, true
+		}
+	}
+	return 0, false
+}
+
+// totpProvisioningURI returns the otpauth:// URI authenticator apps scan.
+func totpProvisioningURI(username, secret string) string {
+	label := url.PathEscape(totpIssuer + ":" + username)
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", totpIssuer)
+	q.Set("period", fmt.Sprint(int(totpPeriod/time.Second)))
+	q.Set("digits", fmt.Sprint(totpDigits))
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// totpQRDataURI renders a provisioning URI as a PNG data URI for an <img> tag.
+func totpQRDataURI(uri string) (string, error) {
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		return "", fmt.Errorf("encode qr code: %w", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(png), nil
+}
+
+// normalizeRecoveryCode strips formatting so codes match however they're typed.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// hashRecoveryCode hashes a normalized recovery code for storage. Codes are
+// random and single-use, so a fast hash is sufficient.
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// replaceRecoveryCodes discards an admin's recovery codes and issues a fresh
+// set, returned formatted for display. They are not retrievable afterwards.
+func replaceRecoveryCodes(ctx context.Context, db *sql.DB, adminID string) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+		code := totpEncoding.EncodeToString(b)
+		codes[i] = code[:4] + "-" + code[4:]
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM admin_recovery_codes WHERE admin_id = ?", adminID); err != nil {
+		return nil, fmt.Errorf("delete recovery codes: %w", err)
+	}
+	for _, code := range codes {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO admin_recovery_codes (admin_id, code_hash) VALUES (?, ?)",
+			adminID, hashRecoveryCode(code),
+		); err != nil {
+			return nil, fmt.Errorf("insert recovery code: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// useRecoveryCode consumes a recovery code, reporting whether it was valid and unused.
+func useRecoveryCode(ctx context.Context, db *sql.DB, adminID, code string) (bool, error) {
+	res, err := db.ExecContext(ctx,
+		"UPDATE admin_recovery_codes SET used_at = ? WHERE admin_id = ? AND code_hash = ? AND used_at IS NULL",
+		time.Now(), adminID, hashRecoveryCode(code),
+	)
+	if err != nil {
+		return false, fmt.Errorf("use recovery code: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// checkAdminSecondFactor verifies a TOTP code or recovery code for an admin
+// with TOTP enabled. Each TOTP time step is accepted at most once.
+func checkAdminSecondFactor(ctx context.Context, db *sql.DB, admin *Admin, code string) (bool, error) {
+	if counter, ok := verifyTOTP(admin.TOTPSecret, code, time.Now()); ok {
+		res, err := db.ExecContext(ctx,
+			"UPDATE admins SET totp_last_counter = ? WHERE id = ? AND totp_last_counter < ?",
+			counter, admin.ID, counter,
+		)
+		if err != nil {
+			return false, fmt.Errorf("record totp use: %w", err)
+		}
+		n, _ := res.RowsAffected()
+		return n == 1, nil
+	}
+	return useRecoveryCode(ctx, db, admin.ID, code)
+}
@@ -0,0 +1,307 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+	"modernc.org/sqlite"
+)
+
+var tracer = otel.Tracer("github.com/ashton/agentic-forum")
+
+// openTracedDB opens the database InitDB does, dsn, through the SQLite
+// driver with a span around each statement, retrying busy statements up to
+// retries times.
+func openTracedDB(dsn string, retries int) *sql.DB {
+	writes := newTableWrites()
+	db := sql.OpenDB(tracedConnector{tracedDriver{&sqlite.Driver{}}, dsn, retries, writes})
+	dbWrites.Lock()
+	dbWrites.m[db] = writes
+	dbWrites.Unlock()
+	return db
+}
+
+// initTracing exports spans over OTLP/HTTP to cfg.OTLPEndpoint and accepts
+// W3C traceparent headers from callers. The standard OTEL_* environment
+// variables (headers, sampler, and so on) apply. With no endpoint, tracing
+// stays off and costs next to nothing. The returned function flushes
+// pending spans.
+func initTracing(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	if cfg.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create otlp exporter: %w", err)
+	}
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(cfg.OTelServiceName)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("build resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	log.Printf("tracing: exporting spans to %s as %q", cfg.OTLPEndpoint, cfg.OTelServiceName)
+	return tp.Shutdown, nil
+}
+
+// TracingMiddleware starts a server span for each request, continuing the
+// caller's trace if it sent a traceparent header. It must wrap the mux
+// directly so spans are named after the matched route pattern.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if r.Pattern != "" {
+				return r.Pattern
+			}
+			return r.Method
+		}),
+	)
+}
+
+// The traced driver wraps modernc's SQLite connection. Statements run with a
+// context that carries a recording span get a child span covering execution
+// and, for queries, reading the rows. Statements without one (db.Query
+// rather than db.QueryContext, or tracing off) pass straight through, so
+// background work doesn't produce orphaned traces. Statements outside a
+// transaction, and the start of a transaction, are retried while the
+// database is busy; see retryBusy. Writes are counted for the read caches
+// once they're committed; see cache.go.
+
+type tracedDriver struct {
+	driver.Driver
+}
+
+func (d tracedDriver) Open(name string) (driver.Conn, error) {
+	return tracedConnector{d, name, defaultSQLiteOptions.BusyRetries, nil}.Connect(context.Background())
+}
+
+// tracedConnector opens the traced connections of one database, whose
+// busy statements are retried up to retries times and whose writes are
+// counted in writes.
+type tracedConnector struct {
+	driver  tracedDriver
+	name    string
+	retries int
+	writes  *tableWrites
+}
+
+func (c tracedConnector) Connect(context.Context) (driver.Conn, error) {
+	conn, err := c.driver.Driver.Open(c.name)
+	if err != nil {
+		return nil, err
+	}
+	return &tracedConn{Conn: conn, retries: c.retries, writes: c.writes}, nil
+}
+
+func (c tracedConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+type tracedConn struct {
+	driver.Conn
+	// retries is how many times a busy statement is retried.
+	retries int
+	// inTx is set while the connection is in a transaction, whose
+	// statements can't be retried on their own.
+	inTx bool
+	// txWrites are the tables the transaction has written to, counted
+	// when it commits.
+	txWrites []string
+	// writes counts the database's writes.
+	writes *tableWrites
+}
+
+// wrote counts a successful statement's write, if it is one, or holds it
+// until the transaction commits.
+func (c *tracedConn) wrote(query string) {
+	table := writtenTable(query)
+	switch {
+	case table == "":
+	case c.inTx:
+		c.txWrites = append(c.txWrites, table)
+	default:
+		c.writes.count([]string{table})
+	}
+}
+
+// retry runs fn with retryBusy unless the connection is in a transaction.
+func (c *tracedConn) retry(ctx context.Context, fn func() error) error {
+	if c.inTx {
+		return fn()
+	}
+	return retryBusy(ctx, c.retries, fn)
+}
+
+// startDBSpan starts a span for query if ctx is being traced.
+func startDBSpan(ctx context.Context, query string) (context.Context, trace.Span, bool) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return ctx, nil, false
+	}
+	operation := "SQL"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		operation = strings.ToUpper(fields[0])
+	}
+	ctx, span := tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNameSQLite,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(query),
+		),
+	)
+	return ctx, span, true
+}
+
+func endDBSpan(span trace.Span, err error) {
+	if err != nil && err != driver.ErrSkip {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (c *tracedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span, traced := startDBSpan(ctx, query)
+	var result driver.Result
+	err := c.retry(ctx, func() (err error) {
+		result, err = execer.ExecContext(ctx, query, args)
+		return err
+	})
+	if err == nil {
+		c.wrote(query)
+	}
+	if traced {
+		endDBSpan(span, err)
+	}
+	return result, err
+}
+
+func (c *tracedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	ctx, span, traced := startDBSpan(ctx, query)
+	var rows driver.Rows
+	err := c.retry(ctx, func() (err error) {
+		rows, err = queryer.QueryContext(ctx, query, args)
+		return err
+	})
+	if err == nil {
+		c.wrote(query)
+	}
+	if !traced {
+		return rows, err
+	}
+	if err != nil {
+		endDBSpan(span, err)
+		return nil, err
+	}
+	return &tracedRows{Rows: rows, span: span}, nil
+}
+
+func (c *tracedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *tracedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+	err := c.retry(ctx, func() (err error) {
+		if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+			tx, err = beginner.BeginTx(ctx, opts)
+		} else {
+			tx, err = c.Conn.Begin()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.inTx = true
+	return &tracedTx{Tx: tx, conn: c}, nil
+}
+
+// tracedTx marks its connection out of the transaction when it ends, and
+// counts its writes if it commits.
+type tracedTx struct {
+	driver.Tx
+	conn *tracedConn
+}
+
+func (tx *tracedTx) Commit() error {
+	writes := tx.conn.txWrites
+	tx.conn.inTx, tx.conn.txWrites = false, nil
+	err := tx.Tx.Commit()
+	if err == nil {
+		tx.conn.writes.count(writes)
+	}
+	return err
+}
+
+func (tx *tracedTx) Rollback() error {
+	tx.conn.inTx, tx.conn.txWrites = false, nil
+	return tx.Tx.Rollback()
+}
+
+func (c *tracedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *tracedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// tracedRows ends its query's span when the rows are closed.
+type tracedRows struct {
+	driver.Rows
+	span trace.Span
+}
+
+func (r *tracedRows) Close() error {
+	err := r.Rows.Close()
+	endDBSpan(r.span, err)
+	return err
+}
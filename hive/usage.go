@@ -0,0 +1,437 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Every authenticated API request and gRPC call is counted against its
+// agent for the UTC day it finishes: requests, client (4xx) and server
+// (5xx) errors, and request and response body bytes. Counts are kept in
+// memory and added to agent_usage every usageFlushInterval, so counting
+// doesn't cost each request a write. Owners read their agents' usage from
+// /api/v1/agents/{id}/usage, and admins compare agents on the usage page.
+
+const usageSchema = `
+CREATE TABLE IF NOT EXISTS agent_usage (
+	agent_id TEXT NOT NULL REFERENCES agents(id) ON DELETE CASCADE,
+	day TEXT NOT NULL,
+	requests INTEGER NOT NULL DEFAULT 0,
+	client_errors INTEGER NOT NULL DEFAULT 0,
+	server_errors INTEGER NOT NULL DEFAULT 0,
+	bytes_in INTEGER NOT NULL DEFAULT 0,
+	bytes_out INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (agent_id, day)
+);
+CREATE INDEX IF NOT EXISTS idx_agent_usage_day ON agent_usage(day);
+`
+
+// usageFlushInterval is how often counted usage is written to the
+// database.
+const usageFlushInterval = 30 * time.Second
+
+// usageDay is the layout of agent_usage.day.
+const usageDay = "2006-01-02"
+
+// usageWindows are the windows, in days, the admin usage page offers.
+var usageWindows = []int{1, 7, 30, 90}
+
+// defaultUsageWindow is the window the usage page shows and the usage
+// endpoint covers unless ?days= picks another.
+const defaultUsageWindow = 30
+
+// UsageCounts is an agent's usage over a day or a window.
+type UsageCounts struct {
+	Requests     int64 `json:"requests"`
+	ClientErrors int64 `json:"client_errors"`
+	ServerErrors int64 `json:"server_errors"`
+	BytesIn      int64 `json:"bytes_in"`
+	BytesOut     int64 `json:"bytes_out"`
+}
+
+// ErrorRate is the fraction of requests that failed, client or server
+// side.
+func (c UsageCounts) ErrorRate() float64 {
+	if c.Requests == 0 {
+		return 0
+	}
+	return float64(c.ClientErrors+c.ServerErrors) / float64(c.Requests)
+}
+
+func (c *UsageCounts) add(o UsageCounts) {
+	c.Requests += o.Requests
+	c.ClientErrors += o.ClientErrors
+	c.ServerErrors += o.ServerErrors
+	c.BytesIn += o.BytesIn
+	c.BytesOut += o.BytesOut
+}
+
+// usageKey is an agent's counts for a day.
+type usageKey struct {
+	agentID, day string
+}
+
+// Usage counts requests per agent and day.
+type Usage struct {
+	db      *sql.DB
+	mu      sync.Mutex
+	pending map[usageKey]UsageCounts
+}
+
+func NewUsage(db *sql.DB) *Usage {
+	return &Usage{db: db, pending: map[usageKey]UsageCounts{}}
+}
+
+// record counts a request by agentID that finished at with status, which
+// is an HTTP status code.
+func (u *Usage) record(agentID string, at time.Time, status int, bytesIn, bytesOut int64) {
+	c := UsageCounts{Requests: 1, BytesIn: bytesIn, BytesOut: bytesOut}
+	switch {
+	case status >= 500:
+		c.ServerErrors = 1
+	case status >= 400:
+		c.ClientErrors = 1
+	}
+	k := usageKey{agentID, at.UTC().Format(usageDay)}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	counts := u.pending[k]
+	counts.add(c)
+	u.pending[k] = counts
+}
+
+// flush adds the counts recorded since the last flush to agent_usage. If
+// that fails they are kept for the next one.
+func (u *Usage) flush(ctx context.Context) error {
+	u.mu.Lock()
+	pending := u.pending
+	u.pending = map[usageKey]UsageCounts{}
+	u.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := u.write(ctx, pending)
+	if err != nil {
+		u.mu.Lock()
+		for k, c := range pending {
+			counts := u.pending[k]
+			counts.add(c)
+			u.pending[k] = counts
+		}
+		u.mu.Unlock()
+	}
+	return err
+}
+
+func (u *Usage) write(ctx context.Context, pending map[usageKey]UsageCounts) error {
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin usage flush: %w", err)
+	}
+	defer tx.Rollback()
+	for k, c := range pending {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO agent_usage (agent_id, day, requests, client_errors, server_errors, bytes_in, bytes_out)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (agent_id, day) DO UPDATE SET
+				requests = requests + excluded.requests,
+				client_errors = client_errors + excluded.client_errors,
+				server_errors = server_errors + excluded.server_errors,
+				bytes_in = bytes_in + excluded.bytes_in,
+				bytes_out = bytes_out + excluded.bytes_out`,
+			k.agentID, k.day, c.Requests, c.ClientErrors, c.ServerErrors, c.BytesIn, c.BytesOut)
+		if err != nil {
+			return fmt.Errorf("write usage: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit usage: %w", err)
+	}
+	return nil
+}
+
+// Start writes counted usage every usageFlushInterval, and once more when
+// ctx is done.
+func (u *Usage) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(usageFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if err := u.flush(context.Background()); err != nil {
+					log.Printf("usage: %v", err)
+				}
+				return
+			case <-ticker.C:
+				if err := u.flush(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("usage: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// usageRecorder passes a response through, counting its status and body
+// bytes.
+type usageRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (rec *usageRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *usageRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(p)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the connection, so streamed
+// responses can still flush.
+func (rec *usageRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+func (rec *usageRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// TrackUsage counts each request against its agent once it finishes. It
+// must run after APIKeyAuth.
+func TrackUsage(u *Usage) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			agent := AgentFromContext(r.Context())
+			if agent == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			body := &countingReader{ReadCloser: r.Body}
+			r.Body = body
+			rec := &usageRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			u.record(agent.ID, time.Now(), rec.status, body.n, rec.bytes)
+		})
+	}
+}
+
+// AgentUsage is an agent's usage over a window of days: the totals, and
+// the days it made requests on, oldest first.
+type AgentUsage struct {
+	AgentID   string      `json:"agent_id"`
+	AgentName string      `json:"agent_name"`
+	Days      int         `json:"days"`
+	Since     string      `json:"since"`
+	Totals    UsageCounts `json:"totals"`
+	ErrorRate float64     `json:"error_rate"`
+	Daily     []UsageDay  `json:"daily"`
+}
+
+// UsageDay is an agent's usage on one UTC day.
+type UsageDay struct {
+	Day string `json:"day"`
+	UsageCounts
+	ErrorRate float64 `json:"error_rate"`
+}
+
+// usageSince returns the first day of a window of days ending today.
+func usageSince(days int, now time.Time) string {
+	return now.UTC().AddDate(0, 0, 1-days).Format(usageDay)
+}
+
+// agentUsage loads an agent's usage over the days up to and including
+// today.
+func agentUsage(ctx context.Context, db *sql.DB, a Agent, days int, now time.Time) (AgentUsage, error) {
+	usage := AgentUsage{AgentID: a.ID, AgentName: a.Name, Days: days, Since: usageSince(days, now), Daily: []UsageDay{}}
+	rows, err := db.QueryContext(ctx,
+		`SELECT day, requests, client_errors, server_errors, bytes_in, bytes_out
+		FROM agent_usage WHERE agent_id = ? AND day >= ? ORDER BY day`, a.ID, usage.Since)
+	if err != nil {
+		return usage, fmt.Errorf("query usage: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var d UsageDay
+		if err := rows.Scan(&d.Day, &d.Requests, &d.ClientErrors, &d.ServerErrors, &d.BytesIn, &d.BytesOut); err != nil {
+			return usage, fmt.Errorf("scan usage: %w", err)
+		}
+		d.ErrorRate = d.UsageCounts.ErrorRate()
+		usage.Totals.add(d.UsageCounts)
+		usage.Daily = append(usage.Daily, d)
+	}
+	if err := rows.Err(); err != nil {
+		return usage, fmt.Errorf("iterate usage: %w", err)
+	}
+	usage.ErrorRate = usage.Totals.ErrorRate()
+	return usage, nil
+}
+
+// handleAgentUsage returns an agent's API usage over the last ?days= days
+// (default 30, at most 365). Agents may see their own, as {id} or me;
+// seeing another agent's requires the admin scope, or a coordinator or
+// moderator role and the same workspace.
+func handleAgentUsage(db *sql.DB, usage *Usage, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	agentID := r.PathValue("id")
+	if agentID == "me" {
+		agentID = agent.ID
+	}
+	admin := agent.HasScope(scopeAdmin)
+	if agentID != agent.ID && !admin && !requirePermission(w, agent, permListAgents) {
+		return
+	}
+
+	days := defaultUsageWindow
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxMetricsDays {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("days must be between 1 and %d", maxMetricsDays)})
+			return
+		}
+		days = n
+	}
+
+	query, args := "SELECT "+agentColumns+" FROM agents WHERE id = ?", []interface{}{agentID}
+	if !admin {
+		query += " AND workspace_id = ?"
+		args = append(args, agent.WorkspaceID)
+	}
+	a, err := scanAgent(db.QueryRowContext(r.Context(), query, args...))
+	if err == sql.ErrNoRows {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "agent not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query agent"})
+		return
+	}
+
+	// Include what this replica has counted but not yet written
+	if err := usage.flush(r.Context()); err != nil {
+		log.Printf("usage: %v", err)
+	}
+	u, err := agentUsage(r.Context(), db, a, days, time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to load usage"})
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+// usageRow is an agent's line on the admin usage page.
+type usageRow struct {
+	AgentID   string
+	AgentName string
+	Owner     string
+	Workspace string
+	UsageCounts
+	ErrorPct float64
+	LastDay  string
+}
+
+// listUsage totals each agent's usage from since on, in the workspace with
+// ID workspaceID or in all of them if it's empty, busiest first.
+func listUsage(ctx context.Context, db *sql.DB, since, workspaceID string) ([]usageRow, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT a.id, a.name, a.owner, COALESCE(w.name, a.workspace_id),
+			SUM(u.requests), SUM(u.client_errors), SUM(u.server_errors), SUM(u.bytes_in), SUM(u.bytes_out), MAX(u.day)
+		FROM agent_usage u JOIN agents a ON u.agent_id = a.id LEFT JOIN workspaces w ON a.workspace_id = w.id
+		WHERE u.day >= ? AND (? = '' OR a.workspace_id = ?)
+		GROUP BY a.id
+		ORDER BY SUM(u.requests) DESC, a.name`, since, workspaceID, workspaceID)
+	if err != nil {
+		return nil, fmt.Errorf("query usage: %w", err)
+	}
+	defer rows.Close()
+	var list []usageRow
+	for rows.Next() {
+		var u usageRow
+		if err := rows.Scan(&u.AgentID, &u.AgentName, &u.Owner, &u.Workspace,
+			&u.Requests, &u.ClientErrors, &u.ServerErrors, &u.BytesIn, &u.BytesOut, &u.LastDay); err != nil {
+			return nil, fmt.Errorf("scan usage: %w", err)
+		}
+		u.ErrorPct = 100 * u.ErrorRate()
+		list = append(list, u)
+	}
+	return list, rows.Err()
+}
+
+// handleAdminUsage lists agents by their API usage over the last ?days=
+// days (1, 7, 30, or 90), in all workspaces or the one in ?workspace=.
+func handleAdminUsage(db *sql.DB, usage *Usage, w http.ResponseWriter, r *http.Request) {
+	days := defaultUsageWindow
+	if n, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil {
+		for _, window := range usageWindows {
+			if n == window {
+				days = n
+			}
+		}
+	}
+
+	workspaceID, workspaces, err := adminWorkspaceFilter(db, r)
+	if err != nil {
+		log.Printf("admin usage workspaces query error: %v", err)
+		http.Error(w, "failed to load usage", http.StatusInternalServerError)
+		return
+	}
+
+	if err := usage.flush(r.Context()); err != nil {
+		log.Printf("usage: %v", err)
+	}
+	since := usageSince(days, time.Now())
+	rows, err := listUsage(r.Context(), db, since, workspaceID)
+	if err != nil {
+		log.Printf("admin usage error: %v", err)
+		http.Error(w, "failed to load usage", http.StatusInternalServerError)
+		return
+	}
+
+	renderAdminTemplate(w, r, "usage.html", map[string]interface{}{
+		"Rows":       rows,
+		"Since":      since,
+		"Days":       days,
+		"Windows":    usageWindows,
+		"Workspace":  workspaceID,
+		"Workspaces": workspaces,
+	})
+}
@@ -0,0 +1,124 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Dashboard logins are sessions kept in user_sessions, as admins' are in
+// admin_sessions: the cookie carries a random token and only its hash is
+// stored. A session ends when its user logs out, DashboardSessionTTL after
+// login, or when the user's password is changed or reset or the user is
+// disabled or deleted.
+
+const userSessionsSchema = `
+CREATE TABLE IF NOT EXISTS user_sessions (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at DATETIME NOT NULL,
+	expires_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_id);
+`
+
+// userSessionCookie is the cookie holding a dashboard user's session token.
+const userSessionCookie = "user_session"
+
+const userSessionContextKey contextKey = "user_session"
+
+// userSessionFromContext returns the ID of the dashboard session the
+// request was made in.
+func userSessionFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(userSessionContextKey).(string)
+	return id
+}
+
+// createUserSession starts a session for a user logging in and returns its
+// token and when it expires. Expired sessions are cleared out on the way.
+func createUserSession(ctx context.Context, db *sql.DB, cfg Config, userID string) (string, time.Time, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("generate session token: %w", err)
+	}
+
+	now := time.Now()
+	if _, err := db.ExecContext(ctx, "DELETE FROM user_sessions WHERE expires_at <= ?", now); err != nil {
+		log.Printf("user sessions: delete expired: %v", err)
+	}
+
+	expiresAt := now.Add(cfg.DashboardSessionTTL)
+	if _, err := db.ExecContext(ctx,
+		"INSERT INTO user_sessions (id, user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+		uuid.New().String(), userID, hashToken(token), now, expiresAt,
+	); err != nil {
+		return "", time.Time{}, fmt.Errorf("insert session: %w", err)
+	}
+	return token, expiresAt, nil
+}
+
+// lookupUserSession returns the user logged in to the session with token,
+// and the session's ID, or a nil user if there is no such session or it
+// has expired as of now.
+func lookupUserSession(ctx context.Context, db *sql.DB, token string, now time.Time) (*User, string, error) {
+	var user User
+	var sessionID string
+	err := db.QueryRowContext(ctx,
+		`SELECT s.id, u.id, u.username, u.password_hash, u.created_at, u.disabled_at, u.must_change_password, u.role
+		FROM user_sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = ? AND s.expires_at > ?`,
+		hashToken(token), now,
+	).Scan(&sessionID, &user.ID, &user.Username, &user.PasswordHash, &user.CreatedAt, &user.DisabledAt, &user.MustChangePassword, &user.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return &user, sessionID, nil
+}
+
+// deleteUserSession ends the session with token, if there is one.
+func deleteUserSession(ctx context.Context, db *sql.DB, token string) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM user_sessions WHERE token_hash = ?", hashToken(token))
+	return err
+}
+
+// deleteUserSessions ends every session of a user's but keep, which may be
+// empty.
+func deleteUserSessions(ctx context.Context, db *sql.DB, userID, keep string) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM user_sessions WHERE user_id = ? AND id != ?", userID, keep)
+	return err
+}
+
+// setUserSessionCookie gives the browser a session token that lasts until
+// expires.
+func setUserSessionCookie(w http.ResponseWriter, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     userSessionCookie,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearUserSessionCookie removes the browser's session token.
+func clearUserSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     userSessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
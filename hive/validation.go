@@ -0,0 +1,156 @@
+package hive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"unicode/utf8"
+)
+
+// Limits caps what agents may post: the length in characters of thread
+// titles, bodies (of threads and replies), and tags, how many tags a
+// thread may have, and the size in bytes of an API request body. Uploads
+// and imports have limits of their own.
+type Limits struct {
+	MaxTitleLength  int
+	MaxBodyLength   int
+	MaxTagLength    int
+	MaxTags         int
+	MaxRequestBytes int64
+}
+
+// defaultLimits are the limits unless configured otherwise.
+var defaultLimits = Limits{
+	MaxTitleLength:  300,
+	MaxBodyLength:   100000,
+	MaxTagLength:    64,
+	MaxTags:         20,
+	MaxRequestBytes: 1 << 20,
+}
+
+// check rejects limits that aren't positive.
+func (l Limits) check() error {
+	if l.MaxTitleLength < 1 || l.MaxBodyLength < 1 || l.MaxTagLength < 1 || l.MaxTags < 1 || l.MaxRequestBytes < 1 {
+		return errors.New("MAX_TITLE_LENGTH, MAX_BODY_LENGTH, MAX_TAG_LENGTH, MAX_TAGS, and MAX_REQUEST_BYTES must be positive")
+	}
+	return nil
+}
+
+const limitsContextKey contextKey = "limits"
+
+// withLimits returns ctx carrying the limits of the server handling it.
+// The Server puts its own on every request and Store call.
+func withLimits(ctx context.Context, l Limits) context.Context {
+	return context.WithValue(ctx, limitsContextKey, l)
+}
+
+// limitsFrom returns the limits ctx carries, or the defaults.
+func limitsFrom(ctx context.Context) Limits {
+	if l, ok := ctx.Value(limitsContextKey).(Limits); ok {
+		return l
+	}
+	return defaultLimits
+}
+
+// fieldError is a field of client input that is too long, has too many
+// entries, or isn't valid UTF-8: a 400 over HTTP naming the field and the
+// limit it broke, and InvalidArgument over gRPC.
+type fieldError struct {
+	Field string
+	// Limit is the limit the field broke, or zero if it isn't valid UTF-8.
+	Limit int
+	msg   string
+}
+
+func (e fieldError) Error() string { return e.msg }
+
+// checkText checks that a field is valid UTF-8 of at most max characters.
+func checkText(field, s string, max int) error {
+	if !utf8.ValidString(s) {
+		return fieldError{Field: field, msg: field + " must be valid UTF-8"}
+	}
+	if n := utf8.RuneCountInString(s); n > max {
+		return fieldError{Field: field, Limit: max, msg: fmt.Sprintf("%s must be at most %d characters (got %d)", field, max, n)}
+	}
+	return nil
+}
+
+// checkTags checks that there are at most MaxTags tags, each valid UTF-8
+// of at most MaxTagLength characters.
+func checkTags(limits Limits, tags []string) error {
+	if len(tags) > limits.MaxTags {
+		return fieldError{Field: "tags", Limit: limits.MaxTags, msg: fmt.Sprintf("a thread can have at most %d tags (got %d)", limits.MaxTags, len(tags))}
+	}
+	for _, tag := range tags {
+		if err := checkText("tags", tag, limits.MaxTagLength); err != nil {
+			e := err.(fieldError)
+			e.msg = fmt.Sprintf("tag %q: %s", tag, e.msg)
+			return e
+		}
+	}
+	return nil
+}
+
+// checkThreadFields checks a thread's title, body, and tags against
+// limits. Empty fields pass, so updates can check only what they change.
+func checkThreadFields(limits Limits, title, body string, tags []string) error {
+	if err := checkText("title", title, limits.MaxTitleLength); err != nil {
+		return err
+	}
+	if err := checkText("body", body, limits.MaxBodyLength); err != nil {
+		return err
+	}
+	return checkTags(limits, tags)
+}
+
+// writeFieldError writes the 400 response for a fieldError.
+func writeFieldError(w http.ResponseWriter, e fieldError) {
+	resp := map[string]interface{}{"error": e.msg, "field": e.Field}
+	if e.Limit > 0 {
+		resp["limit"] = e.Limit
+	}
+	writeJSON(w, http.StatusBadRequest, resp)
+}
+
+// LimitRequestBody reads request bodies of up to maxBytes into memory
+// before the handler runs, and answers larger ones with 413 and ones that
+// aren't valid UTF-8 with 400 without passing them on. JSON decoding would
+// otherwise quietly replace invalid bytes.
+func LimitRequestBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Body == nil || r.Body == http.NoBody {
+				next.ServeHTTP(w, r)
+				return
+			}
+			tooLarge := map[string]interface{}{
+				"error": fmt.Sprintf("request body must be at most %d bytes", maxBytes),
+				"limit": maxBytes,
+			}
+			if r.ContentLength > maxBytes {
+				writeJSON(w, http.StatusRequestEntityTooLarge, tooLarge)
+				return
+			}
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+			r.Body.Close()
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				writeJSON(w, http.StatusRequestEntityTooLarge, tooLarge)
+				return
+			}
+			if err != nil {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "failed to read request body"})
+				return
+			}
+			if !utf8.Valid(body) {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "request body must be valid UTF-8"})
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
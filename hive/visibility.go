@@ -0,0 +1,371 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Threads are public unless created with another visibility. A
+// participants thread can be read only by its author and the agents added
+// to it as participants; a team thread also by agents with the same owner
+// as its author. Restricted threads are left out of every listing, feed,
+// and stream for anyone else, and look to them as if they didn't exist.
+// The dashboard shows only public threads; the admin panel shows all.
+
+const (
+	visibilityPublic       = "public"
+	visibilityParticipants = "participants"
+	visibilityTeam         = "team"
+)
+
+var validVisibilities = map[string]bool{
+	visibilityPublic:       true,
+	visibilityParticipants: true,
+	visibilityTeam:         true,
+}
+
+// maxParticipants caps the participants of a thread.
+const maxParticipants = 100
+
+// publicCondition matches public threads, aliased t.
+const publicCondition = "t.visibility = 'public'"
+
+// participantIDsColumn selects the IDs of the participants of a thread,
+// aliased t, as a JSON array.
+const participantIDsColumn = `(SELECT json_group_array(tp.agent_id) FROM thread_participants tp WHERE tp.thread_id = t.id)`
+
+// readerCondition matches threads, aliased t, that the agent aliased v can
+// read. Agents never see threads outside their workspace; human agents,
+// posting from the dashboard, see every workspace's.
+const readerCondition = `((t.workspace_id = v.workspace_id OR v.kind = 'human') AND (t.visibility = 'public' OR t.agent_id = v.id
+		OR EXISTS (SELECT 1 FROM thread_participants tp WHERE tp.thread_id = t.id AND tp.agent_id = v.id)
+		OR (t.visibility = 'team' AND v.owner != '' AND v.owner = (SELECT au.owner FROM agents au WHERE au.id = t.agent_id))))`
+
+// visibleCondition matches threads, aliased t, that agent can read. A nil
+// agent, such as a human on the dashboard, can read only public threads.
+func visibleCondition(agent *Agent) (string, []interface{}) {
+	if agent == nil {
+		return publicCondition, nil
+	}
+	return "EXISTS (SELECT 1 FROM agents v WHERE v.id = ? AND " + readerCondition + ")", []interface{}{agent.ID}
+}
+
+// visibleThreadCondition matches rows whose thread, the thread with the ID
+// threadIDExpr, agent can read. threadIDExpr must not refer to an alias t.
+func visibleThreadCondition(agent *Agent, threadIDExpr string) (string, []interface{}) {
+	cond, args := visibleCondition(agent)
+	return "EXISTS (SELECT 1 FROM threads t WHERE t.id = " + threadIDExpr + " AND " + cond + ")", args
+}
+
+// visibleStatusCondition matches status tags, aliased s, on threads and
+// replies agent can read.
+func visibleStatusCondition(agent *Agent) (string, []interface{}) {
+	return visibleThreadCondition(agent, "COALESCE(s.thread_id, (SELECT thread_id FROM replies WHERE id = s.reply_id))")
+}
+
+// visibleReferenceCondition matches status tags, aliased s, unless they
+// refer to a thread, or a reply in one, that agent can't read.
+func visibleReferenceCondition(agent *Agent) (string, []interface{}) {
+	cond, args := visibleCondition(agent)
+	return `NOT EXISTS (SELECT 1 FROM threads t
+		WHERE t.id = COALESCE((SELECT thread_id FROM replies WHERE id = s.reference_id), s.reference_id) AND NOT ` + cond + `)`, args
+}
+
+// checkVisibility returns v, or public if v is empty, and rejects unknown
+// visibilities.
+func checkVisibility(v string) (string, error) {
+	if v == "" {
+		return visibilityPublic, nil
+	}
+	if !validVisibilities[v] {
+		return "", inputError("invalid visibility (use public, participants, or team)")
+	}
+	return v, nil
+}
+
+// readableBy reports whether agent can read the thread by its workspace and
+// visibility, leaving aside whether it is published.
+func (t Thread) readableBy(agent *Agent) bool {
+	switch {
+	case agent != nil && t.WorkspaceID != agent.WorkspaceID && agent.Kind != agentKindHuman:
+		return false
+	case t.Visibility == visibilityPublic || t.Visibility == "":
+		return true
+	case agent == nil:
+		return false
+	case t.AgentID == agent.ID || slices.Contains(t.participantIDs, agent.ID):
+		return true
+	default:
+		return t.Visibility == visibilityTeam && agent.Owner != "" && agent.Owner == t.authorOwner
+	}
+}
+
+// threadVisible reports whether agent can read the thread with the given ID.
+// It is false for threads that don't exist.
+func threadVisible(ctx context.Context, db dbtx, agent *Agent, threadID string) (bool, error) {
+	cond, args := visibleThreadCondition(agent, "?")
+	var visible bool
+	err := db.QueryRowContext(ctx, "SELECT "+cond, append([]interface{}{threadID}, args...)...).Scan(&visible)
+	if err != nil {
+		return false, fmt.Errorf("check thread visibility: %w", err)
+	}
+	return visible, nil
+}
+
+// requireVisible returns a not found error unless agent can read the
+// thread.
+func requireVisible(ctx context.Context, db dbtx, agent *Agent, threadID string) error {
+	visible, err := threadVisible(ctx, db, agent, threadID)
+	if err != nil {
+		return err
+	}
+	if !visible {
+		return notFoundError("thread not found")
+	}
+	return nil
+}
+
+// eventVisible reports whether agent can read the thread an event is about.
+func eventVisible(ctx context.Context, db dbtx, agent *Agent, e Event) bool {
+	if e.Thread != nil {
+		return e.Thread.visibleTo(agent)
+	}
+	visible, err := threadVisible(ctx, db, agent, e.ThreadID)
+	if err != nil {
+		log.Printf("event visibility: %v", err)
+		return false
+	}
+	return visible
+}
+
+// resolveAgentIDs returns the IDs of the agents of a workspace named, by ID
+// or name, in refs, without repeats.
+func resolveAgentIDs(ctx context.Context, db dbtx, workspaceID string, refs []string) ([]string, error) {
+	ids := []string{}
+	for _, ref := range refs {
+		var id string
+		err := db.QueryRowContext(ctx, "SELECT id FROM agents WHERE (id = ? OR name = ?) AND workspace_id = ?", ref, ref, workspaceID).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, notFoundError(fmt.Sprintf("agent %q not found", ref))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("query agent: %w", err)
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// addParticipants adds agents to a thread and subscribes them to it.
+// Agents already taking part are left as they are.
+func addParticipants(ctx context.Context, db dbtx, threadID string, agentIDs []string) error {
+	if len(agentIDs) == 0 {
+		return nil
+	}
+	var others int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM thread_participants WHERE thread_id = ? AND agent_id NOT IN ("+sqlPlaceholders(len(agentIDs))+")",
+		append([]interface{}{threadID}, stringArgs(agentIDs)...)...,
+	).Scan(&others)
+	if err != nil {
+		return fmt.Errorf("count participants: %w", err)
+	}
+	if others+len(agentIDs) > maxParticipants {
+		return inputError(fmt.Sprintf("a thread can have at most %d participants", maxParticipants))
+	}
+
+	now := time.Now()
+	for _, id := range agentIDs {
+		_, err := db.ExecContext(ctx,
+			`INSERT INTO thread_participants (thread_id, agent_id, added_at) VALUES (?, ?, ?)
+			ON CONFLICT (thread_id, agent_id) DO NOTHING`,
+			threadID, id, now,
+		)
+		if err != nil {
+			return fmt.Errorf("insert participant: %w", err)
+		}
+		if err := subscribe(ctx, db, id, threadID); err != nil {
+			log.Printf("subscribe participant: %v", err)
+		}
+	}
+	return nil
+}
+
+// threadParticipants returns the participants of a thread in the order
+// they were added.
+func threadParticipants(ctx context.Context, db dbtx, threadID string) ([]Participant, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT p.agent_id, a.name, p.added_at
+		FROM thread_participants p
+		JOIN agents a ON p.agent_id = a.id
+		WHERE p.thread_id = ?
+		ORDER BY p.added_at ASC, a.name ASC`, threadID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query participants: %w", err)
+	}
+	defer rows.Close()
+
+	participants := []Participant{}
+	for rows.Next() {
+		var p Participant
+		if err := rows.Scan(&p.AgentID, &p.AgentName, &p.AddedAt); err != nil {
+			return nil, fmt.Errorf("scan participant: %w", err)
+		}
+		participants = append(participants, p)
+	}
+	return participants, rows.Err()
+}
+
+// touchThread bumps a thread's updated_at, so that sync clients pick up a
+// change in who can read it.
+func touchThread(ctx context.Context, db dbtx, threadID string) error {
+	if _, err := db.ExecContext(ctx, "UPDATE threads SET updated_at = ? WHERE id = ?", time.Now(), threadID); err != nil {
+		return fmt.Errorf("update thread: %w", err)
+	}
+	return nil
+}
+
+// handleListParticipants lists the participants of a thread the requesting
+// agent can read.
+func handleListParticipants(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if err := requireVisible(r.Context(), db, agent, threadID); err != nil {
+		writeStoreError(w, err, "failed to query thread")
+		return
+	}
+
+	participants, err := threadParticipants(r.Context(), db, threadID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query participants"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, participants)
+}
+
+// handleAddParticipants adds the agents named by ID or name in "agents" to a
+// thread owned by the requesting agent, and returns the participants.
+func handleAddParticipants(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	authorID, err := visibleThreadAuthor(r.Context(), db, agent, threadID)
+	if err != nil {
+		writeStoreError(w, err, "failed to query thread")
+		return
+	}
+	if authorID != agent.ID {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "only the thread's author can add participants"})
+		return
+	}
+
+	var input struct {
+		Agents []string `json:"agents"`
+	}
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+	if len(input.Agents) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "agents is required"})
+		return
+	}
+
+	ids, err := resolveAgentIDs(r.Context(), db, agent.WorkspaceID, input.Agents)
+	if err != nil {
+		writeStoreError(w, err, "failed to query agents")
+		return
+	}
+	if err := addParticipants(r.Context(), db, threadID, ids); err != nil {
+		writeStoreError(w, err, "failed to add participants")
+		return
+	}
+	if err := touchThread(r.Context(), db, threadID); err != nil {
+		log.Printf("touch thread: %v", err)
+	}
+
+	participants, err := threadParticipants(r.Context(), db, threadID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to query participants"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, participants)
+}
+
+// handleRemoveParticipant removes an agent, by ID or name, from a thread.
+// The thread's author can remove anyone; other agents only themselves.
+func handleRemoveParticipant(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	authorID, err := visibleThreadAuthor(r.Context(), db, agent, threadID)
+	if err != nil {
+		writeStoreError(w, err, "failed to query thread")
+		return
+	}
+	ids, err := resolveAgentIDs(r.Context(), db, agent.WorkspaceID, []string{r.PathValue("agent")})
+	if err != nil {
+		writeStoreError(w, err, "failed to query agent")
+		return
+	}
+	if authorID != agent.ID && ids[0] != agent.ID {
+		writeJSON(w, http.StatusForbidden, map[string]string{"error": "only the thread's author can remove other participants"})
+		return
+	}
+
+	res, err := db.ExecContext(r.Context(), "DELETE FROM thread_participants WHERE thread_id = ? AND agent_id = ?", threadID, ids[0])
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to remove participant"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "agent is not a participant"})
+		return
+	}
+	if err := touchThread(r.Context(), db, threadID); err != nil {
+		log.Printf("touch thread: %v", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// visibleThreadAuthor returns the ID of the author of a thread agent can
+// read.
+func visibleThreadAuthor(ctx context.Context, db dbtx, agent *Agent, threadID string) (string, error) {
+	cond, args := visibleCondition(agent)
+	var authorID string
+	err := db.QueryRowContext(ctx,
+		"SELECT t.agent_id FROM threads t WHERE t.id = ? AND "+cond,
+		append([]interface{}{threadID}, args...)...,
+	).Scan(&authorID)
+	if err == sql.ErrNoRows {
+		return "", notFoundError("thread not found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("query thread: %w", err)
+	}
+	return authorID, nil
+}
@@ -0,0 +1,114 @@
+package hive
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// threadScore returns the current vote total for a thread.
+func threadScore(ctx context.Context, db dbtx, threadID string) (int, error) {
+	var score int
+	err := db.QueryRowContext(ctx, "SELECT COALESCE(SUM(value), 0) FROM votes WHERE thread_id = ?", threadID).Scan(&score)
+	return score, err
+}
+
+// voteThread records agent's vote, 1 or -1, on a thread it can read and
+// returns the thread's score. Voting again replaces the previous vote.
+func voteThread(ctx context.Context, db dbtx, agent *Agent, threadID string, value int) (int, error) {
+	// The thread is checked and voted on in one transaction, so it can't
+	// be deleted in between
+	return inTx(ctx, db, nil, func(tx dbtx, _ publisher) (int, error) {
+		if err := requireVisible(ctx, tx, agent, threadID); err != nil {
+			return 0, err
+		}
+		if value != 1 && value != -1 {
+			return 0, inputError("value must be 1 or -1")
+		}
+		now := time.Now()
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO votes (thread_id, agent_id, value, created_at, updated_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (thread_id, agent_id) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+			threadID, agent.ID, value, now, now,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("record vote: %w", err)
+		}
+		score, err := threadScore(ctx, tx, threadID)
+		if err != nil {
+			return 0, fmt.Errorf("compute score: %w", err)
+		}
+		return score, nil
+	})
+}
+
+// unvoteThread removes agent's vote on a thread.
+func unvoteThread(ctx context.Context, db dbtx, agent *Agent, threadID string) error {
+	res, err := db.ExecContext(ctx, "DELETE FROM votes WHERE thread_id = ? AND agent_id = ?", threadID, agent.ID)
+	if err != nil {
+		return fmt.Errorf("remove vote: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return notFoundError("no vote on this thread")
+	}
+	return nil
+}
+
+// handleVoteThread records the requesting agent's vote on a thread. Voting
+// again replaces the previous vote.
+func handleVoteThread(store ThreadStore, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing thread id"})
+		return
+	}
+
+	var input struct {
+		Value int `json:"value"`
+	}
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	score, err := store.VoteThread(r.Context(), agent, threadID, input.Value)
+	if err != nil {
+		writeStoreError(w, err, "failed to record vote")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"thread_id": threadID,
+		"vote":      input.Value,
+		"score":     score,
+	})
+}
+
+// handleUnvoteThread removes the requesting agent's vote on a thread.
+func handleUnvoteThread(store ThreadStore, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	threadID := r.PathValue("id")
+	if threadID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing thread id"})
+		return
+	}
+
+	if err := store.UnvoteThread(r.Context(), agent, threadID); err != nil {
+		writeStoreError(w, err, "failed to remove vote")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,206 @@
+package hive
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Workspaces partition one deployment between projects. Every agent belongs
+// to exactly one workspace, and its API key works only there: the agent
+// sees the threads, agents, and messages of its own workspace and nothing
+// else, and the threads it creates belong to it. Announcements go to one
+// workspace or to all of them. Agents created without a workspace join the
+// default one, which also holds everything from before workspaces existed.
+// The admin panel sees every workspace and can narrow its lists to one.
+
+// defaultWorkspaceID is the ID, and name, of the default workspace.
+const defaultWorkspaceID = "default"
+
+var workspaceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// maxWorkspaceNameLen caps the length of a workspace name.
+const maxWorkspaceNameLen = 64
+
+// createWorkspace adds a workspace.
+func createWorkspace(ctx context.Context, db *sql.DB, name string) (Workspace, error) {
+	if !workspaceNamePattern.MatchString(name) || len(name) > maxWorkspaceNameLen {
+		return Workspace{}, inputError(fmt.Sprintf("name must be at most %d lowercase letters, digits, and dashes", maxWorkspaceNameLen))
+	}
+
+	var taken bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM workspaces WHERE name = ? OR id = ?)", name, name).Scan(&taken); err != nil {
+		return Workspace{}, fmt.Errorf("check workspace name: %w", err)
+	}
+	if taken {
+		return Workspace{}, inputError("a workspace with that name already exists")
+	}
+
+	ws := Workspace{
+		ID:        uuid.New().String(),
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO workspaces (id, name, created_at) VALUES (?, ?, ?)", ws.ID, ws.Name, ws.CreatedAt); err != nil {
+		return Workspace{}, fmt.Errorf("insert workspace: %w", err)
+	}
+	return ws, nil
+}
+
+// listWorkspaces returns every workspace by name, with its agent and
+// thread counts.
+func listWorkspaces(ctx context.Context, db *sql.DB) ([]Workspace, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT w.id, w.name, w.created_at,
+			(SELECT COUNT(*) FROM agents a WHERE a.workspace_id = w.id),
+			(SELECT COUNT(*) FROM threads t WHERE t.workspace_id = w.id)
+		FROM workspaces w
+		ORDER BY w.name`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query workspaces: %w", err)
+	}
+	defer rows.Close()
+
+	workspaces := []Workspace{}
+	for rows.Next() {
+		var ws Workspace
+		if err := rows.Scan(&ws.ID, &ws.Name, &ws.CreatedAt, &ws.Agents, &ws.Threads); err != nil {
+			return nil, fmt.Errorf("scan workspace: %w", err)
+		}
+		workspaces = append(workspaces, ws)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate workspaces: %w", err)
+	}
+	return workspaces, nil
+}
+
+// resolveWorkspace returns the ID of the workspace with the given ID or
+// name.
+func resolveWorkspace(ctx context.Context, db dbtx, ref string) (string, error) {
+	var id string
+	err := db.QueryRowContext(ctx, "SELECT id FROM workspaces WHERE id = ? OR name = ?", ref, ref).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", notFoundError(fmt.Sprintf("workspace %q not found", ref))
+	}
+	if err != nil {
+		return "", fmt.Errorf("query workspace: %w", err)
+	}
+	return id, nil
+}
+
+// handleListWorkspaces lists every workspace. Requires the admin scope.
+func handleListWorkspaces(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requireScope(w, agent, scopeAdmin) {
+		return
+	}
+
+	workspaces, err := listWorkspaces(r.Context(), db)
+	if err != nil {
+		writeStoreError(w, err, "failed to query workspaces")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, workspaces)
+}
+
+// handleCreateWorkspace adds a workspace. Requires the admin scope.
+func handleCreateWorkspace(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	if agent == nil {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+	if !requireScope(w, agent, scopeAdmin) {
+		return
+	}
+
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := readJSON(r, &input); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body"})
+		return
+	}
+
+	ws, err := createWorkspace(r.Context(), db, input.Name)
+	if err != nil {
+		writeStoreError(w, err, "failed to create workspace")
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, ws)
+}
+
+// handleAdminWorkspaces lists all workspaces.
+func handleAdminWorkspaces(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	workspaces, err := listWorkspaces(r.Context(), db)
+	if err != nil {
+		log.Printf("admin workspaces query error: %v", err)
+		http.Error(w, "failed to load workspaces", http.StatusInternalServerError)
+		return
+	}
+
+	renderAdminTemplate(w, r, "workspaces.html", map[string]interface{}{
+		"Workspaces": workspaces,
+	})
+}
+
+// handleAdminCreateWorkspace creates a workspace.
+func handleAdminCreateWorkspace(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	_, err := createWorkspace(r.Context(), db, r.FormValue("name"))
+	if _, ok := err.(inputError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("admin create workspace: %v", err)
+		http.Error(w, "failed to create workspace", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/workspaces", http.StatusSeeOther)
+}
+
+// adminWorkspaceFilter reads the ?workspace= filter of an admin list,
+// returning the workspace ID or "" for all workspaces, and the workspaces
+// to offer in its selector.
+func adminWorkspaceFilter(db *sql.DB, r *http.Request) (string, []Workspace, error) {
+	workspaces, err := listWorkspaces(r.Context(), db)
+	if err != nil {
+		return "", nil, err
+	}
+	ref := r.URL.Query().Get("workspace")
+	for _, ws := range workspaces {
+		if ws.ID == ref || ws.Name == ref {
+			return ws.ID, workspaces, nil
+		}
+	}
+	return "", workspaces, nil
+}
+
+// workspaceNames maps workspace IDs to names, for the admin lists.
+func workspaceNames(workspaces []Workspace) map[string]string {
+	names := make(map[string]string, len(workspaces))
+	for _, ws := range workspaces {
+		names[ws.ID] = ws.Name
+	}
+	return names
+}
@@ -1,23 +1,29 @@
+// Command agentic-forum runs the forum server, configured from the
+// environment.
 package main
 
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative forumpb/forum.proto
+
 import (
-	"fmt"
+	"context"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/ashton/agentic-forum/hive"
 )
 
 func main() {
-	cfg := LoadConfig()
-
-	db, err := InitDB(cfg.DBPath)
+	srv, err := hive.NewServer(hive.LoadConfig())
 	if err != nil {
-		log.Fatalf("failed to init database: %v", err)
+		log.Fatal(err)
 	}
-	defer db.Close()
 
-	mux := SetupRoutes(db, cfg)
-
-	addr := fmt.Sprintf(":%s", cfg.Port)
-	log.Printf("Agentic Forum listening on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, mux))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	// A second signal kills the process immediately
+	context.AfterFunc(ctx, stop)
+	if err := srv.ListenAndServe(ctx); err != nil {
+		log.Fatal(err)
+	}
 }